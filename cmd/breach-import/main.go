@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/manorfm/authM/internal/infrastructure/password"
+	"go.uber.org/zap"
+)
+
+func main() {
+	// Parse command line flags
+	source := flag.String("source", "", "Corpus of SHA-1 HASH:COUNT lines ordered by hash, or - for stdin")
+	dir := flag.String("dir", "", "Corpus directory (defaults to BREACHED_PASSWORDS_DIR)")
+	minCount := flag.Int("min-count", 1, "Skip hashes seen fewer times than this")
+	flag.Parse()
+
+	// Initialize logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	if *source == "" {
+		logger.Fatal("A -source corpus is required")
+	}
+	if *dir == "" {
+		cfg, err := config.LoadConfig(logger)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		*dir = cfg.BreachedPasswordsDir
+	}
+	if *dir == "" {
+		logger.Fatal("No corpus directory, set -dir or BREACHED_PASSWORDS_DIR")
+	}
+
+	var input io.Reader = os.Stdin
+	if *source != "-" {
+		file, err := os.Open(*source)
+		if err != nil {
+			logger.Fatal("Failed to open corpus source", zap.Error(err))
+		}
+		defer file.Close()
+		input = file
+	}
+
+	logger.Info("Importing breached password corpus",
+		zap.String("source", *source),
+		zap.String("dir", *dir),
+		zap.Int("min_count", *minCount))
+
+	stats, err := password.ImportBreachCorpus(input, *dir, *minCount)
+	if err != nil {
+		logger.Fatal("Failed to import breached password corpus", zap.Error(err))
+	}
+
+	logger.Info("Breached password corpus imported successfully",
+		zap.Int("buckets", stats.Buckets),
+		zap.Int("hashes", stats.Hashes),
+		zap.Int("skipped", stats.Skipped))
+}
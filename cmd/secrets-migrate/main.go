@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/manorfm/authM/internal/infrastructure/secrets"
+	"go.uber.org/zap"
+)
+
+// secretColumns lists the tables holding authenticator app secrets and their key column
+var secretColumns = []struct {
+	table string
+	key   string
+}{
+	{table: "totp_secrets", key: "factor_id"},
+	{table: "totp_enrollments", key: "user_id"},
+}
+
+func main() {
+	// Initialize logger
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// Load configuration
+	cfg, err := config.LoadConfig(logger)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Create database connection
+	ctx := context.Background()
+	db, err := database.NewPostgres(ctx, cfg, logger)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	defer db.Close()
+
+	cipher := secrets.NewCipher(cfg, logger)
+
+	for _, column := range secretColumns {
+		sealed, err := sealSecrets(ctx, db, cipher, column.table, column.key)
+		if err != nil {
+			logger.Fatal("Failed to encrypt secrets", zap.String("table", column.table), zap.Error(err))
+		}
+		logger.Info("Secrets encrypted", zap.String("table", column.table), zap.Int("rows", sealed))
+	}
+}
+
+// sealSecrets encrypts the plaintext secrets of a table. A row is only updated if its secret is
+// unchanged, so running alongside the service, or more than once, is safe.
+func sealSecrets(ctx context.Context, db *database.Postgres, cipher domain.SecretCipher, table, key string) (int, error) {
+	rows, err := db.Query(ctx, fmt.Sprintf("SELECT %s, secret FROM %s", key, table))
+	if err != nil {
+		return 0, err
+	}
+
+	plaintexts := make(map[string]string)
+	for rows.Next() {
+		var id, secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !secrets.IsSealed(secret) {
+			plaintexts[id] = secret
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, secret := range plaintexts {
+		sealed, err := cipher.Encrypt(ctx, secret)
+		if err != nil {
+			return 0, err
+		}
+		query := fmt.Sprintf("UPDATE %s SET secret = $1 WHERE %s = $2 AND secret = $3", table, key)
+		if err := db.Exec(ctx, query, sealed, id, secret); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(plaintexts), nil
+}
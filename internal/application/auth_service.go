@@ -2,12 +2,20 @@ package application
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
 	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
@@ -15,8 +23,18 @@ type AuthService struct {
 	verificationRepo domain.VerificationCodeRepository
 	jwtService       domain.JWTService
 	emailService     domain.EmailService
+	mfaService       domain.MFAService
+	mfaPolicy        domain.MFAPolicyService
 	totpService      domain.TOTPService
 	mfaTicketRepo    domain.MFATicketRepository
+	deviceTrust      domain.TrustedDeviceService
+	loginHistory     domain.LoginHistoryService
+	passwordHasher   domain.PasswordHasher
+	passwordPolicy   domain.PasswordPolicyService
+	lockoutService   domain.LockoutService
+	directory        domain.CredentialVerifier
+	identityRepo     domain.FederatedIdentityRepository
+	config           *config.Config
 	logger           *zap.Logger
 }
 
@@ -25,8 +43,18 @@ func NewAuthService(
 	verificationRepo domain.VerificationCodeRepository,
 	jwtService domain.JWTService,
 	emailService domain.EmailService,
+	mfaService domain.MFAService,
+	mfaPolicy domain.MFAPolicyService,
 	totpService domain.TOTPService,
 	mfaTicketRepo domain.MFATicketRepository,
+	deviceTrust domain.TrustedDeviceService,
+	loginHistory domain.LoginHistoryService,
+	passwordHasher domain.PasswordHasher,
+	passwordPolicy domain.PasswordPolicyService,
+	lockoutService domain.LockoutService,
+	directory domain.CredentialVerifier,
+	identityRepo domain.FederatedIdentityRepository,
+	config *config.Config,
 	logger *zap.Logger,
 ) *AuthService {
 	return &AuthService{
@@ -34,8 +62,18 @@ func NewAuthService(
 		verificationRepo: verificationRepo,
 		jwtService:       jwtService,
 		emailService:     emailService,
+		mfaService:       mfaService,
+		mfaPolicy:        mfaPolicy,
 		totpService:      totpService,
 		mfaTicketRepo:    mfaTicketRepo,
+		deviceTrust:      deviceTrust,
+		loginHistory:     loginHistory,
+		passwordHasher:   passwordHasher,
+		passwordPolicy:   passwordPolicy,
+		lockoutService:   lockoutService,
+		directory:        directory,
+		identityRepo:     identityRepo,
+		config:           config,
 		logger:           logger,
 	}
 }
@@ -51,18 +89,11 @@ func (s *AuthService) Register(ctx context.Context, name, email, password, phone
 		return nil, domain.ErrUserAlreadyExists
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return nil, err
-	}
-
 	// Create user
 	user := &domain.User{
 		ID:            ulid.Make(),
 		Name:          name,
 		Email:         email,
-		Password:      string(hashedPassword),
 		Phone:         phone,
 		Roles:         []string{"ADMIN", "USER"},
 		EmailVerified: false,
@@ -70,9 +101,22 @@ func (s *AuthService) Register(ctx context.Context, name, email, password, phone
 		UpdatedAt:     time.Now(),
 	}
 
+	if err := s.passwordPolicy.Validate(ctx, user, password); err != nil {
+		return nil, err
+	}
+
+	// Hash password
+	hashedPassword, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+	user.Password = hashedPassword
+
 	if err := s.userRepo.Create(ctx, user); err != nil {
 		return nil, err
 	}
+	s.rememberPassword(ctx, user.ID, hashedPassword)
 
 	// Generate verification code
 	code := generateRandomCode()
@@ -93,45 +137,298 @@ func (s *AuthService) Register(ctx context.Context, name, email, password, phone
 	return user, nil
 }
 
+// Login signs a user in with their password. With a directory configured, unknown users and users
+// linked to the directory are checked against the directory instead.
 func (s *AuthService) Login(ctx context.Context, email, password string) (interface{}, error) {
+	ip, _ := domain.GetClientIP(ctx)
+	if err := s.lockoutService.Check(ctx, "", ip); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err != nil {
+		// Unknown logins are locked like accounts, so a locked answer does not reveal an account
+		if err := s.lockoutService.CheckUnknown(ctx, email); err != nil {
+			return nil, err
+		}
+		if err == domain.ErrUserNotFound && s.directory != nil {
+			return s.directoryLogin(ctx, nil, email, password, ip)
+		}
+		s.recordUnknownFailure(ctx, email, ip)
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if err := s.lockoutService.Check(ctx, user.ID.String(), ""); err != nil {
+		return nil, err
+	}
+
+	if s.directory != nil && user.Password == "" {
+		// Other accounts without a local password, such as federated ones, fail like a wrong password
+		linked, err := s.isDirectoryUser(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if linked {
+			return s.directoryLogin(ctx, user, email, password, ip)
+		}
+	}
+
+	ok, err := s.passwordHasher.Verify(password, user.Password)
+	if err != nil {
+		s.logger.Error("Failed to verify password hash",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+	}
+	if !ok {
+		s.recordFailure(ctx, user, ip)
 		return nil, domain.ErrInvalidCredentials
 	}
 
+	// Only told once the password is right, so the answer does not reveal an unverified account
 	if !user.EmailVerified {
 		return nil, domain.ErrEmailNotVerified
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, domain.ErrInvalidCredentials
+	if s.passwordHasher.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, user, password)
 	}
 
-	// Check if TOTP is enabled for the user
-	secret, err := s.totpService.GetTOTPSecret(ctx, user.ID.String())
+	return s.completeLogin(ctx, user, domain.NewAuthentication(domain.AMRPassword))
+}
+
+// directoryLogin checks the password of a user, known or not, against the directory and signs
+// them in like a local password would
+func (s *AuthService) directoryLogin(ctx context.Context, user *domain.User, login, password, ip string) (interface{}, error) {
+	entry, err := s.directory.Verify(ctx, login, password)
+	if err == domain.ErrInvalidCredentials {
+		if user == nil {
+			s.recordUnknownFailure(ctx, login, ip)
+		} else {
+			s.recordFailure(ctx, user, ip)
+		}
+		return nil, domain.ErrInvalidCredentials
+	}
 	if err != nil {
-		// If TOTP is not enabled, proceed with normal login
-		if err == domain.ErrTOTPNotEnabled || secret == "" {
-			tokenPair, err := s.jwtService.GenerateTokenPair(user.ID, user.Roles)
+		s.logger.Error("Failed to verify password against the directory", zap.Error(err))
+		return nil, domain.ErrDirectoryUnavailable
+	}
+
+	if user, err = s.syncDirectoryUser(ctx, user, login, entry); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user, domain.NewAuthentication(domain.AMRPassword))
+}
+
+// syncDirectoryUser finds the local user of a directory user by the identity linking them to
+// their DN, provisioning one on their first sign-in, and refreshes their name and roles, which the
+// directory stays authoritative for. Accounts not linked to the directory are never taken over.
+func (s *AuthService) syncDirectoryUser(ctx context.Context, user *domain.User, login string, entry *domain.DirectoryUser) (*domain.User, error) {
+	email := entry.Email
+	if email == "" {
+		email = login
+	}
+
+	identity, err := s.identityRepo.FindByProviderSubject(ctx, domain.DirectoryIdentityProvider, entry.DN)
+	switch {
+	case err == nil && user != nil && user.ID != identity.UserID:
+		// The login is the email of another account than the one linked to the entry
+		s.logger.Warn("Directory user linked to another account",
+			zap.String("user_id", user.ID.String()),
+			zap.String("dn", entry.DN))
+		return nil, domain.ErrInvalidCredentials
+	case err == nil && user == nil:
+		if user, err = s.userRepo.FindByID(ctx, identity.UserID); err != nil {
+			s.logger.Error("Failed to find directory user", zap.String("dn", entry.DN), zap.Error(err))
+			return nil, domain.ErrInternal
+		}
+		if err := s.lockoutService.Check(ctx, user.ID.String(), ""); err != nil {
+			return nil, err
+		}
+	case err == domain.ErrFederatedIdentityNotFound:
+		identity = nil
+	case err != nil:
+		s.logger.Error("Failed to find directory identity", zap.String("dn", entry.DN), zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	// The login, such as an Active Directory user principal name, may differ from the email
+	if identity == nil && user == nil && email != login {
+		found, err := s.userRepo.FindByEmail(ctx, email)
+		switch {
+		case err == nil:
+			linked, err := s.isDirectoryUser(ctx, found.ID)
 			if err != nil {
 				return nil, err
 			}
-			return tokenPair, nil
+			if !linked {
+				s.logger.Warn("Directory user matches an account not linked to the directory",
+					zap.String("user_id", found.ID.String()),
+					zap.String("dn", entry.DN))
+				return nil, domain.ErrInvalidCredentials
+			}
+			if err := s.lockoutService.Check(ctx, found.ID.String(), ""); err != nil {
+				return nil, err
+			}
+			user = found
+		case err != domain.ErrUserNotFound:
+			s.logger.Error("Failed to find directory user", zap.String("dn", entry.DN), zap.Error(err))
+			return nil, domain.ErrInternal
+		}
+	}
+
+	if user == nil {
+		name := entry.Name
+		if name == "" {
+			name = email
+		}
+		user, err := domain.NewUser(name, email, "", "")
+		if err != nil {
+			return nil, err
+		}
+		user.Roles = entry.Roles
+		user.EmailVerified = true
+
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			s.logger.Error("Failed to provision directory user", zap.String("dn", entry.DN), zap.Error(err))
+			return nil, domain.ErrInternal
+		}
+		if err := s.linkDirectoryUser(ctx, user, entry.DN, email); err != nil {
+			return nil, err
 		}
+		s.logger.Info("User provisioned from directory",
+			zap.String("user_id", user.ID.String()),
+			zap.String("dn", entry.DN))
+		return user, nil
+	}
 
-		s.logger.Error("Failed to check TOTP status",
+	if identity == nil {
+		// A directory user whose entry was renamed or moved keeps their account under the new DN
+		if err := s.linkDirectoryUser(ctx, user, entry.DN, email); err != nil {
+			return nil, err
+		}
+	} else if err := s.identityRepo.Touch(ctx, identity.ID, email, time.Now()); err != nil {
+		s.logger.Warn("Failed to record directory sign-in",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+	}
+
+	if entry.Name != "" {
+		user.Name = entry.Name
+	}
+	user.Roles = entry.Roles
+	user.EmailVerified = true
+	user.UpdatedAt = time.Now()
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		s.logger.Error("Failed to refresh directory user",
 			zap.String("user_id", user.ID.String()),
 			zap.Error(err))
 		return nil, domain.ErrInternal
 	}
 
-	// Generate MFA ticket
-	ticketID := ulid.Make()
+	return user, nil
+}
+
+// isDirectoryUser reports whether the user is linked to a directory entry
+func (s *AuthService) isDirectoryUser(ctx context.Context, userID ulid.ULID) (bool, error) {
+	identities, err := s.identityRepo.ListByUser(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list user identities", zap.String("user_id", userID.String()), zap.Error(err))
+		return false, domain.ErrInternal
+	}
+	for _, identity := range identities {
+		if identity.Provider == domain.DirectoryIdentityProvider {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// linkDirectoryUser records the directory entry a user signs in with
+func (s *AuthService) linkDirectoryUser(ctx context.Context, user *domain.User, dn, email string) error {
+	identity := domain.NewFederatedIdentity(user.ID, domain.DirectoryIdentityProvider, dn, email)
+	now := time.Now()
+	identity.LastLoginAt = &now
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		s.logger.Error("Failed to link directory user",
+			zap.String("user_id", user.ID.String()),
+			zap.String("dn", dn),
+			zap.Error(err))
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+// completeLogin finishes a first-factor login, returning a token pair, or an MFA ticket listing
+// the user's factors when they have enrolled any. Backup codes alone do not require MFA. Users
+// the MFA policy applies to, for their roles or for the client and scopes in the context, get an
+// enrolment ticket instead of tokens once their grace period is over. Tickets remember the methods
+// of the first factor, which the second one completes. A device token in the context of a device
+// the user trusted lets them skip MFA. With LoginRiskMFA, users without a factor signing in from a
+// new device get a ticket redeemed with a code sent to their email.
+func (s *AuthService) completeLogin(ctx context.Context, user *domain.User, auth *domain.Authentication) (interface{}, error) {
+	factors, err := s.mfaService.ListFactors(ctx, user.ID.String())
+	if err != nil {
+		s.logger.Error("Failed to list MFA factors",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	now := time.Now()
 	ticket := &domain.MFATicket{
-		Ticket:    ticketID,
-		User:      user.ID.String(),
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(5 * time.Minute),
+		Ticket:      ulid.Make(),
+		User:        user.ID.String(),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(5 * time.Minute),
+		FactorTypes: domain.MFAFactorTypes(factors),
+		Factors:     factors,
+		AMR:         auth.Methods,
+	}
+
+	if requiresMFA(factors) {
+		if token, ok := domain.GetDeviceToken(ctx); ok && s.deviceTrust.Verify(ctx, user, token) {
+			s.logger.Info("MFA skipped on a trusted device", zap.String("user_id", user.ID.String()))
+			s.recordSuccess(ctx, user, auth)
+			return s.jwtService.GenerateTokenPairWithParams(user.ID, user.Roles, &domain.TokenParams{Authentication: auth})
+		}
+	} else {
+		clientID, _ := domain.GetClientID(ctx)
+		scopes, _ := domain.GetScopes(ctx)
+		requirement := s.mfaPolicy.Evaluate(user, clientID, scopes)
+
+		switch {
+		case requirement.Required() && !requirement.InGracePeriod(now):
+			// The ticket only allows enrolling an authenticator app, which completes the login
+			s.logger.Info("MFA enrolment required",
+				zap.String("user_id", user.ID.String()),
+				zap.Strings("reasons", requirement.Reasons))
+			ticket.EnrollmentRequired = true
+			ticket.ExpiresAt = now.Add(s.config.TOTPEnrollmentTTL)
+			ticket.FactorTypes = []domain.MFAFactorType{domain.MFAFactorTOTP}
+			ticket.Factors = nil
+
+		case s.config.LoginRiskMFA && user.EmailVerified && s.loginHistory.IsNewDevice(ctx, user):
+			// The ticket is redeemed with a code sent to the user's email
+			s.logger.Info("Login from a new device needs confirming", zap.String("user_id", user.ID.String()))
+			ticket.RiskChallenge = true
+			ticket.FactorTypes = []domain.MFAFactorType{domain.MFAFactorEmailOTP}
+			ticket.Factors = nil
+
+		default:
+			if requirement.Required() {
+				s.logger.Info("Login without MFA during the enrolment grace period",
+					zap.String("user_id", user.ID.String()),
+					zap.Strings("reasons", requirement.Reasons),
+					zap.Time("grace_ends_at", requirement.GraceEndsAt))
+			}
+			s.recordSuccess(ctx, user, auth)
+			tokenPair, err := s.jwtService.GenerateTokenPairWithParams(user.ID, user.Roles, &domain.TokenParams{Authentication: auth})
+			if err != nil {
+				return nil, err
+			}
+			return tokenPair, nil
+		}
 	}
 
 	if err := s.mfaTicketRepo.Create(ctx, ticket); err != nil {
@@ -144,6 +441,16 @@ func (s *AuthService) Login(ctx context.Context, email, password string) (interf
 	return ticket, nil
 }
 
+// requiresMFA reports whether any of the factors can answer an MFA challenge on its own
+func requiresMFA(factors []*domain.MFAFactor) bool {
+	for _, factor := range factors {
+		if factor.Type != domain.MFAFactorRecovery {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *AuthService) VerifyEmail(ctx context.Context, email, code string) error {
 	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err != nil {
@@ -235,6 +542,8 @@ func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) er
 	return nil
 }
 
+// ResetPassword sets a new password with the code sent by RequestPasswordReset. Trusted devices of
+// the user stop skipping MFA.
 func (s *AuthService) ResetPassword(ctx context.Context, email, code, newPassword string) error {
 	user, err := s.userRepo.FindByEmail(ctx, email)
 	if err != nil {
@@ -264,20 +573,356 @@ func (s *AuthService) ResetPassword(ctx context.Context, email, code, newPasswor
 		return domain.ErrPasswordChangeCodeExpired
 	}
 
+	// The code stays valid when the password is rejected, so the user can pick another one
+	if err := s.passwordPolicy.Validate(ctx, user, newPassword); err != nil {
+		return err
+	}
+
 	// Delete the used code
 	deleteCode()
 
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwordHasher.Hash(newPassword)
 	if err != nil {
-		return err
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return domain.ErrInternal
 	}
 
 	// Update password using dedicated method
-	return s.userRepo.UpdatePassword(ctx, user.ID, string(hashedPassword))
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return err
+	}
+	s.rememberPassword(ctx, user.ID, hashedPassword)
+
+	return s.revokeTrustedDevices(ctx, user.ID.String())
+}
+
+// ChangePassword changes the password of a signed-in user after checking their current one.
+// Tokens issued before the change and trusted devices stop working, and the caller gets a new
+// token pair.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (*domain.TokenPair, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.verifyCurrentPassword(ctx, user, currentPassword); err != nil {
+		return nil, err
+	}
+
+	if err := s.passwordPolicy.Validate(ctx, user, newPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.passwordHasher.Hash(newPassword)
+	if err != nil {
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return nil, err
+	}
+	s.rememberPassword(ctx, user.ID, hashedPassword)
+
+	// A pending reset code would otherwise still allow setting another password
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, user.ID, domain.PasswordReset); err != nil {
+		s.logger.Error("Failed to delete reset codes", zap.String("user_id", userID), zap.Error(err))
+	}
+
+	// Tokens carry their issue time in whole seconds, so the cutoff is truncated to keep the new pair valid
+	if err := s.userRepo.RevokeSessions(ctx, user.ID, time.Now().Truncate(time.Second)); err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.String("user_id", userID), zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	if err := s.revokeTrustedDevices(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Password changed", zap.String("user_id", userID))
+
+	// The new pair continues the caller's session, so it keeps the way they signed in
+	auth, _ := domain.GetAuthentication(ctx)
+	return s.jwtService.GenerateTokenPairWithParams(user.ID, user.Roles, &domain.TokenParams{Authentication: auth})
+}
+
+// RequestEmailChange starts an email change. The current email stays in place until the code sent
+// to the new address is confirmed, and the current address is told about the request.
+func (s *AuthService) RequestEmailChange(ctx context.Context, userID, newEmail, password string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyCurrentPassword(ctx, user, password); err != nil {
+		return err
+	}
+
+	exists, err := s.userRepo.ExistsByEmail(ctx, newEmail)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrUserAlreadyExists
+	}
+
+	// Only the latest request can be confirmed
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, user.ID, domain.EmailChange); err != nil {
+		s.logger.Error("Failed to delete existing email change codes", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	code := generateRandomCode()
+	if err := s.verificationRepo.Create(ctx, domain.NewEmailChangeCode(user.ID, code, newEmail, 1*time.Hour)); err != nil {
+		s.logger.Error("Failed to store email change code", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	if err := s.emailService.SendEmailChangeEmail(ctx, newEmail, code); err != nil {
+		s.logger.Error("Failed to send email change email", zap.Error(err))
+		return domain.ErrEmailSendFailed
+	}
+
+	// The change cannot happen without the new address, so a failed notice is only logged
+	if err := s.emailService.SendEmailChangeNoticeEmail(ctx, user.Email, newEmail); err != nil {
+		s.logger.Error("Failed to send email change notice", zap.String("user_id", userID), zap.Error(err))
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange swaps in the new email of a pending change. The new address is verified
+// by the confirmation itself.
+func (s *AuthService) ConfirmEmailChange(ctx context.Context, userID, code string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	changeCode, err := s.verificationRepo.FindByUserIDAndType(ctx, user.ID, domain.EmailChange)
+	if err != nil {
+		return domain.ErrInvalidVerificationCode
+	}
+
+	if changeCode.Code != code {
+		return domain.ErrInvalidVerificationCode
+	}
+
+	var deleteCode = func() {
+		if err := s.verificationRepo.DeleteByUserIDAndType(ctx, user.ID, domain.EmailChange); err != nil {
+			s.logger.Error("Failed to delete email change code", zap.Error(err))
+		}
+	}
+
+	if changeCode.IsExpired() {
+		deleteCode()
+		return domain.ErrVerificationCodeExpired
+	}
+
+	// The address may have been registered since the change was requested
+	exists, err := s.userRepo.ExistsByEmail(ctx, changeCode.Target)
+	if err != nil {
+		return err
+	}
+	if exists {
+		deleteCode()
+		return domain.ErrUserAlreadyExists
+	}
+
+	if err := s.userRepo.UpdateEmail(ctx, user.ID, changeCode.Target); err != nil {
+		if err == domain.ErrUserAlreadyExists {
+			deleteCode()
+		}
+		return err
+	}
+	deleteCode()
+
+	s.logger.Info("Email changed", zap.String("user_id", userID))
+	return nil
+}
+
+// RequestPasswordlessLogin emails a single-use sign-in code or link. Unknown and unverified
+// addresses get no email but the same answer, so the endpoint does not reveal who is registered.
+func (s *AuthService) RequestPasswordlessLogin(ctx context.Context, email string, method domain.PasswordlessMethod) error {
+	if !s.config.PasswordlessEnabled || (method == domain.PasswordlessLink && s.config.PasswordlessLinkURL == "") {
+		return domain.ErrPasswordlessDisabled
+	}
+
+	if err := s.lockoutService.Throttle(ctx, domain.PasswordlessAttemptsKey(email), s.config.PasswordlessRateLimit, s.config.PasswordlessRateWindow); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil || !user.EmailVerified {
+		s.logger.Debug("Passwordless login requested for unknown or unverified email")
+		return nil
+	}
+
+	// Only the latest code can be redeemed
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, user.ID, domain.PasswordlessLogin); err != nil {
+		s.logger.Error("Failed to delete existing passwordless codes", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	var secret string
+	if method == domain.PasswordlessLink {
+		secret, err = generateLinkToken()
+	} else {
+		secret, err = generateNumericCode(s.config.PasswordlessCodeLength)
+	}
+	if err != nil {
+		s.logger.Error("Failed to generate passwordless secret", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	code := domain.NewVerificationCode(user.ID, hashOneTimeSecret(user.ID, secret), domain.PasswordlessLogin, s.config.PasswordlessCodeTTL)
+	if err := s.verificationRepo.Create(ctx, code); err != nil {
+		s.logger.Error("Failed to store passwordless code", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	if method == domain.PasswordlessLink {
+		err = s.emailService.SendMagicLinkEmail(ctx, email, linkWithQuery(s.config.PasswordlessLinkURL, url.Values{"email": {email}, "token": {secret}}))
+	} else {
+		err = s.emailService.SendPasswordlessCodeEmail(ctx, email, secret)
+	}
+	if err != nil {
+		s.logger.Error("Failed to send passwordless login email", zap.Error(err))
+		return domain.ErrEmailSendFailed
+	}
+
+	return nil
 }
 
-func (s *AuthService) VerifyMFA(ctx context.Context, ticketID, code string) (*domain.TokenPair, error) {
+// PasswordlessLogin redeems a sign-in code or the token of a magic link. Wrong codes count towards
+// the lockout like wrong passwords, which keeps short numeric codes from being guessed.
+func (s *AuthService) PasswordlessLogin(ctx context.Context, email, code string) (interface{}, error) {
+	if !s.config.PasswordlessEnabled {
+		return nil, domain.ErrPasswordlessDisabled
+	}
+
+	ip, _ := domain.GetClientIP(ctx)
+	if err := s.lockoutService.Check(ctx, "", ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		if err := s.lockoutService.CheckUnknown(ctx, email); err != nil {
+			return nil, err
+		}
+		s.recordUnknownFailure(ctx, email, ip)
+		return nil, domain.ErrInvalidVerificationCode
+	}
+
+	if err := s.lockoutService.Check(ctx, user.ID.String(), ""); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.verificationRepo.FindByUserIDAndType(ctx, user.ID, domain.PasswordlessLogin)
+	if err != nil || subtle.ConstantTimeCompare([]byte(stored.Code), []byte(hashOneTimeSecret(user.ID, code))) != 1 {
+		s.recordFailure(ctx, user, ip)
+		return nil, domain.ErrInvalidVerificationCode
+	}
+
+	// Codes are single-use, so a code that cannot be deleted is not honoured
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, user.ID, domain.PasswordlessLogin); err != nil {
+		s.logger.Error("Failed to delete passwordless code", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	if stored.IsExpired() {
+		return nil, domain.ErrVerificationCodeExpired
+	}
+
+	return s.completeLogin(ctx, user, domain.NewAuthentication(domain.AMROTP))
+}
+
+// CompleteLogin finishes a sign-in whose first factor was verified elsewhere, the same way as a
+// password login once the password is accepted. Locked accounts and client IPs are refused.
+func (s *AuthService) CompleteLogin(ctx context.Context, user *domain.User, auth *domain.Authentication) (interface{}, error) {
+	ip, _ := domain.GetClientIP(ctx)
+	if err := s.lockoutService.Check(ctx, user.ID.String(), ip); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user, auth)
+}
+
+// VerifyMFA redeems an MFA ticket with a code of the chosen factor, or with the emailed code for
+// a ticket of a login from a new device. With trust set, the device is remembered and the tokens
+// carry its device token; a device that cannot be trusted does not fail the login, which then
+// comes without a device token.
+func (s *AuthService) VerifyMFA(ctx context.Context, ticketID, factorID, code string, trust *domain.DeviceTrust) (*domain.TokenPair, error) {
+	ticket, err := s.mfaTicketRepo.Get(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	var verified *domain.User
+	tokenPair, err := s.CompleteMFA(ctx, ticketID, func(user *domain.User) (domain.MFAFactorType, error) {
+		verified = user
+		if ticket.RiskChallenge {
+			return domain.MFAFactorEmailOTP, s.mfaService.VerifyEmailCode(ctx, user, code)
+		}
+		return s.mfaService.VerifyFactor(ctx, user, factorID, code)
+	})
+	if err != nil || trust == nil {
+		return tokenPair, err
+	}
+
+	device, token, err := s.deviceTrust.Trust(ctx, verified, trust.Name)
+	if err != nil {
+		s.logger.Warn("Device not trusted",
+			zap.String("user_id", verified.ID.String()),
+			zap.Error(err))
+		return tokenPair, nil
+	}
+	tokenPair.DeviceToken = token
+	tokenPair.DeviceExpiresAt = &device.ExpiresAt
+	return tokenPair, nil
+}
+
+// ChallengeMFA sends the code of a factor that delivers one, or the emailed code of a ticket of a
+// login from a new device, at most MFAMaxAttempts times per ticket. The channel only matters to
+// sms_otp factors.
+func (s *AuthService) ChallengeMFA(ctx context.Context, ticketID, factorID string, channel domain.SMSChannel) error {
+	ticket, err := s.mfaTicketRepo.Get(ctx, ticketID)
+	if err != nil {
+		return err
+	}
+
+	if time.Now().After(ticket.ExpiresAt) {
+		s.mfaTicketRepo.Delete(ctx, ticketID)
+		return domain.ErrMFATicketExpired
+	}
+
+	if err := s.lockoutService.Throttle(ctx, domain.MFAChallengeKey(ticketID), s.config.MFAMaxAttempts, ticket.ExpiresAt.Sub(ticket.CreatedAt)); err != nil {
+		return err
+	}
+
+	userID, err := ulid.Parse(ticket.User)
+	if err != nil {
+		s.logger.Error("Invalid user ID", zap.String("ticket_id", ticketID), zap.Error(err))
+		return domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		s.logger.Error("User not found", zap.String("ticket_id", ticketID), zap.Error(err))
+		return domain.ErrUserNotFound
+	}
+
+	if ticket.RiskChallenge {
+		return s.mfaService.SendEmailCode(ctx, user)
+	}
+	return s.mfaService.SendChallenge(ctx, user, factorID, channel)
+}
+
+// CompleteMFA redeems an MFA ticket once verify accepts the second factor presented for its user.
+// The tokens record the first factor of the ticket together with the type of factor verify reports.
+func (s *AuthService) CompleteMFA(ctx context.Context, ticketID string, verify func(user *domain.User) (domain.MFAFactorType, error)) (*domain.TokenPair, error) {
 	// Get and validate ticket
 	ticket, err := s.mfaTicketRepo.Get(ctx, ticketID)
 	if err != nil {
@@ -290,6 +935,12 @@ func (s *AuthService) VerifyMFA(ctx context.Context, ticketID, code string) (*do
 		return nil, domain.ErrMFATicketExpired
 	}
 
+	if ticket.Attempts >= s.config.MFAMaxAttempts {
+		s.logger.Warn("MFA ticket has no attempts left", zap.String("ticket_id", ticketID))
+		s.mfaTicketRepo.Delete(ctx, ticketID)
+		return nil, domain.ErrMFAAttemptsExceeded
+	}
+
 	// Get user
 	userID, err := ulid.Parse(ticket.User)
 	if err != nil {
@@ -303,10 +954,28 @@ func (s *AuthService) VerifyMFA(ctx context.Context, ticketID, code string) (*do
 		return nil, domain.ErrUserNotFound
 	}
 
-	// Verify TOTP code
-	err = s.totpService.VerifyTOTP(user.ID.String(), code)
+	ip, _ := domain.GetClientIP(ctx)
+	if err := s.lockoutService.Check(ctx, user.ID.String(), ip); err != nil {
+		return nil, err
+	}
+
+	// Verify the second factor
+	factorType, err := verify(user)
 	if err != nil {
-		s.logger.Error("Invalid TOTP code", zap.String("ticket_id", ticketID), zap.Error(err))
+		s.logger.Error("Invalid MFA verification", zap.String("ticket_id", ticketID), zap.Error(err))
+		s.recordFailure(ctx, user, ip)
+
+		attempts, incErr := s.mfaTicketRepo.IncrementAttempts(ctx, ticketID)
+		if incErr != nil {
+			s.logger.Error("Failed to record MFA attempt",
+				zap.String("ticket_id", ticketID),
+				zap.Error(incErr))
+			return nil, domain.ErrInternal
+		}
+		if attempts >= s.config.MFAMaxAttempts {
+			s.mfaTicketRepo.Delete(ctx, ticketID)
+			return nil, domain.ErrMFAAttemptsExceeded
+		}
 		return nil, err
 	}
 
@@ -319,7 +988,10 @@ func (s *AuthService) VerifyMFA(ctx context.Context, ticketID, code string) (*do
 	}
 
 	// Generate token pair with MFA AMR
-	tokenPair, err := s.jwtService.GenerateTokenPair(user.ID, user.Roles)
+	auth := (&domain.Authentication{Methods: ticket.AMR}).WithFactor(factorType)
+	s.recordSuccess(ctx, user, auth)
+
+	tokenPair, err := s.jwtService.GenerateTokenPairWithParams(user.ID, user.Roles, &domain.TokenParams{Authentication: auth})
 	if err != nil {
 		return nil, err
 	}
@@ -327,6 +999,209 @@ func (s *AuthService) VerifyMFA(ctx context.Context, ticketID, code string) (*do
 	return tokenPair, nil
 }
 
+// BeginMFAEnrollment starts enrolling an authenticator app for the user of an enrolment ticket
+func (s *AuthService) BeginMFAEnrollment(ctx context.Context, ticketID, label string) (*domain.TOTP, error) {
+	ticket, err := s.enrollmentTicket(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.totpService.EnableTOTP(ticket.User, label)
+}
+
+// CompleteMFAEnrollment confirms the authenticator app started with an enrolment ticket and redeems
+// the ticket, returning the tokens together with the new factor and the user's backup codes
+func (s *AuthService) CompleteMFAEnrollment(ctx context.Context, ticketID, code string) (*domain.MFAEnrollment, error) {
+	if _, err := s.enrollmentTicket(ctx, ticketID); err != nil {
+		return nil, err
+	}
+
+	var enrolled *domain.TOTP
+	tokenPair, err := s.CompleteMFA(ctx, ticketID, func(user *domain.User) (domain.MFAFactorType, error) {
+		var err error
+		enrolled, err = s.totpService.ConfirmTOTP(ctx, user.ID.String(), code)
+		return domain.MFAFactorTOTP, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.MFAEnrollment{
+		TokenPair:   *tokenPair,
+		FactorID:    enrolled.FactorID,
+		BackupCodes: enrolled.BackupCodes,
+	}, nil
+}
+
+// enrollmentTicket returns an unexpired enrolment ticket. Tickets of users who already have a
+// factor, such as one enrolled since the ticket was issued, are refused, so a password alone never
+// enrols another one. Backup codes alone do not count, as they do not require MFA either.
+func (s *AuthService) enrollmentTicket(ctx context.Context, ticketID string) (*domain.MFATicket, error) {
+	ticket, err := s.mfaTicketRepo.Get(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ticket.EnrollmentRequired {
+		return nil, domain.ErrInvalidMFATicket
+	}
+
+	if time.Now().After(ticket.ExpiresAt) {
+		s.mfaTicketRepo.Delete(ctx, ticketID)
+		return nil, domain.ErrMFATicketExpired
+	}
+
+	factors, err := s.mfaService.ListFactors(ctx, ticket.User)
+	if err != nil {
+		s.logger.Error("Failed to list MFA factors",
+			zap.String("user_id", ticket.User),
+			zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+	if requiresMFA(factors) {
+		s.logger.Warn("Enrolment ticket of a user who has a factor",
+			zap.String("ticket_id", ticketID),
+			zap.String("user_id", ticket.User))
+		return nil, domain.ErrInvalidMFATicket
+	}
+
+	return ticket, nil
+}
+
+// findUser loads the signed-in user identified by the token subject
+func (s *AuthService) findUser(ctx context.Context, userID string) (*domain.User, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("User not found", zap.String("user_id", userID), zap.Error(err))
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// verifyCurrentPassword re-authenticates a signed-in user before a sensitive change. Wrong
+// passwords count towards the lockout, so a stolen session cannot be used to guess it.
+func (s *AuthService) verifyCurrentPassword(ctx context.Context, user *domain.User, password string) error {
+	ip, _ := domain.GetClientIP(ctx)
+	if err := s.lockoutService.Check(ctx, user.ID.String(), ip); err != nil {
+		return err
+	}
+
+	ok, err := s.passwordHasher.Verify(password, user.Password)
+	if err != nil {
+		s.logger.Error("Failed to verify password hash",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+	}
+	if !ok {
+		s.recordFailure(ctx, user, ip)
+		return domain.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// rehashPassword upgrades a hash made with an outdated algorithm or parameters while the plain
+// password is at hand; the login goes ahead even if the upgrade fails
+func (s *AuthService) rehashPassword(ctx context.Context, user *domain.User, password string) {
+	hashedPassword, err := s.passwordHasher.Hash(password)
+	if err != nil {
+		s.logger.Error("Failed to rehash password", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		s.logger.Error("Failed to store rehashed password", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return
+	}
+
+	s.logger.Info("Password rehashed with current parameters", zap.String("user_id", user.ID.String()))
+}
+
+// rememberPassword adds a newly set password to the user's history; the password is already
+// stored, so a failure is only logged
+func (s *AuthService) rememberPassword(ctx context.Context, userID ulid.ULID, hashedPassword string) {
+	if err := s.passwordPolicy.Remember(ctx, userID, hashedPassword); err != nil {
+		s.logger.Error("Failed to record password history", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+}
+
+// recordFailure counts a failed password or MFA attempt; lockout bookkeeping never changes the
+// answer given to the caller, so errors are only logged
+func (s *AuthService) recordFailure(ctx context.Context, user *domain.User, ip string) {
+	if err := s.lockoutService.RecordFailure(ctx, user, ip); err != nil {
+		s.logger.Error("Failed to record failed attempt", zap.String("ip", ip), zap.Error(err))
+	}
+}
+
+// revokeTrustedDevices revokes every trusted device of a user whose password changed, so a device
+// trusted by whoever knew the old password cannot skip MFA any more
+func (s *AuthService) revokeTrustedDevices(ctx context.Context, userID string) error {
+	if err := s.deviceTrust.RevokeAll(ctx, userID); err != nil {
+		s.logger.Error("Failed to revoke trusted devices", zap.String("user_id", userID), zap.Error(err))
+		return domain.ErrInternal
+	}
+	return nil
+}
+
+// recordUnknownFailure counts a failed attempt at a login that matches no account; like
+// recordFailure, errors are only logged
+func (s *AuthService) recordUnknownFailure(ctx context.Context, login, ip string) {
+	if err := s.lockoutService.RecordUnknownFailure(ctx, login, ip); err != nil {
+		s.logger.Error("Failed to record failed attempt", zap.String("ip", ip), zap.Error(err))
+	}
+}
+
+// recordSuccess clears the failed attempts of a user once they are fully authenticated and adds
+// the login to their history
+func (s *AuthService) recordSuccess(ctx context.Context, user *domain.User, auth *domain.Authentication) {
+	if err := s.lockoutService.RecordSuccess(ctx, user.ID.String()); err != nil {
+		s.logger.Error("Failed to reset failed attempts", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+	s.loginHistory.Record(ctx, user, auth)
+}
+
+// generateNumericCode returns a random code of the given number of digits
+func generateNumericCode(length int) (string, error) {
+	digits := make([]byte, length)
+	for i := range digits {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		digits[i] = byte('0' + n.Int64())
+	}
+	return string(digits), nil
+}
+
+// generateLinkToken returns a 256-bit random token for a magic link
+func generateLinkToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashOneTimeSecret hashes a sign-in or MFA secret for storage; the user ID keeps equal codes of
+// different users from sharing a hash
+func hashOneTimeSecret(userID ulid.ULID, secret string) string {
+	sum := sha256.Sum256([]byte(userID.String() + ":" + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// linkWithQuery appends the query parameters to a configured page URL, which may already carry some
+func linkWithQuery(baseURL string, params url.Values) string {
+	query := params.Encode()
+	if strings.Contains(baseURL, "?") {
+		return baseURL + "&" + query
+	}
+	return baseURL + "?" + query
+}
+
 func generateRandomCode() string {
 	// Generate a ULID which provides good entropy and is time-ordered
 	id := ulid.Make()
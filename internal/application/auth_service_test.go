@@ -3,15 +3,21 @@ package application
 import (
 	"context"
 	"crypto/rsa"
+	"errors"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
 	"github.com/manorfm/authM/internal/infrastructure/password"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type MockUserRepository struct {
@@ -77,6 +83,21 @@ func (m *MockUserRepository) UpdatePassword(ctx context.Context, userID ulid.ULI
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) UpdateEmail(ctx context.Context, userID ulid.ULID, email string) error {
+	args := m.Called(ctx, userID, email)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdatePhone(ctx context.Context, userID ulid.ULID, phone string) error {
+	args := m.Called(ctx, userID, phone)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) RevokeSessions(ctx context.Context, userID ulid.ULID, before time.Time) error {
+	args := m.Called(ctx, userID, before)
+	return args.Error(0)
+}
+
 type mockEmailService struct {
 	mock.Mock
 }
@@ -91,8 +112,55 @@ func (m *mockEmailService) SendPasswordResetEmail(ctx context.Context, email, co
 	return args.Error(0)
 }
 
+func (m *mockEmailService) SendAccountLockedEmail(ctx context.Context, email string, lockedUntil time.Time) error {
+	args := m.Called(ctx, email, lockedUntil)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendEmailChangeEmail(ctx context.Context, email, code string) error {
+	args := m.Called(ctx, email, code)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendEmailChangeNoticeEmail(ctx context.Context, email, newEmail string) error {
+	args := m.Called(ctx, email, newEmail)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendPasswordlessCodeEmail(ctx context.Context, email, code string) error {
+	args := m.Called(ctx, email, code)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendMagicLinkEmail(ctx context.Context, email, link string) error {
+	args := m.Called(ctx, email, link)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendMFACodeEmail(ctx context.Context, email, code string) error {
+	args := m.Called(ctx, email, code)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendBackupCodesLowEmail(ctx context.Context, email string, remaining int) error {
+	args := m.Called(ctx, email, remaining)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendMFAResetEmail(ctx context.Context, email string, resetAt time.Time) error {
+	args := m.Called(ctx, email, resetAt)
+	return args.Error(0)
+}
+
+func (m *mockEmailService) SendNewSignInEmail(ctx context.Context, email string, event *domain.LoginEvent, revokeLink string) error {
+	args := m.Called(ctx, email, event, revokeLink)
+	return args.Error(0)
+}
+
 type mockJWTService struct {
 	mock.Mock
+	// params records the grant details of the last token pair
+	params *domain.TokenParams
 }
 
 func (m *mockJWTService) GenerateTokenPair(userID ulid.ULID, roles []string) (*domain.TokenPair, error) {
@@ -103,6 +171,15 @@ func (m *mockJWTService) GenerateTokenPair(userID ulid.ULID, roles []string) (*d
 	return args.Get(0).(*domain.TokenPair), args.Error(1)
 }
 
+func (m *mockJWTService) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	m.params = params
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func (m *mockJWTService) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "", nil
+}
+
 func (m *mockJWTService) ValidateToken(token string) (*domain.Claims, error) {
 	args := m.Called(token)
 	if args.Get(0) == nil {
@@ -182,36 +259,79 @@ func (m *mockVerificationCodeRepository) DeleteByUserIDAndType(ctx context.Conte
 	return args.Error(0)
 }
 
-type authMockTOTPService struct {
+type mockMFAService struct {
 	mock.Mock
 }
 
-func (m *authMockTOTPService) EnableTOTP(userID string) (*domain.TOTP, error) {
-	args := m.Called(userID)
+func (m *mockMFAService) ListFactors(ctx context.Context, userID string) ([]*domain.MFAFactor, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.MFAFactor), args.Error(1)
+}
+
+func (m *mockMFAService) RenameFactor(ctx context.Context, userID, factorID, label string) error {
+	args := m.Called(ctx, userID, factorID, label)
+	return args.Error(0)
+}
+
+func (m *mockMFAService) DeleteFactor(ctx context.Context, userID, factorID string) error {
+	args := m.Called(ctx, userID, factorID)
+	return args.Error(0)
+}
+
+func (m *mockMFAService) EnrollEmailOTP(ctx context.Context, userID string) (*domain.MFAFactor, error) {
+	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*domain.TOTP), args.Error(1)
+	return args.Get(0).(*domain.MFAFactor), args.Error(1)
 }
 
-func (m *authMockTOTPService) VerifyTOTP(userID, code string) error {
-	args := m.Called(userID, code)
+func (m *mockMFAService) EnrollSMSOTP(ctx context.Context, userID string) (*domain.MFAFactor, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAFactor), args.Error(1)
+}
+
+func (m *mockMFAService) SendChallenge(ctx context.Context, user *domain.User, factorID string, channel domain.SMSChannel) error {
+	args := m.Called(ctx, user, factorID, channel)
 	return args.Error(0)
 }
 
-func (m *authMockTOTPService) VerifyBackupCode(userID, code string) error {
-	args := m.Called(userID, code)
+func (m *mockMFAService) SendEmailCode(ctx context.Context, user *domain.User) error {
+	args := m.Called(ctx, user)
 	return args.Error(0)
 }
 
-func (m *authMockTOTPService) DisableTOTP(userID string) error {
-	args := m.Called(userID)
+func (m *mockMFAService) VerifyEmailCode(ctx context.Context, user *domain.User, code string) error {
+	args := m.Called(ctx, user, code)
 	return args.Error(0)
 }
 
-func (m *authMockTOTPService) GetTOTPSecret(ctx context.Context, userID string) (string, error) {
+func (m *mockMFAService) VerifyFactor(ctx context.Context, user *domain.User, factorID, code string) (domain.MFAFactorType, error) {
+	args := m.Called(ctx, user, factorID, code)
+	factorType, _ := args.Get(0).(domain.MFAFactorType)
+	return factorType, args.Error(1)
+}
+
+func (m *mockMFAService) ResetMFA(ctx context.Context, adminID, userID string, method domain.MFAResetMethod, reason string) (*domain.MFAReset, error) {
+	args := m.Called(ctx, adminID, userID, method, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAReset), args.Error(1)
+}
+
+func (m *mockMFAService) ListResets(ctx context.Context, userID string) ([]*domain.MFAReset, error) {
 	args := m.Called(ctx, userID)
-	return args.String(0), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.MFAReset), args.Error(1)
 }
 
 type mockMFATicketRepository struct {
@@ -231,11 +351,137 @@ func (m *mockMFATicketRepository) Get(ctx context.Context, id string) (*domain.M
 	return args.Get(0).(*domain.MFATicket), args.Error(1)
 }
 
+func (m *mockMFATicketRepository) IncrementAttempts(ctx context.Context, id string) (int, error) {
+	args := m.Called(ctx, id)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *mockMFATicketRepository) Delete(ctx context.Context, id string) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+type mockLockoutService struct {
+	mock.Mock
+}
+
+func (m *mockLockoutService) Check(ctx context.Context, userID, ip string) error {
+	args := m.Called(ctx, userID, ip)
+	return args.Error(0)
+}
+
+func (m *mockLockoutService) RecordFailure(ctx context.Context, user *domain.User, ip string) error {
+	args := m.Called(ctx, user, ip)
+	return args.Error(0)
+}
+
+func (m *mockLockoutService) CheckUnknown(ctx context.Context, login string) error {
+	args := m.Called(ctx, login)
+	return args.Error(0)
+}
+
+func (m *mockLockoutService) RecordUnknownFailure(ctx context.Context, login, ip string) error {
+	args := m.Called(ctx, login, ip)
+	return args.Error(0)
+}
+
+func (m *mockLockoutService) RecordSuccess(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *mockLockoutService) Unlock(ctx context.Context, userID ulid.ULID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *mockLockoutService) Throttle(ctx context.Context, key string, limit int, window time.Duration) error {
+	args := m.Called(ctx, key, limit, window)
+	return args.Error(0)
+}
+
+type mockCredentialVerifier struct {
+	mock.Mock
+}
+
+func (m *mockCredentialVerifier) Verify(ctx context.Context, login, password string) (*domain.DirectoryUser, error) {
+	args := m.Called(ctx, login, password)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.DirectoryUser), args.Error(1)
+}
+
+// newTestPasswordHasher uses the default parameters so hashes from password.HashPassword need no rehash
+func newTestPasswordHasher() *password.Hasher {
+	return password.NewHasher(&config.Config{
+		PasswordHashMemory:      password.DefaultParams.Memory,
+		PasswordHashIterations:  password.DefaultParams.Iterations,
+		PasswordHashParallelism: password.DefaultParams.Parallelism,
+	})
+}
+
+type mockPasswordPolicyService struct {
+	mock.Mock
+}
+
+func (m *mockPasswordPolicyService) Validate(ctx context.Context, user *domain.User, password string) error {
+	args := m.Called(ctx, user, password)
+	return args.Error(0)
+}
+
+func (m *mockPasswordPolicyService) Remember(ctx context.Context, userID ulid.ULID, hashedPassword string) error {
+	args := m.Called(ctx, userID, hashedPassword)
+	return args.Error(0)
+}
+
+// newAllowAllPasswordPolicy accepts every password
+// newTestMFAPolicy returns an MFA policy without any rule, so MFA stays up to the user
+func newTestMFAPolicy() *MFAPolicyService {
+	return NewMFAPolicyService(nil, nil, &config.Config{}, zap.NewNop())
+}
+
+type mockLoginHistoryService struct {
+	mock.Mock
+}
+
+func (m *mockLoginHistoryService) IsNewDevice(ctx context.Context, user *domain.User) bool {
+	args := m.Called(ctx, user)
+	return args.Bool(0)
+}
+
+func (m *mockLoginHistoryService) Record(ctx context.Context, user *domain.User, auth *domain.Authentication) {
+	m.Called(ctx, user, auth)
+}
+
+func (m *mockLoginHistoryService) List(ctx context.Context, userID string) ([]*domain.LoginEvent, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.LoginEvent), args.Error(1)
+}
+
+func (m *mockLoginHistoryService) RevokeSignIn(ctx context.Context, token string) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+// newTestLoginHistory returns a login history where every device is known
+func newTestLoginHistory() *mockLoginHistoryService {
+	history := new(mockLoginHistoryService)
+	history.On("IsNewDevice", mock.Anything, mock.Anything).Return(false).Maybe()
+	history.On("Record", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	return history
+}
+
+func newAllowAllPasswordPolicy() *mockPasswordPolicyService {
+	policy := new(mockPasswordPolicyService)
+	policy.On("Validate", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	policy.On("Remember", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return policy
+}
+
 func TestAuthService_Register(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -286,7 +532,7 @@ func TestAuthService_Register(t *testing.T) {
 			mockUserRepo := new(MockUserRepository)
 			mockVerificationRepo := new(mockVerificationCodeRepository)
 			mockEmailSvc := new(mockEmailService)
-			mockTOTPSvc := new(authMockTOTPService)
+			mockMFASvc := new(mockMFAService)
 			mockMFATicketRepo := new(mockMFATicketRepository)
 			tt.setupMocks(mockUserRepo, mockVerificationRepo, mockEmailSvc)
 
@@ -295,8 +541,18 @@ func TestAuthService_Register(t *testing.T) {
 				mockVerificationRepo,
 				nil,
 				mockEmailSvc,
-				mockTOTPSvc,
+				mockMFASvc,
+				newTestMFAPolicy(),
+				nil,
 				mockMFATicketRepo,
+				nil,
+				newTestLoginHistory(),
+				newTestPasswordHasher(),
+				newAllowAllPasswordPolicy(),
+				nil,
+				nil,
+				nil,
+				nil,
 				zap.NewNop(),
 			)
 			_, err := service.Register(context.Background(), "Test User", tt.email, tt.password, "1234567890")
@@ -314,6 +570,45 @@ func TestAuthService_Register(t *testing.T) {
 	}
 }
 
+func TestAuthService_PasswordPolicyRejection(t *testing.T) {
+	rejection := domain.NewPasswordPolicyError([]domain.PasswordViolation{
+		{Rule: domain.PasswordRuleCommon, Message: "Password is too common"},
+	})
+
+	t.Run("register", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("ExistsByEmail", mock.Anything, "test@example.com").Return(false, nil)
+		policy := new(mockPasswordPolicyService)
+		policy.On("Validate", mock.Anything, mock.MatchedBy(func(user *domain.User) bool {
+			return user.Email == "test@example.com" && user.Name == "Test User"
+		}), "password123").Return(rejection)
+
+		service := NewAuthService(userRepo, nil, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), policy, nil, nil, nil, nil, zap.NewNop())
+		_, err := service.Register(context.Background(), "Test User", "test@example.com", "password123", "1234567890")
+
+		assert.Equal(t, rejection, err)
+		userRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("reset keeps the code for another try", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		resetCode := domain.NewVerificationCode(user.ID, "123456", domain.PasswordReset, time.Hour)
+		verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.PasswordReset).Return(resetCode, nil)
+		policy := new(mockPasswordPolicyService)
+		policy.On("Validate", mock.Anything, user, "password123").Return(rejection)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), policy, nil, nil, nil, nil, zap.NewNop())
+		err := service.ResetPassword(context.Background(), "test@example.com", "123456", "password123")
+
+		assert.Equal(t, rejection, err)
+		verificationRepo.AssertNotCalled(t, "DeleteByUserIDAndType", mock.Anything, mock.Anything, mock.Anything)
+		userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
 func TestAuthService_VerifyEmail(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -393,7 +688,7 @@ func TestAuthService_VerifyEmail(t *testing.T) {
 			mockUserRepo := new(MockUserRepository)
 			mockVerificationRepo := new(mockVerificationCodeRepository)
 			mockEmailSvc := new(mockEmailService)
-			mockTOTPSvc := new(authMockTOTPService)
+			mockMFASvc := new(mockMFAService)
 			mockMFATicketRepo := new(mockMFATicketRepository)
 			tt.setupMocks(mockUserRepo, mockVerificationRepo, mockEmailSvc)
 
@@ -402,8 +697,18 @@ func TestAuthService_VerifyEmail(t *testing.T) {
 				mockVerificationRepo,
 				nil,
 				mockEmailSvc,
-				mockTOTPSvc,
+				mockMFASvc,
+				newTestMFAPolicy(),
+				nil,
 				mockMFATicketRepo,
+				nil,
+				newTestLoginHistory(),
+				newTestPasswordHasher(),
+				newAllowAllPasswordPolicy(),
+				nil,
+				nil,
+				nil,
+				nil,
 				zap.NewNop(),
 			)
 			err := service.VerifyEmail(context.Background(), tt.email, tt.code)
@@ -486,7 +791,7 @@ func TestAuthService_RequestPasswordReset(t *testing.T) {
 			mockUserRepo := new(MockUserRepository)
 			mockVerificationRepo := new(mockVerificationCodeRepository)
 			mockEmailSvc := new(mockEmailService)
-			mockTOTPSvc := new(authMockTOTPService)
+			mockMFASvc := new(mockMFAService)
 			mockMFATicketRepo := new(mockMFATicketRepository)
 			tt.setupMocks(mockUserRepo, mockVerificationRepo, mockEmailSvc)
 
@@ -495,8 +800,18 @@ func TestAuthService_RequestPasswordReset(t *testing.T) {
 				mockVerificationRepo,
 				nil,
 				mockEmailSvc,
-				mockTOTPSvc,
+				mockMFASvc,
+				newTestMFAPolicy(),
+				nil,
 				mockMFATicketRepo,
+				nil,
+				newTestLoginHistory(),
+				newTestPasswordHasher(),
+				newAllowAllPasswordPolicy(),
+				nil,
+				nil,
+				nil,
+				nil,
 				zap.NewNop(),
 			)
 			err := service.RequestPasswordReset(context.Background(), tt.email)
@@ -620,6 +935,8 @@ func TestAuthService_ResetPassword(t *testing.T) {
 			logger := zap.NewNop()
 
 			tt.setupMocks(mockUserRepo, mockVerificationRepo)
+			deviceRepo := new(mockTrustedDeviceRepository)
+			deviceRepo.On("DeleteByUser", mock.Anything, mock.Anything).Return(nil)
 
 			service := NewAuthService(
 				mockUserRepo,
@@ -628,6 +945,16 @@ func TestAuthService_ResetPassword(t *testing.T) {
 				mockEmailService,
 				nil,
 				nil,
+				nil,
+				nil,
+				NewTrustedDeviceService(deviceRepo, newTestDeviceTrustConfig(), zap.NewNop()),
+				newTestLoginHistory(),
+				newTestPasswordHasher(),
+				newAllowAllPasswordPolicy(),
+				nil,
+				nil,
+				nil,
+				nil,
 				logger,
 			)
 
@@ -636,8 +963,10 @@ func TestAuthService_ResetPassword(t *testing.T) {
 			if tt.expectedError != nil {
 				assert.Error(t, err)
 				assert.Equal(t, tt.expectedError, err)
+				deviceRepo.AssertNotCalled(t, "DeleteByUser", mock.Anything, mock.Anything)
 			} else {
 				assert.NoError(t, err)
+				deviceRepo.AssertNumberOfCalls(t, "DeleteByUser", 1)
 			}
 
 			mockUserRepo.AssertExpectations(t)
@@ -652,6 +981,7 @@ func TestAuthService_Login(t *testing.T) {
 		mockSetup     func(*MockUserRepository)
 		email         string
 		password      string
+		lockoutSetup  func(*mockLockoutService)
 		expectedError error
 		expectedToken *domain.TokenPair
 	}{
@@ -716,6 +1046,50 @@ func TestAuthService_Login(t *testing.T) {
 			expectedError: nil,
 			expectedToken: &domain.TokenPair{},
 		},
+		{
+			name: "account locked",
+			mockSetup: func(mockRepo *MockUserRepository) {
+				hashedPassword, _ := password.HashPassword("correctpassword")
+				mockRepo.On("FindByEmail", mock.Anything, "test@example.com").Return(&domain.User{
+					ID:            ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV"),
+					Email:         "test@example.com",
+					Password:      hashedPassword,
+					Roles:         []string{"user"},
+					EmailVerified: true,
+				}, nil)
+			},
+			lockoutSetup: func(m *mockLockoutService) {
+				m.On("Check", mock.Anything, "01ARZ3NDEKTSV4RRFFQ69G5FAV", "").Return(domain.ErrAccountLocked)
+			},
+			email:         "test@example.com",
+			password:      "correctpassword",
+			expectedError: domain.ErrAccountLocked,
+			expectedToken: nil,
+		},
+		{
+			name: "unknown login locked",
+			mockSetup: func(mockRepo *MockUserRepository) {
+				mockRepo.On("FindByEmail", mock.Anything, "nonexistent@example.com").Return(nil, domain.ErrUserNotFound)
+			},
+			lockoutSetup: func(m *mockLockoutService) {
+				m.On("CheckUnknown", mock.Anything, "nonexistent@example.com").Return(domain.ErrAccountLocked)
+			},
+			email:         "nonexistent@example.com",
+			password:      "password123",
+			expectedError: domain.ErrAccountLocked,
+			expectedToken: nil,
+		},
+		{
+			name:      "client IP locked",
+			mockSetup: func(mockRepo *MockUserRepository) {},
+			lockoutSetup: func(m *mockLockoutService) {
+				m.On("Check", mock.Anything, "", "203.0.113.7").Return(domain.ErrAccountLocked)
+			},
+			email:         "test@example.com",
+			password:      "correctpassword",
+			expectedError: domain.ErrAccountLocked,
+			expectedToken: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -723,21 +1097,40 @@ func TestAuthService_Login(t *testing.T) {
 			repo := new(MockUserRepository)
 			mockJWTService := new(mockJWTService)
 			mockEmailSvc := new(mockEmailService)
-			mockTOTPSvc := new(authMockTOTPService)
+			mockMFASvc := new(mockMFAService)
 			mockMFATicketRepo := new(mockMFATicketRepository)
+			mockLockout := new(mockLockoutService)
 			service := NewAuthService(
 				repo,
 				nil,
 				mockJWTService,
 				mockEmailSvc,
-				mockTOTPSvc,
+				mockMFASvc,
+				newTestMFAPolicy(),
+				nil,
 				mockMFATicketRepo,
+				nil,
+				newTestLoginHistory(),
+				newTestPasswordHasher(),
+				nil,
+				mockLockout,
+				nil,
+				nil,
+				&config.Config{MFAMaxAttempts: 5},
 				zap.NewNop(),
 			)
 
 			tt.mockSetup(repo)
+			if tt.lockoutSetup != nil {
+				tt.lockoutSetup(mockLockout)
+			}
+			mockLockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockLockout.On("CheckUnknown", mock.Anything, mock.Anything).Return(nil)
+			mockLockout.On("RecordFailure", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockLockout.On("RecordUnknownFailure", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockLockout.On("RecordSuccess", mock.Anything, mock.Anything).Return(nil)
 			if tt.expectedToken != nil {
-				mockTOTPSvc.On("GetTOTPSecret", mock.Anything, mock.Anything).Return("", domain.ErrTOTPNotEnabled)
+				mockMFASvc.On("ListFactors", mock.Anything, mock.Anything).Return([]*domain.MFAFactor{}, nil)
 				mockMFATicketRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
 				mockJWTService.On("GenerateTokenPair", mock.Anything, mock.Anything).Return(&domain.TokenPair{
 					AccessToken:  "access_token",
@@ -745,7 +1138,8 @@ func TestAuthService_Login(t *testing.T) {
 				}, nil)
 			}
 
-			token, err := service.Login(context.Background(), tt.email, tt.password)
+			ctx := domain.WithClientIP(context.Background(), "203.0.113.7")
+			token, err := service.Login(ctx, tt.email, tt.password)
 			if tt.expectedError != nil {
 				assert.Error(t, err)
 				assert.Equal(t, tt.expectedError, err)
@@ -765,3 +1159,1252 @@ func TestAuthService_Login(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthService_Login_MFAFactors(t *testing.T) {
+	hashedPassword, _ := password.HashPassword("correctpassword")
+	user := &domain.User{
+		ID:            ulid.Make(),
+		Email:         "test@example.com",
+		Password:      hashedPassword,
+		Roles:         []string{"user"},
+		EmailVerified: true,
+	}
+	totp := domain.NewMFAFactor(user.ID, domain.MFAFactorTOTP, "Phone")
+	passkey := domain.NewMFAFactor(user.ID, domain.MFAFactorWebAuthn, "Laptop")
+	recovery := domain.NewMFAFactor(user.ID, domain.MFAFactorRecovery, "Backup codes")
+
+	login := func(factors []*domain.MFAFactor) (interface{}, *mockMFATicketRepository, *mockJWTService) {
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, user.Email).Return(user, nil)
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return(factors, nil)
+		ticketRepo := new(mockMFATicketRepository)
+		ticketRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil).Maybe()
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil).Maybe()
+
+		service := NewAuthService(repo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+		result, err := service.Login(context.Background(), user.Email, "correctpassword")
+		require.NoError(t, err)
+		return result, ticketRepo, jwtSvc
+	}
+
+	t.Run("ticket lists the enrolled factors", func(t *testing.T) {
+		result, ticketRepo, jwtSvc := login([]*domain.MFAFactor{totp, passkey, recovery})
+
+		ticket, ok := result.(*domain.MFATicket)
+		require.True(t, ok)
+		assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorTOTP, domain.MFAFactorWebAuthn, domain.MFAFactorRecovery}, ticket.FactorTypes)
+		assert.Equal(t, []*domain.MFAFactor{totp, passkey, recovery}, ticket.Factors)
+		assert.Equal(t, []string{domain.AMRPassword}, ticket.AMR)
+		ticketRepo.AssertCalled(t, "Create", mock.Anything, ticket)
+		jwtSvc.AssertNotCalled(t, "GenerateTokenPair", mock.Anything, mock.Anything)
+	})
+
+	t.Run("passkey alone requires MFA", func(t *testing.T) {
+		result, _, _ := login([]*domain.MFAFactor{passkey})
+
+		assert.IsType(t, &domain.MFATicket{}, result)
+	})
+
+	t.Run("backup codes alone do not", func(t *testing.T) {
+		result, ticketRepo, jwtSvc := login([]*domain.MFAFactor{recovery})
+
+		assert.IsType(t, &domain.TokenPair{}, result)
+		ticketRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		require.NotNil(t, jwtSvc.params.Authentication)
+		assert.Equal(t, []string{domain.AMRPassword}, jwtSvc.params.Authentication.Methods)
+		assert.Equal(t, domain.ACRSingleFactor, jwtSvc.params.Authentication.ACR())
+	})
+}
+
+func TestAuthService_Login_MFAPolicy(t *testing.T) {
+	hashedPassword, _ := password.HashPassword("correctpassword")
+	newUser := func(roles []string, createdAt time.Time) *domain.User {
+		return &domain.User{
+			ID:            ulid.Make(),
+			Email:         "test@example.com",
+			Password:      hashedPassword,
+			Roles:         roles,
+			EmailVerified: true,
+			CreatedAt:     createdAt,
+		}
+	}
+	monthAgo := time.Now().Add(-30 * 24 * time.Hour)
+
+	login := func(ctx context.Context, user *domain.User, factors []*domain.MFAFactor) (interface{}, *mockMFATicketRepository) {
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, user.Email).Return(user, nil)
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return(factors, nil)
+		ticketRepo := new(mockMFATicketRepository)
+		ticketRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil).Maybe()
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil).Maybe()
+
+		cfg := &config.Config{MFAMaxAttempts: 5, TOTPEnrollmentTTL: 10 * time.Minute}
+		policy := NewMFAPolicyService(nil, nil, newMFAPolicyTestConfig(), zap.NewNop())
+		service := NewAuthService(repo, nil, jwtSvc, nil, mfaSvc, policy, nil, ticketRepo, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, cfg, zap.NewNop())
+		result, err := service.Login(ctx, user.Email, "correctpassword")
+		require.NoError(t, err)
+		return result, ticketRepo
+	}
+
+	t.Run("admin without a factor gets an enrolment ticket", func(t *testing.T) {
+		result, ticketRepo := login(context.Background(), newUser([]string{"admin"}, monthAgo), []*domain.MFAFactor{})
+
+		ticket, ok := result.(*domain.MFATicket)
+		require.True(t, ok)
+		assert.True(t, ticket.EnrollmentRequired)
+		assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorTOTP}, ticket.FactorTypes)
+		assert.Empty(t, ticket.Factors)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), ticket.ExpiresAt, time.Minute)
+		ticketRepo.AssertCalled(t, "Create", mock.Anything, ticket)
+	})
+
+	t.Run("admin with a factor gets an MFA ticket", func(t *testing.T) {
+		user := newUser([]string{"admin"}, monthAgo)
+		totp := domain.NewMFAFactor(user.ID, domain.MFAFactorTOTP, "Phone")
+
+		result, _ := login(context.Background(), user, []*domain.MFAFactor{totp})
+
+		ticket, ok := result.(*domain.MFATicket)
+		require.True(t, ok)
+		assert.False(t, ticket.EnrollmentRequired)
+		assert.Equal(t, []*domain.MFAFactor{totp}, ticket.Factors)
+	})
+
+	t.Run("new admin signs in during the grace period", func(t *testing.T) {
+		result, ticketRepo := login(context.Background(), newUser([]string{"admin"}, time.Now().Add(-time.Hour)), []*domain.MFAFactor{})
+
+		assert.IsType(t, &domain.TokenPair{}, result)
+		ticketRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("signing in to a covered client", func(t *testing.T) {
+		ctx := domain.WithClientID(context.Background(), "payments-app")
+
+		result, _ := login(ctx, newUser([]string{"user"}, monthAgo), []*domain.MFAFactor{})
+
+		ticket, ok := result.(*domain.MFATicket)
+		require.True(t, ok)
+		assert.True(t, ticket.EnrollmentRequired)
+	})
+
+	t.Run("signing in for a covered scope", func(t *testing.T) {
+		ctx := domain.WithScopes(context.Background(), []string{"openid", "payments:write"})
+
+		result, _ := login(ctx, newUser([]string{"user"}, monthAgo), []*domain.MFAFactor{})
+
+		assert.IsType(t, &domain.MFATicket{}, result)
+	})
+
+	t.Run("users the policy does not cover", func(t *testing.T) {
+		result, _ := login(context.Background(), newUser([]string{"user"}, monthAgo), []*domain.MFAFactor{})
+
+		assert.IsType(t, &domain.TokenPair{}, result)
+	})
+}
+
+func TestAuthService_MFAEnrollment(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", Roles: []string{"admin"}}
+	enrollmentTicket := func() *domain.MFATicket {
+		return &domain.MFATicket{
+			Ticket:             ulid.Make(),
+			User:               user.ID.String(),
+			CreatedAt:          time.Now(),
+			ExpiresAt:          time.Now().Add(10 * time.Minute),
+			EnrollmentRequired: true,
+		}
+	}
+
+	setup := func(ticket *domain.MFATicket, factors ...*domain.MFAFactor) (*AuthService, *mockTOTPService, *mockMFATicketRepository, *mockLockoutService) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil).Maybe()
+		totpSvc := new(mockTOTPService)
+		ticketRepo := new(mockMFATicketRepository)
+		ticketRepo.On("Get", mock.Anything, ticket.Ticket.String()).Return(ticket, nil)
+		ticketRepo.On("Delete", mock.Anything, ticket.Ticket.String()).Return(nil).Maybe()
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil).Maybe()
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+		lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil).Maybe()
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return(factors, nil).Maybe()
+
+		service := NewAuthService(userRepo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), totpSvc, ticketRepo, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+		return service, totpSvc, ticketRepo, lockout
+	}
+
+	t.Run("begin starts enrolling an authenticator app", func(t *testing.T) {
+		ticket := enrollmentTicket()
+		service, totpSvc, _, _ := setup(ticket)
+		totpSvc.On("EnableTOTP", user.ID.String(), "Phone").Return(&domain.TOTP{OTPAuthURI: "otpauth://totp/AuthM:test@example.com"}, nil)
+
+		totp, err := service.BeginMFAEnrollment(context.Background(), ticket.Ticket.String(), "Phone")
+
+		require.NoError(t, err)
+		assert.Equal(t, "otpauth://totp/AuthM:test@example.com", totp.OTPAuthURI)
+	})
+
+	t.Run("confirming completes the login", func(t *testing.T) {
+		ticket := enrollmentTicket()
+		service, totpSvc, ticketRepo, _ := setup(ticket)
+		totpSvc.On("ConfirmTOTP", mock.Anything, user.ID.String(), "123456").Return(&domain.TOTP{FactorID: "factor", BackupCodes: []string{"code1", "code2"}}, nil)
+
+		enrollment, err := service.CompleteMFAEnrollment(context.Background(), ticket.Ticket.String(), "123456")
+
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", enrollment.AccessToken)
+		assert.Equal(t, "factor", enrollment.FactorID)
+		assert.Equal(t, []string{"code1", "code2"}, enrollment.BackupCodes)
+		ticketRepo.AssertCalled(t, "Delete", mock.Anything, ticket.Ticket.String())
+	})
+
+	t.Run("wrong code counts against the ticket", func(t *testing.T) {
+		ticket := enrollmentTicket()
+		service, totpSvc, ticketRepo, lockout := setup(ticket)
+		totpSvc.On("ConfirmTOTP", mock.Anything, user.ID.String(), "000000").Return(nil, domain.ErrInvalidTOTPCode)
+		ticketRepo.On("IncrementAttempts", mock.Anything, ticket.Ticket.String()).Return(1, nil)
+		lockout.On("RecordFailure", mock.Anything, user, mock.Anything).Return(nil)
+
+		_, err := service.CompleteMFAEnrollment(context.Background(), ticket.Ticket.String(), "000000")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidTOTPCode)
+		ticketRepo.AssertCalled(t, "IncrementAttempts", mock.Anything, ticket.Ticket.String())
+	})
+
+	t.Run("MFA tickets cannot enrol a factor", func(t *testing.T) {
+		ticket := enrollmentTicket()
+		ticket.EnrollmentRequired = false
+		service, totpSvc, _, _ := setup(ticket)
+
+		_, err := service.BeginMFAEnrollment(context.Background(), ticket.Ticket.String(), "Phone")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
+
+		_, err = service.CompleteMFAEnrollment(context.Background(), ticket.Ticket.String(), "123456")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
+		totpSvc.AssertNotCalled(t, "EnableTOTP", mock.Anything, mock.Anything)
+		totpSvc.AssertNotCalled(t, "ConfirmTOTP", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("user who enrolled a factor since the ticket was issued", func(t *testing.T) {
+		ticket := enrollmentTicket()
+		service, totpSvc, _, _ := setup(ticket, &domain.MFAFactor{ID: ulid.Make(), Type: domain.MFAFactorWebAuthn})
+
+		_, err := service.BeginMFAEnrollment(context.Background(), ticket.Ticket.String(), "Phone")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
+
+		_, err = service.CompleteMFAEnrollment(context.Background(), ticket.Ticket.String(), "123456")
+		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
+		totpSvc.AssertNotCalled(t, "EnableTOTP", mock.Anything, mock.Anything)
+		totpSvc.AssertNotCalled(t, "ConfirmTOTP", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("backup codes alone do not count as a factor", func(t *testing.T) {
+		ticket := enrollmentTicket()
+		service, totpSvc, _, _ := setup(ticket, &domain.MFAFactor{ID: ulid.Make(), Type: domain.MFAFactorRecovery})
+		totpSvc.On("EnableTOTP", user.ID.String(), "Phone").Return(&domain.TOTP{}, nil)
+
+		_, err := service.BeginMFAEnrollment(context.Background(), ticket.Ticket.String(), "Phone")
+		assert.NoError(t, err)
+	})
+
+	t.Run("expired ticket", func(t *testing.T) {
+		ticket := enrollmentTicket()
+		ticket.ExpiresAt = time.Now().Add(-time.Minute)
+		service, _, ticketRepo, _ := setup(ticket)
+
+		_, err := service.BeginMFAEnrollment(context.Background(), ticket.Ticket.String(), "Phone")
+
+		assert.ErrorIs(t, err, domain.ErrMFATicketExpired)
+		ticketRepo.AssertCalled(t, "Delete", mock.Anything, ticket.Ticket.String())
+	})
+}
+
+func TestAuthService_Login_RecordsFailures(t *testing.T) {
+	hashedPassword, _ := password.HashPassword("correctpassword")
+	user := &domain.User{
+		ID:            ulid.Make(),
+		Email:         "test@example.com",
+		Password:      hashedPassword,
+		EmailVerified: true,
+	}
+
+	repo := new(MockUserRepository)
+	repo.On("FindByEmail", mock.Anything, "test@example.com").Return(user, nil)
+	repo.On("FindByEmail", mock.Anything, "nobody@example.com").Return(nil, domain.ErrUserNotFound)
+
+	mockLockout := new(mockLockoutService)
+	mockLockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockLockout.On("RecordFailure", mock.Anything, user, "203.0.113.7").Return(nil).Once()
+	mockLockout.On("CheckUnknown", mock.Anything, "nobody@example.com").Return(nil)
+	mockLockout.On("RecordUnknownFailure", mock.Anything, "nobody@example.com", "203.0.113.7").Return(nil).Once()
+
+	service := NewAuthService(repo, nil, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, mockLockout, nil, nil, &config.Config{}, zap.NewNop())
+	ctx := domain.WithClientIP(context.Background(), "203.0.113.7")
+
+	_, err := service.Login(ctx, "test@example.com", "wrongpassword")
+	assert.Equal(t, domain.ErrInvalidCredentials, err)
+
+	_, err = service.Login(ctx, "nobody@example.com", "wrongpassword")
+	assert.Equal(t, domain.ErrInvalidCredentials, err)
+
+	mockLockout.AssertExpectations(t)
+	mockLockout.AssertNotCalled(t, "RecordSuccess", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_RehashesLegacyPassword(t *testing.T) {
+	legacyHash, _ := bcrypt.GenerateFromPassword([]byte("correctpassword"), bcrypt.MinCost)
+	user := &domain.User{
+		ID:            ulid.Make(),
+		Email:         "test@example.com",
+		Password:      string(legacyHash),
+		Roles:         []string{"user"},
+		EmailVerified: true,
+	}
+
+	repo := new(MockUserRepository)
+	repo.On("FindByEmail", mock.Anything, "test@example.com").Return(user, nil)
+	repo.On("UpdatePassword", mock.Anything, user.ID, mock.MatchedBy(func(hash string) bool {
+		return strings.HasPrefix(hash, "$argon2id$")
+	})).Return(nil)
+
+	mockLockout := new(mockLockoutService)
+	mockLockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockLockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+
+	mockMFASvc := new(mockMFAService)
+	mockMFASvc.On("ListFactors", mock.Anything, user.ID.String()).Return([]*domain.MFAFactor{}, nil)
+
+	mockJWTService := new(mockJWTService)
+	mockJWTService.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+
+	service := NewAuthService(repo, nil, mockJWTService, nil, mockMFASvc, newTestMFAPolicy(), nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, mockLockout, nil, nil, &config.Config{}, zap.NewNop())
+
+	_, err := service.Login(context.Background(), "test@example.com", "correctpassword")
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_Directory(t *testing.T) {
+	entry := &domain.DirectoryUser{
+		DN:    "uid=jane,ou=people,dc=example,dc=com",
+		Name:  "Jane Doe",
+		Email: "jane@example.com",
+		Roles: []string{"user", "admin"},
+	}
+
+	newService := func(repo *MockUserRepository, directory *mockCredentialVerifier, identities *mockFederatedIdentityRepository) (*AuthService, *mockLockoutService) {
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, mock.Anything).Return([]*domain.MFAFactor{}, nil)
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", mock.Anything, mock.Anything).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordSuccess", mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordFailure", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("CheckUnknown", mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordUnknownFailure", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+		service := NewAuthService(repo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, directory, identities, &config.Config{}, zap.NewNop())
+		return service, lockout
+	}
+
+	linkedTo := func(userID ulid.ULID, dn string) *domain.FederatedIdentity {
+		return domain.NewFederatedIdentity(userID, domain.DirectoryIdentityProvider, dn, "jane@example.com")
+	}
+
+	t.Run("provisions a new user", func(t *testing.T) {
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(nil, domain.ErrUserNotFound)
+		repo.On("Create", mock.Anything, mock.MatchedBy(func(user *domain.User) bool {
+			return user.Name == "Jane Doe" && user.Email == "jane@example.com" && user.Password == "" &&
+				user.EmailVerified && assert.ObjectsAreEqual([]string{"user", "admin"}, user.Roles)
+		})).Return(nil)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@example.com", "directory-password").Return(entry, nil)
+		identities := new(mockFederatedIdentityRepository)
+		identities.On("FindByProviderSubject", mock.Anything, "ldap", entry.DN).Return(nil, domain.ErrFederatedIdentityNotFound)
+		identities.On("Create", mock.Anything, mock.MatchedBy(func(identity *domain.FederatedIdentity) bool {
+			return identity.Provider == "ldap" && identity.Subject == entry.DN && identity.Email == "jane@example.com"
+		})).Return(nil)
+		service, _ := newService(repo, directory, identities)
+
+		result, err := service.Login(context.Background(), "jane@example.com", "directory-password")
+		require.NoError(t, err)
+		assert.IsType(t, &domain.TokenPair{}, result)
+		repo.AssertExpectations(t)
+		identities.AssertExpectations(t)
+	})
+
+	t.Run("refreshes a known user", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Name: "Jane", Email: "jane@example.com", Roles: []string{"user"}, EmailVerified: true}
+		identity := linkedTo(user.ID, entry.DN)
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		repo.On("Update", mock.Anything, mock.MatchedBy(func(u *domain.User) bool {
+			return u.ID == user.ID && u.Name == "Jane Doe" && assert.ObjectsAreEqual([]string{"user", "admin"}, u.Roles)
+		})).Return(nil)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@example.com", "directory-password").Return(entry, nil)
+		identities := new(mockFederatedIdentityRepository)
+		identities.On("ListByUser", mock.Anything, user.ID).Return([]*domain.FederatedIdentity{identity}, nil)
+		identities.On("FindByProviderSubject", mock.Anything, "ldap", entry.DN).Return(identity, nil)
+		identities.On("Touch", mock.Anything, identity.ID, "jane@example.com", mock.Anything).Return(nil)
+		service, _ := newService(repo, directory, identities)
+
+		_, err := service.Login(context.Background(), "jane@example.com", "directory-password")
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+		identities.AssertExpectations(t)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("links a known user whose entry moved", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", EmailVerified: true}
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		repo.On("Update", mock.Anything, user).Return(nil)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@example.com", "directory-password").Return(entry, nil)
+		identities := new(mockFederatedIdentityRepository)
+		identities.On("ListByUser", mock.Anything, user.ID).Return([]*domain.FederatedIdentity{linkedTo(user.ID, "uid=jane,ou=former,dc=example,dc=com")}, nil)
+		identities.On("FindByProviderSubject", mock.Anything, "ldap", entry.DN).Return(nil, domain.ErrFederatedIdentityNotFound)
+		identities.On("Create", mock.Anything, mock.MatchedBy(func(identity *domain.FederatedIdentity) bool {
+			return identity.UserID == user.ID && identity.Subject == entry.DN
+		})).Return(nil)
+		service, _ := newService(repo, directory, identities)
+
+		_, err := service.Login(context.Background(), "jane@example.com", "directory-password")
+		require.NoError(t, err)
+		identities.AssertExpectations(t)
+	})
+
+	t.Run("login other than the email", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", EmailVerified: true}
+		identity := linkedTo(user.ID, entry.DN)
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@corp.example.com").Return(nil, domain.ErrUserNotFound)
+		repo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		repo.On("Update", mock.Anything, user).Return(nil)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@corp.example.com", "directory-password").Return(entry, nil)
+		identities := new(mockFederatedIdentityRepository)
+		identities.On("FindByProviderSubject", mock.Anything, "ldap", entry.DN).Return(identity, nil)
+		identities.On("Touch", mock.Anything, identity.ID, "jane@example.com", mock.Anything).Return(nil)
+		service, _ := newService(repo, directory, identities)
+
+		_, err := service.Login(context.Background(), "jane@corp.example.com", "directory-password")
+		require.NoError(t, err)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("accounts with a local password skip the directory", func(t *testing.T) {
+		hashedPassword, _ := password.HashPassword("correctpassword")
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", Password: hashedPassword, EmailVerified: true}
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		directory := new(mockCredentialVerifier)
+		service, _ := newService(repo, directory, new(mockFederatedIdentityRepository))
+
+		_, err := service.Login(context.Background(), "jane@example.com", "directory-password")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		directory.AssertNotCalled(t, "Verify", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("accounts without a password not linked to the directory skip it", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", Roles: []string{"user"}, EmailVerified: true}
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		directory := new(mockCredentialVerifier)
+		identities := new(mockFederatedIdentityRepository)
+		identities.On("ListByUser", mock.Anything, user.ID).Return([]*domain.FederatedIdentity{
+			domain.NewFederatedIdentity(user.ID, "corp", "upstream-subject", "jane@example.com"),
+		}, nil)
+		service, lockout := newService(repo, directory, identities)
+
+		_, err := service.Login(context.Background(), "jane@example.com", "directory-password")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		directory.AssertNotCalled(t, "Verify", mock.Anything, mock.Anything, mock.Anything)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		lockout.AssertCalled(t, "RecordFailure", mock.Anything, user, mock.Anything)
+	})
+
+	t.Run("directory user matching an account not linked to the directory", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", EmailVerified: true}
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@corp.example.com").Return(nil, domain.ErrUserNotFound)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@corp.example.com", "directory-password").Return(entry, nil)
+		identities := new(mockFederatedIdentityRepository)
+		identities.On("FindByProviderSubject", mock.Anything, "ldap", entry.DN).Return(nil, domain.ErrFederatedIdentityNotFound)
+		identities.On("ListByUser", mock.Anything, user.ID).Return([]*domain.FederatedIdentity{}, nil)
+		service, _ := newService(repo, directory, identities)
+
+		_, err := service.Login(context.Background(), "jane@corp.example.com", "directory-password")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		identities.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("directory user linked to another account", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", EmailVerified: true}
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@example.com", "directory-password").Return(entry, nil)
+		identities := new(mockFederatedIdentityRepository)
+		identities.On("ListByUser", mock.Anything, user.ID).Return([]*domain.FederatedIdentity{linkedTo(user.ID, "uid=jdoe,ou=people,dc=example,dc=com")}, nil)
+		identities.On("FindByProviderSubject", mock.Anything, "ldap", entry.DN).Return(linkedTo(ulid.Make(), entry.DN), nil)
+		service, _ := newService(repo, directory, identities)
+
+		_, err := service.Login(context.Background(), "jane@example.com", "directory-password")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(nil, domain.ErrUserNotFound)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@example.com", "guess").Return(nil, domain.ErrInvalidCredentials)
+		service, lockout := newService(repo, directory, new(mockFederatedIdentityRepository))
+
+		_, err := service.Login(domain.WithClientIP(context.Background(), "203.0.113.7"), "jane@example.com", "guess")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		lockout.AssertCalled(t, "RecordUnknownFailure", mock.Anything, "jane@example.com", "203.0.113.7")
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("directory unavailable", func(t *testing.T) {
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, "jane@example.com").Return(nil, domain.ErrUserNotFound)
+		directory := new(mockCredentialVerifier)
+		directory.On("Verify", mock.Anything, "jane@example.com", "directory-password").Return(nil, errors.New("connection refused"))
+		service, lockout := newService(repo, directory, new(mockFederatedIdentityRepository))
+
+		_, err := service.Login(context.Background(), "jane@example.com", "directory-password")
+		assert.Equal(t, domain.ErrDirectoryUnavailable, err)
+		lockout.AssertNotCalled(t, "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthService_VerifyMFA(t *testing.T) {
+	user := &domain.User{
+		ID:    ulid.Make(),
+		Email: "test@example.com",
+		Roles: []string{"user"},
+	}
+	ticketID := ulid.Make()
+
+	tests := []struct {
+		name          string
+		attempts      int
+		setupMocks    func(*mockMFATicketRepository, *mockMFAService, *mockLockoutService, *mockJWTService)
+		expectedError error
+	}{
+		{
+			name: "valid code",
+			setupMocks: func(tr *mockMFATicketRepository, ms *mockMFAService, l *mockLockoutService, j *mockJWTService) {
+				l.On("Check", mock.Anything, user.ID.String(), "203.0.113.7").Return(nil)
+				ms.On("VerifyFactor", mock.Anything, user, "", "123456").Return(domain.MFAFactorTOTP, nil)
+				tr.On("Delete", mock.Anything, ticketID.String()).Return(nil)
+				l.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+				j.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+			},
+		},
+		{
+			name: "invalid code counts against ticket and user",
+			setupMocks: func(tr *mockMFATicketRepository, ms *mockMFAService, l *mockLockoutService, j *mockJWTService) {
+				l.On("Check", mock.Anything, user.ID.String(), "203.0.113.7").Return(nil)
+				ms.On("VerifyFactor", mock.Anything, user, "", "123456").Return(nil, domain.ErrInvalidTOTPCode)
+				l.On("RecordFailure", mock.Anything, user, "203.0.113.7").Return(nil)
+				tr.On("IncrementAttempts", mock.Anything, ticketID.String()).Return(1, nil)
+			},
+			expectedError: domain.ErrInvalidTOTPCode,
+		},
+		{
+			name:     "last attempt burns the ticket",
+			attempts: 4,
+			setupMocks: func(tr *mockMFATicketRepository, ms *mockMFAService, l *mockLockoutService, j *mockJWTService) {
+				l.On("Check", mock.Anything, user.ID.String(), "203.0.113.7").Return(nil)
+				ms.On("VerifyFactor", mock.Anything, user, "", "123456").Return(nil, domain.ErrInvalidTOTPCode)
+				l.On("RecordFailure", mock.Anything, user, "203.0.113.7").Return(nil)
+				tr.On("IncrementAttempts", mock.Anything, ticketID.String()).Return(5, nil)
+				tr.On("Delete", mock.Anything, ticketID.String()).Return(nil)
+			},
+			expectedError: domain.ErrMFAAttemptsExceeded,
+		},
+		{
+			name:     "ticket out of attempts",
+			attempts: 5,
+			setupMocks: func(tr *mockMFATicketRepository, ms *mockMFAService, l *mockLockoutService, j *mockJWTService) {
+				tr.On("Delete", mock.Anything, ticketID.String()).Return(nil)
+			},
+			expectedError: domain.ErrMFAAttemptsExceeded,
+		},
+		{
+			name: "account locked",
+			setupMocks: func(tr *mockMFATicketRepository, ms *mockMFAService, l *mockLockoutService, j *mockJWTService) {
+				l.On("Check", mock.Anything, user.ID.String(), "203.0.113.7").Return(domain.ErrAccountLocked)
+			},
+			expectedError: domain.ErrAccountLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			ticketRepo := new(mockMFATicketRepository)
+			mfaSvc := new(mockMFAService)
+			lockout := new(mockLockoutService)
+			jwtSvc := new(mockJWTService)
+
+			ticketRepo.On("Get", mock.Anything, ticketID.String()).Return(&domain.MFATicket{
+				Ticket:    ticketID,
+				User:      user.ID.String(),
+				CreatedAt: time.Now(),
+				ExpiresAt: time.Now().Add(5 * time.Minute),
+				Attempts:  tt.attempts,
+				AMR:       []string{domain.AMRPassword},
+			}, nil)
+			userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil).Maybe()
+			tt.setupMocks(ticketRepo, mfaSvc, lockout, jwtSvc)
+
+			service := NewAuthService(userRepo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+			ctx := domain.WithClientIP(context.Background(), "203.0.113.7")
+
+			tokenPair, err := service.VerifyMFA(ctx, ticketID.String(), "", "123456", nil)
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, tokenPair)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "access_token", tokenPair.AccessToken)
+				// The tokens record the password of the ticket completed by the code
+				auth := jwtSvc.params.Authentication
+				assert.Equal(t, []string{domain.AMRPassword, domain.AMROTP, domain.AMRMultiFactor}, auth.Methods)
+				assert.Equal(t, domain.ACRMultiFactor, auth.ACR())
+				assert.WithinDuration(t, time.Now(), auth.Time, 2*time.Second)
+			}
+
+			ticketRepo.AssertExpectations(t)
+			mfaSvc.AssertExpectations(t)
+			lockout.AssertExpectations(t)
+			jwtSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthService_VerifyMFA_ChosenFactor(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Roles: []string{"user"}}
+	ticketID := ulid.Make()
+	factorID := ulid.Make().String()
+
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+	ticketRepo := new(mockMFATicketRepository)
+	ticketRepo.On("Get", mock.Anything, ticketID.String()).Return(&domain.MFATicket{
+		Ticket:    ticketID,
+		User:      user.ID.String(),
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}, nil)
+	ticketRepo.On("Delete", mock.Anything, ticketID.String()).Return(nil)
+	mfaSvc := new(mockMFAService)
+	mfaSvc.On("VerifyFactor", mock.Anything, user, factorID, "A1B2C3D4").Return(domain.MFAFactorRecovery, nil)
+	lockout := new(mockLockoutService)
+	lockout.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+	lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+	jwtSvc := new(mockJWTService)
+	jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+
+	service := NewAuthService(userRepo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+
+	tokenPair, err := service.VerifyMFA(context.Background(), ticketID.String(), factorID, "A1B2C3D4", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "access_token", tokenPair.AccessToken)
+	mfaSvc.AssertExpectations(t)
+}
+
+func TestAuthService_VerifyMFA_TrustDevice(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Roles: []string{"user"}}
+	admin := &domain.User{ID: ulid.Make(), Roles: []string{"admin"}}
+
+	verify := func(user *domain.User) (*domain.TokenPair, *mockTrustedDeviceRepository) {
+		ticketID := ulid.Make()
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		ticketRepo := new(mockMFATicketRepository)
+		ticketRepo.On("Get", mock.Anything, ticketID.String()).Return(&domain.MFATicket{
+			Ticket:    ticketID,
+			User:      user.ID.String(),
+			ExpiresAt: time.Now().Add(5 * time.Minute),
+			AMR:       []string{domain.AMRPassword},
+		}, nil)
+		ticketRepo.On("Delete", mock.Anything, ticketID.String()).Return(nil)
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("VerifyFactor", mock.Anything, user, "", "123456").Return(domain.MFAFactorTOTP, nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+		lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+		deviceRepo := new(mockTrustedDeviceRepository)
+		deviceRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+		deviceTrust := NewTrustedDeviceService(deviceRepo, newTestDeviceTrustConfig(), zap.NewNop())
+
+		service := NewAuthService(userRepo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, deviceTrust, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+		tokenPair, err := service.VerifyMFA(context.Background(), ticketID.String(), "", "123456", &domain.DeviceTrust{Name: "Laptop"})
+		require.NoError(t, err)
+		return tokenPair, deviceRepo
+	}
+
+	t.Run("issues a device token", func(t *testing.T) {
+		tokenPair, deviceRepo := verify(user)
+
+		assert.Equal(t, "access_token", tokenPair.AccessToken)
+		require.NotEmpty(t, tokenPair.DeviceToken)
+		require.NotNil(t, tokenPair.DeviceExpiresAt)
+		device := deviceRepo.Calls[0].Arguments.Get(1).(*domain.TrustedDevice)
+		assert.Equal(t, "Laptop", device.Name)
+		assert.True(t, strings.HasPrefix(tokenPair.DeviceToken, device.ID.String()+"."))
+		assert.Equal(t, device.ExpiresAt, *tokenPair.DeviceExpiresAt)
+	})
+
+	t.Run("excluded role still signs in", func(t *testing.T) {
+		tokenPair, deviceRepo := verify(admin)
+
+		assert.Equal(t, "access_token", tokenPair.AccessToken)
+		assert.Empty(t, tokenPair.DeviceToken)
+		deviceRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthService_Login_TrustedDevice(t *testing.T) {
+	hashedPassword, _ := password.HashPassword("correctpassword")
+	user := &domain.User{
+		ID:            ulid.Make(),
+		Email:         "test@example.com",
+		Password:      hashedPassword,
+		Roles:         []string{"user"},
+		EmailVerified: true,
+	}
+	device, token := trustTestDevice(t, user)
+
+	login := func(token string) (interface{}, *mockJWTService) {
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, user.Email).Return(user, nil)
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return([]*domain.MFAFactor{domain.NewMFAFactor(user.ID, domain.MFAFactorTOTP, "Phone")}, nil)
+		ticketRepo := new(mockMFATicketRepository)
+		ticketRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil).Maybe()
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil).Maybe()
+		deviceRepo := new(mockTrustedDeviceRepository)
+		deviceRepo.On("FindByID", mock.Anything, device.ID).Return(device, nil).Maybe()
+		deviceRepo.On("Touch", mock.Anything, device.ID, mock.Anything).Return(nil).Maybe()
+		deviceTrust := NewTrustedDeviceService(deviceRepo, newTestDeviceTrustConfig(), zap.NewNop())
+
+		service := NewAuthService(repo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, deviceTrust, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+		ctx := domain.WithDeviceToken(context.Background(), token)
+		result, err := service.Login(ctx, user.Email, "correctpassword")
+		require.NoError(t, err)
+		return result, jwtSvc
+	}
+
+	t.Run("trusted device skips MFA", func(t *testing.T) {
+		result, jwtSvc := login(token)
+
+		assert.IsType(t, &domain.TokenPair{}, result)
+		// Skipping the second factor leaves the sign-in single-factor
+		assert.Equal(t, []string{domain.AMRPassword}, jwtSvc.params.Authentication.Methods)
+	})
+
+	t.Run("unknown token asks for MFA", func(t *testing.T) {
+		result, _ := login(device.ID.String() + ".wrong")
+
+		assert.IsType(t, &domain.MFATicket{}, result)
+	})
+}
+
+func TestAuthService_Login_RiskChallenge(t *testing.T) {
+	hashedPassword, _ := password.HashPassword("correctpassword")
+	user := &domain.User{
+		ID:            ulid.Make(),
+		Email:         "test@example.com",
+		Password:      hashedPassword,
+		Roles:         []string{"user"},
+		EmailVerified: true,
+	}
+
+	login := func(cfg *config.Config, newDevice bool) (interface{}, *mockMFATicketRepository) {
+		repo := new(MockUserRepository)
+		repo.On("FindByEmail", mock.Anything, user.Email).Return(user, nil)
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return([]*domain.MFAFactor{}, nil)
+		ticketRepo := new(mockMFATicketRepository)
+		ticketRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil).Maybe()
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil).Maybe()
+		history := new(mockLoginHistoryService)
+		history.On("IsNewDevice", mock.Anything, user).Return(newDevice)
+		history.On("Record", mock.Anything, user, mock.Anything).Maybe()
+
+		service := NewAuthService(repo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, nil, history, newTestPasswordHasher(), nil, lockout, nil, nil, cfg, zap.NewNop())
+		result, err := service.Login(context.Background(), user.Email, "correctpassword")
+		require.NoError(t, err)
+		return result, ticketRepo
+	}
+
+	t.Run("new device asks for an emailed code", func(t *testing.T) {
+		result, ticketRepo := login(&config.Config{MFAMaxAttempts: 5, LoginRiskMFA: true}, true)
+
+		ticket, ok := result.(*domain.MFATicket)
+		require.True(t, ok)
+		assert.True(t, ticket.RiskChallenge)
+		assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorEmailOTP}, ticket.FactorTypes)
+		ticketRepo.AssertCalled(t, "Create", mock.Anything, ticket)
+	})
+
+	t.Run("known device signs in", func(t *testing.T) {
+		result, _ := login(&config.Config{MFAMaxAttempts: 5, LoginRiskMFA: true}, false)
+
+		assert.IsType(t, &domain.TokenPair{}, result)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		result, _ := login(&config.Config{MFAMaxAttempts: 5}, true)
+
+		assert.IsType(t, &domain.TokenPair{}, result)
+	})
+}
+
+func TestAuthService_VerifyMFA_RiskChallenge(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Roles: []string{"user"}}
+	ticketID := ulid.Make()
+
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+	ticketRepo := new(mockMFATicketRepository)
+	ticketRepo.On("Get", mock.Anything, ticketID.String()).Return(&domain.MFATicket{
+		Ticket:        ticketID,
+		User:          user.ID.String(),
+		ExpiresAt:     time.Now().Add(5 * time.Minute),
+		AMR:           []string{domain.AMRPassword},
+		RiskChallenge: true,
+	}, nil)
+	ticketRepo.On("Delete", mock.Anything, ticketID.String()).Return(nil)
+	mfaSvc := new(mockMFAService)
+	mfaSvc.On("VerifyEmailCode", mock.Anything, user, "123456").Return(nil)
+	lockout := new(mockLockoutService)
+	lockout.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+	lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+	jwtSvc := new(mockJWTService)
+	jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+	history := newTestLoginHistory()
+
+	service := NewAuthService(userRepo, nil, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, nil, history, newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+	tokenPair, err := service.VerifyMFA(context.Background(), ticketID.String(), "", "123456", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, "access_token", tokenPair.AccessToken)
+	assert.Equal(t, []string{domain.AMRPassword, domain.AMROTP, domain.AMRMultiFactor}, jwtSvc.params.Authentication.Methods)
+	mfaSvc.AssertNotCalled(t, "VerifyFactor", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	history.AssertCalled(t, "Record", mock.Anything, user, jwtSvc.params.Authentication)
+}
+
+func TestAuthService_ChallengeMFA(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+	ticketID := ulid.Make()
+	factorID := ulid.Make().String()
+	now := time.Now()
+
+	setup := func(ticket *domain.MFATicket) (*AuthService, *mockMFAService, *mockLockoutService) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil).Maybe()
+		ticketRepo := new(mockMFATicketRepository)
+		ticketRepo.On("Get", mock.Anything, ticketID.String()).Return(ticket, nil)
+		ticketRepo.On("Delete", mock.Anything, ticketID.String()).Return(nil).Maybe()
+		mfaSvc := new(mockMFAService)
+		lockout := new(mockLockoutService)
+
+		service := NewAuthService(userRepo, nil, nil, nil, mfaSvc, newTestMFAPolicy(), nil, ticketRepo, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{MFAMaxAttempts: 5}, zap.NewNop())
+		return service, mfaSvc, lockout
+	}
+
+	t.Run("sends the code", func(t *testing.T) {
+		service, mfaSvc, lockout := setup(&domain.MFATicket{
+			Ticket:    ticketID,
+			User:      user.ID.String(),
+			CreatedAt: now,
+			ExpiresAt: now.Add(5 * time.Minute),
+		})
+		lockout.On("Throttle", mock.Anything, domain.MFAChallengeKey(ticketID.String()), 5, 5*time.Minute).Return(nil)
+		mfaSvc.On("SendChallenge", mock.Anything, user, factorID, domain.SMSChannelVoice).Return(nil)
+
+		assert.NoError(t, service.ChallengeMFA(context.Background(), ticketID.String(), factorID, domain.SMSChannelVoice))
+		lockout.AssertExpectations(t)
+		mfaSvc.AssertExpectations(t)
+	})
+
+	t.Run("sends the emailed code of a new device", func(t *testing.T) {
+		service, mfaSvc, lockout := setup(&domain.MFATicket{
+			Ticket:        ticketID,
+			User:          user.ID.String(),
+			CreatedAt:     now,
+			ExpiresAt:     now.Add(5 * time.Minute),
+			RiskChallenge: true,
+		})
+		lockout.On("Throttle", mock.Anything, domain.MFAChallengeKey(ticketID.String()), 5, 5*time.Minute).Return(nil)
+		mfaSvc.On("SendEmailCode", mock.Anything, user).Return(nil)
+
+		assert.NoError(t, service.ChallengeMFA(context.Background(), ticketID.String(), "", ""))
+		mfaSvc.AssertExpectations(t)
+		mfaSvc.AssertNotCalled(t, "SendChallenge", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("too many codes sent", func(t *testing.T) {
+		service, mfaSvc, lockout := setup(&domain.MFATicket{
+			Ticket:    ticketID,
+			User:      user.ID.String(),
+			CreatedAt: now,
+			ExpiresAt: now.Add(5 * time.Minute),
+		})
+		lockout.On("Throttle", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(domain.ErrTooManyAttempts)
+
+		err := service.ChallengeMFA(context.Background(), ticketID.String(), factorID, "")
+		assert.Equal(t, domain.ErrTooManyAttempts, err)
+		mfaSvc.AssertNotCalled(t, "SendChallenge", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("expired ticket", func(t *testing.T) {
+		service, _, _ := setup(&domain.MFATicket{
+			Ticket:    ticketID,
+			User:      user.ID.String(),
+			CreatedAt: now.Add(-10 * time.Minute),
+			ExpiresAt: now.Add(-5 * time.Minute),
+		})
+
+		err := service.ChallengeMFA(context.Background(), ticketID.String(), factorID, "")
+		assert.Equal(t, domain.ErrMFATicketExpired, err)
+	})
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	currentHash, err := newTestPasswordHasher().Hash("Old-Secret-42")
+	require.NoError(t, err)
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", Password: currentHash, Roles: []string{"user"}}
+
+	t.Run("changes the password and revokes other sessions", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("UpdatePassword", mock.Anything, user.ID, mock.MatchedBy(func(hash string) bool {
+			return strings.HasPrefix(hash, "$argon2id$")
+		})).Return(nil)
+		userRepo.On("RevokeSessions", mock.Anything, user.ID, mock.MatchedBy(func(before time.Time) bool {
+			return !before.After(time.Now()) && before.Equal(before.Truncate(time.Second))
+		})).Return(nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.PasswordReset).Return(nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+		deviceRepo := new(mockTrustedDeviceRepository)
+		deviceRepo.On("DeleteByUser", mock.Anything, user.ID).Return(nil)
+		deviceTrust := NewTrustedDeviceService(deviceRepo, newTestDeviceTrustConfig(), zap.NewNop())
+
+		service := NewAuthService(userRepo, verificationRepo, jwtSvc, nil, nil, nil, nil, nil, deviceTrust, newTestLoginHistory(), newTestPasswordHasher(), newAllowAllPasswordPolicy(), lockout, nil, nil, &config.Config{}, zap.NewNop())
+		tokenPair, err := service.ChangePassword(context.Background(), user.ID.String(), "Old-Secret-42", "New-Secret-42")
+
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", tokenPair.AccessToken)
+		userRepo.AssertExpectations(t)
+		verificationRepo.AssertExpectations(t)
+		deviceRepo.AssertExpectations(t)
+	})
+
+	t.Run("wrong current password counts as a failed attempt", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+		lockout.On("RecordFailure", mock.Anything, user, "").Return(nil)
+
+		service := NewAuthService(userRepo, nil, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), newAllowAllPasswordPolicy(), lockout, nil, nil, &config.Config{}, zap.NewNop())
+		_, err := service.ChangePassword(context.Background(), user.ID.String(), "wrong", "New-Secret-42")
+
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+		lockout.AssertExpectations(t)
+		userRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAuthService_EmailChange(t *testing.T) {
+	currentHash, err := newTestPasswordHasher().Hash("Old-Secret-42")
+	require.NoError(t, err)
+	user := &domain.User{ID: ulid.Make(), Email: "old@example.com", Password: currentHash, EmailVerified: true}
+
+	t.Run("request sends a code to the new address and a notice to the old one", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("ExistsByEmail", mock.Anything, "new@example.com").Return(false, nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.EmailChange).Return(nil)
+		verificationRepo.On("Create", mock.Anything, mock.MatchedBy(func(code *domain.VerificationCode) bool {
+			return code.Type == domain.EmailChange && code.Target == "new@example.com"
+		})).Return(nil)
+		emailSvc := new(mockEmailService)
+		emailSvc.On("SendEmailChangeEmail", mock.Anything, "new@example.com", mock.Anything).Return(nil)
+		emailSvc.On("SendEmailChangeNoticeEmail", mock.Anything, "old@example.com", "new@example.com").Return(nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, emailSvc, nil, nil, nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{}, zap.NewNop())
+		err := service.RequestEmailChange(context.Background(), user.ID.String(), "new@example.com", "Old-Secret-42")
+
+		assert.NoError(t, err)
+		verificationRepo.AssertExpectations(t)
+		emailSvc.AssertExpectations(t)
+		userRepo.AssertNotCalled(t, "UpdateEmail", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("request for an address in use", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("ExistsByEmail", mock.Anything, "taken@example.com").Return(true, nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+
+		service := NewAuthService(userRepo, nil, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, &config.Config{}, zap.NewNop())
+		err := service.RequestEmailChange(context.Background(), user.ID.String(), "taken@example.com", "Old-Secret-42")
+
+		assert.Equal(t, domain.ErrUserAlreadyExists, err)
+	})
+
+	t.Run("confirm swaps the email", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("ExistsByEmail", mock.Anything, "new@example.com").Return(false, nil)
+		userRepo.On("UpdateEmail", mock.Anything, user.ID, "new@example.com").Return(nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		changeCode := domain.NewEmailChangeCode(user.ID, "CODE123", "new@example.com", time.Hour)
+		verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.EmailChange).Return(changeCode, nil)
+		verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.EmailChange).Return(nil)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, nil, nil, nil, &config.Config{}, zap.NewNop())
+		err := service.ConfirmEmailChange(context.Background(), user.ID.String(), "CODE123")
+
+		assert.NoError(t, err)
+		userRepo.AssertExpectations(t)
+		verificationRepo.AssertExpectations(t)
+	})
+
+	t.Run("confirm after the address was taken", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		userRepo.On("ExistsByEmail", mock.Anything, "new@example.com").Return(false, nil)
+		userRepo.On("UpdateEmail", mock.Anything, user.ID, "new@example.com").Return(domain.ErrUserAlreadyExists)
+		verificationRepo := new(mockVerificationCodeRepository)
+		changeCode := domain.NewEmailChangeCode(user.ID, "CODE123", "new@example.com", time.Hour)
+		verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.EmailChange).Return(changeCode, nil)
+		verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.EmailChange).Return(nil)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, nil, nil, nil, &config.Config{}, zap.NewNop())
+		err := service.ConfirmEmailChange(context.Background(), user.ID.String(), "CODE123")
+
+		assert.Equal(t, domain.ErrUserAlreadyExists, err)
+		verificationRepo.AssertExpectations(t)
+	})
+
+	t.Run("confirm with a wrong code", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		changeCode := domain.NewEmailChangeCode(user.ID, "CODE123", "new@example.com", time.Hour)
+		verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.EmailChange).Return(changeCode, nil)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, nil, nil, nil, &config.Config{}, zap.NewNop())
+		err := service.ConfirmEmailChange(context.Background(), user.ID.String(), "WRONG")
+
+		assert.Equal(t, domain.ErrInvalidVerificationCode, err)
+		userRepo.AssertNotCalled(t, "UpdateEmail", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func newPasswordlessTestConfig() *config.Config {
+	return &config.Config{
+		PasswordlessEnabled:    true,
+		PasswordlessCodeLength: 6,
+		PasswordlessCodeTTL:    10 * time.Minute,
+		PasswordlessLinkURL:    "https://app.example.com/login/magic",
+		PasswordlessRateLimit:  5,
+		PasswordlessRateWindow: time.Hour,
+	}
+}
+
+func TestAuthService_RequestPasswordlessLogin(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", EmailVerified: true}
+	throttleKey := domain.PasswordlessAttemptsKey("test@example.com")
+
+	t.Run("emails a hashed single-use code", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Throttle", mock.Anything, throttleKey, 5, time.Hour).Return(nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.PasswordlessLogin).Return(nil)
+		var stored *domain.VerificationCode
+		verificationRepo.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(*domain.VerificationCode)
+		}).Return(nil)
+		var sent string
+		emailSvc := new(mockEmailService)
+		emailSvc.On("SendPasswordlessCodeEmail", mock.Anything, "test@example.com", mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.String(2)
+		}).Return(nil)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, emailSvc, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, newPasswordlessTestConfig(), zap.NewNop())
+		err := service.RequestPasswordlessLogin(context.Background(), "test@example.com", domain.PasswordlessCode)
+
+		require.NoError(t, err)
+		assert.Regexp(t, `^\d{6}$`, sent)
+		assert.Equal(t, domain.PasswordlessLogin, stored.Type)
+		assert.Equal(t, hashOneTimeSecret(user.ID, sent), stored.Code)
+	})
+
+	t.Run("emails a magic link", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Throttle", mock.Anything, throttleKey, 5, time.Hour).Return(nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.PasswordlessLogin).Return(nil)
+		verificationRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		emailSvc := new(mockEmailService)
+		emailSvc.On("SendMagicLinkEmail", mock.Anything, "test@example.com", mock.MatchedBy(func(link string) bool {
+			return strings.HasPrefix(link, "https://app.example.com/login/magic?email=test%40example.com&token=")
+		})).Return(nil)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, emailSvc, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, newPasswordlessTestConfig(), zap.NewNop())
+		err := service.RequestPasswordlessLogin(context.Background(), "test@example.com", domain.PasswordlessLink)
+
+		require.NoError(t, err)
+		emailSvc.AssertExpectations(t)
+	})
+
+	t.Run("unknown email gets the same answer", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByEmail", mock.Anything, "nobody@example.com").Return(nil, domain.ErrUserNotFound)
+		lockout := new(mockLockoutService)
+		lockout.On("Throttle", mock.Anything, mock.Anything, 5, time.Hour).Return(nil)
+		emailSvc := new(mockEmailService)
+
+		service := NewAuthService(userRepo, nil, nil, emailSvc, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, newPasswordlessTestConfig(), zap.NewNop())
+		err := service.RequestPasswordlessLogin(context.Background(), "nobody@example.com", domain.PasswordlessCode)
+
+		assert.NoError(t, err)
+		emailSvc.AssertNotCalled(t, "SendPasswordlessCodeEmail", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("rate limited per address", func(t *testing.T) {
+		lockout := new(mockLockoutService)
+		lockout.On("Throttle", mock.Anything, throttleKey, 5, time.Hour).Return(domain.ErrTooManyAttempts)
+
+		service := NewAuthService(nil, nil, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, newPasswordlessTestConfig(), zap.NewNop())
+		err := service.RequestPasswordlessLogin(context.Background(), "test@example.com", domain.PasswordlessCode)
+
+		assert.Equal(t, domain.ErrTooManyAttempts, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		service := NewAuthService(nil, nil, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, nil, nil, nil, &config.Config{}, zap.NewNop())
+		err := service.RequestPasswordlessLogin(context.Background(), "test@example.com", domain.PasswordlessCode)
+
+		assert.Equal(t, domain.ErrPasswordlessDisabled, err)
+	})
+}
+
+func TestAuthService_PasswordlessLogin(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", Roles: []string{"user"}, EmailVerified: true}
+	stored := domain.NewVerificationCode(user.ID, hashOneTimeSecret(user.ID, "123456"), domain.PasswordlessLogin, 10*time.Minute)
+
+	t.Run("redeems the code for a token pair", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.PasswordlessLogin).Return(stored, nil)
+		verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.PasswordlessLogin).Return(nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return([]*domain.MFAFactor{}, nil)
+		jwtSvc := new(mockJWTService)
+		jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+
+		service := NewAuthService(userRepo, verificationRepo, jwtSvc, nil, mfaSvc, newTestMFAPolicy(), nil, nil, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, newPasswordlessTestConfig(), zap.NewNop())
+		result, err := service.PasswordlessLogin(context.Background(), "test@example.com", "123456")
+
+		require.NoError(t, err)
+		assert.Equal(t, "access_token", result.(*domain.TokenPair).AccessToken)
+		verificationRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown email counts as a failed attempt at the login", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByEmail", mock.Anything, "nobody@example.com").Return(nil, domain.ErrUserNotFound)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("CheckUnknown", mock.Anything, "nobody@example.com").Return(nil)
+		lockout.On("RecordUnknownFailure", mock.Anything, "nobody@example.com", "").Return(nil)
+
+		service := NewAuthService(userRepo, nil, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, newPasswordlessTestConfig(), zap.NewNop())
+		_, err := service.PasswordlessLogin(context.Background(), "nobody@example.com", "654321")
+
+		assert.Equal(t, domain.ErrInvalidVerificationCode, err)
+		lockout.AssertExpectations(t)
+	})
+
+	t.Run("wrong code counts as a failed attempt", func(t *testing.T) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByEmail", mock.Anything, "test@example.com").Return(user, nil)
+		verificationRepo := new(mockVerificationCodeRepository)
+		verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.PasswordlessLogin).Return(stored, nil)
+		lockout := new(mockLockoutService)
+		lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		lockout.On("RecordFailure", mock.Anything, user, "").Return(nil)
+
+		service := NewAuthService(userRepo, verificationRepo, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), nil, nil, lockout, nil, nil, newPasswordlessTestConfig(), zap.NewNop())
+		_, err := service.PasswordlessLogin(context.Background(), "test@example.com", "654321")
+
+		assert.Equal(t, domain.ErrInvalidVerificationCode, err)
+		lockout.AssertExpectations(t)
+		verificationRepo.AssertNotCalled(t, "DeleteByUserIDAndType", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestLinkWithQuery(t *testing.T) {
+	params := url.Values{"email": {"jane+1@example.com"}, "token": {"abc"}}
+
+	assert.Equal(t, "https://app.example.com/signin?email=jane%2B1%40example.com&token=abc",
+		linkWithQuery("https://app.example.com/signin", params))
+	assert.Equal(t, "https://app.example.com/signin?lang=en&email=jane%2B1%40example.com&token=abc",
+		linkWithQuery("https://app.example.com/signin?lang=en", params))
+}
@@ -0,0 +1,298 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+type CIBAService struct {
+	oauth2Service domain.OAuth2Service
+	scopeService  domain.ScopeService
+	cibaRepo      domain.BackchannelAuthRepository
+	userRepo      domain.UserRepository
+	tokenIssuer   domain.TokenIssuer
+	notifier      domain.BackchannelNotifier
+	config        *config.Config
+	logger        *zap.Logger
+}
+
+func NewCIBAService(oauth2Service domain.OAuth2Service, scopeService domain.ScopeService, cibaRepo domain.BackchannelAuthRepository, userRepo domain.UserRepository, tokenIssuer domain.TokenIssuer, notifier domain.BackchannelNotifier, config *config.Config, logger *zap.Logger) *CIBAService {
+	return &CIBAService{
+		oauth2Service: oauth2Service,
+		scopeService:  scopeService,
+		cibaRepo:      cibaRepo,
+		userRepo:      userRepo,
+		tokenIssuer:   tokenIssuer,
+		notifier:      notifier,
+		config:        config,
+		logger:        logger,
+	}
+}
+
+func (s *CIBAService) Authenticate(ctx context.Context, clientID, clientSecret, loginHint, bindingMessage, scope, clientNotificationToken string) (*domain.BackchannelAuthResponse, error) {
+	s.logger.Debug("Starting backchannel authentication",
+		zap.String("client_id", clientID),
+		zap.String("scope", scope))
+
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ping clients must give us a token to authenticate the notification with
+	if client.UsesBackchannelPing() && clientNotificationToken == "" {
+		s.logger.Error("Missing client notification token",
+			zap.String("client_id", clientID))
+		return nil, domain.ErrInvalidField
+	}
+
+	// CIBA is an OpenID Connect flow, so the openid scope is required
+	scopes := strings.Fields(scope)
+	if !containsScope(scopes, "openid") {
+		s.logger.Error("Backchannel authentication without openid scope",
+			zap.String("client_id", clientID))
+		return nil, domain.ErrInvalidScope
+	}
+	for _, requested := range scopes {
+		if !containsScope(client.Scopes, requested) {
+			s.logger.Error("Invalid scope requested",
+				zap.String("scope", requested),
+				zap.Strings("allowed_scopes", client.Scopes))
+			return nil, domain.ErrInvalidScope
+		}
+	}
+	if err := s.scopeService.ValidateScopes(ctx, client.ID, scopes); err != nil {
+		return nil, err
+	}
+
+	// The login hint identifies the user by email
+	user, err := s.userRepo.FindByEmail(ctx, loginHint)
+	if err != nil {
+		s.logger.Error("Unknown user in login hint",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return nil, domain.ErrUnknownUserID
+	}
+
+	now := time.Now()
+	req := &domain.BackchannelAuthRequest{
+		AuthReqID:               ulid.Make().String(),
+		ClientID:                client.ID,
+		UserID:                  user.ID.String(),
+		Scopes:                  scopes,
+		BindingMessage:          bindingMessage,
+		ClientNotificationToken: clientNotificationToken,
+		Status:                  domain.BackchannelAuthStatusPending,
+		Interval:                int(s.config.CIBAPollInterval.Seconds()),
+		CreatedAt:               now,
+		ExpiresAt:               now.Add(s.config.CIBARequestExpiry),
+	}
+
+	if err := s.cibaRepo.Create(ctx, req); err != nil {
+		s.logger.Error("Failed to store backchannel authentication request",
+			zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	s.logger.Info("Backchannel authentication request created",
+		zap.String("client_id", client.ID),
+		zap.String("auth_req_id", req.AuthReqID),
+		zap.String("user_id", req.UserID))
+
+	return &domain.BackchannelAuthResponse{
+		AuthReqID: req.AuthReqID,
+		ExpiresIn: int(s.config.CIBARequestExpiry.Seconds()),
+		Interval:  req.Interval,
+	}, nil
+}
+
+func (s *CIBAService) ListPending(ctx context.Context, userID string) ([]*domain.BackchannelAuthRequest, error) {
+	return s.cibaRepo.ListPendingByUser(ctx, userID)
+}
+
+func (s *CIBAService) Approve(ctx context.Context, userID, authReqID string) error {
+	return s.answer(ctx, userID, authReqID, domain.BackchannelAuthStatusApproved)
+}
+
+func (s *CIBAService) Deny(ctx context.Context, userID, authReqID string) error {
+	return s.answer(ctx, userID, authReqID, domain.BackchannelAuthStatusDenied)
+}
+
+func (s *CIBAService) ExchangeAuthReqID(ctx context.Context, clientID, clientSecret, authReqID string) (*domain.TokenPair, error) {
+	s.logger.Debug("Exchanging auth_req_id",
+		zap.String("client_id", clientID),
+		zap.String("auth_req_id", authReqID))
+
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := s.cibaRepo.FindByID(ctx, authReqID)
+	if err != nil {
+		s.logger.Error("Failed to find backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+		return nil, domain.ErrInvalidAuthReqID
+	}
+	if req.ClientID != client.ID {
+		s.logger.Error("auth_req_id issued to another client",
+			zap.String("auth_req_id", authReqID),
+			zap.String("client_id", client.ID))
+		return nil, domain.ErrInvalidAuthReqID
+	}
+
+	now := time.Now()
+	if req.IsExpired(now) {
+		return nil, domain.ErrAuthReqExpired
+	}
+
+	switch req.Status {
+	case domain.BackchannelAuthStatusDenied:
+		s.deleteRequest(ctx, authReqID)
+		return nil, domain.ErrAccessDenied
+
+	case domain.BackchannelAuthStatusPending:
+		// Poll clients that call faster than their interval get a longer one
+		if req.PolledTooSoon(now) {
+			req.Interval += domain.BackchannelSlowDownIncrement
+			err = domain.ErrSlowDown
+		} else {
+			err = domain.ErrAuthorizationPending
+		}
+		req.LastPolledAt = &now
+		if updateErr := s.cibaRepo.Update(ctx, req); updateErr != nil {
+			s.logger.Error("Failed to record poll",
+				zap.String("auth_req_id", authReqID),
+				zap.Error(updateErr))
+			return nil, domain.ErrInternal
+		}
+		return nil, err
+	}
+
+	// An auth_req_id can only be redeemed once, so concurrent polls race on consuming it
+	req, err = s.cibaRepo.ConsumeApproved(ctx, authReqID)
+	if err != nil {
+		s.logger.Error("Failed to consume backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+		return nil, domain.ErrInvalidAuthReqID
+	}
+
+	id, err := ulid.Parse(req.UserID)
+	if err != nil {
+		s.logger.Error("Invalid user ID in backchannel authentication request",
+			zap.String("user_id", req.UserID),
+			zap.Error(err))
+		return nil, domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to find user",
+			zap.String("user_id", req.UserID),
+			zap.Error(err))
+		return nil, domain.ErrUserNotFound
+	}
+
+	var auth *domain.Authentication
+	if req.AuthTime != nil {
+		auth = &domain.Authentication{Methods: req.AMR, Time: *req.AuthTime}
+	}
+
+	tokenPair, err := s.tokenIssuer.IssueTokens(ctx, client, user, req.Scopes, nil, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Successfully exchanged auth_req_id",
+		zap.String("client_id", client.ID),
+		zap.String("user_id", req.UserID))
+
+	return tokenPair, nil
+}
+
+// answer records the user's answer and pings the client when it registered for ping delivery
+func (s *CIBAService) answer(ctx context.Context, userID, authReqID, status string) error {
+	req, err := s.cibaRepo.FindByID(ctx, authReqID)
+	if err != nil {
+		s.logger.Error("Failed to find backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+		return domain.ErrInvalidAuthReqID
+	}
+	if req.UserID != userID || req.Status != domain.BackchannelAuthStatusPending {
+		s.logger.Error("Backchannel authentication request cannot be answered by user",
+			zap.String("auth_req_id", authReqID),
+			zap.String("user_id", userID),
+			zap.String("status", req.Status))
+		return domain.ErrInvalidAuthReqID
+	}
+	if req.IsExpired(time.Now()) {
+		return domain.ErrAuthReqExpired
+	}
+
+	req.Status = status
+	// Tokens of an approved request record the sign-in the user approved it with
+	if auth, ok := domain.GetAuthentication(ctx); ok && status == domain.BackchannelAuthStatusApproved {
+		req.AMR = auth.Methods
+		req.AuthTime = &auth.Time
+	}
+	if err := s.cibaRepo.Update(ctx, req); err != nil {
+		s.logger.Error("Failed to update backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	s.logger.Info("Backchannel authentication request answered",
+		zap.String("auth_req_id", authReqID),
+		zap.String("status", status))
+
+	client, err := s.oauth2Service.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return err
+	}
+	if client.UsesBackchannelPing() {
+		// The answer is stored, so the client can still poll if the ping is lost
+		if err := s.notifier.Notify(ctx, client.BackchannelClientNotificationEndpoint, req.ClientNotificationToken, req.AuthReqID); err != nil {
+			s.logger.Warn("Failed to ping client",
+				zap.String("client_id", client.ID),
+				zap.String("auth_req_id", authReqID),
+				zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// authenticateClient checks the client credentials and that the client may use the CIBA grant, which
+// is only open to confidential clients
+func (s *CIBAService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*domain.OAuth2Client, error) {
+	client, err := s.oauth2Service.AuthenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.IsConfidential() || !client.AllowsGrantType(domain.GrantTypeCIBA) || client.BackchannelTokenDeliveryMode == "" {
+		s.logger.Error("Client is not registered for backchannel authentication",
+			zap.String("client_id", clientID))
+		return nil, domain.ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+func (s *CIBAService) deleteRequest(ctx context.Context, authReqID string) {
+	if err := s.cibaRepo.Delete(ctx, authReqID); err != nil {
+		s.logger.Error("Failed to delete backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+	}
+}
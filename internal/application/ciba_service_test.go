@@ -0,0 +1,441 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type mockBackchannelAuthRepository struct {
+	mock.Mock
+}
+
+func (m *mockBackchannelAuthRepository) Create(ctx context.Context, req *domain.BackchannelAuthRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *mockBackchannelAuthRepository) FindByID(ctx context.Context, authReqID string) (*domain.BackchannelAuthRequest, error) {
+	args := m.Called(ctx, authReqID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BackchannelAuthRequest), args.Error(1)
+}
+
+func (m *mockBackchannelAuthRepository) ListPendingByUser(ctx context.Context, userID string) ([]*domain.BackchannelAuthRequest, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BackchannelAuthRequest), args.Error(1)
+}
+
+func (m *mockBackchannelAuthRepository) Update(ctx context.Context, req *domain.BackchannelAuthRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *mockBackchannelAuthRepository) ConsumeApproved(ctx context.Context, authReqID string) (*domain.BackchannelAuthRequest, error) {
+	args := m.Called(ctx, authReqID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BackchannelAuthRequest), args.Error(1)
+}
+
+func (m *mockBackchannelAuthRepository) Delete(ctx context.Context, authReqID string) error {
+	args := m.Called(ctx, authReqID)
+	return args.Error(0)
+}
+
+type mockBackchannelNotifier struct {
+	mock.Mock
+}
+
+func (m *mockBackchannelNotifier) Notify(ctx context.Context, endpoint, clientNotificationToken, authReqID string) error {
+	args := m.Called(ctx, endpoint, clientNotificationToken, authReqID)
+	return args.Error(0)
+}
+
+type mockTokenIssuer struct {
+	mock.Mock
+}
+
+func (m *mockTokenIssuer) IssueTokens(ctx context.Context, client *domain.OAuth2Client, user *domain.User, scopes []string, claimsRequest *domain.ClaimsRequest, auth *domain.Authentication) (*domain.TokenPair, error) {
+	args := m.Called(ctx, client, user, scopes, claimsRequest, auth)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+const cibaUserID = "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+
+func newCIBAClient(deliveryMode string) *domain.OAuth2Client {
+	client := &domain.OAuth2Client{
+		ID:                           "call-centre",
+		Secret:                       "secret",
+		GrantTypes:                   []string{domain.GrantTypeCIBA},
+		Scopes:                       []string{"openid", "profile"},
+		BackchannelTokenDeliveryMode: deliveryMode,
+	}
+	if deliveryMode == domain.BackchannelTokenDeliveryModePing {
+		client.BackchannelClientNotificationEndpoint = "https://call-centre.example.com/ciba"
+	}
+	return client
+}
+
+func newCIBAService(oauth2Service *mockOAuth2Service, cibaRepo *mockBackchannelAuthRepository, userRepo *mockUserRepository, tokenIssuer *mockTokenIssuer, notifier *mockBackchannelNotifier) *CIBAService {
+	cfg := &config.Config{CIBARequestExpiry: 5 * time.Minute, CIBAPollInterval: 5 * time.Second}
+	return NewCIBAService(oauth2Service, newStandardScopeService(), cibaRepo, userRepo, tokenIssuer, notifier, cfg, zap.NewNop())
+}
+
+// onAuthenticateClient makes the OAuth2 service authenticate the client when the secret matches
+func onAuthenticateClient(oauth2Service *mockOAuth2Service, client *domain.OAuth2Client, secret string) {
+	if secret == client.Secret {
+		oauth2Service.On("AuthenticateClient", mock.Anything, client.ID, secret).Return(client, nil)
+	} else {
+		oauth2Service.On("AuthenticateClient", mock.Anything, client.ID, secret).Return(nil, domain.ErrInvalidClient)
+	}
+}
+
+func TestCIBAService_Authenticate(t *testing.T) {
+	tests := []struct {
+		name                    string
+		client                  *domain.OAuth2Client
+		clientSecret            string
+		scope                   string
+		clientNotificationToken string
+		mockSetup               func(*mockUserRepository, *mockBackchannelAuthRepository)
+		expectedError           error
+	}{
+		{
+			name:         "poll client starts a request",
+			client:       newCIBAClient(domain.BackchannelTokenDeliveryModePoll),
+			clientSecret: "secret",
+			scope:        "openid profile",
+			mockSetup: func(u *mockUserRepository, r *mockBackchannelAuthRepository) {
+				u.On("FindByEmail", mock.Anything, "customer@example.com").Return(&domain.User{ID: ulid.MustParse(cibaUserID)}, nil)
+				r.On("Create", mock.Anything, mock.MatchedBy(func(req *domain.BackchannelAuthRequest) bool {
+					return req.UserID == cibaUserID &&
+						req.ClientID == "call-centre" &&
+						req.Status == domain.BackchannelAuthStatusPending &&
+						req.BindingMessage == "Call 4821" &&
+						req.Interval == 5
+				})).Return(nil)
+			},
+		},
+		{
+			name:          "invalid client secret",
+			client:        newCIBAClient(domain.BackchannelTokenDeliveryModePoll),
+			clientSecret:  "wrong",
+			scope:         "openid",
+			mockSetup:     func(u *mockUserRepository, r *mockBackchannelAuthRepository) {},
+			expectedError: domain.ErrInvalidClient,
+		},
+		{
+			name: "public client",
+			client: &domain.OAuth2Client{
+				ID:                           "call-centre",
+				GrantTypes:                   []string{domain.GrantTypeCIBA},
+				Scopes:                       []string{"openid"},
+				BackchannelTokenDeliveryMode: domain.BackchannelTokenDeliveryModePoll,
+			},
+			clientSecret:  "",
+			scope:         "openid",
+			mockSetup:     func(u *mockUserRepository, r *mockBackchannelAuthRepository) {},
+			expectedError: domain.ErrInvalidClient,
+		},
+		{
+			name: "client not registered for CIBA",
+			client: &domain.OAuth2Client{
+				ID:         "call-centre",
+				Secret:     "secret",
+				GrantTypes: []string{"authorization_code"},
+				Scopes:     []string{"openid"},
+			},
+			clientSecret:  "secret",
+			scope:         "openid",
+			mockSetup:     func(u *mockUserRepository, r *mockBackchannelAuthRepository) {},
+			expectedError: domain.ErrInvalidClient,
+		},
+		{
+			name:          "missing openid scope",
+			client:        newCIBAClient(domain.BackchannelTokenDeliveryModePoll),
+			clientSecret:  "secret",
+			scope:         "profile",
+			mockSetup:     func(u *mockUserRepository, r *mockBackchannelAuthRepository) {},
+			expectedError: domain.ErrInvalidScope,
+		},
+		{
+			name:          "ping client without notification token",
+			client:        newCIBAClient(domain.BackchannelTokenDeliveryModePing),
+			clientSecret:  "secret",
+			scope:         "openid",
+			mockSetup:     func(u *mockUserRepository, r *mockBackchannelAuthRepository) {},
+			expectedError: domain.ErrInvalidField,
+		},
+		{
+			name:         "unknown login hint",
+			client:       newCIBAClient(domain.BackchannelTokenDeliveryModePoll),
+			clientSecret: "secret",
+			scope:        "openid",
+			mockSetup: func(u *mockUserRepository, r *mockBackchannelAuthRepository) {
+				u.On("FindByEmail", mock.Anything, "customer@example.com").Return(nil, domain.ErrUserNotFound)
+			},
+			expectedError: domain.ErrUnknownUserID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oauth2Service := new(mockOAuth2Service)
+			cibaRepo := new(mockBackchannelAuthRepository)
+			userRepo := new(mockUserRepository)
+			onAuthenticateClient(oauth2Service, tt.client, tt.clientSecret)
+			tt.mockSetup(userRepo, cibaRepo)
+
+			service := newCIBAService(oauth2Service, cibaRepo, userRepo, nil, nil)
+			response, err := service.Authenticate(context.Background(), "call-centre", tt.clientSecret, "customer@example.com", "Call 4821", tt.scope, tt.clientNotificationToken)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, response)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, response.AuthReqID)
+				assert.Equal(t, 300, response.ExpiresIn)
+				assert.Equal(t, 5, response.Interval)
+			}
+
+			cibaRepo.AssertExpectations(t)
+			userRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCIBAService_ExchangeAuthReqID(t *testing.T) {
+	justNow := time.Now().Add(-time.Second)
+	longAgo := time.Now().Add(-time.Minute)
+	approvedAt := time.Now().Add(-30 * time.Second)
+
+	tests := []struct {
+		name          string
+		request       *domain.BackchannelAuthRequest
+		mockSetup     func(*mockBackchannelAuthRepository, *mockUserRepository, *mockTokenIssuer)
+		expectedError error
+	}{
+		{
+			name: "pending request",
+			request: &domain.BackchannelAuthRequest{
+				Status:       domain.BackchannelAuthStatusPending,
+				Interval:     5,
+				LastPolledAt: &longAgo,
+			},
+			mockSetup: func(r *mockBackchannelAuthRepository, u *mockUserRepository, j *mockTokenIssuer) {
+				r.On("Update", mock.Anything, mock.MatchedBy(func(req *domain.BackchannelAuthRequest) bool {
+					return req.Interval == 5 && req.LastPolledAt.After(longAgo)
+				})).Return(nil)
+			},
+			expectedError: domain.ErrAuthorizationPending,
+		},
+		{
+			name: "polling faster than the interval",
+			request: &domain.BackchannelAuthRequest{
+				Status:       domain.BackchannelAuthStatusPending,
+				Interval:     5,
+				LastPolledAt: &justNow,
+			},
+			mockSetup: func(r *mockBackchannelAuthRepository, u *mockUserRepository, j *mockTokenIssuer) {
+				r.On("Update", mock.Anything, mock.MatchedBy(func(req *domain.BackchannelAuthRequest) bool {
+					return req.Interval == 10
+				})).Return(nil)
+			},
+			expectedError: domain.ErrSlowDown,
+		},
+		{
+			name:    "denied request",
+			request: &domain.BackchannelAuthRequest{Status: domain.BackchannelAuthStatusDenied},
+			mockSetup: func(r *mockBackchannelAuthRepository, u *mockUserRepository, j *mockTokenIssuer) {
+				r.On("Delete", mock.Anything, "auth-req-1").Return(nil)
+			},
+			expectedError: domain.ErrAccessDenied,
+		},
+		{
+			name: "expired request",
+			request: &domain.BackchannelAuthRequest{
+				Status:    domain.BackchannelAuthStatusApproved,
+				ExpiresAt: time.Now().Add(-time.Second),
+			},
+			mockSetup:     func(r *mockBackchannelAuthRepository, u *mockUserRepository, j *mockTokenIssuer) {},
+			expectedError: domain.ErrAuthReqExpired,
+		},
+		{
+			name: "request issued to another client",
+			request: &domain.BackchannelAuthRequest{
+				ClientID: "other-client",
+				Status:   domain.BackchannelAuthStatusApproved,
+			},
+			mockSetup:     func(r *mockBackchannelAuthRepository, u *mockUserRepository, j *mockTokenIssuer) {},
+			expectedError: domain.ErrInvalidAuthReqID,
+		},
+		{
+			name: "approved request",
+			request: &domain.BackchannelAuthRequest{
+				Status: domain.BackchannelAuthStatusApproved,
+				Scopes: []string{"openid", "profile"},
+			},
+			mockSetup: func(r *mockBackchannelAuthRepository, u *mockUserRepository, j *mockTokenIssuer) {
+				r.On("ConsumeApproved", mock.Anything, "auth-req-1").Return(&domain.BackchannelAuthRequest{
+					AuthReqID: "auth-req-1",
+					ClientID:  "call-centre",
+					UserID:    cibaUserID,
+					Scopes:    []string{"openid", "profile"},
+					Status:    domain.BackchannelAuthStatusApproved,
+					AMR:       []string{domain.AMRPassword, domain.AMROTP, domain.AMRMultiFactor},
+					AuthTime:  &approvedAt,
+				}, nil)
+				user := &domain.User{ID: ulid.MustParse(cibaUserID), Roles: []string{"user"}}
+				u.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+				j.On("IssueTokens", mock.Anything, mock.MatchedBy(func(c *domain.OAuth2Client) bool {
+					return c.ID == "call-centre"
+				}), user, []string{"openid", "profile"}, (*domain.ClaimsRequest)(nil), mock.MatchedBy(func(auth *domain.Authentication) bool {
+					return auth != nil && auth.Time.Equal(approvedAt) && auth.ACR() == domain.ACRMultiFactor
+				})).Return(&domain.TokenPair{
+					AccessToken:  "access_token",
+					RefreshToken: "refresh_token",
+					IDToken:      "id_token",
+				}, nil)
+			},
+		},
+		{
+			name:    "approved request redeemed concurrently",
+			request: &domain.BackchannelAuthRequest{Status: domain.BackchannelAuthStatusApproved},
+			mockSetup: func(r *mockBackchannelAuthRepository, u *mockUserRepository, j *mockTokenIssuer) {
+				r.On("ConsumeApproved", mock.Anything, "auth-req-1").Return(nil, domain.ErrInvalidAuthReqID)
+			},
+			expectedError: domain.ErrInvalidAuthReqID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oauth2Service := new(mockOAuth2Service)
+			cibaRepo := new(mockBackchannelAuthRepository)
+			userRepo := new(mockUserRepository)
+			tokenIssuer := new(mockTokenIssuer)
+
+			tt.request.AuthReqID = "auth-req-1"
+			tt.request.UserID = cibaUserID
+			if tt.request.ClientID == "" {
+				tt.request.ClientID = "call-centre"
+			}
+			if tt.request.ExpiresAt.IsZero() {
+				tt.request.ExpiresAt = time.Now().Add(time.Minute)
+			}
+
+			onAuthenticateClient(oauth2Service, newCIBAClient(domain.BackchannelTokenDeliveryModePoll), "secret")
+			cibaRepo.On("FindByID", mock.Anything, "auth-req-1").Return(tt.request, nil)
+			tt.mockSetup(cibaRepo, userRepo, tokenIssuer)
+
+			service := newCIBAService(oauth2Service, cibaRepo, userRepo, tokenIssuer, nil)
+			token, err := service.ExchangeAuthReqID(context.Background(), "call-centre", "secret", "auth-req-1")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, token)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "access_token", token.AccessToken)
+			}
+
+			cibaRepo.AssertExpectations(t)
+			tokenIssuer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCIBAService_Approve(t *testing.T) {
+	signedIn := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name          string
+		userID        string
+		deliveryMode  string
+		notifyError   error
+		expectedError error
+	}{
+		{
+			name:         "poll client is not notified",
+			userID:       cibaUserID,
+			deliveryMode: domain.BackchannelTokenDeliveryModePoll,
+		},
+		{
+			name:         "ping client is notified",
+			userID:       cibaUserID,
+			deliveryMode: domain.BackchannelTokenDeliveryModePing,
+		},
+		{
+			name:         "failed ping still records the answer",
+			userID:       cibaUserID,
+			deliveryMode: domain.BackchannelTokenDeliveryModePing,
+			notifyError:  errors.New("connection refused"),
+		},
+		{
+			name:          "request of another user",
+			userID:        "01BX5ZZKBKACTAV9WEVGEMMVRZ",
+			deliveryMode:  domain.BackchannelTokenDeliveryModePoll,
+			expectedError: domain.ErrInvalidAuthReqID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oauth2Service := new(mockOAuth2Service)
+			cibaRepo := new(mockBackchannelAuthRepository)
+			notifier := new(mockBackchannelNotifier)
+
+			cibaRepo.On("FindByID", mock.Anything, "auth-req-1").Return(&domain.BackchannelAuthRequest{
+				AuthReqID:               "auth-req-1",
+				ClientID:                "call-centre",
+				UserID:                  cibaUserID,
+				ClientNotificationToken: "notification-token",
+				Status:                  domain.BackchannelAuthStatusPending,
+				ExpiresAt:               time.Now().Add(time.Minute),
+			}, nil)
+
+			if tt.expectedError == nil {
+				cibaRepo.On("Update", mock.Anything, mock.MatchedBy(func(req *domain.BackchannelAuthRequest) bool {
+					return req.Status == domain.BackchannelAuthStatusApproved &&
+						req.AuthTime != nil && req.AuthTime.Equal(signedIn) &&
+						assert.ObjectsAreEqual([]string{domain.AMRPassword}, req.AMR)
+				})).Return(nil)
+				oauth2Service.On("GetClient", mock.Anything, "call-centre").Return(newCIBAClient(tt.deliveryMode), nil)
+			}
+			if tt.deliveryMode == domain.BackchannelTokenDeliveryModePing {
+				notifier.On("Notify", mock.Anything, "https://call-centre.example.com/ciba", "notification-token", "auth-req-1").Return(tt.notifyError)
+			}
+
+			service := newCIBAService(oauth2Service, cibaRepo, nil, nil, notifier)
+			ctx := domain.WithAuthentication(context.Background(), &domain.Authentication{Methods: []string{domain.AMRPassword}, Time: signedIn})
+			err := service.Approve(ctx, tt.userID, "auth-req-1")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			cibaRepo.AssertExpectations(t)
+			notifier.AssertExpectations(t)
+		})
+	}
+}
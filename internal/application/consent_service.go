@@ -0,0 +1,162 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// ConsentService keeps the consents users give clients for scopes registered as requiring consent.
+// Scopes that do not require consent are granted without asking.
+type ConsentService struct {
+	consentRepo   domain.ConsentRepository
+	oauth2Service domain.OAuth2Service
+	scopeService  domain.ScopeService
+	logger        *zap.Logger
+}
+
+// NewConsentService creates a new consent service
+func NewConsentService(consentRepo domain.ConsentRepository, oauth2Service domain.OAuth2Service, scopeService domain.ScopeService, logger *zap.Logger) *ConsentService {
+	return &ConsentService{
+		consentRepo:   consentRepo,
+		oauth2Service: oauth2Service,
+		scopeService:  scopeService,
+		logger:        logger,
+	}
+}
+
+// CheckConsent returns a *ConsentRequiredError when any of the scopes requires consent the user has
+// not given the client
+func (s *ConsentService) CheckConsent(ctx context.Context, userID, clientID string, scopes []string) error {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return domain.ErrInvalidUserID
+	}
+
+	required, err := s.requiringConsent(ctx, scopes)
+	if err != nil || len(required) == 0 {
+		return err
+	}
+
+	consent, err := s.consentRepo.Find(ctx, id, clientID)
+	if err != nil && err != domain.ErrConsentNotFound {
+		return err
+	}
+
+	missing := make([]string, 0, len(required))
+	for _, scope := range required {
+		if consent == nil || !consent.Covers(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		s.logger.Info("Consent required",
+			zap.String("user_id", userID),
+			zap.String("client_id", clientID),
+			zap.Strings("scopes", missing))
+		return domain.NewConsentRequiredError(missing)
+	}
+
+	return nil
+}
+
+// GrantConsent records the user's consent to the scopes for the client, keeping earlier ones. The
+// scopes must be ones the client may request.
+func (s *ConsentService) GrantConsent(ctx context.Context, userID, clientID string, scopes []string) (*domain.Consent, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	client, err := s.oauth2Service.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		if !containsScope(client.Scopes, scope) {
+			return nil, domain.ErrInvalidScope
+		}
+	}
+	if err := s.scopeService.ValidateScopes(ctx, client.ID, scopes); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	consent, err := s.consentRepo.Find(ctx, id, client.ID)
+	if err == domain.ErrConsentNotFound {
+		consent = &domain.Consent{UserID: id, ClientID: client.ID, Scopes: []string{}, CreatedAt: now}
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, scope := range scopes {
+		if !consent.Covers(scope) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+	consent.UpdatedAt = now
+
+	if err := s.consentRepo.Save(ctx, consent); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Consent granted",
+		zap.String("user_id", userID),
+		zap.String("client_id", client.ID),
+		zap.Strings("scopes", consent.Scopes))
+
+	return consent, nil
+}
+
+// ListConsents lists the consents of a user
+func (s *ConsentService) ListConsents(ctx context.Context, userID string) ([]*domain.Consent, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	return s.consentRepo.ListByUser(ctx, id)
+}
+
+// RevokeConsent withdraws the user's consent for the client; its next authorization request asks again
+func (s *ConsentService) RevokeConsent(ctx context.Context, userID, clientID string) error {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return domain.ErrInvalidUserID
+	}
+
+	if _, err := s.consentRepo.Find(ctx, id, clientID); err != nil {
+		return err
+	}
+
+	if err := s.consentRepo.Delete(ctx, id, clientID); err != nil {
+		return err
+	}
+
+	s.logger.Info("Consent revoked",
+		zap.String("user_id", userID),
+		zap.String("client_id", clientID))
+
+	return nil
+}
+
+// requiringConsent returns the scopes registered as requiring consent
+func (s *ConsentService) requiringConsent(ctx context.Context, scopes []string) ([]string, error) {
+	required := make([]string, 0)
+	for _, name := range scopes {
+		scope, err := s.scopeService.GetScope(ctx, name)
+		if err != nil {
+			if err == domain.ErrScopeNotFound {
+				continue
+			}
+			return nil, err
+		}
+		if scope.RequiresConsent && !containsScope(required, name) {
+			required = append(required, name)
+		}
+	}
+	return required, nil
+}
@@ -0,0 +1,147 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockConsentRepository struct {
+	mock.Mock
+}
+
+func (m *mockConsentRepository) Find(ctx context.Context, userID ulid.ULID, clientID string) (*domain.Consent, error) {
+	args := m.Called(ctx, userID, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Consent), args.Error(1)
+}
+
+func (m *mockConsentRepository) Save(ctx context.Context, consent *domain.Consent) error {
+	args := m.Called(ctx, consent)
+	return args.Error(0)
+}
+
+func (m *mockConsentRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.Consent, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Consent), args.Error(1)
+}
+
+func (m *mockConsentRepository) Delete(ctx context.Context, userID ulid.ULID, clientID string) error {
+	args := m.Called(ctx, userID, clientID)
+	return args.Error(0)
+}
+
+// newTestConsentService returns a consent service over the standard scopes, where payments requires consent
+func newTestConsentService(repo *mockConsentRepository, oauth2Service domain.OAuth2Service) *ConsentService {
+	return NewConsentService(repo, oauth2Service, newStandardScopeService(), zap.NewNop())
+}
+
+func TestConsentService_CheckConsent(t *testing.T) {
+	userID := ulid.Make()
+
+	t.Run("scopes without consent", func(t *testing.T) {
+		repo := new(mockConsentRepository)
+		service := newTestConsentService(repo, nil)
+
+		err := service.CheckConsent(context.Background(), userID.String(), "shop", []string{"openid", "profile"})
+		assert.NoError(t, err)
+		repo.AssertNotCalled(t, "Find", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("consent not given", func(t *testing.T) {
+		repo := new(mockConsentRepository)
+		repo.On("Find", mock.Anything, userID, "shop").Return(nil, domain.ErrConsentNotFound)
+		service := newTestConsentService(repo, nil)
+
+		err := service.CheckConsent(context.Background(), userID.String(), "shop", []string{"openid", "payments"})
+		var consentErr *domain.ConsentRequiredError
+		require.True(t, errors.As(err, &consentErr))
+		assert.ErrorIs(t, err, domain.ErrConsentRequired)
+		assert.Equal(t, []string{"payments"}, consentErr.Scopes)
+	})
+
+	t.Run("consent given", func(t *testing.T) {
+		repo := new(mockConsentRepository)
+		repo.On("Find", mock.Anything, userID, "shop").Return(&domain.Consent{UserID: userID, ClientID: "shop", Scopes: []string{"payments"}}, nil)
+		service := newTestConsentService(repo, nil)
+
+		err := service.CheckConsent(context.Background(), userID.String(), "shop", []string{"openid", "payments"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestConsentService_GrantConsent(t *testing.T) {
+	userID := ulid.Make()
+	client := &domain.OAuth2Client{ID: "shop", Scopes: []string{"openid", "payments"}}
+
+	t.Run("adds to the earlier consent", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		oauth2Service.On("GetClient", mock.Anything, "shop").Return(client, nil)
+		repo := new(mockConsentRepository)
+		repo.On("Find", mock.Anything, userID, "shop").Return(&domain.Consent{UserID: userID, ClientID: "shop", Scopes: []string{"openid"}}, nil)
+		repo.On("Save", mock.Anything, mock.MatchedBy(func(consent *domain.Consent) bool {
+			return assert.ObjectsAreEqual([]string{"openid", "payments"}, consent.Scopes)
+		})).Return(nil)
+		service := newTestConsentService(repo, oauth2Service)
+
+		consent, err := service.GrantConsent(context.Background(), userID.String(), "shop", []string{"payments"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"openid", "payments"}, consent.Scopes)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("scope the client may not request", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		oauth2Service.On("GetClient", mock.Anything, "shop").Return(client, nil)
+		repo := new(mockConsentRepository)
+		service := newTestConsentService(repo, oauth2Service)
+
+		_, err := service.GrantConsent(context.Background(), userID.String(), "shop", []string{"admin"})
+		assert.ErrorIs(t, err, domain.ErrInvalidScope)
+		repo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown client", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		oauth2Service.On("GetClient", mock.Anything, "other").Return(nil, domain.ErrClientNotFound)
+		service := newTestConsentService(new(mockConsentRepository), oauth2Service)
+
+		_, err := service.GrantConsent(context.Background(), userID.String(), "other", []string{"payments"})
+		assert.ErrorIs(t, err, domain.ErrClientNotFound)
+	})
+}
+
+func TestConsentService_RevokeConsent(t *testing.T) {
+	userID := ulid.Make()
+
+	t.Run("revokes the consent", func(t *testing.T) {
+		repo := new(mockConsentRepository)
+		repo.On("Find", mock.Anything, userID, "shop").Return(&domain.Consent{UserID: userID, ClientID: "shop"}, nil)
+		repo.On("Delete", mock.Anything, userID, "shop").Return(nil)
+		service := newTestConsentService(repo, nil)
+
+		assert.NoError(t, service.RevokeConsent(context.Background(), userID.String(), "shop"))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("no consent", func(t *testing.T) {
+		repo := new(mockConsentRepository)
+		repo.On("Find", mock.Anything, userID, "shop").Return(nil, domain.ErrConsentNotFound)
+		service := newTestConsentService(repo, nil)
+
+		assert.ErrorIs(t, service.RevokeConsent(context.Background(), userID.String(), "shop"), domain.ErrConsentNotFound)
+		repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,234 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// FederationService signs users in with upstream OpenID Connect providers. A provider's user is
+// linked to a local account the first time they sign in: to the account with the same email when
+// both the provider and the account have verified it, or else to a new account provisioned on
+// the spot. Later sign-ins follow the link, whatever email the provider then reports.
+type FederationService struct {
+	providers    map[string]domain.IdentityProvider
+	names        []string
+	identityRepo domain.FederatedIdentityRepository
+	stateRepo    domain.FederatedLoginStateRepository
+	userRepo     domain.UserRepository
+	authService  domain.AuthService
+	config       *config.Config
+	logger       *zap.Logger
+}
+
+// NewFederationService creates a new federation service
+func NewFederationService(
+	providers []domain.IdentityProvider,
+	identityRepo domain.FederatedIdentityRepository,
+	stateRepo domain.FederatedLoginStateRepository,
+	userRepo domain.UserRepository,
+	authService domain.AuthService,
+	config *config.Config,
+	logger *zap.Logger,
+) *FederationService {
+	byName := make(map[string]domain.IdentityProvider, len(providers))
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+		names = append(names, provider.Name())
+	}
+
+	return &FederationService{
+		providers:    byName,
+		names:        names,
+		identityRepo: identityRepo,
+		stateRepo:    stateRepo,
+		userRepo:     userRepo,
+		authService:  authService,
+		config:       config,
+		logger:       logger,
+	}
+}
+
+// Providers lists the names of the configured providers
+func (s *FederationService) Providers() []string {
+	return s.names
+}
+
+// BeginLogin starts a sign-in at the provider. The state, nonce and PKCE code verifier are kept
+// for FederatedStateTTL; only the state and the code challenge are sent to the provider.
+func (s *FederationService) BeginLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", domain.ErrIdentityProviderNotFound
+	}
+
+	nonce, err := generateLinkToken()
+	if err != nil {
+		s.logger.Error("Failed to generate nonce", zap.Error(err))
+		return "", "", domain.ErrInternal
+	}
+	verifier, err := generateLinkToken()
+	if err != nil {
+		s.logger.Error("Failed to generate code verifier", zap.Error(err))
+		return "", "", domain.ErrInternal
+	}
+
+	state := &domain.FederatedLoginState{
+		ID:           ulid.Make(),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(s.config.FederatedStateTTL),
+	}
+
+	redirectURL, err := provider.AuthorizationURL(ctx, state.ID.String(), nonce, pkceChallenge(verifier))
+	if err != nil {
+		s.logger.Error("Failed to build authorization URL",
+			zap.String("provider", providerName),
+			zap.Error(err))
+		return "", "", domain.ErrFederatedLoginFailed
+	}
+
+	if err := s.stateRepo.Create(ctx, state); err != nil {
+		return "", "", err
+	}
+
+	return state.ID.String(), redirectURL, nil
+}
+
+// FinishLogin redeems the authorization code the provider sent the user back with. The state is
+// single-use and must have been issued for the same provider.
+func (s *FederationService) FinishLogin(ctx context.Context, providerName, stateID, code string) (interface{}, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, domain.ErrIdentityProviderNotFound
+	}
+
+	id, err := ulid.Parse(stateID)
+	if err != nil {
+		return nil, domain.ErrFederatedLoginFailed
+	}
+	state, err := s.stateRepo.Consume(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if state.Provider != providerName || state.IsExpired() {
+		return nil, domain.ErrFederatedLoginFailed
+	}
+
+	claims, err := provider.Exchange(ctx, code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		s.logger.Warn("Identity provider sign-in rejected",
+			zap.String("provider", providerName),
+			zap.Error(err))
+		return nil, domain.ErrFederatedLoginFailed
+	}
+
+	user, err := s.linkedUser(ctx, providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.CompleteLogin(ctx, user, domain.NewAuthentication(domain.AMRFederated))
+}
+
+// ListIdentities lists the upstream identities linked to a user
+func (s *FederationService) ListIdentities(ctx context.Context, userID string) ([]*domain.FederatedIdentity, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+	return s.identityRepo.ListByUser(ctx, id)
+}
+
+// linkedUser returns the user linked to the provider's user, linking or provisioning one on
+// their first sign-in
+func (s *FederationService) linkedUser(ctx context.Context, providerName string, claims *domain.UpstreamClaims) (*domain.User, error) {
+	now := time.Now()
+
+	identity, err := s.identityRepo.FindByProviderSubject(ctx, providerName, claims.Subject)
+	if err == nil {
+		user, err := s.userRepo.FindByID(ctx, identity.UserID)
+		if err != nil {
+			s.logger.Error("Failed to find linked user",
+				zap.String("identity_id", identity.ID.String()),
+				zap.Error(err))
+			return nil, domain.ErrFederatedLoginFailed
+		}
+		if err := s.identityRepo.Touch(ctx, identity.ID, claims.Email, now); err != nil {
+			s.logger.Error("Failed to record federated sign-in",
+				zap.String("identity_id", identity.ID.String()),
+				zap.Error(err))
+		}
+		return user, nil
+	}
+	if err != domain.ErrFederatedIdentityNotFound {
+		return nil, err
+	}
+
+	// Accounts are only matched by an email the provider vouches for
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, domain.ErrUpstreamEmailNotVerified
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, claims.Email)
+	switch err {
+	case nil:
+		// An unverified account may have been registered by someone else ahead of its owner
+		if !user.EmailVerified {
+			return nil, domain.ErrEmailNotVerified
+		}
+	case domain.ErrUserNotFound:
+		if user, err = s.provisionUser(ctx, claims); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	identity = domain.NewFederatedIdentity(user.ID, providerName, claims.Subject, claims.Email)
+	identity.LastLoginAt = &now
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Federated identity linked",
+		zap.String("user_id", user.ID.String()),
+		zap.String("provider", providerName))
+	return user, nil
+}
+
+// provisionUser creates the account of a provider's user signing in for the first time. The
+// account has no password, so it can only be signed in to through the provider until one is set.
+func (s *FederationService) provisionUser(ctx context.Context, claims *domain.UpstreamClaims) (*domain.User, error) {
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	user, err := domain.NewUser(name, claims.Email, "", "")
+	if err != nil {
+		return nil, err
+	}
+	user.EmailVerified = true
+
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User provisioned from identity provider", zap.String("user_id", user.ID.String()))
+	return user, nil
+}
+
+// pkceChallenge derives the S256 code challenge of a PKCE code verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,280 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockIdentityProvider struct {
+	mock.Mock
+}
+
+func (m *mockIdentityProvider) Name() string {
+	return "corp"
+}
+
+func (m *mockIdentityProvider) AuthorizationURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	args := m.Called(ctx, state, nonce, codeChallenge)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockIdentityProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.UpstreamClaims, error) {
+	args := m.Called(ctx, code, codeVerifier, nonce)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UpstreamClaims), args.Error(1)
+}
+
+type mockFederatedIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *mockFederatedIdentityRepository) Create(ctx context.Context, identity *domain.FederatedIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+func (m *mockFederatedIdentityRepository) FindByProviderSubject(ctx context.Context, provider, subject string) (*domain.FederatedIdentity, error) {
+	args := m.Called(ctx, provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.FederatedIdentity), args.Error(1)
+}
+
+func (m *mockFederatedIdentityRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.FederatedIdentity, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.FederatedIdentity), args.Error(1)
+}
+
+func (m *mockFederatedIdentityRepository) Touch(ctx context.Context, id ulid.ULID, email string, at time.Time) error {
+	args := m.Called(ctx, id, email, at)
+	return args.Error(0)
+}
+
+type mockFederatedLoginStateRepository struct {
+	mock.Mock
+}
+
+func (m *mockFederatedLoginStateRepository) Create(ctx context.Context, state *domain.FederatedLoginState) error {
+	args := m.Called(ctx, state)
+	return args.Error(0)
+}
+
+func (m *mockFederatedLoginStateRepository) Consume(ctx context.Context, id ulid.ULID) (*domain.FederatedLoginState, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.FederatedLoginState), args.Error(1)
+}
+
+type federationTestFixture struct {
+	provider     *mockIdentityProvider
+	identityRepo *mockFederatedIdentityRepository
+	stateRepo    *mockFederatedLoginStateRepository
+	userRepo     *MockUserRepository
+	jwtService   *mockJWTService
+	service      *FederationService
+}
+
+// newFederationTestFixture signs users in through a real auth service, for users without MFA
+// factors, so a finished sign-in returns a token pair
+func newFederationTestFixture() *federationTestFixture {
+	f := &federationTestFixture{
+		provider:     new(mockIdentityProvider),
+		identityRepo: new(mockFederatedIdentityRepository),
+		stateRepo:    new(mockFederatedLoginStateRepository),
+		userRepo:     new(MockUserRepository),
+		jwtService:   new(mockJWTService),
+	}
+
+	mfaSvc := new(mockMFAService)
+	mfaSvc.On("ListFactors", mock.Anything, mock.Anything).Return([]*domain.MFAFactor{}, nil)
+	lockout := new(mockLockoutService)
+	lockout.On("Check", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	lockout.On("RecordSuccess", mock.Anything, mock.Anything).Return(nil)
+	f.jwtService.On("GenerateTokenPair", mock.Anything, mock.Anything).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+
+	cfg := &config.Config{FederatedStateTTL: 10 * time.Minute}
+	authService := NewAuthService(f.userRepo, nil, f.jwtService, nil, mfaSvc, newTestMFAPolicy(), nil, nil, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, cfg, zap.NewNop())
+	f.service = NewFederationService([]domain.IdentityProvider{f.provider}, f.identityRepo, f.stateRepo, f.userRepo, authService, cfg, zap.NewNop())
+	return f
+}
+
+// withState makes the callback carry a state issued for the provider
+func (f *federationTestFixture) withState() *domain.FederatedLoginState {
+	state := &domain.FederatedLoginState{
+		ID:           ulid.Make(),
+		Provider:     "corp",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+		ExpiresAt:    time.Now().Add(time.Minute),
+	}
+	f.stateRepo.On("Consume", mock.Anything, state.ID).Return(state, nil)
+	return state
+}
+
+func TestFederationService_BeginLogin(t *testing.T) {
+	t.Run("stores the state and sends its PKCE challenge", func(t *testing.T) {
+		f := newFederationTestFixture()
+		var stored *domain.FederatedLoginState
+		f.stateRepo.On("Create", mock.Anything, mock.MatchedBy(func(state *domain.FederatedLoginState) bool {
+			stored = state
+			return state.Provider == "corp"
+		})).Return(nil)
+		f.provider.On("AuthorizationURL", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return("https://idp.example.com/authorize?"+url.Values{"client_id": {"authm"}}.Encode(), nil)
+
+		state, redirectURL, err := f.service.BeginLogin(context.Background(), "corp")
+		require.NoError(t, err)
+		assert.Equal(t, "https://idp.example.com/authorize?client_id=authm", redirectURL)
+		assert.Equal(t, stored.ID.String(), state)
+		assert.WithinDuration(t, time.Now().Add(10*time.Minute), stored.ExpiresAt, time.Second)
+		f.provider.AssertCalled(t, "AuthorizationURL", mock.Anything, state, stored.Nonce, pkceChallenge(stored.CodeVerifier))
+		assert.NotEqual(t, stored.CodeVerifier, pkceChallenge(stored.CodeVerifier), "the verifier stays on the server")
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		f := newFederationTestFixture()
+
+		_, _, err := f.service.BeginLogin(context.Background(), "social")
+		assert.Equal(t, domain.ErrIdentityProviderNotFound, err)
+	})
+}
+
+func TestFederationService_FinishLogin(t *testing.T) {
+	claims := &domain.UpstreamClaims{Subject: "upstream-42", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"}
+
+	t.Run("linked identity", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", EmailVerified: true, Roles: []string{"user"}}
+		identity := domain.NewFederatedIdentity(user.ID, "corp", "upstream-42", "jane@old.example.com")
+		f.provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(claims, nil)
+		f.identityRepo.On("FindByProviderSubject", mock.Anything, "corp", "upstream-42").Return(identity, nil)
+		f.identityRepo.On("Touch", mock.Anything, identity.ID, "jane@example.com", mock.Anything).Return(nil)
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+
+		result, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		require.NoError(t, err)
+		assert.IsType(t, &domain.TokenPair{}, result)
+		assert.Equal(t, []string{domain.AMRFederated}, f.jwtService.params.Authentication.Methods)
+		f.identityRepo.AssertExpectations(t)
+		f.userRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("links the account with the verified email", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		user := &domain.User{ID: ulid.Make(), Email: "jane@example.com", EmailVerified: true}
+		f.provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(claims, nil)
+		f.identityRepo.On("FindByProviderSubject", mock.Anything, "corp", "upstream-42").Return(nil, domain.ErrFederatedIdentityNotFound)
+		f.userRepo.On("FindByEmail", mock.Anything, "jane@example.com").Return(user, nil)
+		f.identityRepo.On("Create", mock.Anything, mock.MatchedBy(func(identity *domain.FederatedIdentity) bool {
+			return identity.UserID == user.ID && identity.Provider == "corp" && identity.Subject == "upstream-42"
+		})).Return(nil)
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		require.NoError(t, err)
+		f.identityRepo.AssertExpectations(t)
+		f.userRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("provisions a new user", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		f.provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(claims, nil)
+		f.identityRepo.On("FindByProviderSubject", mock.Anything, "corp", "upstream-42").Return(nil, domain.ErrFederatedIdentityNotFound)
+		f.userRepo.On("FindByEmail", mock.Anything, "jane@example.com").Return(nil, domain.ErrUserNotFound)
+		var created *domain.User
+		f.userRepo.On("Create", mock.Anything, mock.MatchedBy(func(user *domain.User) bool {
+			created = user
+			return user.Email == "jane@example.com" && user.Name == "Jane Doe" && user.EmailVerified && user.Password == ""
+		})).Return(nil)
+		f.identityRepo.On("Create", mock.Anything, mock.MatchedBy(func(identity *domain.FederatedIdentity) bool {
+			return identity.UserID == created.ID
+		})).Return(nil)
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user"}, created.Roles)
+		f.userRepo.AssertExpectations(t)
+		f.identityRepo.AssertExpectations(t)
+	})
+
+	t.Run("unverified upstream email", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		unverified := *claims
+		unverified.EmailVerified = false
+		f.provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(&unverified, nil)
+		f.identityRepo.On("FindByProviderSubject", mock.Anything, "corp", "upstream-42").Return(nil, domain.ErrFederatedIdentityNotFound)
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		assert.Equal(t, domain.ErrUpstreamEmailNotVerified, err)
+		f.userRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unverified local account", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		f.provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(claims, nil)
+		f.identityRepo.On("FindByProviderSubject", mock.Anything, "corp", "upstream-42").Return(nil, domain.ErrFederatedIdentityNotFound)
+		f.userRepo.On("FindByEmail", mock.Anything, "jane@example.com").Return(&domain.User{ID: ulid.Make(), Email: "jane@example.com"}, nil)
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		assert.Equal(t, domain.ErrEmailNotVerified, err)
+		f.identityRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("rejected code", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		f.provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(nil, errors.New("invalid_grant"))
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		assert.Equal(t, domain.ErrFederatedLoginFailed, err)
+	})
+
+	t.Run("state of another provider", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		state.Provider = "social"
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		assert.Equal(t, domain.ErrFederatedLoginFailed, err)
+		f.provider.AssertNotCalled(t, "Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("expired state", func(t *testing.T) {
+		f := newFederationTestFixture()
+		state := f.withState()
+		state.ExpiresAt = time.Now().Add(-time.Second)
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", state.ID.String(), "code")
+		assert.Equal(t, domain.ErrFederatedLoginFailed, err)
+	})
+
+	t.Run("unknown state", func(t *testing.T) {
+		f := newFederationTestFixture()
+
+		_, err := f.service.FinishLogin(context.Background(), "corp", "not-a-state", "code")
+		assert.Equal(t, domain.ErrFederatedLoginFailed, err)
+	})
+}
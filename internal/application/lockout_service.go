@@ -0,0 +1,230 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// maxBackoffShift keeps the exponential back-off from overflowing time.Duration
+const maxBackoffShift = 30
+
+type LockoutService struct {
+	attemptRepo  domain.LoginAttemptRepository
+	userRepo     domain.UserRepository
+	emailService domain.EmailService
+	config       *config.Config
+	logger       *zap.Logger
+}
+
+func NewLockoutService(attemptRepo domain.LoginAttemptRepository, userRepo domain.UserRepository, emailService domain.EmailService, config *config.Config, logger *zap.Logger) *LockoutService {
+	return &LockoutService{
+		attemptRepo:  attemptRepo,
+		userRepo:     userRepo,
+		emailService: emailService,
+		config:       config,
+		logger:       logger,
+	}
+}
+
+// Check returns ErrAccountLocked while the user or client IP is locked, and ErrTooManyAttempts
+// while the user is still inside the back-off delay of their last failure
+func (s *LockoutService) Check(ctx context.Context, userID, ip string) error {
+	if ip != "" {
+		attempts, err := s.attemptRepo.Get(ctx, domain.IPAttemptsKey(ip))
+		if err != nil {
+			return err
+		}
+		if attempts.IsLocked(time.Now()) {
+			s.logger.Warn("Authentication attempt from locked IP",
+				zap.String("ip", ip))
+			return domain.ErrAccountLocked
+		}
+	}
+
+	if userID == "" {
+		return nil
+	}
+	return s.checkAccount(ctx, domain.UserAttemptsKey(userID))
+}
+
+// CheckUnknown answers attempts at a login that matches no account the way Check answers attempts
+// at a user, so that lockouts and back-off do not tell which accounts exist
+func (s *LockoutService) CheckUnknown(ctx context.Context, login string) error {
+	return s.checkAccount(ctx, domain.UnknownAccountAttemptsKey(login))
+}
+
+// RecordFailure counts a failed attempt against the client IP and the user, locking either one
+// once it reaches its threshold. The user is told by email when their account gets locked.
+func (s *LockoutService) RecordFailure(ctx context.Context, user *domain.User, ip string) error {
+	if err := s.recordIPFailure(ctx, ip); err != nil {
+		return err
+	}
+
+	if user == nil {
+		return nil
+	}
+
+	lockedUntil, err := s.recordAccountFailure(ctx, domain.UserAttemptsKey(user.ID.String()))
+	if err != nil || lockedUntil == nil {
+		return err
+	}
+
+	// The lock is already in place, so a failed notification is only logged
+	if err := s.emailService.SendAccountLockedEmail(ctx, user.Email, *lockedUntil); err != nil {
+		s.logger.Error("Failed to send account locked email",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+	}
+
+	return nil
+}
+
+// RecordUnknownFailure counts a failed attempt at a login that matches no account against the
+// client IP and the login, which is locked at the same threshold as an account
+func (s *LockoutService) RecordUnknownFailure(ctx context.Context, login, ip string) error {
+	if err := s.recordIPFailure(ctx, ip); err != nil {
+		return err
+	}
+
+	_, err := s.recordAccountFailure(ctx, domain.UnknownAccountAttemptsKey(login))
+	return err
+}
+
+// RecordSuccess clears the failed attempts of a user. The client IP keeps its count so that
+// one valid account cannot be used to reset the counter of an IP guessing at others.
+func (s *LockoutService) RecordSuccess(ctx context.Context, userID string) error {
+	return s.attemptRepo.Reset(ctx, domain.UserAttemptsKey(userID))
+}
+
+// Unlock clears the lockout of a user
+func (s *LockoutService) Unlock(ctx context.Context, userID ulid.ULID) error {
+	if _, err := s.userRepo.FindByID(ctx, userID); err != nil {
+		s.logger.Error("Failed to find user to unlock",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return domain.ErrUserNotFound
+	}
+
+	if err := s.attemptRepo.Reset(ctx, domain.UserAttemptsKey(userID.String())); err != nil {
+		return err
+	}
+
+	s.logger.Info("Account unlocked",
+		zap.String("user_id", userID.String()))
+	return nil
+}
+
+// Throttle counts a request against key within a sliding window that restarts once window has passed
+// since the last request, so a caller who keeps retrying stays throttled
+func (s *LockoutService) Throttle(ctx context.Context, key string, limit int, window time.Duration) error {
+	now := time.Now()
+	attempts, err := s.attemptRepo.RecordFailure(ctx, key, now, now.Add(-window))
+	if err != nil {
+		return err
+	}
+	if attempts.Failures > limit {
+		s.logger.Warn("Request throttled",
+			zap.String("key", key),
+			zap.Int("requests", attempts.Failures))
+		return domain.ErrTooManyAttempts
+	}
+	return nil
+}
+
+// checkAccount returns ErrAccountLocked while the account key is locked, and ErrTooManyAttempts
+// while it is still inside the back-off delay of its last failure
+func (s *LockoutService) checkAccount(ctx context.Context, key string) error {
+	now := time.Now()
+
+	attempts, err := s.attemptRepo.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if attempts.IsLocked(now) {
+		s.logger.Warn("Authentication attempt on locked account",
+			zap.String("key", key))
+		return domain.ErrAccountLocked
+	}
+	if attempts.Failures == 0 || s.isStale(attempts, now) {
+		return nil
+	}
+	if now.Before(attempts.LastFailureAt.Add(s.backoff(attempts.Failures))) {
+		return domain.ErrTooManyAttempts
+	}
+
+	return nil
+}
+
+// recordIPFailure counts a failed attempt against the client IP, locking it once it reaches its threshold
+func (s *LockoutService) recordIPFailure(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+
+	now := time.Now()
+	key := domain.IPAttemptsKey(ip)
+	attempts, err := s.attemptRepo.RecordFailure(ctx, key, now, now.Add(-s.config.LockoutDuration))
+	if err != nil {
+		return err
+	}
+	if attempts.Failures >= s.config.LockoutIPThreshold && !attempts.IsLocked(now) {
+		if err := s.attemptRepo.Lock(ctx, key, now.Add(s.config.LockoutDuration)); err != nil {
+			return err
+		}
+		s.logger.Warn("Client IP locked after too many failed attempts",
+			zap.String("ip", ip),
+			zap.Int("failures", attempts.Failures))
+	}
+
+	return nil
+}
+
+// recordAccountFailure counts a failed attempt against the account key and locks it once it reaches
+// the threshold, returning the end of the lock it just put in place
+func (s *LockoutService) recordAccountFailure(ctx context.Context, key string) (*time.Time, error) {
+	now := time.Now()
+	attempts, err := s.attemptRepo.RecordFailure(ctx, key, now, now.Add(-s.config.LockoutDuration))
+	if err != nil {
+		return nil, err
+	}
+	if attempts.Failures < s.config.LockoutThreshold || attempts.IsLocked(now) {
+		return nil, nil
+	}
+
+	lockedUntil := now.Add(s.config.LockoutDuration)
+	if err := s.attemptRepo.Lock(ctx, key, lockedUntil); err != nil {
+		return nil, err
+	}
+	s.logger.Warn("Account locked after too many failed attempts",
+		zap.String("key", key),
+		zap.Int("failures", attempts.Failures))
+
+	return &lockedUntil, nil
+}
+
+// isStale reports whether the failures are old enough to be forgotten on the next attempt
+func (s *LockoutService) isStale(attempts *domain.LoginAttempts, now time.Time) bool {
+	return attempts.LastFailureAt.Before(now.Add(-s.config.LockoutDuration))
+}
+
+// backoff returns the delay before the next attempt is allowed, doubling with every failure
+func (s *LockoutService) backoff(failures int) time.Duration {
+	if failures <= 0 || s.config.LockoutBackoffBase <= 0 {
+		return 0
+	}
+
+	shift := failures - 1
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	delay := s.config.LockoutBackoffBase << shift
+	if delay <= 0 || delay > s.config.LockoutDuration {
+		return s.config.LockoutDuration
+	}
+	return delay
+}
@@ -0,0 +1,242 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type mockLoginAttemptRepository struct {
+	mock.Mock
+}
+
+func (m *mockLoginAttemptRepository) Get(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LoginAttempts), args.Error(1)
+}
+
+func (m *mockLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*domain.LoginAttempts, error) {
+	args := m.Called(ctx, key, at, resetBefore)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LoginAttempts), args.Error(1)
+}
+
+func (m *mockLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	args := m.Called(ctx, key, until)
+	return args.Error(0)
+}
+
+func (m *mockLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func newLockoutTestConfig() *config.Config {
+	return &config.Config{
+		LockoutThreshold:   5,
+		LockoutDuration:    15 * time.Minute,
+		LockoutIPThreshold: 20,
+		LockoutBackoffBase: time.Second,
+	}
+}
+
+func TestLockoutService_Check(t *testing.T) {
+	userID := ulid.Make().String()
+	lockedUntil := time.Now().Add(10 * time.Minute)
+
+	tests := []struct {
+		name          string
+		ip            string
+		userAttempts  *domain.LoginAttempts
+		ipAttempts    *domain.LoginAttempts
+		expectedError error
+	}{
+		{
+			name:         "no failures",
+			userAttempts: &domain.LoginAttempts{},
+		},
+		{
+			name:          "account locked",
+			userAttempts:  &domain.LoginAttempts{Failures: 5, LastFailureAt: time.Now(), LockedUntil: &lockedUntil},
+			expectedError: domain.ErrAccountLocked,
+		},
+		{
+			name:          "inside back-off delay",
+			userAttempts:  &domain.LoginAttempts{Failures: 3, LastFailureAt: time.Now().Add(-2 * time.Second)},
+			expectedError: domain.ErrTooManyAttempts,
+		},
+		{
+			name:         "back-off delay elapsed",
+			userAttempts: &domain.LoginAttempts{Failures: 3, LastFailureAt: time.Now().Add(-5 * time.Second)},
+		},
+		{
+			name:         "stale failures are ignored",
+			userAttempts: &domain.LoginAttempts{Failures: 30, LastFailureAt: time.Now().Add(-time.Hour)},
+		},
+		{
+			name:          "client IP locked",
+			ip:            "203.0.113.7",
+			ipAttempts:    &domain.LoginAttempts{Failures: 20, LastFailureAt: time.Now(), LockedUntil: &lockedUntil},
+			expectedError: domain.ErrAccountLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockLoginAttemptRepository)
+			if tt.ipAttempts != nil {
+				repo.On("Get", mock.Anything, domain.IPAttemptsKey(tt.ip)).Return(tt.ipAttempts, nil)
+			}
+			if tt.userAttempts != nil {
+				repo.On("Get", mock.Anything, domain.UserAttemptsKey(userID)).Return(tt.userAttempts, nil)
+			}
+
+			service := NewLockoutService(repo, nil, nil, newLockoutTestConfig(), zap.NewNop())
+			err := service.Check(context.Background(), userID, tt.ip)
+
+			assert.Equal(t, tt.expectedError, err)
+			repo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLockoutService_RecordFailure(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+	userKey := domain.UserAttemptsKey(user.ID.String())
+	ipKey := domain.IPAttemptsKey("203.0.113.7")
+
+	tests := []struct {
+		name       string
+		setupMocks func(*mockLoginAttemptRepository, *mockEmailService)
+	}{
+		{
+			name: "below thresholds",
+			setupMocks: func(r *mockLoginAttemptRepository, e *mockEmailService) {
+				r.On("RecordFailure", mock.Anything, ipKey, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: ipKey, Failures: 3}, nil)
+				r.On("RecordFailure", mock.Anything, userKey, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: userKey, Failures: 3}, nil)
+			},
+		},
+		{
+			name: "user threshold locks account and notifies user",
+			setupMocks: func(r *mockLoginAttemptRepository, e *mockEmailService) {
+				r.On("RecordFailure", mock.Anything, ipKey, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: ipKey, Failures: 5}, nil)
+				r.On("RecordFailure", mock.Anything, userKey, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: userKey, Failures: 5}, nil)
+				r.On("Lock", mock.Anything, userKey, mock.Anything).Return(nil)
+				e.On("SendAccountLockedEmail", mock.Anything, "test@example.com", mock.Anything).Return(domain.ErrEmailSendFailed)
+			},
+		},
+		{
+			name: "IP threshold locks client IP",
+			setupMocks: func(r *mockLoginAttemptRepository, e *mockEmailService) {
+				r.On("RecordFailure", mock.Anything, ipKey, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: ipKey, Failures: 20}, nil)
+				r.On("Lock", mock.Anything, ipKey, mock.Anything).Return(nil)
+				r.On("RecordFailure", mock.Anything, userKey, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: userKey, Failures: 1}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mockLoginAttemptRepository)
+			emailSvc := new(mockEmailService)
+			tt.setupMocks(repo, emailSvc)
+
+			service := NewLockoutService(repo, nil, emailSvc, newLockoutTestConfig(), zap.NewNop())
+			err := service.RecordFailure(context.Background(), user, "203.0.113.7")
+
+			assert.NoError(t, err)
+			repo.AssertExpectations(t)
+			emailSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLockoutService_UnknownLogin(t *testing.T) {
+	key := domain.UnknownAccountAttemptsKey("Nobody@example.com")
+	ipKey := domain.IPAttemptsKey("203.0.113.7")
+	lockedUntil := time.Now().Add(10 * time.Minute)
+
+	t.Run("locked like an account", func(t *testing.T) {
+		repo := new(mockLoginAttemptRepository)
+		repo.On("Get", mock.Anything, key).Return(&domain.LoginAttempts{Failures: 5, LastFailureAt: time.Now(), LockedUntil: &lockedUntil}, nil)
+
+		service := NewLockoutService(repo, nil, nil, newLockoutTestConfig(), zap.NewNop())
+		err := service.CheckUnknown(context.Background(), "nobody@example.com")
+
+		assert.Equal(t, domain.ErrAccountLocked, err)
+	})
+
+	t.Run("threshold locks the login without an email", func(t *testing.T) {
+		repo := new(mockLoginAttemptRepository)
+		repo.On("RecordFailure", mock.Anything, ipKey, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: ipKey, Failures: 5}, nil)
+		repo.On("RecordFailure", mock.Anything, key, mock.Anything, mock.Anything).Return(&domain.LoginAttempts{Key: key, Failures: 5}, nil)
+		repo.On("Lock", mock.Anything, key, mock.Anything).Return(nil)
+		emailSvc := new(mockEmailService)
+
+		service := NewLockoutService(repo, nil, emailSvc, newLockoutTestConfig(), zap.NewNop())
+		err := service.RecordUnknownFailure(context.Background(), "nobody@example.com", "203.0.113.7")
+
+		assert.NoError(t, err)
+		repo.AssertExpectations(t)
+		emailSvc.AssertNotCalled(t, "SendAccountLockedEmail", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestLockoutService_Unlock(t *testing.T) {
+	t.Run("unlocks existing user", func(t *testing.T) {
+		userID := ulid.Make()
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, userID).Return(&domain.User{ID: userID}, nil)
+		repo := new(mockLoginAttemptRepository)
+		repo.On("Reset", mock.Anything, domain.UserAttemptsKey(userID.String())).Return(nil)
+
+		service := NewLockoutService(repo, userRepo, nil, newLockoutTestConfig(), zap.NewNop())
+
+		assert.NoError(t, service.Unlock(context.Background(), userID))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		userID := ulid.Make()
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, userID).Return(nil, domain.ErrUserNotFound)
+		repo := new(mockLoginAttemptRepository)
+
+		service := NewLockoutService(repo, userRepo, nil, newLockoutTestConfig(), zap.NewNop())
+
+		assert.Equal(t, domain.ErrUserNotFound, service.Unlock(context.Background(), userID))
+		repo.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything)
+	})
+}
+
+func TestLockoutService_Throttle(t *testing.T) {
+	key := domain.PasswordlessAttemptsKey("Test@Example.com")
+	assert.Equal(t, "passwordless:test@example.com", key)
+
+	window := time.Hour
+	inWindow := mock.MatchedBy(func(resetBefore time.Time) bool {
+		return time.Until(resetBefore) > -window-time.Second && time.Until(resetBefore) <= -window+time.Second
+	})
+
+	repo := new(mockLoginAttemptRepository)
+	repo.On("RecordFailure", mock.Anything, key, mock.Anything, inWindow).Return(&domain.LoginAttempts{Failures: 3}, nil).Once()
+	repo.On("RecordFailure", mock.Anything, key, mock.Anything, inWindow).Return(&domain.LoginAttempts{Failures: 4}, nil).Once()
+
+	service := NewLockoutService(repo, nil, nil, newLockoutTestConfig(), zap.NewNop())
+
+	assert.NoError(t, service.Throttle(context.Background(), key, 3, window))
+	assert.Equal(t, domain.ErrTooManyAttempts, service.Throttle(context.Background(), key, 3, window))
+	repo.AssertExpectations(t)
+}
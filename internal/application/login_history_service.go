@@ -0,0 +1,240 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// loginHistoryLimit is the number of logins listed to a user
+const loginHistoryLimit = 50
+
+// LoginHistoryService records the logins of users and emails them when one comes from a device
+// they never signed in from
+type LoginHistoryService struct {
+	historyRepo  domain.LoginHistoryRepository
+	userRepo     domain.UserRepository
+	deviceRepo   domain.TrustedDeviceRepository
+	emailService domain.EmailService
+	config       *config.Config
+	logger       *zap.Logger
+}
+
+// NewLoginHistoryService creates a new login history service
+func NewLoginHistoryService(
+	historyRepo domain.LoginHistoryRepository,
+	userRepo domain.UserRepository,
+	deviceRepo domain.TrustedDeviceRepository,
+	emailService domain.EmailService,
+	config *config.Config,
+	logger *zap.Logger,
+) *LoginHistoryService {
+	return &LoginHistoryService{
+		historyRepo:  historyRepo,
+		userRepo:     userRepo,
+		deviceRepo:   deviceRepo,
+		emailService: emailService,
+		config:       config,
+		logger:       logger,
+	}
+}
+
+// IsNewDevice reports whether the login in the context comes from a device the user never signed
+// in from. A failed lookup counts as a known device, so it never blocks a login.
+func (s *LoginHistoryService) IsNewDevice(ctx context.Context, user *domain.User) bool {
+	event := newLoginEvent(ctx, user, nil)
+	isNew, err := s.historyRepo.IsNewDevice(ctx, user.ID, event.IPPrefix, event.Device)
+	if err != nil {
+		return false
+	}
+	return isNew
+}
+
+// Record adds the login in the context to the user's history. A login from a new device comes
+// with a single-use token revoking it, sent to the user by email.
+func (s *LoginHistoryService) Record(ctx context.Context, user *domain.User, auth *domain.Authentication) {
+	event := newLoginEvent(ctx, user, auth)
+	isNew, err := s.historyRepo.IsNewDevice(ctx, user.ID, event.IPPrefix, event.Device)
+	event.NewDevice = err == nil && isNew
+
+	var revokeLink string
+	if event.NewDevice {
+		secret, err := generateLinkToken()
+		if err != nil {
+			s.logger.Error("Failed to generate sign-in revocation token", zap.Error(err))
+		} else {
+			event.RevokeTokenHash = hashOneTimeSecret(user.ID, secret)
+			if s.config.LoginAlertLinkURL != "" {
+				revokeLink = linkWithQuery(s.config.LoginAlertLinkURL, url.Values{"token": {event.ID.String() + "." + secret}})
+			}
+		}
+	}
+
+	if err := s.historyRepo.Create(ctx, event); err != nil {
+		return
+	}
+
+	if !event.NewDevice {
+		return
+	}
+
+	s.logger.Info("Login from a new device",
+		zap.String("user_id", user.ID.String()),
+		zap.String("login_id", event.ID.String()),
+		zap.String("device", event.Device),
+		zap.String("ip_prefix", event.IPPrefix))
+
+	if err := s.emailService.SendNewSignInEmail(ctx, user.Email, event, revokeLink); err != nil {
+		s.logger.Error("Failed to send new sign-in email",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+	}
+}
+
+// List lists the latest logins of a user, newest first
+func (s *LoginHistoryService) List(ctx context.Context, userID string) ([]*domain.LoginEvent, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+	return s.historyRepo.ListByUser(ctx, id, loginHistoryLimit)
+}
+
+// RevokeSignIn redeems the token of a new sign-in email within LoginAlertLinkTTL. Every refresh
+// token and trusted device of the user is revoked, as the login cannot be told apart from the
+// user's own sessions.
+func (s *LoginHistoryService) RevokeSignIn(ctx context.Context, token string) error {
+	loginID, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return domain.ErrInvalidSignInRevocation
+	}
+	id, err := ulid.Parse(loginID)
+	if err != nil {
+		return domain.ErrInvalidSignInRevocation
+	}
+
+	event, err := s.historyRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if event.RevokeTokenHash == "" || subtle.ConstantTimeCompare([]byte(event.RevokeTokenHash), []byte(hashOneTimeSecret(event.UserID, secret))) != 1 {
+		return domain.ErrInvalidSignInRevocation
+	}
+
+	now := time.Now()
+	if now.After(event.CreatedAt.Add(s.config.LoginAlertLinkTTL)) {
+		return domain.ErrInvalidSignInRevocation
+	}
+
+	// Revoking the login first makes the link single-use even when two requests race
+	if err := s.historyRepo.Revoke(ctx, event.ID, now); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.RevokeSessions(ctx, event.UserID, now); err != nil {
+		s.logger.Error("Failed to revoke sessions", zap.String("user_id", event.UserID.String()), zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	if err := s.deviceRepo.DeleteByUser(ctx, event.UserID); err != nil {
+		s.logger.Error("Failed to revoke trusted devices", zap.String("user_id", event.UserID.String()), zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	s.logger.Warn("Sign-in revoked by the user",
+		zap.String("user_id", event.UserID.String()),
+		zap.String("login_id", event.ID.String()))
+	return nil
+}
+
+// newLoginEvent describes the login in the context, fingerprinting the device it comes from
+func newLoginEvent(ctx context.Context, user *domain.User, auth *domain.Authentication) *domain.LoginEvent {
+	event := &domain.LoginEvent{
+		ID:        ulid.Make(),
+		UserID:    user.ID,
+		CreatedAt: time.Now(),
+	}
+	if auth != nil {
+		event.Methods = auth.Methods
+	}
+	if ip, ok := domain.GetClientIP(ctx); ok {
+		event.ClientIP = ip
+		event.IPPrefix = ipPrefix(ip)
+	}
+	userAgent, _ := domain.GetUserAgent(ctx)
+	if runes := []rune(userAgent); len(runes) > 512 {
+		userAgent = string(runes[:512])
+	}
+	event.UserAgent = userAgent
+	event.Device = userAgentFamily(userAgent)
+	return event
+}
+
+// ipPrefix returns the network of an IP address: its /24 for IPv4 and its /48 for IPv6, which
+// usually stays the same while a home or office connection changes address
+func ipPrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// userAgentFamily names the browser and operating system of a user agent, leaving out versions
+func userAgentFamily(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case !strings.Contains(userAgent, "Mozilla/"):
+		// Command-line tools and libraries name themselves first, as in "curl/8.5.0"
+		browser, _, _ = strings.Cut(userAgent, "/")
+		browser, _, _ = strings.Cut(browser, " ")
+	}
+
+	system := "unknown system"
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		system = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		system = "iOS"
+	case strings.Contains(userAgent, "CrOS"):
+		system = "ChromeOS"
+	case strings.Contains(userAgent, "Windows"):
+		system = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		system = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		system = "Linux"
+	}
+
+	family := browser + " on " + system
+	if runes := []rune(family); len(runes) > 64 {
+		family = string(runes[:64])
+	}
+	return family
+}
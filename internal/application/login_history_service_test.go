@@ -0,0 +1,242 @@
+package application
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockLoginHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *mockLoginHistoryRepository) Create(ctx context.Context, event *domain.LoginEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *mockLoginHistoryRepository) FindByID(ctx context.Context, id ulid.ULID) (*domain.LoginEvent, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.LoginEvent), args.Error(1)
+}
+
+func (m *mockLoginHistoryRepository) ListByUser(ctx context.Context, userID ulid.ULID, limit int) ([]*domain.LoginEvent, error) {
+	args := m.Called(ctx, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.LoginEvent), args.Error(1)
+}
+
+func (m *mockLoginHistoryRepository) IsNewDevice(ctx context.Context, userID ulid.ULID, ipPrefix, device string) (bool, error) {
+	args := m.Called(ctx, userID, ipPrefix, device)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockLoginHistoryRepository) Revoke(ctx context.Context, id ulid.ULID, revokedAt time.Time) error {
+	args := m.Called(ctx, id, revokedAt)
+	return args.Error(0)
+}
+
+func newTestLoginAlertConfig() *config.Config {
+	return &config.Config{
+		LoginAlertLinkURL: "https://app.example.com/revoke-sign-in",
+		LoginAlertLinkTTL: 7 * 24 * time.Hour,
+	}
+}
+
+const testFirefoxUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+func newTestLoginContext() context.Context {
+	ctx := domain.WithClientIP(context.Background(), "203.0.113.7")
+	return domain.WithUserAgent(ctx, testFirefoxUserAgent)
+}
+
+func TestLoginHistoryService_Record(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+	auth := &domain.Authentication{Methods: []string{"pwd"}}
+
+	t.Run("new device", func(t *testing.T) {
+		historyRepo := new(mockLoginHistoryRepository)
+		emailService := new(mockEmailService)
+		service := NewLoginHistoryService(historyRepo, new(mockUserRepository), new(mockTrustedDeviceRepository), emailService, newTestLoginAlertConfig(), zap.NewNop())
+
+		historyRepo.On("IsNewDevice", mock.Anything, user.ID, "203.0.113.0/24", "Firefox on Linux").Return(true, nil)
+		historyRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		emailService.On("SendNewSignInEmail", mock.Anything, user.Email, mock.Anything, mock.Anything).Return(nil)
+
+		service.Record(newTestLoginContext(), user, auth)
+
+		event := historyRepo.Calls[1].Arguments.Get(1).(*domain.LoginEvent)
+		assert.True(t, event.NewDevice)
+		assert.Equal(t, "203.0.113.7", event.ClientIP)
+		assert.Equal(t, testFirefoxUserAgent, event.UserAgent)
+		assert.Equal(t, []string{"pwd"}, event.Methods)
+		assert.NotEmpty(t, event.RevokeTokenHash)
+
+		link := emailService.Calls[0].Arguments.String(3)
+		parsed, err := url.Parse(link)
+		require.NoError(t, err)
+		token := parsed.Query().Get("token")
+		assert.True(t, strings.HasPrefix(link, "https://app.example.com/revoke-sign-in?token="))
+		assert.True(t, strings.HasPrefix(token, event.ID.String()+"."))
+		assert.Equal(t, hashOneTimeSecret(user.ID, strings.TrimPrefix(token, event.ID.String()+".")), event.RevokeTokenHash)
+	})
+
+	t.Run("known device", func(t *testing.T) {
+		historyRepo := new(mockLoginHistoryRepository)
+		emailService := new(mockEmailService)
+		service := NewLoginHistoryService(historyRepo, new(mockUserRepository), new(mockTrustedDeviceRepository), emailService, newTestLoginAlertConfig(), zap.NewNop())
+
+		historyRepo.On("IsNewDevice", mock.Anything, user.ID, mock.Anything, mock.Anything).Return(false, nil)
+		historyRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		service.Record(newTestLoginContext(), user, auth)
+
+		event := historyRepo.Calls[1].Arguments.Get(1).(*domain.LoginEvent)
+		assert.False(t, event.NewDevice)
+		assert.Empty(t, event.RevokeTokenHash)
+		emailService.AssertNotCalled(t, "SendNewSignInEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("new device without a revocation page", func(t *testing.T) {
+		historyRepo := new(mockLoginHistoryRepository)
+		emailService := new(mockEmailService)
+		cfg := newTestLoginAlertConfig()
+		cfg.LoginAlertLinkURL = ""
+		service := NewLoginHistoryService(historyRepo, new(mockUserRepository), new(mockTrustedDeviceRepository), emailService, cfg, zap.NewNop())
+
+		historyRepo.On("IsNewDevice", mock.Anything, user.ID, mock.Anything, mock.Anything).Return(true, nil)
+		historyRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		emailService.On("SendNewSignInEmail", mock.Anything, user.Email, mock.Anything, "").Return(nil)
+
+		service.Record(newTestLoginContext(), user, auth)
+
+		emailService.AssertExpectations(t)
+	})
+
+	t.Run("failed write sends no email", func(t *testing.T) {
+		historyRepo := new(mockLoginHistoryRepository)
+		emailService := new(mockEmailService)
+		service := NewLoginHistoryService(historyRepo, new(mockUserRepository), new(mockTrustedDeviceRepository), emailService, newTestLoginAlertConfig(), zap.NewNop())
+
+		historyRepo.On("IsNewDevice", mock.Anything, user.ID, mock.Anything, mock.Anything).Return(true, nil)
+		historyRepo.On("Create", mock.Anything, mock.Anything).Return(domain.ErrDatabaseQuery)
+
+		service.Record(newTestLoginContext(), user, auth)
+
+		emailService.AssertNotCalled(t, "SendNewSignInEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestLoginHistoryService_RevokeSignIn(t *testing.T) {
+	userID := ulid.Make()
+	secret := "secret"
+
+	newEvent := func(createdAt time.Time) *domain.LoginEvent {
+		return &domain.LoginEvent{
+			ID:              ulid.Make(),
+			UserID:          userID,
+			NewDevice:       true,
+			RevokeTokenHash: hashOneTimeSecret(userID, secret),
+			CreatedAt:       createdAt,
+		}
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		historyRepo := new(mockLoginHistoryRepository)
+		userRepo := new(mockUserRepository)
+		deviceRepo := new(mockTrustedDeviceRepository)
+		service := NewLoginHistoryService(historyRepo, userRepo, deviceRepo, new(mockEmailService), newTestLoginAlertConfig(), zap.NewNop())
+
+		event := newEvent(time.Now().Add(-time.Hour))
+		historyRepo.On("FindByID", mock.Anything, event.ID).Return(event, nil)
+		historyRepo.On("Revoke", mock.Anything, event.ID, mock.Anything).Return(nil)
+		userRepo.On("RevokeSessions", mock.Anything, userID, mock.Anything).Return(nil)
+		deviceRepo.On("DeleteByUser", mock.Anything, userID).Return(nil)
+
+		err := service.RevokeSignIn(context.Background(), event.ID.String()+"."+secret)
+		require.NoError(t, err)
+
+		historyRepo.AssertExpectations(t)
+		userRepo.AssertExpectations(t)
+		deviceRepo.AssertExpectations(t)
+	})
+
+	tests := []struct {
+		name  string
+		event *domain.LoginEvent
+		token func(event *domain.LoginEvent) string
+	}{
+		{
+			name:  "wrong secret",
+			event: newEvent(time.Now()),
+			token: func(event *domain.LoginEvent) string { return event.ID.String() + ".other" },
+		},
+		{
+			name:  "expired link",
+			event: newEvent(time.Now().Add(-8 * 24 * time.Hour)),
+			token: func(event *domain.LoginEvent) string { return event.ID.String() + "." + secret },
+		},
+		{
+			name:  "login from a known device",
+			event: &domain.LoginEvent{ID: ulid.Make(), UserID: userID, CreatedAt: time.Now()},
+			token: func(event *domain.LoginEvent) string { return event.ID.String() + "." + secret },
+		},
+		{
+			name:  "malformed token",
+			token: func(event *domain.LoginEvent) string { return "not-a-token" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			historyRepo := new(mockLoginHistoryRepository)
+			userRepo := new(mockUserRepository)
+			service := NewLoginHistoryService(historyRepo, userRepo, new(mockTrustedDeviceRepository), new(mockEmailService), newTestLoginAlertConfig(), zap.NewNop())
+
+			if tt.event != nil {
+				historyRepo.On("FindByID", mock.Anything, tt.event.ID).Return(tt.event, nil)
+			}
+
+			err := service.RevokeSignIn(context.Background(), tt.token(tt.event))
+			assert.Equal(t, domain.ErrInvalidSignInRevocation, err)
+			historyRepo.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything, mock.Anything)
+			userRepo.AssertNotCalled(t, "RevokeSessions", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestIPPrefix(t *testing.T) {
+	assert.Equal(t, "203.0.113.0/24", ipPrefix("203.0.113.7"))
+	assert.Equal(t, "2001:db8:1::/48", ipPrefix("2001:db8:1:2::7"))
+	assert.Equal(t, "", ipPrefix("not-an-ip"))
+}
+
+func TestUserAgentFamily(t *testing.T) {
+	tests := map[string]string{
+		testFirefoxUserAgent: "Firefox on Linux",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0":           "Edge on Windows",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1": "Safari on iOS",
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36":                            "Chrome on Android",
+		"curl/8.5.0": "curl on unknown system",
+		"":           "Unknown device",
+	}
+
+	for userAgent, expected := range tests {
+		assert.Equal(t, expected, userAgentFamily(userAgent), userAgent)
+	}
+}
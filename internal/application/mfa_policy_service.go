@@ -0,0 +1,101 @@
+package application
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// MFAPolicyService requires a second factor of the roles, OAuth2 clients and scopes listed in the
+// configuration, on top of the users who enrolled one of their own accord
+type MFAPolicyService struct {
+	mfaService domain.MFAService
+	userRepo   domain.UserRepository
+	config     *config.Config
+	logger     *zap.Logger
+}
+
+// NewMFAPolicyService creates a new MFA policy service
+func NewMFAPolicyService(mfaService domain.MFAService, userRepo domain.UserRepository, config *config.Config, logger *zap.Logger) *MFAPolicyService {
+	return &MFAPolicyService{
+		mfaService: mfaService,
+		userRepo:   userRepo,
+		config:     config,
+		logger:     logger,
+	}
+}
+
+// Evaluate lists the rules requiring the user to use MFA. New users have MFAEnrollmentGracePeriod
+// from their registration to enrol a factor.
+func (s *MFAPolicyService) Evaluate(user *domain.User, clientID string, scopes []string) *domain.MFARequirement {
+	var reasons []string
+	for _, role := range user.Roles {
+		if slices.Contains(s.config.MFARequiredRoles, role) {
+			reasons = append(reasons, "role:"+role)
+		}
+	}
+
+	return &domain.MFARequirement{
+		Reasons:     append(reasons, s.clientReasons(clientID, scopes)...),
+		GraceEndsAt: user.CreatedAt.Add(s.config.MFAEnrollmentGracePeriod),
+	}
+}
+
+// CheckAuthorization keeps a user who signed in without naming the client from reaching it without
+// a second factor. Roles are enforced when signing in, so only the client and scopes are checked.
+func (s *MFAPolicyService) CheckAuthorization(ctx context.Context, userID, clientID string, scopes []string) error {
+	if len(s.clientReasons(clientID, scopes)) == 0 {
+		return nil
+	}
+
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return domain.ErrUserNotFound
+	}
+
+	requirement := s.Evaluate(user, clientID, scopes)
+	if requirement.InGracePeriod(time.Now()) {
+		return nil
+	}
+
+	factors, err := s.mfaService.ListFactors(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to list MFA factors",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return domain.ErrInternal
+	}
+	if requiresMFA(factors) {
+		return nil
+	}
+
+	s.logger.Warn("Authorization requires MFA enrolment",
+		zap.String("user_id", userID),
+		zap.String("client_id", clientID),
+		zap.Strings("reasons", requirement.Reasons))
+	return domain.ErrMFAEnrollmentRequired
+}
+
+// clientReasons lists the rules requiring MFA of anyone signing in to the client for the scopes
+func (s *MFAPolicyService) clientReasons(clientID string, scopes []string) []string {
+	var reasons []string
+	if clientID != "" && slices.Contains(s.config.MFARequiredClients, clientID) {
+		reasons = append(reasons, "client:"+clientID)
+	}
+	for _, scope := range scopes {
+		if slices.Contains(s.config.MFARequiredScopes, scope) {
+			reasons = append(reasons, "scope:"+scope)
+		}
+	}
+	return reasons
+}
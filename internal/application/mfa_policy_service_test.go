@@ -0,0 +1,139 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+func newMFAPolicyTestConfig() *config.Config {
+	return &config.Config{
+		MFARequiredRoles:         []string{"admin"},
+		MFARequiredClients:       []string{"payments-app"},
+		MFARequiredScopes:        []string{"payments:write"},
+		MFAEnrollmentGracePeriod: 7 * 24 * time.Hour,
+	}
+}
+
+func TestMFAPolicyService_Evaluate(t *testing.T) {
+	service := NewMFAPolicyService(nil, nil, newMFAPolicyTestConfig(), zap.NewNop())
+	createdAt := time.Now().Add(-30 * 24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		roles    []string
+		clientID string
+		scopes   []string
+		want     []string
+	}{
+		{
+			name:  "user without a covered role",
+			roles: []string{"user"},
+		},
+		{
+			name:  "admin role",
+			roles: []string{"user", "admin"},
+			want:  []string{"role:admin"},
+		},
+		{
+			name:     "covered client",
+			roles:    []string{"user"},
+			clientID: "payments-app",
+			want:     []string{"client:payments-app"},
+		},
+		{
+			name:     "covered scope requested by another client",
+			roles:    []string{"user"},
+			clientID: "reports-app",
+			scopes:   []string{"openid", "payments:write"},
+			want:     []string{"scope:payments:write"},
+		},
+		{
+			name:     "every rule at once",
+			roles:    []string{"admin"},
+			clientID: "payments-app",
+			scopes:   []string{"payments:write"},
+			want:     []string{"role:admin", "client:payments-app", "scope:payments:write"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &domain.User{ID: ulid.Make(), Roles: tt.roles, CreatedAt: createdAt}
+
+			requirement := service.Evaluate(user, tt.clientID, tt.scopes)
+
+			assert.Equal(t, tt.want, requirement.Reasons)
+			assert.Equal(t, len(tt.want) > 0, requirement.Required())
+			assert.Equal(t, createdAt.Add(7*24*time.Hour), requirement.GraceEndsAt)
+			assert.False(t, requirement.InGracePeriod(time.Now()))
+		})
+	}
+
+	t.Run("new users are in their grace period", func(t *testing.T) {
+		user := &domain.User{ID: ulid.Make(), Roles: []string{"admin"}, CreatedAt: time.Now().Add(-time.Hour)}
+
+		requirement := service.Evaluate(user, "", nil)
+
+		assert.True(t, requirement.Required())
+		assert.True(t, requirement.InGracePeriod(time.Now()))
+	})
+}
+
+func TestMFAPolicyService_CheckAuthorization(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Roles: []string{"user"}, CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+	newUser := &domain.User{ID: ulid.Make(), Roles: []string{"user"}, CreatedAt: time.Now().Add(-time.Hour)}
+
+	check := func(user *domain.User, factors []*domain.MFAFactor, clientID string, scopes []string) (*mockMFAService, error) {
+		userRepo := new(MockUserRepository)
+		userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil).Maybe()
+		mfaSvc := new(mockMFAService)
+		mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return(factors, nil).Maybe()
+
+		service := NewMFAPolicyService(mfaSvc, userRepo, newMFAPolicyTestConfig(), zap.NewNop())
+		return mfaSvc, service.CheckAuthorization(context.Background(), user.ID.String(), clientID, scopes)
+	}
+
+	t.Run("clients and scopes the policy does not cover", func(t *testing.T) {
+		mfaSvc, err := check(user, nil, "reports-app", []string{"openid"})
+
+		assert.NoError(t, err)
+		mfaSvc.AssertNotCalled(t, "ListFactors", mock.Anything, mock.Anything)
+	})
+
+	t.Run("covered client without a factor", func(t *testing.T) {
+		_, err := check(user, []*domain.MFAFactor{}, "payments-app", []string{"openid"})
+
+		assert.ErrorIs(t, err, domain.ErrMFAEnrollmentRequired)
+	})
+
+	t.Run("backup codes alone are not enough", func(t *testing.T) {
+		recovery := domain.NewMFAFactor(user.ID, domain.MFAFactorRecovery, "Backup codes")
+
+		_, err := check(user, []*domain.MFAFactor{recovery}, "reports-app", []string{"payments:write"})
+
+		assert.ErrorIs(t, err, domain.ErrMFAEnrollmentRequired)
+	})
+
+	t.Run("covered scope with a factor", func(t *testing.T) {
+		totp := domain.NewMFAFactor(user.ID, domain.MFAFactorTOTP, "Phone")
+
+		_, err := check(user, []*domain.MFAFactor{totp}, "reports-app", []string{"payments:write"})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("new user in the grace period", func(t *testing.T) {
+		mfaSvc, err := check(newUser, []*domain.MFAFactor{}, "payments-app", nil)
+
+		assert.NoError(t, err)
+		mfaSvc.AssertNotCalled(t, "ListFactors", mock.Anything, mock.Anything)
+	})
+}
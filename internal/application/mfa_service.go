@@ -0,0 +1,413 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"sort"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// mfaEmailCodeLength is the number of digits of an email_otp code
+const mfaEmailCodeLength = 6
+
+// MFAService keeps the registry of a user's second factors and verifies them by type
+type MFAService struct {
+	factorRepo       domain.MFAFactorRepository
+	credentialRepo   domain.WebAuthnCredentialRepository
+	resetRepo        domain.MFAResetRepository
+	totpService      domain.TOTPService
+	userRepo         domain.UserRepository
+	verificationRepo domain.VerificationCodeRepository
+	emailService     domain.EmailService
+	phoneService     domain.PhoneService
+	config           *config.Config
+	logger           *zap.Logger
+}
+
+// NewMFAService creates a new MFA service
+func NewMFAService(
+	factorRepo domain.MFAFactorRepository,
+	credentialRepo domain.WebAuthnCredentialRepository,
+	resetRepo domain.MFAResetRepository,
+	totpService domain.TOTPService,
+	userRepo domain.UserRepository,
+	verificationRepo domain.VerificationCodeRepository,
+	emailService domain.EmailService,
+	phoneService domain.PhoneService,
+	config *config.Config,
+	logger *zap.Logger,
+) *MFAService {
+	return &MFAService{
+		factorRepo:       factorRepo,
+		credentialRepo:   credentialRepo,
+		resetRepo:        resetRepo,
+		totpService:      totpService,
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		emailService:     emailService,
+		phoneService:     phoneService,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// ListFactors lists the registered factors of a user together with their passkeys, oldest first
+func (s *MFAService) ListFactors(ctx context.Context, userID string) ([]*domain.MFAFactor, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	factors, err := s.factorRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, credential := range credentials {
+		factors = append(factors, &domain.MFAFactor{
+			ID:         credential.ID,
+			UserID:     credential.UserID,
+			Type:       domain.MFAFactorWebAuthn,
+			Label:      credential.Name,
+			CreatedAt:  credential.CreatedAt,
+			LastUsedAt: credential.LastUsedAt,
+		})
+	}
+
+	sort.SliceStable(factors, func(i, j int) bool {
+		return factors[i].CreatedAt.Before(factors[j].CreatedAt)
+	})
+	return factors, nil
+}
+
+// RenameFactor changes the label of one of the user's factors
+func (s *MFAService) RenameFactor(ctx context.Context, userID, factorID, label string) error {
+	factor, err := s.findFactor(ctx, userID, factorID)
+	if err != nil {
+		return err
+	}
+
+	if factor.Type == domain.MFAFactorWebAuthn {
+		return s.credentialRepo.Rename(ctx, factor.UserID, factor.ID, label)
+	}
+	return s.factorRepo.Rename(ctx, factor.UserID, factor.ID, label)
+}
+
+// DeleteFactor removes one of the user's factors. Deleting the recovery factor discards the
+// backup codes.
+func (s *MFAService) DeleteFactor(ctx context.Context, userID, factorID string) error {
+	factor, err := s.findFactor(ctx, userID, factorID)
+	if err != nil {
+		return err
+	}
+
+	if factor.Type == domain.MFAFactorWebAuthn {
+		return s.credentialRepo.Delete(ctx, factor.UserID, factor.ID)
+	}
+	return s.factorRepo.Delete(ctx, factor.UserID, factor.ID)
+}
+
+// EnrollEmailOTP registers the user's email as a factor; the address must have been verified
+func (s *MFAService) EnrollEmailOTP(ctx context.Context, userID string) (*domain.MFAFactor, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if !user.EmailVerified {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	factors, err := s.factorRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, factor := range factors {
+		if factor.Type == domain.MFAFactorEmailOTP {
+			return nil, domain.ErrMFAFactorExists
+		}
+	}
+
+	factor := domain.NewMFAFactor(id, domain.MFAFactorEmailOTP, user.Email)
+	if err := s.factorRepo.Create(ctx, factor); err != nil {
+		return nil, err
+	}
+
+	return factor, nil
+}
+
+// EnrollSMSOTP registers the user's phone as a factor; the number must have been verified
+func (s *MFAService) EnrollSMSOTP(ctx context.Context, userID string) (*domain.MFAFactor, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if !user.PhoneVerified {
+		return nil, domain.ErrPhoneNotVerified
+	}
+
+	factors, err := s.factorRepo.ListByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, factor := range factors {
+		if factor.Type == domain.MFAFactorSMSOTP {
+			return nil, domain.ErrMFAFactorExists
+		}
+	}
+
+	factor := domain.NewMFAFactor(id, domain.MFAFactorSMSOTP, maskPhoneNumber(user.Phone))
+	if err := s.factorRepo.Create(ctx, factor); err != nil {
+		return nil, err
+	}
+
+	return factor, nil
+}
+
+// SendChallenge emails a single-use code for an email_otp factor, or texts or calls it for an
+// sms_otp factor. Authenticator apps, passkeys and backup codes need nothing sent.
+func (s *MFAService) SendChallenge(ctx context.Context, user *domain.User, factorID string, channel domain.SMSChannel) error {
+	factor, err := s.findFactor(ctx, user.ID.String(), factorID)
+	if err != nil {
+		return err
+	}
+
+	switch factor.Type {
+	case domain.MFAFactorEmailOTP:
+		return s.SendEmailCode(ctx, user)
+	case domain.MFAFactorSMSOTP:
+		return s.phoneService.SendCode(ctx, user, channel)
+	default:
+		return domain.ErrMFAFactorNotSupported
+	}
+}
+
+// SendEmailCode emails a single-use code to the user, replacing any code sent before
+func (s *MFAService) SendEmailCode(ctx context.Context, user *domain.User) error {
+	// Only the latest code can be redeemed
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, user.ID, domain.MFAEmailCode); err != nil {
+		s.logger.Error("Failed to delete existing MFA email codes", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	code, err := generateNumericCode(mfaEmailCodeLength)
+	if err != nil {
+		s.logger.Error("Failed to generate MFA email code", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	stored := domain.NewVerificationCode(user.ID, hashOneTimeSecret(user.ID, code), domain.MFAEmailCode, s.config.MFAEmailCodeTTL)
+	if err := s.verificationRepo.Create(ctx, stored); err != nil {
+		s.logger.Error("Failed to store MFA email code", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	if err := s.emailService.SendMFACodeEmail(ctx, user.Email, code); err != nil {
+		s.logger.Error("Failed to send MFA email code", zap.Error(err))
+		return domain.ErrEmailSendFailed
+	}
+
+	return nil
+}
+
+// VerifyFactor checks a code against one of the user's factors and returns the factor's type.
+// Passkeys answer the WebAuthn ceremony instead of a code.
+func (s *MFAService) VerifyFactor(ctx context.Context, user *domain.User, factorID, code string) (domain.MFAFactorType, error) {
+	if factorID == "" {
+		return s.verifyTOTPOrBackupCode(user.ID.String(), code)
+	}
+
+	factor, err := s.findFactor(ctx, user.ID.String(), factorID)
+	if err != nil {
+		return "", err
+	}
+
+	switch factor.Type {
+	case domain.MFAFactorTOTP:
+		err = s.totpService.VerifyTOTPFactor(ctx, user.ID.String(), factorID, code)
+	case domain.MFAFactorRecovery:
+		err = s.totpService.VerifyBackupCode(user.ID.String(), code)
+	case domain.MFAFactorEmailOTP:
+		err = s.verifyEmailCode(ctx, user, factor, code)
+	case domain.MFAFactorSMSOTP:
+		err = s.verifySMSCode(ctx, user, factor, code)
+	default:
+		return "", domain.ErrMFAFactorNotSupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return factor.Type, nil
+}
+
+// verifyTOTPOrBackupCode checks a code against the user's authenticator apps and, when none of
+// them accepts it, against their backup codes. A code rejected by both reports the authenticator
+// app error, which is what most users mistyped.
+func (s *MFAService) verifyTOTPOrBackupCode(userID, code string) (domain.MFAFactorType, error) {
+	err := s.totpService.VerifyTOTP(userID, code)
+	if err == nil {
+		return domain.MFAFactorTOTP, nil
+	}
+	if err != domain.ErrInvalidTOTPCode && err != domain.ErrTOTPNotEnabled {
+		return "", err
+	}
+
+	switch backupErr := s.totpService.VerifyBackupCode(userID, code); backupErr {
+	case nil:
+		return domain.MFAFactorRecovery, nil
+	case domain.ErrInvalidTOTPBackupCode, domain.ErrTOTPNotEnabled:
+		return "", err
+	default:
+		return "", backupErr
+	}
+}
+
+// ResetMFA removes every factor, passkey, pending enrolment and trusted device of a user who lost
+// access to them, once an administrator verified their identity out of band. Administrators cannot
+// reset their own MFA, and every reset is recorded along with the user's factors at the time.
+func (s *MFAService) ResetMFA(ctx context.Context, adminID, userID string, method domain.MFAResetMethod, reason string) (*domain.MFAReset, error) {
+	admin, err := ulid.Parse(adminID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+	if adminID == userID {
+		return nil, domain.ErrForbidden
+	}
+
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	factors, err := s.ListFactors(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(factors) == 0 {
+		return nil, domain.ErrNoMFAFactors
+	}
+
+	reset := &domain.MFAReset{
+		ID:                 ulid.Make(),
+		UserID:             user.ID,
+		AdminID:            admin,
+		VerificationMethod: method,
+		Reason:             reason,
+		RemovedFactors:     domain.MFAFactorTypes(factors),
+		CreatedAt:          time.Now(),
+	}
+	if ip, ok := domain.GetClientIP(ctx); ok {
+		reset.ClientIP = ip
+	}
+	if err := s.resetRepo.Reset(ctx, reset); err != nil {
+		return nil, err
+	}
+
+	s.logger.Warn("MFA reset by administrator",
+		zap.String("user_id", userID),
+		zap.String("admin_id", adminID),
+		zap.String("verification_method", string(method)),
+		zap.String("reset_id", reset.ID.String()))
+
+	// The factors are already gone, so a failed notification is only logged
+	if err := s.emailService.SendMFAResetEmail(ctx, user.Email, reset.CreatedAt); err != nil {
+		s.logger.Error("Failed to send MFA reset email",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	}
+
+	return reset, nil
+}
+
+// ListResets lists the MFA resets of a user, newest first
+func (s *MFAService) ListResets(ctx context.Context, userID string) ([]*domain.MFAReset, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+	return s.resetRepo.ListByUser(ctx, id)
+}
+
+// verifyEmailCode redeems the code last sent for an email_otp factor
+func (s *MFAService) verifyEmailCode(ctx context.Context, user *domain.User, factor *domain.MFAFactor, code string) error {
+	if err := s.VerifyEmailCode(ctx, user, code); err != nil {
+		return err
+	}
+
+	if err := s.factorRepo.Touch(ctx, factor.ID, time.Now()); err != nil {
+		s.logger.Warn("Failed to record MFA factor use", zap.String("factor_id", factor.ID.String()), zap.Error(err))
+	}
+	return nil
+}
+
+// verifySMSCode redeems the code last texted or called to the phone of an sms_otp factor
+func (s *MFAService) verifySMSCode(ctx context.Context, user *domain.User, factor *domain.MFAFactor, code string) error {
+	if err := s.phoneService.VerifyCode(ctx, user, code); err != nil {
+		return err
+	}
+
+	if err := s.factorRepo.Touch(ctx, factor.ID, time.Now()); err != nil {
+		s.logger.Warn("Failed to record MFA factor use", zap.String("factor_id", factor.ID.String()), zap.Error(err))
+	}
+	return nil
+}
+
+// VerifyEmailCode redeems the code last emailed to the user
+func (s *MFAService) VerifyEmailCode(ctx context.Context, user *domain.User, code string) error {
+	stored, err := s.verificationRepo.FindByUserIDAndType(ctx, user.ID, domain.MFAEmailCode)
+	if err != nil || subtle.ConstantTimeCompare([]byte(stored.Code), []byte(hashOneTimeSecret(user.ID, code))) != 1 {
+		return domain.ErrInvalidVerificationCode
+	}
+
+	// Codes are single-use, so a code that cannot be deleted is not honoured
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, user.ID, domain.MFAEmailCode); err != nil {
+		s.logger.Error("Failed to delete MFA email code", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	if stored.IsExpired() {
+		return domain.ErrVerificationCodeExpired
+	}
+	return nil
+}
+
+// findFactor looks up one of the user's factors, passkeys included
+func (s *MFAService) findFactor(ctx context.Context, userID, factorID string) (*domain.MFAFactor, error) {
+	id, err := ulid.Parse(factorID)
+	if err != nil {
+		return nil, domain.ErrMFAFactorNotFound
+	}
+
+	factors, err := s.ListFactors(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, factor := range factors {
+		if factor.ID == id {
+			return factor, nil
+		}
+	}
+	return nil, domain.ErrMFAFactorNotFound
+}
@@ -0,0 +1,517 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockMFAFactorRepository struct {
+	mock.Mock
+}
+
+func (m *mockMFAFactorRepository) Create(ctx context.Context, factor *domain.MFAFactor) error {
+	args := m.Called(ctx, factor)
+	return args.Error(0)
+}
+
+func (m *mockMFAFactorRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.MFAFactor, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.MFAFactor), args.Error(1)
+}
+
+func (m *mockMFAFactorRepository) Touch(ctx context.Context, id ulid.ULID, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func (m *mockMFAFactorRepository) Rename(ctx context.Context, userID, id ulid.ULID, label string) error {
+	args := m.Called(ctx, userID, id, label)
+	return args.Error(0)
+}
+
+func (m *mockMFAFactorRepository) Delete(ctx context.Context, userID, id ulid.ULID) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+type mockMFAResetRepository struct {
+	mock.Mock
+}
+
+func (m *mockMFAResetRepository) Reset(ctx context.Context, reset *domain.MFAReset) error {
+	args := m.Called(ctx, reset)
+	return args.Error(0)
+}
+
+func (m *mockMFAResetRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.MFAReset, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.MFAReset), args.Error(1)
+}
+
+type mockTOTPService struct {
+	mock.Mock
+}
+
+func (m *mockTOTPService) EnableTOTP(userID, label string) (*domain.TOTP, error) {
+	args := m.Called(userID, label)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTP), args.Error(1)
+}
+
+func (m *mockTOTPService) ConfirmTOTP(ctx context.Context, userID, code string) (*domain.TOTP, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTP), args.Error(1)
+}
+
+func (m *mockTOTPService) VerifyTOTP(userID, code string) error {
+	args := m.Called(userID, code)
+	return args.Error(0)
+}
+
+func (m *mockTOTPService) VerifyTOTPFactor(ctx context.Context, userID, factorID, code string) error {
+	args := m.Called(ctx, userID, factorID, code)
+	return args.Error(0)
+}
+
+func (m *mockTOTPService) VerifyBackupCode(userID, code string) error {
+	args := m.Called(userID, code)
+	return args.Error(0)
+}
+
+func (m *mockTOTPService) VerifyUserCode(ctx context.Context, userID, code string, factorTypes ...domain.MFAFactorType) error {
+	args := m.Called(ctx, userID, code, factorTypes)
+	return args.Error(0)
+}
+
+func (m *mockTOTPService) BackupCodesStatus(ctx context.Context, userID string) (*domain.BackupCodesStatus, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BackupCodesStatus), args.Error(1)
+}
+
+func (m *mockTOTPService) RegenerateBackupCodes(ctx context.Context, userID, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *mockTOTPService) DisableTOTP(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *mockTOTPService) IsTOTPEnabled(ctx context.Context, userID string) (bool, error) {
+	args := m.Called(ctx, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+type mfaTestFixture struct {
+	factorRepo       *mockMFAFactorRepository
+	credentialRepo   *mockWebAuthnCredentialRepository
+	resetRepo        *mockMFAResetRepository
+	totpService      *mockTOTPService
+	userRepo         *MockUserRepository
+	verificationRepo *mockVerificationCodeRepository
+	emailService     *mockEmailService
+	phoneService     *mockPhoneService
+	service          *MFAService
+}
+
+func newMFATestFixture() *mfaTestFixture {
+	f := &mfaTestFixture{
+		factorRepo:       new(mockMFAFactorRepository),
+		credentialRepo:   new(mockWebAuthnCredentialRepository),
+		resetRepo:        new(mockMFAResetRepository),
+		totpService:      new(mockTOTPService),
+		userRepo:         new(MockUserRepository),
+		verificationRepo: new(mockVerificationCodeRepository),
+		emailService:     new(mockEmailService),
+		phoneService:     new(mockPhoneService),
+	}
+	f.service = NewMFAService(f.factorRepo, f.credentialRepo, f.resetRepo, f.totpService, f.userRepo, f.verificationRepo, f.emailService, f.phoneService,
+		&config.Config{MFAEmailCodeTTL: 10 * time.Minute}, zap.NewNop())
+	return f
+}
+
+func TestMFAService_ListFactors(t *testing.T) {
+	userID := ulid.Make()
+	now := time.Now()
+	totp := &domain.MFAFactor{ID: ulid.Make(), UserID: userID, Type: domain.MFAFactorTOTP, Label: "Phone", CreatedAt: now.Add(-2 * time.Hour)}
+	recovery := &domain.MFAFactor{ID: ulid.Make(), UserID: userID, Type: domain.MFAFactorRecovery, Label: "Backup codes", CreatedAt: now}
+	passkey := &domain.WebAuthnCredential{ID: ulid.Make(), UserID: userID, Name: "Laptop", CreatedAt: now.Add(-time.Hour), LastUsedAt: &now}
+
+	f := newMFATestFixture()
+	f.factorRepo.On("ListByUser", mock.Anything, userID).Return([]*domain.MFAFactor{totp, recovery}, nil)
+	f.credentialRepo.On("ListByUser", mock.Anything, userID).Return([]*domain.WebAuthnCredential{passkey}, nil)
+
+	factors, err := f.service.ListFactors(context.Background(), userID.String())
+	require.NoError(t, err)
+	require.Len(t, factors, 3)
+	assert.Equal(t, totp, factors[0])
+	assert.Equal(t, passkey.ID, factors[1].ID)
+	assert.Equal(t, domain.MFAFactorWebAuthn, factors[1].Type)
+	assert.Equal(t, "Laptop", factors[1].Label)
+	assert.Equal(t, &now, factors[1].LastUsedAt)
+	assert.Equal(t, recovery, factors[2])
+	assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorTOTP, domain.MFAFactorWebAuthn, domain.MFAFactorRecovery}, domain.MFAFactorTypes(factors))
+}
+
+func TestMFAService_ManageFactors(t *testing.T) {
+	userID := ulid.Make()
+	totp := &domain.MFAFactor{ID: ulid.Make(), UserID: userID, Type: domain.MFAFactorTOTP, Label: "Phone"}
+	passkey := &domain.WebAuthnCredential{ID: ulid.Make(), UserID: userID, Name: "Laptop"}
+
+	setup := func() *mfaTestFixture {
+		f := newMFATestFixture()
+		f.factorRepo.On("ListByUser", mock.Anything, userID).Return([]*domain.MFAFactor{totp}, nil)
+		f.credentialRepo.On("ListByUser", mock.Anything, userID).Return([]*domain.WebAuthnCredential{passkey}, nil)
+		return f
+	}
+
+	t.Run("rename authenticator app", func(t *testing.T) {
+		f := setup()
+		f.factorRepo.On("Rename", mock.Anything, userID, totp.ID, "Old phone").Return(nil)
+
+		assert.NoError(t, f.service.RenameFactor(context.Background(), userID.String(), totp.ID.String(), "Old phone"))
+		f.factorRepo.AssertExpectations(t)
+	})
+
+	t.Run("delete passkey", func(t *testing.T) {
+		f := setup()
+		f.credentialRepo.On("Delete", mock.Anything, userID, passkey.ID).Return(nil)
+
+		assert.NoError(t, f.service.DeleteFactor(context.Background(), userID.String(), passkey.ID.String()))
+		f.credentialRepo.AssertExpectations(t)
+		f.factorRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("factor of another user", func(t *testing.T) {
+		f := setup()
+
+		err := f.service.DeleteFactor(context.Background(), userID.String(), ulid.Make().String())
+		assert.Equal(t, domain.ErrMFAFactorNotFound, err)
+	})
+
+	t.Run("invalid factor ID", func(t *testing.T) {
+		f := newMFATestFixture()
+
+		err := f.service.RenameFactor(context.Background(), userID.String(), "not-a-factor", "Phone")
+		assert.Equal(t, domain.ErrMFAFactorNotFound, err)
+	})
+}
+
+func TestMFAService_EnrollEmailOTP(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", EmailVerified: true}
+
+	t.Run("registers the verified email", func(t *testing.T) {
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{}, nil)
+		f.factorRepo.On("Create", mock.Anything, mock.MatchedBy(func(factor *domain.MFAFactor) bool {
+			return factor.Type == domain.MFAFactorEmailOTP && factor.Label == user.Email && factor.UserID == user.ID
+		})).Return(nil)
+
+		factor, err := f.service.EnrollEmailOTP(context.Background(), user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, domain.MFAFactorEmailOTP, factor.Type)
+		f.factorRepo.AssertExpectations(t)
+	})
+
+	t.Run("already enrolled", func(t *testing.T) {
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{
+			domain.NewMFAFactor(user.ID, domain.MFAFactorEmailOTP, user.Email),
+		}, nil)
+
+		_, err := f.service.EnrollEmailOTP(context.Background(), user.ID.String())
+		assert.Equal(t, domain.ErrMFAFactorExists, err)
+	})
+
+	t.Run("unverified email", func(t *testing.T) {
+		unverified := *user
+		unverified.EmailVerified = false
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(&unverified, nil)
+
+		_, err := f.service.EnrollEmailOTP(context.Background(), user.ID.String())
+		assert.Equal(t, domain.ErrEmailNotVerified, err)
+	})
+}
+
+func TestMFAService_EmailOTPChallenge(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", EmailVerified: true}
+	factor := domain.NewMFAFactor(user.ID, domain.MFAFactorEmailOTP, user.Email)
+
+	f := newMFATestFixture()
+	f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{factor}, nil)
+	f.credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{}, nil)
+	f.verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.MFAEmailCode).Return(nil)
+
+	var stored *domain.VerificationCode
+	f.verificationRepo.On("Create", mock.Anything, mock.MatchedBy(func(code *domain.VerificationCode) bool {
+		stored = code
+		return code.Type == domain.MFAEmailCode
+	})).Return(nil)
+	var sent string
+	f.emailService.On("SendMFACodeEmail", mock.Anything, user.Email, mock.MatchedBy(func(code string) bool {
+		sent = code
+		return len(code) == mfaEmailCodeLength
+	})).Return(nil)
+
+	require.NoError(t, f.service.SendChallenge(context.Background(), user, factor.ID.String(), ""))
+	assert.NotEqual(t, sent, stored.Code, "codes are stored hashed")
+
+	f.verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.MFAEmailCode).Return(stored, nil)
+
+	t.Run("wrong code", func(t *testing.T) {
+		_, err := f.service.VerifyFactor(context.Background(), user, factor.ID.String(), "wrong")
+		assert.Equal(t, domain.ErrInvalidVerificationCode, err)
+	})
+
+	t.Run("sent code", func(t *testing.T) {
+		f.factorRepo.On("Touch", mock.Anything, factor.ID, mock.Anything).Return(nil)
+
+		factorType, err := f.service.VerifyFactor(context.Background(), user, factor.ID.String(), sent)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.MFAFactorEmailOTP, factorType)
+		f.factorRepo.AssertExpectations(t)
+		f.verificationRepo.AssertNumberOfCalls(t, "DeleteByUserIDAndType", 2)
+	})
+}
+
+func TestMFAService_EnrollSMSOTP(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", Phone: "+14155550123", PhoneVerified: true}
+
+	t.Run("registers the verified phone", func(t *testing.T) {
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{}, nil)
+		f.factorRepo.On("Create", mock.Anything, mock.MatchedBy(func(factor *domain.MFAFactor) bool {
+			return factor.Type == domain.MFAFactorSMSOTP && factor.Label == "+*******0123"
+		})).Return(nil)
+
+		factor, err := f.service.EnrollSMSOTP(context.Background(), user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, domain.MFAFactorSMSOTP, factor.Type)
+		f.factorRepo.AssertExpectations(t)
+	})
+
+	t.Run("already enrolled", func(t *testing.T) {
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{
+			domain.NewMFAFactor(user.ID, domain.MFAFactorSMSOTP, "+*******0123"),
+		}, nil)
+
+		_, err := f.service.EnrollSMSOTP(context.Background(), user.ID.String())
+		assert.Equal(t, domain.ErrMFAFactorExists, err)
+	})
+
+	t.Run("unverified phone", func(t *testing.T) {
+		unverified := *user
+		unverified.PhoneVerified = false
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(&unverified, nil)
+
+		_, err := f.service.EnrollSMSOTP(context.Background(), user.ID.String())
+		assert.Equal(t, domain.ErrPhoneNotVerified, err)
+	})
+}
+
+func TestMFAService_SMSOTPChallenge(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Phone: "+14155550123", PhoneVerified: true}
+	factor := domain.NewMFAFactor(user.ID, domain.MFAFactorSMSOTP, "+*******0123")
+
+	f := newMFATestFixture()
+	f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{factor}, nil)
+	f.credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{}, nil)
+	f.phoneService.On("SendCode", mock.Anything, user, domain.SMSChannelVoice).Return(nil)
+
+	require.NoError(t, f.service.SendChallenge(context.Background(), user, factor.ID.String(), domain.SMSChannelVoice))
+	f.phoneService.AssertExpectations(t)
+
+	t.Run("wrong code", func(t *testing.T) {
+		f.phoneService.On("VerifyCode", mock.Anything, user, "000000").Return(domain.ErrInvalidVerificationCode).Once()
+
+		_, err := f.service.VerifyFactor(context.Background(), user, factor.ID.String(), "000000")
+		assert.Equal(t, domain.ErrInvalidVerificationCode, err)
+	})
+
+	t.Run("sent code", func(t *testing.T) {
+		f.phoneService.On("VerifyCode", mock.Anything, user, "123456").Return(nil).Once()
+		f.factorRepo.On("Touch", mock.Anything, factor.ID, mock.Anything).Return(nil)
+
+		factorType, err := f.service.VerifyFactor(context.Background(), user, factor.ID.String(), "123456")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.MFAFactorSMSOTP, factorType)
+		f.factorRepo.AssertExpectations(t)
+	})
+}
+
+func TestMFAService_VerifyFactor(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+	totp := domain.NewMFAFactor(user.ID, domain.MFAFactorTOTP, "Phone")
+	recovery := domain.NewMFAFactor(user.ID, domain.MFAFactorRecovery, "Backup codes")
+	passkey := &domain.WebAuthnCredential{ID: ulid.Make(), UserID: user.ID, Name: "Laptop"}
+
+	tests := []struct {
+		name          string
+		factorID      string
+		setupMocks    func(*mockTOTPService)
+		expectedType  domain.MFAFactorType
+		expectedError error
+	}{
+		{
+			name:     "any authenticator app without a factor ID",
+			factorID: "",
+			setupMocks: func(ts *mockTOTPService) {
+				ts.On("VerifyTOTP", user.ID.String(), "123456").Return(nil)
+			},
+			expectedType: domain.MFAFactorTOTP,
+		},
+		{
+			name:     "backup code without a factor ID",
+			factorID: "",
+			setupMocks: func(ts *mockTOTPService) {
+				ts.On("VerifyTOTP", user.ID.String(), "123456").Return(domain.ErrInvalidTOTPCode)
+				ts.On("VerifyBackupCode", user.ID.String(), "123456").Return(nil)
+			},
+			expectedType: domain.MFAFactorRecovery,
+		},
+		{
+			name:     "code rejected by apps and backup codes",
+			factorID: "",
+			setupMocks: func(ts *mockTOTPService) {
+				ts.On("VerifyTOTP", user.ID.String(), "123456").Return(domain.ErrInvalidTOTPCode)
+				ts.On("VerifyBackupCode", user.ID.String(), "123456").Return(domain.ErrInvalidTOTPBackupCode)
+			},
+			expectedError: domain.ErrInvalidTOTPCode,
+		},
+		{
+			name:     "replayed code is not tried as a backup code",
+			factorID: "",
+			setupMocks: func(ts *mockTOTPService) {
+				ts.On("VerifyTOTP", user.ID.String(), "123456").Return(domain.ErrTOTPCodeReused)
+			},
+			expectedError: domain.ErrTOTPCodeReused,
+		},
+		{
+			name:     "chosen authenticator app",
+			factorID: totp.ID.String(),
+			setupMocks: func(ts *mockTOTPService) {
+				ts.On("VerifyTOTPFactor", mock.Anything, user.ID.String(), totp.ID.String(), "123456").Return(nil)
+			},
+			expectedType: domain.MFAFactorTOTP,
+		},
+		{
+			name:     "backup code",
+			factorID: recovery.ID.String(),
+			setupMocks: func(ts *mockTOTPService) {
+				ts.On("VerifyBackupCode", user.ID.String(), "123456").Return(domain.ErrInvalidTOTPBackupCode)
+			},
+			expectedError: domain.ErrInvalidTOTPBackupCode,
+		},
+		{
+			name:          "passkeys answer the WebAuthn ceremony",
+			factorID:      passkey.ID.String(),
+			setupMocks:    func(ts *mockTOTPService) {},
+			expectedError: domain.ErrMFAFactorNotSupported,
+		},
+		{
+			name:          "unknown factor",
+			factorID:      ulid.Make().String(),
+			setupMocks:    func(ts *mockTOTPService) {},
+			expectedError: domain.ErrMFAFactorNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMFATestFixture()
+			f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{totp, recovery}, nil).Maybe()
+			f.credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{passkey}, nil).Maybe()
+			tt.setupMocks(f.totpService)
+
+			factorType, err := f.service.VerifyFactor(context.Background(), user, tt.factorID, "123456")
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedType, factorType)
+			f.totpService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMFAService_ResetMFA(t *testing.T) {
+	admin := ulid.Make()
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+	totp := domain.NewMFAFactor(user.ID, domain.MFAFactorTOTP, "Phone")
+	recovery := domain.NewMFAFactor(user.ID, domain.MFAFactorRecovery, "Backup codes")
+	passkey := &domain.WebAuthnCredential{ID: ulid.Make(), UserID: user.ID, Name: "Laptop", CreatedAt: recovery.CreatedAt.Add(time.Second)}
+
+	t.Run("removes every factor and records the reset", func(t *testing.T) {
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{totp, recovery}, nil)
+		f.credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{passkey}, nil)
+		f.resetRepo.On("Reset", mock.Anything, mock.MatchedBy(func(reset *domain.MFAReset) bool {
+			return reset.UserID == user.ID && reset.AdminID == admin &&
+				reset.VerificationMethod == domain.MFAResetIDDocument && reset.Reason == "Lost phone" &&
+				reset.ClientIP == "203.0.113.7"
+		})).Return(nil)
+		f.emailService.On("SendMFAResetEmail", mock.Anything, user.Email, mock.Anything).Return(domain.ErrEmailSendFailed)
+
+		ctx := domain.WithClientIP(context.Background(), "203.0.113.7")
+		reset, err := f.service.ResetMFA(ctx, admin.String(), user.ID.String(), domain.MFAResetIDDocument, "Lost phone")
+		require.NoError(t, err)
+		assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorTOTP, domain.MFAFactorRecovery, domain.MFAFactorWebAuthn}, reset.RemovedFactors)
+		f.resetRepo.AssertExpectations(t)
+		f.emailService.AssertExpectations(t)
+	})
+
+	t.Run("administrators cannot reset their own MFA", func(t *testing.T) {
+		f := newMFATestFixture()
+
+		_, err := f.service.ResetMFA(context.Background(), user.ID.String(), user.ID.String(), domain.MFAResetInPerson, "Lost phone")
+		assert.Equal(t, domain.ErrForbidden, err)
+		f.resetRepo.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything)
+	})
+
+	t.Run("user without factors", func(t *testing.T) {
+		f := newMFATestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.factorRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.MFAFactor{}, nil)
+		f.credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{}, nil)
+
+		_, err := f.service.ResetMFA(context.Background(), admin.String(), user.ID.String(), domain.MFAResetVideoCall, "Lost phone")
+		assert.Equal(t, domain.ErrNoMFAFactors, err)
+		f.resetRepo.AssertNotCalled(t, "Reset", mock.Anything, mock.Anything)
+	})
+}
@@ -3,7 +3,9 @@ package application
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/json"
 	"time"
 
 	"github.com/manorfm/authM/internal/domain"
@@ -37,15 +39,8 @@ func (s *OAuth2Service) ValidateClient(ctx context.Context, clientID, redirectUR
 		return nil, domain.ErrClientNotFound
 	}
 
-	// Validate redirect URI
-	valid := false
-	for _, uri := range client.RedirectURIs {
-		if uri == redirectURI {
-			valid = true
-			break
-		}
-	}
-	if !valid {
+	// Validate redirect URI against the client's redirect policy
+	if !client.MatchRedirectURI(redirectURI) {
 		s.logger.Error("Invalid redirect URI",
 			zap.String("client_id", clientID),
 			zap.String("redirect_uri", redirectURI))
@@ -55,6 +50,38 @@ func (s *OAuth2Service) ValidateClient(ctx context.Context, clientID, redirectUR
 	return client, nil
 }
 
+func (s *OAuth2Service) GetClient(ctx context.Context, clientID string) (*domain.OAuth2Client, error) {
+	client, err := s.oauthRepo.FindClientByID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to find client",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return nil, domain.ErrClientNotFound
+	}
+
+	return client, nil
+}
+
+// AuthenticateClient checks the secret of a confidential client. Public clients have no secret and are
+// identified by their ID alone, so they must not send one.
+func (s *OAuth2Service) AuthenticateClient(ctx context.Context, clientID, clientSecret string) (*domain.OAuth2Client, error) {
+	client, err := s.oauthRepo.FindClientByID(ctx, clientID)
+	if err != nil {
+		s.logger.Error("Failed to find client",
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return nil, domain.ErrInvalidClient
+	}
+
+	if subtle.ConstantTimeCompare([]byte(client.Secret), []byte(clientSecret)) != 1 {
+		s.logger.Error("Invalid client secret",
+			zap.String("client_id", clientID))
+		return nil, domain.ErrInvalidClient
+	}
+
+	return client, nil
+}
+
 func (s *OAuth2Service) GenerateAuthorizationCode(ctx context.Context, clientID, userID string, scopes []string, codeChallenge, codeChallengeMethod string) (string, error) {
 	s.logger.Debug("Generating authorization code",
 		zap.String("client_id", clientID),
@@ -64,6 +91,18 @@ func (s *OAuth2Service) GenerateAuthorizationCode(ctx context.Context, clientID,
 	// Generate random code
 	code := ulid.Make().String()
 
+	// Keep the claims request so the token endpoint can honour it
+	var claimsRequest string
+	if claims, ok := domain.GetClaimsRequest(ctx); ok && claims != nil {
+		encoded, err := json.Marshal(claims)
+		if err != nil {
+			s.logger.Error("Failed to encode claims request",
+				zap.Error(err))
+			return "", domain.ErrInvalidClaimsRequest
+		}
+		claimsRequest = string(encoded)
+	}
+
 	// Create authorization code
 	authCode := &domain.AuthorizationCode{
 		Code:                code,
@@ -72,10 +111,17 @@ func (s *OAuth2Service) GenerateAuthorizationCode(ctx context.Context, clientID,
 		Scopes:              scopes,
 		CodeChallenge:       codeChallenge,
 		CodeChallengeMethod: codeChallengeMethod,
+		ClaimsRequest:       claimsRequest,
 		CreatedAt:           time.Now(),
 		ExpiresAt:           time.Now().Add(10 * time.Minute),
 	}
 
+	// Keep how the user signed in so the tokens can report it
+	if auth, ok := domain.GetAuthentication(ctx); ok {
+		authCode.AMR = auth.Methods
+		authCode.AuthTime = &auth.Time
+	}
+
 	// Store code in repository
 	err := s.oauthRepo.CreateAuthorizationCode(ctx, authCode)
 	if err != nil {
@@ -87,7 +133,7 @@ func (s *OAuth2Service) GenerateAuthorizationCode(ctx context.Context, clientID,
 	return code, nil
 }
 
-func (s *OAuth2Service) ValidateAuthorizationCode(ctx context.Context, code string) (*domain.OAuth2Client, string, []string, error) {
+func (s *OAuth2Service) ValidateAuthorizationCode(ctx context.Context, code string) (*domain.OAuth2Client, *domain.AuthorizationCode, error) {
 	s.logger.Debug("Validating authorization code",
 		zap.String("code", code))
 
@@ -97,7 +143,7 @@ func (s *OAuth2Service) ValidateAuthorizationCode(ctx context.Context, code stri
 		s.logger.Error("Failed to find authorization code",
 			zap.String("code", code),
 			zap.Error(err))
-		return nil, "", nil, domain.ErrInvalidAuthorizationCode
+		return nil, nil, domain.ErrInvalidAuthorizationCode
 	}
 
 	// Check if code is expired
@@ -105,7 +151,7 @@ func (s *OAuth2Service) ValidateAuthorizationCode(ctx context.Context, code stri
 		s.logger.Error("Authorization code expired",
 			zap.String("code", code),
 			zap.Time("expires_at", authCode.ExpiresAt))
-		return nil, "", nil, domain.ErrAuthorizationCodeExpired
+		return nil, nil, domain.ErrAuthorizationCodeExpired
 	}
 
 	// Get client from repository
@@ -114,7 +160,7 @@ func (s *OAuth2Service) ValidateAuthorizationCode(ctx context.Context, code stri
 		s.logger.Error("Failed to find client",
 			zap.String("client_id", authCode.ClientID),
 			zap.Error(err))
-		return nil, "", nil, domain.ErrClientNotFound
+		return nil, nil, domain.ErrClientNotFound
 	}
 
 	// Delete the authorization code after use
@@ -126,7 +172,7 @@ func (s *OAuth2Service) ValidateAuthorizationCode(ctx context.Context, code stri
 		// Don't return error here as the code was still valid
 	}
 
-	return client, authCode.UserID, authCode.Scopes, nil
+	return client, authCode, nil
 }
 
 func (s *OAuth2Service) ValidatePKCE(ctx context.Context, codeVerifier, codeChallenge, codeChallengeMethod string) error {
@@ -135,6 +181,11 @@ func (s *OAuth2Service) ValidatePKCE(ctx context.Context, codeVerifier, codeChal
 		zap.String("code_challenge", codeChallenge),
 		zap.String("code_challenge_method", codeChallengeMethod))
 
+	// The method defaults to plain when the authorization request left it out (RFC 7636)
+	if codeChallengeMethod == "" {
+		codeChallengeMethod = "plain"
+	}
+
 	// Validate code challenge method
 	if codeChallengeMethod != "S256" && codeChallengeMethod != "plain" {
 		s.logger.Error("Invalid code challenge method",
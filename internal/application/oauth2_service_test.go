@@ -103,6 +103,83 @@ func TestOAuth2Service_ValidateClient(t *testing.T) {
 			},
 			wantErr: domain.ErrInvalidRedirectURI,
 		},
+		{
+			name:        "native client loopback on any port",
+			clientID:    "desktop-app",
+			redirectURI: "http://127.0.0.1:51004/callback",
+			setupMock: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "desktop-app").Return(&domain.OAuth2Client{
+					ID:              "desktop-app",
+					ApplicationType: domain.ApplicationTypeNative,
+					RedirectURIs:    []string{"http://127.0.0.1/callback"},
+				}, nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:        "native client localhost on any port",
+			clientID:    "desktop-app",
+			redirectURI: "http://localhost:51004/callback",
+			setupMock: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "desktop-app").Return(&domain.OAuth2Client{
+					ID:              "desktop-app",
+					ApplicationType: domain.ApplicationTypeNative,
+					RedirectURIs:    []string{"http://localhost/callback"},
+				}, nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:        "native client loopback with different path",
+			clientID:    "desktop-app",
+			redirectURI: "http://127.0.0.1:51004/other",
+			setupMock: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "desktop-app").Return(&domain.OAuth2Client{
+					ID:              "desktop-app",
+					ApplicationType: domain.ApplicationTypeNative,
+					RedirectURIs:    []string{"http://127.0.0.1/callback"},
+				}, nil)
+			},
+			wantErr: domain.ErrInvalidRedirectURI,
+		},
+		{
+			name:        "web client loopback port must match exactly",
+			clientID:    "test-client",
+			redirectURI: "http://127.0.0.1:51004/callback",
+			setupMock: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "test-client").Return(&domain.OAuth2Client{
+					ID:           "test-client",
+					RedirectURIs: []string{"http://127.0.0.1/callback"},
+				}, nil)
+			},
+			wantErr: domain.ErrInvalidRedirectURI,
+		},
+		{
+			name:        "wildcard subdomain pattern",
+			clientID:    "preview-app",
+			redirectURI: "https://pr-42.preview.example.com/callback",
+			setupMock: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "preview-app").Return(&domain.OAuth2Client{
+					ID:                  "preview-app",
+					RedirectURIs:        []string{"https://app.example.com/callback"},
+					RedirectURIPatterns: []string{"https://*.preview.example.com/callback"},
+				}, nil)
+			},
+			wantErr: nil,
+		},
+		{
+			name:        "wildcard pattern does not match nested subdomains",
+			clientID:    "preview-app",
+			redirectURI: "https://evil.pr-42.preview.example.com/callback",
+			setupMock: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "preview-app").Return(&domain.OAuth2Client{
+					ID:                  "preview-app",
+					RedirectURIs:        []string{"https://app.example.com/callback"},
+					RedirectURIPatterns: []string{"https://*.preview.example.com/callback"},
+				}, nil)
+			},
+			wantErr: domain.ErrInvalidRedirectURI,
+		},
 	}
 
 	for _, tt := range tests {
@@ -127,6 +204,47 @@ func TestOAuth2Service_ValidateClient(t *testing.T) {
 	}
 }
 
+func TestOAuth2Service_AuthenticateClient(t *testing.T) {
+	confidential := &domain.OAuth2Client{ID: "web-app", Secret: "s3cret"}
+	public := &domain.OAuth2Client{ID: "desktop-app", ApplicationType: domain.ApplicationTypeNative}
+
+	tests := []struct {
+		name     string
+		clientID string
+		secret   string
+		client   *domain.OAuth2Client
+		wantErr  error
+	}{
+		{name: "valid secret", clientID: "web-app", secret: "s3cret", client: confidential},
+		{name: "wrong secret", clientID: "web-app", secret: "wrong", client: confidential, wantErr: domain.ErrInvalidClient},
+		{name: "public client", clientID: "desktop-app", secret: "", client: public},
+		{name: "public client sending a secret", clientID: "desktop-app", secret: "s3cret", client: public, wantErr: domain.ErrInvalidClient},
+		{name: "unknown client", clientID: "unknown", secret: "s3cret", wantErr: domain.ErrInvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockOAuth2Repository)
+			if tt.client != nil {
+				mockRepo.On("FindClientByID", mock.Anything, tt.clientID).Return(tt.client, nil)
+			} else {
+				mockRepo.On("FindClientByID", mock.Anything, tt.clientID).Return(nil, domain.ErrClientNotFound)
+			}
+
+			service := NewOAuth2Service(mockRepo, zap.NewNop())
+			client, err := service.AuthenticateClient(context.Background(), tt.clientID, tt.secret)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, client)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.clientID, client.ID)
+			}
+		})
+	}
+}
+
 func TestOAuth2Service_GenerateAuthorizationCode(t *testing.T) {
 	tests := []struct {
 		name                string
@@ -198,6 +316,21 @@ func TestOAuth2Service_GenerateAuthorizationCode(t *testing.T) {
 	}
 }
 
+func TestOAuth2Service_GenerateAuthorizationCode_KeepsAuthentication(t *testing.T) {
+	auth := domain.NewAuthentication(domain.AMRPassword)
+	mockRepo := new(MockOAuth2Repository)
+	mockRepo.On("CreateAuthorizationCode", mock.Anything, mock.MatchedBy(func(code *domain.AuthorizationCode) bool {
+		return assert.ObjectsAreEqual(auth.Methods, code.AMR) && code.AuthTime != nil && code.AuthTime.Equal(auth.Time)
+	})).Return(nil)
+
+	service := NewOAuth2Service(mockRepo, zap.NewNop())
+	ctx := domain.WithAuthentication(context.Background(), auth)
+	_, err := service.GenerateAuthorizationCode(ctx, "test-client", "test-user", []string{"openid"}, "challenge", "S256")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestOAuth2Service_ValidateAuthorizationCode(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -269,18 +402,17 @@ func TestOAuth2Service_ValidateAuthorizationCode(t *testing.T) {
 			tt.setupMock(mockRepo)
 
 			service := NewOAuth2Service(mockRepo, zap.NewNop())
-			client, userID, scopes, err := service.ValidateAuthorizationCode(context.Background(), tt.code)
+			client, authCode, err := service.ValidateAuthorizationCode(context.Background(), tt.code)
 
 			if tt.wantErr != nil {
 				assert.ErrorIs(t, err, tt.wantErr)
 				assert.Nil(t, client)
-				assert.Empty(t, userID)
-				assert.Nil(t, scopes)
+				assert.Nil(t, authCode)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.wantClient, client)
-				assert.Equal(t, tt.wantUserID, userID)
-				assert.Equal(t, tt.wantScopes, scopes)
+				assert.Equal(t, tt.wantUserID, authCode.UserID)
+				assert.Equal(t, tt.wantScopes, authCode.Scopes)
 			}
 
 			mockRepo.AssertExpectations(t)
@@ -310,6 +442,13 @@ func TestOAuth2Service_ValidatePKCE(t *testing.T) {
 			codeChallengeMethod: "plain",
 			wantErr:             nil,
 		},
+		{
+			name:                "method defaults to plain",
+			codeVerifier:        "verifier",
+			codeChallenge:       "verifier",
+			codeChallengeMethod: "",
+			wantErr:             nil,
+		},
 		{
 			name:                "invalid method",
 			codeVerifier:        "verifier",
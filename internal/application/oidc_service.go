@@ -2,6 +2,8 @@ package application
 
 import (
 	"context"
+	"encoding/json"
+	"sort"
 	"strings"
 
 	"github.com/manorfm/authM/internal/domain"
@@ -11,26 +13,34 @@ import (
 )
 
 type OIDCService struct {
-	oauth2Service domain.OAuth2Service
-	jwtService    domain.JWTService
-	userRepo      domain.UserRepository
-	totpService   domain.TOTPService
-	config        *config.Config
-	logger        *zap.Logger
+	oauth2Service  domain.OAuth2Service
+	jwtService     domain.JWTService
+	userRepo       domain.UserRepository
+	scopeService   domain.ScopeService
+	consentService domain.ConsentService
+	pairwiseRepo   domain.PairwiseSubjectRepository
+	mfaPolicy      domain.MFAPolicyService
+	encrypter      domain.TokenEncrypter
+	config         *config.Config
+	logger         *zap.Logger
 }
 
-func NewOIDCService(oauth2Service domain.OAuth2Service, jwtService domain.JWTService, userRepo domain.UserRepository, totpService domain.TOTPService, config *config.Config, logger *zap.Logger) *OIDCService {
+func NewOIDCService(oauth2Service domain.OAuth2Service, jwtService domain.JWTService, userRepo domain.UserRepository, scopeService domain.ScopeService, consentService domain.ConsentService, pairwiseRepo domain.PairwiseSubjectRepository, mfaPolicy domain.MFAPolicyService, encrypter domain.TokenEncrypter, config *config.Config, logger *zap.Logger) *OIDCService {
 	return &OIDCService{
-		oauth2Service: oauth2Service,
-		jwtService:    jwtService,
-		userRepo:      userRepo,
-		totpService:   totpService,
-		config:        config,
-		logger:        logger,
+		oauth2Service:  oauth2Service,
+		jwtService:     jwtService,
+		userRepo:       userRepo,
+		scopeService:   scopeService,
+		consentService: consentService,
+		pairwiseRepo:   pairwiseRepo,
+		mfaPolicy:      mfaPolicy,
+		encrypter:      encrypter,
+		config:         config,
+		logger:         logger,
 	}
 }
 
-func (s *OIDCService) GetUserInfo(ctx context.Context, userID string) (*domain.UserInfo, error) {
+func (s *OIDCService) GetUserInfo(ctx context.Context, userID string) (domain.UserInfo, error) {
 	s.logger.Debug("Getting user info",
 		zap.String("user_id", userID))
 
@@ -52,20 +62,66 @@ func (s *OIDCService) GetUserInfo(ctx context.Context, userID string) (*domain.U
 		return nil, domain.ErrUserNotFound
 	}
 
-	amr := []string{"pwd"}
-	secret, err := s.totpService.GetTOTPSecret(context.Background(), user.ID.String())
-	if err == nil && secret != "" {
-		amr = append(amr, "totp")
+	// Tokens issued without a scope, such as first-party logins, get the default scopes
+	scopes, ok := domain.GetScopes(ctx)
+	if !ok || len(scopes) == 0 {
+		scopes = domain.DefaultScopes
 	}
 
-	// Return user info
-	return &domain.UserInfo{
-		Sub:           user.ID.String(),
-		Name:          user.Name,
-		Email:         user.Email,
-		EmailVerified: true,
-		AMR:           amr,
-	}, nil
+	// Claims requested individually through the claims parameter
+	requested := make(map[string]*domain.ClaimRequest)
+	if names, ok := domain.GetUserInfoClaims(ctx); ok {
+		for _, name := range names {
+			requested[name] = nil
+		}
+	}
+
+	claims, err := s.scopeService.ResolveClaims(ctx, scopes, requested)
+	if err != nil {
+		s.logger.Error("Failed to resolve claims",
+			zap.Strings("scopes", scopes),
+			zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	// Tokens issued to a client carry the subject identifier of that client
+	subject := user.ID.String()
+	if clientID, ok := domain.GetClientID(ctx); ok && clientID != "" {
+		client, err := s.oauth2Service.GetClient(ctx, clientID)
+		if err != nil {
+			return nil, err
+		}
+		if subject, err = s.SubjectFor(client, user.ID.String()); err != nil {
+			return nil, err
+		}
+	}
+
+	return userClaims(subject, user, claims), nil
+}
+
+func (s *OIDCService) SignUserInfo(ctx context.Context, userInfo domain.UserInfo) (string, error) {
+	clientID, ok := domain.GetClientID(ctx)
+	if !ok || clientID == "" {
+		return "", nil
+	}
+
+	client, err := s.oauth2Service.GetClient(ctx, clientID)
+	if err != nil {
+		return "", err
+	}
+	if !client.WantsSignedUserInfo() {
+		return "", nil
+	}
+
+	token, err := s.jwtService.SignUserInfo(client.ID, userInfo)
+	if err != nil {
+		s.logger.Error("Failed to sign userinfo",
+			zap.String("client_id", client.ID),
+			zap.Error(err))
+		return "", err
+	}
+
+	return token, nil
 }
 
 func (s *OIDCService) GetOpenIDConfiguration(ctx context.Context) (map[string]interface{}, error) {
@@ -76,30 +132,76 @@ func (s *OIDCService) GetOpenIDConfiguration(ctx context.Context) (map[string]in
 		return nil, domain.ErrInternal
 	}
 
+	scopes, err := s.scopeService.ListScopes(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list scopes",
+			zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	scopesSupported := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopesSupported = append(scopesSupported, scope.Name)
+	}
+
 	return map[string]interface{}{
-		"issuer":                                s.config.ServerURL,
-		"authorization_endpoint":                s.config.ServerURL + "/oauth2/authorize",
-		"token_endpoint":                        s.config.ServerURL + "/oauth2/token",
-		"userinfo_endpoint":                     s.config.ServerURL + "/oauth2/userinfo",
-		"jwks_uri":                              s.config.ServerURL + "/.well-known/jwks.json",
-		"response_types_supported":              []string{"code", "token", "id_token"},
-		"subject_types_supported":               []string{"public"},
-		"id_token_signing_alg_values_supported": []string{"RS256"},
-		"scopes_supported":                      []string{"openid", "profile", "email"},
-		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
-		"claims_supported":                      []string{"sub", "iss", "name", "email"},
+		"issuer":                                     s.config.ServerURL,
+		"authorization_endpoint":                     s.config.ServerURL + "/oauth2/authorize",
+		"token_endpoint":                             s.config.ServerURL + "/oauth2/token",
+		"userinfo_endpoint":                          s.config.ServerURL + "/oauth2/userinfo",
+		"jwks_uri":                                   s.config.ServerURL + "/.well-known/jwks.json",
+		"response_types_supported":                   []string{"code", "token", "id_token"},
+		"subject_types_supported":                    []string{domain.SubjectTypePublic, domain.SubjectTypePairwise},
+		"id_token_signing_alg_values_supported":      []string{"RS256"},
+		"scopes_supported":                           scopesSupported,
+		"token_endpoint_auth_methods_supported":      []string{"client_secret_basic", "client_secret_post"},
+		"introspection_endpoint":                     s.config.ServerURL + "/oauth2/introspect",
+		"claims_supported":                           domain.SupportedClaims,
+		"claims_parameter_supported":                 true,
+		"acr_values_supported":                       domain.SupportedACRValues,
+		"userinfo_signing_alg_values_supported":      []string{domain.SigningAlgRS256},
+		"id_token_encryption_alg_values_supported":   domain.SupportedEncryptionAlgs,
+		"id_token_encryption_enc_values_supported":   domain.SupportedEncryptionEncs,
+		"backchannel_authentication_endpoint":        s.config.ServerURL + "/oauth2/bc-authorize",
+		"backchannel_token_delivery_modes_supported": domain.SupportedBackchannelTokenDeliveryModes,
+		"backchannel_user_code_parameter_supported":  false,
 	}, nil
 }
 
-func (s *OIDCService) ExchangeCode(ctx context.Context, code string, codeVerifier string) (*domain.TokenPair, error) {
+func (s *OIDCService) ExchangeCode(ctx context.Context, clientID, clientSecret, code, codeVerifier string) (*domain.TokenPair, error) {
 	s.logger.Debug("Exchanging authorization code",
+		zap.String("client_id", clientID),
 		zap.String("code", code))
 
+	authenticated, err := s.oauth2Service.AuthenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get authorization code from repository
-	client, userID, scopes, err := s.oauth2Service.ValidateAuthorizationCode(ctx, code)
+	client, authCode, err := s.oauth2Service.ValidateAuthorizationCode(ctx, code)
 	if err != nil {
 		return nil, err
 	}
+	if client.ID != authenticated.ID {
+		s.logger.Error("Authorization code issued to another client",
+			zap.String("client_id", authenticated.ID),
+			zap.String("code_client_id", client.ID))
+		return nil, domain.ErrInvalidAuthorizationCode
+	}
+
+	// The verifier proves the caller started the authorization request
+	if authCode.CodeChallenge == "" {
+		s.logger.Error("Authorization code was issued without a code challenge",
+			zap.String("client_id", client.ID))
+		return nil, domain.ErrInvalidPKCE
+	}
+	if err := s.oauth2Service.ValidatePKCE(ctx, codeVerifier, authCode.CodeChallenge, authCode.CodeChallengeMethod); err != nil {
+		return nil, err
+	}
+
+	userID := authCode.UserID
+	scopes := authCode.Scopes
 
 	// Parse user ID
 	id, err := ulid.Parse(userID)
@@ -119,12 +221,24 @@ func (s *OIDCService) ExchangeCode(ctx context.Context, code string, codeVerifie
 		return nil, domain.ErrUserNotFound
 	}
 
-	// Generate token pair with scopes
-	tokenPair, err := s.jwtService.GenerateTokenPair(user.ID, user.Roles)
+	// Restore the claims request made at the authorization endpoint
+	claimsRequest := &domain.ClaimsRequest{}
+	if authCode.ClaimsRequest != "" {
+		if err := json.Unmarshal([]byte(authCode.ClaimsRequest), claimsRequest); err != nil {
+			s.logger.Error("Invalid claims request in authorization code",
+				zap.Error(err))
+			return nil, domain.ErrInvalidClaimsRequest
+		}
+	}
+
+	var auth *domain.Authentication
+	if authCode.AuthTime != nil {
+		auth = &domain.Authentication{Methods: authCode.AMR, Time: *authCode.AuthTime}
+	}
+
+	tokenPair, err := s.IssueTokens(ctx, client, user, scopes, claimsRequest, auth)
 	if err != nil {
-		s.logger.Error("Failed to generate token pair",
-			zap.Error(err))
-		return nil, domain.ErrFailedGenerateToken
+		return nil, err
 	}
 
 	// Log successful exchange
@@ -136,8 +250,64 @@ func (s *OIDCService) ExchangeCode(ctx context.Context, code string, codeVerifie
 	return tokenPair, nil
 }
 
-func (s *OIDCService) RefreshToken(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
-	s.logger.Debug("Refreshing token")
+// IssueTokens issues the tokens of a grant of the scopes to the client: an access and refresh token
+// carrying the subject the client knows the user by, and an ID token with the claims the scopes and
+// the claims request release when openid is granted. auth records the sign-in, when known.
+func (s *OIDCService) IssueTokens(ctx context.Context, client *domain.OAuth2Client, user *domain.User, scopes []string, claimsRequest *domain.ClaimsRequest, auth *domain.Authentication) (*domain.TokenPair, error) {
+	if claimsRequest == nil {
+		claimsRequest = &domain.ClaimsRequest{}
+	}
+
+	subject, err := s.IssueSubject(ctx, client, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &domain.TokenParams{
+		ClientID:       client.ID,
+		Scopes:         scopes,
+		UserInfoClaims: supportedClaimNames(claimsRequest.UserInfo),
+		Subject:        subject,
+		Authentication: auth,
+	}
+
+	// Issue an ID token carrying the claims the granted scopes map to
+	if containsScope(scopes, "openid") {
+		claims, err := s.scopeService.ResolveClaims(ctx, scopes, claimsRequest.IDToken)
+		if err != nil {
+			s.logger.Error("Failed to resolve ID token claims",
+				zap.Strings("scopes", scopes),
+				zap.Error(err))
+			return nil, domain.ErrInternal
+		}
+		params.IDTokenClaims = userClaims(subject, user, claims)
+	}
+
+	// Generate token pair with scopes
+	tokenPair, err := s.jwtService.GenerateTokenPairWithParams(user.ID, user.Roles, params)
+	if err != nil {
+		s.logger.Error("Failed to generate token pair",
+			zap.Error(err))
+		return nil, domain.ErrFailedGenerateToken
+	}
+
+	// Encrypt the ID token to the client's keys when it asked for it
+	if tokenPair.IDToken != "" && client.WantsEncryptedIDToken() {
+		tokenPair.IDToken, err = s.encrypter.Encrypt(ctx, client.JWKSURI, client.IDTokenEncryptedResponseAlg, client.IDTokenEncryptionEnc(), tokenPair.IDToken)
+		if err != nil {
+			s.logger.Error("Failed to encrypt ID token",
+				zap.String("client_id", client.ID),
+				zap.Error(err))
+			return nil, err
+		}
+	}
+
+	return tokenPair, nil
+}
+
+func (s *OIDCService) RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*domain.TokenPair, error) {
+	s.logger.Debug("Refreshing token",
+		zap.String("client_id", clientID))
 
 	// Validate refresh token
 	claims, err := s.jwtService.ValidateToken(refreshToken)
@@ -146,12 +316,38 @@ func (s *OIDCService) RefreshToken(ctx context.Context, refreshToken string) (*d
 			zap.Error(err))
 		return nil, domain.ErrInvalidCredentials
 	}
+	// Access and ID tokens and signed userinfo are signed with the same key but are not refresh tokens
+	if !claims.IsRefreshToken() {
+		s.logger.Warn("Token presented as refresh token is not one",
+			zap.String("client_id", clientID),
+			zap.String("typ", claims.Type))
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	// Tokens of a client are only refreshed for that client; first-party tokens carry no client
+	if claims.ClientID != clientID {
+		s.logger.Warn("Refresh token issued to another client",
+			zap.String("client_id", clientID),
+			zap.String("token_client_id", claims.ClientID))
+		return nil, domain.ErrInvalidCredentials
+	}
+	if clientID != "" {
+		if _, err := s.oauth2Service.AuthenticateClient(ctx, clientID, clientSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	// Map the subject, which may be pairwise, back to the user
+	subject, err := s.ResolveSubject(ctx, claims.ClientID, claims.RegisteredClaims.Subject)
+	if err != nil {
+		return nil, domain.ErrInvalidCredentials
+	}
 
 	// Parse user ID
-	userID, err := ulid.Parse(claims.RegisteredClaims.Subject)
+	userID, err := ulid.Parse(subject)
 	if err != nil {
 		s.logger.Error("Invalid user ID in refresh token",
-			zap.String("user_id", claims.RegisteredClaims.Subject),
+			zap.String("user_id", subject),
 			zap.Error(err))
 		return nil, domain.ErrInvalidUserID
 	}
@@ -160,13 +356,26 @@ func (s *OIDCService) RefreshToken(ctx context.Context, refreshToken string) (*d
 	user, err := s.userRepo.FindByID(ctx, userID)
 	if err != nil {
 		s.logger.Error("Failed to find user",
-			zap.String("user_id", claims.RegisteredClaims.Subject),
+			zap.String("user_id", subject),
 			zap.Error(err))
 		return nil, domain.ErrInvalidCredentials
 	}
 
-	// Generate new token pair
-	tokenPair, err := s.jwtService.GenerateTokenPair(user.ID, user.Roles)
+	// Sessions revoked after the refresh token was issued, e.g. by a password change, cannot be refreshed
+	if user.SessionsRevokedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(*user.SessionsRevokedAt)) {
+		s.logger.Warn("Refresh token issued before sessions were revoked",
+			zap.String("user_id", user.ID.String()))
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	// Generate new token pair keeping the original grant and sign-in
+	tokenPair, err := s.jwtService.GenerateTokenPairWithParams(user.ID, user.Roles, &domain.TokenParams{
+		ClientID:       claims.ClientID,
+		Scopes:         claims.Scopes(),
+		UserInfoClaims: claims.UserInfoClaims,
+		Subject:        claims.RegisteredClaims.Subject,
+		Authentication: claims.Authentication(),
+	})
 	if err != nil {
 		s.logger.Error("Failed to generate token pair",
 			zap.Error(err))
@@ -208,17 +417,8 @@ func (s *OIDCService) Authorize(ctx context.Context, clientID, redirectURI, stat
 	}
 
 	// Validate that all requested scopes are allowed for this client
-	validScopes := make([]string, 0)
 	for _, requestedScope := range requestedScopes {
-		valid := false
-		for _, allowedScope := range client.Scopes {
-			if requestedScope == allowedScope {
-				valid = true
-				validScopes = append(validScopes, requestedScope)
-				break
-			}
-		}
-		if !valid {
+		if !containsScope(client.Scopes, requestedScope) {
 			s.logger.Error("Invalid scope requested",
 				zap.String("scope", requestedScope),
 				zap.Strings("allowed_scopes", client.Scopes))
@@ -226,11 +426,235 @@ func (s *OIDCService) Authorize(ctx context.Context, clientID, redirectURI, stat
 		}
 	}
 
+	// Validate that the requested scopes are registered and may be granted to this client
+	if err := s.scopeService.ValidateScopes(ctx, client.ID, requestedScopes); err != nil {
+		return "", err
+	}
+
+	// Scopes registered as requiring consent need the user's consent for this client
+	if err := s.consentService.CheckConsent(ctx, userID, client.ID, requestedScopes); err != nil {
+		return "", err
+	}
+
+	// Clients and scopes the MFA policy covers need a user with a second factor
+	if err := s.mfaPolicy.CheckAuthorization(ctx, userID, client.ID, requestedScopes); err != nil {
+		return "", err
+	}
+
 	// Generate authorization code
-	code, err := s.oauth2Service.GenerateAuthorizationCode(ctx, client.ID, userID, validScopes, codeChallenge, codeChallengeMethod)
+	code, err := s.oauth2Service.GenerateAuthorizationCode(ctx, client.ID, userID, requestedScopes, codeChallenge, codeChallengeMethod)
 	if err != nil {
 		return "", err
 	}
 
 	return code, nil
 }
+
+// SubjectFor returns the subject identifier the client knows the user by: the user ID for public
+// clients, or an identifier derived from the client's sector and the server salt for pairwise clients.
+// Every endpoint that reveals a subject to a client must go through it.
+func (s *OIDCService) SubjectFor(client *domain.OAuth2Client, userID string) (string, error) {
+	if !client.IsPairwise() {
+		return userID, nil
+	}
+
+	if s.config.PairwiseSubjectSalt == "" {
+		s.logger.Error("Pairwise subject salt is not configured",
+			zap.String("client_id", client.ID))
+		return "", domain.ErrInternal
+	}
+
+	sector, err := client.SectorIdentifier()
+	if err != nil {
+		s.logger.Error("Failed to derive sector identifier",
+			zap.String("client_id", client.ID),
+			zap.Error(err))
+		return "", err
+	}
+
+	return domain.PairwiseSubject(sector, userID, s.config.PairwiseSubjectSalt), nil
+}
+
+// IssueSubject returns the subject identifier tokens issued to the client carry for the user. Pairwise
+// identifiers are recorded so that ResolveSubject can map them back to the user.
+func (s *OIDCService) IssueSubject(ctx context.Context, client *domain.OAuth2Client, userID ulid.ULID) (string, error) {
+	subject, err := s.SubjectFor(client, userID.String())
+	if err != nil || !client.IsPairwise() {
+		return subject, err
+	}
+
+	sector, err := client.SectorIdentifier()
+	if err != nil {
+		return "", err
+	}
+	if err := s.pairwiseRepo.Save(ctx, sector, subject, userID); err != nil {
+		return "", err
+	}
+
+	return subject, nil
+}
+
+// ResolveSubject returns the user ID the subject of a token issued to the client stands for. Tokens
+// issued without a client, or to a client receiving public identifiers, carry the user ID itself.
+func (s *OIDCService) ResolveSubject(ctx context.Context, clientID, subject string) (string, error) {
+	if clientID == "" {
+		return subject, nil
+	}
+
+	client, err := s.oauth2Service.GetClient(ctx, clientID)
+	if err != nil {
+		return "", domain.ErrInvalidToken
+	}
+	if !client.IsPairwise() {
+		return subject, nil
+	}
+
+	sector, err := client.SectorIdentifier()
+	if err != nil {
+		s.logger.Error("Failed to derive sector identifier",
+			zap.String("client_id", client.ID),
+			zap.Error(err))
+		return "", domain.ErrInvalidToken
+	}
+
+	userID, err := s.pairwiseRepo.FindUserID(ctx, sector, subject)
+	if err != nil {
+		if err == domain.ErrPairwiseSubjectNotFound {
+			s.logger.Warn("Unknown pairwise subject",
+				zap.String("client_id", client.ID))
+			return "", domain.ErrInvalidToken
+		}
+		return "", err
+	}
+
+	return userID.String(), nil
+}
+
+// IntrospectToken reports whether an access or refresh token issued to the authenticated client is
+// active, as described in RFC 7662. Tokens of other clients are reported inactive, so that a client
+// cannot learn the subject other clients know the user by.
+func (s *OIDCService) IntrospectToken(ctx context.Context, clientID, clientSecret, token string) (*domain.TokenIntrospection, error) {
+	client, err := s.oauth2Service.AuthenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	// Public clients cannot prove who is asking, so they may not introspect tokens
+	if !client.IsConfidential() {
+		s.logger.Error("Public client attempted token introspection",
+			zap.String("client_id", client.ID))
+		return nil, domain.ErrInvalidClient
+	}
+
+	inactive := &domain.TokenIntrospection{Active: false}
+
+	claims, err := s.jwtService.ValidateToken(token)
+	if err != nil {
+		s.logger.Debug("Introspected token is not valid",
+			zap.String("client_id", client.ID),
+			zap.Error(err))
+		return inactive, nil
+	}
+	// Only access and refresh tokens are introspected; ID tokens and signed userinfo are not grants
+	if !claims.IsAccessToken() && !claims.IsRefreshToken() {
+		return inactive, nil
+	}
+
+	if claims.ClientID != client.ID {
+		s.logger.Warn("Client introspected a token issued to another client",
+			zap.String("client_id", client.ID),
+			zap.String("token_client_id", claims.ClientID))
+		return inactive, nil
+	}
+
+	subject, err := s.ResolveSubject(ctx, claims.ClientID, claims.Subject)
+	if err != nil {
+		return inactive, nil
+	}
+	userID, err := ulid.Parse(subject)
+	if err != nil {
+		return inactive, nil
+	}
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return inactive, nil
+	}
+
+	// Tokens issued before the user's sessions were revoked are no longer active
+	if user.SessionsRevokedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(*user.SessionsRevokedAt)) {
+		return inactive, nil
+	}
+
+	introspection := &domain.TokenIntrospection{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Subject:   claims.Subject,
+		TokenType: domain.TokenTypeHintRefreshToken,
+		TokenID:   claims.ID,
+		ACR:       claims.ACR,
+	}
+	if claims.IsAccessToken() {
+		introspection.TokenType = domain.TokenTypeHintAccessToken
+	}
+	if claims.ExpiresAt != nil {
+		introspection.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		introspection.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.AuthTime != nil {
+		introspection.AuthTime = claims.AuthTime.Unix()
+	}
+
+	return introspection, nil
+}
+
+// userClaims maps the given claim names to the user's values
+func userClaims(subject string, user *domain.User, claims []string) domain.UserInfo {
+	info := domain.UserInfo{
+		domain.ClaimSubject: subject,
+	}
+
+	for _, claim := range claims {
+		switch claim {
+		case domain.ClaimName:
+			info[claim] = user.Name
+		case domain.ClaimEmail:
+			info[claim] = user.Email
+		case domain.ClaimEmailVerified:
+			info[claim] = user.EmailVerified
+		case domain.ClaimPhoneNumber:
+			info[claim] = user.Phone
+		case domain.ClaimPhoneNumberVerified:
+			info[claim] = user.PhoneVerified
+		case domain.ClaimRoles:
+			info[claim] = user.Roles
+		case domain.ClaimUpdatedAt:
+			info[claim] = user.UpdatedAt.Unix()
+		}
+	}
+
+	return info
+}
+
+// supportedClaimNames returns the sorted names of the requested claims the service can release
+func supportedClaimNames(requested map[string]*domain.ClaimRequest) []string {
+	names := make([]string, 0, len(requested))
+	for name := range requested {
+		if domain.IsSupportedClaim(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containsScope reports whether scope is present in scopes
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
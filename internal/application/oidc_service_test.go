@@ -34,12 +34,28 @@ func (m *mockOAuth2Service) GenerateAuthorizationCode(ctx context.Context, clien
 	return args.String(0), args.Error(1)
 }
 
-func (m *mockOAuth2Service) ValidateAuthorizationCode(ctx context.Context, code string) (*domain.OAuth2Client, string, []string, error) {
+func (m *mockOAuth2Service) GetClient(ctx context.Context, clientID string) (*domain.OAuth2Client, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OAuth2Client), args.Error(1)
+}
+
+func (m *mockOAuth2Service) AuthenticateClient(ctx context.Context, clientID, clientSecret string) (*domain.OAuth2Client, error) {
+	args := m.Called(ctx, clientID, clientSecret)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OAuth2Client), args.Error(1)
+}
+
+func (m *mockOAuth2Service) ValidateAuthorizationCode(ctx context.Context, code string) (*domain.OAuth2Client, *domain.AuthorizationCode, error) {
 	args := m.Called(ctx, code)
 	if args.Get(0) == nil {
-		return nil, "", nil, args.Error(3)
+		return nil, nil, args.Error(2)
 	}
-	return args.Get(0).(*domain.OAuth2Client), args.String(1), args.Get(2).([]string), args.Error(3)
+	return args.Get(0).(*domain.OAuth2Client), args.Get(1).(*domain.AuthorizationCode), args.Error(2)
 }
 
 func (m *mockOAuth2Service) ValidatePKCE(ctx context.Context, codeVerifier, codeChallenge, codeChallengeMethod string) error {
@@ -115,6 +131,21 @@ func (m *mockUserRepository) UpdatePassword(ctx context.Context, userID ulid.ULI
 	return args.Error(0)
 }
 
+func (m *mockUserRepository) UpdateEmail(ctx context.Context, userID ulid.ULID, email string) error {
+	args := m.Called(ctx, userID, email)
+	return args.Error(0)
+}
+
+func (m *mockUserRepository) UpdatePhone(ctx context.Context, userID ulid.ULID, phone string) error {
+	args := m.Called(ctx, userID, phone)
+	return args.Error(0)
+}
+
+func (m *mockUserRepository) RevokeSessions(ctx context.Context, userID ulid.ULID, before time.Time) error {
+	args := m.Called(ctx, userID, before)
+	return args.Error(0)
+}
+
 // Mock JWT para simular fluxo de refresh token
 type mockJWTRefresh struct{}
 
@@ -124,6 +155,7 @@ func (m *mockJWTRefresh) ValidateToken(token string) (*domain.Claims, error) {
 			Subject: "01ARZ3NDEKTSV4RRFFQ69G5FAV",
 		},
 		Roles: []string{"user"},
+		Type:  domain.TokenTypeRefresh,
 	}, nil
 }
 
@@ -140,6 +172,14 @@ func (m *mockJWTRefresh) GenerateTokenPair(userID ulid.ULID, roles []string) (*d
 	}, nil
 }
 
+func (m *mockJWTRefresh) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func (m *mockJWTRefresh) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "", nil
+}
+
 func (m *mockJWTRefresh) BlacklistToken(tokenID string, expiresAt time.Time) error {
 	return nil
 }
@@ -179,6 +219,14 @@ func (m *mockJWTError) GenerateTokenPair(userID ulid.ULID, roles []string) (*dom
 	return nil, nil
 }
 
+func (m *mockJWTError) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func (m *mockJWTError) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "", nil
+}
+
 func (m *mockJWTError) BlacklistToken(tokenID string, expiresAt time.Time) error {
 	return nil
 }
@@ -195,36 +243,6 @@ func (m *mockJWTError) TryVault() error {
 	return nil
 }
 
-// Mock TOTPService for testing
-type mockTOTPService struct {
-	mock.Mock
-}
-
-func (m *mockTOTPService) EnableTOTP(userID string) (*domain.TOTP, error) {
-	args := m.Called(userID)
-	return args.Get(0).(*domain.TOTP), args.Error(1)
-}
-
-func (m *mockTOTPService) VerifyTOTP(userID, code string) error {
-	args := m.Called(userID, code)
-	return args.Error(0)
-}
-
-func (m *mockTOTPService) VerifyBackupCode(userID, code string) error {
-	args := m.Called(userID, code)
-	return args.Error(0)
-}
-
-func (m *mockTOTPService) DisableTOTP(userID string) error {
-	args := m.Called(userID)
-	return args.Error(0)
-}
-
-func (m *mockTOTPService) GetTOTPSecret(ctx context.Context, userID string) (string, error) {
-	args := m.Called(ctx, userID)
-	return args.String(0), args.Error(1)
-}
-
 // Mock JWT para simular erro de parsing do userID
 type mockJWTInvalidUserID struct{}
 
@@ -234,6 +252,7 @@ func (m *mockJWTInvalidUserID) ValidateToken(token string) (*domain.Claims, erro
 			Subject: "invalid_user_id",
 		},
 		Roles: []string{"user"},
+		Type:  domain.TokenTypeRefresh,
 	}, nil
 }
 
@@ -249,6 +268,14 @@ func (m *mockJWTInvalidUserID) GenerateTokenPair(userID ulid.ULID, roles []strin
 	return nil, nil
 }
 
+func (m *mockJWTInvalidUserID) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func (m *mockJWTInvalidUserID) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "", nil
+}
+
 func (m *mockJWTInvalidUserID) BlacklistToken(tokenID string, expiresAt time.Time) error {
 	return nil
 }
@@ -274,6 +301,7 @@ func (m *mockJWTTokenGenError) ValidateToken(token string) (*domain.Claims, erro
 			Subject: "01ARZ3NDEKTSV4RRFFQ69G5FAV",
 		},
 		Roles: []string{"user"},
+		Type:  domain.TokenTypeRefresh,
 	}, nil
 }
 
@@ -289,6 +317,14 @@ func (m *mockJWTTokenGenError) GenerateTokenPair(userID ulid.ULID, roles []strin
 	return nil, domain.ErrInternal
 }
 
+func (m *mockJWTTokenGenError) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func (m *mockJWTTokenGenError) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "", nil
+}
+
 func (m *mockJWTTokenGenError) BlacklistToken(tokenID string, expiresAt time.Time) error {
 	return nil
 }
@@ -307,36 +343,86 @@ func (m *mockJWTTokenGenError) TryVault() error {
 
 func TestOIDCService_GetUserInfo(t *testing.T) {
 	userID := ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	user := &domain.User{
+		ID:            userID,
+		Name:          "Test User",
+		Email:         "test@example.com",
+		Phone:         "+5511999999999",
+		PhoneVerified: true,
+		Roles:         []string{"user"},
+		EmailVerified: true,
+	}
 	tests := []struct {
 		name          string
 		userID        ulid.ULID
-		mockSetup     func(*mockUserRepository, *mockTOTPService)
+		setupCtx      func(context.Context) context.Context
+		mockSetup     func(*mockUserRepository)
 		expectedError error
-		expectedInfo  *domain.UserInfo
+		expectedInfo  domain.UserInfo
 	}{
 		{
-			name:   "successful user info retrieval",
+			name:   "default scopes",
 			userID: userID,
-			mockSetup: func(m *mockUserRepository, t *mockTOTPService) {
-				m.On("FindByID", mock.Anything, userID).Return(&domain.User{
-					ID:    userID,
-					Name:  "Test User",
-					Email: "test@example.com",
-				}, nil)
-				t.On("GetTOTPSecret", mock.Anything, userID.String()).Return("", domain.ErrTOTPNotEnabled)
+			mockSetup: func(m *mockUserRepository) {
+				m.On("FindByID", mock.Anything, userID).Return(user, nil)
+			},
+			expectedInfo: domain.UserInfo{
+				"sub":            userID.String(),
+				"name":           "Test User",
+				"updated_at":     user.UpdatedAt.Unix(),
+				"email":          "test@example.com",
+				"email_verified": true,
+			},
+		},
+		{
+			name:   "granted scopes only",
+			userID: userID,
+			setupCtx: func(ctx context.Context) context.Context {
+				return domain.WithScopes(ctx, []string{"openid", "phone", "roles"})
+			},
+			mockSetup: func(m *mockUserRepository) {
+				m.On("FindByID", mock.Anything, userID).Return(user, nil)
+			},
+			expectedInfo: domain.UserInfo{
+				"sub":                   userID.String(),
+				"phone_number":          "+5511999999999",
+				"phone_number_verified": true,
+				"roles":                 []string{"user"},
+			},
+		},
+		{
+			name:   "individually requested claims",
+			userID: userID,
+			setupCtx: func(ctx context.Context) context.Context {
+				ctx = domain.WithScopes(ctx, []string{"openid"})
+				return domain.WithUserInfoClaims(ctx, []string{"email"})
+			},
+			mockSetup: func(m *mockUserRepository) {
+				m.On("FindByID", mock.Anything, userID).Return(user, nil)
 			},
-			expectedInfo: &domain.UserInfo{
-				Sub:           userID.String(),
-				Name:          "Test User",
-				Email:         "test@example.com",
-				EmailVerified: true,
-				AMR:           []string{"pwd"},
+			expectedInfo: domain.UserInfo{
+				"sub":   userID.String(),
+				"email": "test@example.com",
+			},
+		},
+		{
+			name:   "pairwise client",
+			userID: userID,
+			setupCtx: func(ctx context.Context) context.Context {
+				ctx = domain.WithScopes(ctx, []string{"openid"})
+				return domain.WithClientID(ctx, "pairwise-client")
+			},
+			mockSetup: func(m *mockUserRepository) {
+				m.On("FindByID", mock.Anything, userID).Return(user, nil)
+			},
+			expectedInfo: domain.UserInfo{
+				"sub": domain.PairwiseSubject("app.example.com", userID.String(), "test-salt"),
 			},
 		},
 		{
 			name:   "user not found",
 			userID: ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAW"),
-			mockSetup: func(m *mockUserRepository, t *mockTOTPService) {
+			mockSetup: func(m *mockUserRepository) {
 				m.On("FindByID", mock.Anything, ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAW")).Return(nil, domain.ErrUserNotFound)
 			},
 			expectedError: domain.ErrUserNotFound,
@@ -347,15 +433,24 @@ func TestOIDCService_GetUserInfo(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			mockUserRepo := new(mockUserRepository)
 			mockOAuth2Service := new(mockOAuth2Service)
-			mockTOTPService := new(mockTOTPService)
-			tt.mockSetup(mockUserRepo, mockTOTPService)
+			mockOAuth2Service.On("GetClient", mock.Anything, "pairwise-client").Return(&domain.OAuth2Client{
+				ID:           "pairwise-client",
+				RedirectURIs: []string{"https://app.example.com/callback"},
+				SubjectType:  domain.SubjectTypePairwise,
+			}, nil).Maybe()
+			tt.mockSetup(mockUserRepo)
 			cfg, err := config.LoadConfig(zap.NewNop())
 			if err != nil {
 				t.Fatalf("Failed to load config: %v", err)
 			}
-			service := NewOIDCService(mockOAuth2Service, nil, mockUserRepo, mockTOTPService, cfg, zap.NewNop())
+			cfg.PairwiseSubjectSalt = "test-salt"
+			service := NewOIDCService(mockOAuth2Service, nil, mockUserRepo, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, cfg, zap.NewNop())
 
-			info, err := service.GetUserInfo(context.Background(), tt.userID.String())
+			ctx := context.Background()
+			if tt.setupCtx != nil {
+				ctx = tt.setupCtx(ctx)
+			}
+			info, err := service.GetUserInfo(ctx, tt.userID.String())
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
@@ -367,11 +462,79 @@ func TestOIDCService_GetUserInfo(t *testing.T) {
 			}
 
 			mockUserRepo.AssertExpectations(t)
-			mockTOTPService.AssertExpectations(t)
 		})
 	}
 }
 
+func TestOIDCService_SubjectFor(t *testing.T) {
+	userID := "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+	pairwise := func(id string, redirectURIs []string, sectorURI string) *domain.OAuth2Client {
+		return &domain.OAuth2Client{
+			ID:                  id,
+			RedirectURIs:        redirectURIs,
+			SubjectType:         domain.SubjectTypePairwise,
+			SectorIdentifierURI: sectorURI,
+		}
+	}
+
+	cfg, err := config.LoadConfig(zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	cfg.PairwiseSubjectSalt = "test-salt"
+	service := NewOIDCService(nil, nil, nil, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, cfg, zap.NewNop())
+
+	subject := func(client *domain.OAuth2Client) string {
+		sub, err := service.SubjectFor(client, userID)
+		assert.NoError(t, err)
+		return sub
+	}
+
+	t.Run("public client gets the user ID", func(t *testing.T) {
+		assert.Equal(t, userID, subject(&domain.OAuth2Client{ID: "public", SubjectType: domain.SubjectTypePublic}))
+		assert.Equal(t, userID, subject(&domain.OAuth2Client{ID: "legacy"}))
+	})
+
+	t.Run("pairwise client gets a stable opaque identifier", func(t *testing.T) {
+		client := pairwise("a", []string{"https://a.example.com/callback"}, "")
+		sub := subject(client)
+		assert.NotEqual(t, userID, sub)
+		assert.Equal(t, sub, subject(client))
+	})
+
+	t.Run("clients of the same sector share the identifier", func(t *testing.T) {
+		a := pairwise("a", []string{"https://a.example.com/callback"}, "https://sector.example.com/uris.json")
+		b := pairwise("b", []string{"https://b.example.com/callback"}, "https://sector.example.com/uris.json")
+		assert.Equal(t, subject(a), subject(b))
+	})
+
+	t.Run("clients of different sectors cannot correlate", func(t *testing.T) {
+		a := pairwise("a", []string{"https://a.example.com/callback"}, "")
+		b := pairwise("b", []string{"https://b.example.com/callback"}, "")
+		assert.NotEqual(t, subject(a), subject(b))
+	})
+
+	t.Run("native clients on the loopback address do not share a sector", func(t *testing.T) {
+		a := pairwise("a", []string{"http://127.0.0.1/callback"}, "")
+		b := pairwise("b", []string{"http://127.0.0.1/callback"}, "")
+		assert.NotEqual(t, subject(a), subject(b))
+	})
+
+	t.Run("redirect URIs on several hosts need a sector identifier URI", func(t *testing.T) {
+		client := pairwise("a", []string{"https://a.example.com/callback", "https://b.example.com/callback"}, "")
+		_, err := service.SubjectFor(client, userID)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing salt", func(t *testing.T) {
+		noSalt := *cfg
+		noSalt.PairwiseSubjectSalt = ""
+		service := NewOIDCService(nil, nil, nil, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, &noSalt, zap.NewNop())
+		_, err := service.SubjectFor(pairwise("a", []string{"https://a.example.com/callback"}, ""), userID)
+		assert.ErrorIs(t, err, domain.ErrInternal)
+	})
+}
+
 func TestOIDCService_Authorize(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -469,6 +632,27 @@ func TestOIDCService_Authorize(t *testing.T) {
 			},
 			wantErr: domain.ErrInvalidScope,
 		},
+		{
+			name:        "scope not allowed for client by registry",
+			clientID:    "test-client",
+			redirectURI: "http://localhost:8080/callback",
+			state:       "state123",
+			scope:       "openid admin",
+			setupMocks: func(m *mockOAuth2Service) {
+				m.On("ValidateClient", mock.Anything, "test-client", "http://localhost:8080/callback").Return(
+					&domain.OAuth2Client{
+						ID:     "test-client",
+						Scopes: []string{"openid", "admin"},
+					},
+					nil,
+				)
+			},
+			setupCtx: func(ctx context.Context) context.Context {
+				ctx = domain.WithSubject(ctx, "01H1VEC8SYM3K9TSDAPFN25XZV")
+				return ctx
+			},
+			wantErr: domain.ErrInvalidScope,
+		},
 		{
 			name:        "code generation failed",
 			clientID:    "test-client",
@@ -505,14 +689,13 @@ func TestOIDCService_Authorize(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockOAuth2 := new(mockOAuth2Service)
-			mockTOTPService := new(mockTOTPService)
 			tt.setupMocks(mockOAuth2)
 
 			cfg, err := config.LoadConfig(zap.NewNop())
 			if err != nil {
 				t.Fatalf("Failed to load config: %v", err)
 			}
-			service := NewOIDCService(mockOAuth2, nil, nil, mockTOTPService, cfg, zap.NewNop())
+			service := NewOIDCService(mockOAuth2, nil, nil, newStandardScopeService(), newTestConsentService(new(mockConsentRepository), mockOAuth2), nil, newTestMFAPolicy(), nil, cfg, zap.NewNop())
 			code, err := service.Authorize(tt.setupCtx(context.Background()), tt.clientID, tt.redirectURI, tt.state, tt.scope)
 
 			if tt.wantErr != nil {
@@ -528,8 +711,76 @@ func TestOIDCService_Authorize(t *testing.T) {
 	}
 }
 
+func TestOIDCService_Authorize_MFAPolicy(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Roles: []string{"user"}, CreatedAt: time.Now().Add(-30 * 24 * time.Hour)}
+	ctx := domain.WithSubject(context.Background(), user.ID.String())
+
+	mockOAuth2 := new(mockOAuth2Service)
+	mockOAuth2.On("ValidateClient", mock.Anything, "payments-app", "http://localhost:8080/callback").Return(
+		&domain.OAuth2Client{
+			ID:     "payments-app",
+			Scopes: []string{"openid"},
+		},
+		nil,
+	)
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+	mfaSvc := new(mockMFAService)
+	mfaSvc.On("ListFactors", mock.Anything, user.ID.String()).Return([]*domain.MFAFactor{}, nil)
+
+	cfg, err := config.LoadConfig(zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	policy := NewMFAPolicyService(mfaSvc, userRepo, newMFAPolicyTestConfig(), zap.NewNop())
+	service := NewOIDCService(mockOAuth2, nil, nil, newStandardScopeService(), newTestConsentService(new(mockConsentRepository), mockOAuth2), nil, policy, nil, cfg, zap.NewNop())
+
+	code, err := service.Authorize(ctx, "payments-app", "http://localhost:8080/callback", "state123", "openid")
+
+	assert.ErrorIs(t, err, domain.ErrMFAEnrollmentRequired)
+	assert.Empty(t, code)
+	mockOAuth2.AssertNotCalled(t, "GenerateAuthorizationCode", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOIDCService_Authorize_Consent(t *testing.T) {
+	userID := ulid.Make()
+	ctx := domain.WithSubject(context.Background(), userID.String())
+
+	mockOAuth2 := new(mockOAuth2Service)
+	mockOAuth2.On("ValidateClient", mock.Anything, "shop", "http://localhost:8080/callback").Return(
+		&domain.OAuth2Client{
+			ID:     "shop",
+			Scopes: []string{"openid", "payments"},
+		},
+		nil,
+	)
+	consentRepo := new(mockConsentRepository)
+	consentRepo.On("Find", mock.Anything, userID, "shop").Return(nil, domain.ErrConsentNotFound)
+
+	service := NewOIDCService(mockOAuth2, nil, nil, newStandardScopeService(), newTestConsentService(consentRepo, mockOAuth2), nil, newTestMFAPolicy(), nil, &config.Config{}, zap.NewNop())
+
+	code, err := service.Authorize(ctx, "shop", "http://localhost:8080/callback", "state123", "openid payments")
+
+	assert.ErrorIs(t, err, domain.ErrConsentRequired)
+	assert.Empty(t, code)
+	mockOAuth2.AssertNotCalled(t, "GenerateAuthorizationCode", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// expectCodeExchange makes the OAuth2 service authenticate the client with "secret" and redeem
+// "valid_code" for the authorization code, whose PKCE challenge the "verifier" satisfies
+func expectCodeExchange(m *mockOAuth2Service, client *domain.OAuth2Client, authCode *domain.AuthorizationCode) {
+	if authCode.CodeChallenge == "" {
+		authCode.CodeChallenge = "challenge"
+		authCode.CodeChallengeMethod = "S256"
+	}
+	m.On("AuthenticateClient", mock.Anything, client.ID, "secret").Return(client, nil)
+	m.On("ValidateAuthorizationCode", mock.Anything, "valid_code").Return(client, authCode, nil)
+	m.On("ValidatePKCE", mock.Anything, "verifier", authCode.CodeChallenge, authCode.CodeChallengeMethod).Return(nil)
+}
+
 func TestOIDCService_ExchangeCode(t *testing.T) {
 	logger := zap.NewNop()
+	client := &domain.OAuth2Client{ID: "client123", Secret: "secret"}
 	tests := []struct {
 		name          string
 		code          string
@@ -541,11 +792,12 @@ func TestOIDCService_ExchangeCode(t *testing.T) {
 		{
 			name:         "successful code exchange",
 			code:         "valid_code",
-			codeVerifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk",
+			codeVerifier: "verifier",
 			mockSetup: func(m *mockOAuth2Service) {
-				m.On("ValidateAuthorizationCode", mock.Anything, "valid_code").Return(&domain.OAuth2Client{
-					ID: "client123",
-				}, "01ARZ3NDEKTSV4RRFFQ69G5FAV", []string{"openid", "profile", "email"}, nil)
+				expectCodeExchange(m, client, &domain.AuthorizationCode{
+					UserID: "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+					Scopes: []string{"openid", "profile", "email"},
+				})
 			},
 			expectedToken: &domain.TokenPair{
 				AccessToken:  "mock_access_token",
@@ -557,10 +809,60 @@ func TestOIDCService_ExchangeCode(t *testing.T) {
 			code:         "invalid_code",
 			codeVerifier: "verifier",
 			mockSetup: func(m *mockOAuth2Service) {
-				m.On("ValidateAuthorizationCode", mock.Anything, "invalid_code").Return(nil, "", nil, domain.ErrInvalidAuthorizationCode)
+				m.On("AuthenticateClient", mock.Anything, "client123", "secret").Return(client, nil)
+				m.On("ValidateAuthorizationCode", mock.Anything, "invalid_code").Return(nil, nil, domain.ErrInvalidAuthorizationCode)
+			},
+			expectedError: domain.ErrInvalidAuthorizationCode,
+		},
+		{
+			name:         "invalid client credentials",
+			code:         "valid_code",
+			codeVerifier: "verifier",
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("AuthenticateClient", mock.Anything, "client123", "secret").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedError: domain.ErrInvalidClient,
+		},
+		{
+			name:         "code issued to another client",
+			code:         "valid_code",
+			codeVerifier: "verifier",
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("AuthenticateClient", mock.Anything, "client123", "secret").Return(client, nil)
+				m.On("ValidateAuthorizationCode", mock.Anything, "valid_code").Return(&domain.OAuth2Client{ID: "other-client"}, &domain.AuthorizationCode{
+					UserID:        "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+					CodeChallenge: "challenge",
+				}, nil)
 			},
 			expectedError: domain.ErrInvalidAuthorizationCode,
 		},
+		{
+			name:         "code issued without a code challenge",
+			code:         "valid_code",
+			codeVerifier: "verifier",
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("AuthenticateClient", mock.Anything, "client123", "secret").Return(client, nil)
+				m.On("ValidateAuthorizationCode", mock.Anything, "valid_code").Return(client, &domain.AuthorizationCode{
+					UserID: "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+				}, nil)
+			},
+			expectedError: domain.ErrInvalidPKCE,
+		},
+		{
+			name:         "wrong code verifier",
+			code:         "valid_code",
+			codeVerifier: "guessed",
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("AuthenticateClient", mock.Anything, "client123", "secret").Return(client, nil)
+				m.On("ValidateAuthorizationCode", mock.Anything, "valid_code").Return(client, &domain.AuthorizationCode{
+					UserID:              "01ARZ3NDEKTSV4RRFFQ69G5FAV",
+					CodeChallenge:       "challenge",
+					CodeChallengeMethod: "S256",
+				}, nil)
+				m.On("ValidatePKCE", mock.Anything, "guessed", "challenge", "S256").Return(domain.ErrInvalidCodeChallenge)
+			},
+			expectedError: domain.ErrInvalidCodeChallenge,
+		},
 	}
 
 	for _, tt := range tests {
@@ -568,7 +870,6 @@ func TestOIDCService_ExchangeCode(t *testing.T) {
 			mockOAuth2Service := new(mockOAuth2Service)
 			mockUserRepo := new(mockUserRepository)
 			mockJWT := &mockJWTRefresh{}
-			mockTOTPService := new(mockTOTPService)
 
 			tt.mockSetup(mockOAuth2Service)
 			if tt.name == "successful code exchange" {
@@ -584,9 +885,9 @@ func TestOIDCService_ExchangeCode(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Failed to load config: %v", err)
 			}
-			service := NewOIDCService(mockOAuth2Service, mockJWT, mockUserRepo, mockTOTPService, cfg, logger)
+			service := NewOIDCService(mockOAuth2Service, mockJWT, mockUserRepo, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, cfg, logger)
 
-			token, err := service.ExchangeCode(context.Background(), tt.code, tt.codeVerifier)
+			token, err := service.ExchangeCode(context.Background(), "client123", "secret", tt.code, tt.codeVerifier)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
@@ -617,17 +918,26 @@ func TestOIDCService_GetOpenIDConfiguration(t *testing.T) {
 				// No mock setup needed
 			},
 			expectedConfig: map[string]interface{}{
-				"issuer":                                "http://localhost:8080",
-				"authorization_endpoint":                "http://localhost:8080/oauth2/authorize",
-				"token_endpoint":                        "http://localhost:8080/oauth2/token",
-				"userinfo_endpoint":                     "http://localhost:8080/oauth2/userinfo",
-				"jwks_uri":                              "http://localhost:8080/.well-known/jwks.json",
-				"response_types_supported":              []string{"code", "token", "id_token"},
-				"subject_types_supported":               []string{"public"},
-				"id_token_signing_alg_values_supported": []string{"RS256"},
-				"scopes_supported":                      []string{"openid", "profile", "email"},
-				"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
-				"claims_supported":                      []string{"sub", "iss", "name", "email"},
+				"issuer":                                     "http://localhost:8080",
+				"authorization_endpoint":                     "http://localhost:8080/oauth2/authorize",
+				"token_endpoint":                             "http://localhost:8080/oauth2/token",
+				"userinfo_endpoint":                          "http://localhost:8080/oauth2/userinfo",
+				"jwks_uri":                                   "http://localhost:8080/.well-known/jwks.json",
+				"response_types_supported":                   []string{"code", "token", "id_token"},
+				"subject_types_supported":                    []string{"public", "pairwise"},
+				"id_token_signing_alg_values_supported":      []string{"RS256"},
+				"scopes_supported":                           []string{"email", "openid", "phone", "profile", "roles"},
+				"token_endpoint_auth_methods_supported":      []string{"client_secret_basic", "client_secret_post"},
+				"introspection_endpoint":                     "http://localhost:8080/oauth2/introspect",
+				"claims_supported":                           domain.SupportedClaims,
+				"claims_parameter_supported":                 true,
+				"acr_values_supported":                       domain.SupportedACRValues,
+				"userinfo_signing_alg_values_supported":      []string{"RS256"},
+				"id_token_encryption_alg_values_supported":   domain.SupportedEncryptionAlgs,
+				"id_token_encryption_enc_values_supported":   domain.SupportedEncryptionEncs,
+				"backchannel_authentication_endpoint":        "http://localhost:8080/oauth2/bc-authorize",
+				"backchannel_token_delivery_modes_supported": []string{"poll", "ping"},
+				"backchannel_user_code_parameter_supported":  false,
 			},
 		},
 		{
@@ -642,7 +952,6 @@ func TestOIDCService_GetOpenIDConfiguration(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockOAuth2Service := new(mockOAuth2Service)
-			mockTOTPService := new(mockTOTPService)
 			tt.mockSetup(mockOAuth2Service)
 
 			var cfg *config.Config
@@ -654,7 +963,7 @@ func TestOIDCService_GetOpenIDConfiguration(t *testing.T) {
 				}
 			}
 
-			service := NewOIDCService(mockOAuth2Service, nil, nil, mockTOTPService, cfg, zap.NewNop())
+			service := NewOIDCService(mockOAuth2Service, nil, nil, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, cfg, zap.NewNop())
 
 			config, err := service.GetOpenIDConfiguration(context.Background())
 
@@ -735,16 +1044,29 @@ func TestOIDCService_RefreshToken(t *testing.T) {
 			},
 			expectedError: domain.ErrInternal,
 		},
+		{
+			name:         "sessions revoked after the token was issued",
+			refreshToken: "valid_refresh_token",
+			mockSetup: func(m *mockUserRepository, _ interface{}) {
+				userID := ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+				revokedAt := time.Now()
+				m.On("FindByID", mock.Anything, userID).Return(&domain.User{
+					ID:                userID,
+					Roles:             []string{"user"},
+					SessionsRevokedAt: &revokedAt,
+				}, nil)
+			},
+			expectedError: domain.ErrInvalidCredentials,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockUserRepo := new(mockUserRepository)
 			mockOAuth2Service := new(mockOAuth2Service)
-			mockTOTPService := new(mockTOTPService)
 			var jwtService domain.JWTService
 			switch tt.name {
-			case "successful token refresh":
+			case "successful token refresh", "sessions revoked after the token was issued":
 				jwtService = &mockJWTRefresh{}
 			case "invalid refresh token":
 				jwtService = &mockJWTError{}
@@ -761,9 +1083,9 @@ func TestOIDCService_RefreshToken(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Failed to load config: %v", err)
 			}
-			service := NewOIDCService(mockOAuth2Service, jwtService, mockUserRepo, mockTOTPService, cfg, logger)
+			service := NewOIDCService(mockOAuth2Service, jwtService, mockUserRepo, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, cfg, logger)
 
-			token, err := service.RefreshToken(context.Background(), tt.refreshToken)
+			token, err := service.RefreshToken(context.Background(), "", "", tt.refreshToken)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
@@ -786,3 +1108,588 @@ func TestOIDCService_RefreshToken(t *testing.T) {
 		})
 	}
 }
+
+// mockJWTIDToken issues an ID token and signs userinfo responses
+type mockJWTIDToken struct {
+	mockJWTRefresh
+}
+
+func (m *mockJWTIDToken) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	return &domain.TokenPair{
+		AccessToken:  "mock_access_token",
+		RefreshToken: "mock_refresh_token",
+		IDToken:      "mock_id_token",
+	}, nil
+}
+
+func (m *mockJWTIDToken) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "signed_userinfo_for_" + clientID, nil
+}
+
+type mockTokenEncrypter struct {
+	mock.Mock
+}
+
+func (m *mockTokenEncrypter) Encrypt(ctx context.Context, jwksURI, alg, enc, token string) (string, error) {
+	args := m.Called(ctx, jwksURI, alg, enc, token)
+	return args.String(0), args.Error(1)
+}
+
+func TestOIDCService_ExchangeCode_EncryptedIDToken(t *testing.T) {
+	userID := ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+
+	tests := []struct {
+		name            string
+		client          *domain.OAuth2Client
+		mockSetup       func(*mockTokenEncrypter)
+		expectedIDToken string
+		expectedError   error
+	}{
+		{
+			name:            "client without encryption gets a signed ID token",
+			client:          &domain.OAuth2Client{ID: "client123", Secret: "secret"},
+			mockSetup:       func(m *mockTokenEncrypter) {},
+			expectedIDToken: "mock_id_token",
+		},
+		{
+			name: "client with encryption gets an encrypted ID token",
+			client: &domain.OAuth2Client{
+				ID:                          "client123",
+				Secret:                      "secret",
+				JWKSURI:                     "https://client.example.com/jwks.json",
+				IDTokenEncryptedResponseAlg: "RSA-OAEP-256",
+			},
+			mockSetup: func(m *mockTokenEncrypter) {
+				m.On("Encrypt", mock.Anything, "https://client.example.com/jwks.json", "RSA-OAEP-256", "A128CBC-HS256", "mock_id_token").
+					Return("encrypted_id_token", nil)
+			},
+			expectedIDToken: "encrypted_id_token",
+		},
+		{
+			name: "encryption failure",
+			client: &domain.OAuth2Client{
+				ID:                          "client123",
+				Secret:                      "secret",
+				JWKSURI:                     "https://client.example.com/jwks.json",
+				IDTokenEncryptedResponseAlg: "RSA-OAEP",
+				IDTokenEncryptedResponseEnc: "A256GCM",
+			},
+			mockSetup: func(m *mockTokenEncrypter) {
+				m.On("Encrypt", mock.Anything, "https://client.example.com/jwks.json", "RSA-OAEP", "A256GCM", "mock_id_token").
+					Return("", domain.ErrTokenEncryption)
+			},
+			expectedError: domain.ErrTokenEncryption,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOAuth2Service := new(mockOAuth2Service)
+			mockUserRepo := new(mockUserRepository)
+			mockEncrypter := new(mockTokenEncrypter)
+			tt.mockSetup(mockEncrypter)
+
+			expectCodeExchange(mockOAuth2Service, tt.client, &domain.AuthorizationCode{
+				UserID: userID.String(),
+				Scopes: []string{"openid", "email"},
+			})
+			mockUserRepo.On("FindByID", mock.Anything, userID).Return(&domain.User{
+				ID:    userID,
+				Email: "test@example.com",
+				Roles: []string{"user"},
+			}, nil)
+
+			cfg := &config.Config{ServerURL: "http://localhost:8080"}
+			service := NewOIDCService(mockOAuth2Service, &mockJWTIDToken{}, mockUserRepo, newStandardScopeService(), nil, nil, newTestMFAPolicy(), mockEncrypter, cfg, zap.NewNop())
+
+			token, err := service.ExchangeCode(context.Background(), tt.client.ID, "secret", "valid_code", "verifier")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, token)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedIDToken, token.IDToken)
+			}
+
+			mockEncrypter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOIDCService_SignUserInfo(t *testing.T) {
+	userInfo := domain.UserInfo{"sub": "01ARZ3NDEKTSV4RRFFQ69G5FAV", "email": "test@example.com"}
+
+	tests := []struct {
+		name          string
+		clientID      string
+		mockSetup     func(*mockOAuth2Service)
+		expectedToken string
+		expectedError error
+	}{
+		{
+			name:          "no client in context",
+			mockSetup:     func(m *mockOAuth2Service) {},
+			expectedToken: "",
+		},
+		{
+			name:     "client without signed userinfo",
+			clientID: "client123",
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("GetClient", mock.Anything, "client123").Return(&domain.OAuth2Client{ID: "client123"}, nil)
+			},
+			expectedToken: "",
+		},
+		{
+			name:     "client with signed userinfo",
+			clientID: "client123",
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("GetClient", mock.Anything, "client123").Return(&domain.OAuth2Client{
+					ID:                        "client123",
+					UserInfoSignedResponseAlg: "RS256",
+				}, nil)
+			},
+			expectedToken: "signed_userinfo_for_client123",
+		},
+		{
+			name:     "unknown client",
+			clientID: "unknown",
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("GetClient", mock.Anything, "unknown").Return(nil, domain.ErrClientNotFound)
+			},
+			expectedError: domain.ErrClientNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockOAuth2Service := new(mockOAuth2Service)
+			tt.mockSetup(mockOAuth2Service)
+
+			ctx := context.Background()
+			if tt.clientID != "" {
+				ctx = domain.WithClientID(ctx, tt.clientID)
+			}
+
+			service := NewOIDCService(mockOAuth2Service, &mockJWTIDToken{}, nil, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, &config.Config{}, zap.NewNop())
+
+			token, err := service.SignUserInfo(ctx, userInfo)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedToken, token)
+			}
+
+			mockOAuth2Service.AssertExpectations(t)
+		})
+	}
+}
+
+// mockJWTAuthentication validates refresh tokens recording a sign-in and keeps the grant of the last token pair
+type mockJWTAuthentication struct {
+	mockJWTRefresh
+	auth   *domain.Authentication
+	params *domain.TokenParams
+}
+
+func (m *mockJWTAuthentication) ValidateToken(token string) (*domain.Claims, error) {
+	claims, _ := m.mockJWTRefresh.ValidateToken(token)
+	claims.SetAuthentication(m.auth)
+	return claims, nil
+}
+
+func (m *mockJWTAuthentication) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	m.params = params
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func TestOIDCService_KeepsAuthentication(t *testing.T) {
+	userID := ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	authTime := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	auth := &domain.Authentication{Methods: []string{domain.AMRPassword, domain.AMROTP, domain.AMRMultiFactor}, Time: authTime}
+
+	newService := func(jwtService *mockJWTAuthentication, oauth2Service *mockOAuth2Service) *OIDCService {
+		userRepo := new(mockUserRepository)
+		userRepo.On("FindByID", mock.Anything, userID).Return(&domain.User{ID: userID, Roles: []string{"user"}}, nil)
+		cfg := &config.Config{ServerURL: "http://localhost:8080"}
+		return NewOIDCService(oauth2Service, jwtService, userRepo, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, cfg, zap.NewNop())
+	}
+
+	t.Run("authorization code", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		expectCodeExchange(oauth2Service, &domain.OAuth2Client{ID: "client123", Secret: "secret"}, &domain.AuthorizationCode{
+			UserID:   userID.String(),
+			Scopes:   []string{"profile"},
+			AMR:      auth.Methods,
+			AuthTime: &authTime,
+		})
+		jwtService := &mockJWTAuthentication{}
+
+		_, err := newService(jwtService, oauth2Service).ExchangeCode(context.Background(), "client123", "secret", "valid_code", "verifier")
+
+		assert.NoError(t, err)
+		assert.Equal(t, auth, jwtService.params.Authentication)
+	})
+
+	t.Run("authorization code without a recorded sign-in", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		expectCodeExchange(oauth2Service, &domain.OAuth2Client{ID: "client123", Secret: "secret"}, &domain.AuthorizationCode{
+			UserID: userID.String(),
+			Scopes: []string{"profile"},
+		})
+		jwtService := &mockJWTAuthentication{}
+
+		_, err := newService(jwtService, oauth2Service).ExchangeCode(context.Background(), "client123", "secret", "valid_code", "verifier")
+
+		assert.NoError(t, err)
+		assert.Nil(t, jwtService.params.Authentication)
+	})
+
+	t.Run("refresh keeps the original sign-in", func(t *testing.T) {
+		jwtService := &mockJWTAuthentication{auth: auth}
+
+		_, err := newService(jwtService, new(mockOAuth2Service)).RefreshToken(context.Background(), "", "", "valid_refresh_token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, auth.Methods, jwtService.params.Authentication.Methods)
+		assert.True(t, authTime.Equal(jwtService.params.Authentication.Time))
+	})
+}
+
+type mockPairwiseSubjectRepository struct {
+	mock.Mock
+}
+
+func (m *mockPairwiseSubjectRepository) Save(ctx context.Context, sector, subject string, userID ulid.ULID) error {
+	args := m.Called(ctx, sector, subject, userID)
+	return args.Error(0)
+}
+
+func (m *mockPairwiseSubjectRepository) FindUserID(ctx context.Context, sector, subject string) (ulid.ULID, error) {
+	args := m.Called(ctx, sector, subject)
+	return args.Get(0).(ulid.ULID), args.Error(1)
+}
+
+// mockJWTClaims validates every token to the given claims and keeps the grant of the last token pair
+type mockJWTClaims struct {
+	mockJWTRefresh
+	claims *domain.Claims
+	err    error
+	params *domain.TokenParams
+}
+
+func (m *mockJWTClaims) ValidateToken(token string) (*domain.Claims, error) {
+	return m.claims, m.err
+}
+
+func (m *mockJWTClaims) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	m.params = params
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func TestOIDCService_PairwiseTokens(t *testing.T) {
+	userID := ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	client := &domain.OAuth2Client{
+		ID:           "pairwise-client",
+		Secret:       "secret",
+		RedirectURIs: []string{"https://app.example.com/callback"},
+		SubjectType:  domain.SubjectTypePairwise,
+	}
+	subject := domain.PairwiseSubject("app.example.com", userID.String(), "test-salt")
+	cfg := &config.Config{ServerURL: "http://localhost:8080", PairwiseSubjectSalt: "test-salt"}
+
+	newService := func(oauth2Service *mockOAuth2Service, jwtService domain.JWTService, pairwiseRepo *mockPairwiseSubjectRepository) *OIDCService {
+		userRepo := new(mockUserRepository)
+		userRepo.On("FindByID", mock.Anything, userID).Return(&domain.User{ID: userID, Roles: []string{"user"}}, nil).Maybe()
+		return NewOIDCService(oauth2Service, jwtService, userRepo, newStandardScopeService(), nil, pairwiseRepo, newTestMFAPolicy(), nil, cfg, zap.NewNop())
+	}
+
+	t.Run("authorization code issues every token with the pairwise subject", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		expectCodeExchange(oauth2Service, client, &domain.AuthorizationCode{
+			UserID: userID.String(),
+			Scopes: []string{"profile"},
+		})
+		pairwiseRepo := new(mockPairwiseSubjectRepository)
+		pairwiseRepo.On("Save", mock.Anything, "app.example.com", subject, userID).Return(nil)
+		jwtService := &mockJWTClaims{}
+
+		_, err := newService(oauth2Service, jwtService, pairwiseRepo).ExchangeCode(context.Background(), client.ID, "secret", "valid_code", "verifier")
+
+		assert.NoError(t, err)
+		assert.Equal(t, subject, jwtService.params.Subject)
+		pairwiseRepo.AssertExpectations(t)
+	})
+
+	t.Run("refresh maps the subject back to the user and keeps it", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		oauth2Service.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+		pairwiseRepo := new(mockPairwiseSubjectRepository)
+		pairwiseRepo.On("FindUserID", mock.Anything, "app.example.com", subject).Return(userID, nil)
+		jwtService := &mockJWTClaims{claims: &domain.Claims{
+			RegisteredClaims: &jwtv5.RegisteredClaims{Subject: subject},
+			Roles:            []string{"user"},
+			ClientID:         client.ID,
+			Type:             domain.TokenTypeRefresh,
+		}}
+
+		oauth2Service.On("AuthenticateClient", mock.Anything, client.ID, "secret").Return(client, nil)
+
+		_, err := newService(oauth2Service, jwtService, pairwiseRepo).RefreshToken(context.Background(), client.ID, "secret", "refresh_token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, subject, jwtService.params.Subject)
+		assert.Equal(t, client.ID, jwtService.params.ClientID)
+	})
+
+	t.Run("unknown pairwise subject", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		oauth2Service.On("GetClient", mock.Anything, client.ID).Return(client, nil)
+		pairwiseRepo := new(mockPairwiseSubjectRepository)
+		pairwiseRepo.On("FindUserID", mock.Anything, "app.example.com", "forged").Return(ulid.ULID{}, domain.ErrPairwiseSubjectNotFound)
+
+		_, err := newService(oauth2Service, nil, pairwiseRepo).ResolveSubject(context.Background(), client.ID, "forged")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidToken)
+	})
+
+	t.Run("public clients and first-party tokens carry the user ID", func(t *testing.T) {
+		oauth2Service := new(mockOAuth2Service)
+		oauth2Service.On("GetClient", mock.Anything, "public-client").Return(&domain.OAuth2Client{ID: "public-client"}, nil)
+		service := newService(oauth2Service, nil, new(mockPairwiseSubjectRepository))
+
+		resolved, err := service.ResolveSubject(context.Background(), "public-client", userID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, userID.String(), resolved)
+
+		resolved, err = service.ResolveSubject(context.Background(), "", userID.String())
+		assert.NoError(t, err)
+		assert.Equal(t, userID.String(), resolved)
+	})
+}
+
+func TestOIDCService_IntrospectToken(t *testing.T) {
+	userID := ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	client := &domain.OAuth2Client{ID: "client123", Secret: "secret"}
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	accessClaims := func(clientID string) *domain.Claims {
+		return &domain.Claims{
+			RegisteredClaims: &jwtv5.RegisteredClaims{
+				Subject:   userID.String(),
+				ID:        "token-id",
+				IssuedAt:  jwtv5.NewNumericDate(issuedAt),
+				ExpiresAt: jwtv5.NewNumericDate(issuedAt.Add(time.Hour)),
+			},
+			Roles:    []string{"user"},
+			Scope:    "openid profile",
+			ClientID: clientID,
+			Type:     domain.TokenTypeAccess,
+		}
+	}
+
+	tests := []struct {
+		name           string
+		secret         string
+		jwtService     *mockJWTClaims
+		user           *domain.User
+		expectedActive bool
+		expectedError  error
+	}{
+		{
+			name:           "active access token",
+			secret:         "secret",
+			jwtService:     &mockJWTClaims{claims: accessClaims("client123")},
+			user:           &domain.User{ID: userID, Roles: []string{"user"}},
+			expectedActive: true,
+		},
+		{
+			name:          "wrong client secret",
+			secret:        "wrong",
+			jwtService:    &mockJWTClaims{claims: accessClaims("client123")},
+			expectedError: domain.ErrInvalidClient,
+		},
+		{
+			name:          "public client",
+			secret:        "",
+			jwtService:    &mockJWTClaims{claims: accessClaims("client123")},
+			expectedError: domain.ErrInvalidClient,
+		},
+		{
+			name:       "expired token",
+			secret:     "secret",
+			jwtService: &mockJWTClaims{err: domain.ErrTokenExpired},
+		},
+		{
+			name:       "token of another client",
+			secret:     "secret",
+			jwtService: &mockJWTClaims{claims: accessClaims("other-client")},
+		},
+		{
+			name:       "first-party token",
+			secret:     "secret",
+			jwtService: &mockJWTClaims{claims: accessClaims("")},
+		},
+		{
+			name:   "ID token",
+			secret: "secret",
+			jwtService: &mockJWTClaims{claims: func() *domain.Claims {
+				claims := accessClaims("client123")
+				claims.Type = domain.TokenTypeID
+				return claims
+			}()},
+			user: &domain.User{ID: userID, Roles: []string{"user"}},
+		},
+		{
+			name:       "sessions revoked after the token was issued",
+			secret:     "secret",
+			jwtService: &mockJWTClaims{claims: accessClaims("client123")},
+			user:       &domain.User{ID: userID, Roles: []string{"user"}, SessionsRevokedAt: func() *time.Time { t := time.Now(); return &t }()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oauth2Service := new(mockOAuth2Service)
+			if tt.secret == client.Secret {
+				oauth2Service.On("AuthenticateClient", mock.Anything, client.ID, tt.secret).Return(client, nil)
+			} else if tt.secret == "" {
+				oauth2Service.On("AuthenticateClient", mock.Anything, client.ID, tt.secret).Return(&domain.OAuth2Client{ID: client.ID}, nil)
+			} else {
+				oauth2Service.On("AuthenticateClient", mock.Anything, client.ID, tt.secret).Return(nil, domain.ErrInvalidClient)
+			}
+			oauth2Service.On("GetClient", mock.Anything, client.ID).Return(client, nil).Maybe()
+			userRepo := new(mockUserRepository)
+			if tt.user != nil {
+				userRepo.On("FindByID", mock.Anything, userID).Return(tt.user, nil)
+			}
+			service := NewOIDCService(oauth2Service, tt.jwtService, userRepo, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, &config.Config{}, zap.NewNop())
+
+			introspection, err := service.IntrospectToken(context.Background(), client.ID, tt.secret, "token")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, introspection)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedActive, introspection.Active)
+			if tt.expectedActive {
+				assert.Equal(t, userID.String(), introspection.Subject)
+				assert.Equal(t, "openid profile", introspection.Scope)
+				assert.Equal(t, domain.TokenTypeHintAccessToken, introspection.TokenType)
+				assert.Equal(t, issuedAt.Unix(), introspection.IssuedAt)
+			} else {
+				assert.Empty(t, introspection.Subject)
+			}
+		})
+	}
+}
+
+func TestOIDCService_RefreshToken_Client(t *testing.T) {
+	userID := ulid.MustParse("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	client := &domain.OAuth2Client{ID: "client123", Secret: "secret"}
+	refreshClaims := func(clientID, tokenType string) *domain.Claims {
+		return &domain.Claims{
+			RegisteredClaims: &jwtv5.RegisteredClaims{Subject: userID.String()},
+			Roles:            []string{"user"},
+			ClientID:         clientID,
+			Type:             tokenType,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		clientID      string
+		secret        string
+		claims        *domain.Claims
+		mockSetup     func(*mockOAuth2Service)
+		expectedError error
+	}{
+		{
+			name:     "client refreshes its own token",
+			clientID: "client123",
+			secret:   "secret",
+			claims:   refreshClaims("client123", domain.TokenTypeRefresh),
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("AuthenticateClient", mock.Anything, "client123", "secret").Return(client, nil)
+				m.On("GetClient", mock.Anything, "client123").Return(client, nil)
+			},
+		},
+		{
+			name:     "wrong client secret",
+			clientID: "client123",
+			secret:   "wrong",
+			claims:   refreshClaims("client123", domain.TokenTypeRefresh),
+			mockSetup: func(m *mockOAuth2Service) {
+				m.On("AuthenticateClient", mock.Anything, "client123", "wrong").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedError: domain.ErrInvalidClient,
+		},
+		{
+			name:          "token of another client",
+			clientID:      "other-client",
+			secret:        "secret",
+			claims:        refreshClaims("client123", domain.TokenTypeRefresh),
+			mockSetup:     func(m *mockOAuth2Service) {},
+			expectedError: domain.ErrInvalidCredentials,
+		},
+		{
+			name:          "client token without client credentials",
+			claims:        refreshClaims("client123", domain.TokenTypeRefresh),
+			mockSetup:     func(m *mockOAuth2Service) {},
+			expectedError: domain.ErrInvalidCredentials,
+		},
+		{
+			name:          "access token",
+			clientID:      "client123",
+			secret:        "secret",
+			claims:        refreshClaims("client123", domain.TokenTypeAccess),
+			mockSetup:     func(m *mockOAuth2Service) {},
+			expectedError: domain.ErrInvalidCredentials,
+		},
+		{
+			name:          "first-party ID token",
+			claims:        refreshClaims("", domain.TokenTypeID),
+			mockSetup:     func(m *mockOAuth2Service) {},
+			expectedError: domain.ErrInvalidCredentials,
+		},
+		{
+			name:          "signed userinfo",
+			claims:        refreshClaims("", domain.TokenTypeUserInfo),
+			mockSetup:     func(m *mockOAuth2Service) {},
+			expectedError: domain.ErrInvalidCredentials,
+		},
+		{
+			name:          "plain JWT without a typ",
+			claims:        refreshClaims("", ""),
+			mockSetup:     func(m *mockOAuth2Service) {},
+			expectedError: domain.ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oauth2Service := new(mockOAuth2Service)
+			tt.mockSetup(oauth2Service)
+			userRepo := new(mockUserRepository)
+			userRepo.On("FindByID", mock.Anything, userID).Return(&domain.User{ID: userID, Roles: []string{"user"}}, nil).Maybe()
+
+			cfg := &config.Config{ServerURL: "http://localhost:8080"}
+			service := NewOIDCService(oauth2Service, &mockJWTClaims{claims: tt.claims}, userRepo, newStandardScopeService(), nil, nil, newTestMFAPolicy(), nil, cfg, zap.NewNop())
+
+			token, err := service.RefreshToken(context.Background(), tt.clientID, tt.secret, "refresh_token")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.Nil(t, token)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, token)
+			}
+
+			oauth2Service.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// minPersonalInfoLength keeps short name parts such as initials from rejecting most passwords
+const minPersonalInfoLength = 3
+
+type PasswordPolicyService struct {
+	historyRepo    domain.PasswordHistoryRepository
+	dictionary     domain.PasswordDictionary
+	breachChecker  domain.BreachedPasswordChecker
+	passwordHasher domain.PasswordHasher
+	config         *config.Config
+	logger         *zap.Logger
+}
+
+func NewPasswordPolicyService(historyRepo domain.PasswordHistoryRepository, dictionary domain.PasswordDictionary, breachChecker domain.BreachedPasswordChecker, passwordHasher domain.PasswordHasher, config *config.Config, logger *zap.Logger) *PasswordPolicyService {
+	return &PasswordPolicyService{
+		historyRepo:    historyRepo,
+		dictionary:     dictionary,
+		breachChecker:  breachChecker,
+		passwordHasher: passwordHasher,
+		config:         config,
+		logger:         logger,
+	}
+}
+
+// Validate checks the password against every rule and reports all of the failing ones at once
+func (s *PasswordPolicyService) Validate(ctx context.Context, user *domain.User, password string) error {
+	var violations []domain.PasswordViolation
+	violate := func(rule, message string) {
+		violations = append(violations, domain.PasswordViolation{Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < s.config.PasswordMinLength {
+		violate(domain.PasswordRuleMinLength, fmt.Sprintf("Password must be at least %d characters long", s.config.PasswordMinLength))
+	}
+	// The cap is in bytes, as bcrypt ignores everything past 72 bytes
+	if len(password) > s.config.PasswordMaxLength {
+		violate(domain.PasswordRuleMaxLength, fmt.Sprintf("Password must be at most %d bytes long", s.config.PasswordMaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if s.config.PasswordRequireUpper && !hasUpper {
+		violate(domain.PasswordRuleUppercase, "Password must contain an uppercase letter")
+	}
+	if s.config.PasswordRequireLower && !hasLower {
+		violate(domain.PasswordRuleLowercase, "Password must contain a lowercase letter")
+	}
+	if s.config.PasswordRequireDigit && !hasDigit {
+		violate(domain.PasswordRuleDigit, "Password must contain a digit")
+	}
+	if s.config.PasswordRequireSymbol && !hasSymbol {
+		violate(domain.PasswordRuleSymbol, "Password must contain a symbol")
+	}
+
+	if containsPersonalInfo(user, password) {
+		violate(domain.PasswordRulePersonalInfo, "Password must not contain your name or email")
+	}
+
+	if s.dictionary.Contains(password) {
+		violate(domain.PasswordRuleCommon, "Password is too common")
+	}
+
+	if s.isBreached(user, password) {
+		violate(domain.PasswordRuleBreached, "Password has appeared in a data breach")
+	}
+
+	reused, err := s.isReused(ctx, user, password)
+	if err != nil {
+		return err
+	}
+	if reused {
+		violate(domain.PasswordRuleHistory, fmt.Sprintf("Password must differ from your last %d passwords", s.config.PasswordHistorySize))
+	}
+
+	if len(violations) > 0 {
+		s.logger.Debug("Password rejected by policy",
+			zap.String("user_id", user.ID.String()),
+			zap.Int("violations", len(violations)))
+		return domain.NewPasswordPolicyError(violations)
+	}
+
+	return nil
+}
+
+// Remember records a newly set password hash in the user's history
+func (s *PasswordPolicyService) Remember(ctx context.Context, userID ulid.ULID, hashedPassword string) error {
+	if s.config.PasswordHistorySize == 0 {
+		return nil
+	}
+	return s.historyRepo.Add(ctx, userID, hashedPassword, s.config.PasswordHistorySize)
+}
+
+// isBreached reports whether the password is in the breach corpus and the policy rejects it.
+// In warn mode breached passwords are only logged, which allows measuring the impact before enforcing.
+// A corpus that cannot be read does not block users from setting a password.
+func (s *PasswordPolicyService) isBreached(user *domain.User, password string) bool {
+	count, err := s.breachChecker.Count(password)
+	if err != nil {
+		s.logger.Error("Failed to check breached password corpus",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return false
+	}
+	if count == 0 {
+		return false
+	}
+
+	if s.config.BreachedPasswordPolicy == domain.BreachedPasswordWarn {
+		s.logger.Warn("Accepted password found in breach corpus",
+			zap.String("user_id", user.ID.String()),
+			zap.Int("occurrences", count))
+		return false
+	}
+	return true
+}
+
+// isReused reports whether the password matches the user's current or one of their recent passwords.
+// Users being registered have no password yet, so there is no history to look up.
+func (s *PasswordPolicyService) isReused(ctx context.Context, user *domain.User, password string) (bool, error) {
+	if s.config.PasswordHistorySize == 0 || user.Password == "" {
+		return false, nil
+	}
+
+	hashes, err := s.historyRepo.ListRecent(ctx, user.ID, s.config.PasswordHistorySize)
+	if err != nil {
+		s.logger.Error("Failed to load password history",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return false, domain.ErrInternal
+	}
+
+	// Users created before the history existed still have their current password checked
+	if !slices.Contains(hashes, user.Password) {
+		hashes = append(hashes, user.Password)
+	}
+
+	for _, hash := range hashes {
+		ok, err := s.passwordHasher.Verify(password, hash)
+		if err != nil {
+			s.logger.Warn("Skipping unreadable password history entry",
+				zap.String("user_id", user.ID.String()),
+				zap.Error(err))
+			continue
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// containsPersonalInfo reports whether the password contains the user's email, its local part or a part of their name
+func containsPersonalInfo(user *domain.User, password string) bool {
+	lowered := strings.ToLower(password)
+
+	candidates := strings.Fields(strings.ToLower(user.Name))
+	if email := strings.ToLower(user.Email); email != "" {
+		candidates = append(candidates, email)
+		if at := strings.Index(email, "@"); at > 0 {
+			candidates = append(candidates, email[:at])
+		}
+	}
+
+	for _, candidate := range candidates {
+		if len(candidate) >= minPersonalInfoLength && strings.Contains(lowered, candidate) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockPasswordHistoryRepository struct {
+	mock.Mock
+}
+
+func (m *mockPasswordHistoryRepository) Add(ctx context.Context, userID ulid.ULID, hashedPassword string, keep int) error {
+	args := m.Called(ctx, userID, hashedPassword, keep)
+	return args.Error(0)
+}
+
+func (m *mockPasswordHistoryRepository) ListRecent(ctx context.Context, userID ulid.ULID, limit int) ([]string, error) {
+	args := m.Called(ctx, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+type stubPasswordDictionary map[string]bool
+
+func (d stubPasswordDictionary) Contains(password string) bool {
+	return d[strings.ToLower(password)]
+}
+
+type stubBreachChecker map[string]int
+
+func (c stubBreachChecker) Count(password string) (int, error) {
+	return c[password], nil
+}
+
+func newPolicyTestConfig() *config.Config {
+	return &config.Config{
+		PasswordMinLength:    8,
+		PasswordMaxLength:    72,
+		PasswordRequireUpper: true,
+		PasswordRequireLower: true,
+		PasswordRequireDigit: true,
+		PasswordHistorySize:  3,
+
+		BreachedPasswordPolicy: domain.BreachedPasswordReject,
+	}
+}
+
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	policyErr, ok := err.(*domain.PasswordPolicyError)
+	require.True(t, ok, "expected a password policy error, got %v", err)
+	assert.ErrorIs(t, err, domain.ErrPasswordPolicy)
+
+	var rules []string
+	for _, v := range policyErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPasswordPolicyService_Validate(t *testing.T) {
+	newUser := &domain.User{ID: ulid.Make(), Name: "Ada Lovelace", Email: "ada.l@example.com"}
+
+	tests := []struct {
+		name     string
+		password string
+		config   func(*config.Config)
+		expected []string
+	}{
+		{
+			name:     "strong password",
+			password: "Tr1cky-Horse-Battery",
+		},
+		{
+			name:     "too short and missing classes",
+			password: "abc",
+			expected: []string{domain.PasswordRuleMinLength, domain.PasswordRuleUppercase, domain.PasswordRuleDigit},
+		},
+		{
+			name:     "over the bcrypt byte limit",
+			password: "Aa1" + strings.Repeat("é", 35),
+			expected: []string{domain.PasswordRuleMaxLength},
+		},
+		{
+			name:     "symbol required",
+			password: "Tr1ckyHorseBattery",
+			config:   func(c *config.Config) { c.PasswordRequireSymbol = true },
+			expected: []string{domain.PasswordRuleSymbol},
+		},
+		{
+			name:     "contains name",
+			password: "LOVELACE2024x",
+			expected: []string{domain.PasswordRulePersonalInfo},
+		},
+		{
+			name:     "contains email local part",
+			password: "Xada.l2024",
+			expected: []string{domain.PasswordRulePersonalInfo},
+		},
+		{
+			name:     "common password",
+			password: "Password123",
+			expected: []string{domain.PasswordRuleCommon},
+		},
+		{
+			name:     "breached password",
+			password: "Summer-Breach-2019",
+			expected: []string{domain.PasswordRuleBreached},
+		},
+		{
+			name:     "breached password in warn mode",
+			password: "Summer-Breach-2019",
+			config:   func(c *config.Config) { c.BreachedPasswordPolicy = domain.BreachedPasswordWarn },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newPolicyTestConfig()
+			if tt.config != nil {
+				tt.config(cfg)
+			}
+			dictionary := stubPasswordDictionary{"password123": true}
+			breaches := stubBreachChecker{"Summer-Breach-2019": 42}
+			service := NewPasswordPolicyService(nil, dictionary, breaches, newTestPasswordHasher(), cfg, zap.NewNop())
+
+			err := service.Validate(context.Background(), newUser, tt.password)
+
+			assert.Equal(t, tt.expected, violatedRules(t, err))
+		})
+	}
+}
+
+func TestPasswordPolicyService_Validate_History(t *testing.T) {
+	hasher := newTestPasswordHasher()
+	current, err := hasher.Hash("Current-Pass1")
+	require.NoError(t, err)
+	previous, err := hasher.Hash("Previous-Pass1")
+	require.NoError(t, err)
+
+	user := &domain.User{ID: ulid.Make(), Name: "Ada Lovelace", Email: "ada.l@example.com", Password: current}
+
+	historyRepo := new(mockPasswordHistoryRepository)
+	historyRepo.On("ListRecent", mock.Anything, user.ID, 3).Return([]string{previous}, nil)
+
+	service := NewPasswordPolicyService(historyRepo, stubPasswordDictionary{}, stubBreachChecker{}, hasher, newPolicyTestConfig(), zap.NewNop())
+
+	// The current password is checked even before it is recorded in the history
+	assert.Equal(t, []string{domain.PasswordRuleHistory}, violatedRules(t, service.Validate(context.Background(), user, "Current-Pass1")))
+	assert.Equal(t, []string{domain.PasswordRuleHistory}, violatedRules(t, service.Validate(context.Background(), user, "Previous-Pass1")))
+	assert.NoError(t, service.Validate(context.Background(), user, "Brand-New-Pass1"))
+}
+
+func TestPasswordPolicyService_Remember(t *testing.T) {
+	userID := ulid.Make()
+	historyRepo := new(mockPasswordHistoryRepository)
+	historyRepo.On("Add", mock.Anything, userID, "$argon2id$hash", 3).Return(nil)
+
+	service := NewPasswordPolicyService(historyRepo, stubPasswordDictionary{}, stubBreachChecker{}, nil, newPolicyTestConfig(), zap.NewNop())
+
+	assert.NoError(t, service.Remember(context.Background(), userID, "$argon2id$hash"))
+	historyRepo.AssertExpectations(t)
+}
@@ -0,0 +1,205 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// smsCodeLength is the number of digits of the codes sent to phones
+const smsCodeLength = 6
+
+const (
+	phoneVerificationMessage = "Your phone verification code is %s."
+	smsSignInMessage         = "Your sign-in code is %s. Never share it with anyone."
+)
+
+// PhoneService verifies the phone numbers of users and sends them the codes of their sms_otp
+// factor. Codes are stored hashed, and every phone number gets at most SMSSendLimit messages or
+// calls per SMSSendWindow.
+type PhoneService struct {
+	userRepo         domain.UserRepository
+	verificationRepo domain.VerificationCodeRepository
+	smsSender        domain.SMSSender
+	lockoutService   domain.LockoutService
+	config           *config.Config
+	logger           *zap.Logger
+}
+
+// NewPhoneService creates a new phone service
+func NewPhoneService(
+	userRepo domain.UserRepository,
+	verificationRepo domain.VerificationCodeRepository,
+	smsSender domain.SMSSender,
+	lockoutService domain.LockoutService,
+	config *config.Config,
+	logger *zap.Logger,
+) *PhoneService {
+	return &PhoneService{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		smsSender:        smsSender,
+		lockoutService:   lockoutService,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// RequestVerification sends a code to the phone number the user wants as theirs, which becomes
+// their phone once the code is confirmed
+func (s *PhoneService) RequestVerification(ctx context.Context, userID, phone string, channel domain.SMSChannel) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	number, err := domain.NormalizePhoneNumber(phone)
+	if err != nil {
+		return err
+	}
+
+	return s.sendCode(ctx, user.ID, number, domain.PhoneVerification, channel, phoneVerificationMessage)
+}
+
+// ConfirmVerification makes the number of the latest request the user's verified phone. Only
+// MFAMaxAttempts codes can be tried per SMSCodeTTL.
+func (s *PhoneService) ConfirmVerification(ctx context.Context, userID, code string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.lockoutService.Throttle(ctx, domain.PhoneVerificationKey(userID), s.config.MFAMaxAttempts, s.config.SMSCodeTTL); err != nil {
+		return err
+	}
+
+	stored, err := s.redeemCode(ctx, user.ID, domain.PhoneVerification, code)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdatePhone(ctx, user.ID, stored.Target); err != nil {
+		s.logger.Error("Failed to update phone", zap.String("user_id", userID), zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	s.logger.Info("Phone verified", zap.String("user_id", userID))
+	return nil
+}
+
+// SendCode texts or calls a single-use code to the user's verified phone
+func (s *PhoneService) SendCode(ctx context.Context, user *domain.User, channel domain.SMSChannel) error {
+	if !user.PhoneVerified || user.Phone == "" {
+		return domain.ErrPhoneNotVerified
+	}
+
+	return s.sendCode(ctx, user.ID, user.Phone, domain.MFASMSCode, channel, smsSignInMessage)
+}
+
+// VerifyCode redeems the code last sent to the user's phone
+func (s *PhoneService) VerifyCode(ctx context.Context, user *domain.User, code string) error {
+	_, err := s.redeemCode(ctx, user.ID, domain.MFASMSCode, code)
+	return err
+}
+
+// sendCode replaces the user's code of the given type with a new one and delivers it to the
+// phone number in the message
+func (s *PhoneService) sendCode(ctx context.Context, userID ulid.ULID, phone string, codeType domain.VerificationCodeType, channel domain.SMSChannel, message string) error {
+	if channel == "" {
+		channel = domain.SMSChannelText
+	}
+
+	// Sends are limited per number, and per user and client IP so that cycling through numbers
+	// does not get around the limit
+	if err := s.lockoutService.Throttle(ctx, domain.SMSSendKey(phone), s.config.SMSSendLimit, s.config.SMSSendWindow); err != nil {
+		return err
+	}
+	if err := s.lockoutService.Throttle(ctx, domain.SMSUserSendKey(userID.String()), s.config.SMSUserSendLimit, s.config.SMSSendWindow); err != nil {
+		return err
+	}
+	if ip, ok := domain.GetClientIP(ctx); ok && ip != "" {
+		if err := s.lockoutService.Throttle(ctx, domain.SMSIPSendKey(ip), s.config.SMSIPSendLimit, s.config.SMSSendWindow); err != nil {
+			return err
+		}
+	}
+
+	// Only the latest code can be redeemed
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, userID, codeType); err != nil {
+		s.logger.Error("Failed to delete existing phone codes", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	code, err := generateNumericCode(smsCodeLength)
+	if err != nil {
+		s.logger.Error("Failed to generate phone code", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	stored := domain.NewVerificationCode(userID, hashOneTimeSecret(userID, code), codeType, s.config.SMSCodeTTL)
+	stored.Target = phone
+	if err := s.verificationRepo.Create(ctx, stored); err != nil {
+		s.logger.Error("Failed to store phone code", zap.Error(err))
+		return domain.ErrInternal
+	}
+
+	// Voice calls read the digits one by one
+	if channel == domain.SMSChannelVoice {
+		code = strings.Join(strings.Split(code, ""), ", ")
+	}
+	if err := s.smsSender.Send(ctx, phone, channel, fmt.Sprintf(message, code)); err != nil {
+		s.logger.Error("Failed to send phone code",
+			zap.String("user_id", userID.String()),
+			zap.String("channel", string(channel)),
+			zap.Error(err))
+		return domain.ErrSMSSendFailed
+	}
+
+	return nil
+}
+
+// redeemCode checks a code against the user's latest code of the given type. A matching code is
+// used up even when it has expired.
+func (s *PhoneService) redeemCode(ctx context.Context, userID ulid.ULID, codeType domain.VerificationCodeType, code string) (*domain.VerificationCode, error) {
+	stored, err := s.verificationRepo.FindByUserIDAndType(ctx, userID, codeType)
+	if err != nil || subtle.ConstantTimeCompare([]byte(stored.Code), []byte(hashOneTimeSecret(userID, code))) != 1 {
+		return nil, domain.ErrInvalidVerificationCode
+	}
+
+	// Codes are single-use, so a code that cannot be deleted is not honoured
+	if err := s.verificationRepo.DeleteByUserIDAndType(ctx, userID, codeType); err != nil {
+		s.logger.Error("Failed to delete phone code", zap.String("user_id", userID.String()), zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	if stored.IsExpired() {
+		return nil, domain.ErrVerificationCodeExpired
+	}
+	return stored, nil
+}
+
+func (s *PhoneService) findUser(ctx context.Context, userID string) (*domain.User, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+// maskPhoneNumber hides every digit of a phone number but the last four, as in +*******0123
+func maskPhoneNumber(phone string) string {
+	if len(phone) <= 5 {
+		return phone
+	}
+	return "+" + strings.Repeat("*", len(phone)-5) + phone[len(phone)-4:]
+}
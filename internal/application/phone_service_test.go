@@ -0,0 +1,254 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockSMSSender struct {
+	mock.Mock
+}
+
+func (m *mockSMSSender) Send(ctx context.Context, phone string, channel domain.SMSChannel, message string) error {
+	args := m.Called(ctx, phone, channel, message)
+	return args.Error(0)
+}
+
+type mockPhoneService struct {
+	mock.Mock
+}
+
+func (m *mockPhoneService) RequestVerification(ctx context.Context, userID, phone string, channel domain.SMSChannel) error {
+	args := m.Called(ctx, userID, phone, channel)
+	return args.Error(0)
+}
+
+func (m *mockPhoneService) ConfirmVerification(ctx context.Context, userID, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+
+func (m *mockPhoneService) SendCode(ctx context.Context, user *domain.User, channel domain.SMSChannel) error {
+	args := m.Called(ctx, user, channel)
+	return args.Error(0)
+}
+
+func (m *mockPhoneService) VerifyCode(ctx context.Context, user *domain.User, code string) error {
+	args := m.Called(ctx, user, code)
+	return args.Error(0)
+}
+
+type phoneTestFixture struct {
+	userRepo         *MockUserRepository
+	verificationRepo *mockVerificationCodeRepository
+	smsSender        *mockSMSSender
+	lockoutService   *mockLockoutService
+	service          *PhoneService
+}
+
+func newPhoneTestFixture() *phoneTestFixture {
+	f := &phoneTestFixture{
+		userRepo:         new(MockUserRepository),
+		verificationRepo: new(mockVerificationCodeRepository),
+		smsSender:        new(mockSMSSender),
+		lockoutService:   new(mockLockoutService),
+	}
+	f.service = NewPhoneService(f.userRepo, f.verificationRepo, f.smsSender, f.lockoutService, &config.Config{
+		MFAMaxAttempts:   5,
+		SMSCodeTTL:       10 * time.Minute,
+		SMSSendLimit:     5,
+		SMSUserSendLimit: 10,
+		SMSIPSendLimit:   20,
+		SMSSendWindow:    time.Hour,
+	}, zap.NewNop())
+	return f
+}
+
+// captureCode records the code stored for the user and the message sent to the phone
+func (f *phoneTestFixture) captureCode(codeType domain.VerificationCodeType, phone string, channel domain.SMSChannel) (*domain.VerificationCode, *string) {
+	stored := &domain.VerificationCode{}
+	message := new(string)
+	f.lockoutService.On("Throttle", mock.Anything, domain.SMSSendKey(phone), 5, time.Hour).Return(nil)
+	f.lockoutService.On("Throttle", mock.Anything, mock.MatchedBy(func(key string) bool {
+		return strings.HasPrefix(key, "sms_user:")
+	}), 10, time.Hour).Return(nil)
+	f.verificationRepo.On("DeleteByUserIDAndType", mock.Anything, mock.Anything, codeType).Return(nil)
+	f.verificationRepo.On("Create", mock.Anything, mock.MatchedBy(func(code *domain.VerificationCode) bool {
+		*stored = *code
+		return code.Type == codeType
+	})).Return(nil)
+	f.smsSender.On("Send", mock.Anything, phone, channel, mock.MatchedBy(func(m string) bool {
+		*message = m
+		return true
+	})).Return(nil)
+	return stored, message
+}
+
+func TestPhoneService_RequestVerification(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+
+	t.Run("texts a hashed code to the normalized number", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		stored, message := f.captureCode(domain.PhoneVerification, "+14155550123", domain.SMSChannelText)
+
+		require.NoError(t, f.service.RequestVerification(context.Background(), user.ID.String(), "+1 (415) 555-0123", ""))
+
+		code := (*message)[len("Your phone verification code is "):][:smsCodeLength]
+		assert.Equal(t, "+14155550123", stored.Target)
+		assert.Equal(t, hashOneTimeSecret(user.ID, code), stored.Code, "codes are stored hashed")
+		f.smsSender.AssertExpectations(t)
+	})
+
+	t.Run("voice calls read the digits one by one", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		_, message := f.captureCode(domain.PhoneVerification, "+14155550123", domain.SMSChannelVoice)
+
+		require.NoError(t, f.service.RequestVerification(context.Background(), user.ID.String(), "+14155550123", domain.SMSChannelVoice))
+		assert.Regexp(t, `^Your phone verification code is \d(, \d){5}\.$`, *message)
+	})
+
+	t.Run("invalid number", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+
+		err := f.service.RequestVerification(context.Background(), user.ID.String(), "4155550123", "")
+		assert.Equal(t, domain.ErrInvalidPhone, err)
+		f.smsSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("too many messages to the number", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.lockoutService.On("Throttle", mock.Anything, domain.SMSSendKey("+14155550123"), 5, time.Hour).Return(domain.ErrTooManyAttempts)
+
+		err := f.service.RequestVerification(context.Background(), user.ID.String(), "+14155550123", "")
+		assert.Equal(t, domain.ErrTooManyAttempts, err)
+		f.verificationRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("too many messages for the user", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.lockoutService.On("Throttle", mock.Anything, domain.SMSSendKey("+14155550123"), 5, time.Hour).Return(nil)
+		f.lockoutService.On("Throttle", mock.Anything, domain.SMSUserSendKey(user.ID.String()), 10, time.Hour).Return(domain.ErrTooManyAttempts)
+
+		err := f.service.RequestVerification(context.Background(), user.ID.String(), "+14155550123", "")
+		assert.Equal(t, domain.ErrTooManyAttempts, err)
+		f.smsSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("too many messages from the client IP", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.lockoutService.On("Throttle", mock.Anything, domain.SMSSendKey("+14155550123"), 5, time.Hour).Return(nil)
+		f.lockoutService.On("Throttle", mock.Anything, domain.SMSUserSendKey(user.ID.String()), 10, time.Hour).Return(nil)
+		f.lockoutService.On("Throttle", mock.Anything, domain.SMSIPSendKey("203.0.113.7"), 20, time.Hour).Return(domain.ErrTooManyAttempts)
+
+		ctx := domain.WithClientIP(context.Background(), "203.0.113.7")
+		err := f.service.RequestVerification(ctx, user.ID.String(), "+14155550123", "")
+		assert.Equal(t, domain.ErrTooManyAttempts, err)
+		f.smsSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("provider failure", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.lockoutService.On("Throttle", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		f.verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.PhoneVerification).Return(nil)
+		f.verificationRepo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		f.smsSender.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("gateway down"))
+
+		err := f.service.RequestVerification(context.Background(), user.ID.String(), "+14155550123", "")
+		assert.Equal(t, domain.ErrSMSSendFailed, err)
+	})
+}
+
+func TestPhoneService_ConfirmVerification(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com"}
+	stored := domain.NewVerificationCode(user.ID, hashOneTimeSecret(user.ID, "123456"), domain.PhoneVerification, 10*time.Minute)
+	stored.Target = "+14155550123"
+
+	setup := func() *phoneTestFixture {
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.lockoutService.On("Throttle", mock.Anything, domain.PhoneVerificationKey(user.ID.String()), 5, 10*time.Minute).Return(nil)
+		f.verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.PhoneVerification).Return(stored, nil)
+		return f
+	}
+
+	t.Run("sent code", func(t *testing.T) {
+		f := setup()
+		f.verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.PhoneVerification).Return(nil)
+		f.userRepo.On("UpdatePhone", mock.Anything, user.ID, "+14155550123").Return(nil)
+
+		assert.NoError(t, f.service.ConfirmVerification(context.Background(), user.ID.String(), "123456"))
+		f.userRepo.AssertExpectations(t)
+		f.verificationRepo.AssertExpectations(t)
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		f := setup()
+
+		err := f.service.ConfirmVerification(context.Background(), user.ID.String(), "654321")
+		assert.Equal(t, domain.ErrInvalidVerificationCode, err)
+		f.userRepo.AssertNotCalled(t, "UpdatePhone", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		expired := *stored
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		f := newPhoneTestFixture()
+		f.userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+		f.lockoutService.On("Throttle", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		f.verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.PhoneVerification).Return(&expired, nil)
+		f.verificationRepo.On("DeleteByUserIDAndType", mock.Anything, user.ID, domain.PhoneVerification).Return(nil)
+
+		err := f.service.ConfirmVerification(context.Background(), user.ID.String(), "123456")
+		assert.Equal(t, domain.ErrVerificationCodeExpired, err)
+		f.userRepo.AssertNotCalled(t, "UpdatePhone", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestPhoneService_SendCode(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Phone: "+14155550123", PhoneVerified: true}
+
+	t.Run("verified phone", func(t *testing.T) {
+		f := newPhoneTestFixture()
+		stored, message := f.captureCode(domain.MFASMSCode, user.Phone, domain.SMSChannelText)
+
+		require.NoError(t, f.service.SendCode(context.Background(), user, domain.SMSChannelText))
+		assert.Contains(t, *message, "Your sign-in code is ")
+		assert.Equal(t, user.Phone, stored.Target)
+
+		code := (*message)[len("Your sign-in code is "):][:smsCodeLength]
+		f.verificationRepo.On("FindByUserIDAndType", mock.Anything, user.ID, domain.MFASMSCode).Return(stored, nil)
+		assert.NoError(t, f.service.VerifyCode(context.Background(), user, code))
+	})
+
+	t.Run("unverified phone", func(t *testing.T) {
+		unverified := *user
+		unverified.PhoneVerified = false
+		f := newPhoneTestFixture()
+
+		err := f.service.SendCode(context.Background(), &unverified, domain.SMSChannelText)
+		assert.Equal(t, domain.ErrPhoneNotVerified, err)
+		f.smsSender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestMaskPhoneNumber(t *testing.T) {
+	assert.Equal(t, "+*******0123", maskPhoneNumber("+14155550123"))
+}
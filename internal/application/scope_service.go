@@ -0,0 +1,165 @@
+package application
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"go.uber.org/zap"
+)
+
+type ScopeService struct {
+	scopeRepo domain.ScopeRepository
+	logger    *zap.Logger
+}
+
+func NewScopeService(scopeRepo domain.ScopeRepository, logger *zap.Logger) *ScopeService {
+	return &ScopeService{
+		scopeRepo: scopeRepo,
+		logger:    logger,
+	}
+}
+
+// CreateScope registers a new scope
+func (s *ScopeService) CreateScope(ctx context.Context, scope *domain.Scope) error {
+	if err := validateScopeClaims(scope); err != nil {
+		return err
+	}
+
+	if _, err := s.scopeRepo.FindByName(ctx, scope.Name); err == nil {
+		return domain.ErrScopeAlreadyExists
+	} else if err != domain.ErrScopeNotFound {
+		return err
+	}
+
+	now := time.Now()
+	scope.CreatedAt = now
+	scope.UpdatedAt = now
+
+	if err := s.scopeRepo.Create(ctx, scope); err != nil {
+		s.logger.Error("Failed to create scope",
+			zap.String("scope", scope.Name),
+			zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetScope retrieves a scope by name
+func (s *ScopeService) GetScope(ctx context.Context, name string) (*domain.Scope, error) {
+	return s.scopeRepo.FindByName(ctx, name)
+}
+
+// ListScopes lists all registered scopes
+func (s *ScopeService) ListScopes(ctx context.Context) ([]*domain.Scope, error) {
+	return s.scopeRepo.List(ctx)
+}
+
+// UpdateScope updates a registered scope
+func (s *ScopeService) UpdateScope(ctx context.Context, scope *domain.Scope) error {
+	if err := validateScopeClaims(scope); err != nil {
+		return err
+	}
+
+	existing, err := s.scopeRepo.FindByName(ctx, scope.Name)
+	if err != nil {
+		return err
+	}
+
+	scope.CreatedAt = existing.CreatedAt
+	scope.UpdatedAt = time.Now()
+
+	if err := s.scopeRepo.Update(ctx, scope); err != nil {
+		s.logger.Error("Failed to update scope",
+			zap.String("scope", scope.Name),
+			zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteScope removes a scope from the registry
+func (s *ScopeService) DeleteScope(ctx context.Context, name string) error {
+	if _, err := s.scopeRepo.FindByName(ctx, name); err != nil {
+		return err
+	}
+
+	return s.scopeRepo.Delete(ctx, name)
+}
+
+// ValidateScopes checks that every scope is registered and may be granted to the client
+func (s *ScopeService) ValidateScopes(ctx context.Context, clientID string, scopes []string) error {
+	for _, name := range scopes {
+		scope, err := s.scopeRepo.FindByName(ctx, name)
+		if err != nil {
+			if err == domain.ErrScopeNotFound {
+				s.logger.Error("Unregistered scope requested",
+					zap.String("scope", name),
+					zap.String("client_id", clientID))
+				return domain.ErrInvalidScope
+			}
+			return err
+		}
+
+		if !scope.AllowsClient(clientID) {
+			s.logger.Error("Scope not allowed for client",
+				zap.String("scope", name),
+				zap.String("client_id", clientID))
+			return domain.ErrInvalidScope
+		}
+	}
+
+	return nil
+}
+
+// ResolveClaims returns the claims released by the scopes plus the individually requested claims.
+// Unregistered scopes and unsupported claims are ignored, as the claims request parameter allows.
+func (s *ScopeService) ResolveClaims(ctx context.Context, scopes []string, requested map[string]*domain.ClaimRequest) ([]string, error) {
+	claims := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(claim string) {
+		if !seen[claim] {
+			seen[claim] = true
+			claims = append(claims, claim)
+		}
+	}
+
+	for _, name := range scopes {
+		scope, err := s.scopeRepo.FindByName(ctx, name)
+		if err != nil {
+			if err == domain.ErrScopeNotFound {
+				continue
+			}
+			return nil, err
+		}
+		for _, claim := range scope.Claims {
+			add(claim)
+		}
+	}
+
+	names := make([]string, 0, len(requested))
+	for claim := range requested {
+		names = append(names, claim)
+	}
+	sort.Strings(names)
+	for _, claim := range names {
+		if domain.IsSupportedClaim(claim) {
+			add(claim)
+		}
+	}
+
+	return claims, nil
+}
+
+// validateScopeClaims ensures a scope only maps to claims the service can release
+func validateScopeClaims(scope *domain.Scope) error {
+	for _, claim := range scope.Claims {
+		if !domain.IsSupportedClaim(claim) {
+			return domain.ErrUnsupportedClaim
+		}
+	}
+	return nil
+}
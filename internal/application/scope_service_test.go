@@ -0,0 +1,214 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// MockScopeRepository is a mock implementation of domain.ScopeRepository
+type MockScopeRepository struct {
+	mock.Mock
+}
+
+func (m *MockScopeRepository) Create(ctx context.Context, scope *domain.Scope) error {
+	args := m.Called(ctx, scope)
+	return args.Error(0)
+}
+
+func (m *MockScopeRepository) FindByName(ctx context.Context, name string) (*domain.Scope, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Scope), args.Error(1)
+}
+
+func (m *MockScopeRepository) List(ctx context.Context) ([]*domain.Scope, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Scope), args.Error(1)
+}
+
+func (m *MockScopeRepository) Update(ctx context.Context, scope *domain.Scope) error {
+	args := m.Called(ctx, scope)
+	return args.Error(0)
+}
+
+func (m *MockScopeRepository) Delete(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+// standardScopes mirrors the scopes seeded by the migrations plus a client-restricted scope
+var standardScopes = []*domain.Scope{
+	{Name: "email", Claims: []string{"email", "email_verified"}},
+	{Name: "openid", Claims: []string{"sub"}},
+	{Name: "phone", Claims: []string{"phone_number", "phone_number_verified"}},
+	{Name: "profile", Claims: []string{"name", "updated_at"}},
+	{Name: "roles", Claims: []string{"roles"}},
+}
+
+var restrictedScope = &domain.Scope{Name: "admin", Claims: []string{"roles"}, AllowedClients: []string{"admin-console"}}
+
+var consentScope = &domain.Scope{Name: "payments", RequiresConsent: true}
+
+// newStandardScopeService returns a scope service backed by the standard scopes
+func newStandardScopeService() *ScopeService {
+	repo := new(MockScopeRepository)
+	for _, scope := range standardScopes {
+		repo.On("FindByName", mock.Anything, scope.Name).Return(scope, nil).Maybe()
+	}
+	repo.On("FindByName", mock.Anything, restrictedScope.Name).Return(restrictedScope, nil).Maybe()
+	repo.On("FindByName", mock.Anything, consentScope.Name).Return(consentScope, nil).Maybe()
+	repo.On("FindByName", mock.Anything, mock.Anything).Return(nil, domain.ErrScopeNotFound).Maybe()
+	repo.On("List", mock.Anything).Return(standardScopes, nil).Maybe()
+	return NewScopeService(repo, zap.NewNop())
+}
+
+func TestScopeService_CreateScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		scope     *domain.Scope
+		setupMock func(*MockScopeRepository)
+		wantErr   error
+	}{
+		{
+			name:  "success",
+			scope: &domain.Scope{Name: "address", Claims: []string{"name"}},
+			setupMock: func(m *MockScopeRepository) {
+				m.On("FindByName", mock.Anything, "address").Return(nil, domain.ErrScopeNotFound)
+				m.On("Create", mock.Anything, mock.AnythingOfType("*domain.Scope")).Return(nil)
+			},
+		},
+		{
+			name:  "already exists",
+			scope: &domain.Scope{Name: "email", Claims: []string{"email"}},
+			setupMock: func(m *MockScopeRepository) {
+				m.On("FindByName", mock.Anything, "email").Return(&domain.Scope{Name: "email"}, nil)
+			},
+			wantErr: domain.ErrScopeAlreadyExists,
+		},
+		{
+			name:      "unsupported claim",
+			scope:     &domain.Scope{Name: "address", Claims: []string{"address"}},
+			setupMock: func(m *MockScopeRepository) {},
+			wantErr:   domain.ErrUnsupportedClaim,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockScopeRepository)
+			tt.setupMock(mockRepo)
+
+			service := NewScopeService(mockRepo, zap.NewNop())
+			err := service.CreateScope(context.Background(), tt.scope)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.False(t, tt.scope.CreatedAt.IsZero())
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestScopeService_ValidateScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		scopes   []string
+		wantErr  error
+	}{
+		{
+			name:     "registered scopes",
+			clientID: "test-client",
+			scopes:   []string{"openid", "profile", "email"},
+		},
+		{
+			name:     "unregistered scope",
+			clientID: "test-client",
+			scopes:   []string{"openid", "unknown"},
+			wantErr:  domain.ErrInvalidScope,
+		},
+		{
+			name:     "restricted scope for allowed client",
+			clientID: "admin-console",
+			scopes:   []string{"admin"},
+		},
+		{
+			name:     "restricted scope for other client",
+			clientID: "test-client",
+			scopes:   []string{"admin"},
+			wantErr:  domain.ErrInvalidScope,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newStandardScopeService()
+			err := service.ValidateScopes(context.Background(), tt.clientID, tt.scopes)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestScopeService_ResolveClaims(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		requested map[string]*domain.ClaimRequest
+		want      []string
+	}{
+		{
+			name:   "scope claims",
+			scopes: []string{"openid", "email", "phone"},
+			want:   []string{"sub", "email", "email_verified", "phone_number", "phone_number_verified"},
+		},
+		{
+			name:   "duplicate claims are released once",
+			scopes: []string{"roles", "admin"},
+			want:   []string{"roles"},
+		},
+		{
+			name:   "requested claims",
+			scopes: []string{"openid"},
+			requested: map[string]*domain.ClaimRequest{
+				"name":    {Essential: true},
+				"email":   nil,
+				"address": nil,
+			},
+			want: []string{"sub", "email", "name"},
+		},
+		{
+			name:   "unregistered scopes are ignored",
+			scopes: []string{"unknown"},
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newStandardScopeService()
+			claims, err := service.ResolveClaims(context.Background(), tt.scopes, tt.requested)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, claims)
+		})
+	}
+}
@@ -5,75 +5,104 @@ import (
 	"time"
 
 	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 )
 
+// defaultTOTPLabel names an authenticator app enrolled without a label
+const defaultTOTPLabel = "Authenticator app"
+
 // totpServiceImpl implements the TOTPService interface
 type totpServiceImpl struct {
-	repo      domain.TOTPRepository
-	generator domain.TOTPGenerator
-	logger    *zap.Logger
+	repo           domain.TOTPRepository
+	factorRepo     domain.MFAFactorRepository
+	generator      domain.TOTPGenerator
+	cipher         domain.SecretCipher
+	userRepo       domain.UserRepository
+	emailService   domain.EmailService
+	lockoutService domain.LockoutService
+	config         *config.Config
+	logger         *zap.Logger
 }
 
 // NewTOTPService creates a new TOTP service
-func NewTOTPService(repo domain.TOTPRepository, generator domain.TOTPGenerator, logger *zap.Logger) domain.TOTPService {
+func NewTOTPService(repo domain.TOTPRepository, factorRepo domain.MFAFactorRepository, generator domain.TOTPGenerator, cipher domain.SecretCipher, userRepo domain.UserRepository, emailService domain.EmailService, lockoutService domain.LockoutService, config *config.Config, logger *zap.Logger) domain.TOTPService {
 	return &totpServiceImpl{
-		repo:      repo,
-		generator: generator,
-		logger:    logger,
+		repo:           repo,
+		factorRepo:     factorRepo,
+		generator:      generator,
+		cipher:         cipher,
+		userRepo:       userRepo,
+		emailService:   emailService,
+		lockoutService: lockoutService,
+		config:         config,
+		logger:         logger,
 	}
 }
 
-// EnableTOTP enables TOTP for a user
-func (s *totpServiceImpl) EnableTOTP(userID string) (*domain.TOTP, error) {
-	secret, err := s.repo.GetTOTPSecret(context.Background(), userID)
-	if err != nil && err != domain.ErrTOTPNotEnabled {
-		s.logger.Error("Failed to get TOTP secret",
-			zap.String("user_id", userID),
-			zap.Error(err))
-		return nil, err
+// EnableTOTP starts enrolling an authenticator app for a user. The app is kept pending, and does
+// not count as a factor, until ConfirmTOTP receives a code it generated; starting again replaces
+// a pending app.
+func (s *totpServiceImpl) EnableTOTP(userID, label string) (*domain.TOTP, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
 	}
-	if secret != "" {
-		return nil, domain.ErrTOTPAlreadyEnabled
+	if label == "" {
+		label = defaultTOTPLabel
 	}
 
-	secret, err = s.generator.GenerateSecret()
+	// Authenticator apps list the account under the user's email
+	user, err := s.userRepo.FindByID(context.Background(), id)
 	if err != nil {
-		s.logger.Error("Failed to generate TOTP secret",
+		s.logger.Error("Failed to find user to enrol TOTP",
 			zap.String("user_id", userID),
 			zap.Error(err))
-		return nil, err
+		return nil, domain.ErrUserNotFound
 	}
 
-	backupCodes, err := s.generator.GenerateBackupCodes(10)
+	secret, err := s.generator.GenerateSecret()
 	if err != nil {
-		s.logger.Error("Failed to generate backup codes",
+		s.logger.Error("Failed to generate TOTP secret",
 			zap.String("user_id", userID),
 			zap.Error(err))
 		return nil, err
 	}
 
-	if err := s.repo.SaveTOTPSecret(context.Background(), userID, secret); err != nil {
-		s.logger.Error("Failed to save TOTP secret",
+	sealedSecret, err := s.cipher.Encrypt(context.Background(), secret)
+	if err != nil {
+		s.logger.Error("Failed to encrypt TOTP secret",
 			zap.String("user_id", userID),
 			zap.Error(err))
 		return nil, err
 	}
 
-	if err := s.repo.SaveBackupCodes(context.Background(), userID, backupCodes); err != nil {
-		s.logger.Error("Failed to save backup codes",
+	now := time.Now()
+	enrollment := &domain.TOTPEnrollment{
+		UserID:    id,
+		Secret:    sealedSecret,
+		Label:     label,
+		Algorithm: s.config.TOTPAlgorithm,
+		Digits:    s.config.TOTPDigits,
+		Period:    s.config.TOTPPeriod,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.config.TOTPEnrollmentTTL),
+	}
+	if err := s.repo.SavePendingTOTP(context.Background(), enrollment); err != nil {
+		s.logger.Error("Failed to save pending TOTP enrolment",
 			zap.String("user_id", userID),
 			zap.Error(err))
 		return nil, err
 	}
 
 	config := &domain.TOTPConfig{
-		Issuer:      "User Manager Service",
-		AccountName: userID,
+		Issuer:      s.config.TOTPIssuer,
+		AccountName: user.Email,
 		Secret:      secret,
-		Period:      30 * time.Second,
-		Digits:      6,
-		Algorithm:   "SHA1",
+		Period:      enrollment.Period,
+		Digits:      enrollment.Digits,
+		Algorithm:   enrollment.Algorithm,
 	}
 
 	qrCode, err := s.generator.GenerateQRCode(config)
@@ -83,30 +112,149 @@ func (s *totpServiceImpl) EnableTOTP(userID string) (*domain.TOTP, error) {
 			zap.Error(err))
 		return nil, err
 	}
-	return &domain.TOTP{QRCode: qrCode, BackupCodes: backupCodes}, nil
+	return &domain.TOTP{
+		QRCode:     qrCode.PNG,
+		QRCodeSVG:  qrCode.SVG,
+		OTPAuthURI: qrCode.URI,
+	}, nil
 }
 
-// VerifyTOTP verifies a TOTP code for a user
+// ConfirmTOTP activates the user's pending authenticator app once it produces a valid code.
+// Backup codes are issued with the first app only; later apps share them.
+func (s *totpServiceImpl) ConfirmTOTP(ctx context.Context, userID, code string) (*domain.TOTP, error) {
+	enrollment, err := s.repo.GetPendingTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.IsExpired() {
+		return nil, domain.ErrTOTPEnrollmentNotFound
+	}
+
+	secret, err := s.cipher.Decrypt(ctx, enrollment.Secret)
+	if err != nil {
+		s.logger.Error("Failed to decrypt pending TOTP secret",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	step, err := s.generator.ValidateCode(&domain.TOTPConfig{
+		Secret:    secret,
+		Period:    enrollment.Period,
+		Digits:    enrollment.Digits,
+		Algorithm: enrollment.Algorithm,
+	}, code)
+	if err != nil {
+		s.logger.Debug("Invalid TOTP enrolment code",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	factor := domain.NewMFAFactor(enrollment.UserID, domain.MFAFactorTOTP, enrollment.Label)
+	if err := s.repo.ActivatePendingTOTP(ctx, factor, enrollment.Secret, step); err != nil {
+		s.logger.Error("Failed to activate TOTP enrolment",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	_, err = s.repo.GetBackupCodes(ctx, userID)
+	if err != nil && err != domain.ErrTOTPNotEnabled {
+		s.logger.Error("Failed to get backup codes",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	var backupCodes []string
+	if err == domain.ErrTOTPNotEnabled {
+		if backupCodes, err = s.issueBackupCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &domain.TOTP{FactorID: factor.ID.String(), BackupCodes: backupCodes}, nil
+}
+
+// VerifyTOTP verifies a TOTP code against each of the user's authenticator apps
 func (s *totpServiceImpl) VerifyTOTP(userID, code string) error {
-	secret, err := s.repo.GetTOTPSecret(context.Background(), userID)
+	secrets, err := s.repo.ListTOTPSecrets(context.Background(), userID)
+	if err != nil {
+		s.logger.Error("Failed to get TOTP secrets",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return err
+	}
+
+	// A code matching an app but already used is not tried against the other apps
+	for _, secret := range secrets {
+		if err = s.verify(context.Background(), secret, code); err == nil || err == domain.ErrTOTPCodeReused {
+			break
+		}
+	}
+	if err != nil {
+		s.logger.Error("Failed to validate TOTP code",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// VerifyTOTPFactor verifies a TOTP code against one of the user's authenticator apps
+func (s *totpServiceImpl) VerifyTOTPFactor(ctx context.Context, userID, factorID, code string) error {
+	secret, err := s.repo.GetTOTPSecret(ctx, userID, factorID)
 	if err != nil {
 		s.logger.Error("Failed to get TOTP secret",
 			zap.String("user_id", userID),
+			zap.String("factor_id", factorID),
 			zap.Error(err))
 		return err
 	}
 
-	if err := s.generator.ValidateCode(secret, code); err != nil {
+	if err := s.verify(ctx, secret, code); err != nil {
 		s.logger.Error("Failed to validate TOTP code",
 			zap.String("user_id", userID),
+			zap.String("factor_id", factorID),
+			zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// verify checks a code against one authenticator app and consumes its time-step, so neither the
+// code nor an earlier one of the same app is accepted again
+func (s *totpServiceImpl) verify(ctx context.Context, secret *domain.TOTPSecret, code string) error {
+	plaintext, err := s.cipher.Decrypt(ctx, secret.Secret)
+	if err != nil {
+		s.logger.Error("Failed to decrypt TOTP secret",
+			zap.String("factor_id", secret.FactorID),
 			zap.Error(err))
 		return err
 	}
 
+	step, err := s.generator.ValidateCode(&domain.TOTPConfig{
+		Secret:    plaintext,
+		Period:    secret.Period,
+		Digits:    secret.Digits,
+		Algorithm: secret.Algorithm,
+	}, code)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.RecordTOTPStep(ctx, secret.FactorID, step); err != nil {
+		return err
+	}
+
+	s.touch(secret.FactorID)
 	return nil
 }
 
-// VerifyBackupCode verifies a backup code for a user
+// VerifyBackupCode verifies a backup code for a user and warns them by email once few are left
 func (s *totpServiceImpl) VerifyBackupCode(userID, code string) error {
 	backupCodes, err := s.repo.GetBackupCodes(context.Background(), userID)
 	if err != nil {
@@ -131,19 +279,196 @@ func (s *totpServiceImpl) VerifyBackupCode(userID, code string) error {
 		return err
 	}
 
+	s.touchRecovery(userID)
+
+	// The code that was just used is still counted in backupCodes
+	if remaining := countBackupCodes(backupCodes) - 1; remaining <= s.config.TOTPBackupCodesThreshold {
+		s.warnBackupCodesLow(userID, remaining)
+	}
 	return nil
 }
 
-// DisableTOTP disables TOTP for a user
-func (s *totpServiceImpl) DisableTOTP(userID string) error {
-	secret, err := s.repo.GetTOTPSecret(context.Background(), userID)
+// VerifyUserCode checks a code a signed-in user sends to confirm an action against their factors of
+// the given types, authenticator apps or backup codes, in turn. Like MFA logins, it is refused while
+// the user or client IP is locked out, and a code every factor rejects counts once towards the
+// lockout, so a stolen session cannot guess codes without limit.
+func (s *totpServiceImpl) VerifyUserCode(ctx context.Context, userID, code string, factorTypes ...domain.MFAFactorType) error {
+	ip, _ := domain.GetClientIP(ctx)
+	if err := s.lockoutService.Check(ctx, userID, ip); err != nil {
+		return err
+	}
+
+	var rejected error
+	for _, factorType := range factorTypes {
+		var err error
+		switch factorType {
+		case domain.MFAFactorTOTP:
+			err = s.VerifyTOTP(userID, code)
+		case domain.MFAFactorRecovery:
+			err = s.VerifyBackupCode(userID, code)
+		default:
+			return domain.ErrMFAFactorNotSupported
+		}
+
+		switch err {
+		case nil:
+			return nil
+		case domain.ErrInvalidTOTPCode, domain.ErrTOTPCodeReused, domain.ErrInvalidTOTPBackupCode:
+			if rejected == nil {
+				rejected = err
+			}
+		case domain.ErrTOTPNotEnabled:
+		default:
+			return err
+		}
+	}
+	if rejected == nil {
+		return domain.ErrTOTPNotEnabled
+	}
+
+	s.recordFailure(ctx, userID, ip)
+	return rejected
+}
+
+// recordFailure counts a rejected code towards the lockout of the user and client IP; errors are
+// only logged
+func (s *totpServiceImpl) recordFailure(ctx context.Context, userID, ip string) {
+	id, err := ulid.Parse(userID)
 	if err != nil {
-		s.logger.Error("Failed to get TOTP secret",
+		return
+	}
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to find user", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+	if err := s.lockoutService.RecordFailure(ctx, user, ip); err != nil {
+		s.logger.Error("Failed to record failed attempt", zap.String("ip", ip), zap.Error(err))
+	}
+}
+
+// BackupCodesStatus reports how many of the user's backup codes are left out of the last set issued
+func (s *totpServiceImpl) BackupCodesStatus(ctx context.Context, userID string) (*domain.BackupCodesStatus, error) {
+	backupCodes, err := s.repo.GetBackupCodes(ctx, userID)
+	if err != nil {
+		if err != domain.ErrTOTPNotEnabled {
+			s.logger.Error("Failed to get backup codes",
+				zap.String("user_id", userID),
+				zap.Error(err))
+		}
+		return nil, err
+	}
+
+	return &domain.BackupCodesStatus{
+		Remaining: countBackupCodes(backupCodes),
+		Total:     len(backupCodes),
+	}, nil
+}
+
+// RegenerateBackupCodes replaces the user's backup codes with a new set. The request must carry a
+// fresh code from one of the user's authenticator apps or, without one, come from a session that
+// completed a second factor of any kind within StepUpMaxAge, so a stolen password alone cannot
+// mint codes. Rejected codes count towards the user's lockout.
+func (s *totpServiceImpl) RegenerateBackupCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if code != "" {
+		if err := s.VerifyUserCode(ctx, userID, code, domain.MFAFactorTOTP); err != nil {
+			return nil, err
+		}
+	} else if !s.recentMFA(ctx) {
+		s.logger.Warn("Backup codes regeneration without a recent second factor",
+			zap.String("user_id", userID))
+		return nil, domain.ErrInsufficientUserAuthentication
+	}
+
+	backupCodes, err := s.issueBackupCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Backup codes regenerated", zap.String("user_id", userID))
+	return backupCodes, nil
+}
+
+// recentMFA reports whether the session in the context completed a second factor, recently enough
+// when StepUpMaxAge is set
+func (s *totpServiceImpl) recentMFA(ctx context.Context) bool {
+	auth, ok := domain.GetAuthentication(ctx)
+	if !ok || !auth.Satisfies(domain.ACRMultiFactor) {
+		return false
+	}
+	return s.config.StepUpMaxAge <= 0 || time.Since(auth.Time) <= s.config.StepUpMaxAge
+}
+
+// issueBackupCodes generates a set of backup codes and stores their hashes in place of any
+// earlier set, returning the codes in plaintext for the user to write down
+func (s *totpServiceImpl) issueBackupCodes(ctx context.Context, userID string) ([]string, error) {
+	backupCodes, err := s.generator.GenerateBackupCodes(s.config.TOTPBackupCodesCount)
+	if err != nil {
+		s.logger.Error("Failed to generate backup codes",
 			zap.String("user_id", userID),
 			zap.Error(err))
+		return nil, err
+	}
+
+	hashedCodes := make([]string, len(backupCodes))
+	for i, backupCode := range backupCodes {
+		if hashedCodes[i], err = s.generator.HashBackupCode(backupCode); err != nil {
+			s.logger.Error("Failed to hash backup codes",
+				zap.String("user_id", userID),
+				zap.Error(err))
+			return nil, err
+		}
+	}
+
+	if err := s.repo.SaveBackupCodes(ctx, userID, hashedCodes); err != nil {
+		s.logger.Error("Failed to save backup codes",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return backupCodes, nil
+}
+
+// warnBackupCodesLow emails the user how many backup codes are left. The code was already
+// accepted, so a failure is only logged.
+func (s *totpServiceImpl) warnBackupCodesLow(userID string, remaining int) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return
+	}
+	user, err := s.userRepo.FindByID(context.Background(), id)
+	if err != nil {
+		s.logger.Error("Failed to find user to warn about backup codes",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return
+	}
+	if err := s.emailService.SendBackupCodesLowEmail(context.Background(), user.Email, remaining); err != nil {
+		s.logger.Error("Failed to send backup codes low email",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	}
+}
+
+// countBackupCodes counts the codes of a set that were not used yet
+func countBackupCodes(backupCodes []string) int {
+	remaining := 0
+	for _, backupCode := range backupCodes {
+		if backupCode != "" {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// DisableTOTP removes every authenticator app and the backup codes of a user
+func (s *totpServiceImpl) DisableTOTP(userID string) error {
+	enabled, err := s.IsTOTPEnabled(context.Background(), userID)
+	if err != nil {
 		return err
 	}
-	if secret == "" {
+	if !enabled {
 		return domain.ErrTOTPNotEnabled
 	}
 
@@ -157,16 +482,46 @@ func (s *totpServiceImpl) DisableTOTP(userID string) error {
 	return nil
 }
 
-// GetTOTPSecret retrieves the TOTP secret for a user
-func (s *totpServiceImpl) GetTOTPSecret(ctx context.Context, userID string) (string, error) {
-	secret, err := s.repo.GetTOTPSecret(ctx, userID)
+// IsTOTPEnabled reports whether the user has enrolled an authenticator app
+func (s *totpServiceImpl) IsTOTPEnabled(ctx context.Context, userID string) (bool, error) {
+	secrets, err := s.repo.ListTOTPSecrets(ctx, userID)
 	if err != nil {
-		if err != domain.ErrTOTPNotEnabled {
-			s.logger.Error("Failed to get TOTP secret",
-				zap.String("user_id", userID),
-				zap.Error(err))
+		if err == domain.ErrTOTPNotEnabled {
+			return false, nil
+		}
+		s.logger.Error("Failed to get TOTP secrets",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return false, err
+	}
+	return len(secrets) > 0, nil
+}
+
+// touch records the use of a factor; a failure only loses the last-used time
+func (s *totpServiceImpl) touch(factorID string) {
+	id, err := ulid.Parse(factorID)
+	if err != nil {
+		return
+	}
+	if err := s.factorRepo.Touch(context.Background(), id, time.Now()); err != nil {
+		s.logger.Warn("Failed to record MFA factor use", zap.String("factor_id", factorID), zap.Error(err))
+	}
+}
+
+// touchRecovery records the use of the user's backup codes
+func (s *totpServiceImpl) touchRecovery(userID string) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return
+	}
+	factors, err := s.factorRepo.ListByUser(context.Background(), id)
+	if err != nil {
+		s.logger.Warn("Failed to record MFA factor use", zap.String("user_id", userID), zap.Error(err))
+		return
+	}
+	for _, factor := range factors {
+		if factor.Type == domain.MFAFactorRecovery {
+			s.touch(factor.ID.String())
 		}
-		return "", err
 	}
-	return secret, nil
 }
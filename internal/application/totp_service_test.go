@@ -2,9 +2,13 @@ package application
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
@@ -15,14 +19,30 @@ type MockTOTPRepository struct {
 	mock.Mock
 }
 
-func (m *MockTOTPRepository) SaveTOTPSecret(ctx context.Context, userID string, secret string) error {
-	args := m.Called(ctx, userID, secret)
+func (m *MockTOTPRepository) SaveTOTPSecret(ctx context.Context, factor *domain.MFAFactor, secret string) error {
+	args := m.Called(ctx, factor, secret)
 	return args.Error(0)
 }
 
-func (m *MockTOTPRepository) GetTOTPSecret(ctx context.Context, userID string) (string, error) {
+func (m *MockTOTPRepository) GetTOTPSecret(ctx context.Context, userID, factorID string) (*domain.TOTPSecret, error) {
+	args := m.Called(ctx, userID, factorID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTPSecret), args.Error(1)
+}
+
+func (m *MockTOTPRepository) ListTOTPSecrets(ctx context.Context, userID string) ([]*domain.TOTPSecret, error) {
 	args := m.Called(ctx, userID)
-	return args.String(0), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.TOTPSecret), args.Error(1)
+}
+
+func (m *MockTOTPRepository) RecordTOTPStep(ctx context.Context, factorID string, step int64) error {
+	args := m.Called(ctx, factorID, step)
+	return args.Error(0)
 }
 
 func (m *MockTOTPRepository) SaveBackupCodes(ctx context.Context, userID string, codes []string) error {
@@ -40,6 +60,24 @@ func (m *MockTOTPRepository) MarkBackupCodeAsUsed(ctx context.Context, userID st
 	return args.Error(0)
 }
 
+func (m *MockTOTPRepository) SavePendingTOTP(ctx context.Context, enrollment *domain.TOTPEnrollment) error {
+	args := m.Called(ctx, enrollment)
+	return args.Error(0)
+}
+
+func (m *MockTOTPRepository) GetPendingTOTP(ctx context.Context, userID string) (*domain.TOTPEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTPEnrollment), args.Error(1)
+}
+
+func (m *MockTOTPRepository) ActivatePendingTOTP(ctx context.Context, factor *domain.MFAFactor, secret string, step int64) error {
+	args := m.Called(ctx, factor, secret, step)
+	return args.Error(0)
+}
+
 func (m *MockTOTPRepository) DeleteTOTPConfig(ctx context.Context, userID string) error {
 	args := m.Called(ctx, userID)
 	return args.Error(0)
@@ -55,9 +93,12 @@ func (m *MockTOTPGenerator) GenerateSecret() (string, error) {
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockTOTPGenerator) GenerateQRCode(config *domain.TOTPConfig) (string, error) {
+func (m *MockTOTPGenerator) GenerateQRCode(config *domain.TOTPConfig) (*domain.TOTPQRCode, error) {
 	args := m.Called(config)
-	return args.String(0), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTPQRCode), args.Error(1)
 }
 
 func (m *MockTOTPGenerator) GenerateBackupCodes(count int) ([]string, error) {
@@ -65,9 +106,24 @@ func (m *MockTOTPGenerator) GenerateBackupCodes(count int) ([]string, error) {
 	return args.Get(0).([]string), args.Error(1)
 }
 
-func (m *MockTOTPGenerator) ValidateCode(secret string, code string) error {
-	args := m.Called(secret, code)
-	return args.Error(0)
+func (m *MockTOTPGenerator) ValidateCode(config *domain.TOTPConfig, code string) (int64, error) {
+	args := m.Called(config, code)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// withSecret matches the TOTP config of a plaintext secret
+func withSecret(secret string) interface{} {
+	return mock.MatchedBy(func(c *domain.TOTPConfig) bool { return c.Secret == secret })
+}
+
+// sealedTOTPSecret is an authenticator app with the default parameters
+func sealedTOTPSecret(factorID, secret string) *domain.TOTPSecret {
+	return &domain.TOTPSecret{FactorID: factorID, Secret: "sealed:" + secret, Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}
+}
+
+func (m *MockTOTPGenerator) HashBackupCode(code string) (string, error) {
+	args := m.Called(code)
+	return args.String(0), args.Error(1)
 }
 
 func (m *MockTOTPGenerator) ValidateBackupCode(backupCodes []string, code string) (int, error) {
@@ -75,49 +131,110 @@ func (m *MockTOTPGenerator) ValidateBackupCode(backupCodes []string, code string
 	return args.Int(0), args.Error(1)
 }
 
+// prefixCipher stands in for the secret cipher by marking sealed secrets with a prefix
+type prefixCipher struct{}
+
+func (prefixCipher) Encrypt(_ context.Context, plaintext string) (string, error) {
+	return "sealed:" + plaintext, nil
+}
+
+func (prefixCipher) Decrypt(_ context.Context, ciphertext string) (string, error) {
+	plaintext, found := strings.CutPrefix(ciphertext, "sealed:")
+	if !found {
+		return "", domain.ErrSecretEncryption
+	}
+	return plaintext, nil
+}
+
+// testTOTPConfig keeps pending enrolments for ten minutes
+var testTOTPConfig = &config.Config{
+	TOTPEnrollmentTTL:        10 * time.Minute,
+	TOTPIssuer:               "AuthM",
+	TOTPAlgorithm:            "SHA256",
+	TOTPDigits:               8,
+	TOTPPeriod:               60 * time.Second,
+	TOTPBackupCodesCount:     10,
+	TOTPBackupCodesThreshold: 1,
+}
+
 func TestTOTPService_EnableTOTP(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
 	mockRepo := new(MockTOTPRepository)
+	mockFactorRepo := new(mockMFAFactorRepository)
 	mockGenerator := new(MockTOTPGenerator)
-	service := NewTOTPService(mockRepo, mockGenerator, logger)
+	mockUserRepo := new(MockUserRepository)
+	service := NewTOTPService(mockRepo, mockFactorRepo, mockGenerator, prefixCipher{}, mockUserRepo, new(mockEmailService), nil, testTOTPConfig, logger)
+	userID := ulid.Make().String()
+	user := &domain.User{ID: ulid.MustParse(userID), Email: "user@example.com"}
+	qrCode := &domain.TOTPQRCode{URI: "otpauth://totp/AuthM:user@example.com", PNG: "data:image/png;base64,", SVG: "<svg/>"}
+
+	// The QR code carries the configured parameters, listed under the issuer and the user's email
+	isEnrolledConfig := mock.MatchedBy(func(c *domain.TOTPConfig) bool {
+		return c.Secret == "secret" && c.Algorithm == "SHA256" && c.Digits == 8 && c.Period == 60*time.Second &&
+			c.Issuer == "AuthM" && c.AccountName == user.Email
+	})
+	isPending := func(label string) interface{} {
+		return mock.MatchedBy(func(e *domain.TOTPEnrollment) bool {
+			ttl := e.ExpiresAt.Sub(e.CreatedAt)
+			return e.UserID.String() == userID && e.Secret == "sealed:secret" && e.Label == label && ttl == 10*time.Minute &&
+				e.Algorithm == "SHA256" && e.Digits == 8 && e.Period == 60*time.Second
+		})
+	}
 
 	tests := []struct {
 		name          string
 		userID        string
+		label         string
 		setupMocks    func()
 		expectedError error
 	}{
 		{
-			name:   "Success",
-			userID: "user1",
+			name:   "Default label",
+			userID: userID,
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("", domain.ErrTOTPNotEnabled)
+				mockUserRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
 				mockGenerator.On("GenerateSecret").Return("secret", nil)
-				mockGenerator.On("GenerateBackupCodes", 10).Return([]string{"code1", "code2"}, nil)
-				mockRepo.On("SaveTOTPSecret", mock.Anything, "user1", "secret").Return(nil)
-				mockRepo.On("SaveBackupCodes", mock.Anything, "user1", []string{"code1", "code2"}).Return(nil)
-				mockGenerator.On("GenerateQRCode", mock.AnythingOfType("*domain.TOTPConfig")).Return("secret", nil)
+				mockRepo.On("SavePendingTOTP", mock.Anything, isPending("Authenticator app")).Return(nil)
+				mockGenerator.On("GenerateQRCode", isEnrolledConfig).Return(qrCode, nil)
 			},
 			expectedError: nil,
 		},
 		{
-			name:   "Already Enabled",
-			userID: "user1",
+			name:   "Custom label",
+			userID: userID,
+			label:  "Work phone",
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("secret", nil)
+				mockUserRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+				mockGenerator.On("GenerateSecret").Return("secret", nil)
+				mockRepo.On("SavePendingTOTP", mock.Anything, isPending("Work phone")).Return(nil)
+				mockGenerator.On("GenerateQRCode", isEnrolledConfig).Return(qrCode, nil)
 			},
-			expectedError: domain.ErrTOTPAlreadyEnabled,
+			expectedError: nil,
 		},
 		{
 			name:   "Secret Generation Failed",
-			userID: "user1",
+			userID: userID,
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("", domain.ErrTOTPNotEnabled)
+				mockUserRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
 				mockGenerator.On("GenerateSecret").Return("", domain.ErrTOTPSecretGeneration)
 			},
 			expectedError: domain.ErrTOTPSecretGeneration,
 		},
+		{
+			name:   "Unknown User",
+			userID: userID,
+			setupMocks: func() {
+				mockUserRepo.On("FindByID", mock.Anything, user.ID).Return(nil, domain.ErrUserNotFound)
+			},
+			expectedError: domain.ErrUserNotFound,
+		},
+		{
+			name:          "Invalid User ID",
+			userID:        "user1",
+			setupMocks:    func() {},
+			expectedError: domain.ErrInvalidUserID,
+		},
 	}
 
 	for _, tt := range tests {
@@ -125,27 +242,152 @@ func TestTOTPService_EnableTOTP(t *testing.T) {
 			// Setup mocks
 			mockRepo.ExpectedCalls = nil
 			mockGenerator.ExpectedCalls = nil
+			mockUserRepo.ExpectedCalls = nil
 			tt.setupMocks()
 
 			// Execute
-			totp, err := service.EnableTOTP(tt.userID)
+			totp, err := service.EnableTOTP(tt.userID, tt.label)
 
 			// Assert
 			if tt.expectedError != nil {
 				assert.Error(t, err)
 				assert.Equal(t, tt.expectedError, err)
-				if totp != nil {
-					assert.Nil(t, totp.QRCode)
-					assert.Len(t, totp.BackupCodes, 0)
-				} else {
-					assert.Nil(t, totp)
-				}
+				assert.Nil(t, totp)
 			} else {
 				assert.NoError(t, err)
-				assert.NotNil(t, totp.QRCode)
-				assert.NotNil(t, totp.BackupCodes)
-				assert.Equal(t, "secret", totp.QRCode)
-				assert.Len(t, totp.BackupCodes, 2)
+				assert.Equal(t, qrCode.PNG, totp.QRCode)
+				assert.Equal(t, qrCode.SVG, totp.QRCodeSVG)
+				assert.Equal(t, qrCode.URI, totp.OTPAuthURI)
+				// Nothing is active, or revealed, before the app is confirmed
+				assert.Empty(t, totp.FactorID)
+				assert.Empty(t, totp.BackupCodes)
+			}
+
+			// Verify mocks
+			mockRepo.AssertExpectations(t)
+			mockGenerator.AssertExpectations(t)
+			mockRepo.AssertNotCalled(t, "SaveTOTPSecret", mock.Anything, mock.Anything, mock.Anything)
+			mockRepo.AssertNotCalled(t, "SaveBackupCodes", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestTOTPService_ConfirmTOTP(t *testing.T) {
+	// Setup
+	logger := zap.NewNop()
+	mockRepo := new(MockTOTPRepository)
+	mockGenerator := new(MockTOTPGenerator)
+	service := NewTOTPService(mockRepo, new(mockMFAFactorRepository), mockGenerator, prefixCipher{}, new(MockUserRepository), new(mockEmailService), nil, testTOTPConfig, logger)
+	userID := ulid.Make()
+
+	pending := &domain.TOTPEnrollment{
+		UserID:    userID,
+		Secret:    "sealed:secret",
+		Label:     "Work phone",
+		Algorithm: "SHA512",
+		Digits:    8,
+		Period:    60 * time.Second,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(10 * time.Minute),
+	}
+	// The code is checked with the parameters of the enrolment
+	isPendingConfig := mock.MatchedBy(func(c *domain.TOTPConfig) bool {
+		return c.Secret == "secret" && c.Algorithm == "SHA512" && c.Digits == 8 && c.Period == 60*time.Second
+	})
+	isTOTPFactor := mock.MatchedBy(func(f *domain.MFAFactor) bool {
+		return f.Type == domain.MFAFactorTOTP && f.Label == "Work phone" && f.UserID == userID
+	})
+
+	tests := []struct {
+		name            string
+		setupMocks      func()
+		expectedError   error
+		wantBackupCodes bool
+	}{
+		{
+			name: "First authenticator app reveals the backup codes",
+			setupMocks: func() {
+				mockRepo.On("GetPendingTOTP", mock.Anything, userID.String()).Return(pending, nil)
+				mockGenerator.On("ValidateCode", isPendingConfig, "123456").Return(int64(100), nil)
+				mockRepo.On("ActivatePendingTOTP", mock.Anything, isTOTPFactor, "sealed:secret", int64(100)).Return(nil)
+				mockRepo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{}, domain.ErrTOTPNotEnabled)
+				mockGenerator.On("GenerateBackupCodes", testTOTPConfig.TOTPBackupCodesCount).Return([]string{"code1", "code2"}, nil)
+				mockGenerator.On("HashBackupCode", "code1").Return("hash1", nil)
+				mockGenerator.On("HashBackupCode", "code2").Return("hash2", nil)
+				// Only the hashes are stored
+				mockRepo.On("SaveBackupCodes", mock.Anything, userID.String(), []string{"hash1", "hash2"}).Return(nil)
+			},
+			wantBackupCodes: true,
+		},
+		{
+			name: "Additional authenticator app keeps the backup codes",
+			setupMocks: func() {
+				mockRepo.On("GetPendingTOTP", mock.Anything, userID.String()).Return(pending, nil)
+				mockGenerator.On("ValidateCode", isPendingConfig, "123456").Return(int64(100), nil)
+				mockRepo.On("ActivatePendingTOTP", mock.Anything, isTOTPFactor, "sealed:secret", int64(100)).Return(nil)
+				mockRepo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{"hash1", "hash2"}, nil)
+			},
+		},
+		{
+			name: "Wrong code",
+			setupMocks: func() {
+				mockRepo.On("GetPendingTOTP", mock.Anything, userID.String()).Return(pending, nil)
+				mockGenerator.On("ValidateCode", isPendingConfig, "123456").Return(int64(0), domain.ErrInvalidTOTPCode)
+			},
+			expectedError: domain.ErrInvalidTOTPCode,
+		},
+		{
+			name: "Nothing pending",
+			setupMocks: func() {
+				mockRepo.On("GetPendingTOTP", mock.Anything, userID.String()).Return(nil, domain.ErrTOTPEnrollmentNotFound)
+			},
+			expectedError: domain.ErrTOTPEnrollmentNotFound,
+		},
+		{
+			name: "Expired enrolment",
+			setupMocks: func() {
+				mockRepo.On("GetPendingTOTP", mock.Anything, userID.String()).Return(&domain.TOTPEnrollment{
+					UserID:    userID,
+					Secret:    "sealed:secret",
+					CreatedAt: time.Now().Add(-20 * time.Minute),
+					ExpiresAt: time.Now().Add(-10 * time.Minute),
+				}, nil)
+			},
+			expectedError: domain.ErrTOTPEnrollmentNotFound,
+		},
+		{
+			name: "Replaced by a newer enrolment",
+			setupMocks: func() {
+				mockRepo.On("GetPendingTOTP", mock.Anything, userID.String()).Return(pending, nil)
+				mockGenerator.On("ValidateCode", isPendingConfig, "123456").Return(int64(100), nil)
+				mockRepo.On("ActivatePendingTOTP", mock.Anything, isTOTPFactor, "sealed:secret", int64(100)).Return(domain.ErrTOTPEnrollmentNotFound)
+			},
+			expectedError: domain.ErrTOTPEnrollmentNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup mocks
+			mockRepo.ExpectedCalls = nil
+			mockGenerator.ExpectedCalls = nil
+			tt.setupMocks()
+
+			// Execute
+			totp, err := service.ConfirmTOTP(context.Background(), userID.String(), "123456")
+
+			// Assert
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, totp)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, totp.FactorID)
+				if tt.wantBackupCodes {
+					assert.Len(t, totp.BackupCodes, 2)
+				} else {
+					assert.Empty(t, totp.BackupCodes)
+				}
 			}
 
 			// Verify mocks
@@ -159,8 +401,10 @@ func TestTOTPService_VerifyTOTP(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
 	mockRepo := new(MockTOTPRepository)
+	mockFactorRepo := new(mockMFAFactorRepository)
 	mockGenerator := new(MockTOTPGenerator)
-	service := NewTOTPService(mockRepo, mockGenerator, logger)
+	service := NewTOTPService(mockRepo, mockFactorRepo, mockGenerator, prefixCipher{}, new(MockUserRepository), new(mockEmailService), nil, testTOTPConfig, logger)
+	phone, tablet := ulid.Make(), ulid.Make()
 
 	tests := []struct {
 		name          string
@@ -174,17 +418,49 @@ func TestTOTPService_VerifyTOTP(t *testing.T) {
 			userID: "user1",
 			code:   "123456",
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("secret", nil)
-				mockGenerator.On("ValidateCode", "secret", "123456").Return(nil)
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				mockGenerator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(100), nil)
+				mockRepo.On("RecordTOTPStep", mock.Anything, phone.String(), int64(100)).Return(nil)
+				mockFactorRepo.On("Touch", mock.Anything, phone, mock.Anything).Return(nil)
 			},
 			expectedError: nil,
 		},
+		{
+			name:   "Code of the second authenticator app",
+			userID: "user1",
+			code:   "123456",
+			setupMocks: func() {
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{
+					sealedTOTPSecret(phone.String(), "secret"),
+					sealedTOTPSecret(tablet.String(), "other"),
+				}, nil)
+				mockGenerator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(0), domain.ErrInvalidTOTPCode)
+				mockGenerator.On("ValidateCode", withSecret("other"), "123456").Return(int64(100), nil)
+				mockRepo.On("RecordTOTPStep", mock.Anything, tablet.String(), int64(100)).Return(nil)
+				mockFactorRepo.On("Touch", mock.Anything, tablet, mock.Anything).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:   "Replayed code",
+			userID: "user1",
+			code:   "123456",
+			setupMocks: func() {
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{
+					sealedTOTPSecret(phone.String(), "secret"),
+					sealedTOTPSecret(tablet.String(), "other"),
+				}, nil)
+				mockGenerator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(100), nil)
+				mockRepo.On("RecordTOTPStep", mock.Anything, phone.String(), int64(100)).Return(domain.ErrTOTPCodeReused)
+			},
+			expectedError: domain.ErrTOTPCodeReused,
+		},
 		{
 			name:   "Not Enabled",
 			userID: "user1",
 			code:   "123456",
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("", domain.ErrTOTPNotEnabled)
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return(nil, domain.ErrTOTPNotEnabled)
 			},
 			expectedError: domain.ErrTOTPNotEnabled,
 		},
@@ -193,8 +469,8 @@ func TestTOTPService_VerifyTOTP(t *testing.T) {
 			userID: "user1",
 			code:   "123456",
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("secret", nil)
-				mockGenerator.On("ValidateCode", "secret", "123456").Return(domain.ErrInvalidTOTPCode)
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				mockGenerator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(0), domain.ErrInvalidTOTPCode)
 			},
 			expectedError: domain.ErrInvalidTOTPCode,
 		},
@@ -204,6 +480,7 @@ func TestTOTPService_VerifyTOTP(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mocks
 			mockRepo.ExpectedCalls = nil
+			mockFactorRepo.ExpectedCalls = nil
 			mockGenerator.ExpectedCalls = nil
 			tt.setupMocks()
 
@@ -220,17 +497,59 @@ func TestTOTPService_VerifyTOTP(t *testing.T) {
 
 			// Verify mocks
 			mockRepo.AssertExpectations(t)
+			mockFactorRepo.AssertExpectations(t)
 			mockGenerator.AssertExpectations(t)
 		})
 	}
 }
 
+func TestTOTPService_VerifyTOTPFactor(t *testing.T) {
+	logger := zap.NewNop()
+	mockRepo := new(MockTOTPRepository)
+	mockFactorRepo := new(mockMFAFactorRepository)
+	mockGenerator := new(MockTOTPGenerator)
+	service := NewTOTPService(mockRepo, mockFactorRepo, mockGenerator, prefixCipher{}, new(MockUserRepository), new(mockEmailService), nil, testTOTPConfig, logger)
+	factorID := ulid.Make()
+
+	secret := sealedTOTPSecret(factorID.String(), "secret")
+	secret.Algorithm, secret.Digits, secret.Period = "SHA256", 8, 60*time.Second
+	mockRepo.On("GetTOTPSecret", mock.Anything, "user1", factorID.String()).Return(secret, nil)
+	mockGenerator.On("ValidateCode", mock.MatchedBy(func(c *domain.TOTPConfig) bool {
+		return c.Secret == "secret" && c.Algorithm == "SHA256" && c.Digits == 8 && c.Period == 60*time.Second
+	}), "123456").Return(int64(100), nil)
+	mockRepo.On("RecordTOTPStep", mock.Anything, factorID.String(), int64(100)).Return(nil).Once()
+	mockFactorRepo.On("Touch", mock.Anything, factorID, mock.Anything).Return(nil)
+	assert.NoError(t, service.VerifyTOTPFactor(context.Background(), "user1", factorID.String(), "123456"),
+		"the code is checked with the parameters the app was enrolled with")
+
+	mockRepo.On("RecordTOTPStep", mock.Anything, factorID.String(), int64(100)).Return(domain.ErrTOTPCodeReused).Once()
+	assert.Equal(t, domain.ErrTOTPCodeReused, service.VerifyTOTPFactor(context.Background(), "user1", factorID.String(), "123456"),
+		"a code is only accepted once")
+
+	mockRepo.On("GetTOTPSecret", mock.Anything, "user2", factorID.String()).Return(nil, domain.ErrTOTPNotEnabled)
+	assert.Equal(t, domain.ErrTOTPNotEnabled, service.VerifyTOTPFactor(context.Background(), "user2", factorID.String(), "123456"),
+		"an authenticator app of another user is not found")
+
+	mockRepo.On("GetTOTPSecret", mock.Anything, "user3", factorID.String()).Return(&domain.TOTPSecret{FactorID: factorID.String(), Secret: "plaintext"}, nil)
+	assert.Equal(t, domain.ErrSecretEncryption, service.VerifyTOTPFactor(context.Background(), "user3", factorID.String(), "123456"),
+		"a secret that cannot be decrypted is never used")
+
+	mockRepo.AssertExpectations(t)
+	mockFactorRepo.AssertExpectations(t)
+}
+
 func TestTOTPService_VerifyBackupCode(t *testing.T) {
 	// Setup
 	logger := zap.NewNop()
 	mockRepo := new(MockTOTPRepository)
+	mockFactorRepo := new(mockMFAFactorRepository)
 	mockGenerator := new(MockTOTPGenerator)
-	service := NewTOTPService(mockRepo, mockGenerator, logger)
+	mockUserRepo := new(MockUserRepository)
+	mockEmailService := new(mockEmailService)
+	service := NewTOTPService(mockRepo, mockFactorRepo, mockGenerator, prefixCipher{}, mockUserRepo, mockEmailService, nil, testTOTPConfig, logger)
+	userID := ulid.Make()
+	user := &domain.User{ID: userID, Email: "user@example.com"}
+	recovery := domain.NewMFAFactor(userID, domain.MFAFactorRecovery, "Backup codes")
 
 	tests := []struct {
 		name          string
@@ -241,30 +560,47 @@ func TestTOTPService_VerifyBackupCode(t *testing.T) {
 	}{
 		{
 			name:   "Success",
-			userID: "user1",
+			userID: userID.String(),
 			code:   "code1",
 			setupMocks: func() {
-				mockRepo.On("GetBackupCodes", mock.Anything, "user1").Return([]string{"code1", "code2"}, nil)
-				mockGenerator.On("ValidateBackupCode", []string{"code1", "code2"}, "code1").Return(0, nil)
-				mockRepo.On("MarkBackupCodeAsUsed", mock.Anything, "user1", 0).Return(nil)
+				mockRepo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{"code1", "code2", "code3"}, nil)
+				mockGenerator.On("ValidateBackupCode", []string{"code1", "code2", "code3"}, "code1").Return(0, nil)
+				mockRepo.On("MarkBackupCodeAsUsed", mock.Anything, userID.String(), 0).Return(nil)
+				mockFactorRepo.On("ListByUser", mock.Anything, userID).Return([]*domain.MFAFactor{recovery}, nil)
+				mockFactorRepo.On("Touch", mock.Anything, recovery.ID, mock.Anything).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:   "Few codes left",
+			userID: userID.String(),
+			code:   "code3",
+			setupMocks: func() {
+				mockRepo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{"", "code2", "code3"}, nil)
+				mockGenerator.On("ValidateBackupCode", []string{"", "code2", "code3"}, "code3").Return(2, nil)
+				mockRepo.On("MarkBackupCodeAsUsed", mock.Anything, userID.String(), 2).Return(nil)
+				mockFactorRepo.On("ListByUser", mock.Anything, userID).Return([]*domain.MFAFactor{recovery}, nil)
+				mockFactorRepo.On("Touch", mock.Anything, recovery.ID, mock.Anything).Return(nil)
+				mockUserRepo.On("FindByID", mock.Anything, userID).Return(user, nil)
+				mockEmailService.On("SendBackupCodesLowEmail", mock.Anything, user.Email, 1).Return(domain.ErrEmailSendFailed)
 			},
 			expectedError: nil,
 		},
 		{
 			name:   "Not Enabled",
-			userID: "user1",
+			userID: userID.String(),
 			code:   "code1",
 			setupMocks: func() {
-				mockRepo.On("GetBackupCodes", mock.Anything, "user1").Return([]string{}, domain.ErrTOTPNotEnabled)
+				mockRepo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{}, domain.ErrTOTPNotEnabled)
 			},
 			expectedError: domain.ErrTOTPNotEnabled,
 		},
 		{
 			name:   "Invalid Code",
-			userID: "user1",
+			userID: userID.String(),
 			code:   "invalid",
 			setupMocks: func() {
-				mockRepo.On("GetBackupCodes", mock.Anything, "user1").Return([]string{"code1", "code2"}, nil)
+				mockRepo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{"code1", "code2"}, nil)
 				mockGenerator.On("ValidateBackupCode", []string{"code1", "code2"}, "invalid").Return(-1, domain.ErrInvalidTOTPBackupCode)
 			},
 			expectedError: domain.ErrInvalidTOTPBackupCode,
@@ -275,7 +611,10 @@ func TestTOTPService_VerifyBackupCode(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mocks
 			mockRepo.ExpectedCalls = nil
+			mockFactorRepo.ExpectedCalls = nil
 			mockGenerator.ExpectedCalls = nil
+			mockUserRepo.ExpectedCalls = nil
+			mockEmailService.ExpectedCalls = nil
 			tt.setupMocks()
 
 			// Execute
@@ -291,6 +630,259 @@ func TestTOTPService_VerifyBackupCode(t *testing.T) {
 
 			// Verify mocks
 			mockRepo.AssertExpectations(t)
+			mockFactorRepo.AssertExpectations(t)
+			mockGenerator.AssertExpectations(t)
+			mockUserRepo.AssertExpectations(t)
+			mockEmailService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTOTPService_BackupCodesStatus(t *testing.T) {
+	mockRepo := new(MockTOTPRepository)
+	service := NewTOTPService(mockRepo, new(mockMFAFactorRepository), new(MockTOTPGenerator), prefixCipher{}, new(MockUserRepository), new(mockEmailService), nil, testTOTPConfig, zap.NewNop())
+
+	mockRepo.On("GetBackupCodes", mock.Anything, "user1").Return([]string{"", "hash2", "", "hash4"}, nil)
+	status, err := service.BackupCodesStatus(context.Background(), "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.BackupCodesStatus{Remaining: 2, Total: 4}, status)
+
+	mockRepo.ExpectedCalls = nil
+	mockRepo.On("GetBackupCodes", mock.Anything, "user2").Return([]string{}, domain.ErrTOTPNotEnabled)
+	_, err = service.BackupCodesStatus(context.Background(), "user2")
+	assert.Equal(t, domain.ErrTOTPNotEnabled, err)
+}
+
+func TestTOTPService_RegenerateBackupCodes(t *testing.T) {
+	logger := zap.NewNop()
+	mockRepo := new(MockTOTPRepository)
+	mockFactorRepo := new(mockMFAFactorRepository)
+	mockGenerator := new(MockTOTPGenerator)
+	mockLockout := new(mockLockoutService)
+	service := NewTOTPService(mockRepo, mockFactorRepo, mockGenerator, prefixCipher{}, new(MockUserRepository), new(mockEmailService), mockLockout, testTOTPConfig, logger)
+	phone := ulid.Make()
+
+	tests := []struct {
+		name          string
+		setupMocks    func()
+		expectedCodes []string
+		expectedError error
+	}{
+		{
+			name: "Success",
+			setupMocks: func() {
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				mockGenerator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(100), nil)
+				mockRepo.On("RecordTOTPStep", mock.Anything, phone.String(), int64(100)).Return(nil)
+				mockFactorRepo.On("Touch", mock.Anything, phone, mock.Anything).Return(nil)
+				mockGenerator.On("GenerateBackupCodes", testTOTPConfig.TOTPBackupCodesCount).Return([]string{"code1", "code2"}, nil)
+				mockGenerator.On("HashBackupCode", "code1").Return("hash1", nil)
+				mockGenerator.On("HashBackupCode", "code2").Return("hash2", nil)
+				mockRepo.On("SaveBackupCodes", mock.Anything, "user1", []string{"hash1", "hash2"}).Return(nil)
+			},
+			expectedCodes: []string{"code1", "code2"},
+		},
+		{
+			name: "Invalid authenticator code",
+			setupMocks: func() {
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				mockGenerator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(0), domain.ErrInvalidTOTPCode)
+			},
+			expectedError: domain.ErrInvalidTOTPCode,
+		},
+		{
+			name:          "Locked out",
+			setupMocks:    func() {},
+			expectedError: domain.ErrAccountLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo.ExpectedCalls = nil
+			mockFactorRepo.ExpectedCalls = nil
+			mockGenerator.ExpectedCalls = nil
+			mockLockout.ExpectedCalls = nil
+			if tt.expectedError == domain.ErrAccountLocked {
+				mockLockout.On("Check", mock.Anything, "user1", "").Return(domain.ErrAccountLocked)
+			} else {
+				mockLockout.On("Check", mock.Anything, "user1", "").Return(nil)
+			}
+			tt.setupMocks()
+
+			codes, err := service.RegenerateBackupCodes(context.Background(), "user1", "123456")
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, tt.expectedCodes, codes)
+			mockRepo.AssertExpectations(t)
+			mockFactorRepo.AssertExpectations(t)
+			mockGenerator.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTOTPService_VerifyUserCode(t *testing.T) {
+	userID := ulid.Make()
+	user := &domain.User{ID: userID, Email: "user@example.com"}
+	phone := ulid.Make()
+	recovery := domain.NewMFAFactor(userID, domain.MFAFactorRecovery, "Backup codes")
+
+	tests := []struct {
+		name          string
+		factorTypes   []domain.MFAFactorType
+		setupMocks    func(*MockTOTPRepository, *mockMFAFactorRepository, *MockTOTPGenerator, *mockLockoutService)
+		expectFailure bool
+		expectedError error
+	}{
+		{
+			name:        "Authenticator code",
+			factorTypes: []domain.MFAFactorType{domain.MFAFactorTOTP},
+			setupMocks: func(repo *MockTOTPRepository, factorRepo *mockMFAFactorRepository, generator *MockTOTPGenerator, _ *mockLockoutService) {
+				repo.On("ListTOTPSecrets", mock.Anything, userID.String()).Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				generator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(100), nil)
+				repo.On("RecordTOTPStep", mock.Anything, phone.String(), int64(100)).Return(nil)
+				factorRepo.On("Touch", mock.Anything, phone, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:        "Wrong authenticator code",
+			factorTypes: []domain.MFAFactorType{domain.MFAFactorTOTP},
+			setupMocks: func(repo *MockTOTPRepository, _ *mockMFAFactorRepository, generator *MockTOTPGenerator, _ *mockLockoutService) {
+				repo.On("ListTOTPSecrets", mock.Anything, userID.String()).Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				generator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(0), domain.ErrInvalidTOTPCode)
+			},
+			expectFailure: true,
+			expectedError: domain.ErrInvalidTOTPCode,
+		},
+		{
+			name:        "Backup code after the authenticator apps",
+			factorTypes: []domain.MFAFactorType{domain.MFAFactorTOTP, domain.MFAFactorRecovery},
+			setupMocks: func(repo *MockTOTPRepository, factorRepo *mockMFAFactorRepository, generator *MockTOTPGenerator, _ *mockLockoutService) {
+				repo.On("ListTOTPSecrets", mock.Anything, userID.String()).Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				generator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(0), domain.ErrInvalidTOTPCode)
+				repo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{"123456", "234567", "345678"}, nil)
+				generator.On("ValidateBackupCode", []string{"123456", "234567", "345678"}, "123456").Return(0, nil)
+				repo.On("MarkBackupCodeAsUsed", mock.Anything, userID.String(), 0).Return(nil)
+				factorRepo.On("ListByUser", mock.Anything, userID).Return([]*domain.MFAFactor{recovery}, nil)
+				factorRepo.On("Touch", mock.Anything, recovery.ID, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:        "Rejected by every factor counts once",
+			factorTypes: []domain.MFAFactorType{domain.MFAFactorTOTP, domain.MFAFactorRecovery},
+			setupMocks: func(repo *MockTOTPRepository, _ *mockMFAFactorRepository, generator *MockTOTPGenerator, _ *mockLockoutService) {
+				repo.On("ListTOTPSecrets", mock.Anything, userID.String()).Return([]*domain.TOTPSecret{sealedTOTPSecret(phone.String(), "secret")}, nil)
+				generator.On("ValidateCode", withSecret("secret"), "123456").Return(int64(0), domain.ErrInvalidTOTPCode)
+				repo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string{"654321"}, nil)
+				generator.On("ValidateBackupCode", []string{"654321"}, "123456").Return(-1, domain.ErrInvalidTOTPBackupCode)
+			},
+			expectFailure: true,
+			expectedError: domain.ErrInvalidTOTPCode,
+		},
+		{
+			name:        "Not enabled does not count",
+			factorTypes: []domain.MFAFactorType{domain.MFAFactorRecovery},
+			setupMocks: func(repo *MockTOTPRepository, _ *mockMFAFactorRepository, _ *MockTOTPGenerator, _ *mockLockoutService) {
+				repo.On("GetBackupCodes", mock.Anything, userID.String()).Return([]string(nil), domain.ErrTOTPNotEnabled)
+			},
+			expectedError: domain.ErrTOTPNotEnabled,
+		},
+		{
+			name:        "Locked out",
+			factorTypes: []domain.MFAFactorType{domain.MFAFactorTOTP},
+			setupMocks: func(_ *MockTOTPRepository, _ *mockMFAFactorRepository, _ *MockTOTPGenerator, lockout *mockLockoutService) {
+				lockout.ExpectedCalls = nil
+				lockout.On("Check", mock.Anything, userID.String(), "").Return(domain.ErrAccountLocked)
+			},
+			expectedError: domain.ErrAccountLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockTOTPRepository)
+			mockFactorRepo := new(mockMFAFactorRepository)
+			mockGenerator := new(MockTOTPGenerator)
+			mockUserRepo := new(MockUserRepository)
+			mockLockout := new(mockLockoutService)
+			mockLockout.On("Check", mock.Anything, userID.String(), "").Return(nil)
+			if tt.expectFailure {
+				mockUserRepo.On("FindByID", mock.Anything, userID).Return(user, nil)
+				mockLockout.On("RecordFailure", mock.Anything, user, "").Return(nil).Once()
+			}
+			tt.setupMocks(mockRepo, mockFactorRepo, mockGenerator, mockLockout)
+			service := NewTOTPService(mockRepo, mockFactorRepo, mockGenerator, prefixCipher{}, mockUserRepo, new(mockEmailService), mockLockout, testTOTPConfig, zap.NewNop())
+
+			err := service.VerifyUserCode(context.Background(), userID.String(), "123456", tt.factorTypes...)
+
+			assert.Equal(t, tt.expectedError, err)
+			mockRepo.AssertExpectations(t)
+			mockFactorRepo.AssertExpectations(t)
+			mockGenerator.AssertExpectations(t)
+			mockUserRepo.AssertExpectations(t)
+			mockLockout.AssertExpectations(t)
+			if !tt.expectFailure {
+				mockLockout.AssertNotCalled(t, "RecordFailure", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestTOTPService_RegenerateBackupCodes_RecentMFA(t *testing.T) {
+	cfg := *testTOTPConfig
+	cfg.StepUpMaxAge = 15 * time.Minute
+
+	tests := []struct {
+		name          string
+		auth          *domain.Authentication
+		expectedError error
+	}{
+		{
+			name: "passkey sign-in",
+			auth: domain.NewAuthentication(domain.AMRHardwareKey, domain.AMRMultiFactor),
+		},
+		{
+			name: "second factor by email",
+			auth: domain.NewAuthentication(domain.AMRPassword).WithFactor(domain.MFAFactorEmailOTP),
+		},
+		{
+			name:          "single factor",
+			auth:          domain.NewAuthentication(domain.AMRPassword),
+			expectedError: domain.ErrInsufficientUserAuthentication,
+		},
+		{
+			name:          "second factor too long ago",
+			auth:          &domain.Authentication{Methods: []string{domain.AMRPassword, domain.AMROTP, domain.AMRMultiFactor}, Time: time.Now().Add(-time.Hour)},
+			expectedError: domain.ErrInsufficientUserAuthentication,
+		},
+		{
+			name:          "no authentication",
+			expectedError: domain.ErrInsufficientUserAuthentication,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockTOTPRepository)
+			mockGenerator := new(MockTOTPGenerator)
+			if tt.expectedError == nil {
+				mockGenerator.On("GenerateBackupCodes", cfg.TOTPBackupCodesCount).Return([]string{"code1"}, nil)
+				mockGenerator.On("HashBackupCode", "code1").Return("hash1", nil)
+				mockRepo.On("SaveBackupCodes", mock.Anything, "user1", []string{"hash1"}).Return(nil)
+			}
+			service := NewTOTPService(mockRepo, new(mockMFAFactorRepository), mockGenerator, prefixCipher{}, new(MockUserRepository), new(mockEmailService), nil, &cfg, zap.NewNop())
+
+			ctx := context.Background()
+			if tt.auth != nil {
+				ctx = domain.WithAuthentication(ctx, tt.auth)
+			}
+			codes, err := service.RegenerateBackupCodes(ctx, "user1", "")
+
+			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.Equal(t, []string{"code1"}, codes)
+			}
+			mockRepo.AssertExpectations(t)
 			mockGenerator.AssertExpectations(t)
 		})
 	}
@@ -301,7 +893,7 @@ func TestTOTPService_DisableTOTP(t *testing.T) {
 	logger := zap.NewNop()
 	mockRepo := new(MockTOTPRepository)
 	mockGenerator := new(MockTOTPGenerator)
-	service := NewTOTPService(mockRepo, mockGenerator, logger)
+	service := NewTOTPService(mockRepo, new(mockMFAFactorRepository), mockGenerator, prefixCipher{}, new(MockUserRepository), new(mockEmailService), nil, testTOTPConfig, logger)
 
 	tests := []struct {
 		name          string
@@ -313,7 +905,7 @@ func TestTOTPService_DisableTOTP(t *testing.T) {
 			name:   "Success",
 			userID: "user1",
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("secret", nil)
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{sealedTOTPSecret("factor1", "secret")}, nil)
 				mockRepo.On("DeleteTOTPConfig", mock.Anything, "user1").Return(nil)
 			},
 			expectedError: nil,
@@ -322,7 +914,7 @@ func TestTOTPService_DisableTOTP(t *testing.T) {
 			name:   "Not Enabled",
 			userID: "user1",
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("", domain.ErrTOTPNotEnabled)
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return(nil, domain.ErrTOTPNotEnabled)
 			},
 			expectedError: domain.ErrTOTPNotEnabled,
 		},
@@ -330,7 +922,7 @@ func TestTOTPService_DisableTOTP(t *testing.T) {
 			name:   "Delete Failed",
 			userID: "user1",
 			setupMocks: func() {
-				mockRepo.On("GetTOTPSecret", mock.Anything, "user1").Return("secret", nil)
+				mockRepo.On("ListTOTPSecrets", mock.Anything, "user1").Return([]*domain.TOTPSecret{sealedTOTPSecret("factor1", "secret")}, nil)
 				mockRepo.On("DeleteTOTPConfig", mock.Anything, "user1").Return(domain.ErrInternal)
 			},
 			expectedError: domain.ErrInternal,
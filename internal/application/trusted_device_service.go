@@ -0,0 +1,188 @@
+package application
+
+import (
+	"context"
+	"crypto/subtle"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// TrustedDeviceService remembers the devices users complete MFA on. A device token is the device ID
+// and a random secret; only a hash of the secret bound to the user is stored, so a token cannot be
+// used for another account and revoking the device invalidates it.
+type TrustedDeviceService struct {
+	deviceRepo domain.TrustedDeviceRepository
+	config     *config.Config
+	logger     *zap.Logger
+}
+
+// NewTrustedDeviceService creates a new trusted device service
+func NewTrustedDeviceService(deviceRepo domain.TrustedDeviceRepository, config *config.Config, logger *zap.Logger) *TrustedDeviceService {
+	return &TrustedDeviceService{
+		deviceRepo: deviceRepo,
+		config:     config,
+		logger:     logger,
+	}
+}
+
+// Trust remembers a device for DeviceTrustDuration. Device trust is off when the duration is zero
+// and for users with one of DeviceTrustExcludedRoles.
+func (s *TrustedDeviceService) Trust(ctx context.Context, user *domain.User, name string) (*domain.TrustedDevice, string, error) {
+	if !s.allowed(user) {
+		return nil, "", domain.ErrDeviceTrustNotAllowed
+	}
+
+	secret, err := generateLinkToken()
+	if err != nil {
+		s.logger.Error("Failed to generate device token", zap.Error(err))
+		return nil, "", domain.ErrInternal
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		name = "Unnamed device"
+	}
+	if runes := []rune(name); len(runes) > 64 {
+		name = string(runes[:64])
+	}
+
+	now := time.Now()
+	device := &domain.TrustedDevice{
+		ID:        ulid.Make(),
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: hashOneTimeSecret(user.ID, secret),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.config.DeviceTrustDuration),
+	}
+	if ip, ok := domain.GetClientIP(ctx); ok {
+		device.ClientIP = ip
+	}
+
+	if err := s.deviceRepo.Create(ctx, device); err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info("Device trusted",
+		zap.String("user_id", user.ID.String()),
+		zap.String("device_id", device.ID.String()),
+		zap.Time("expires_at", device.ExpiresAt))
+
+	return device, device.ID.String() + "." + secret, nil
+}
+
+// Verify reports whether the token belongs to an unexpired device of the user. Expired devices are
+// removed, and a user who gained an excluded role since trusting the device has to use MFA again.
+func (s *TrustedDeviceService) Verify(ctx context.Context, user *domain.User, token string) bool {
+	if !s.allowed(user) {
+		return false
+	}
+
+	deviceID, secret, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	id, err := ulid.Parse(deviceID)
+	if err != nil {
+		return false
+	}
+
+	device, err := s.deviceRepo.FindByID(ctx, id)
+	if err != nil {
+		if err != domain.ErrTrustedDeviceNotFound {
+			s.logger.Error("Failed to find trusted device",
+				zap.String("device_id", deviceID),
+				zap.Error(err))
+		}
+		return false
+	}
+
+	if device.UserID != user.ID || subtle.ConstantTimeCompare([]byte(device.TokenHash), []byte(hashOneTimeSecret(user.ID, secret))) != 1 {
+		s.logger.Warn("Device token presented for another user",
+			zap.String("user_id", user.ID.String()),
+			zap.String("device_id", deviceID))
+		return false
+	}
+
+	now := time.Now()
+	if device.IsExpired(now) {
+		if err := s.deviceRepo.Delete(ctx, user.ID, device.ID); err != nil && err != domain.ErrTrustedDeviceNotFound {
+			s.logger.Error("Failed to delete expired trusted device",
+				zap.String("device_id", deviceID),
+				zap.Error(err))
+		}
+		return false
+	}
+
+	// The device already let the user in, so a failed update is only logged
+	if err := s.deviceRepo.Touch(ctx, device.ID, now); err != nil {
+		s.logger.Error("Failed to record trusted device use",
+			zap.String("device_id", deviceID),
+			zap.Error(err))
+	}
+
+	return true
+}
+
+// List lists the trusted devices of a user, newest first
+func (s *TrustedDeviceService) List(ctx context.Context, userID string) ([]*domain.TrustedDevice, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+	return s.deviceRepo.ListByUser(ctx, id)
+}
+
+// Revoke revokes one of the user's trusted devices
+func (s *TrustedDeviceService) Revoke(ctx context.Context, userID, deviceID string) error {
+	user, err := ulid.Parse(userID)
+	if err != nil {
+		return domain.ErrInvalidUserID
+	}
+	id, err := ulid.Parse(deviceID)
+	if err != nil {
+		return domain.ErrTrustedDeviceNotFound
+	}
+
+	if err := s.deviceRepo.Delete(ctx, user, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("Trusted device revoked",
+		zap.String("user_id", userID),
+		zap.String("device_id", deviceID))
+	return nil
+}
+
+// RevokeAll revokes every trusted device of a user
+func (s *TrustedDeviceService) RevokeAll(ctx context.Context, userID string) error {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return domain.ErrInvalidUserID
+	}
+
+	if err := s.deviceRepo.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("All trusted devices revoked", zap.String("user_id", userID))
+	return nil
+}
+
+// allowed reports whether the user may skip MFA on a trusted device
+func (s *TrustedDeviceService) allowed(user *domain.User) bool {
+	if s.config.DeviceTrustDuration <= 0 {
+		return false
+	}
+	for _, role := range user.Roles {
+		if slices.Contains(s.config.DeviceTrustExcludedRoles, role) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockTrustedDeviceRepository struct {
+	mock.Mock
+}
+
+func (m *mockTrustedDeviceRepository) Create(ctx context.Context, device *domain.TrustedDevice) error {
+	args := m.Called(ctx, device)
+	return args.Error(0)
+}
+
+func (m *mockTrustedDeviceRepository) FindByID(ctx context.Context, id ulid.ULID) (*domain.TrustedDevice, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TrustedDevice), args.Error(1)
+}
+
+func (m *mockTrustedDeviceRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.TrustedDevice, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.TrustedDevice), args.Error(1)
+}
+
+func (m *mockTrustedDeviceRepository) Touch(ctx context.Context, id ulid.ULID, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func (m *mockTrustedDeviceRepository) Delete(ctx context.Context, userID, id ulid.ULID) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *mockTrustedDeviceRepository) DeleteByUser(ctx context.Context, userID ulid.ULID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func newTestDeviceTrustConfig() *config.Config {
+	return &config.Config{
+		DeviceTrustDuration:      30 * 24 * time.Hour,
+		DeviceTrustExcludedRoles: []string{"admin"},
+	}
+}
+
+// trustTestDevice trusts a device for the user and returns it with its token, as stored by the repository
+func trustTestDevice(t *testing.T, user *domain.User) (*domain.TrustedDevice, string) {
+	repo := new(mockTrustedDeviceRepository)
+	repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+	service := NewTrustedDeviceService(repo, newTestDeviceTrustConfig(), zap.NewNop())
+
+	device, token, err := service.Trust(context.Background(), user, "Laptop")
+	require.NoError(t, err)
+	return device, token
+}
+
+func TestTrustedDeviceService_Trust(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Roles: []string{"user"}}
+
+	t.Run("stores a hash of the token", func(t *testing.T) {
+		repo := new(mockTrustedDeviceRepository)
+		repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		service := NewTrustedDeviceService(repo, newTestDeviceTrustConfig(), zap.NewNop())
+		ctx := domain.WithClientIP(context.Background(), "203.0.113.7")
+
+		device, token, err := service.Trust(ctx, user, "  Work laptop ")
+		require.NoError(t, err)
+
+		assert.Equal(t, user.ID, device.UserID)
+		assert.Equal(t, "Work laptop", device.Name)
+		assert.Equal(t, "203.0.113.7", device.ClientIP)
+		assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), device.ExpiresAt, time.Minute)
+		assert.True(t, strings.HasPrefix(token, device.ID.String()+"."))
+		assert.NotContains(t, device.TokenHash, strings.TrimPrefix(token, device.ID.String()+"."))
+		repo.AssertCalled(t, "Create", mock.Anything, device)
+	})
+
+	t.Run("names unnamed devices", func(t *testing.T) {
+		repo := new(mockTrustedDeviceRepository)
+		repo.On("Create", mock.Anything, mock.Anything).Return(nil)
+		service := NewTrustedDeviceService(repo, newTestDeviceTrustConfig(), zap.NewNop())
+
+		device, _, err := service.Trust(context.Background(), user, "")
+		require.NoError(t, err)
+		assert.Equal(t, "Unnamed device", device.Name)
+	})
+
+	t.Run("excluded role", func(t *testing.T) {
+		repo := new(mockTrustedDeviceRepository)
+		service := NewTrustedDeviceService(repo, newTestDeviceTrustConfig(), zap.NewNop())
+		admin := &domain.User{ID: ulid.Make(), Roles: []string{"user", "admin"}}
+
+		_, _, err := service.Trust(context.Background(), admin, "Laptop")
+		assert.Equal(t, domain.ErrDeviceTrustNotAllowed, err)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		repo := new(mockTrustedDeviceRepository)
+		service := NewTrustedDeviceService(repo, &config.Config{}, zap.NewNop())
+
+		_, _, err := service.Trust(context.Background(), user, "Laptop")
+		assert.Equal(t, domain.ErrDeviceTrustNotAllowed, err)
+	})
+}
+
+func TestTrustedDeviceService_Verify(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Roles: []string{"user"}}
+
+	tests := []struct {
+		name     string
+		user     *domain.User
+		config   *config.Config
+		token    func(device *domain.TrustedDevice, token string) string
+		expired  bool
+		expected bool
+	}{
+		{
+			name:     "trusted device",
+			expected: true,
+		},
+		{
+			name: "wrong secret",
+			token: func(device *domain.TrustedDevice, token string) string {
+				return device.ID.String() + ".wrong"
+			},
+		},
+		{
+			name: "malformed token",
+			token: func(device *domain.TrustedDevice, token string) string {
+				return "not-a-token"
+			},
+		},
+		{
+			name: "device of another user",
+			user: &domain.User{ID: ulid.Make(), Roles: []string{"user"}},
+		},
+		{
+			name:    "expired device",
+			expired: true,
+		},
+		{
+			name: "role excluded since trusting the device",
+			user: &domain.User{ID: user.ID, Roles: []string{"user", "admin"}},
+		},
+		{
+			name:   "device trust disabled",
+			config: &config.Config{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device, token := trustTestDevice(t, user)
+			if tt.expired {
+				device.ExpiresAt = time.Now().Add(-time.Minute)
+			}
+			if tt.token != nil {
+				token = tt.token(device, token)
+			}
+			verifying := user
+			if tt.user != nil {
+				verifying = tt.user
+			}
+			cfg := newTestDeviceTrustConfig()
+			if tt.config != nil {
+				cfg = tt.config
+			}
+
+			repo := new(mockTrustedDeviceRepository)
+			repo.On("FindByID", mock.Anything, device.ID).Return(device, nil).Maybe()
+			repo.On("Touch", mock.Anything, device.ID, mock.Anything).Return(nil).Maybe()
+			repo.On("Delete", mock.Anything, user.ID, device.ID).Return(nil).Maybe()
+			service := NewTrustedDeviceService(repo, cfg, zap.NewNop())
+
+			assert.Equal(t, tt.expected, service.Verify(context.Background(), verifying, token))
+			if tt.expected {
+				repo.AssertCalled(t, "Touch", mock.Anything, device.ID, mock.Anything)
+			} else {
+				repo.AssertNotCalled(t, "Touch", mock.Anything, mock.Anything, mock.Anything)
+			}
+			if tt.expired {
+				repo.AssertCalled(t, "Delete", mock.Anything, user.ID, device.ID)
+			}
+		})
+	}
+}
+
+func TestTrustedDeviceService_Revoke(t *testing.T) {
+	userID := ulid.Make()
+	deviceID := ulid.Make()
+
+	t.Run("revokes the device", func(t *testing.T) {
+		repo := new(mockTrustedDeviceRepository)
+		repo.On("Delete", mock.Anything, userID, deviceID).Return(nil)
+		service := NewTrustedDeviceService(repo, newTestDeviceTrustConfig(), zap.NewNop())
+
+		require.NoError(t, service.Revoke(context.Background(), userID.String(), deviceID.String()))
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("unknown device", func(t *testing.T) {
+		repo := new(mockTrustedDeviceRepository)
+		service := NewTrustedDeviceService(repo, newTestDeviceTrustConfig(), zap.NewNop())
+
+		assert.Equal(t, domain.ErrTrustedDeviceNotFound, service.Revoke(context.Background(), userID.String(), "invalid"))
+	})
+
+	t.Run("revokes every device", func(t *testing.T) {
+		repo := new(mockTrustedDeviceRepository)
+		repo.On("DeleteByUser", mock.Anything, userID).Return(nil)
+		service := NewTrustedDeviceService(repo, newTestDeviceTrustConfig(), zap.NewNop())
+
+		require.NoError(t, service.RevokeAll(context.Background(), userID.String()))
+		repo.AssertExpectations(t)
+	})
+}
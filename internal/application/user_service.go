@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 )
 
@@ -44,6 +45,10 @@ func (s *UserService) UpdateUser(ctx context.Context, id domain.ULID, name, phon
 		return domain.ErrUserNotFound
 	}
 
+	// A new number has to be verified before codes are sent to it
+	if phone != user.Phone {
+		user.PhoneVerified = false
+	}
 	user.Name = name
 	user.Phone = phone
 	user.UpdatedAt = time.Now()
@@ -66,3 +71,28 @@ func (s *UserService) ListUsers(ctx context.Context, limit, offset int) ([]*doma
 
 	return users, nil
 }
+
+// ValidateSession rejects tokens issued before the user's sessions were revoked, and tokens of
+// users that no longer exist
+func (s *UserService) ValidateSession(ctx context.Context, userID string, issuedAt *time.Time) error {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return domain.ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err == domain.ErrUserNotFound {
+		return domain.ErrInvalidToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.SessionsRevokedAt != nil && (issuedAt == nil || issuedAt.Before(*user.SessionsRevokedAt)) {
+		s.logger.Warn("Token issued before sessions were revoked",
+			zap.String("user_id", userID))
+		return domain.ErrInvalidToken
+	}
+
+	return nil
+}
@@ -193,3 +193,61 @@ func TestUserService_ListUsers(t *testing.T) {
 		assert.Equal(t, assert.AnError, err)
 	})
 }
+
+func TestUserService_ValidateSession(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Hour)
+	before := revokedAt.Add(-time.Minute)
+	after := revokedAt.Add(time.Minute)
+	userID := ulid.Make()
+
+	tests := []struct {
+		name          string
+		user          *domain.User
+		issuedAt      *time.Time
+		expectedError error
+	}{
+		{
+			name:     "sessions never revoked",
+			user:     &domain.User{ID: userID},
+			issuedAt: &before,
+		},
+		{
+			name:     "issued after revocation",
+			user:     &domain.User{ID: userID, SessionsRevokedAt: &revokedAt},
+			issuedAt: &after,
+		},
+		{
+			name:          "issued before revocation",
+			user:          &domain.User{ID: userID, SessionsRevokedAt: &revokedAt},
+			issuedAt:      &before,
+			expectedError: domain.ErrInvalidToken,
+		},
+		{
+			name:          "no issue time",
+			user:          &domain.User{ID: userID, SessionsRevokedAt: &revokedAt},
+			expectedError: domain.ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockUserRepository)
+			repo.On("FindByID", mock.Anything, userID).Return(tt.user, nil)
+			service := NewUserService(repo, zap.NewNop())
+
+			err := service.ValidateSession(context.Background(), userID.String(), tt.issuedAt)
+
+			assert.Equal(t, tt.expectedError, err)
+		})
+	}
+
+	t.Run("deleted user", func(t *testing.T) {
+		repo := new(MockUserRepository)
+		repo.On("FindByID", mock.Anything, userID).Return(nil, domain.ErrUserNotFound)
+		service := NewUserService(repo, zap.NewNop())
+
+		err := service.ValidateSession(context.Background(), userID.String(), &after)
+
+		assert.Equal(t, domain.ErrInvalidToken, err)
+	})
+}
@@ -0,0 +1,454 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/manorfm/authM/internal/infrastructure/webauthn"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// webAuthnChallengeLength follows the recommendation of at least 16 random bytes
+	webAuthnChallengeLength = 32
+	// defaultPasskeyName names passkeys registered without a friendly name
+	defaultPasskeyName  = "Passkey"
+	publicKeyCredential = "public-key"
+)
+
+type WebAuthnService struct {
+	credentialRepo domain.WebAuthnCredentialRepository
+	challengeRepo  domain.WebAuthnChallengeRepository
+	userRepo       domain.UserRepository
+	mfaTicketRepo  domain.MFATicketRepository
+	verifier       domain.WebAuthnVerifier
+	authService    domain.AuthService
+	jwtService     domain.JWTService
+	lockoutService domain.LockoutService
+	loginHistory   domain.LoginHistoryService
+	config         *config.Config
+	logger         *zap.Logger
+}
+
+func NewWebAuthnService(
+	credentialRepo domain.WebAuthnCredentialRepository,
+	challengeRepo domain.WebAuthnChallengeRepository,
+	userRepo domain.UserRepository,
+	mfaTicketRepo domain.MFATicketRepository,
+	verifier domain.WebAuthnVerifier,
+	authService domain.AuthService,
+	jwtService domain.JWTService,
+	lockoutService domain.LockoutService,
+	loginHistory domain.LoginHistoryService,
+	config *config.Config,
+	logger *zap.Logger,
+) *WebAuthnService {
+	return &WebAuthnService{
+		credentialRepo: credentialRepo,
+		challengeRepo:  challengeRepo,
+		userRepo:       userRepo,
+		mfaTicketRepo:  mfaTicketRepo,
+		verifier:       verifier,
+		authService:    authService,
+		jwtService:     jwtService,
+		lockoutService: lockoutService,
+		loginHistory:   loginHistory,
+		config:         config,
+		logger:         logger,
+	}
+}
+
+// BeginRegistration starts registering a passkey, excluding the authenticators the user already registered
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID string) (*domain.WebAuthnRegistrationStart, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepo.ListByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.createChallenge(ctx, domain.WebAuthnRegistration, user.ID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]domain.WebAuthnCredentialParameter, 0, len(webauthn.SupportedAlgorithms))
+	for _, alg := range webauthn.SupportedAlgorithms {
+		params = append(params, domain.WebAuthnCredentialParameter{Type: publicKeyCredential, Alg: alg})
+	}
+
+	return &domain.WebAuthnRegistrationStart{
+		Session: challenge.ID.String(),
+		PublicKey: &domain.WebAuthnCreationOptions{
+			RP: domain.WebAuthnRelyingParty{
+				ID:   s.config.WebAuthnRPID,
+				Name: s.config.WebAuthnRPName,
+			},
+			User: domain.WebAuthnUserEntity{
+				ID:          user.ID.Bytes(),
+				Name:        user.Email,
+				DisplayName: user.Name,
+			},
+			Challenge:          challenge.Challenge,
+			PubKeyCredParams:   params,
+			Timeout:            s.config.WebAuthnTimeout.Milliseconds(),
+			ExcludeCredentials: descriptors(credentials),
+			// Discoverable credentials let the passkey be used without typing an email first
+			AuthenticatorSelection: domain.WebAuthnAuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: "preferred",
+			},
+			Attestation: "none",
+		},
+	}, nil
+}
+
+// FinishRegistration verifies the authenticator response and stores the new passkey
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID, session, name string, response *domain.WebAuthnAttestationResponse) (*domain.WebAuthnCredential, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.consumeChallenge(ctx, session, domain.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID != user.ID {
+		s.logger.Warn("WebAuthn registration answered for another user",
+			zap.String("user_id", user.ID.String()))
+		return nil, domain.ErrInvalidWebAuthnChallenge
+	}
+
+	attestation, err := s.verifier.VerifyRegistration(challenge.Challenge, response, false)
+	if err != nil {
+		s.logger.Warn("WebAuthn registration rejected",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		return nil, domain.ErrWebAuthnVerificationFailed
+	}
+
+	if _, err := s.credentialRepo.FindByCredentialID(ctx, attestation.CredentialID); err == nil {
+		return nil, domain.ErrWebAuthnCredentialExists
+	} else if err != domain.ErrWebAuthnCredentialNotFound {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+
+	credential := &domain.WebAuthnCredential{
+		ID:           ulid.Make(),
+		UserID:       user.ID,
+		CredentialID: attestation.CredentialID,
+		PublicKey:    attestation.PublicKey,
+		SignCount:    attestation.SignCount,
+		Transports:   response.Response.Transports,
+		AAGUID:       attestation.AAGUID,
+		Name:         name,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.credentialRepo.Create(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Passkey registered",
+		zap.String("user_id", user.ID.String()),
+		zap.String("credential_id", credential.ID.String()))
+	return credential, nil
+}
+
+// BeginLogin starts a passwordless sign-in. No credentials are listed, so the authenticator
+// offers the discoverable passkeys it holds for this relying party.
+func (s *WebAuthnService) BeginLogin(ctx context.Context) (*domain.WebAuthnLoginStart, error) {
+	challenge, err := s.createChallenge(ctx, domain.WebAuthnLogin, ulid.ULID{}, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.requestOptions(challenge, nil, "required"), nil
+}
+
+// FinishLogin signs a user in with a passkey. User verification is required, so the passkey
+// proves both possession and a PIN or biometric and no further MFA ticket is issued.
+func (s *WebAuthnService) FinishLogin(ctx context.Context, session string, response *domain.WebAuthnAssertionResponse) (*domain.TokenPair, error) {
+	ip, _ := domain.GetClientIP(ctx)
+	if err := s.lockoutService.Check(ctx, "", ip); err != nil {
+		return nil, err
+	}
+
+	challenge, err := s.consumeChallenge(ctx, session, domain.WebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.credentialRepo.FindByCredentialID(ctx, response.RawID)
+	if err != nil {
+		if err == domain.ErrWebAuthnCredentialNotFound {
+			s.recordFailure(ctx, nil, ip)
+			return nil, domain.ErrWebAuthnVerificationFailed
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(ctx, credential.UserID)
+	if err != nil {
+		s.logger.Error("Failed to find passkey owner",
+			zap.String("credential_id", credential.ID.String()),
+			zap.Error(err))
+		return nil, domain.ErrWebAuthnVerificationFailed
+	}
+
+	if err := s.lockoutService.Check(ctx, user.ID.String(), ""); err != nil {
+		return nil, err
+	}
+
+	// A discoverable credential reports the user it was created for, which must be its owner
+	if len(response.Response.UserHandle) > 0 && !bytes.Equal(response.Response.UserHandle, user.ID.Bytes()) {
+		s.recordFailure(ctx, user, ip)
+		return nil, domain.ErrWebAuthnVerificationFailed
+	}
+
+	if err := s.verifyAssertion(ctx, credential, challenge, response, true); err != nil {
+		s.recordFailure(ctx, user, ip)
+		return nil, err
+	}
+
+	// Passkeys sign users in like passwords do, so an unverified email is refused the same way
+	if !user.EmailVerified {
+		return nil, domain.ErrEmailNotVerified
+	}
+
+	if err := s.lockoutService.RecordSuccess(ctx, user.ID.String()); err != nil {
+		s.logger.Error("Failed to clear failed login attempts",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+	}
+
+	// The passkey verified the user with a PIN or biometric on top of possession
+	auth := domain.NewAuthentication(domain.AMRHardwareKey, domain.AMRMultiFactor)
+	s.loginHistory.Record(ctx, user, auth)
+	return s.jwtService.GenerateTokenPairWithParams(user.ID, user.Roles, &domain.TokenParams{Authentication: auth})
+}
+
+// BeginMFA starts verifying one of the ticket user's passkeys as their second factor
+func (s *WebAuthnService) BeginMFA(ctx context.Context, ticketID string) (*domain.WebAuthnLoginStart, error) {
+	ticket, err := s.mfaTicketRepo.Get(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(ticket.ExpiresAt) {
+		return nil, domain.ErrMFATicketExpired
+	}
+
+	userID, err := ulid.Parse(ticket.User)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	credentials, err := s.credentialRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, domain.ErrWebAuthnCredentialNotFound
+	}
+
+	challenge, err := s.createChallenge(ctx, domain.WebAuthnMFA, userID, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	// The password was already checked, so presence on a registered authenticator is enough
+	return s.requestOptions(challenge, credentials, "preferred"), nil
+}
+
+// FinishMFA verifies a passkey assertion for an MFA ticket. Failures count against the ticket
+// like a wrong TOTP code.
+func (s *WebAuthnService) FinishMFA(ctx context.Context, ticketID, session string, response *domain.WebAuthnAssertionResponse) (*domain.TokenPair, error) {
+	return s.authService.CompleteMFA(ctx, ticketID, func(user *domain.User) (domain.MFAFactorType, error) {
+		challenge, err := s.consumeChallenge(ctx, session, domain.WebAuthnMFA)
+		if err != nil {
+			return "", err
+		}
+		if challenge.MFATicket != ticketID || challenge.UserID != user.ID {
+			return "", domain.ErrInvalidWebAuthnChallenge
+		}
+
+		credential, err := s.credentialRepo.FindByCredentialID(ctx, response.RawID)
+		if err != nil || credential.UserID != user.ID {
+			return "", domain.ErrWebAuthnVerificationFailed
+		}
+
+		return domain.MFAFactorWebAuthn, s.verifyAssertion(ctx, credential, challenge, response, false)
+	})
+}
+
+// ListCredentials lists the passkeys of a user
+func (s *WebAuthnService) ListCredentials(ctx context.Context, userID string) ([]*domain.WebAuthnCredential, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+	return s.credentialRepo.ListByUser(ctx, id)
+}
+
+// RenameCredential changes the friendly name of a passkey
+func (s *WebAuthnService) RenameCredential(ctx context.Context, userID, credentialID, name string) error {
+	user, id, err := parseCredentialIDs(userID, credentialID)
+	if err != nil {
+		return err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	return s.credentialRepo.Rename(ctx, user, id, name)
+}
+
+// DeleteCredential removes a passkey
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID, credentialID string) error {
+	user, id, err := parseCredentialIDs(userID, credentialID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.credentialRepo.Delete(ctx, user, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("Passkey deleted",
+		zap.String("user_id", userID),
+		zap.String("credential_id", credentialID))
+	return nil
+}
+
+// verifyAssertion verifies an assertion made with the credential and records its use
+func (s *WebAuthnService) verifyAssertion(ctx context.Context, credential *domain.WebAuthnCredential, challenge *domain.WebAuthnChallenge, response *domain.WebAuthnAssertionResponse, requireUserVerification bool) error {
+	assertion, err := s.verifier.VerifyAssertion(challenge.Challenge, response, credential.PublicKey, requireUserVerification)
+	if err != nil {
+		s.logger.Warn("WebAuthn assertion rejected",
+			zap.String("credential_id", credential.ID.String()),
+			zap.Error(err))
+		return domain.ErrWebAuthnVerificationFailed
+	}
+
+	// Authenticators that keep a counter must increase it on every use, so a counter that
+	// goes backwards points at a cloned authenticator
+	if (assertion.SignCount != 0 || credential.SignCount != 0) && assertion.SignCount <= credential.SignCount {
+		s.logger.Warn("WebAuthn signature counter did not increase, the authenticator may be cloned",
+			zap.String("credential_id", credential.ID.String()),
+			zap.Uint32("stored", credential.SignCount),
+			zap.Uint32("received", assertion.SignCount))
+		return domain.ErrWebAuthnVerificationFailed
+	}
+
+	return s.credentialRepo.UpdateSignCount(ctx, credential.ID, assertion.SignCount, time.Now())
+}
+
+// createChallenge stores a new random challenge for a ceremony
+func (s *WebAuthnService) createChallenge(ctx context.Context, ceremony domain.WebAuthnCeremony, userID ulid.ULID, ticketID string) (*domain.WebAuthnChallenge, error) {
+	random := make([]byte, webAuthnChallengeLength)
+	if _, err := rand.Read(random); err != nil {
+		s.logger.Error("Failed to generate WebAuthn challenge", zap.Error(err))
+		return nil, domain.ErrInternal
+	}
+
+	challenge := &domain.WebAuthnChallenge{
+		ID:        ulid.Make(),
+		Ceremony:  ceremony,
+		Challenge: random,
+		UserID:    userID,
+		MFATicket: ticketID,
+		ExpiresAt: time.Now().Add(s.config.WebAuthnTimeout),
+	}
+	if err := s.challengeRepo.Create(ctx, challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// consumeChallenge takes a challenge out of storage, checking it was issued for the ceremony
+func (s *WebAuthnService) consumeChallenge(ctx context.Context, session string, ceremony domain.WebAuthnCeremony) (*domain.WebAuthnChallenge, error) {
+	id, err := ulid.Parse(session)
+	if err != nil {
+		return nil, domain.ErrInvalidWebAuthnChallenge
+	}
+
+	challenge, err := s.challengeRepo.Consume(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Ceremony != ceremony || challenge.IsExpired() {
+		return nil, domain.ErrInvalidWebAuthnChallenge
+	}
+	return challenge, nil
+}
+
+func (s *WebAuthnService) requestOptions(challenge *domain.WebAuthnChallenge, credentials []*domain.WebAuthnCredential, userVerification string) *domain.WebAuthnLoginStart {
+	return &domain.WebAuthnLoginStart{
+		Session: challenge.ID.String(),
+		PublicKey: &domain.WebAuthnRequestOptions{
+			Challenge:        challenge.Challenge,
+			Timeout:          s.config.WebAuthnTimeout.Milliseconds(),
+			RPID:             s.config.WebAuthnRPID,
+			AllowCredentials: descriptors(credentials),
+			UserVerification: userVerification,
+		},
+	}
+}
+
+func (s *WebAuthnService) findUser(ctx context.Context, userID string) (*domain.User, error) {
+	id, err := ulid.Parse(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidUserID
+	}
+
+	user, err := s.userRepo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("User not found", zap.String("user_id", userID), zap.Error(err))
+		return nil, domain.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *WebAuthnService) recordFailure(ctx context.Context, user *domain.User, ip string) {
+	if err := s.lockoutService.RecordFailure(ctx, user, ip); err != nil {
+		s.logger.Error("Failed to record failed passkey attempt", zap.Error(err))
+	}
+}
+
+// descriptors lists credentials in the form the WebAuthn client API takes
+func descriptors(credentials []*domain.WebAuthnCredential) []domain.WebAuthnCredentialDescriptor {
+	list := make([]domain.WebAuthnCredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		list = append(list, domain.WebAuthnCredentialDescriptor{
+			Type:       publicKeyCredential,
+			ID:         credential.CredentialID,
+			Transports: credential.Transports,
+		})
+	}
+	return list
+}
+
+func parseCredentialIDs(userID, credentialID string) (ulid.ULID, ulid.ULID, error) {
+	user, err := ulid.Parse(userID)
+	if err != nil {
+		return ulid.ULID{}, ulid.ULID{}, domain.ErrInvalidUserID
+	}
+	id, err := ulid.Parse(credentialID)
+	if err != nil {
+		return ulid.ULID{}, ulid.ULID{}, domain.ErrWebAuthnCredentialNotFound
+	}
+	return user, id, nil
+}
@@ -0,0 +1,494 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockWebAuthnCredentialRepository struct {
+	mock.Mock
+}
+
+func (m *mockWebAuthnCredentialRepository) Create(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	args := m.Called(ctx, credential)
+	return args.Error(0)
+}
+
+func (m *mockWebAuthnCredentialRepository) FindByCredentialID(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error) {
+	args := m.Called(ctx, credentialID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnCredential), args.Error(1)
+}
+
+func (m *mockWebAuthnCredentialRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.WebAuthnCredential, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.WebAuthnCredential), args.Error(1)
+}
+
+func (m *mockWebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id ulid.ULID, signCount uint32, usedAt time.Time) error {
+	args := m.Called(ctx, id, signCount, usedAt)
+	return args.Error(0)
+}
+
+func (m *mockWebAuthnCredentialRepository) Rename(ctx context.Context, userID, id ulid.ULID, name string) error {
+	args := m.Called(ctx, userID, id, name)
+	return args.Error(0)
+}
+
+func (m *mockWebAuthnCredentialRepository) Delete(ctx context.Context, userID, id ulid.ULID) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+type mockWebAuthnChallengeRepository struct {
+	mock.Mock
+}
+
+func (m *mockWebAuthnChallengeRepository) Create(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	args := m.Called(ctx, challenge)
+	return args.Error(0)
+}
+
+func (m *mockWebAuthnChallengeRepository) Consume(ctx context.Context, id ulid.ULID) (*domain.WebAuthnChallenge, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnChallenge), args.Error(1)
+}
+
+type mockWebAuthnVerifier struct {
+	mock.Mock
+}
+
+func (m *mockWebAuthnVerifier) VerifyRegistration(challenge []byte, response *domain.WebAuthnAttestationResponse, requireUserVerification bool) (*domain.WebAuthnAttestation, error) {
+	args := m.Called(challenge, response, requireUserVerification)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnAttestation), args.Error(1)
+}
+
+func (m *mockWebAuthnVerifier) VerifyAssertion(challenge []byte, response *domain.WebAuthnAssertionResponse, publicKey []byte, requireUserVerification bool) (*domain.WebAuthnAssertion, error) {
+	args := m.Called(challenge, response, publicKey, requireUserVerification)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnAssertion), args.Error(1)
+}
+
+func newTestWebAuthnConfig() *config.Config {
+	return &config.Config{
+		WebAuthnRPID:    "example.com",
+		WebAuthnRPName:  "Example",
+		WebAuthnOrigins: []string{"https://example.com"},
+		WebAuthnTimeout: 5 * time.Minute,
+		MFAMaxAttempts:  5,
+	}
+}
+
+func TestWebAuthnService_Registration(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Name: "Test User", Email: "test@example.com"}
+	existing := &domain.WebAuthnCredential{ID: ulid.Make(), UserID: user.ID, CredentialID: []byte("existing"), Transports: []string{"usb"}}
+
+	userRepo := new(MockUserRepository)
+	credentialRepo := new(mockWebAuthnCredentialRepository)
+	challengeRepo := new(mockWebAuthnChallengeRepository)
+	verifier := new(mockWebAuthnVerifier)
+
+	userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+	credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{existing}, nil)
+
+	var stored *domain.WebAuthnChallenge
+	challengeRepo.On("Create", mock.Anything, mock.AnythingOfType("*domain.WebAuthnChallenge")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*domain.WebAuthnChallenge) }).
+		Return(nil)
+
+	service := NewWebAuthnService(credentialRepo, challengeRepo, userRepo, nil, verifier, nil, nil, nil, newTestLoginHistory(), newTestWebAuthnConfig(), zap.NewNop())
+
+	start, err := service.BeginRegistration(context.Background(), user.ID.String())
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, stored.ID.String(), start.Session)
+	assert.Equal(t, domain.WebAuthnRegistration, stored.Ceremony)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.Len(t, stored.Challenge, webAuthnChallengeLength)
+	assert.Equal(t, domain.Base64URL(stored.Challenge), start.PublicKey.Challenge)
+	assert.Equal(t, "example.com", start.PublicKey.RP.ID)
+	assert.Equal(t, domain.Base64URL(user.ID.Bytes()), start.PublicKey.User.ID)
+	assert.Equal(t, int64(300000), start.PublicKey.Timeout)
+	assert.Equal(t, []domain.WebAuthnCredentialDescriptor{{Type: "public-key", ID: existing.CredentialID, Transports: []string{"usb"}}}, start.PublicKey.ExcludeCredentials)
+
+	response := &domain.WebAuthnAttestationResponse{RawID: []byte("new-credential")}
+	response.Response.Transports = []string{"internal", "hybrid"}
+
+	tests := []struct {
+		name          string
+		challenge     *domain.WebAuthnChallenge
+		setupMocks    func(*mockWebAuthnCredentialRepository, *mockWebAuthnVerifier)
+		expectedError error
+	}{
+		{
+			name:      "valid registration",
+			challenge: stored,
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier) {
+				v.On("VerifyRegistration", stored.Challenge, response, false).Return(&domain.WebAuthnAttestation{
+					CredentialID: []byte("new-credential"),
+					PublicKey:    []byte("cose-key"),
+					AAGUID:       "adce0002-35bc-c60a-648b-0b25f1f05503",
+				}, nil)
+				c.On("FindByCredentialID", mock.Anything, []byte("new-credential")).Return(nil, domain.ErrWebAuthnCredentialNotFound)
+				c.On("Create", mock.Anything, mock.MatchedBy(func(credential *domain.WebAuthnCredential) bool {
+					return credential.UserID == user.ID &&
+						string(credential.CredentialID) == "new-credential" &&
+						string(credential.PublicKey) == "cose-key" &&
+						credential.Name == defaultPasskeyName &&
+						len(credential.Transports) == 2
+				})).Return(nil)
+			},
+		},
+		{
+			name:      "credential already registered",
+			challenge: stored,
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier) {
+				v.On("VerifyRegistration", stored.Challenge, response, false).Return(&domain.WebAuthnAttestation{CredentialID: []byte("new-credential")}, nil)
+				c.On("FindByCredentialID", mock.Anything, []byte("new-credential")).Return(existing, nil)
+			},
+			expectedError: domain.ErrWebAuthnCredentialExists,
+		},
+		{
+			name:      "response does not verify",
+			challenge: stored,
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier) {
+				v.On("VerifyRegistration", stored.Challenge, response, false).Return(nil, assert.AnError)
+			},
+			expectedError: domain.ErrWebAuthnVerificationFailed,
+		},
+		{
+			name:          "challenge issued for another user",
+			challenge:     &domain.WebAuthnChallenge{ID: stored.ID, Ceremony: domain.WebAuthnRegistration, UserID: ulid.Make(), ExpiresAt: stored.ExpiresAt},
+			setupMocks:    func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier) {},
+			expectedError: domain.ErrInvalidWebAuthnChallenge,
+		},
+		{
+			name:          "challenge issued for a login",
+			challenge:     &domain.WebAuthnChallenge{ID: stored.ID, Ceremony: domain.WebAuthnLogin, UserID: user.ID, ExpiresAt: stored.ExpiresAt},
+			setupMocks:    func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier) {},
+			expectedError: domain.ErrInvalidWebAuthnChallenge,
+		},
+		{
+			name:          "expired challenge",
+			challenge:     &domain.WebAuthnChallenge{ID: stored.ID, Ceremony: domain.WebAuthnRegistration, UserID: user.ID, ExpiresAt: time.Now().Add(-time.Second)},
+			setupMocks:    func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier) {},
+			expectedError: domain.ErrInvalidWebAuthnChallenge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credentialRepo := new(mockWebAuthnCredentialRepository)
+			challengeRepo := new(mockWebAuthnChallengeRepository)
+			verifier := new(mockWebAuthnVerifier)
+
+			challengeRepo.On("Consume", mock.Anything, stored.ID).Return(tt.challenge, nil)
+			tt.setupMocks(credentialRepo, verifier)
+
+			service := NewWebAuthnService(credentialRepo, challengeRepo, userRepo, nil, verifier, nil, nil, nil, newTestLoginHistory(), newTestWebAuthnConfig(), zap.NewNop())
+
+			credential, err := service.FinishRegistration(context.Background(), user.ID.String(), start.Session, "  ", response)
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, credential)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, defaultPasskeyName, credential.Name)
+			}
+
+			credentialRepo.AssertExpectations(t)
+			verifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWebAuthnService_FinishLogin(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", Roles: []string{"user"}, EmailVerified: true}
+	unverified := &domain.User{ID: user.ID, Email: user.Email, Roles: user.Roles}
+	credential := &domain.WebAuthnCredential{ID: ulid.Make(), UserID: user.ID, CredentialID: []byte("credential"), PublicKey: []byte("cose-key"), SignCount: 4}
+	session := ulid.Make()
+	challenge := &domain.WebAuthnChallenge{ID: session, Ceremony: domain.WebAuthnLogin, Challenge: []byte("challenge"), ExpiresAt: time.Now().Add(time.Minute)}
+	const ip = "203.0.113.7"
+
+	newResponse := func(userHandle []byte) *domain.WebAuthnAssertionResponse {
+		response := &domain.WebAuthnAssertionResponse{RawID: []byte("credential")}
+		response.Response.UserHandle = userHandle
+		return response
+	}
+
+	tests := []struct {
+		name          string
+		response      *domain.WebAuthnAssertionResponse
+		owner         *domain.User
+		setupMocks    func(*mockWebAuthnCredentialRepository, *mockWebAuthnVerifier, *mockLockoutService, *mockJWTService)
+		expectedError error
+	}{
+		{
+			name:     "valid passkey",
+			response: newResponse(user.ID.Bytes()),
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier, l *mockLockoutService, j *mockJWTService) {
+				c.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(credential, nil)
+				l.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+				v.On("VerifyAssertion", challenge.Challenge, mock.Anything, credential.PublicKey, true).Return(&domain.WebAuthnAssertion{SignCount: 5, UserVerified: true}, nil)
+				c.On("UpdateSignCount", mock.Anything, credential.ID, uint32(5), mock.AnythingOfType("time.Time")).Return(nil)
+				l.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+				j.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+			},
+		},
+		{
+			name:     "unknown credential counts against the IP",
+			response: newResponse(nil),
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier, l *mockLockoutService, j *mockJWTService) {
+				c.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(nil, domain.ErrWebAuthnCredentialNotFound)
+				l.On("RecordFailure", mock.Anything, (*domain.User)(nil), ip).Return(nil)
+			},
+			expectedError: domain.ErrWebAuthnVerificationFailed,
+		},
+		{
+			name:     "user handle of another user",
+			response: newResponse(ulid.Make().Bytes()),
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier, l *mockLockoutService, j *mockJWTService) {
+				c.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(credential, nil)
+				l.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+				l.On("RecordFailure", mock.Anything, user, ip).Return(nil)
+			},
+			expectedError: domain.ErrWebAuthnVerificationFailed,
+		},
+		{
+			name:     "invalid signature",
+			response: newResponse(nil),
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier, l *mockLockoutService, j *mockJWTService) {
+				c.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(credential, nil)
+				l.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+				v.On("VerifyAssertion", challenge.Challenge, mock.Anything, credential.PublicKey, true).Return(nil, assert.AnError)
+				l.On("RecordFailure", mock.Anything, user, ip).Return(nil)
+			},
+			expectedError: domain.ErrWebAuthnVerificationFailed,
+		},
+		{
+			name:     "signature counter went backwards",
+			response: newResponse(nil),
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier, l *mockLockoutService, j *mockJWTService) {
+				c.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(credential, nil)
+				l.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+				v.On("VerifyAssertion", challenge.Challenge, mock.Anything, credential.PublicKey, true).Return(&domain.WebAuthnAssertion{SignCount: 4, UserVerified: true}, nil)
+				l.On("RecordFailure", mock.Anything, user, ip).Return(nil)
+			},
+			expectedError: domain.ErrWebAuthnVerificationFailed,
+		},
+		{
+			name:     "email not verified",
+			response: newResponse(nil),
+			owner:    unverified,
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier, l *mockLockoutService, j *mockJWTService) {
+				c.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(credential, nil)
+				l.On("Check", mock.Anything, user.ID.String(), "").Return(nil)
+				v.On("VerifyAssertion", challenge.Challenge, mock.Anything, credential.PublicKey, true).Return(&domain.WebAuthnAssertion{SignCount: 5, UserVerified: true}, nil)
+				c.On("UpdateSignCount", mock.Anything, credential.ID, uint32(5), mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			expectedError: domain.ErrEmailNotVerified,
+		},
+		{
+			name:     "account locked",
+			response: newResponse(nil),
+			setupMocks: func(c *mockWebAuthnCredentialRepository, v *mockWebAuthnVerifier, l *mockLockoutService, j *mockJWTService) {
+				c.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(credential, nil)
+				l.On("Check", mock.Anything, user.ID.String(), "").Return(domain.ErrAccountLocked)
+			},
+			expectedError: domain.ErrAccountLocked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			credentialRepo := new(mockWebAuthnCredentialRepository)
+			challengeRepo := new(mockWebAuthnChallengeRepository)
+			verifier := new(mockWebAuthnVerifier)
+			lockout := new(mockLockoutService)
+			jwtSvc := new(mockJWTService)
+
+			lockout.On("Check", mock.Anything, "", ip).Return(nil)
+			challengeRepo.On("Consume", mock.Anything, session).Return(challenge, nil)
+			owner := user
+			if tt.owner != nil {
+				owner = tt.owner
+			}
+			userRepo.On("FindByID", mock.Anything, user.ID).Return(owner, nil).Maybe()
+			tt.setupMocks(credentialRepo, verifier, lockout, jwtSvc)
+
+			service := NewWebAuthnService(credentialRepo, challengeRepo, userRepo, nil, verifier, nil, jwtSvc, lockout, newTestLoginHistory(), newTestWebAuthnConfig(), zap.NewNop())
+			ctx := domain.WithClientIP(context.Background(), ip)
+
+			tokenPair, err := service.FinishLogin(ctx, session.String(), tt.response)
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, tokenPair)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "access_token", tokenPair.AccessToken)
+			}
+
+			credentialRepo.AssertExpectations(t)
+			verifier.AssertExpectations(t)
+			lockout.AssertExpectations(t)
+			jwtSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWebAuthnService_MFA(t *testing.T) {
+	user := &domain.User{ID: ulid.Make(), Email: "test@example.com", Roles: []string{"user"}}
+	credential := &domain.WebAuthnCredential{ID: ulid.Make(), UserID: user.ID, CredentialID: []byte("credential"), PublicKey: []byte("cose-key")}
+	ticketID := ulid.Make().String()
+	ticket := &domain.MFATicket{User: user.ID.String(), ExpiresAt: time.Now().Add(5 * time.Minute)}
+	const ip = "203.0.113.7"
+
+	t.Run("begin lists the user's passkeys", func(t *testing.T) {
+		ticketRepo := new(mockMFATicketRepository)
+		credentialRepo := new(mockWebAuthnCredentialRepository)
+		challengeRepo := new(mockWebAuthnChallengeRepository)
+
+		ticketRepo.On("Get", mock.Anything, ticketID).Return(ticket, nil)
+		credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{credential}, nil)
+		challengeRepo.On("Create", mock.Anything, mock.MatchedBy(func(c *domain.WebAuthnChallenge) bool {
+			return c.Ceremony == domain.WebAuthnMFA && c.UserID == user.ID && c.MFATicket == ticketID
+		})).Return(nil)
+
+		service := NewWebAuthnService(credentialRepo, challengeRepo, nil, ticketRepo, nil, nil, nil, nil, newTestLoginHistory(), newTestWebAuthnConfig(), zap.NewNop())
+
+		start, err := service.BeginMFA(context.Background(), ticketID)
+		require.NoError(t, err)
+		require.Len(t, start.PublicKey.AllowCredentials, 1)
+		assert.Equal(t, domain.Base64URL("credential"), start.PublicKey.AllowCredentials[0].ID)
+		challengeRepo.AssertExpectations(t)
+	})
+
+	t.Run("begin without passkeys", func(t *testing.T) {
+		ticketRepo := new(mockMFATicketRepository)
+		credentialRepo := new(mockWebAuthnCredentialRepository)
+
+		ticketRepo.On("Get", mock.Anything, ticketID).Return(ticket, nil)
+		credentialRepo.On("ListByUser", mock.Anything, user.ID).Return([]*domain.WebAuthnCredential{}, nil)
+
+		service := NewWebAuthnService(credentialRepo, nil, nil, ticketRepo, nil, nil, nil, nil, newTestLoginHistory(), newTestWebAuthnConfig(), zap.NewNop())
+
+		_, err := service.BeginMFA(context.Background(), ticketID)
+		assert.Equal(t, domain.ErrWebAuthnCredentialNotFound, err)
+	})
+
+	session := ulid.Make()
+	tests := []struct {
+		name          string
+		challenge     *domain.WebAuthnChallenge
+		assertion     *domain.WebAuthnAssertion
+		expectedError error
+	}{
+		{
+			name:      "valid passkey redeems the ticket",
+			challenge: &domain.WebAuthnChallenge{ID: session, Ceremony: domain.WebAuthnMFA, Challenge: []byte("challenge"), UserID: user.ID, MFATicket: ticketID, ExpiresAt: time.Now().Add(time.Minute)},
+			assertion: &domain.WebAuthnAssertion{SignCount: 0},
+		},
+		{
+			name:          "challenge of another ticket counts as an attempt",
+			challenge:     &domain.WebAuthnChallenge{ID: session, Ceremony: domain.WebAuthnMFA, Challenge: []byte("challenge"), UserID: user.ID, MFATicket: "other", ExpiresAt: time.Now().Add(time.Minute)},
+			expectedError: domain.ErrInvalidWebAuthnChallenge,
+		},
+		{
+			name:          "invalid assertion counts as an attempt",
+			challenge:     &domain.WebAuthnChallenge{ID: session, Ceremony: domain.WebAuthnMFA, Challenge: []byte("challenge"), UserID: user.ID, MFATicket: ticketID, ExpiresAt: time.Now().Add(time.Minute)},
+			expectedError: domain.ErrWebAuthnVerificationFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepo := new(MockUserRepository)
+			ticketRepo := new(mockMFATicketRepository)
+			credentialRepo := new(mockWebAuthnCredentialRepository)
+			challengeRepo := new(mockWebAuthnChallengeRepository)
+			verifier := new(mockWebAuthnVerifier)
+			lockout := new(mockLockoutService)
+			jwtSvc := new(mockJWTService)
+
+			ticketRepo.On("Get", mock.Anything, ticketID).Return(ticket, nil)
+			userRepo.On("FindByID", mock.Anything, user.ID).Return(user, nil)
+			lockout.On("Check", mock.Anything, user.ID.String(), ip).Return(nil)
+			challengeRepo.On("Consume", mock.Anything, session).Return(tt.challenge, nil)
+			credentialRepo.On("FindByCredentialID", mock.Anything, []byte("credential")).Return(credential, nil).Maybe()
+
+			if tt.expectedError == nil {
+				verifier.On("VerifyAssertion", []byte("challenge"), mock.Anything, credential.PublicKey, false).Return(tt.assertion, nil)
+				credentialRepo.On("UpdateSignCount", mock.Anything, credential.ID, uint32(0), mock.AnythingOfType("time.Time")).Return(nil)
+				ticketRepo.On("Delete", mock.Anything, ticketID).Return(nil)
+				lockout.On("RecordSuccess", mock.Anything, user.ID.String()).Return(nil)
+				jwtSvc.On("GenerateTokenPair", user.ID, user.Roles).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+			} else {
+				verifier.On("VerifyAssertion", []byte("challenge"), mock.Anything, credential.PublicKey, false).Return(nil, assert.AnError).Maybe()
+				lockout.On("RecordFailure", mock.Anything, user, ip).Return(nil)
+				ticketRepo.On("IncrementAttempts", mock.Anything, ticketID).Return(1, nil)
+			}
+
+			authService := NewAuthService(userRepo, nil, jwtSvc, nil, nil, nil, nil, ticketRepo, nil, newTestLoginHistory(), newTestPasswordHasher(), nil, lockout, nil, nil, newTestWebAuthnConfig(), zap.NewNop())
+			service := NewWebAuthnService(credentialRepo, challengeRepo, userRepo, ticketRepo, verifier, authService, jwtSvc, lockout, newTestLoginHistory(), newTestWebAuthnConfig(), zap.NewNop())
+			ctx := domain.WithClientIP(context.Background(), ip)
+
+			response := &domain.WebAuthnAssertionResponse{RawID: []byte("credential")}
+			tokenPair, err := service.FinishMFA(ctx, ticketID, session.String(), response)
+			if tt.expectedError != nil {
+				assert.Equal(t, tt.expectedError, err)
+				assert.Nil(t, tokenPair)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "access_token", tokenPair.AccessToken)
+			}
+
+			ticketRepo.AssertExpectations(t)
+			lockout.AssertExpectations(t)
+			jwtSvc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWebAuthnService_ManageCredentials(t *testing.T) {
+	userID := ulid.Make()
+	credentialID := ulid.Make()
+
+	credentialRepo := new(mockWebAuthnCredentialRepository)
+	credentialRepo.On("Rename", mock.Anything, userID, credentialID, defaultPasskeyName).Return(nil)
+	credentialRepo.On("Rename", mock.Anything, userID, credentialID, "Work laptop").Return(nil)
+	credentialRepo.On("Delete", mock.Anything, userID, credentialID).Return(domain.ErrWebAuthnCredentialNotFound)
+
+	service := NewWebAuthnService(credentialRepo, nil, nil, nil, nil, nil, nil, nil, newTestLoginHistory(), newTestWebAuthnConfig(), zap.NewNop())
+	ctx := context.Background()
+
+	assert.NoError(t, service.RenameCredential(ctx, userID.String(), credentialID.String(), " Work laptop "))
+	assert.NoError(t, service.RenameCredential(ctx, userID.String(), credentialID.String(), ""))
+	assert.Equal(t, domain.ErrWebAuthnCredentialNotFound, service.DeleteCredential(ctx, userID.String(), credentialID.String()))
+	assert.Equal(t, domain.ErrWebAuthnCredentialNotFound, service.DeleteCredential(ctx, userID.String(), "not-an-id"))
+	assert.Equal(t, domain.ErrInvalidUserID, service.RenameCredential(ctx, "not-an-id", credentialID.String(), "name"))
+
+	credentialRepo.AssertExpectations(t)
+}
@@ -13,6 +13,28 @@ type MFATicket struct {
 	User      string    `json:"user"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int       `json:"-"`
+	// EnrollmentRequired marks a ticket issued because the MFA policy requires a second factor the
+	// user has not enrolled; it can only be redeemed by enrolling one
+	EnrollmentRequired bool `json:"enrollment_required,omitempty"`
+	// RiskChallenge marks a ticket issued because a user without a second factor signed in from a
+	// new device; it is redeemed with a code sent to the user's email
+	RiskChallenge bool `json:"risk_challenge,omitempty"`
+	// AMR lists the methods of the first factor, completed by the second one in the tokens
+	AMR []string `json:"-"`
+	// FactorTypes and Factors list the second factors the user can choose from, or for an
+	// enrolment ticket the types that can be enrolled; they are filled in when the ticket is
+	// issued and not stored with it
+	FactorTypes []MFAFactorType `json:"factor_types,omitempty"`
+	Factors     []*MFAFactor    `json:"factors,omitempty"`
+}
+
+// MFAEnrollment is the outcome of enrolling a first authenticator app with an enrolment ticket:
+// the tokens of the login it completes, the new factor and the user's backup codes
+type MFAEnrollment struct {
+	TokenPair
+	FactorID    string   `json:"factor_id"`
+	BackupCodes []string `json:"backup_codes,omitempty"`
 }
 
 // MFATicketRepository defines the interface for MFA ticket operations
@@ -21,6 +43,8 @@ type MFATicketRepository interface {
 	Create(ctx context.Context, ticket *MFATicket) error
 	// Get retrieves an MFA ticket by ID
 	Get(ctx context.Context, id string) (*MFATicket, error)
+	// IncrementAttempts records a failed verification against an MFA ticket and returns the new count
+	IncrementAttempts(ctx context.Context, id string) (int, error)
 	// Delete deletes an MFA ticket
 	Delete(ctx context.Context, id string) error
 }
@@ -31,12 +55,48 @@ type AuthService interface {
 	Register(ctx context.Context, name, email, password, phone string) (*User, error)
 	// Login authenticates a user and returns a token pair or MFA ticket
 	Login(ctx context.Context, email, password string) (interface{}, error)
-	// VerifyMFA verifies the MFA code and returns a token pair
-	VerifyMFA(ctx context.Context, ticketID, code string) (*TokenPair, error)
+	// VerifyMFA verifies a code against the chosen factor and returns a token pair. Without a
+	// factor ID the code is checked against the user's authenticator apps.
+	VerifyMFA(ctx context.Context, ticketID, factorID, code string, trust *DeviceTrust) (*TokenPair, error)
+	// ChallengeMFA sends a one-time code for the chosen factor of an MFA ticket, by text message
+	// or voice call for an sms_otp factor
+	ChallengeMFA(ctx context.Context, ticketID, factorID string, channel SMSChannel) error
+	// CompleteMFA redeems an MFA ticket once verify accepts the user's second factor and reports its
+	// type, counting rejected attempts against the ticket and the lockout the same way as VerifyMFA
+	CompleteMFA(ctx context.Context, ticketID string, verify func(user *User) (MFAFactorType, error)) (*TokenPair, error)
+	// BeginMFAEnrollment starts enrolling an authenticator app for the user of an enrolment ticket
+	BeginMFAEnrollment(ctx context.Context, ticketID, label string) (*TOTP, error)
+	// CompleteMFAEnrollment confirms the authenticator app started with an enrolment ticket and
+	// finishes the login, counting rejected codes the same way as VerifyMFA
+	CompleteMFAEnrollment(ctx context.Context, ticketID, code string) (*MFAEnrollment, error)
 	// VerifyEmail verifies the email code and returns a token pair
 	VerifyEmail(ctx context.Context, email, code string) error
 	// RequestPasswordReset requests a password reset
 	RequestPasswordReset(ctx context.Context, email string) error
 	// ResetPassword resets the password
 	ResetPassword(ctx context.Context, email, code, newPassword string) error
+	// ChangePassword changes the password of a signed-in user, revoking their other sessions,
+	// and returns a new token pair for the current one
+	ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (*TokenPair, error)
+	// RequestEmailChange sends a confirmation code to the new email and a notice to the current one
+	RequestEmailChange(ctx context.Context, userID, newEmail, password string) error
+	// ConfirmEmailChange swaps in the new email once its confirmation code is given
+	ConfirmEmailChange(ctx context.Context, userID, code string) error
+	// RequestPasswordlessLogin emails a one-time sign-in code or link to a registered address
+	RequestPasswordlessLogin(ctx context.Context, email string, method PasswordlessMethod) error
+	// PasswordlessLogin redeems a one-time sign-in code and returns a token pair or MFA ticket
+	PasswordlessLogin(ctx context.Context, email, code string) (interface{}, error)
+	// CompleteLogin finishes a sign-in whose first factor was verified elsewhere, such as by an
+	// upstream identity provider, and returns a token pair or MFA ticket
+	CompleteLogin(ctx context.Context, user *User, auth *Authentication) (interface{}, error)
 }
+
+// PasswordlessMethod is how a passwordless login secret is delivered
+type PasswordlessMethod string
+
+const (
+	// PasswordlessCode emails a short numeric code to type in
+	PasswordlessCode PasswordlessMethod = "code"
+	// PasswordlessLink emails a link carrying a long random token
+	PasswordlessLink PasswordlessMethod = "link"
+)
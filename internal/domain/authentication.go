@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Authentication method references (RFC 8176) recorded in the amr claim
+const (
+	// AMRPassword is a password
+	AMRPassword = "pwd"
+	// AMROTP is a one-time code, from an authenticator app, an email or a backup code list
+	AMROTP = "otp"
+	// AMRSMS is a one-time code sent by text message
+	AMRSMS = "sms"
+	// AMRHardwareKey is a passkey or security key
+	AMRHardwareKey = "hwk"
+	// AMRMultiFactor marks a sign-in that used more than one factor
+	AMRMultiFactor = "mfa"
+	// AMRFederated is a sign-in at an upstream identity provider. It is not registered by RFC 8176,
+	// which has no value for delegated authentication.
+	AMRFederated = "fed"
+)
+
+// Authentication context classes recorded in the acr claim, from the weakest to the strongest
+const (
+	// ACRSingleFactor is a sign-in with a single factor
+	ACRSingleFactor = "urn:authm:acr:single-factor"
+	// ACRMultiFactor is a sign-in with a second factor, or with a passkey verifying the user
+	ACRMultiFactor = "urn:authm:acr:multi-factor"
+)
+
+// SupportedACRValues lists the authentication context classes the server issues, weakest first
+var SupportedACRValues = []string{ACRSingleFactor, ACRMultiFactor}
+
+// WeakestACR returns the weakest of the requested context classes the server issues, which is the
+// least a sign-in must reach to satisfy any of them, or an empty string when it issues none of them
+func WeakestACR(values []string) string {
+	for _, acr := range SupportedACRValues {
+		if slices.Contains(values, acr) {
+			return acr
+		}
+	}
+	return ""
+}
+
+// Authentication records how and when a user signed in. It is carried by the amr, acr and
+// auth_time claims of every token issued for the sign-in, including refreshed ones.
+type Authentication struct {
+	Methods []string
+	Time    time.Time
+}
+
+// NewAuthentication records a sign-in completed now with the given methods
+func NewAuthentication(methods ...string) *Authentication {
+	return &Authentication{
+		Methods: methods,
+		Time:    time.Now().Truncate(time.Second),
+	}
+}
+
+// ACR returns the authentication context class the sign-in reached
+func (a *Authentication) ACR() string {
+	if slices.Contains(a.Methods, AMRMultiFactor) {
+		return ACRMultiFactor
+	}
+	return ACRSingleFactor
+}
+
+// Satisfies reports whether the sign-in reached at least the given context class
+func (a *Authentication) Satisfies(acr string) bool {
+	required := slices.Index(SupportedACRValues, acr)
+	return required >= 0 && slices.Index(SupportedACRValues, a.ACR()) >= required
+}
+
+// WithFactor returns the authentication completed by a second factor of the given type
+func (a *Authentication) WithFactor(factorType MFAFactorType) *Authentication {
+	methods := slices.Clone(a.Methods)
+	if method := factorType.AMR(); method != "" && !slices.Contains(methods, method) {
+		methods = append(methods, method)
+	}
+	if !slices.Contains(methods, AMRMultiFactor) {
+		methods = append(methods, AMRMultiFactor)
+	}
+	return NewAuthentication(methods...)
+}
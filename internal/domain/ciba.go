@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+const (
+	// GrantTypeCIBA is the grant type clients use to redeem a backchannel authentication request
+	GrantTypeCIBA = "urn:openid:params:grant-type:ciba"
+
+	// BackchannelTokenDeliveryModePoll has the client poll the token endpoint until the user answers
+	BackchannelTokenDeliveryModePoll = "poll"
+	// BackchannelTokenDeliveryModePing notifies the client once the user answers, after which it calls the token endpoint
+	BackchannelTokenDeliveryModePing = "ping"
+
+	BackchannelAuthStatusPending  = "pending"
+	BackchannelAuthStatusApproved = "approved"
+	BackchannelAuthStatusDenied   = "denied"
+
+	// BackchannelSlowDownIncrement is added to a request's polling interval each time the client polls too fast
+	BackchannelSlowDownIncrement = 5
+)
+
+// SupportedBackchannelTokenDeliveryModes lists the CIBA token delivery modes the service supports
+var SupportedBackchannelTokenDeliveryModes = []string{BackchannelTokenDeliveryModePoll, BackchannelTokenDeliveryModePing}
+
+// BackchannelAuthRequest is a Client-Initiated Backchannel Authentication request awaiting the user's answer
+type BackchannelAuthRequest struct {
+	AuthReqID               string     `json:"auth_req_id"`
+	ClientID                string     `json:"client_id"`
+	UserID                  string     `json:"-"`
+	Scopes                  []string   `json:"scopes"`
+	BindingMessage          string     `json:"binding_message,omitempty"`
+	ClientNotificationToken string     `json:"-"`
+	Status                  string     `json:"status"`
+	Interval                int        `json:"-"`
+	LastPolledAt            *time.Time `json:"-"`
+	CreatedAt               time.Time  `json:"created_at"`
+	ExpiresAt               time.Time  `json:"expires_at"`
+	// AMR and AuthTime record how and when the user signed in before approving the request
+	AMR      []string   `json:"-"`
+	AuthTime *time.Time `json:"-"`
+}
+
+// IsExpired reports whether the request can no longer be answered or redeemed
+func (r *BackchannelAuthRequest) IsExpired(now time.Time) bool {
+	return now.After(r.ExpiresAt)
+}
+
+// PolledTooSoon reports whether the client polled again before its interval elapsed
+func (r *BackchannelAuthRequest) PolledTooSoon(now time.Time) bool {
+	return r.LastPolledAt != nil && now.Sub(*r.LastPolledAt) < time.Duration(r.Interval)*time.Second
+}
+
+// BackchannelAuthResponse is returned by the backchannel authentication endpoint
+type BackchannelAuthResponse struct {
+	AuthReqID string `json:"auth_req_id"`
+	ExpiresIn int    `json:"expires_in"`
+	Interval  int    `json:"interval,omitempty"`
+}
+
+// UsesBackchannelPing reports whether the client is notified when the user answers a backchannel authentication request
+func (c *OAuth2Client) UsesBackchannelPing() bool {
+	return c.BackchannelTokenDeliveryMode == BackchannelTokenDeliveryModePing
+}
+
+// ValidateBackchannel checks the client's CIBA token delivery metadata
+func (c *OAuth2Client) ValidateBackchannel() error {
+	if c.BackchannelTokenDeliveryMode == "" {
+		if c.BackchannelClientNotificationEndpoint != "" {
+			return ErrInvalidClientMetadataReason("backchannel_client_notification_endpoint requires backchannel_token_delivery_mode")
+		}
+		return nil
+	}
+
+	if !contains(SupportedBackchannelTokenDeliveryModes, c.BackchannelTokenDeliveryMode) {
+		return ErrInvalidClientMetadataReason("unsupported backchannel_token_delivery_mode " + c.BackchannelTokenDeliveryMode)
+	}
+
+	if !c.UsesBackchannelPing() {
+		return nil
+	}
+
+	u, err := url.Parse(c.BackchannelClientNotificationEndpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return ErrInvalidClientMetadataReason("ping mode requires an https backchannel_client_notification_endpoint")
+	}
+
+	return nil
+}
+
+// BackchannelNotifier delivers ping notifications to a client's backchannel notification endpoint
+type BackchannelNotifier interface {
+	// Notify tells the client the user answered the request identified by authReqID
+	Notify(ctx context.Context, endpoint, clientNotificationToken, authReqID string) error
+}
+
+// BackchannelAuthRepository defines the interface for backchannel authentication request data access
+type BackchannelAuthRepository interface {
+	// Create stores a new backchannel authentication request
+	Create(ctx context.Context, req *BackchannelAuthRequest) error
+
+	// FindByID finds a backchannel authentication request by auth_req_id
+	FindByID(ctx context.Context, authReqID string) (*BackchannelAuthRequest, error)
+
+	// ListPendingByUser lists the unexpired requests awaiting the user's answer
+	ListPendingByUser(ctx context.Context, userID string) ([]*BackchannelAuthRequest, error)
+
+	// Update updates the status, interval, last poll time and approving sign-in of a request
+	Update(ctx context.Context, req *BackchannelAuthRequest) error
+
+	// ConsumeApproved deletes an approved request and returns it, so that it is redeemed at most once
+	ConsumeApproved(ctx context.Context, authReqID string) (*BackchannelAuthRequest, error)
+
+	// Delete deletes a backchannel authentication request
+	Delete(ctx context.Context, authReqID string) error
+}
+
+// CIBAService defines the interface for Client-Initiated Backchannel Authentication
+type CIBAService interface {
+	// Authenticate starts a backchannel authentication request for the user identified by loginHint
+	Authenticate(ctx context.Context, clientID, clientSecret, loginHint, bindingMessage, scope, clientNotificationToken string) (*BackchannelAuthResponse, error)
+
+	// ListPending lists the requests awaiting the user's answer
+	ListPending(ctx context.Context, userID string) ([]*BackchannelAuthRequest, error)
+
+	// Approve approves a pending request on behalf of the user
+	Approve(ctx context.Context, userID, authReqID string) error
+
+	// Deny denies a pending request on behalf of the user
+	Deny(ctx context.Context, userID, authReqID string) error
+
+	// ExchangeAuthReqID redeems an approved request for tokens
+	ExchangeAuthReqID(ctx context.Context, clientID, clientSecret, authReqID string) (*TokenPair, error)
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Consent records the scopes requiring consent a user agreed to grant a client
+type Consent struct {
+	UserID    ulid.ULID `json:"-"`
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Covers reports whether the user consented to the scope
+func (c *Consent) Covers(scope string) bool {
+	for _, granted := range c.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsentRequiredError is returned when a client requests scopes the user has not consented to
+type ConsentRequiredError struct {
+	*BusinessError
+	Scopes []string
+}
+
+// NewConsentRequiredError creates a ConsentRequiredError listing the scopes awaiting consent
+func NewConsentRequiredError(scopes []string) *ConsentRequiredError {
+	return &ConsentRequiredError{
+		BusinessError: ErrConsentRequired,
+		Scopes:        scopes,
+	}
+}
+
+// Unwrap lets errors.Is match ErrConsentRequired
+func (e *ConsentRequiredError) Unwrap() error {
+	return e.BusinessError
+}
+
+// ConsentRepository defines the interface for consent data access
+type ConsentRepository interface {
+	// Find retrieves the consent of the user for the client
+	Find(ctx context.Context, userID ulid.ULID, clientID string) (*Consent, error)
+
+	// Save creates or replaces the consent of the user for the client
+	Save(ctx context.Context, consent *Consent) error
+
+	// ListByUser lists the consents of a user
+	ListByUser(ctx context.Context, userID ulid.ULID) ([]*Consent, error)
+
+	// Delete removes the consent of the user for the client
+	Delete(ctx context.Context, userID ulid.ULID, clientID string) error
+}
+
+// ConsentService defines the interface for the consents users give clients
+type ConsentService interface {
+	// CheckConsent returns a *ConsentRequiredError when any of the scopes requires consent the
+	// user has not given the client
+	CheckConsent(ctx context.Context, userID, clientID string, scopes []string) error
+
+	// GrantConsent records the user's consent to the scopes for the client, keeping earlier ones
+	GrantConsent(ctx context.Context, userID, clientID string, scopes []string) (*Consent, error)
+
+	// ListConsents lists the consents of a user
+	ListConsents(ctx context.Context, userID string) ([]*Consent, error)
+
+	// RevokeConsent withdraws the user's consent for the client
+	RevokeConsent(ctx context.Context, userID, clientID string) error
+}
@@ -18,6 +18,22 @@ const (
 	ContextKeyRoles ContextKey = "roles"
 	// ContextKeyTOTPVerified is the key for the TOTP verification status in the context
 	ContextKeyTOTPVerified ContextKey = "totp_verified"
+	// ContextKeyScopes is the key for the granted scopes in the context
+	ContextKeyScopes ContextKey = "scopes"
+	// ContextKeyUserInfoClaims is the key for the individually requested userinfo claims in the context
+	ContextKeyUserInfoClaims ContextKey = "userinfo_claims"
+	// ContextKeyClaimsRequest is the key for the OIDC claims request parameter in the context
+	ContextKeyClaimsRequest ContextKey = "claims_request"
+	// ContextKeyClientID is the key for the client the token was issued to in the context
+	ContextKeyClientID ContextKey = "client_id"
+	// ContextKeyClientIP is the key for the IP address of the caller in the context
+	ContextKeyClientIP ContextKey = "client_ip"
+	// ContextKeyAuthentication is the key for how and when the user of the token signed in in the context
+	ContextKeyAuthentication ContextKey = "authentication"
+	// ContextKeyDeviceToken is the key for the trusted device token presented at login in the context
+	ContextKeyDeviceToken ContextKey = "device_token"
+	// ContextKeyUserAgent is the key for the user agent of the caller in the context
+	ContextKeyUserAgent ContextKey = "user_agent"
 )
 
 // WithSubject adds the subject (user ID) to the context
@@ -50,6 +66,46 @@ func WithTOTPVerified(ctx context.Context, verified bool) context.Context {
 	return context.WithValue(ctx, ContextKeyTOTPVerified, verified)
 }
 
+// WithScopes adds the granted scopes to the context
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, ContextKeyScopes, scopes)
+}
+
+// WithUserInfoClaims adds the individually requested userinfo claims to the context
+func WithUserInfoClaims(ctx context.Context, claims []string) context.Context {
+	return context.WithValue(ctx, ContextKeyUserInfoClaims, claims)
+}
+
+// WithClaimsRequest adds the OIDC claims request parameter to the context
+func WithClaimsRequest(ctx context.Context, claims *ClaimsRequest) context.Context {
+	return context.WithValue(ctx, ContextKeyClaimsRequest, claims)
+}
+
+// WithClientID adds the client the token was issued to to the context
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, ContextKeyClientID, clientID)
+}
+
+// WithClientIP adds the IP address of the caller to the context
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ContextKeyClientIP, ip)
+}
+
+// WithAuthentication adds how and when the user of the token signed in to the context
+func WithAuthentication(ctx context.Context, auth *Authentication) context.Context {
+	return context.WithValue(ctx, ContextKeyAuthentication, auth)
+}
+
+// WithDeviceToken adds the trusted device token presented at login to the context
+func WithDeviceToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, ContextKeyDeviceToken, token)
+}
+
+// WithUserAgent adds the user agent of the caller to the context
+func WithUserAgent(ctx context.Context, userAgent string) context.Context {
+	return context.WithValue(ctx, ContextKeyUserAgent, userAgent)
+}
+
 // GetSubject retrieves the subject (user ID) from the context
 func GetSubject(ctx context.Context) (string, bool) {
 	subject, ok := ctx.Value(ContextKeySubject).(string)
@@ -85,3 +141,51 @@ func GetTOTPVerified(ctx context.Context) (bool, bool) {
 	verified, ok := ctx.Value(ContextKeyTOTPVerified).(bool)
 	return verified, ok
 }
+
+// GetScopes retrieves the granted scopes from the context
+func GetScopes(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(ContextKeyScopes).([]string)
+	return scopes, ok
+}
+
+// GetUserInfoClaims retrieves the individually requested userinfo claims from the context
+func GetUserInfoClaims(ctx context.Context) ([]string, bool) {
+	claims, ok := ctx.Value(ContextKeyUserInfoClaims).([]string)
+	return claims, ok
+}
+
+// GetClaimsRequest retrieves the OIDC claims request parameter from the context
+func GetClaimsRequest(ctx context.Context) (*ClaimsRequest, bool) {
+	claims, ok := ctx.Value(ContextKeyClaimsRequest).(*ClaimsRequest)
+	return claims, ok
+}
+
+// GetClientID retrieves the client the token was issued to from the context
+func GetClientID(ctx context.Context) (string, bool) {
+	clientID, ok := ctx.Value(ContextKeyClientID).(string)
+	return clientID, ok
+}
+
+// GetClientIP retrieves the IP address of the caller from the context
+func GetClientIP(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(ContextKeyClientIP).(string)
+	return ip, ok
+}
+
+// GetAuthentication retrieves how and when the user of the token signed in from the context
+func GetAuthentication(ctx context.Context) (*Authentication, bool) {
+	auth, ok := ctx.Value(ContextKeyAuthentication).(*Authentication)
+	return auth, ok && auth != nil
+}
+
+// GetDeviceToken retrieves the trusted device token presented at login from the context
+func GetDeviceToken(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(ContextKeyDeviceToken).(string)
+	return token, ok && token != ""
+}
+
+// GetUserAgent retrieves the user agent of the caller from the context
+func GetUserAgent(ctx context.Context) (string, bool) {
+	userAgent, ok := ctx.Value(ContextKeyUserAgent).(string)
+	return userAgent, ok && userAgent != ""
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "context"
+
+// DirectoryIdentityProvider is the provider of the identities linking users to their directory
+// entry, keyed by DN. Only users linked to the directory sign in against it.
+const DirectoryIdentityProvider = "ldap"
+
+// DirectoryUser is a user as a directory describes them, with their attributes mapped to a name,
+// an email and roles
+type DirectoryUser struct {
+	// DN is the distinguished name the user was bound as
+	DN    string
+	Name  string
+	Email string
+	Roles []string
+}
+
+// CredentialVerifier checks passwords against a directory holding users who have no local password
+type CredentialVerifier interface {
+	// Verify checks the password of the user signing in with the login, returning
+	// ErrInvalidCredentials when the directory has no such user or rejects the password
+	Verify(ctx context.Context, login, password string) (*DirectoryUser, error)
+}
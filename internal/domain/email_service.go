@@ -1,6 +1,9 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // EmailService defines the interface for email operations
 type EmailService interface {
@@ -9,4 +12,32 @@ type EmailService interface {
 
 	// SendPasswordResetEmail sends a password reset email to the user
 	SendPasswordResetEmail(ctx context.Context, email, code string) error
+
+	// SendAccountLockedEmail tells the user their account was locked after too many failed attempts
+	SendAccountLockedEmail(ctx context.Context, email string, lockedUntil time.Time) error
+
+	// SendEmailChangeEmail sends the code confirming an email change to the new address
+	SendEmailChangeEmail(ctx context.Context, email, code string) error
+
+	// SendEmailChangeNoticeEmail tells the current address that a change to newEmail was requested
+	SendEmailChangeNoticeEmail(ctx context.Context, email, newEmail string) error
+
+	// SendPasswordlessCodeEmail sends a one-time sign-in code
+	SendPasswordlessCodeEmail(ctx context.Context, email, code string) error
+
+	// SendMagicLinkEmail sends a one-time sign-in link
+	SendMagicLinkEmail(ctx context.Context, email, link string) error
+
+	// SendMFACodeEmail sends a one-time code completing a sign-in with the email_otp factor
+	SendMFACodeEmail(ctx context.Context, email, code string) error
+
+	// SendBackupCodesLowEmail warns the user that only a few backup codes are left
+	SendBackupCodesLowEmail(ctx context.Context, email string, remaining int) error
+
+	// SendMFAResetEmail tells the user an administrator removed their second factors
+	SendMFAResetEmail(ctx context.Context, email string, resetAt time.Time) error
+
+	// SendNewSignInEmail tells the user about a login from a new device, with a link revoking it
+	// when one is configured
+	SendNewSignInEmail(ctx context.Context, email string, event *LoginEvent, revokeLink string) error
 }
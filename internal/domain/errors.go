@@ -125,6 +125,11 @@ var (
 	// ErrInvalidRedirectURI is returned when the redirect URI is invalid
 	ErrInvalidRedirectURI = NewBusinessError("U0031", "Invalid redirect URI")
 
+	// ErrInvalidRedirectURIReason is returned when a redirect URI violates the client's redirect policy
+	ErrInvalidRedirectURIReason = func(reason string) *BusinessError {
+		return NewBusinessError("U0031", fmt.Sprintf("Invalid redirect URI: %s", reason))
+	}
+
 	// ErrInvalidCodeChallengeMethod is returned when the code challenge method is invalid
 	ErrInvalidCodeChallengeMethod = NewBusinessError("U0032", "Invalid code challenge method")
 
@@ -180,6 +185,146 @@ var (
 
 	// ErrInvalidUserID is returned when the user ID is invalid
 	ErrInvalidUserID = NewBusinessError("U0057", "Invalid user ID")
+
+	// ErrScopeNotFound is returned when a scope does not exist
+	ErrScopeNotFound = errNotFound("Scope")
+
+	// ErrScopeAlreadyExists is returned when a scope with the same name already exists
+	ErrScopeAlreadyExists = ErrAlreadyExists("Scope")
+
+	// ErrUnsupportedClaim is returned when a scope maps to a claim the service cannot release
+	ErrUnsupportedClaim = NewBusinessError("U0058", "Unsupported claim")
+
+	// ErrInvalidClaimsRequest is returned when the claims request parameter is malformed
+	ErrInvalidClaimsRequest = NewBusinessError("U0059", "Invalid claims request")
+
+	// ErrInvalidSectorIdentifierReason is returned when no sector identifier can be derived for a pairwise client
+	ErrInvalidSectorIdentifierReason = func(reason string) *BusinessError {
+		return NewBusinessError("U0060", fmt.Sprintf("Invalid sector identifier: %s", reason))
+	}
+
+	// ErrInvalidClientMetadataReason is returned when the client's response signing or encryption metadata is invalid
+	ErrInvalidClientMetadataReason = func(reason string) *BusinessError {
+		return NewBusinessError("U0061", fmt.Sprintf("Invalid client metadata: %s", reason))
+	}
+
+	// ErrTokenEncryption is returned when a token cannot be encrypted to the client's keys
+	ErrTokenEncryption = NewInfraError("U0062", "Failed to encrypt token")
+
+	// ErrAuthorizationPending is returned while the user has not yet answered a backchannel authentication request
+	ErrAuthorizationPending = NewBusinessError("U0063", "Authorization pending")
+
+	// ErrSlowDown is returned when a client polls for a backchannel authentication result faster than its interval
+	ErrSlowDown = NewBusinessError("U0064", "Slow down")
+
+	// ErrAuthReqExpired is returned when a backchannel authentication request has expired
+	ErrAuthReqExpired = NewBusinessError("U0065", "Authentication request expired")
+
+	// ErrAccessDenied is returned when the user denied a backchannel authentication request
+	ErrAccessDenied = NewBusinessError("U0066", "Access denied")
+
+	// ErrInvalidAuthReqID is returned when the auth_req_id is unknown or belongs to another client or user
+	ErrInvalidAuthReqID = NewBusinessError("U0067", "Invalid auth_req_id")
+
+	// ErrUnknownUserID is returned when the login hint does not identify a user
+	ErrUnknownUserID = NewBusinessError("U0068", "Unknown user")
+
+	// ErrAccountLocked is returned when the account or client IP is locked after too many failed attempts
+	ErrAccountLocked = NewBusinessError("U0069", "Account temporarily locked")
+
+	// ErrTooManyAttempts is returned when a new attempt is made before the back-off delay has elapsed
+	ErrTooManyAttempts = NewBusinessError("U0070", "Too many attempts, try again later")
+
+	// ErrMFAAttemptsExceeded is returned when an MFA ticket has used up its verification attempts
+	ErrMFAAttemptsExceeded = NewBusinessError("U0071", "Too many MFA attempts, log in again")
+
+	// ErrPasswordPolicy is returned when a new password does not meet the password policy
+	ErrPasswordPolicy = NewBusinessError("U0072", "Password does not meet the password policy")
+
+	// ErrPasswordlessDisabled is returned when passwordless login, or the requested delivery method, is not enabled
+	ErrPasswordlessDisabled = NewBusinessError("U0073", "Passwordless login is not available")
+
+	// ErrInvalidWebAuthnChallenge is returned when a WebAuthn response answers an unknown, used or expired challenge
+	ErrInvalidWebAuthnChallenge = NewBusinessError("U0074", "Invalid or expired WebAuthn challenge")
+
+	// ErrWebAuthnVerificationFailed is returned when a passkey response does not verify
+	ErrWebAuthnVerificationFailed = NewBusinessError("U0075", "Passkey verification failed")
+
+	// ErrWebAuthnCredentialNotFound is returned when a passkey does not exist or belongs to another user
+	ErrWebAuthnCredentialNotFound = NewBusinessError("U0076", "Passkey not found")
+
+	// ErrWebAuthnCredentialExists is returned when an authenticator registers a credential that is already registered
+	ErrWebAuthnCredentialExists = NewBusinessError("U0077", "Passkey already registered")
+
+	// ErrMFAFactorNotFound is returned when an MFA factor does not exist or belongs to another user
+	ErrMFAFactorNotFound = NewBusinessError("U0078", "MFA factor not found")
+
+	// ErrMFAFactorExists is returned when enrolling a factor the user can only have once
+	ErrMFAFactorExists = NewBusinessError("U0079", "MFA factor already enrolled")
+
+	// ErrMFAFactorNotSupported is returned when an MFA factor cannot be used for the requested operation
+	ErrMFAFactorNotSupported = NewBusinessError("U0080", "MFA factor does not support this operation")
+
+	// ErrTOTPEnrollmentNotFound is returned when confirming an authenticator app that was never enrolled or whose enrolment expired
+	ErrTOTPEnrollmentNotFound = NewBusinessError("U0081", "No pending TOTP enrolment, or it has expired")
+
+	// ErrSecretEncryption is returned when a secret kept at rest cannot be encrypted or decrypted
+	ErrSecretEncryption = NewInfraError("U0082", "Failed to encrypt or decrypt secret")
+
+	// ErrTOTPCodeReused is returned when a TOTP code, or an earlier one, was already accepted for the same authenticator app
+	ErrTOTPCodeReused = NewBusinessError("U0083", "TOTP code has already been used")
+
+	// ErrNoMFAFactors is returned when resetting the MFA of a user who has no second factor
+	ErrNoMFAFactors = NewBusinessError("U0084", "User has no MFA factor enrolled")
+
+	// ErrMFAEnrollmentRequired is returned when the MFA policy requires a second factor of a user who has not enrolled one
+	ErrMFAEnrollmentRequired = NewBusinessError("U0085", "MFA enrolment required")
+
+	// ErrInsufficientUserAuthentication is returned when a route needs a stronger or more recent sign-in than the token records
+	ErrInsufficientUserAuthentication = NewBusinessError("U0086", "A stronger or more recent authentication is required")
+
+	// ErrTrustedDeviceNotFound is returned when a trusted device does not exist or belongs to another user
+	ErrTrustedDeviceNotFound = NewBusinessError("U0087", "Trusted device not found")
+
+	// ErrDeviceTrustNotAllowed is returned when device trust is disabled, or for the roles of the user
+	ErrDeviceTrustNotAllowed = NewBusinessError("U0088", "Devices cannot be trusted for this account")
+
+	// ErrInvalidSignInRevocation is returned when the link revoking a sign-in is unknown, used or expired
+	ErrInvalidSignInRevocation = NewBusinessError("U0089", "Invalid or expired sign-in revocation link")
+
+	// ErrInvalidPhone is returned when a phone number is not in international format
+	ErrInvalidPhone = NewBusinessError("U0090", "Invalid phone number")
+
+	// ErrPhoneNotVerified is returned when the phone number of the user is not verified
+	ErrPhoneNotVerified = NewBusinessError("U0091", "Phone number not verified")
+
+	// ErrSMSSendFailed is returned when a text message or voice call cannot be delivered
+	ErrSMSSendFailed = NewInfraError("U0092", "Failed to send SMS")
+
+	// ErrIdentityProviderNotFound is returned when no upstream identity provider has the name
+	ErrIdentityProviderNotFound = NewBusinessError("U0093", "Identity provider not found")
+
+	// ErrFederatedLoginFailed is returned when a sign-in at an upstream identity provider cannot
+	// be completed, such as for an unknown state or a rejected ID token
+	ErrFederatedLoginFailed = NewBusinessError("U0094", "Sign-in with the identity provider failed")
+
+	// ErrUpstreamEmailNotVerified is returned when the identity provider does not vouch for the email of the user
+	ErrUpstreamEmailNotVerified = NewBusinessError("U0095", "The identity provider did not verify the email")
+
+	// ErrFederatedIdentityNotFound is returned when no user is linked to an upstream identity
+	ErrFederatedIdentityNotFound = NewBusinessError("U0096", "Federated identity not found")
+
+	// ErrDirectoryUnavailable is returned when the LDAP directory users sign in against cannot be reached
+	ErrDirectoryUnavailable = NewInfraError("U0097", "Directory unavailable")
+
+	// ErrConsentRequired is returned when a client requests scopes the user has not consented to
+	ErrConsentRequired = NewBusinessError("U0098", "The user has not consented to the requested scopes")
+
+	// ErrConsentNotFound is returned when the user has not consented to any scope for the client
+	ErrConsentNotFound = NewBusinessError("U0099", "Consent not found")
+
+	// ErrPairwiseSubjectNotFound is returned when a pairwise subject identifier was never issued
+	ErrPairwiseSubjectNotFound = NewBusinessError("U0100", "Pairwise subject not found")
 )
 
 func (e *BusinessError) GetCode() string {
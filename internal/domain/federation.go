@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// FederatedIdentity links a user to their account at an upstream identity provider
+type FederatedIdentity struct {
+	ID       ulid.ULID `json:"id"`
+	UserID   ulid.ULID `json:"-"`
+	Provider string    `json:"provider"`
+	// Subject is the sub claim the provider identifies the user with, stable across email changes
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// NewFederatedIdentity creates a link between a user and their account at a provider
+func NewFederatedIdentity(userID ulid.ULID, provider, subject, email string) *FederatedIdentity {
+	return &FederatedIdentity{
+		ID:        ulid.Make(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+}
+
+// FederatedLoginState is a sign-in in progress at an upstream provider. Its ID travels as the
+// OAuth2 state parameter; the nonce and PKCE code verifier never leave the server.
+type FederatedLoginState struct {
+	ID           ulid.ULID
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// IsExpired reports whether the provider took too long to send the user back
+func (s *FederatedLoginState) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// UpstreamClaims are the claims of a verified ID token, mapped with the provider's claim mapping
+type UpstreamClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// IdentityProvider is an upstream OpenID Connect provider users can sign in with
+type IdentityProvider interface {
+	// Name is the name the provider is configured and routed under
+	Name() string
+	// AuthorizationURL returns the URL of the provider's authorization endpoint the user is sent
+	// to, carrying the state, the nonce and the S256 PKCE code challenge
+	AuthorizationURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems an authorization code with its PKCE code verifier and returns the claims
+	// of the ID token, once its signature, issuer, audience, expiry and nonce are verified
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*UpstreamClaims, error)
+}
+
+// FederatedIdentityRepository defines the interface for the links to upstream identities
+type FederatedIdentityRepository interface {
+	// Create stores a new link
+	Create(ctx context.Context, identity *FederatedIdentity) error
+	// FindByProviderSubject retrieves the link of a provider's user
+	FindByProviderSubject(ctx context.Context, provider, subject string) (*FederatedIdentity, error)
+	// ListByUser lists the links of a user, oldest first
+	ListByUser(ctx context.Context, userID ulid.ULID) ([]*FederatedIdentity, error)
+	// Touch records a sign-in through a link, along with the email the provider now reports
+	Touch(ctx context.Context, id ulid.ULID, email string, at time.Time) error
+}
+
+// FederatedLoginStateRepository defines the interface for the sign-ins in progress upstream
+type FederatedLoginStateRepository interface {
+	// Create stores a new state
+	Create(ctx context.Context, state *FederatedLoginState) error
+	// Consume retrieves and deletes a state, so that a callback can be handled only once
+	Consume(ctx context.Context, id ulid.ULID) (*FederatedLoginState, error)
+}
+
+// FederationService defines the interface for signing users in with upstream identity providers
+type FederationService interface {
+	// Providers lists the names of the configured providers
+	Providers() []string
+	// BeginLogin starts a sign-in at the provider, returning the state it was started with and
+	// the URL to send the user to
+	BeginLogin(ctx context.Context, provider string) (state, redirectURL string, err error)
+	// FinishLogin handles the user coming back from the provider with an authorization code,
+	// linking or provisioning their account, and returns a token pair or MFA ticket
+	FinishLogin(ctx context.Context, provider, state, code string) (interface{}, error)
+	// ListIdentities lists the upstream identities linked to a user
+	ListIdentities(ctx context.Context, userID string) ([]*FederatedIdentity, error)
+}
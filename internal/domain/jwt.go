@@ -6,7 +6,9 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,11 +37,105 @@ type RefreshTokenRequest struct {
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token,omitempty"`
+	// DeviceToken lets later logins from the device skip MFA until DeviceExpiresAt, when the user
+	// chose to trust it
+	DeviceToken     string     `json:"device_token,omitempty"`
+	DeviceExpiresAt *time.Time `json:"device_expires_at,omitempty"`
 }
 
+// TokenParams carries the optional grant details recorded in a token pair
+type TokenParams struct {
+	// ClientID is the OAuth2 client the tokens are issued to
+	ClientID string
+	// Scopes are the granted scopes
+	Scopes []string
+	// UserInfoClaims are the claims individually requested for the userinfo endpoint
+	UserInfoClaims []string
+	// IDTokenClaims are the user claims released in the ID token; an ID token is only issued when set
+	IDTokenClaims map[string]interface{}
+	// Subject overrides the subject of every token of the pair, e.g. with a pairwise identifier
+	Subject string
+	// Authentication is how and when the user signed in, recorded in the amr, acr and auth_time claims
+	Authentication *Authentication
+}
+
+// Tokens are told apart by their typ header, since they are all signed with the same key
+const (
+	// TokenTypeAccess is the typ header of access tokens (RFC 9068)
+	TokenTypeAccess = "at+jwt"
+	// TokenTypeRefresh is the typ header of refresh tokens
+	TokenTypeRefresh = "refresh+jwt"
+	// TokenTypeID is the typ header of ID tokens
+	TokenTypeID = "id_token+jwt"
+	// TokenTypeUserInfo is the typ header of signed userinfo responses
+	TokenTypeUserInfo = "userinfo+jwt"
+)
+
 type Claims struct {
 	*jwt.RegisteredClaims
-	Roles []string `json:"roles"`
+	Roles          []string         `json:"roles,omitempty"`
+	Scope          string           `json:"scope,omitempty"`
+	ClientID       string           `json:"client_id,omitempty"`
+	UserInfoClaims []string         `json:"userinfo_claims,omitempty"`
+	AMR            []string         `json:"amr,omitempty"`
+	ACR            string           `json:"acr,omitempty"`
+	AuthTime       *jwt.NumericDate `json:"auth_time,omitempty"`
+	// Extra holds additional claims, such as the user claims of an ID token
+	Extra map[string]interface{} `json:"-"`
+	// Type is the typ header of the token, such as TokenTypeAccess; empty for plain JWTs
+	Type string `json:"-"`
+}
+
+// IsAccessToken reports whether the token was issued as an access token
+func (c *Claims) IsAccessToken() bool {
+	return c.Type == TokenTypeAccess
+}
+
+// IsRefreshToken reports whether the token was issued as a refresh token
+func (c *Claims) IsRefreshToken() bool {
+	return c.Type == TokenTypeRefresh
+}
+
+// MarshalJSON merges the extra claims into the serialized claim set
+func (c Claims) MarshalJSON() ([]byte, error) {
+	type claims Claims
+	data, err := json.Marshal(claims(c))
+	if err != nil || len(c.Extra) == 0 {
+		return data, err
+	}
+
+	merged := make(map[string]interface{}, len(c.Extra))
+	for name, value := range c.Extra {
+		merged[name] = value
+	}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	return json.Marshal(merged)
+}
+
+// Scopes returns the granted scopes recorded in the token
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Authentication returns how and when the user signed in, or nil for tokens that do not record it
+func (c *Claims) Authentication() *Authentication {
+	if c.AuthTime == nil {
+		return nil
+	}
+	return &Authentication{Methods: c.AMR, Time: c.AuthTime.Time}
+}
+
+// SetAuthentication records how and when the user signed in
+func (c *Claims) SetAuthentication(auth *Authentication) {
+	if auth == nil {
+		return
+	}
+	c.AMR = auth.Methods
+	c.ACR = auth.ACR()
+	c.AuthTime = jwt.NewNumericDate(auth.Time)
 }
 
 // Valid implements the jwt.Claims interface
@@ -178,6 +274,8 @@ type JWTService interface {
 	ValidateToken(token string) (*Claims, error)
 	GetJWKS(ctx context.Context) (map[string]interface{}, error)
 	GenerateTokenPair(userID ulid.ULID, roles []string) (*TokenPair, error)
+	GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *TokenParams) (*TokenPair, error)
+	SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error)
 	GetPublicKey() *rsa.PublicKey
 	RotateKeys() error
 	BlacklistToken(tokenID string, expiresAt time.Time) error
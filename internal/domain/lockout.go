@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// LoginAttempts tracks the recent failed authentication attempts for a user or a client IP
+type LoginAttempts struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// IsLocked reports whether the key is locked out at the given time
+func (a *LoginAttempts) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// UserAttemptsKey returns the login attempts key for a user
+func UserAttemptsKey(userID string) string {
+	return "user:" + userID
+}
+
+// UnknownAccountAttemptsKey returns the login attempts key for a login that matches no account
+func UnknownAccountAttemptsKey(login string) string {
+	return "unknown:" + strings.ToLower(login)
+}
+
+// IPAttemptsKey returns the login attempts key for a client IP
+func IPAttemptsKey(ip string) string {
+	return "ip:" + ip
+}
+
+// PasswordlessAttemptsKey returns the key counting passwordless login requests for an email address
+func PasswordlessAttemptsKey(email string) string {
+	return "passwordless:" + strings.ToLower(email)
+}
+
+// MFAChallengeKey returns the key counting the codes sent for an MFA ticket
+func MFAChallengeKey(ticketID string) string {
+	return "mfa_challenge:" + ticketID
+}
+
+// SMSSendKey returns the key counting the text messages and voice calls sent to a phone number
+func SMSSendKey(phone string) string {
+	return "sms:" + phone
+}
+
+// SMSUserSendKey returns the key counting the text messages and voice calls sent for a user, to any number
+func SMSUserSendKey(userID string) string {
+	return "sms_user:" + userID
+}
+
+// SMSIPSendKey returns the key counting the text messages and voice calls requested from a client IP
+func SMSIPSendKey(ip string) string {
+	return "sms_ip:" + ip
+}
+
+// PhoneVerificationKey returns the key counting the attempts to confirm a user's phone number
+func PhoneVerificationKey(userID string) string {
+	return "phone_verification:" + userID
+}
+
+// LoginAttemptRepository defines the interface for failed login attempt storage
+type LoginAttemptRepository interface {
+	// Get retrieves the attempts for a key, returning an empty record when there are none
+	Get(ctx context.Context, key string) (*LoginAttempts, error)
+	// RecordFailure counts a failure at the given time, restarting the count when the
+	// previous failure happened before resetBefore, and returns the updated record
+	RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*LoginAttempts, error)
+	// Lock locks the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset clears the attempts and any lock for a key
+	Reset(ctx context.Context, key string) error
+}
+
+// LockoutService defines the interface for brute-force protection on password and MFA checks
+type LockoutService interface {
+	// Check returns an error when the user or client IP may not attempt to authenticate right now
+	Check(ctx context.Context, userID, ip string) error
+	// RecordFailure records a failed attempt and locks the user or client IP once their threshold is reached;
+	// user may be nil when the login did not match an account
+	RecordFailure(ctx context.Context, user *User, ip string) error
+	// CheckUnknown returns an error when a login that matches no account may not be tried right now,
+	// answering like Check does for a user so that lockouts do not reveal which accounts exist
+	CheckUnknown(ctx context.Context, login string) error
+	// RecordUnknownFailure records a failed attempt at a login that matches no account, locking the
+	// login and the client IP once their threshold is reached
+	RecordUnknownFailure(ctx context.Context, login, ip string) error
+	// RecordSuccess clears the failed attempts of a user after a successful authentication
+	RecordSuccess(ctx context.Context, userID string) error
+	// Unlock clears the lockout of a user
+	Unlock(ctx context.Context, userID ulid.ULID) error
+	// Throttle counts a request against key and returns ErrTooManyAttempts once more than limit
+	// requests were made within window
+	Throttle(ctx context.Context, key string, limit int, window time.Duration) error
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// LoginEvent records a successful login and the device it came from. The device is fingerprinted
+// by the network prefix of the client IP and the family of its user agent, such as
+// "Firefox on Linux", so a new browser version or address in the same network is not a new device.
+type LoginEvent struct {
+	ID        ulid.ULID `json:"id"`
+	UserID    ulid.ULID `json:"-"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	IPPrefix  string    `json:"ip_prefix,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Device    string    `json:"device"`
+	Methods   []string  `json:"amr,omitempty"`
+	// NewDevice marks the first login from the device, which the user was told about by email
+	NewDevice bool `json:"new_device"`
+	// RevokeTokenHash is the hash of the token of the link in that email, until it is used
+	RevokeTokenHash string     `json:"-"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// LoginHistoryRepository defines the interface for storing the login history of users
+type LoginHistoryRepository interface {
+	// Create records a login
+	Create(ctx context.Context, event *LoginEvent) error
+	// FindByID retrieves a login
+	FindByID(ctx context.Context, id ulid.ULID) (*LoginEvent, error)
+	// ListByUser lists the latest logins of a user, newest first
+	ListByUser(ctx context.Context, userID ulid.ULID, limit int) ([]*LoginEvent, error)
+	// IsNewDevice reports whether a user who signed in before never did from the device
+	IsNewDevice(ctx context.Context, userID ulid.ULID, ipPrefix, device string) (bool, error)
+	// Revoke marks a login as revoked and forgets the token of its revocation link
+	Revoke(ctx context.Context, id ulid.ULID, revokedAt time.Time) error
+}
+
+// LoginHistoryService records the logins of users and tells them about logins from new devices
+type LoginHistoryService interface {
+	// IsNewDevice reports whether the login in the context comes from a device the user never
+	// signed in from. A user's first login is not from a new device.
+	IsNewDevice(ctx context.Context, user *User) bool
+	// Record adds the login in the context to the user's history and, when it comes from a new
+	// device, emails the user a link revoking it. Failures are logged, as the login succeeded.
+	Record(ctx context.Context, user *User, auth *Authentication)
+	// List lists the latest logins of a user, newest first
+	List(ctx context.Context, userID string) ([]*LoginEvent, error)
+	// RevokeSignIn redeems the token of a new sign-in email: the sessions and trusted devices of
+	// the user are revoked so that whoever signed in has to do so again
+	RevokeSignIn(ctx context.Context, token string) error
+}
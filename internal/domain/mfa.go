@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// MFAFactorType identifies how a second factor is verified
+type MFAFactorType string
+
+const (
+	// MFAFactorTOTP is an authenticator app generating time-based codes
+	MFAFactorTOTP MFAFactorType = "totp"
+	// MFAFactorWebAuthn is a passkey or security key
+	MFAFactorWebAuthn MFAFactorType = "webauthn"
+	// MFAFactorEmailOTP is a one-time code sent to the user's verified email
+	MFAFactorEmailOTP MFAFactorType = "email_otp"
+	// MFAFactorSMSOTP is a one-time code sent to the user's phone
+	MFAFactorSMSOTP MFAFactorType = "sms_otp"
+	// MFAFactorRecovery is the set of single-use backup codes
+	MFAFactorRecovery MFAFactorType = "recovery"
+)
+
+// AMR returns the authentication method reference recorded when the factor is used
+func (t MFAFactorType) AMR() string {
+	switch t {
+	case MFAFactorTOTP, MFAFactorEmailOTP, MFAFactorRecovery:
+		return AMROTP
+	case MFAFactorSMSOTP:
+		return AMRSMS
+	case MFAFactorWebAuthn:
+		return AMRHardwareKey
+	default:
+		return ""
+	}
+}
+
+// MFAFactor is a second factor enrolled by a user
+type MFAFactor struct {
+	ID         ulid.ULID     `json:"id"`
+	UserID     ulid.ULID     `json:"-"`
+	Type       MFAFactorType `json:"type"`
+	Label      string        `json:"label"`
+	CreatedAt  time.Time     `json:"created_at"`
+	LastUsedAt *time.Time    `json:"last_used_at,omitempty"`
+}
+
+// NewMFAFactor creates a factor of the given type for a user
+func NewMFAFactor(userID ulid.ULID, factorType MFAFactorType, label string) *MFAFactor {
+	return &MFAFactor{
+		ID:        ulid.Make(),
+		UserID:    userID,
+		Type:      factorType,
+		Label:     label,
+		CreatedAt: time.Now(),
+	}
+}
+
+// MFAFactorTypes lists the distinct types of the given factors in the order they first appear
+func MFAFactorTypes(factors []*MFAFactor) []MFAFactorType {
+	types := make([]MFAFactorType, 0, len(factors))
+	seen := make(map[MFAFactorType]bool, len(factors))
+	for _, factor := range factors {
+		if !seen[factor.Type] {
+			seen[factor.Type] = true
+			types = append(types, factor.Type)
+		}
+	}
+	return types
+}
+
+// MFAFactorRepository defines the interface for the registry of enrolled factors. Passkeys are
+// kept in the WebAuthn credential store and are not part of it.
+type MFAFactorRepository interface {
+	// Create registers a new factor
+	Create(ctx context.Context, factor *MFAFactor) error
+	// ListByUser lists the factors of a user, oldest first
+	ListByUser(ctx context.Context, userID ulid.ULID) ([]*MFAFactor, error)
+	// Touch records a successful use of a factor
+	Touch(ctx context.Context, id ulid.ULID, usedAt time.Time) error
+	// Rename changes the label of one of the user's factors
+	Rename(ctx context.Context, userID, id ulid.ULID, label string) error
+	// Delete removes one of the user's factors along with its secrets
+	Delete(ctx context.Context, userID, id ulid.ULID) error
+}
+
+// MFAResetMethod is how an administrator verified the identity of a user before resetting their MFA
+type MFAResetMethod string
+
+const (
+	// MFAResetIDDocument is a check of an official identity document
+	MFAResetIDDocument MFAResetMethod = "id_document"
+	// MFAResetInPerson is a check made with the user in person
+	MFAResetInPerson MFAResetMethod = "in_person"
+	// MFAResetVideoCall is a check made over a video call
+	MFAResetVideoCall MFAResetMethod = "video_call"
+)
+
+// MFAReset records an administrator removing every second factor of a user who lost access to them
+type MFAReset struct {
+	ID                 ulid.ULID       `json:"id"`
+	UserID             ulid.ULID       `json:"user_id"`
+	AdminID            ulid.ULID       `json:"admin_id"`
+	VerificationMethod MFAResetMethod  `json:"verification_method"`
+	Reason             string          `json:"reason"`
+	RemovedFactors     []MFAFactorType `json:"removed_factors"`
+	ClientIP           string          `json:"client_ip,omitempty"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+// MFAResetRepository defines the interface for resetting the MFA of a user and its audit trail
+type MFAResetRepository interface {
+	// Reset removes every factor, passkey, pending enrolment and trusted device of the user and
+	// records the reset
+	Reset(ctx context.Context, reset *MFAReset) error
+	// ListByUser lists the MFA resets of a user, newest first
+	ListByUser(ctx context.Context, userID ulid.ULID) ([]*MFAReset, error)
+}
+
+// MFARequirement is what the MFA policy asks of a user signing in
+type MFARequirement struct {
+	// Reasons lists the rules requiring a second factor, such as "role:admin", "client:<id>" or
+	// "scope:<name>"; without any MFA is up to the user
+	Reasons []string
+	// GraceEndsAt is when a user the policy applies to can no longer sign in without a factor
+	GraceEndsAt time.Time
+}
+
+// Required reports whether any rule requires a second factor
+func (r *MFARequirement) Required() bool {
+	return len(r.Reasons) > 0
+}
+
+// InGracePeriod reports whether a user without a factor may still sign in at the given time
+func (r *MFARequirement) InGracePeriod(now time.Time) bool {
+	return now.Before(r.GraceEndsAt)
+}
+
+// MFAPolicyService decides which users must sign in with a second factor, by role, by the OAuth2
+// client they sign in to or by the scopes it asks for
+type MFAPolicyService interface {
+	// Evaluate returns what the policy asks of the user signing in; the client and scopes are
+	// optional, as first-party logins have neither
+	Evaluate(user *User, clientID string, scopes []string) *MFARequirement
+	// CheckAuthorization rejects authorizing the client for the scopes when the policy requires a
+	// second factor the user has not enrolled and their grace period is over
+	CheckAuthorization(ctx context.Context, userID, clientID string, scopes []string) error
+}
+
+// MFAService manages the second factors of a user and verifies them during an MFA challenge
+type MFAService interface {
+	// ListFactors lists every factor a user has enrolled, passkeys included
+	ListFactors(ctx context.Context, userID string) ([]*MFAFactor, error)
+	// RenameFactor changes the label of one of the user's factors
+	RenameFactor(ctx context.Context, userID, factorID, label string) error
+	// DeleteFactor removes one of the user's factors
+	DeleteFactor(ctx context.Context, userID, factorID string) error
+	// EnrollEmailOTP registers the user's verified email as a factor
+	EnrollEmailOTP(ctx context.Context, userID string) (*MFAFactor, error)
+	// EnrollSMSOTP registers the user's verified phone as a factor
+	EnrollSMSOTP(ctx context.Context, userID string) (*MFAFactor, error)
+	// SendChallenge delivers a one-time code for factors that need one sent, such as email_otp
+	// and sms_otp; the channel picks a text message or a voice call for sms_otp
+	SendChallenge(ctx context.Context, user *User, factorID string, channel SMSChannel) error
+	// SendEmailCode emails a single-use code to the user without an email_otp factor, for a
+	// login the risk checks ask to confirm
+	SendEmailCode(ctx context.Context, user *User) error
+	// VerifyEmailCode redeems the code last emailed to the user
+	VerifyEmailCode(ctx context.Context, user *User, code string) error
+	// VerifyFactor checks a code against one of the user's factors. Without a factor ID the
+	// code is checked against the user's authenticator apps, then their backup codes. It returns
+	// the type of the factor that accepted the code.
+	VerifyFactor(ctx context.Context, user *User, factorID, code string) (MFAFactorType, error)
+	// ResetMFA removes every second factor of a user on behalf of an administrator who verified
+	// the user's identity
+	ResetMFA(ctx context.Context, adminID, userID string, method MFAResetMethod, reason string) (*MFAReset, error)
+	// ListResets lists the MFA resets of a user, newest first
+	ListResets(ctx context.Context, userID string) ([]*MFAReset, error)
+}
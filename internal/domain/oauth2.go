@@ -7,13 +7,33 @@ import (
 
 // OAuth2Client represents a registered OAuth2 client
 type OAuth2Client struct {
-	ID           string    `json:"id"`
-	Secret       string    `json:"secret"`
-	RedirectURIs []string  `json:"redirect_uris"`
-	GrantTypes   []string  `json:"grant_types"`
-	Scopes       []string  `json:"scopes"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                                    string    `json:"id"`
+	Secret                                string    `json:"secret"`
+	ApplicationType                       string    `json:"application_type"`
+	RedirectURIs                          []string  `json:"redirect_uris"`
+	RedirectURIPatterns                   []string  `json:"redirect_uri_patterns,omitempty"`
+	GrantTypes                            []string  `json:"grant_types"`
+	Scopes                                []string  `json:"scopes"`
+	SubjectType                           string    `json:"subject_type"`
+	SectorIdentifierURI                   string    `json:"sector_identifier_uri,omitempty"`
+	JWKSURI                               string    `json:"jwks_uri,omitempty"`
+	UserInfoSignedResponseAlg             string    `json:"userinfo_signed_response_alg,omitempty"`
+	IDTokenEncryptedResponseAlg           string    `json:"id_token_encrypted_response_alg,omitempty"`
+	IDTokenEncryptedResponseEnc           string    `json:"id_token_encrypted_response_enc,omitempty"`
+	BackchannelTokenDeliveryMode          string    `json:"backchannel_token_delivery_mode,omitempty"`
+	BackchannelClientNotificationEndpoint string    `json:"backchannel_client_notification_endpoint,omitempty"`
+	CreatedAt                             time.Time `json:"created_at"`
+	UpdatedAt                             time.Time `json:"updated_at"`
+}
+
+// AllowsGrantType reports whether the client is registered for the grant type
+func (c *OAuth2Client) AllowsGrantType(grantType string) bool {
+	for _, g := range c.GrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
 }
 
 // AuthorizationCode represents an OAuth2 authorization code
@@ -27,6 +47,10 @@ type AuthorizationCode struct {
 	CodeVerifier        string    `json:"code_verifier"`
 	CodeChallenge       string    `json:"code_challenge"`
 	CodeChallengeMethod string    `json:"code_challenge_method"`
+	ClaimsRequest       string    `json:"claims_request,omitempty"`
+	// AMR and AuthTime record how and when the user signed in before authorizing the client
+	AMR      []string   `json:"amr,omitempty"`
+	AuthTime *time.Time `json:"auth_time,omitempty"`
 }
 
 // OAuth2Service defines the interface for OAuth2 operations
@@ -37,8 +61,18 @@ type OAuth2Service interface {
 	// GenerateAuthorizationCode generates a new authorization code for the client and user
 	GenerateAuthorizationCode(ctx context.Context, clientID, userID string, scopes []string, codeChallenge, codeChallengeMethod string) (string, error)
 
-	// ValidateAuthorizationCode validates an authorization code and returns the associated data
-	ValidateAuthorizationCode(ctx context.Context, code string) (*OAuth2Client, string, []string, error)
+	// GetClient retrieves a registered client by ID
+	GetClient(ctx context.Context, clientID string) (*OAuth2Client, error)
+
+	// AuthenticateClient checks the secret of a confidential client, or that a public client sent none,
+	// and returns the client
+	AuthenticateClient(ctx context.Context, clientID, clientSecret string) (*OAuth2Client, error)
+
+	// ValidatePKCE checks the code verifier against the code challenge of an authorization code
+	ValidatePKCE(ctx context.Context, codeVerifier, codeChallenge, codeChallengeMethod string) error
+
+	// ValidateAuthorizationCode validates an authorization code and returns the client and the consumed code
+	ValidateAuthorizationCode(ctx context.Context, code string) (*OAuth2Client, *AuthorizationCode, error)
 }
 
 // OAuth2Repository defines the interface for OAuth2 data access
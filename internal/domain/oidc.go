@@ -4,28 +4,60 @@ import (
 	"context"
 )
 
-type UserInfo struct {
-	Sub           string   `json:"sub"`
-	Name          string   `json:"name"`
-	Email         string   `json:"email"`
-	EmailVerified bool     `json:"email_verified"`
-	AMR           []string `json:"amr"`
-}
+// UserInfo holds the claims released about a user, keyed by claim name
+type UserInfo map[string]interface{}
 
 // OIDCService defines the interface for OpenID Connect operations
 type OIDCService interface {
-	// GetUserInfo retrieves user information for the given user ID
-	GetUserInfo(ctx context.Context, userID string) (*UserInfo, error)
+	// GetUserInfo retrieves the claims the granted scopes in the context release for the given user ID
+	GetUserInfo(ctx context.Context, userID string) (UserInfo, error)
+
+	// SignUserInfo returns the userinfo as a signed JWT when the client in the context registered
+	// userinfo_signed_response_alg, or an empty string when it expects plain JSON
+	SignUserInfo(ctx context.Context, userInfo UserInfo) (string, error)
 
 	// GetOpenIDConfiguration retrieves the OpenID Connect configuration
 	GetOpenIDConfiguration(ctx context.Context) (map[string]interface{}, error)
 
-	// ExchangeCode exchanges an authorization code for tokens
-	ExchangeCode(ctx context.Context, code string, codeVerifier string) (*TokenPair, error)
+	// ExchangeCode authenticates the client and exchanges an authorization code issued to it for tokens,
+	// checking the PKCE code verifier
+	ExchangeCode(ctx context.Context, clientID, clientSecret, code, codeVerifier string) (*TokenPair, error)
 
-	// RefreshToken refreshes an access token using a refresh token
-	RefreshToken(ctx context.Context, refreshToken string) (*TokenPair, error)
+	// RefreshToken refreshes an access token using a refresh token. Tokens issued to a client need its
+	// credentials; first-party tokens are refreshed without a client
+	RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*TokenPair, error)
 
 	// Authorize handles the authorization request and returns an authorization code
 	Authorize(ctx context.Context, clientID, redirectURI, state, scope string) (string, error)
+
+	// IntrospectToken authenticates the client and describes a token issued to it
+	IntrospectToken(ctx context.Context, clientID, clientSecret, token string) (*TokenIntrospection, error)
+}
+
+// TokenIssuer issues the tokens of an OpenID Connect grant
+type TokenIssuer interface {
+	// IssueTokens issues the token pair granting the scopes to the client, with an ID token when openid
+	// is granted, carrying the subject the client knows the user by
+	IssueTokens(ctx context.Context, client *OAuth2Client, user *User, scopes []string, claimsRequest *ClaimsRequest, auth *Authentication) (*TokenPair, error)
+}
+
+const (
+	// TokenTypeHintAccessToken is the token_type of introspected access tokens
+	TokenTypeHintAccessToken = "access_token"
+	// TokenTypeHintRefreshToken is the token_type of introspected refresh tokens
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// TokenIntrospection is the introspection response of RFC 7662. Inactive tokens only report Active.
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	ACR       string `json:"acr,omitempty"`
+	AuthTime  int64  `json:"auth_time,omitempty"`
 }
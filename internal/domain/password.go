@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// PasswordHasher defines the interface for hashing and verifying user passwords
+type PasswordHasher interface {
+	// Hash hashes a password with the current algorithm and parameters, in PHC string format
+	Hash(password string) (string, error)
+	// Verify reports whether the password matches the hash; hashes in older formats are still accepted
+	Verify(password, hash string) (bool, error)
+	// NeedsRehash reports whether the hash was made with an outdated algorithm or parameters
+	NeedsRehash(hash string) bool
+}
+
+// Password policy rules reported in PasswordViolation.Rule
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleMaxLength    = "max_length"
+	PasswordRuleUppercase    = "uppercase"
+	PasswordRuleLowercase    = "lowercase"
+	PasswordRuleDigit        = "digit"
+	PasswordRuleSymbol       = "symbol"
+	PasswordRulePersonalInfo = "personal_info"
+	PasswordRuleCommon       = "common"
+	PasswordRuleHistory      = "history"
+	PasswordRuleBreached     = "breached"
+)
+
+// PasswordViolation describes a password policy rule the password fails
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError is returned when a password fails one or more policy rules
+type PasswordPolicyError struct {
+	*BusinessError
+	Violations []PasswordViolation
+}
+
+// NewPasswordPolicyError creates a PasswordPolicyError listing the failing rules
+func NewPasswordPolicyError(violations []PasswordViolation) *PasswordPolicyError {
+	return &PasswordPolicyError{
+		BusinessError: ErrPasswordPolicy,
+		Violations:    violations,
+	}
+}
+
+// Unwrap lets errors.Is match ErrPasswordPolicy
+func (e *PasswordPolicyError) Unwrap() error {
+	return e.BusinessError
+}
+
+// PasswordDictionary defines the interface for the list of commonly used passwords
+type PasswordDictionary interface {
+	// Contains reports whether the password is a commonly used one
+	Contains(password string) bool
+}
+
+// PasswordHistoryRepository defines the interface for storing the hashes of a user's previous passwords
+type PasswordHistoryRepository interface {
+	// Add stores a password hash for the user, keeping only the newest keep entries
+	Add(ctx context.Context, userID ulid.ULID, hashedPassword string, keep int) error
+	// ListRecent lists the user's newest password hashes, newest first
+	ListRecent(ctx context.Context, userID ulid.ULID, limit int) ([]string, error)
+}
+
+// PasswordPolicyService defines the interface for the password policy applied whenever a password is set
+type PasswordPolicyService interface {
+	// Validate checks a new password for the user and returns a *PasswordPolicyError listing every failing rule
+	Validate(ctx context.Context, user *User, password string) error
+	// Remember records a newly set password hash in the user's history
+	Remember(ctx context.Context, userID ulid.ULID, hashedPassword string) error
+}
+
+// Breached password policies, chosen with BREACHED_PASSWORD_POLICY
+const (
+	// BreachedPasswordReject rejects passwords found in the breach corpus
+	BreachedPasswordReject = "reject"
+	// BreachedPasswordWarn accepts passwords found in the breach corpus and logs a warning
+	BreachedPasswordWarn = "warn"
+)
+
+// BreachedPasswordChecker defines the interface for looking passwords up in a corpus of breached passwords
+type BreachedPasswordChecker interface {
+	// Count returns how many times the password appears in the corpus, zero when it does not
+	Count(password string) (int, error)
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"context"
+	"strings"
+)
+
+// NormalizePhoneNumber returns a phone number in E.164 format, such as +14155550123. Spaces,
+// dashes, dots and parentheses are ignored, and the country code is required.
+func NormalizePhoneNumber(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	if !strings.HasPrefix(phone, "+") {
+		return "", ErrInvalidPhone
+	}
+
+	var digits strings.Builder
+	for _, r := range phone[1:] {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+
+	number := digits.String()
+	if len(number) < 8 || len(number) > 15 || number[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return "+" + number, nil
+}
+
+// PhoneService verifies the phone numbers of users and sends them single-use codes by text message
+// or voice call
+type PhoneService interface {
+	// RequestVerification sends a code to a phone number the user wants as theirs. Their current
+	// number stays in place until the code is confirmed.
+	RequestVerification(ctx context.Context, userID, phone string, channel SMSChannel) error
+	// ConfirmVerification makes the number of the latest request the user's verified phone
+	ConfirmVerification(ctx context.Context, userID, code string) error
+	// SendCode sends a single-use code to the user's verified phone, replacing any code sent before
+	SendCode(ctx context.Context, user *User, channel SMSChannel) error
+	// VerifyCode redeems the code last sent to the user's phone
+	VerifyCode(ctx context.Context, user *User, code string) error
+}
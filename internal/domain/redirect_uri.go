@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ApplicationTypeWeb identifies browser-based and server-side clients
+	ApplicationTypeWeb = "web"
+	// ApplicationTypeNative identifies desktop and mobile clients (RFC 8252)
+	ApplicationTypeNative = "native"
+)
+
+// reverseDNSScheme matches private-use URI schemes in reverse domain name notation, e.g. com.example.app
+var reverseDNSScheme = regexp.MustCompile(`^[a-z][a-z0-9-]*(\.[a-z0-9][a-z0-9-]*)+$`)
+
+// IsNative reports whether the client is a native application
+func (c *OAuth2Client) IsNative() bool {
+	return c.ApplicationType == ApplicationTypeNative
+}
+
+// IsConfidential reports whether the client can keep a secret
+func (c *OAuth2Client) IsConfidential() bool {
+	return c.Secret != ""
+}
+
+// ValidateRedirectPolicy checks the registered redirect URIs and patterns against the client's redirect policy
+func (c *OAuth2Client) ValidateRedirectPolicy() error {
+	if c.ApplicationType != "" && c.ApplicationType != ApplicationTypeWeb && c.ApplicationType != ApplicationTypeNative {
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("unsupported application type %q", c.ApplicationType))
+	}
+
+	for _, raw := range c.RedirectURIs {
+		if err := c.validateRedirectURI(raw); err != nil {
+			return err
+		}
+	}
+
+	for _, pattern := range c.RedirectURIPatterns {
+		if err := validateRedirectURIPattern(pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MatchRedirectURI reports whether the redirect URI is allowed for the client
+func (c *OAuth2Client) MatchRedirectURI(redirectURI string) bool {
+	requested, err := url.Parse(redirectURI)
+	if err != nil || requested.Fragment != "" {
+		return false
+	}
+
+	for _, registered := range c.RedirectURIs {
+		if registered == redirectURI {
+			return true
+		}
+		if c.IsNative() && matchLoopback(registered, requested) {
+			return true
+		}
+	}
+
+	for _, pattern := range c.RedirectURIPatterns {
+		if matchPattern(pattern, requested) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *OAuth2Client) validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("%s is not an absolute URI", raw))
+	}
+	if u.Fragment != "" {
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("%s must not contain a fragment", raw))
+	}
+	if strings.Contains(u.Host, "*") {
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("%s must be registered as a redirect URI pattern", raw))
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if isLoopbackHost(u.Hostname()) && (c.IsNative() || !c.IsConfidential()) {
+			return nil
+		}
+		if c.IsNative() {
+			return ErrInvalidRedirectURIReason(fmt.Sprintf("%s must use a loopback address", raw))
+		}
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("%s must use https", raw))
+	default:
+		if !c.IsNative() {
+			return ErrInvalidRedirectURIReason(fmt.Sprintf("%s uses a custom scheme, which is only allowed for native clients", raw))
+		}
+		if !reverseDNSScheme.MatchString(u.Scheme) {
+			return ErrInvalidRedirectURIReason(fmt.Sprintf("%s must use a reverse domain name scheme", raw))
+		}
+		return nil
+	}
+}
+
+func validateRedirectURIPattern(pattern string) error {
+	u, err := url.Parse(strings.Replace(pattern, "*", "wildcard", 1))
+	if err != nil || u.Scheme != "https" {
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("pattern %s must be an https URI", pattern))
+	}
+	if u.Fragment != "" || strings.Count(pattern, "*") != 1 {
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("pattern %s must contain exactly one wildcard and no fragment", pattern))
+	}
+
+	labels := strings.Split(u.Hostname(), ".")
+	if labels[0] != "wildcard" || len(labels) < 3 {
+		return ErrInvalidRedirectURIReason(fmt.Sprintf("pattern %s may only wildcard the leftmost label of a registrable domain", pattern))
+	}
+
+	return nil
+}
+
+// matchLoopback implements RFC 8252 section 7.3: loopback redirects match on any port. localhost
+// is matched the same way, as registration accepts it for native clients.
+func matchLoopback(registered string, requested *url.URL) bool {
+	reg, err := url.Parse(registered)
+	if err != nil || reg.Scheme != "http" || requested.Scheme != "http" {
+		return false
+	}
+
+	if !isLoopbackHost(reg.Hostname()) {
+		return false
+	}
+
+	return reg.Hostname() == requested.Hostname() &&
+		reg.EscapedPath() == requested.EscapedPath() &&
+		reg.RawQuery == requested.RawQuery
+}
+
+// matchPattern matches a single-label wildcard pattern such as https://*.preview.example.com/callback
+func matchPattern(pattern string, requested *url.URL) bool {
+	if requested.Scheme != "https" {
+		return false
+	}
+
+	pat, err := url.Parse(strings.Replace(pattern, "*", "wildcard", 1))
+	if err != nil {
+		return false
+	}
+
+	suffix := strings.TrimPrefix(pat.Hostname(), "wildcard")
+	host := requested.Hostname()
+	if !strings.HasSuffix(host, suffix) {
+		return false
+	}
+
+	label := strings.TrimSuffix(host, suffix)
+	if label == "" || strings.Contains(label, ".") {
+		return false
+	}
+
+	return pat.Port() == requested.Port() &&
+		pat.EscapedPath() == requested.EscapedPath() &&
+		pat.RawQuery == requested.RawQuery
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
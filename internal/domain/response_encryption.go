@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+const (
+	// SigningAlgRS256 is the only algorithm the service signs tokens and responses with
+	SigningAlgRS256 = "RS256"
+
+	// DefaultIDTokenEncryptionEnc is the content encryption used when a client only registers the key management algorithm
+	DefaultIDTokenEncryptionEnc = "A128CBC-HS256"
+)
+
+// SupportedEncryptionAlgs lists the supported JWE key management algorithms
+var SupportedEncryptionAlgs = []string{"RSA-OAEP", "RSA-OAEP-256"}
+
+// SupportedEncryptionEncs lists the supported JWE content encryption algorithms
+var SupportedEncryptionEncs = []string{"A128CBC-HS256", "A256CBC-HS512", "A128GCM", "A256GCM"}
+
+// TokenEncrypter encrypts tokens to the public keys a client publishes at its jwks_uri
+type TokenEncrypter interface {
+	// Encrypt wraps the token in a JWE using a key fetched from jwksURI
+	Encrypt(ctx context.Context, jwksURI, alg, enc, token string) (string, error)
+}
+
+// WantsSignedUserInfo reports whether the client expects userinfo responses as signed JWTs
+func (c *OAuth2Client) WantsSignedUserInfo() bool {
+	return c.UserInfoSignedResponseAlg != ""
+}
+
+// WantsEncryptedIDToken reports whether the client expects ID tokens encrypted to its keys
+func (c *OAuth2Client) WantsEncryptedIDToken() bool {
+	return c.IDTokenEncryptedResponseAlg != ""
+}
+
+// IDTokenEncryptionEnc returns the content encryption algorithm for the client's ID tokens
+func (c *OAuth2Client) IDTokenEncryptionEnc() string {
+	if c.IDTokenEncryptedResponseEnc == "" {
+		return DefaultIDTokenEncryptionEnc
+	}
+	return c.IDTokenEncryptedResponseEnc
+}
+
+// ValidateResponseFormats checks the client's userinfo signing and ID token encryption metadata
+func (c *OAuth2Client) ValidateResponseFormats() error {
+	if c.UserInfoSignedResponseAlg != "" && c.UserInfoSignedResponseAlg != SigningAlgRS256 {
+		return ErrInvalidClientMetadataReason(fmt.Sprintf("unsupported userinfo_signed_response_alg %q", c.UserInfoSignedResponseAlg))
+	}
+
+	if c.IDTokenEncryptedResponseEnc != "" && c.IDTokenEncryptedResponseAlg == "" {
+		return ErrInvalidClientMetadataReason("id_token_encrypted_response_enc requires id_token_encrypted_response_alg")
+	}
+
+	if !c.WantsEncryptedIDToken() {
+		return nil
+	}
+
+	if !contains(SupportedEncryptionAlgs, c.IDTokenEncryptedResponseAlg) {
+		return ErrInvalidClientMetadataReason(fmt.Sprintf("unsupported id_token_encrypted_response_alg %q", c.IDTokenEncryptedResponseAlg))
+	}
+	if !contains(SupportedEncryptionEncs, c.IDTokenEncryptionEnc()) {
+		return ErrInvalidClientMetadataReason(fmt.Sprintf("unsupported id_token_encrypted_response_enc %q", c.IDTokenEncryptedResponseEnc))
+	}
+
+	u, err := url.Parse(c.JWKSURI)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return ErrInvalidClientMetadataReason("ID token encryption requires an https jwks_uri")
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
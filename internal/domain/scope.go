@@ -0,0 +1,127 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Standard OpenID Connect claims that can be released about a user
+const (
+	ClaimSubject             = "sub"
+	ClaimName                = "name"
+	ClaimEmail               = "email"
+	ClaimEmailVerified       = "email_verified"
+	ClaimPhoneNumber         = "phone_number"
+	ClaimPhoneNumberVerified = "phone_number_verified"
+	ClaimRoles               = "roles"
+	ClaimUpdatedAt           = "updated_at"
+)
+
+// SupportedClaims lists the user claims the service is able to release
+var SupportedClaims = []string{
+	ClaimSubject,
+	ClaimName,
+	ClaimEmail,
+	ClaimEmailVerified,
+	ClaimPhoneNumber,
+	ClaimPhoneNumberVerified,
+	ClaimRoles,
+	ClaimUpdatedAt,
+}
+
+// DefaultScopes are assumed for tokens issued without a scope, such as first-party logins
+var DefaultScopes = []string{"openid", "profile", "email"}
+
+// Scope represents a registered OAuth2 scope and the claims it releases
+type Scope struct {
+	Name            string    `json:"name"`
+	Description     string    `json:"description"`
+	Claims          []string  `json:"claims"`
+	RequiresConsent bool      `json:"requires_consent"`
+	AllowedClients  []string  `json:"allowed_clients,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// IsRestricted reports whether the scope may only be granted to specific clients
+func (s *Scope) IsRestricted() bool {
+	return len(s.AllowedClients) > 0
+}
+
+// AllowsClient reports whether the scope may be granted to the client
+func (s *Scope) AllowsClient(clientID string) bool {
+	if !s.IsRestricted() {
+		return true
+	}
+	for _, allowed := range s.AllowedClients {
+		if allowed == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSupportedClaim reports whether the service can release the claim
+func IsSupportedClaim(claim string) bool {
+	for _, supported := range SupportedClaims {
+		if supported == claim {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimRequest represents a single entry of the OpenID Connect claims request parameter
+type ClaimRequest struct {
+	Essential bool          `json:"essential,omitempty"`
+	Value     interface{}   `json:"value,omitempty"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// ClaimsRequest represents the OpenID Connect claims request parameter
+type ClaimsRequest struct {
+	UserInfo map[string]*ClaimRequest `json:"userinfo,omitempty"`
+	IDToken  map[string]*ClaimRequest `json:"id_token,omitempty"`
+}
+
+// ScopeRepository defines the interface for scope data access
+type ScopeRepository interface {
+	// Create creates a new scope
+	Create(ctx context.Context, scope *Scope) error
+
+	// FindByName finds a scope by name
+	FindByName(ctx context.Context, name string) (*Scope, error)
+
+	// List lists all scopes
+	List(ctx context.Context) ([]*Scope, error)
+
+	// Update updates a scope
+	Update(ctx context.Context, scope *Scope) error
+
+	// Delete deletes a scope
+	Delete(ctx context.Context, name string) error
+}
+
+// ScopeService defines the interface for scope registry operations
+type ScopeService interface {
+	// CreateScope registers a new scope
+	CreateScope(ctx context.Context, scope *Scope) error
+
+	// GetScope retrieves a scope by name
+	GetScope(ctx context.Context, name string) (*Scope, error)
+
+	// ListScopes lists all registered scopes
+	ListScopes(ctx context.Context) ([]*Scope, error)
+
+	// UpdateScope updates a registered scope
+	UpdateScope(ctx context.Context, scope *Scope) error
+
+	// DeleteScope removes a scope from the registry
+	DeleteScope(ctx context.Context, name string) error
+
+	// ValidateScopes checks that every scope is registered and may be granted to the client
+	ValidateScopes(ctx context.Context, clientID string, scopes []string) error
+
+	// ResolveClaims returns the claims released by the scopes plus the individually requested claims
+	ResolveClaims(ctx context.Context, scopes []string, requested map[string]*ClaimRequest) ([]string, error)
+}
@@ -0,0 +1,11 @@
+package domain
+
+import "context"
+
+// SecretCipher encrypts secrets kept at rest, such as the seeds of authenticator apps
+type SecretCipher interface {
+	// Encrypt seals a secret for storage
+	Encrypt(ctx context.Context, plaintext string) (string, error)
+	// Decrypt opens a secret sealed by Encrypt
+	Decrypt(ctx context.Context, ciphertext string) (string, error)
+}
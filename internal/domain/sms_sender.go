@@ -0,0 +1,18 @@
+package domain
+
+import "context"
+
+// SMSChannel is how a message reaches a phone number
+type SMSChannel string
+
+const (
+	// SMSChannelText delivers the message as a text message
+	SMSChannelText SMSChannel = "sms"
+	// SMSChannelVoice reads the message out in a phone call
+	SMSChannelVoice SMSChannel = "voice"
+)
+
+// SMSSender defines the interface for delivering messages to phone numbers
+type SMSSender interface {
+	Send(ctx context.Context, phone string, channel SMSChannel, message string) error
+}
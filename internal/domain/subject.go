@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	// SubjectTypePublic gives every client the same subject identifier for a user
+	SubjectTypePublic = "public"
+	// SubjectTypePairwise gives each sector a different subject identifier for a user
+	SubjectTypePairwise = "pairwise"
+)
+
+// IsPairwise reports whether the client receives pairwise subject identifiers
+func (c *OAuth2Client) IsPairwise() bool {
+	return c.SubjectType == SubjectTypePairwise
+}
+
+// ValidateSubjectType checks the client's subject type and that a sector identifier can be derived for it
+func (c *OAuth2Client) ValidateSubjectType() error {
+	switch c.SubjectType {
+	case "", SubjectTypePublic:
+		return nil
+	case SubjectTypePairwise:
+		_, err := c.SectorIdentifier()
+		return err
+	default:
+		return ErrInvalidSectorIdentifierReason(fmt.Sprintf("unsupported subject type %q", c.SubjectType))
+	}
+}
+
+// SectorIdentifier returns the host pairwise subject identifiers are computed for: the host of the
+// sector_identifier_uri when registered, otherwise the single host shared by all redirect URIs. Every
+// native app on the device shares the loopback host, so clients redirecting only to loopback
+// addresses form a sector of their own, named after the client ID.
+func (c *OAuth2Client) SectorIdentifier() (string, error) {
+	if c.SectorIdentifierURI != "" {
+		u, err := url.Parse(c.SectorIdentifierURI)
+		if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+			return "", ErrInvalidSectorIdentifierReason(fmt.Sprintf("%s must be an https URI", c.SectorIdentifierURI))
+		}
+		return u.Hostname(), nil
+	}
+
+	sector := ""
+	for _, raw := range c.RedirectURIs {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			return "", ErrInvalidSectorIdentifierReason(fmt.Sprintf("no host in redirect URI %s", raw))
+		}
+		host := u.Hostname()
+		if isLoopbackHost(host) {
+			host = c.ID
+		}
+		if sector != "" && sector != host {
+			return "", ErrInvalidSectorIdentifierReason("redirect URIs use several hosts, a sector_identifier_uri is required")
+		}
+		sector = host
+	}
+
+	if sector == "" {
+		return "", ErrInvalidSectorIdentifierReason("no redirect URI to derive the sector from")
+	}
+	return sector, nil
+}
+
+// PairwiseSubject computes the pairwise subject identifier for a local user ID within a sector,
+// as described in OpenID Connect Core section 8.1
+func PairwiseSubject(sector, userID, salt string) string {
+	hash := sha256.Sum256([]byte(sector + userID + salt))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// PairwiseSubjectRepository records the pairwise subject identifiers issued in tokens, so the
+// subject of a token can be mapped back to the local user
+type PairwiseSubjectRepository interface {
+	// Save records the subject identifier of the user within the sector; saving it again is a no-op
+	Save(ctx context.Context, sector, subject string, userID ulid.ULID) error
+	// FindUserID returns the user the subject identifier was issued for within the sector
+	FindUserID(ctx context.Context, sector, subject string) (ulid.ULID, error)
+}
+
+// SubjectResolver maps the subject of a token back to the local user ID
+type SubjectResolver interface {
+	// ResolveSubject returns the user ID the subject of a token issued to the client stands for
+	ResolveSubject(ctx context.Context, clientID, subject string) (string, error)
+}
+
+// SectorIdentifierVerifier checks client registrations against their sector_identifier_uri
+type SectorIdentifierVerifier interface {
+	// Verify fetches the JSON array of redirect URIs published at sectorIdentifierURI and checks
+	// that it lists every one of redirectURIs
+	Verify(ctx context.Context, sectorIdentifierURI string, redirectURIs []string) error
+}
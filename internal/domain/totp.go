@@ -3,6 +3,8 @@ package domain
 import (
 	"context"
 	"time"
+
+	"github.com/oklog/ulid/v2"
 )
 
 // TOTPConfig represents the configuration for TOTP
@@ -21,46 +23,125 @@ type TOTPBackupCodes struct {
 	Used  []bool
 }
 
+// TOTPQRCode is the otpauth URI of an authenticator app secret and the QR code encoding it
+type TOTPQRCode struct {
+	URI string
+	PNG string
+	SVG string
+}
+
+// TOTP is the result of enrolling an authenticator app. Starting an enrolment sets the QR code, as
+// a PNG data URI in QRCode and as SVG markup in QRCodeSVG, and the OTPAuthURI it encodes for apps
+// on the same device; confirming it sets FactorID, and BackupCodes for the first authenticator app
+// of a user.
 type TOTP struct {
+	FactorID    string
 	QRCode      string
+	QRCodeSVG   string
+	OTPAuthURI  string
 	BackupCodes []string
 }
 
+// BackupCodesStatus reports how many of the user's backup codes are still unused
+type BackupCodesStatus struct {
+	Remaining int `json:"remaining"`
+	Total     int `json:"total"`
+}
+
+// TOTPEnrollment is an authenticator app waiting for the user to confirm it with a first code
+type TOTPEnrollment struct {
+	UserID    ulid.ULID
+	Secret    string
+	Label     string
+	Algorithm string
+	Digits    int
+	Period    time.Duration
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// TOTPSecret is the secret of an enrolled authenticator app with the parameters its codes are
+// generated with, fixed when the app was enrolled
+type TOTPSecret struct {
+	FactorID  string
+	Secret    string
+	Algorithm string
+	Digits    int
+	Period    time.Duration
+}
+
+// IsExpired reports whether the enrolment can no longer be confirmed
+func (e *TOTPEnrollment) IsExpired() bool {
+	return time.Now().After(e.ExpiresAt)
+}
+
 // TOTPRepository defines the interface for TOTP data access
 type TOTPRepository interface {
-	// SaveTOTPSecret saves the TOTP secret for a user
-	SaveTOTPSecret(ctx context.Context, userID, secret string) error
-	// GetTOTPSecret retrieves the TOTP secret for a user
-	GetTOTPSecret(ctx context.Context, userID string) (string, error)
-	// SaveBackupCodes saves the backup codes for a user
+	// SaveTOTPSecret registers an authenticator app factor together with its secret
+	SaveTOTPSecret(ctx context.Context, factor *MFAFactor, secret string) error
+	// GetTOTPSecret retrieves the secret of one of the user's authenticator apps
+	GetTOTPSecret(ctx context.Context, userID, factorID string) (*TOTPSecret, error)
+	// ListTOTPSecrets retrieves the secrets of all the user's authenticator apps
+	ListTOTPSecrets(ctx context.Context, userID string) ([]*TOTPSecret, error)
+	// RecordTOTPStep stores the time-step of the last code an authenticator app was verified
+	// with, failing with ErrTOTPCodeReused unless it is later than the previous one
+	RecordTOTPStep(ctx context.Context, factorID string, step int64) error
+	// SaveBackupCodes saves the backup codes for a user, registering their recovery factor
 	SaveBackupCodes(ctx context.Context, userID string, codes []string) error
 	// GetBackupCodes retrieves the backup codes for a user
 	GetBackupCodes(ctx context.Context, userID string) ([]string, error)
 	// MarkBackupCodeAsUsed marks a backup code as used
 	MarkBackupCodeAsUsed(ctx context.Context, userID string, codeIndex int) error
-	// DeleteTOTPConfig deletes the TOTP configuration for a user
+	// DeleteTOTPConfig deletes every authenticator app, the backup codes and any pending
+	// enrolment of a user
 	DeleteTOTPConfig(ctx context.Context, userID string) error
+	// SavePendingTOTP stores an unconfirmed enrolment, replacing any earlier one of the user
+	SavePendingTOTP(ctx context.Context, enrollment *TOTPEnrollment) error
+	// GetPendingTOTP retrieves the unconfirmed enrolment of a user
+	GetPendingTOTP(ctx context.Context, userID string) (*TOTPEnrollment, error)
+	// ActivatePendingTOTP turns the user's pending enrolment with the given secret into an
+	// authenticator app factor, whose code at the given time-step was just verified
+	ActivatePendingTOTP(ctx context.Context, factor *MFAFactor, secret string, step int64) error
 }
 
 // TOTPGenerator defines the interface for TOTP code generation and validation
 type TOTPGenerator interface {
 	// GenerateSecret generates a new TOTP secret
 	GenerateSecret() (string, error)
-	// GenerateQRCode generates a QR code for the TOTP secret
-	GenerateQRCode(config *TOTPConfig) (string, error)
+	// GenerateQRCode generates the otpauth URI of the TOTP secret and its QR code
+	GenerateQRCode(config *TOTPConfig) (*TOTPQRCode, error)
 	// GenerateBackupCodes generates backup codes
 	GenerateBackupCodes(count int) ([]string, error)
-	// ValidateCode validates a TOTP code
-	ValidateCode(secret, code string) error
-	// ValidateBackupCode validates a backup code
+	// ValidateCode validates a TOTP code against the secret and parameters of the config,
+	// returning the time-step the code belongs to
+	ValidateCode(config *TOTPConfig, code string) (int64, error)
+	// HashBackupCode hashes a backup code with a random salt for storage
+	HashBackupCode(code string) (string, error)
+	// ValidateBackupCode returns the index of the hashed backup code matching a code
 	ValidateBackupCode(backupCodes []string, code string) (int, error)
 }
 
 // TOTPService defines the interface for TOTP operations
 type TOTPService interface {
-	EnableTOTP(userID string) (*TOTP, error)
+	// EnableTOTP starts enrolling an additional authenticator app under the given label
+	EnableTOTP(userID, label string) (*TOTP, error)
+	// ConfirmTOTP activates the pending authenticator app once it produces a valid code
+	ConfirmTOTP(ctx context.Context, userID, code string) (*TOTP, error)
+	// VerifyTOTP checks a code against all the user's authenticator apps
 	VerifyTOTP(userID, code string) error
+	// VerifyTOTPFactor checks a code against one of the user's authenticator apps
+	VerifyTOTPFactor(ctx context.Context, userID, factorID, code string) error
 	VerifyBackupCode(userID, code string) error
+	// VerifyUserCode checks a code a signed-in user sends against their factors of the given types,
+	// authenticator apps or backup codes, in turn, counting a rejected code towards their lockout
+	VerifyUserCode(ctx context.Context, userID, code string, factorTypes ...MFAFactorType) error
+	// BackupCodesStatus reports how many of the user's backup codes are left
+	BackupCodesStatus(ctx context.Context, userID string) (*BackupCodesStatus, error)
+	// RegenerateBackupCodes replaces the user's backup codes once an authenticator app code, or
+	// without one a recent second factor of the session, confirms the request
+	RegenerateBackupCodes(ctx context.Context, userID, code string) ([]string, error)
+	// DisableTOTP removes every authenticator app and the backup codes of the user
 	DisableTOTP(userID string) error
-	GetTOTPSecret(ctx context.Context, userID string) (string, error)
+	// IsTOTPEnabled reports whether the user has enrolled an authenticator app
+	IsTOTPEnabled(ctx context.Context, userID string) (bool, error)
 }
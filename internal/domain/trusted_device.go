@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// TrustedDevice is a device a user completed MFA on and chose to trust. Logins presenting its
+// device token skip MFA until it expires or is revoked.
+type TrustedDevice struct {
+	ID         ulid.ULID  `json:"id"`
+	UserID     ulid.ULID  `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	ClientIP   string     `json:"client_ip,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// IsExpired reports whether the device no longer lets its user skip MFA
+func (d *TrustedDevice) IsExpired(now time.Time) bool {
+	return now.After(d.ExpiresAt)
+}
+
+// DeviceTrust asks for the device completing MFA to be trusted under the given name
+type DeviceTrust struct {
+	Name string
+}
+
+// TrustedDeviceRepository defines the interface for storing trusted devices
+type TrustedDeviceRepository interface {
+	// Create stores a newly trusted device
+	Create(ctx context.Context, device *TrustedDevice) error
+	// FindByID retrieves a trusted device
+	FindByID(ctx context.Context, id ulid.ULID) (*TrustedDevice, error)
+	// ListByUser lists the trusted devices of a user, newest first
+	ListByUser(ctx context.Context, userID ulid.ULID) ([]*TrustedDevice, error)
+	// Touch records a login from a trusted device
+	Touch(ctx context.Context, id ulid.ULID, usedAt time.Time) error
+	// Delete revokes one of the user's trusted devices
+	Delete(ctx context.Context, userID, id ulid.ULID) error
+	// DeleteByUser revokes every trusted device of a user
+	DeleteByUser(ctx context.Context, userID ulid.ULID) error
+}
+
+// TrustedDeviceService defines the interface for remembering the devices users complete MFA on
+type TrustedDeviceService interface {
+	// Trust remembers a device for the user and returns it with the device token to present at
+	// later logins. Users with a role excluded from device trust get ErrDeviceTrustNotAllowed.
+	Trust(ctx context.Context, user *User, name string) (*TrustedDevice, string, error)
+	// Verify reports whether the device token belongs to an unexpired device of the user, and
+	// whether the user may still skip MFA on it
+	Verify(ctx context.Context, user *User, token string) bool
+	// List lists the trusted devices of a user, newest first
+	List(ctx context.Context, userID string) ([]*TrustedDevice, error)
+	// Revoke revokes one of the user's trusted devices
+	Revoke(ctx context.Context, userID, deviceID string) error
+	// RevokeAll revokes every trusted device of a user
+	RevokeAll(ctx context.Context, userID string) error
+}
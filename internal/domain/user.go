@@ -20,18 +20,21 @@ type User struct {
 	Email         string     `json:"email"`
 	Password      string     `json:"-"` // Password is not serialized to JSON
 	Phone         string     `json:"phone"`
+	PhoneVerified bool       `json:"phone_verified"`
 	Roles         []string   `json:"roles"`
 	EmailVerified bool       `json:"email_verified"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	// SessionsRevokedAt invalidates the refresh tokens issued before it
+	SessionsRevokedAt *time.Time `json:"-"`
 }
 
 // CreateUserRequest represents the request to create a new user
 type CreateUserRequest struct {
 	Name     string `json:"name" validate:"required"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
 	Phone    string `json:"phone" validate:"required"`
 }
 
@@ -41,10 +44,15 @@ type UpdateUserRequest struct {
 	Phone string `json:"phone" validate:"required"`
 }
 
-// LoginRequest represents the request to login a user
+// LoginRequest represents the request to login a user. ClientID and Scope name the OAuth2 client
+// and the space-separated scopes a login page signs in for, so their MFA policy applies.
+// DeviceToken is the token of a trusted device, letting the user skip MFA.
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email       string `json:"email" validate:"required,email"`
+	Password    string `json:"password" validate:"required"`
+	ClientID    string `json:"client_id"`
+	Scope       string `json:"scope"`
+	DeviceToken string `json:"device_token"`
 }
 
 // NewUser creates a new user instance
@@ -101,6 +109,13 @@ type UserService interface {
 	ListUsers(ctx context.Context, limit, offset int) ([]*User, error)
 }
 
+// SessionValidator checks that a token still belongs to a session of its user
+type SessionValidator interface {
+	// ValidateSession returns ErrInvalidToken when the sessions of the user were revoked after
+	// the token was issued, e.g. by a password change
+	ValidateSession(ctx context.Context, userID string, issuedAt *time.Time) error
+}
+
 // UserRepository defines the interface for user data access
 type UserRepository interface {
 	// Create creates a new user in the database
@@ -121,6 +136,15 @@ type UserRepository interface {
 	// UpdatePassword updates a user's password
 	UpdatePassword(ctx context.Context, userID ulid.ULID, hashedPassword string) error
 
+	// UpdateEmail changes a user's email to a confirmed address
+	UpdateEmail(ctx context.Context, userID ulid.ULID, email string) error
+
+	// UpdatePhone changes a user's phone to a verified number
+	UpdatePhone(ctx context.Context, userID ulid.ULID, phone string) error
+
+	// RevokeSessions invalidates the refresh tokens issued to a user before the given time
+	RevokeSessions(ctx context.Context, userID ulid.ULID, before time.Time) error
+
 	// Delete deletes a user
 	Delete(ctx context.Context, id ulid.ULID) error
 
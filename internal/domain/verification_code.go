@@ -13,6 +13,11 @@ type VerificationCodeType string
 const (
 	EmailVerification VerificationCodeType = "email_verification"
 	PasswordReset     VerificationCodeType = "password_reset"
+	EmailChange       VerificationCodeType = "email_change"
+	PasswordlessLogin VerificationCodeType = "passwordless_login"
+	MFAEmailCode      VerificationCodeType = "mfa_email_code"
+	PhoneVerification VerificationCodeType = "phone_verification"
+	MFASMSCode        VerificationCodeType = "mfa_sms_code"
 )
 
 // VerificationCode represents a verification code for email verification or password reset
@@ -21,6 +26,7 @@ type VerificationCode struct {
 	UserID    ulid.ULID            `json:"user_id"`
 	Code      string               `json:"code"`
 	Type      VerificationCodeType `json:"type"`
+	Target    string               `json:"target,omitempty"` // New address of an email change, or phone number being verified
 	ExpiresAt time.Time            `json:"expires_at"`
 	CreatedAt time.Time            `json:"created_at"`
 }
@@ -38,6 +44,13 @@ func NewVerificationCode(userID ulid.ULID, code string, codeType VerificationCod
 	}
 }
 
+// NewEmailChangeCode creates a verification code confirming a change to the given email address
+func NewEmailChangeCode(userID ulid.ULID, code, newEmail string, expiresIn time.Duration) *VerificationCode {
+	verificationCode := NewVerificationCode(userID, code, EmailChange, expiresIn)
+	verificationCode.Target = newEmail
+	return verificationCode
+}
+
 // IsExpired checks if the verification code is expired
 func (vc *VerificationCode) IsExpired() bool {
 	return time.Now().After(vc.ExpiresAt)
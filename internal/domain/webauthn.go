@@ -0,0 +1,239 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// WebAuthnCeremony is the operation a WebAuthn challenge was issued for
+type WebAuthnCeremony string
+
+const (
+	// WebAuthnRegistration registers a new passkey for a signed-in user
+	WebAuthnRegistration WebAuthnCeremony = "registration"
+	// WebAuthnLogin signs a user in with a passkey as the first factor
+	WebAuthnLogin WebAuthnCeremony = "login"
+	// WebAuthnMFA verifies a passkey as the second factor of an MFA ticket
+	WebAuthnMFA WebAuthnCeremony = "mfa"
+)
+
+// Base64URL is binary data encoded as unpadded base64url in JSON, as WebAuthn clients expect
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	// Some clients pad their output even though the specification asks them not to
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+func (b Base64URL) String() string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// WebAuthnCredential is a passkey registered by a user
+type WebAuthnCredential struct {
+	ID           ulid.ULID  `json:"id"`
+	UserID       ulid.ULID  `json:"-"`
+	CredentialID Base64URL  `json:"credential_id"`
+	PublicKey    []byte     `json:"-"`
+	SignCount    uint32     `json:"-"`
+	Transports   []string   `json:"transports"`
+	AAGUID       string     `json:"aaguid"`
+	Name         string     `json:"name"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnChallenge is a single-use challenge handed to the client for one ceremony
+type WebAuthnChallenge struct {
+	ID        ulid.ULID
+	Ceremony  WebAuthnCeremony
+	Challenge []byte
+	// UserID is empty for passkey logins, where the user is only known from the assertion
+	UserID ulid.ULID
+	// MFATicket binds an MFA challenge to the ticket it was issued for
+	MFATicket string
+	ExpiresAt time.Time
+}
+
+// IsExpired reports whether the challenge can no longer be answered
+func (c *WebAuthnChallenge) IsExpired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// WebAuthnRelyingParty identifies this server to the authenticator
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// WebAuthnUserEntity identifies the user a passkey is created for
+type WebAuthnUserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+// WebAuthnCredentialParameter is a credential type and COSE algorithm the server accepts
+type WebAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// WebAuthnCredentialDescriptor refers to an existing credential
+type WebAuthnCredentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+
+// WebAuthnAuthenticatorSelection states the authenticator requirements of a registration
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// WebAuthnCreationOptions are the PublicKeyCredentialCreationOptions passed to navigator.credentials.create
+type WebAuthnCreationOptions struct {
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	Challenge              Base64URL                      `json:"challenge"`
+	PubKeyCredParams       []WebAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnRequestOptions are the PublicKeyCredentialRequestOptions passed to navigator.credentials.get
+type WebAuthnRequestOptions struct {
+	Challenge        Base64URL                      `json:"challenge"`
+	Timeout          int64                          `json:"timeout"`
+	RPID             string                         `json:"rpId"`
+	AllowCredentials []WebAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+// WebAuthnRegistrationStart pairs the creation options with the challenge session to finish with
+type WebAuthnRegistrationStart struct {
+	Session   string                   `json:"session"`
+	PublicKey *WebAuthnCreationOptions `json:"publicKey"`
+}
+
+// WebAuthnLoginStart pairs the request options with the challenge session to finish with
+type WebAuthnLoginStart struct {
+	Session   string                  `json:"session"`
+	PublicKey *WebAuthnRequestOptions `json:"publicKey"`
+}
+
+// WebAuthnAttestationResponse is the JSON form of the PublicKeyCredential returned by a registration
+type WebAuthnAttestationResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId" validate:"required"`
+	Type     string    `json:"type" validate:"eq=public-key"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+		AttestationObject Base64URL `json:"attestationObject" validate:"required"`
+		Transports        []string  `json:"transports"`
+	} `json:"response"`
+}
+
+// WebAuthnAssertionResponse is the JSON form of the PublicKeyCredential returned by an authentication
+type WebAuthnAssertionResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId" validate:"required"`
+	Type     string    `json:"type" validate:"eq=public-key"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON" validate:"required"`
+		AuthenticatorData Base64URL `json:"authenticatorData" validate:"required"`
+		Signature         Base64URL `json:"signature" validate:"required"`
+		UserHandle        Base64URL `json:"userHandle"`
+	} `json:"response"`
+}
+
+// WebAuthnAttestation is the credential data verified from a registration
+type WebAuthnAttestation struct {
+	CredentialID []byte
+	// PublicKey is the COSE encoded credential public key
+	PublicKey    []byte
+	SignCount    uint32
+	AAGUID       string
+	UserVerified bool
+}
+
+// WebAuthnAssertion is the authenticator state verified from an authentication
+type WebAuthnAssertion struct {
+	SignCount    uint32
+	UserVerified bool
+}
+
+// WebAuthnCredentialRepository defines the interface for passkey storage
+type WebAuthnCredentialRepository interface {
+	// Create stores a new credential
+	Create(ctx context.Context, credential *WebAuthnCredential) error
+	// FindByCredentialID retrieves a credential by the ID the authenticator assigned it
+	FindByCredentialID(ctx context.Context, credentialID []byte) (*WebAuthnCredential, error)
+	// ListByUser lists the credentials of a user, oldest first
+	ListByUser(ctx context.Context, userID ulid.ULID) ([]*WebAuthnCredential, error)
+	// UpdateSignCount records a successful use of a credential
+	UpdateSignCount(ctx context.Context, id ulid.ULID, signCount uint32, usedAt time.Time) error
+	// Rename changes the friendly name of one of the user's credentials
+	Rename(ctx context.Context, userID, id ulid.ULID, name string) error
+	// Delete removes one of the user's credentials
+	Delete(ctx context.Context, userID, id ulid.ULID) error
+}
+
+// WebAuthnChallengeRepository defines the interface for pending WebAuthn challenges
+type WebAuthnChallengeRepository interface {
+	// Create stores a new challenge
+	Create(ctx context.Context, challenge *WebAuthnChallenge) error
+	// Consume retrieves and deletes a challenge, so that it can be answered only once
+	Consume(ctx context.Context, id ulid.ULID) (*WebAuthnChallenge, error)
+}
+
+// WebAuthnVerifier defines the interface for verifying WebAuthn ceremony responses
+type WebAuthnVerifier interface {
+	// VerifyRegistration verifies a registration response against the challenge it answers
+	VerifyRegistration(challenge []byte, response *WebAuthnAttestationResponse, requireUserVerification bool) (*WebAuthnAttestation, error)
+	// VerifyAssertion verifies an authentication response against the challenge and the stored COSE public key
+	VerifyAssertion(challenge []byte, response *WebAuthnAssertionResponse, publicKey []byte, requireUserVerification bool) (*WebAuthnAssertion, error)
+}
+
+// WebAuthnService defines the interface for passkey registration, sign-in and management
+type WebAuthnService interface {
+	// BeginRegistration starts registering a passkey for a signed-in user
+	BeginRegistration(ctx context.Context, userID string) (*WebAuthnRegistrationStart, error)
+	// FinishRegistration verifies the authenticator response and stores the new passkey
+	FinishRegistration(ctx context.Context, userID, session, name string, response *WebAuthnAttestationResponse) (*WebAuthnCredential, error)
+	// BeginLogin starts a passwordless sign-in with a discoverable passkey
+	BeginLogin(ctx context.Context) (*WebAuthnLoginStart, error)
+	// FinishLogin verifies a passkey assertion and returns a token pair
+	FinishLogin(ctx context.Context, session string, response *WebAuthnAssertionResponse) (*TokenPair, error)
+	// BeginMFA starts verifying a passkey as the second factor of an MFA ticket
+	BeginMFA(ctx context.Context, ticketID string) (*WebAuthnLoginStart, error)
+	// FinishMFA verifies a passkey assertion for an MFA ticket and returns a token pair
+	FinishMFA(ctx context.Context, ticketID, session string, response *WebAuthnAssertionResponse) (*TokenPair, error)
+	// ListCredentials lists the passkeys of a user
+	ListCredentials(ctx context.Context, userID string) ([]*WebAuthnCredential, error)
+	// RenameCredential changes the friendly name of a passkey
+	RenameCredential(ctx context.Context, userID, credentialID, name string) error
+	// DeleteCredential removes a passkey
+	DeleteCredential(ctx context.Context, userID, credentialID string) error
+}
@@ -0,0 +1,58 @@
+package ciba
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"go.uber.org/zap"
+)
+
+// notifier implements domain.BackchannelNotifier by POSTing to the client notification endpoint
+type notifier struct {
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewNotifier creates a new backchannel ping notifier
+func NewNotifier(logger *zap.Logger) domain.BackchannelNotifier {
+	return &notifier{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+	}
+}
+
+// Notify sends the ping callback, authenticated with the client notification token as a bearer token
+func (n *notifier) Notify(ctx context.Context, endpoint, clientNotificationToken, authReqID string) error {
+	body, err := json.Marshal(map[string]string{"auth_req_id": authReqID})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+clientNotificationToken)
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, endpoint)
+	}
+
+	n.logger.Debug("Backchannel ping delivered",
+		zap.String("endpoint", endpoint),
+		zap.String("auth_req_id", authReqID))
+
+	return nil
+}
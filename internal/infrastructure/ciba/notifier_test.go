@@ -0,0 +1,48 @@
+package ciba
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNotifier_Notify(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		expectedError bool
+	}{
+		{name: "client acknowledges ping", status: http.StatusNoContent},
+		{name: "client rejects ping", status: http.StatusUnauthorized, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authorization string
+			var body map[string]string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get("Authorization")
+				json.NewDecoder(r.Body).Decode(&body)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			n := NewNotifier(zap.NewNop())
+			err := n.Notify(context.Background(), server.URL, "notification-token", "auth-req-1")
+
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, "Bearer notification-token", authorization)
+			assert.Equal(t, map[string]string{"auth_req_id": "auth-req-1"}, body)
+		})
+	}
+}
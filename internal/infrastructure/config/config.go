@@ -1,10 +1,15 @@
 package config
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -23,6 +28,51 @@ type SMTPConfig struct {
 	SkipVerify     bool
 }
 
+// SMSConfig holds the configuration of the provider delivering text messages and voice calls
+type SMSConfig struct {
+	Provider    string
+	LogFile     string
+	HTTPURL     string
+	HTTPToken   string
+	HTTPTimeout time.Duration
+	From        string
+}
+
+// FederatedProviderConfig holds the configuration of an upstream OpenID Connect provider and
+// the claims its ID tokens carry the user's email, email verification and name in
+type FederatedProviderConfig struct {
+	Name               string
+	DiscoveryURL       string
+	ClientID           string
+	ClientSecret       string
+	Scopes             []string
+	EmailClaim         string
+	EmailVerifiedClaim string
+	NameClaim          string
+	TrustEmail         bool
+}
+
+// LDAPConfig holds the configuration of the directory users without a local password sign in
+// against, either binding with a DN built from BindDNTemplate or searching BaseDN for the entry
+// matching UserFilter first, and the attributes holding the user's name, email and groups
+type LDAPConfig struct {
+	URL            string
+	StartTLS       bool
+	SkipVerify     bool
+	CAFile         string
+	Timeout        time.Duration
+	BindDNTemplate string
+	BindDN         string
+	BindPassword   string
+	BaseDN         string
+	UserFilter     string
+	NameAttribute  string
+	EmailAttribute string
+	GroupAttribute string
+	GroupRoles     map[string]string
+	DefaultRoles   []string
+}
+
 type Config struct {
 	DBHost     string
 	DBPort     int
@@ -40,12 +90,90 @@ type Config struct {
 	VaultMountPath string
 	VaultKeyName   string
 
+	SecretKeyProvider   string
+	SecretEncryptionKey string
+	VaultSecretKeyName  string
+
 	ServerPort        int
 	ServerURL         string
 	RSAKeySize        int
 	JWKSCacheDuration time.Duration
 
+	// TrustedProxies are the reverse proxies, as IP addresses or CIDR ranges, whose X-Forwarded-For
+	// header is believed
+	TrustedProxies []string
+
+	PairwiseSubjectSalt string
+
+	CIBARequestExpiry time.Duration
+	CIBAPollInterval  time.Duration
+
+	LockoutThreshold         int
+	LockoutDuration          time.Duration
+	LockoutIPThreshold       int
+	LockoutBackoffBase       time.Duration
+	MFAMaxAttempts           int
+	MFAEmailCodeTTL          time.Duration
+	MFARequiredRoles         []string
+	MFARequiredClients       []string
+	MFARequiredScopes        []string
+	MFAEnrollmentGracePeriod time.Duration
+	StepUpACR                string
+	StepUpMaxAge             time.Duration
+	AdminRequiredACR         string
+	DeviceTrustDuration      time.Duration
+	DeviceTrustExcludedRoles []string
+	LoginAlertLinkURL        string
+	LoginAlertLinkTTL        time.Duration
+	LoginRiskMFA             bool
+	SMSCodeTTL               time.Duration
+	SMSSendLimit             int
+	SMSUserSendLimit         int
+	SMSIPSendLimit           int
+	SMSSendWindow            time.Duration
+	TOTPEnrollmentTTL        time.Duration
+	TOTPIssuer               string
+	TOTPAlgorithm            string
+	TOTPDigits               int
+	TOTPPeriod               time.Duration
+	TOTPBackupCodesCount     int
+	TOTPBackupCodesThreshold int
+
+	PasswordHashMemory      uint32
+	PasswordHashIterations  uint32
+	PasswordHashParallelism uint8
+
+	PasswordMinLength      int
+	PasswordMaxLength      int
+	PasswordRequireUpper   bool
+	PasswordRequireLower   bool
+	PasswordRequireDigit   bool
+	PasswordRequireSymbol  bool
+	PasswordHistorySize    int
+	PasswordDictionaryPath string
+
+	BreachedPasswordsDir   string
+	BreachedPasswordPolicy string
+
+	PasswordlessEnabled    bool
+	PasswordlessCodeLength int
+	PasswordlessCodeTTL    time.Duration
+	PasswordlessLinkURL    string
+	PasswordlessRateLimit  int
+	PasswordlessRateWindow time.Duration
+
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+	WebAuthnTimeout time.Duration
+
+	FederatedProviders   []FederatedProviderConfig
+	FederatedStateTTL    time.Duration
+	FederatedHTTPTimeout time.Duration
+
 	SMTP SMTPConfig
+	SMS  SMSConfig
+	LDAP LDAPConfig
 }
 
 // LoadConfig loads configuration from environment variables, logging with zap
@@ -80,6 +208,16 @@ func LoadConfig(logger *zap.Logger) (*Config, error) {
 		return val, nil
 	}
 
+	getList := func(key, defaultVal string) []string {
+		var vals []string
+		for _, val := range strings.Split(getEnv(key, defaultVal), ",") {
+			if val = strings.TrimSpace(val); val != "" {
+				vals = append(vals, val)
+			}
+		}
+		return vals
+	}
+
 	cfg := &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBUser:     getEnv("DB_USER", "owner"),
@@ -94,8 +232,29 @@ func LoadConfig(logger *zap.Logger) (*Config, error) {
 		VaultMountPath: getEnv("VAULT_MOUNT_PATH", "transit/authM"),
 		VaultKeyName:   getEnv("VAULT_KEY_NAME", "jwt-signing-key"),
 
+		SecretKeyProvider:   getEnv("SECRET_KEY_PROVIDER", "local"),
+		SecretEncryptionKey: getEnv("SECRET_ENCRYPTION_KEY", ""),
+		VaultSecretKeyName:  getEnv("VAULT_SECRET_KEY_NAME", "totp-secrets"),
+
 		ServerURL: getEnv("SERVER_URL", "http://localhost:8080"),
 
+		PairwiseSubjectSalt: getEnv("PAIRWISE_SUBJECT_SALT", ""),
+
+		PasswordRequireUpper:   getEnv("PASSWORD_REQUIRE_UPPERCASE", "true") == "true",
+		PasswordRequireLower:   getEnv("PASSWORD_REQUIRE_LOWERCASE", "true") == "true",
+		PasswordRequireDigit:   getEnv("PASSWORD_REQUIRE_DIGIT", "true") == "true",
+		PasswordRequireSymbol:  getEnv("PASSWORD_REQUIRE_SYMBOL", "false") == "true",
+		PasswordDictionaryPath: getEnv("PASSWORD_DICTIONARY_PATH", ""),
+
+		BreachedPasswordsDir:   getEnv("BREACHED_PASSWORDS_DIR", ""),
+		BreachedPasswordPolicy: getEnv("BREACHED_PASSWORD_POLICY", "reject"),
+
+		PasswordlessEnabled: getEnv("PASSWORDLESS_ENABLED", "false") == "true",
+		PasswordlessLinkURL: getEnv("PASSWORDLESS_LINK_URL", ""),
+
+		WebAuthnRPID:   getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName: getEnv("WEBAUTHN_RP_NAME", "authM"),
+
 		SMTP: SMTPConfig{
 			Host:           getEnv("SMTP_HOST", "localhost"),
 			Username:       getEnv("SMTP_USERNAME", ""),
@@ -105,6 +264,29 @@ func LoadConfig(logger *zap.Logger) (*Config, error) {
 			UseTLS:         getEnv("SMTP_USE_TLS", "true") == "true",
 			SkipVerify:     getEnv("SMTP_SKIP_VERIFY", "false") == "true",
 		},
+
+		SMS: SMSConfig{
+			Provider:  getEnv("SMS_PROVIDER", "log"),
+			LogFile:   getEnv("SMS_LOG_FILE", ""),
+			HTTPURL:   getEnv("SMS_HTTP_URL", ""),
+			HTTPToken: getEnv("SMS_HTTP_TOKEN", ""),
+			From:      getEnv("SMS_FROM", ""),
+		},
+
+		LDAP: LDAPConfig{
+			URL:            getEnv("LDAP_URL", ""),
+			StartTLS:       getEnv("LDAP_START_TLS", "false") == "true",
+			SkipVerify:     getEnv("LDAP_SKIP_VERIFY", "false") == "true",
+			CAFile:         getEnv("LDAP_CA_FILE", ""),
+			BindDNTemplate: getEnv("LDAP_BIND_DN_TEMPLATE", ""),
+			BindDN:         getEnv("LDAP_BIND_DN", ""),
+			BindPassword:   getEnv("LDAP_BIND_PASSWORD", ""),
+			BaseDN:         getEnv("LDAP_BASE_DN", ""),
+			UserFilter:     getEnv("LDAP_USER_FILTER", "(mail={login})"),
+			NameAttribute:  getEnv("LDAP_NAME_ATTRIBUTE", "cn"),
+			EmailAttribute: getEnv("LDAP_EMAIL_ATTRIBUTE", "mail"),
+			GroupAttribute: getEnv("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		},
 	}
 
 	// Load numeric and duration values with error handling
@@ -121,15 +303,182 @@ func LoadConfig(logger *zap.Logger) (*Config, error) {
 	if cfg.ServerPort, err = getInt("PORT", 8080); err != nil {
 		return nil, err
 	}
+	cfg.TrustedProxies = getList("TRUSTED_PROXIES", "")
 	if cfg.RSAKeySize, err = getInt("RSA_KEY_SIZE", 2048); err != nil {
 		return nil, err
 	}
 	if cfg.JWKSCacheDuration, err = getDuration("JWKS_CACHE_DURATION", time.Hour); err != nil {
 		return nil, err
 	}
+	if cfg.CIBARequestExpiry, err = getDuration("CIBA_REQUEST_EXPIRY", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.CIBAPollInterval, err = getDuration("CIBA_POLL_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.LockoutThreshold, err = getInt("LOCKOUT_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.LockoutDuration, err = getDuration("LOCKOUT_DURATION", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.LockoutIPThreshold, err = getInt("LOCKOUT_IP_THRESHOLD", 20); err != nil {
+		return nil, err
+	}
+	if cfg.LockoutBackoffBase, err = getDuration("LOCKOUT_BACKOFF_BASE", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.MFAMaxAttempts, err = getInt("MFA_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.MFAEmailCodeTTL, err = getDuration("MFA_EMAIL_CODE_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	// Users with these roles, or signing in to these clients or for these scopes, must use MFA
+	cfg.MFARequiredRoles = getList("MFA_REQUIRED_ROLES", "")
+	cfg.MFARequiredClients = getList("MFA_REQUIRED_CLIENTS", "")
+	cfg.MFARequiredScopes = getList("MFA_REQUIRED_SCOPES", "")
+	if cfg.MFAEnrollmentGracePeriod, err = getDuration("MFA_ENROLLMENT_GRACE_PERIOD", 0); err != nil {
+		return nil, err
+	}
+	// Sensitive account changes need a sign-in this recent and this strong; admin routes may need a stronger one
+	cfg.StepUpACR = getEnv("STEP_UP_ACR", "")
+	if cfg.StepUpMaxAge, err = getDuration("STEP_UP_MAX_AGE", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	cfg.AdminRequiredACR = getEnv("ADMIN_REQUIRED_ACR", "")
+	// Trusted devices skip MFA for this long, except for users with one of the excluded roles
+	if cfg.DeviceTrustDuration, err = getDuration("DEVICE_TRUST_DURATION", 30*24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.DeviceTrustExcludedRoles = getList("DEVICE_TRUST_EXCLUDED_ROLES", "")
+	// Emails about logins from new devices link to this page with a token revoking the login
+	cfg.LoginAlertLinkURL = getEnv("LOGIN_ALERT_LINK_URL", "")
+	if cfg.LoginAlertLinkTTL, err = getDuration("LOGIN_ALERT_LINK_TTL", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.LoginRiskMFA = getEnv("LOGIN_RISK_MFA", "false") == "true"
+	// Codes texted or called to phones; each number gets at most SMS_SEND_LIMIT per SMS_SEND_WINDOW,
+	// each user SMS_USER_SEND_LIMIT across their numbers and each client IP SMS_IP_SEND_LIMIT
+	if cfg.SMSCodeTTL, err = getDuration("SMS_CODE_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.SMSSendLimit, err = getInt("SMS_SEND_LIMIT", 5); err != nil {
+		return nil, err
+	}
+	if cfg.SMSUserSendLimit, err = getInt("SMS_USER_SEND_LIMIT", 10); err != nil {
+		return nil, err
+	}
+	if cfg.SMSIPSendLimit, err = getInt("SMS_IP_SEND_LIMIT", 20); err != nil {
+		return nil, err
+	}
+	if cfg.SMSSendWindow, err = getDuration("SMS_SEND_WINDOW", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.TOTPEnrollmentTTL, err = getDuration("TOTP_ENROLLMENT_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	cfg.TOTPIssuer = getEnv("TOTP_ISSUER", "AuthM")
+	cfg.TOTPAlgorithm = strings.ToUpper(getEnv("TOTP_ALGORITHM", "SHA1"))
+	if cfg.TOTPDigits, err = getInt("TOTP_DIGITS", 6); err != nil {
+		return nil, err
+	}
+	totpPeriod, err := getInt("TOTP_PERIOD", 30)
+	if err != nil {
+		return nil, err
+	}
+	cfg.TOTPPeriod = time.Duration(totpPeriod) * time.Second
+	if cfg.TOTPBackupCodesCount, err = getInt("TOTP_BACKUP_CODES_COUNT", 10); err != nil {
+		return nil, err
+	}
+	if cfg.TOTPBackupCodesThreshold, err = getInt("TOTP_BACKUP_CODES_WARN_THRESHOLD", 3); err != nil {
+		return nil, err
+	}
+	passwordHashMemory, err := getInt("PASSWORD_HASH_MEMORY", 64*1024)
+	if err != nil {
+		return nil, err
+	}
+	passwordHashIterations, err := getInt("PASSWORD_HASH_ITERATIONS", 3)
+	if err != nil {
+		return nil, err
+	}
+	passwordHashParallelism, err := getInt("PASSWORD_HASH_PARALLELISM", 2)
+	if err != nil {
+		return nil, err
+	}
+	if passwordHashMemory <= 0 || passwordHashIterations <= 0 || passwordHashParallelism <= 0 || passwordHashParallelism > 255 {
+		return nil, fmt.Errorf("invalid password hash parameters: m=%d, t=%d, p=%d", passwordHashMemory, passwordHashIterations, passwordHashParallelism)
+	}
+	cfg.PasswordHashMemory = uint32(passwordHashMemory)
+	cfg.PasswordHashIterations = uint32(passwordHashIterations)
+	cfg.PasswordHashParallelism = uint8(passwordHashParallelism)
+
+	if cfg.PasswordMinLength, err = getInt("PASSWORD_MIN_LENGTH", 8); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordMaxLength, err = getInt("PASSWORD_MAX_LENGTH", 72); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordHistorySize, err = getInt("PASSWORD_HISTORY_SIZE", 5); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordlessCodeLength, err = getInt("PASSWORDLESS_CODE_LENGTH", 6); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordlessCodeTTL, err = getDuration("PASSWORDLESS_CODE_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordlessRateLimit, err = getInt("PASSWORDLESS_RATE_LIMIT", 5); err != nil {
+		return nil, err
+	}
+	if cfg.PasswordlessRateWindow, err = getDuration("PASSWORDLESS_RATE_WINDOW", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.WebAuthnTimeout, err = getDuration("WEBAUTHN_TIMEOUT", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	// Passkeys are only accepted from these origins, the server itself by default
+	cfg.WebAuthnOrigins = getList("WEBAUTHN_ORIGINS", cfg.ServerURL)
+	// Upstream identity providers, each configured under FEDERATED_<NAME>_*
+	for _, name := range getList("FEDERATED_PROVIDERS", "") {
+		prefix := "FEDERATED_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cfg.FederatedProviders = append(cfg.FederatedProviders, FederatedProviderConfig{
+			Name:               name,
+			DiscoveryURL:       getEnv(prefix+"DISCOVERY_URL", ""),
+			ClientID:           getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:       getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:             getList(prefix+"SCOPES", "openid,email,profile"),
+			EmailClaim:         getEnv(prefix+"EMAIL_CLAIM", "email"),
+			EmailVerifiedClaim: getEnv(prefix+"EMAIL_VERIFIED_CLAIM", "email_verified"),
+			NameClaim:          getEnv(prefix+"NAME_CLAIM", "name"),
+			TrustEmail:         getEnv(prefix+"TRUST_EMAIL", "false") == "true",
+		})
+	}
+	if cfg.FederatedStateTTL, err = getDuration("FEDERATED_STATE_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.FederatedHTTPTimeout, err = getDuration("FEDERATED_HTTP_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
 	if cfg.SMTP.Port, err = getInt("SMTP_PORT", 1025); err != nil {
 		return nil, err
 	}
+	if cfg.SMS.HTTPTimeout, err = getDuration("SMS_HTTP_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.LDAP.Timeout, err = getDuration("LDAP_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	// Directory users get the default roles and the roles of their groups, listed as group=role
+	cfg.LDAP.DefaultRoles = getList("LDAP_DEFAULT_ROLES", "user")
+	cfg.LDAP.GroupRoles = make(map[string]string)
+	for _, mapping := range getList("LDAP_GROUP_ROLES", "") {
+		group, role, ok := strings.Cut(mapping, "=")
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("invalid group to role mapping in LDAP_GROUP_ROLES: %q", mapping)
+		}
+		cfg.LDAP.GroupRoles[strings.ToLower(group)] = role
+	}
 
 	if err := cfg.Validate(); err != nil {
 		logger.Error("Invalid configuration", zap.Error(err))
@@ -140,6 +489,9 @@ func LoadConfig(logger *zap.Logger) (*Config, error) {
 	return cfg, nil
 }
 
+// federatedProviderName restricts provider names to what fits in a URL path and an env var name
+var federatedProviderName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -158,9 +510,147 @@ func (c *Config) Validate() error {
 	if c.ServerPort <= 0 || c.ServerPort > 65535 {
 		return fmt.Errorf("ServerPort must be valid: got %d", c.ServerPort)
 	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("TrustedProxies must be IP addresses or CIDR ranges: got %q", proxy)
+		}
+	}
 	if c.SMTP.Port <= 0 || c.SMTP.Port > 65535 {
 		return fmt.Errorf("SMTPPort must be valid: got %d", c.SMTP.Port)
 	}
+	if c.LockoutThreshold <= 0 || c.LockoutIPThreshold <= 0 {
+		return errors.New("lockout thresholds must be positive")
+	}
+	if c.LockoutDuration <= 0 {
+		return errors.New("LockoutDuration must be positive")
+	}
+	if c.MFAMaxAttempts <= 0 {
+		return fmt.Errorf("MFAMaxAttempts must be positive: got %d", c.MFAMaxAttempts)
+	}
+	if c.MFAEmailCodeTTL <= 0 {
+		return errors.New("MFAEmailCodeTTL must be positive")
+	}
+	if c.MFAEnrollmentGracePeriod < 0 {
+		return fmt.Errorf("MFAEnrollmentGracePeriod must not be negative: got %s", c.MFAEnrollmentGracePeriod)
+	}
+	if c.StepUpMaxAge < 0 {
+		return fmt.Errorf("StepUpMaxAge must not be negative: got %s", c.StepUpMaxAge)
+	}
+	if c.DeviceTrustDuration < 0 {
+		return fmt.Errorf("DeviceTrustDuration must not be negative: got %s", c.DeviceTrustDuration)
+	}
+	if c.LoginAlertLinkTTL <= 0 {
+		return errors.New("LoginAlertLinkTTL must be positive")
+	}
+	if c.SMSCodeTTL <= 0 || c.SMSSendLimit <= 0 || c.SMSUserSendLimit <= 0 || c.SMSIPSendLimit <= 0 || c.SMSSendWindow <= 0 {
+		return errors.New("SMS code TTL and send limits must be positive")
+	}
+	switch c.SMS.Provider {
+	case "log":
+	case "http":
+		if c.SMS.HTTPURL == "" || c.SMS.HTTPTimeout <= 0 {
+			return errors.New("the http SMS provider needs an HTTPURL and a positive HTTPTimeout")
+		}
+	default:
+		return fmt.Errorf("SMSProvider must be log or http: got %q", c.SMS.Provider)
+	}
+	if c.FederatedStateTTL <= 0 || c.FederatedHTTPTimeout <= 0 {
+		return errors.New("FederatedStateTTL and FederatedHTTPTimeout must be positive")
+	}
+	names := make(map[string]bool, len(c.FederatedProviders))
+	for _, provider := range c.FederatedProviders {
+		if !federatedProviderName.MatchString(provider.Name) || names[provider.Name] {
+			return fmt.Errorf("federated provider names must be unique lowercase letters, digits and dashes: got %q", provider.Name)
+		}
+		names[provider.Name] = true
+		if provider.Name == "ldap" {
+			return errors.New(`the federated provider name "ldap" is reserved for the directory`)
+		}
+		if provider.DiscoveryURL == "" || provider.ClientID == "" {
+			return fmt.Errorf("federated provider %q needs a discovery URL and a client ID", provider.Name)
+		}
+		if !slices.Contains(provider.Scopes, "openid") {
+			return fmt.Errorf("federated provider %q must request the openid scope", provider.Name)
+		}
+		if provider.EmailClaim == "" {
+			return fmt.Errorf("federated provider %q needs an email claim", provider.Name)
+		}
+	}
+	if c.LDAP.URL != "" {
+		if !strings.HasPrefix(c.LDAP.URL, "ldap://") && !strings.HasPrefix(c.LDAP.URL, "ldaps://") {
+			return fmt.Errorf("LDAPURL must be an ldap:// or ldaps:// URL: got %q", c.LDAP.URL)
+		}
+		if c.LDAP.StartTLS && strings.HasPrefix(c.LDAP.URL, "ldaps://") {
+			return errors.New("LDAP StartTLS cannot be used with an ldaps:// URL")
+		}
+		if c.LDAP.Timeout <= 0 {
+			return fmt.Errorf("LDAPTimeout must be positive: got %s", c.LDAP.Timeout)
+		}
+		if (c.LDAP.BindDNTemplate == "") == (c.LDAP.BaseDN == "") {
+			return errors.New("LDAP needs either a bind DN template or a base DN to search users in")
+		}
+		if c.LDAP.BindDNTemplate != "" && !strings.Contains(c.LDAP.BindDNTemplate, "{login}") {
+			return errors.New("LDAP bind DN template must contain {login}")
+		}
+		if c.LDAP.BaseDN != "" && !strings.Contains(c.LDAP.UserFilter, "{login}") {
+			return errors.New("LDAP user filter must contain {login}")
+		}
+		if c.LDAP.EmailAttribute == "" {
+			return errors.New("LDAP email attribute must be set")
+		}
+	}
+	if c.TOTPEnrollmentTTL <= 0 {
+		return errors.New("TOTPEnrollmentTTL must be positive")
+	}
+	if c.TOTPIssuer == "" || strings.Contains(c.TOTPIssuer, ":") {
+		return fmt.Errorf("TOTPIssuer must be set and cannot contain a colon: got %q", c.TOTPIssuer)
+	}
+	if c.TOTPAlgorithm != "SHA1" && c.TOTPAlgorithm != "SHA256" && c.TOTPAlgorithm != "SHA512" {
+		return fmt.Errorf("TOTPAlgorithm must be SHA1, SHA256 or SHA512: got %q", c.TOTPAlgorithm)
+	}
+	if c.TOTPDigits != 6 && c.TOTPDigits != 8 {
+		return fmt.Errorf("TOTPDigits must be 6 or 8: got %d", c.TOTPDigits)
+	}
+	if c.TOTPPeriod < time.Second {
+		return fmt.Errorf("TOTPPeriod must be at least one second: got %s", c.TOTPPeriod)
+	}
+	if c.TOTPBackupCodesCount <= 0 {
+		return fmt.Errorf("TOTPBackupCodesCount must be positive: got %d", c.TOTPBackupCodesCount)
+	}
+	if c.TOTPBackupCodesThreshold < 0 || c.TOTPBackupCodesThreshold >= c.TOTPBackupCodesCount {
+		return fmt.Errorf("TOTPBackupCodesThreshold must be between 0 and TOTPBackupCodesCount - 1: got %d", c.TOTPBackupCodesThreshold)
+	}
+	if c.SecretKeyProvider != "local" && c.SecretKeyProvider != "vault" {
+		return fmt.Errorf("SecretKeyProvider must be local or vault: got %q", c.SecretKeyProvider)
+	}
+	if c.SecretEncryptionKey != "" {
+		if key, err := base64.StdEncoding.DecodeString(c.SecretEncryptionKey); err != nil || len(key) != 32 {
+			return errors.New("SecretEncryptionKey must be 32 bytes encoded in base64")
+		}
+	}
+	if c.PasswordMinLength <= 0 || c.PasswordMaxLength < c.PasswordMinLength {
+		return fmt.Errorf("password length limits must be positive and ordered: got %d-%d", c.PasswordMinLength, c.PasswordMaxLength)
+	}
+	if c.PasswordHistorySize < 0 {
+		return fmt.Errorf("PasswordHistorySize must not be negative: got %d", c.PasswordHistorySize)
+	}
+	if c.BreachedPasswordPolicy != "reject" && c.BreachedPasswordPolicy != "warn" {
+		return fmt.Errorf("BreachedPasswordPolicy must be reject or warn: got %q", c.BreachedPasswordPolicy)
+	}
+	if c.PasswordlessEnabled {
+		if c.PasswordlessCodeLength < 6 || c.PasswordlessCodeLength > 10 {
+			return fmt.Errorf("PasswordlessCodeLength must be between 6 and 10: got %d", c.PasswordlessCodeLength)
+		}
+		if c.PasswordlessCodeTTL <= 0 || c.PasswordlessRateLimit <= 0 || c.PasswordlessRateWindow <= 0 {
+			return errors.New("passwordless code TTL and rate limits must be positive")
+		}
+	}
+	if c.WebAuthnRPID == "" || len(c.WebAuthnOrigins) == 0 {
+		return errors.New("WebAuthn relying party ID and origins must be set")
+	}
+	if c.WebAuthnTimeout <= 0 {
+		return fmt.Errorf("WebAuthnTimeout must be positive: got %s", c.WebAuthnTimeout)
+	}
 	if c.RSAKeySize < 2048 {
 		return fmt.Errorf("RSAKeySize must be at least 2048 bits: got %d", c.RSAKeySize)
 	}
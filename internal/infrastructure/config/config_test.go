@@ -68,6 +68,141 @@ func TestLoadConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "short secret encryption key",
+			setup: func() {
+				os.Setenv("SECRET_ENCRYPTION_KEY", "c2hvcnQ=")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported totp algorithm",
+			setup: func() {
+				os.Setenv("TOTP_ALGORITHM", "MD5")
+			},
+			wantErr: true,
+		},
+		{
+			name: "totp issuer with a colon",
+			setup: func() {
+				os.Setenv("TOTP_ISSUER", "Acme:Auth")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsupported totp digits",
+			setup: func() {
+				os.Setenv("TOTP_DIGITS", "7")
+			},
+			wantErr: true,
+		},
+		{
+			name: "backup code warning threshold not below count",
+			setup: func() {
+				os.Setenv("TOTP_BACKUP_CODES_COUNT", "5")
+				os.Setenv("TOTP_BACKUP_CODES_WARN_THRESHOLD", "5")
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative mfa enrolment grace period",
+			setup: func() {
+				os.Setenv("MFA_ENROLLMENT_GRACE_PERIOD", "-1h")
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative step-up max age",
+			setup: func() {
+				os.Setenv("STEP_UP_MAX_AGE", "-15m")
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative device trust duration",
+			setup: func() {
+				os.Setenv("DEVICE_TRUST_DURATION", "-1h")
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero login alert link TTL",
+			setup: func() {
+				os.Setenv("LOGIN_ALERT_LINK_TTL", "0s")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown sms provider",
+			setup: func() {
+				os.Setenv("SMS_PROVIDER", "carrier-pigeon")
+			},
+			wantErr: true,
+		},
+		{
+			name: "http sms provider without url",
+			setup: func() {
+				os.Setenv("SMS_PROVIDER", "http")
+			},
+			wantErr: true,
+		},
+		{
+			name: "federated provider without client id",
+			setup: func() {
+				os.Setenv("FEDERATED_PROVIDERS", "corp")
+				os.Setenv("FEDERATED_CORP_DISCOVERY_URL", "https://idp.example.com/.well-known/openid-configuration")
+			},
+			wantErr: true,
+		},
+		{
+			name: "federated provider without the openid scope",
+			setup: func() {
+				os.Setenv("FEDERATED_PROVIDERS", "corp")
+				os.Setenv("FEDERATED_CORP_DISCOVERY_URL", "https://idp.example.com/.well-known/openid-configuration")
+				os.Setenv("FEDERATED_CORP_CLIENT_ID", "authm")
+				os.Setenv("FEDERATED_CORP_SCOPES", "email,profile")
+			},
+			wantErr: true,
+		},
+		{
+			name: "federated provider named like the directory",
+			setup: func() {
+				os.Setenv("FEDERATED_PROVIDERS", "ldap")
+				os.Setenv("FEDERATED_LDAP_DISCOVERY_URL", "https://idp.example.com/.well-known/openid-configuration")
+				os.Setenv("FEDERATED_LDAP_CLIENT_ID", "authm")
+			},
+			wantErr: true,
+		},
+		{
+			name: "ldap without a bind dn template or base dn",
+			setup: func() {
+				os.Setenv("LDAP_URL", "ldap://directory.example.com")
+			},
+			wantErr: true,
+		},
+		{
+			name: "ldap start tls over ldaps",
+			setup: func() {
+				os.Setenv("LDAP_URL", "ldaps://directory.example.com")
+				os.Setenv("LDAP_START_TLS", "true")
+				os.Setenv("LDAP_BIND_DN_TEMPLATE", "uid={login},ou=people,dc=example,dc=com")
+			},
+			wantErr: true,
+		},
+		{
+			name: "ldap group role mapping without a role",
+			setup: func() {
+				os.Setenv("LDAP_GROUP_ROLES", "admins")
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown secret key provider",
+			setup: func() {
+				os.Setenv("SECRET_KEY_PROVIDER", "kms")
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -79,6 +214,29 @@ func TestLoadConfig(t *testing.T) {
 			os.Setenv("PORT", "8080")
 			os.Setenv("RSA_KEY_SIZE", "2048")
 			os.Setenv("SMTP_PORT", "1025")
+			os.Unsetenv("SECRET_ENCRYPTION_KEY")
+			os.Unsetenv("SECRET_KEY_PROVIDER")
+			os.Unsetenv("MFA_ENROLLMENT_GRACE_PERIOD")
+			os.Unsetenv("STEP_UP_MAX_AGE")
+			os.Unsetenv("DEVICE_TRUST_DURATION")
+			os.Unsetenv("LOGIN_ALERT_LINK_TTL")
+			os.Unsetenv("SMS_PROVIDER")
+			os.Unsetenv("SMS_HTTP_URL")
+			os.Unsetenv("FEDERATED_PROVIDERS")
+			os.Unsetenv("FEDERATED_CORP_DISCOVERY_URL")
+			os.Unsetenv("FEDERATED_CORP_CLIENT_ID")
+			os.Unsetenv("FEDERATED_CORP_SCOPES")
+			os.Unsetenv("FEDERATED_LDAP_DISCOVERY_URL")
+			os.Unsetenv("FEDERATED_LDAP_CLIENT_ID")
+			os.Unsetenv("LDAP_URL")
+			os.Unsetenv("LDAP_START_TLS")
+			os.Unsetenv("LDAP_BIND_DN_TEMPLATE")
+			os.Unsetenv("LDAP_GROUP_ROLES")
+			os.Unsetenv("TOTP_ISSUER")
+			os.Unsetenv("TOTP_ALGORITHM")
+			os.Unsetenv("TOTP_DIGITS")
+			os.Unsetenv("TOTP_BACKUP_CODES_COUNT")
+			os.Unsetenv("TOTP_BACKUP_CODES_WARN_THRESHOLD")
 
 			// Run test-specific setup
 			tt.setup()
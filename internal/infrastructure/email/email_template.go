@@ -2,6 +2,9 @@ package email
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/manorfm/authM/internal/domain"
 	"github.com/manorfm/authM/internal/infrastructure/config"
@@ -60,3 +63,172 @@ The Team
 `
 	return s.emailSender.Send(ctx, email, subject, template, code)
 }
+
+func (s *EmailTemplate) SendAccountLockedEmail(ctx context.Context, email string, lockedUntil time.Time) error {
+	subject := "Your account has been temporarily locked"
+	template := `
+Hi there,
+
+We noticed several failed sign-in attempts on your account, so we've locked it temporarily to keep it safe.
+
+You can try again after:
+%s
+
+If these attempts weren't you, we recommend resetting your password once the lock expires.
+
+Stay secure,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, lockedUntil.UTC().Format(time.RFC1123))
+}
+
+func (s *EmailTemplate) SendEmailChangeEmail(ctx context.Context, email, code string) error {
+	subject := "Confirm your new email address"
+	template := `
+Hi there! 👋
+
+We received a request to use this address for your account. To confirm it, please use this code:
+%s
+
+This code will expire in 1 hour.
+
+If you didn't request this change, you can safely ignore this email.
+
+Best regards,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, code)
+}
+
+func (s *EmailTemplate) SendEmailChangeNoticeEmail(ctx context.Context, email, newEmail string) error {
+	subject := "A change to your email address was requested"
+	template := `
+Hi there,
+
+We received a request to change the email address of your account to:
+%s
+
+The change only takes effect once it is confirmed from the new address.
+
+If this wasn't you, please change your password right away and contact support.
+
+Stay secure,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, newEmail)
+}
+
+func (s *EmailTemplate) SendPasswordlessCodeEmail(ctx context.Context, email, code string) error {
+	subject := "Your sign-in code"
+	template := `
+Hi there! 👋
+
+Use this code to sign in:
+%s
+
+The code can be used once and expires shortly.
+
+If you didn't try to sign in, you can safely ignore this email.
+
+Stay secure,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, code)
+}
+
+func (s *EmailTemplate) SendMagicLinkEmail(ctx context.Context, email, link string) error {
+	subject := "Your sign-in link"
+	template := `
+Hi there! 👋
+
+Open this link to sign in:
+%s
+
+The link can be used once and expires shortly.
+
+If you didn't try to sign in, you can safely ignore this email.
+
+Stay secure,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, link)
+}
+
+func (s *EmailTemplate) SendMFACodeEmail(ctx context.Context, email, code string) error {
+	subject := "Your verification code"
+	template := `
+Hi there! 👋
+
+Your password was accepted. To finish signing in, enter this verification code:
+%s
+
+The code can be used once and expires shortly.
+
+If you didn't try to sign in, someone may know your password. Please change it right away.
+
+Stay secure,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, code)
+}
+
+func (s *EmailTemplate) SendBackupCodesLowEmail(ctx context.Context, email string, remaining int) error {
+	subject := "You are running out of backup codes"
+	template := `
+Hi there,
+
+You just signed in with a backup code. Backup codes left on your account:
+%s
+
+Once they run out, losing your authenticator app could lock you out of your account. You can generate a new set from your security settings at any time; it replaces the codes you have left.
+
+If you didn't use a backup code, someone may have access to them. Please generate a new set and change your password right away.
+
+Stay secure,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, strconv.Itoa(remaining))
+}
+
+func (s *EmailTemplate) SendMFAResetEmail(ctx context.Context, email string, resetAt time.Time) error {
+	subject := "Your two-step verification was reset"
+	template := `
+Hi there,
+
+After verifying your identity, an administrator removed every two-step verification method from your account on:
+%s
+
+Your authenticator apps, passkeys and backup codes no longer work. Please set up two-step verification again as soon as you sign in.
+
+If you didn't ask for this, please contact support right away.
+
+Stay secure,
+The Team
+`
+	return s.emailSender.Send(ctx, email, subject, template, resetAt.UTC().Format(time.RFC1123))
+}
+
+func (s *EmailTemplate) SendNewSignInEmail(ctx context.Context, email string, event *domain.LoginEvent, revokeLink string) error {
+	subject := "New sign-in to your account"
+	template := `
+Hi there,
+
+Your account was just signed in to from a device we haven't seen before:
+%s
+
+If this was you, there is nothing to do.
+
+If this wasn't you, please change your password right away and contact support.
+
+Stay secure,
+The Team
+`
+	details := fmt.Sprintf("Time: %s\nDevice: %s", event.CreatedAt.UTC().Format(time.RFC1123), event.Device)
+	if event.ClientIP != "" {
+		details += "\nIP address: " + event.ClientIP
+	}
+	if revokeLink != "" {
+		details += "\n\nTo sign this login out along with every other session and trusted device, open:\n" + revokeLink
+	}
+	return s.emailSender.Send(ctx, email, subject, template, details)
+}
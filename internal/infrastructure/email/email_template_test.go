@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/manorfm/authM/internal/domain"
 	"github.com/manorfm/authM/internal/infrastructure/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -166,3 +168,129 @@ func TestEmailTemplate_SendPasswordResetEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestEmailTemplate_SendAccountLockedEmail(t *testing.T) {
+	lockedUntil := time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC)
+
+	mockEmailService := new(MockEmailSender)
+	mockEmailService.On("Send",
+		mock.Anything,
+		"test@example.com",
+		"Your account has been temporarily locked",
+		mock.Anything,
+		"Fri, 14 Mar 2025 10:30:00 UTC",
+	).Return(nil)
+
+	logger, _ := zap.NewDevelopment()
+	template := &EmailTemplate{
+		config:      &config.SMTPConfig{},
+		logger:      logger,
+		emailSender: mockEmailService,
+	}
+
+	err := template.SendAccountLockedEmail(context.Background(), "test@example.com", lockedUntil)
+
+	assert.NoError(t, err)
+	mockEmailService.AssertExpectations(t)
+}
+
+func TestEmailTemplate_SendEmailChangeNoticeEmail(t *testing.T) {
+	mockEmailService := new(MockEmailSender)
+	mockEmailService.On("Send",
+		mock.Anything,
+		"old@example.com",
+		"A change to your email address was requested",
+		mock.Anything,
+		"new@example.com",
+	).Return(nil)
+
+	logger, _ := zap.NewDevelopment()
+	template := &EmailTemplate{
+		config:      &config.SMTPConfig{},
+		logger:      logger,
+		emailSender: mockEmailService,
+	}
+
+	err := template.SendEmailChangeNoticeEmail(context.Background(), "old@example.com", "new@example.com")
+
+	assert.NoError(t, err)
+	mockEmailService.AssertExpectations(t)
+}
+
+func TestEmailTemplate_SendBackupCodesLowEmail(t *testing.T) {
+	mockEmailService := new(MockEmailSender)
+	mockEmailService.On("Send",
+		mock.Anything,
+		"test@example.com",
+		"You are running out of backup codes",
+		mock.Anything,
+		"2",
+	).Return(nil)
+
+	logger, _ := zap.NewDevelopment()
+	template := &EmailTemplate{
+		config:      &config.SMTPConfig{},
+		logger:      logger,
+		emailSender: mockEmailService,
+	}
+
+	err := template.SendBackupCodesLowEmail(context.Background(), "test@example.com", 2)
+
+	assert.NoError(t, err)
+	mockEmailService.AssertExpectations(t)
+}
+
+func TestEmailTemplate_SendMFAResetEmail(t *testing.T) {
+	resetAt := time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC)
+
+	mockEmailService := new(MockEmailSender)
+	mockEmailService.On("Send",
+		mock.Anything,
+		"test@example.com",
+		"Your two-step verification was reset",
+		mock.Anything,
+		"Fri, 14 Mar 2025 10:30:00 UTC",
+	).Return(nil)
+
+	logger, _ := zap.NewDevelopment()
+	template := &EmailTemplate{
+		config:      &config.SMTPConfig{},
+		logger:      logger,
+		emailSender: mockEmailService,
+	}
+
+	err := template.SendMFAResetEmail(context.Background(), "test@example.com", resetAt)
+
+	assert.NoError(t, err)
+	mockEmailService.AssertExpectations(t)
+}
+
+func TestEmailTemplate_SendNewSignInEmail(t *testing.T) {
+	event := &domain.LoginEvent{
+		ClientIP:  "203.0.113.7",
+		Device:    "Firefox on Linux",
+		CreatedAt: time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC),
+	}
+
+	mockEmailService := new(MockEmailSender)
+	mockEmailService.On("Send",
+		mock.Anything,
+		"test@example.com",
+		"New sign-in to your account",
+		mock.Anything,
+		"Time: Fri, 14 Mar 2025 10:30:00 UTC\nDevice: Firefox on Linux\nIP address: 203.0.113.7\n\n"+
+			"To sign this login out along with every other session and trusted device, open:\nhttps://app.example.com/revoke?token=abc",
+	).Return(nil)
+
+	logger, _ := zap.NewDevelopment()
+	template := &EmailTemplate{
+		config:      &config.SMTPConfig{},
+		logger:      logger,
+		emailSender: mockEmailService,
+	}
+
+	err := template.SendNewSignInEmail(context.Background(), "test@example.com", event, "https://app.example.com/revoke?token=abc")
+
+	assert.NoError(t, err)
+	mockEmailService.AssertExpectations(t)
+}
@@ -0,0 +1,339 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// clockSkew is how far the clocks of the provider and the server may drift apart
+const clockSkew = time.Minute
+
+// maxResponseSize bounds the discovery documents, key sets and token responses read from providers
+const maxResponseSize = 256 << 10
+
+// keyRefreshInterval is how long after fetching the signing keys a token signed with an unknown
+// key is rejected without fetching them again
+const keyRefreshInterval = time.Minute
+
+// signatureAlgorithms are the ID token signature algorithms accepted from providers
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+}
+
+// discoveryDocument holds the parts of a provider's OpenID configuration used to sign users in
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider signs users in at an upstream OpenID Connect provider with the authorization code flow
+// and PKCE. The discovery document is fetched on first use, and the signing keys are refetched,
+// at most once every keyRefreshInterval, when an ID token is signed with a key they lack, as the
+// provider may have rotated its keys.
+type Provider struct {
+	config      config.FederatedProviderConfig
+	redirectURL string
+	httpClient  *http.Client
+	logger      *zap.Logger
+
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          *jose.JSONWebKeySet
+	keysFetchedAt time.Time
+}
+
+// NewIdentityProviders creates the configured upstream providers, which send users back to
+// /api/auth/federated/{name}/callback on the server
+func NewIdentityProviders(cfg *config.Config, logger *zap.Logger) []domain.IdentityProvider {
+	providers := make([]domain.IdentityProvider, 0, len(cfg.FederatedProviders))
+	for _, providerCfg := range cfg.FederatedProviders {
+		redirectURL := strings.TrimRight(cfg.ServerURL, "/") + "/api/auth/federated/" + providerCfg.Name + "/callback"
+		providers = append(providers, NewProvider(providerCfg, redirectURL, cfg.FederatedHTTPTimeout, logger))
+	}
+	return providers
+}
+
+// NewProvider creates an upstream provider sending users back to redirectURL
+func NewProvider(cfg config.FederatedProviderConfig, redirectURL string, timeout time.Duration, logger *zap.Logger) *Provider {
+	return &Provider{
+		config:      cfg,
+		redirectURL: redirectURL,
+		httpClient:  &http.Client{Timeout: timeout},
+		logger:      logger.With(zap.String("provider", cfg.Name)),
+	}
+}
+
+// Name is the name the provider is configured and routed under
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthorizationURL returns the URL of the provider's authorization endpoint for a new sign-in
+func (p *Provider) AuthorizationURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.redirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+
+	return authURL.String(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint and verifies the ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*domain.UpstreamClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	// Public clients identify themselves in the body, confidential ones with HTTP Basic
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected status %d from token endpoint: %s", resp.StatusCode, body)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response carries no ID token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, discovery, tokens.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return p.mapClaims(claims)
+}
+
+// verifyIDToken checks the signature of the ID token and its issuer, audience, expiry and nonce,
+// and returns its claims
+func (p *Provider) verifyIDToken(ctx context.Context, discovery *discoveryDocument, rawIDToken, nonce string) (map[string]any, error) {
+	token, err := jose.ParseSigned(rawIDToken, signatureAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token: %w", err)
+	}
+	if len(token.Signatures) != 1 {
+		return nil, errors.New("ID token must carry exactly one signature")
+	}
+
+	key, err := p.signingKey(ctx, discovery, token.Signatures[0].Header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := token.Verify(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token signature: %w", err)
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed ID token claims: %w", err)
+	}
+
+	if iss, _ := claims["iss"].(string); iss != discovery.Issuer {
+		return nil, fmt.Errorf("ID token issued by %q instead of %q", iss, discovery.Issuer)
+	}
+	audiences := stringList(claims["aud"])
+	if !slices.Contains(audiences, p.config.ClientID) {
+		return nil, errors.New("ID token not issued to this client")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, errors.New("ID token authorized for another party")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || time.Now().Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("ID token expired")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("ID token nonce does not match")
+	}
+
+	return claims, nil
+}
+
+// mapClaims reads the user's identity out of the ID token claims with the claim mapping
+func (p *Provider) mapClaims(claims map[string]any) (*domain.UpstreamClaims, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("ID token carries no subject")
+	}
+
+	upstream := &domain.UpstreamClaims{Subject: subject}
+	upstream.Email, _ = claims[p.config.EmailClaim].(string)
+	upstream.Name, _ = claims[p.config.NameClaim].(string)
+
+	// Some providers send email_verified as a string
+	switch verified := claims[p.config.EmailVerifiedClaim].(type) {
+	case bool:
+		upstream.EmailVerified = verified
+	case string:
+		upstream.EmailVerified = verified == "true"
+	}
+	if p.config.TrustEmail && upstream.Email != "" {
+		upstream.EmailVerified = true
+	}
+
+	return upstream, nil
+}
+
+// discover returns the provider's OpenID configuration, fetching it on first use
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	discovery := p.discovery
+	p.mu.Unlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	discovery = &discoveryDocument{}
+	if err := p.getJSON(ctx, p.config.DiscoveryURL, discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer == "" || discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("discovery document lacks the issuer, an endpoint or the jwks_uri")
+	}
+
+	p.mu.Lock()
+	p.discovery = discovery
+	p.mu.Unlock()
+
+	p.logger.Info("Identity provider discovered", zap.String("issuer", discovery.Issuer))
+	return discovery, nil
+}
+
+// signingKey returns the key with the ID, refetching the key set once when the cached one lacks it
+// and was not fetched within keyRefreshInterval
+func (p *Provider) signingKey(ctx context.Context, discovery *discoveryDocument, keyID string) (*jose.JSONWebKey, error) {
+	for _, refresh := range []bool{false, true} {
+		keys, err := p.keySet(ctx, discovery, refresh)
+		if err != nil {
+			return nil, err
+		}
+		for i := range keys.Keys {
+			key := &keys.Keys[i]
+			if (keyID == "" || key.KeyID == keyID) && key.Use != "enc" {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no signing key %q", keyID)
+}
+
+func (p *Provider) keySet(ctx context.Context, discovery *discoveryDocument, refresh bool) (*jose.JSONWebKeySet, error) {
+	p.mu.Lock()
+	keys, fetchedAt := p.keys, p.keysFetchedAt
+	p.mu.Unlock()
+	// Tokens naming unknown keys do not make every sign-in fetch the keys again
+	if keys != nil && (!refresh || time.Since(fetchedAt) < keyRefreshInterval) {
+		return keys, nil
+	}
+
+	keys = &jose.JSONWebKeySet{}
+	if err := p.getJSON(ctx, discovery.JWKSURI, keys); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	p.mu.Unlock()
+	return keys, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d fetching %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// stringList reads a claim that is either a string or an array of strings
+func stringList(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
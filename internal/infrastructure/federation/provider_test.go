@@ -0,0 +1,253 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubIdP is a minimal OpenID Connect provider issuing ID tokens for a single authorization code
+type stubIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+	// challenge is the PKCE code challenge the code was issued with
+	challenge string
+	// keyFetches counts the requests for the key set
+	keyFetches int
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	idp := &stubIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.keyFetches++
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "stub-key", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || clientID != "authm" || secret != "s3cret" || r.PostFormValue("code") != "stub-code" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "stub-access-token",
+			"token_type":   "Bearer",
+			"id_token":     idp.sign(t, idp.claims),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *stubIdP) sign(t *testing.T, claims map[string]any) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: idp.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "stub-key").WithType("JWT"))
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	object, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := object.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func (idp *stubIdP) provider() *Provider {
+	return NewProvider(config.FederatedProviderConfig{
+		Name:               "stub",
+		DiscoveryURL:       idp.URL + "/.well-known/openid-configuration",
+		ClientID:           "authm",
+		ClientSecret:       "s3cret",
+		Scopes:             []string{"openid", "email", "profile"},
+		EmailClaim:         "email",
+		EmailVerifiedClaim: "email_verified",
+		NameClaim:          "name",
+	}, "http://localhost:8080/api/auth/federated/stub/callback", time.Second, zap.NewNop())
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestProvider_AuthorizationURL(t *testing.T) {
+	idp := newStubIdP(t)
+
+	authURL, err := idp.provider().AuthorizationURL(context.Background(), "state-1", "nonce-1", "challenge-1")
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, idp.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	query := parsed.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "authm", query.Get("client_id"))
+	assert.Equal(t, "http://localhost:8080/api/auth/federated/stub/callback", query.Get("redirect_uri"))
+	assert.Equal(t, "openid email profile", query.Get("scope"))
+	assert.Equal(t, "state-1", query.Get("state"))
+	assert.Equal(t, "nonce-1", query.Get("nonce"))
+	assert.Equal(t, "challenge-1", query.Get("code_challenge"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	idp := newStubIdP(t)
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":            idp.URL,
+			"sub":            "upstream-42",
+			"aud":            "authm",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          "nonce-1",
+			"email":          "jane@example.com",
+			"email_verified": true,
+			"name":           "Jane Doe",
+		}
+	}
+
+	tests := []struct {
+		name          string
+		claims        func(map[string]any)
+		verifier      string
+		expectedError bool
+	}{
+		{name: "valid ID token", claims: func(map[string]any) {}},
+		{name: "audience list", claims: func(c map[string]any) { c["aud"] = []string{"other", "authm"}; c["azp"] = "authm" }},
+		{name: "email verified as a string", claims: func(c map[string]any) { c["email_verified"] = "true" }},
+		{name: "wrong code verifier", claims: func(map[string]any) {}, verifier: "wrong", expectedError: true},
+		{name: "other issuer", claims: func(c map[string]any) { c["iss"] = "https://evil.example.com" }, expectedError: true},
+		{name: "other audience", claims: func(c map[string]any) { c["aud"] = "other" }, expectedError: true},
+		{name: "expired", claims: func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, expectedError: true},
+		{name: "replayed nonce", claims: func(c map[string]any) { c["nonce"] = "nonce-0" }, expectedError: true},
+		{name: "no subject", claims: func(c map[string]any) { delete(c, "sub") }, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.claims = validClaims()
+			tt.claims(idp.claims)
+			idp.challenge = codeChallenge("verifier-1")
+			verifier := "verifier-1"
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+
+			claims, err := idp.provider().Exchange(context.Background(), "stub-code", verifier, "nonce-1")
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "upstream-42", claims.Subject)
+			assert.Equal(t, "jane@example.com", claims.Email)
+			assert.True(t, claims.EmailVerified)
+			assert.Equal(t, "Jane Doe", claims.Name)
+		})
+	}
+}
+
+func TestProvider_Exchange_ForgedSignature(t *testing.T) {
+	idp := newStubIdP(t)
+	idp.challenge = codeChallenge("verifier-1")
+	idp.claims = map[string]any{"iss": idp.URL, "sub": "upstream-42", "aud": "authm", "exp": time.Now().Add(time.Hour).Unix(), "nonce": "nonce-1"}
+
+	// The provider rotated to a key the token was not signed with
+	provider := idp.provider()
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	genuine := idp.key
+	idp.key = forger
+	defer func() { idp.key = genuine }()
+
+	_, err = provider.Exchange(context.Background(), "stub-code", "verifier-1", "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestProvider_SigningKey_Throttled(t *testing.T) {
+	idp := newStubIdP(t)
+	provider := idp.provider()
+	discovery, err := provider.discover(context.Background())
+	require.NoError(t, err)
+
+	_, err = provider.signingKey(context.Background(), discovery, "stub-key")
+	require.NoError(t, err)
+	assert.Equal(t, 1, idp.keyFetches)
+
+	// Unknown keys only refetch the key set once it is older than the refresh interval
+	for range 3 {
+		_, err = provider.signingKey(context.Background(), discovery, "forged-key")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 1, idp.keyFetches)
+
+	provider.keysFetchedAt = time.Now().Add(-keyRefreshInterval)
+	_, err = provider.signingKey(context.Background(), discovery, "forged-key")
+	assert.Error(t, err)
+	assert.Equal(t, 2, idp.keyFetches)
+}
+
+func TestProvider_OversizedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"issuer":"`))
+		w.Write(bytes.Repeat([]byte("a"), maxResponseSize))
+		w.Write([]byte(`"}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(config.FederatedProviderConfig{
+		Name:         "oversized",
+		DiscoveryURL: server.URL,
+	}, "", time.Second, zap.NewNop())
+
+	_, err := provider.discover(context.Background())
+	assert.Error(t, err)
+}
+
+func TestProvider_MapClaims(t *testing.T) {
+	provider := NewProvider(config.FederatedProviderConfig{
+		Name:       "corp",
+		EmailClaim: "upn",
+		NameClaim:  "display_name",
+		TrustEmail: true,
+	}, "", time.Second, zap.NewNop())
+
+	claims, err := provider.mapClaims(map[string]any{"sub": "42", "upn": "jane@corp.example.com", "display_name": "Jane"})
+	require.NoError(t, err)
+	assert.Equal(t, "jane@corp.example.com", claims.Email)
+	assert.True(t, claims.EmailVerified, "emails of trusted providers are verified")
+	assert.Equal(t, "Jane", claims.Name)
+}
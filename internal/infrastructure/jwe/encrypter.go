@@ -0,0 +1,153 @@
+package jwe
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// maxKeySetSize bounds the key set read from a client's jwks_uri
+const maxKeySetSize = 1 << 20
+
+// encrypter implements domain.TokenEncrypter using keys published at client jwks_uri endpoints
+type encrypter struct {
+	httpClient    *http.Client
+	cacheDuration time.Duration
+	logger        *zap.Logger
+
+	mu    sync.Mutex
+	cache map[string]*cachedKeySet
+}
+
+type cachedKeySet struct {
+	keys      jose.JSONWebKeySet
+	fetchedAt time.Time
+}
+
+// NewEncrypter creates a new token encrypter
+func NewEncrypter(cfg *config.Config, logger *zap.Logger) domain.TokenEncrypter {
+	return &encrypter{
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		cacheDuration: cfg.JWKSCacheDuration,
+		logger:        logger,
+		cache:         make(map[string]*cachedKeySet),
+	}
+}
+
+// Encrypt wraps the token in a compact JWE using an RSA encryption key fetched from jwksURI
+func (e *encrypter) Encrypt(ctx context.Context, jwksURI, alg, enc, token string) (string, error) {
+	key, err := e.encryptionKey(ctx, jwksURI, alg)
+	if err != nil {
+		e.logger.Error("Failed to find client encryption key",
+			zap.String("jwks_uri", jwksURI),
+			zap.Error(err))
+		return "", domain.ErrTokenEncryption
+	}
+
+	opts := (&jose.EncrypterOptions{}).WithContentType("JWT").WithType("JWT")
+	crypter, err := jose.NewEncrypter(jose.ContentEncryption(enc), jose.Recipient{
+		Algorithm: jose.KeyAlgorithm(alg),
+		Key:       key.Key,
+		KeyID:     key.KeyID,
+	}, opts)
+	if err != nil {
+		e.logger.Error("Failed to create encrypter",
+			zap.String("alg", alg),
+			zap.String("enc", enc),
+			zap.Error(err))
+		return "", domain.ErrTokenEncryption
+	}
+
+	object, err := crypter.Encrypt([]byte(token))
+	if err != nil {
+		e.logger.Error("Failed to encrypt token", zap.Error(err))
+		return "", domain.ErrTokenEncryption
+	}
+
+	return object.CompactSerialize()
+}
+
+// encryptionKey returns a key suitable for alg, refetching the key set once when the cached one has none,
+// as the client may have rotated its keys
+func (e *encrypter) encryptionKey(ctx context.Context, jwksURI, alg string) (*jose.JSONWebKey, error) {
+	keys, err := e.keySet(ctx, jwksURI, false)
+	if err != nil {
+		return nil, err
+	}
+	if key := selectKey(keys, alg); key != nil {
+		return key, nil
+	}
+
+	if keys, err = e.keySet(ctx, jwksURI, true); err != nil {
+		return nil, err
+	}
+	if key := selectKey(keys, alg); key != nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("no RSA encryption key for %s", alg)
+}
+
+func (e *encrypter) keySet(ctx context.Context, jwksURI string, refresh bool) (jose.JSONWebKeySet, error) {
+	e.mu.Lock()
+	cached, ok := e.cache[jwksURI]
+	e.mu.Unlock()
+	if ok && !refresh && time.Since(cached.fetchedAt) < e.cacheDuration {
+		return cached.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return jose.JSONWebKeySet{}, fmt.Errorf("unexpected status %d fetching %s", resp.StatusCode, jwksURI)
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxKeySetSize)).Decode(&keys); err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	e.mu.Lock()
+	e.cache[jwksURI] = &cachedKeySet{keys: keys, fetchedAt: time.Now()}
+	e.mu.Unlock()
+
+	return keys, nil
+}
+
+// selectKey picks the first RSA key intended for encryption that allows alg
+func selectKey(keys jose.JSONWebKeySet, alg string) *jose.JSONWebKey {
+	for i := range keys.Keys {
+		key := &keys.Keys[i]
+		if _, ok := key.Key.(*rsa.PublicKey); !ok {
+			continue
+		}
+		if key.Use != "" && key.Use != "enc" {
+			continue
+		}
+		if key.Algorithm != "" && key.Algorithm != alg {
+			continue
+		}
+		return key
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package jwe
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newJWKSServer(t *testing.T, keys *jose.JSONWebKeySet, fetches *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(fetches, 1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestEncrypter(server *httptest.Server) *encrypter {
+	e := NewEncrypter(&config.Config{JWKSCacheDuration: time.Hour}, zap.NewNop()).(*encrypter)
+	e.httpClient = server.Client()
+	return e
+}
+
+func TestEncrypter_Encrypt(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signingKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &signingKey.PublicKey, KeyID: "sig-1", Use: "sig", Algorithm: "RS256"},
+		{Key: &privateKey.PublicKey, KeyID: "enc-1", Use: "enc"},
+	}}
+	var fetches int32
+	server := newJWKSServer(t, keys, &fetches)
+	e := newTestEncrypter(server)
+
+	tests := []struct {
+		name string
+		alg  string
+		enc  string
+	}{
+		{name: "RSA-OAEP with A128CBC-HS256", alg: "RSA-OAEP", enc: "A128CBC-HS256"},
+		{name: "RSA-OAEP-256 with A256GCM", alg: "RSA-OAEP-256", enc: "A256GCM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := e.Encrypt(context.Background(), server.URL, tt.alg, tt.enc, "header.payload.signature")
+			require.NoError(t, err)
+
+			object, err := jose.ParseEncrypted(token,
+				[]jose.KeyAlgorithm{jose.KeyAlgorithm(tt.alg)},
+				[]jose.ContentEncryption{jose.ContentEncryption(tt.enc)})
+			require.NoError(t, err)
+			assert.Equal(t, "enc-1", object.Header.KeyID)
+			assert.Equal(t, "JWT", object.Header.ExtraHeaders[jose.HeaderContentType])
+
+			plaintext, err := object.Decrypt(privateKey)
+			require.NoError(t, err)
+			assert.Equal(t, "header.payload.signature", string(plaintext))
+		})
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "key set should be cached")
+}
+
+func TestEncrypter_RefetchesRotatedKeys(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys := &jose.JSONWebKeySet{}
+	var fetches int32
+	server := newJWKSServer(t, keys, &fetches)
+	e := newTestEncrypter(server)
+
+	_, err = e.Encrypt(context.Background(), server.URL, "RSA-OAEP", "A128CBC-HS256", "token")
+	assert.ErrorIs(t, err, domain.ErrTokenEncryption)
+
+	keys.Keys = append(keys.Keys, jose.JSONWebKey{Key: &privateKey.PublicKey, KeyID: "enc-2", Use: "enc"})
+
+	_, err = e.Encrypt(context.Background(), server.URL, "RSA-OAEP", "A128CBC-HS256", "token")
+	assert.NoError(t, err)
+}
+
+func TestEncrypter_UnreachableJWKS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	e := newTestEncrypter(server)
+
+	_, err := e.Encrypt(context.Background(), server.URL, "RSA-OAEP", "A128CBC-HS256", "token")
+	assert.ErrorIs(t, err, domain.ErrTokenEncryption)
+}
+
+func TestEncrypter_OversizedJWKS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"keys": [], "padding": "`))
+		w.Write(bytes.Repeat([]byte("a"), maxKeySetSize))
+		w.Write([]byte(`"}`))
+	}))
+	defer server.Close()
+	e := newTestEncrypter(server)
+
+	_, err := e.Encrypt(context.Background(), server.URL, "RSA-OAEP", "A128CBC-HS256", "token")
+	assert.ErrorIs(t, err, domain.ErrTokenEncryption)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -164,26 +165,28 @@ func (j *jwtService) GetJWKS(ctx context.Context) (map[string]interface{}, error
 
 // GenerateTokenPair generates a new pair of access and refresh tokens
 func (j *jwtService) GenerateTokenPair(userID ulid.ULID, roles []string) (*domain.TokenPair, error) {
+	return j.GenerateTokenPairWithParams(userID, roles, nil)
+}
+
+// GenerateTokenPairWithParams generates a new pair of access and refresh tokens recording the grant details,
+// plus an ID token when ID token claims are provided
+func (j *jwtService) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
 	j.mu.RLock()
 	defer j.mu.RUnlock()
 
 	if len(roles) == 0 {
 		return nil, domain.ErrTokenHasNoRoles
 	}
+	if params == nil {
+		params = &domain.TokenParams{}
+	}
 
 	// Generate access token
 	accessTokenID := ulid.Make().String()
-	accessClaims := domain.Claims{
-		Roles: roles,
-		RegisteredClaims: &jwt.RegisteredClaims{
-			Subject:   userID.String(),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.config.JWTAccessDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ID:        accessTokenID,
-		},
-	}
+	accessClaims := j.grantClaims(userID, roles, params, accessTokenID, j.config.JWTAccessDuration)
+	accessClaims.Type = domain.TokenTypeAccess
 
-	accessToken, err := j.strategy.Sign(&accessClaims)
+	accessToken, err := j.strategy.Sign(accessClaims)
 	if err != nil {
 		j.logger.Error("Failed to sign access token",
 			zap.Error(err),
@@ -194,17 +197,10 @@ func (j *jwtService) GenerateTokenPair(userID ulid.ULID, roles []string) (*domai
 
 	// Generate refresh token
 	refreshTokenID := ulid.Make().String()
-	refreshClaims := domain.Claims{
-		Roles: roles,
-		RegisteredClaims: &jwt.RegisteredClaims{
-			Subject:   userID.String(),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.config.JWTRefreshDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ID:        refreshTokenID,
-		},
-	}
+	refreshClaims := j.grantClaims(userID, roles, params, refreshTokenID, j.config.JWTRefreshDuration)
+	refreshClaims.Type = domain.TokenTypeRefresh
 
-	refreshToken, err := j.strategy.Sign(&refreshClaims)
+	refreshToken, err := j.strategy.Sign(refreshClaims)
 	if err != nil {
 		j.logger.Error("Failed to sign refresh token",
 			zap.Error(err),
@@ -213,16 +209,100 @@ func (j *jwtService) GenerateTokenPair(userID ulid.ULID, roles []string) (*domai
 		return nil, domain.ErrTokenGeneration
 	}
 
+	tokenPair := &domain.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+
+	// Generate ID token
+	if params.IDTokenClaims != nil {
+		idTokenID := ulid.Make().String()
+		idClaims := &domain.Claims{
+			RegisteredClaims: &jwt.RegisteredClaims{
+				Issuer:    j.config.ServerURL,
+				Subject:   tokenSubject(userID, params),
+				Audience:  jwt.ClaimStrings{params.ClientID},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.config.JWTAccessDuration)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ID:        idTokenID,
+			},
+			Extra: params.IDTokenClaims,
+			Type:  domain.TokenTypeID,
+		}
+		idClaims.SetAuthentication(params.Authentication)
+
+		tokenPair.IDToken, err = j.strategy.Sign(idClaims)
+		if err != nil {
+			j.logger.Error("Failed to sign ID token",
+				zap.Error(err),
+				zap.String("token_id", idTokenID),
+				zap.String("user_id", userID.String()))
+			return nil, domain.ErrTokenGeneration
+		}
+	}
+
 	j.logger.Debug("Generated token pair",
 		zap.String("access_token_id", accessTokenID),
 		zap.String("refresh_token_id", refreshTokenID),
 		zap.String("user_id", userID.String()),
 		zap.String("key_id", j.strategy.GetKeyID()))
 
-	return &domain.TokenPair{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return tokenPair, nil
+}
+
+// SignUserInfo signs the userinfo claims released to a client as a JWT, which expires like an access
+// token and whose typ keeps it from being used as one
+func (j *jwtService) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	claims := &domain.Claims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			Issuer:    j.config.ServerURL,
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.config.JWTAccessDuration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        ulid.Make().String(),
+		},
+		Extra: userInfo,
+		Type:  domain.TokenTypeUserInfo,
+	}
+
+	token, err := j.strategy.Sign(claims)
+	if err != nil {
+		j.logger.Error("Failed to sign userinfo",
+			zap.Error(err),
+			zap.String("client_id", clientID))
+		return "", domain.ErrTokenGeneration
+	}
+
+	return token, nil
+}
+
+// grantClaims builds the claims shared by access and refresh tokens
+func (j *jwtService) grantClaims(userID ulid.ULID, roles []string, params *domain.TokenParams, tokenID string, duration time.Duration) *domain.Claims {
+	claims := &domain.Claims{
+		Roles:          roles,
+		Scope:          strings.Join(params.Scopes, " "),
+		ClientID:       params.ClientID,
+		UserInfoClaims: params.UserInfoClaims,
+		RegisteredClaims: &jwt.RegisteredClaims{
+			Subject:   tokenSubject(userID, params),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(duration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        tokenID,
+		},
+	}
+	claims.SetAuthentication(params.Authentication)
+	return claims
+}
+
+// tokenSubject returns the subject the tokens are issued with: the user ID unless the params override it
+func tokenSubject(userID ulid.ULID, params *domain.TokenParams) string {
+	if params.Subject != "" {
+		return params.Subject
+	}
+	return userID.String()
 }
 
 func (j *jwtService) GetPublicKey() *rsa.PublicKey {
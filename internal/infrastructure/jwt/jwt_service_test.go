@@ -154,6 +154,7 @@ func TestJWTService_GenerateTokenPair(t *testing.T) {
 		assert.NotNil(t, claims)
 		assert.Equal(t, userID.String(), claims.Subject)
 		assert.Equal(t, roles, claims.Roles)
+		assert.True(t, claims.IsAccessToken())
 
 		// Validate refresh token
 		claims, err = service.ValidateToken(tokenPair.RefreshToken)
@@ -161,6 +162,32 @@ func TestJWTService_GenerateTokenPair(t *testing.T) {
 		assert.NotNil(t, claims)
 		assert.Equal(t, userID.String(), claims.Subject)
 		assert.Equal(t, roles, claims.Roles)
+		assert.False(t, claims.IsAccessToken(), "refresh tokens are not bearer credentials")
+		assert.True(t, claims.IsRefreshToken())
+	})
+
+	t.Run("token pair with a subject override", func(t *testing.T) {
+		userID := ulid.Make()
+
+		tokenPair, err := service.GenerateTokenPairWithParams(userID, []string{"USER"}, &domain.TokenParams{
+			ClientID:      "pairwise-client",
+			Subject:       "pairwise-subject",
+			IDTokenClaims: map[string]interface{}{},
+		})
+		require.NoError(t, err)
+
+		for _, token := range []string{tokenPair.AccessToken, tokenPair.RefreshToken} {
+			claims, err := service.ValidateToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, "pairwise-subject", claims.Subject)
+		}
+		require.NotEmpty(t, tokenPair.IDToken)
+
+		// ID tokens are neither bearer credentials nor refresh tokens
+		claims, err := service.ValidateToken(tokenPair.IDToken)
+		require.NoError(t, err)
+		assert.Equal(t, domain.TokenTypeID, claims.Type)
+		assert.False(t, claims.IsRefreshToken())
 	})
 
 	t.Run("token pair with empty roles", func(t *testing.T) {
@@ -182,6 +209,26 @@ func TestJWTService_GenerateTokenPair(t *testing.T) {
 	})
 }
 
+func TestJWTService_SignUserInfo(t *testing.T) {
+	service := getJWTService(t)
+	userID := ulid.Make()
+
+	token, err := service.SignUserInfo("client123", map[string]interface{}{
+		"sub":   userID.String(),
+		"roles": []string{"ADMIN"},
+	})
+	require.NoError(t, err)
+
+	// Signed userinfo expires and is neither a bearer credential nor a refresh token
+	claims, err := service.ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, domain.TokenTypeUserInfo, claims.Type)
+	assert.NotNil(t, claims.ExpiresAt)
+	assert.NotEmpty(t, claims.ID)
+	assert.False(t, claims.IsAccessToken())
+	assert.False(t, claims.IsRefreshToken())
+}
+
 func TestJWTService_GetJWKS(t *testing.T) {
 	service := getJWTService(t)
 
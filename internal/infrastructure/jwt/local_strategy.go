@@ -10,6 +10,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -126,6 +127,9 @@ func (l *localStrategy) Sign(claims *domain.Claims) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	token.Header["kid"] = l.keyID
+	if claims.Type != "" {
+		token.Header["typ"] = claims.Type
+	}
 
 	return token.SignedString(l.privateKey)
 }
@@ -168,6 +172,15 @@ func (l *localStrategy) GetLastRotation() time.Time {
 	return l.lastRotation
 }
 
+// tokenType returns the typ header of a token, leaving it empty for plain JWTs
+func tokenType(header map[string]interface{}) string {
+	typ, _ := header["typ"].(string)
+	if strings.EqualFold(typ, "JWT") {
+		return ""
+	}
+	return typ
+}
+
 // generateKeyID generates a unique key ID from the private key
 func generateKeyID(key *rsa.PrivateKey) string {
 	// Use the public key components to generate a unique ID
@@ -228,6 +241,7 @@ func (l *localStrategy) Verify(tokenString string) (*domain.Claims, error) {
 		l.logger.Error("Invalid claims type")
 		return nil, domain.ErrInvalidClaims
 	}
+	claims.Type = tokenType(token.Header)
 
 	return claims, nil
 }
@@ -80,6 +80,9 @@ func (v *vaultStrategy) Sign(claims *domain.Claims) (string, error) {
 	// Cria o token sem assinatura
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
 	token.Header["kid"] = v.keyID
+	if claims.Type != "" {
+		token.Header["typ"] = claims.Type
+	}
 
 	unsignedToken, err := token.SigningString()
 	if err != nil {
@@ -453,6 +456,8 @@ func (v *vaultStrategy) Verify(tokenString string) (*domain.Claims, error) {
 		return nil, domain.ErrInvalidSignature
 	}
 
+	claims.Type = tokenType(header)
+
 	v.logger.Debug("Token verified successfully",
 		zap.String("token_id", claims.ID),
 		zap.String("subject", claims.Subject))
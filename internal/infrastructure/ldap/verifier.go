@@ -0,0 +1,195 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// Verifier checks passwords by binding to an LDAP directory, such as Active Directory, as the
+// user. The user's entry is found either by building its DN from a template or by searching for
+// it with a service account, and its attributes are read as the user once the bind succeeds.
+type Verifier struct {
+	config    config.LDAPConfig
+	tlsConfig *tls.Config
+	logger    *zap.Logger
+}
+
+// NewCredentialVerifier creates the verifier of the configured directory, or nil when no
+// directory is configured
+func NewCredentialVerifier(cfg *config.Config, logger *zap.Logger) domain.CredentialVerifier {
+	if cfg.LDAP.URL == "" {
+		return nil
+	}
+	return NewVerifier(cfg.LDAP, logger)
+}
+
+// NewVerifier creates a verifier of the directory at cfg.URL
+func NewVerifier(cfg config.LDAPConfig, logger *zap.Logger) *Verifier {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.SkipVerify,
+	}
+	if u, err := url.Parse(cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	if cfg.CAFile != "" {
+		// Without its CA the directory's certificate fails to verify, so sign-ins fail closed
+		if pem, err := os.ReadFile(cfg.CAFile); err != nil {
+			logger.Error("Failed to read LDAP CA file", zap.String("path", cfg.CAFile), zap.Error(err))
+		} else {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				logger.Error("No certificates in LDAP CA file", zap.String("path", cfg.CAFile))
+			}
+		}
+	}
+
+	return &Verifier{
+		config:    cfg,
+		tlsConfig: tlsConfig,
+		logger:    logger,
+	}
+}
+
+// Verify binds as the user with the password and reads their entry
+func (v *Verifier) Verify(ctx context.Context, login, password string) (*domain.DirectoryUser, error) {
+	// Directories accept a simple bind without a password as an anonymous bind
+	if login == "" || password == "" {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	conn, err := v.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var dn string
+	if v.config.BindDNTemplate != "" {
+		dn = strings.ReplaceAll(v.config.BindDNTemplate, "{login}", ldapv3.EscapeDN(login))
+	} else if dn, err = v.findUser(conn, login); err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(dn, password); err != nil {
+		if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultInvalidCredentials) {
+			return nil, domain.ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind as the user: %w", err)
+	}
+
+	// Read as the user, as a service account may not see every attribute of the entry
+	entry, err := v.readEntry(conn, dn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.DirectoryUser{
+		DN:    entry.DN,
+		Name:  entry.GetAttributeValue(v.config.NameAttribute),
+		Email: entry.GetAttributeValue(v.config.EmailAttribute),
+		Roles: v.roles(entry.GetAttributeValues(v.config.GroupAttribute)),
+	}, nil
+}
+
+// connect dials the directory, upgrading the connection with StartTLS when configured
+func (v *Verifier) connect(ctx context.Context) (*ldapv3.Conn, error) {
+	dialer := &net.Dialer{Timeout: v.config.Timeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	conn, err := ldapv3.DialURL(v.config.URL, ldapv3.DialWithDialer(dialer), ldapv3.DialWithTLSConfig(v.tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the directory: %w", err)
+	}
+	conn.SetTimeout(v.config.Timeout)
+
+	if v.config.StartTLS {
+		if err := conn.StartTLS(v.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// findUser searches the base DN for the entry of the login, with the service account or, without
+// one, anonymously
+func (v *Verifier) findUser(conn *ldapv3.Conn, login string) (string, error) {
+	if v.config.BindDN != "" {
+		if err := conn.Bind(v.config.BindDN, v.config.BindPassword); err != nil {
+			return "", fmt.Errorf("failed to bind as the service account: %w", err)
+		}
+	}
+
+	filter := strings.ReplaceAll(v.config.UserFilter, "{login}", ldapv3.EscapeFilter(login))
+	result, err := conn.Search(ldapv3.NewSearchRequest(
+		v.config.BaseDN,
+		ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 2, int(v.config.Timeout.Seconds()), false,
+		filter,
+		[]string{"dn"},
+		nil,
+	))
+	if err != nil && !ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultSizeLimitExceeded) {
+		return "", fmt.Errorf("failed to search for the user: %w", err)
+	}
+
+	switch {
+	case result == nil || len(result.Entries) == 0:
+		return "", domain.ErrInvalidCredentials
+	case len(result.Entries) > 1:
+		// Binding as either entry would let one user sign in as the other
+		v.logger.Warn("LDAP user filter matches several entries", zap.String("filter", filter))
+		return "", domain.ErrInvalidCredentials
+	}
+	return result.Entries[0].DN, nil
+}
+
+// readEntry reads the mapped attributes of the entry
+func (v *Verifier) readEntry(conn *ldapv3.Conn, dn string) (*ldapv3.Entry, error) {
+	result, err := conn.Search(ldapv3.NewSearchRequest(
+		dn,
+		ldapv3.ScopeBaseObject, ldapv3.NeverDerefAliases, 1, int(v.config.Timeout.Seconds()), false,
+		"(objectClass=*)",
+		[]string{v.config.NameAttribute, v.config.EmailAttribute, v.config.GroupAttribute},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the user's entry: %w", err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, errors.New("user's entry not found after binding")
+	}
+	return result.Entries[0], nil
+}
+
+// roles maps the groups of the user to roles. Groups given as DNs, such as the values of
+// memberOf, are matched by the value of their first RDN, such as the cn of the group.
+func (v *Verifier) roles(groups []string) []string {
+	roles := append([]string{}, v.config.DefaultRoles...)
+	for _, group := range groups {
+		name := group
+		if dn, err := ldapv3.ParseDN(group); err == nil && len(dn.RDNs) > 0 && len(dn.RDNs[0].Attributes) > 0 {
+			name = dn.RDNs[0].Attributes[0].Value
+		}
+		role, ok := v.config.GroupRoles[strings.ToLower(name)]
+		if ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
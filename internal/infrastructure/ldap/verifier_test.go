@@ -0,0 +1,374 @@
+package ldap
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	ldapv3 "github.com/go-ldap/ldap/v3"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	serviceDN = "cn=authm,ou=services,dc=example,dc=com"
+	janeDN    = "uid=jane,ou=people,dc=example,dc=com"
+)
+
+// stubDirectory is an in-process LDAP server answering simple binds, searches and StartTLS. It
+// only lets bound clients search, and binds only over TLS once a certificate is set.
+type stubDirectory struct {
+	listener  net.Listener
+	passwords map[string]string
+	entries   map[string]map[string][]string
+	tlsConfig *tls.Config
+	caFile    string
+
+	mu    sync.Mutex
+	binds []string
+}
+
+func newStubDirectory(t *testing.T) *stubDirectory {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	d := &stubDirectory{
+		listener: listener,
+		passwords: map[string]string{
+			serviceDN:                              "service-secret",
+			janeDN:                                 "jane-secret",
+			"uid=john,ou=people,dc=example,dc=com": "john-secret",
+		},
+		entries: map[string]map[string][]string{
+			janeDN: {
+				"cn":       {"Jane Doe"},
+				"mail":     {"jane@example.com"},
+				"memberOf": {"cn=authm-admins,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+			},
+			"uid=john,ou=people,dc=example,dc=com": {
+				"cn":   {"John Doe"},
+				"mail": {"john@example.com"},
+			},
+		},
+	}
+	t.Cleanup(func() { listener.Close() })
+	go d.serve()
+	return d
+}
+
+func (d *stubDirectory) url() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+// requireTLS makes the directory only accept binds after StartTLS, with a certificate whose CA is
+// written to caFile
+func (d *stubDirectory) requireTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "stub directory"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	d.caFile = filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(d.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	d.tlsConfig = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func (d *stubDirectory) boundDNs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.binds...)
+}
+
+func (d *stubDirectory) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *stubDirectory) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	var bound string
+	secure := false
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldapv3.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+			code := ldapv3.LDAPResultSuccess
+			switch {
+			case d.tlsConfig != nil && !secure:
+				code = ldapv3.LDAPResultConfidentialityRequired
+			case d.passwords[dn] == "" || d.passwords[dn] != password:
+				code = ldapv3.LDAPResultInvalidCredentials
+			default:
+				bound = dn
+				d.mu.Lock()
+				d.binds = append(d.binds, dn)
+				d.mu.Unlock()
+			}
+			writeResult(conn, messageID, ldapv3.ApplicationBindResponse, code)
+
+		case ldapv3.ApplicationSearchRequest:
+			if bound == "" {
+				writeResult(conn, messageID, ldapv3.ApplicationSearchResultDone, ldapv3.LDAPResultInsufficientAccessRights)
+				continue
+			}
+			base := op.Children[0].Data.String()
+			scope := op.Children[1].Value.(int64)
+			filter, err := ldapv3.DecompileFilter(op.Children[6])
+			if err != nil {
+				writeResult(conn, messageID, ldapv3.ApplicationSearchResultDone, ldapv3.LDAPResultProtocolError)
+				continue
+			}
+			for dn, attributes := range d.entries {
+				inScope := dn == base || (scope != ldapv3.ScopeBaseObject && strings.HasSuffix(dn, ","+base))
+				if inScope && matches(filter, attributes) {
+					writeEntry(conn, messageID, dn, attributes)
+				}
+			}
+			writeResult(conn, messageID, ldapv3.ApplicationSearchResultDone, ldapv3.LDAPResultSuccess)
+
+		case ldapv3.ApplicationExtendedRequest:
+			if d.tlsConfig == nil || op.Children[0].Data.String() != "1.3.6.1.4.1.1466.20037" {
+				writeResult(conn, messageID, ldapv3.ApplicationExtendedResponse, ldapv3.LDAPResultProtocolError)
+				continue
+			}
+			writeResult(conn, messageID, ldapv3.ApplicationExtendedResponse, ldapv3.LDAPResultSuccess)
+			tlsConn := tls.Server(conn, d.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+
+		default:
+			return
+		}
+	}
+}
+
+// matches evaluates the equality and presence filters the verifier sends
+func matches(filter string, attributes map[string][]string) bool {
+	attribute, value, ok := strings.Cut(strings.Trim(filter, "()"), "=")
+	if !ok {
+		return false
+	}
+	if value == "*" {
+		return attribute == "objectClass" || len(attributes[attribute]) > 0
+	}
+	for _, v := range attributes[attribute] {
+		if ldapv3.EscapeFilter(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func envelope(messageID int64, op *ber.Packet) []byte {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	packet.AppendChild(op)
+	return packet.Bytes()
+}
+
+func writeResult(conn net.Conn, messageID int64, tag ber.Tag, code int) {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	conn.Write(envelope(messageID, op))
+}
+
+func writeEntry(conn net.Conn, messageID int64, dn string, attributes map[string][]string) {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapv3.ApplicationSearchResultEntry, nil, "Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute.AppendChild(set)
+		list.AppendChild(attribute)
+	}
+	op.AppendChild(list)
+	conn.Write(envelope(messageID, op))
+}
+
+func (d *stubDirectory) config() config.LDAPConfig {
+	return config.LDAPConfig{
+		URL:            d.url(),
+		Timeout:        2 * time.Second,
+		NameAttribute:  "cn",
+		EmailAttribute: "mail",
+		GroupAttribute: "memberOf",
+		GroupRoles:     map[string]string{"authm-admins": "admin"},
+		DefaultRoles:   []string{"user"},
+	}
+}
+
+func TestVerifier_BindDNTemplate(t *testing.T) {
+	directory := newStubDirectory(t)
+	cfg := directory.config()
+	cfg.BindDNTemplate = "uid={login},ou=people,dc=example,dc=com"
+	verifier := NewVerifier(cfg, zap.NewNop())
+
+	t.Run("maps the entry", func(t *testing.T) {
+		user, err := verifier.Verify(context.Background(), "jane", "jane-secret")
+		require.NoError(t, err)
+		assert.Equal(t, janeDN, user.DN)
+		assert.Equal(t, "Jane Doe", user.Name)
+		assert.Equal(t, "jane@example.com", user.Email)
+		assert.Equal(t, []string{"user", "admin"}, user.Roles)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, err := verifier.Verify(context.Background(), "jane", "guess")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("empty password", func(t *testing.T) {
+		_, err := verifier.Verify(context.Background(), "jane", "")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("login escaped in the DN", func(t *testing.T) {
+		_, err := verifier.Verify(context.Background(), "jane,ou=people", "jane-secret")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+}
+
+func TestVerifier_SearchThenBind(t *testing.T) {
+	directory := newStubDirectory(t)
+	cfg := directory.config()
+	cfg.BindDN = serviceDN
+	cfg.BindPassword = "service-secret"
+	cfg.BaseDN = "ou=people,dc=example,dc=com"
+	cfg.UserFilter = "(mail={login})"
+	verifier := NewVerifier(cfg, zap.NewNop())
+
+	t.Run("binds as the entry found", func(t *testing.T) {
+		user, err := verifier.Verify(context.Background(), "john@example.com", "john-secret")
+		require.NoError(t, err)
+		assert.Equal(t, "uid=john,ou=people,dc=example,dc=com", user.DN)
+		assert.Equal(t, "John Doe", user.Name)
+		assert.Equal(t, []string{"user"}, user.Roles)
+		assert.Equal(t, []string{serviceDN, "uid=john,ou=people,dc=example,dc=com"}, directory.boundDNs())
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		_, err := verifier.Verify(context.Background(), "nobody@example.com", "secret")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("wildcard login", func(t *testing.T) {
+		// Unescaped, the login would match the only entry with groups
+		cfg := cfg
+		cfg.UserFilter = "(memberOf={login})"
+		_, err := NewVerifier(cfg, zap.NewNop()).Verify(context.Background(), "*", "jane-secret")
+		assert.Equal(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("wrong service account password", func(t *testing.T) {
+		cfg := cfg
+		cfg.BindPassword = "wrong"
+		_, err := NewVerifier(cfg, zap.NewNop()).Verify(context.Background(), "jane@example.com", "jane-secret")
+		assert.Error(t, err)
+		assert.NotEqual(t, domain.ErrInvalidCredentials, err)
+	})
+}
+
+func TestVerifier_StartTLS(t *testing.T) {
+	directory := newStubDirectory(t)
+	directory.requireTLS(t)
+	cfg := directory.config()
+	cfg.BindDNTemplate = "uid={login},ou=people,dc=example,dc=com"
+	cfg.StartTLS = true
+	cfg.CAFile = directory.caFile
+
+	t.Run("trusted certificate", func(t *testing.T) {
+		user, err := NewVerifier(cfg, zap.NewNop()).Verify(context.Background(), "jane", "jane-secret")
+		require.NoError(t, err)
+		assert.Equal(t, "jane@example.com", user.Email)
+	})
+
+	t.Run("unknown certificate authority", func(t *testing.T) {
+		cfg := cfg
+		cfg.CAFile = ""
+		_, err := NewVerifier(cfg, zap.NewNop()).Verify(context.Background(), "jane", "jane-secret")
+		assert.Error(t, err)
+		assert.NotEqual(t, domain.ErrInvalidCredentials, err)
+	})
+
+	t.Run("without StartTLS", func(t *testing.T) {
+		cfg := cfg
+		cfg.StartTLS = false
+		_, err := NewVerifier(cfg, zap.NewNop()).Verify(context.Background(), "jane", "jane-secret")
+		assert.Error(t, err)
+		assert.NotEqual(t, domain.ErrInvalidCredentials, err)
+	})
+}
+
+func TestVerifier_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	verifier := NewVerifier(config.LDAPConfig{
+		URL:            "ldap://" + addr,
+		Timeout:        time.Second,
+		BindDNTemplate: "uid={login},ou=people,dc=example,dc=com",
+		EmailAttribute: "mail",
+	}, zap.NewNop())
+
+	_, err = verifier.Verify(context.Background(), "jane", "jane-secret")
+	assert.Error(t, err)
+	assert.NotEqual(t, domain.ErrInvalidCredentials, err)
+}
+
+func TestNewCredentialVerifier(t *testing.T) {
+	assert.Nil(t, NewCredentialVerifier(&config.Config{}, zap.NewNop()), "no directory is configured")
+	assert.NotNil(t, NewCredentialVerifier(&config.Config{LDAP: config.LDAPConfig{URL: "ldap://localhost"}}, zap.NewNop()))
+}
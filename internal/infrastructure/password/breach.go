@@ -0,0 +1,224 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// breachPrefixLength is the number of hex characters of the SHA-1 hash that name a bucket,
+// the same k-anonymity prefix used by the Have I Been Pwned range API
+const breachPrefixLength = 5
+
+// ErrUnorderedCorpus is returned when an imported corpus is not ordered by hash
+var ErrUnorderedCorpus = errors.New("breach corpus must be ordered by hash")
+
+// BreachCorpus looks passwords up in a local copy of a breached password corpus.
+// The corpus is a directory holding one <PREFIX>.txt file per 5 character SHA-1 prefix,
+// each line being SUFFIX:COUNT exactly as returned by the range API.
+type BreachCorpus struct {
+	dir string
+}
+
+// NewBreachCorpus returns a corpus reading buckets from the configured directory.
+// Without a directory every password is reported as not breached.
+func NewBreachCorpus(cfg *config.Config, logger *zap.Logger) *BreachCorpus {
+	if cfg.BreachedPasswordsDir == "" {
+		return &BreachCorpus{}
+	}
+
+	if info, err := os.Stat(cfg.BreachedPasswordsDir); err != nil || !info.IsDir() {
+		logger.Warn("Breached password corpus not found, passwords will not be checked until it is imported",
+			zap.String("dir", cfg.BreachedPasswordsDir))
+	} else {
+		logger.Info("Using breached password corpus",
+			zap.String("dir", cfg.BreachedPasswordsDir))
+	}
+	return &BreachCorpus{dir: cfg.BreachedPasswordsDir}
+}
+
+// Count returns how many times the password appears in the corpus. Buckets are read on
+// every lookup, so a refreshed corpus is picked up without a restart.
+func (c *BreachCorpus) Count(password string) (int, error) {
+	if c.dir == "" {
+		return 0, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:breachPrefixLength], hash[breachPrefixLength:]
+
+	file, err := os.Open(filepath.Join(c.dir, prefix+".txt"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entrySuffix, count, ok := parseBreachLine(scanner.Text())
+		if ok && entrySuffix == suffix {
+			return count, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// ImportStats summarizes a corpus import
+type ImportStats struct {
+	Buckets int
+	Hashes  int
+	Skipped int
+}
+
+// ImportBreachCorpus splits a corpus of HASH:COUNT lines, ordered by hash as in the
+// downloadable Have I Been Pwned SHA-1 file, into buckets under dir. Hashes seen fewer
+// than minCount times are left out. The buckets are written next to dir and swapped in
+// once complete, so an existing corpus keeps serving lookups until the refresh succeeds.
+func ImportBreachCorpus(r io.Reader, dir string, minCount int) (*ImportStats, error) {
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".import-")
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := writeBuckets(r, staging, minCount)
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+	if err := os.Chmod(staging, 0o755); err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+
+	if err := swapDir(staging, dir); err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+	return stats, nil
+}
+
+func writeBuckets(r io.Reader, dir string, minCount int) (*ImportStats, error) {
+	stats := &ImportStats{}
+	var (
+		bucket *os.File
+		writer *bufio.Writer
+		prefix string
+	)
+	closeBucket := func() error {
+		if bucket == nil {
+			return nil
+		}
+		if err := writer.Flush(); err != nil {
+			bucket.Close()
+			return err
+		}
+		return bucket.Close()
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hash, count, ok := parseBreachLine(text)
+		if !ok || len(hash) != sha1.Size*2 {
+			closeBucket()
+			return nil, fmt.Errorf("invalid corpus entry on line %d", line)
+		}
+		if count < minCount {
+			stats.Skipped++
+			continue
+		}
+
+		hashPrefix := hash[:breachPrefixLength]
+		if hashPrefix != prefix {
+			if hashPrefix < prefix {
+				closeBucket()
+				return nil, fmt.Errorf("%w: line %d", ErrUnorderedCorpus, line)
+			}
+			if err := closeBucket(); err != nil {
+				return nil, err
+			}
+			file, err := os.Create(filepath.Join(dir, hashPrefix+".txt"))
+			if err != nil {
+				return nil, err
+			}
+			bucket, writer, prefix = file, bufio.NewWriter(file), hashPrefix
+			stats.Buckets++
+		}
+
+		if _, err := fmt.Fprintf(writer, "%s:%d\r\n", hash[breachPrefixLength:], count); err != nil {
+			closeBucket()
+			return nil, err
+		}
+		stats.Hashes++
+	}
+	if err := scanner.Err(); err != nil {
+		closeBucket()
+		return nil, err
+	}
+	if err := closeBucket(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// swapDir replaces dir with staging, removing the previous corpus afterwards
+func swapDir(staging, dir string) error {
+	previous := dir + ".previous"
+	if err := os.RemoveAll(previous); err != nil {
+		return err
+	}
+
+	if err := os.Rename(dir, previous); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.Rename(staging, dir)
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		// Put the previous corpus back so lookups keep working
+		os.Rename(previous, dir)
+		return err
+	}
+	return os.RemoveAll(previous)
+}
+
+// parseBreachLine parses a HASH:COUNT line, upper-casing the hash
+func parseBreachLine(line string) (string, int, bool) {
+	hash, countText, found := strings.Cut(strings.TrimSpace(line), ":")
+	if !found {
+		return "", 0, false
+	}
+	count, err := strconv.Atoi(countText)
+	if err != nil || count < 0 {
+		return "", 0, false
+	}
+	hash = strings.ToUpper(hash)
+	if hash == "" || strings.Trim(hash, "0123456789ABCDEF") != "" {
+		return "", 0, false
+	}
+	return hash, count, true
+}
@@ -0,0 +1,107 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func TestBreachCorpus_Count(t *testing.T) {
+	dir := t.TempDir()
+	hash := sha1Hex("password")
+	bucket := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + hash[5:] + ":9545824\r\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(bucket), 0o600))
+
+	corpus := NewBreachCorpus(&config.Config{BreachedPasswordsDir: dir}, zap.NewNop())
+
+	count, err := corpus.Count("password")
+	require.NoError(t, err)
+	assert.Equal(t, 9545824, count)
+
+	// Same bucket, different suffix
+	count, err = corpus.Count("Tr1cky-Horse-Battery")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestBreachCorpus_Disabled(t *testing.T) {
+	corpus := NewBreachCorpus(&config.Config{}, zap.NewNop())
+
+	count, err := corpus.Count("password")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestImportBreachCorpus(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "breached")
+	passwords := map[string]int{"password": 100, "123456": 50, "letmein": 2}
+
+	var lines []string
+	for password, count := range passwords {
+		lines = append(lines, sha1Hex(password)+":"+strconv.Itoa(count))
+	}
+	sort.Strings(lines)
+
+	stats, err := ImportBreachCorpus(strings.NewReader(strings.Join(lines, "\n")), dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Hashes)
+	assert.Equal(t, 1, stats.Skipped)
+
+	corpus := NewBreachCorpus(&config.Config{BreachedPasswordsDir: dir}, zap.NewNop())
+	for password, expected := range map[string]int{"password": 100, "123456": 50, "letmein": 0} {
+		count, err := corpus.Count(password)
+		require.NoError(t, err)
+		assert.Equal(t, expected, count, password)
+	}
+
+	// A refresh replaces the previous corpus
+	_, err = ImportBreachCorpus(strings.NewReader(sha1Hex("letmein")+":20\n"), dir, 10)
+	require.NoError(t, err)
+
+	count, err := corpus.Count("password")
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	count, err = corpus.Count("letmein")
+	require.NoError(t, err)
+	assert.Equal(t, 20, count)
+}
+
+func TestImportBreachCorpus_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "unordered", input: "FFFFF" + strings.Repeat("0", 35) + ":1\n00000" + strings.Repeat("0", 35) + ":1\n"},
+		{name: "malformed", input: "not-a-hash:1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "breached")
+
+			_, err := ImportBreachCorpus(strings.NewReader(tt.input), dir, 0)
+			assert.Error(t, err)
+
+			// A failed import leaves nothing behind
+			entries, err := os.ReadDir(parent)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
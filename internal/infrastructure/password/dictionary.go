@@ -0,0 +1,73 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+//go:embed common_passwords.txt
+var defaultCommonPasswords string
+
+// Dictionary is an in-memory list of commonly used passwords
+type Dictionary struct {
+	words map[string]struct{}
+}
+
+// NewDictionary loads the dictionary from the configured file, one password per line.
+// Without a file, or when it cannot be read, the built-in list is used.
+func NewDictionary(cfg *config.Config, logger *zap.Logger) *Dictionary {
+	if cfg.PasswordDictionaryPath != "" {
+		dictionary, err := loadDictionary(cfg.PasswordDictionaryPath)
+		if err == nil {
+			logger.Info("Loaded password dictionary",
+				zap.String("path", cfg.PasswordDictionaryPath),
+				zap.Int("entries", len(dictionary.words)))
+			return dictionary
+		}
+		logger.Error("Failed to load password dictionary, using built-in list",
+			zap.String("path", cfg.PasswordDictionaryPath),
+			zap.Error(err))
+	}
+
+	dictionary, _ := readDictionary(strings.NewReader(defaultCommonPasswords))
+	return dictionary
+}
+
+// Contains reports whether the password is in the dictionary, ignoring case
+func (d *Dictionary) Contains(password string) bool {
+	_, ok := d.words[strings.ToLower(password)]
+	return ok
+}
+
+func loadDictionary(path string) (*Dictionary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readDictionary(file)
+}
+
+// readDictionary reads one password per line, skipping blank lines and # comments
+func readDictionary(r io.Reader) (*Dictionary, error) {
+	words := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &Dictionary{words: words}, nil
+}
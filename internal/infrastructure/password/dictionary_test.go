@@ -0,0 +1,37 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewDictionary_BuiltIn(t *testing.T) {
+	dictionary := NewDictionary(&config.Config{}, zap.NewNop())
+
+	assert.True(t, dictionary.Contains("password123"))
+	assert.True(t, dictionary.Contains("QWERTY"))
+	assert.False(t, dictionary.Contains("correct horse battery staple"))
+}
+
+func TestNewDictionary_FromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# company specific\nAcme2024\n\nwidgets!\n"), 0o600))
+
+	dictionary := NewDictionary(&config.Config{PasswordDictionaryPath: path}, zap.NewNop())
+
+	assert.True(t, dictionary.Contains("acme2024"))
+	assert.True(t, dictionary.Contains("widgets!"))
+	assert.False(t, dictionary.Contains("password123"))
+}
+
+func TestNewDictionary_MissingFileFallsBack(t *testing.T) {
+	dictionary := NewDictionary(&config.Config{PasswordDictionaryPath: "/nonexistent/passwords.txt"}, zap.NewNop())
+
+	assert.True(t, dictionary.Contains("password123"))
+}
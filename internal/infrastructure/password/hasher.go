@@ -0,0 +1,143 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2idID = "argon2id"
+	saltLength = 16
+	keyLength  = 32
+)
+
+var (
+	// ErrUnsupportedHash is returned when a hash is in neither the Argon2id PHC nor the bcrypt format
+	ErrUnsupportedHash = errors.New("unsupported password hash format")
+	// ErrMalformedHash is returned when an Argon2id PHC string cannot be parsed
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Params holds the Argon2id cost parameters
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultParams follows the OWASP recommendation for Argon2id
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+}
+
+// Hasher hashes passwords with Argon2id and verifies both Argon2id and legacy bcrypt hashes
+type Hasher struct {
+	params Params
+}
+
+// NewHasher creates a Hasher with the cost parameters from the configuration
+func NewHasher(cfg *config.Config) *Hasher {
+	return &Hasher{
+		params: Params{
+			Memory:      cfg.PasswordHashMemory,
+			Iterations:  cfg.PasswordHashIterations,
+			Parallelism: cfg.PasswordHashParallelism,
+		},
+	}
+}
+
+// Hash returns the Argon2id hash of the password as a PHC string:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, keyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idID,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether the password matches an Argon2id or bcrypt hash
+func (h *Hasher) Verify(password, hash string) (bool, error) {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, candidate) == 1, nil
+}
+
+// NeedsRehash reports whether the hash is not Argon2id with the configured parameters
+func (h *Hasher) NeedsRehash(hash string) bool {
+	params, _, _, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params != h.params
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// decodeArgon2id parses an Argon2id PHC string into its parameters, salt and key
+func decodeArgon2id(hash string) (Params, []byte, []byte, error) {
+	var params Params
+
+	// The leading $ yields an empty first field
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return params, nil, nil, ErrUnsupportedHash
+	}
+	if parts[1] != argon2idID {
+		return params, nil, nil, ErrUnsupportedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	return params, salt, key, nil
+}
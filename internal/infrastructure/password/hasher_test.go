@@ -0,0 +1,116 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestHasher(memory, iterations uint32, parallelism uint8) *Hasher {
+	return NewHasher(&config.Config{
+		PasswordHashMemory:      memory,
+		PasswordHashIterations:  iterations,
+		PasswordHashParallelism: parallelism,
+	})
+}
+
+func TestHasher_Hash(t *testing.T) {
+	hasher := newTestHasher(1024, 2, 1)
+
+	hash, err := hasher.Hash("securePassword123!")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$"))
+
+	// Every hash gets its own salt
+	other, err := hasher.Hash("securePassword123!")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestHasher_Verify(t *testing.T) {
+	hasher := newTestHasher(1024, 2, 1)
+
+	argonHash, err := hasher.Hash("securePassword123!")
+	require.NoError(t, err)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("securePassword123!"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+		wantErr  error
+	}{
+		{
+			name:     "argon2id match",
+			password: "securePassword123!",
+			hash:     argonHash,
+			want:     true,
+		},
+		{
+			name:     "argon2id mismatch",
+			password: "wrongPassword123!",
+			hash:     argonHash,
+		},
+		{
+			name:     "legacy bcrypt match",
+			password: "securePassword123!",
+			hash:     string(bcryptHash),
+			want:     true,
+		},
+		{
+			name:     "legacy bcrypt mismatch",
+			password: "wrongPassword123!",
+			hash:     string(bcryptHash),
+		},
+		{
+			name:     "unsupported algorithm",
+			password: "securePassword123!",
+			hash:     "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5",
+			wantErr:  ErrUnsupportedHash,
+		},
+		{
+			name:     "malformed parameters",
+			password: "securePassword123!",
+			hash:     "$argon2id$v=19$m=abc$c2FsdA$a2V5",
+			wantErr:  ErrMalformedHash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := hasher.Verify(tt.password, tt.hash)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	hasher := newTestHasher(1024, 2, 1)
+
+	current, err := hasher.Hash("securePassword123!")
+	require.NoError(t, err)
+	weaker, err := newTestHasher(1024, 1, 1).Hash("securePassword123!")
+	require.NoError(t, err)
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("securePassword123!"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	assert.False(t, hasher.NeedsRehash(current))
+	assert.True(t, hasher.NeedsRehash(weaker))
+	assert.True(t, hasher.NeedsRehash(string(bcryptHash)))
+
+	// A hash made with the outdated parameters still verifies
+	ok, err := hasher.Verify("securePassword123!", weaker)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -2,27 +2,23 @@ package password
 
 import (
 	"errors"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a password using bcrypt
+var defaultHasher = &Hasher{params: DefaultParams}
+
+// HashPassword hashes a password using Argon2id with the default parameters
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hashedPassword), nil
+	return defaultHasher.Hash(password)
 }
 
-// CheckPassword checks if a password matches its hash
+// CheckPassword checks if a password matches its Argon2id or bcrypt hash
 func CheckPassword(password, hash string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	ok, err := defaultHasher.Verify(password, hash)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
-			return errors.New("invalid password")
-		}
 		return err
 	}
+	if !ok {
+		return errors.New("invalid password")
+	}
 	return nil
 }
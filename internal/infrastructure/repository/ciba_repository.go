@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"go.uber.org/zap"
+)
+
+// BackchannelAuthRepository implements the backchannel authentication request repository interface
+type BackchannelAuthRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewBackchannelAuthRepository creates a new backchannel authentication request repository
+func NewBackchannelAuthRepository(db *database.Postgres, logger *zap.Logger) *BackchannelAuthRepository {
+	return &BackchannelAuthRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new backchannel authentication request
+func (r *BackchannelAuthRepository) Create(ctx context.Context, req *domain.BackchannelAuthRequest) error {
+	query := `
+		INSERT INTO backchannel_auth_requests (auth_req_id, client_id, user_id, scopes, binding_message, client_notification_token,
+			status, poll_interval, last_polled_at, created_at, expires_at, amr, auth_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+
+	err := r.db.Exec(ctx, query,
+		req.AuthReqID,
+		req.ClientID,
+		req.UserID,
+		nonNil(req.Scopes),
+		req.BindingMessage,
+		req.ClientNotificationToken,
+		req.Status,
+		req.Interval,
+		req.LastPolledAt,
+		req.CreatedAt,
+		req.ExpiresAt,
+		req.AMR,
+		req.AuthTime,
+	)
+	if err != nil {
+		r.logger.Error("failed to create backchannel authentication request",
+			zap.String("auth_req_id", req.AuthReqID),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// FindByID finds a backchannel authentication request by auth_req_id
+func (r *BackchannelAuthRepository) FindByID(ctx context.Context, authReqID string) (*domain.BackchannelAuthRequest, error) {
+	query := `
+		SELECT auth_req_id, client_id, user_id, scopes, binding_message, client_notification_token,
+			status, poll_interval, last_polled_at, created_at, expires_at, amr, auth_time
+		FROM backchannel_auth_requests
+		WHERE auth_req_id = $1
+	`
+
+	req, err := scanBackchannelAuthRequest(r.db.QueryRow(ctx, query, authReqID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrInvalidAuthReqID
+		}
+		r.logger.Error("failed to find backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return req, nil
+}
+
+// ListPendingByUser lists the unexpired requests awaiting the user's answer
+func (r *BackchannelAuthRepository) ListPendingByUser(ctx context.Context, userID string) ([]*domain.BackchannelAuthRequest, error) {
+	query := `
+		SELECT auth_req_id, client_id, user_id, scopes, binding_message, client_notification_token,
+			status, poll_interval, last_polled_at, created_at, expires_at, amr, auth_time
+		FROM backchannel_auth_requests
+		WHERE user_id = $1 AND status = $2 AND expires_at > NOW()
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(ctx, query, userID, domain.BackchannelAuthStatusPending)
+	if err != nil {
+		r.logger.Error("failed to list backchannel authentication requests",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	requests := make([]*domain.BackchannelAuthRequest, 0)
+	for rows.Next() {
+		req, err := scanBackchannelAuthRequest(rows)
+		if err != nil {
+			r.logger.Error("failed to scan backchannel authentication request", zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		requests = append(requests, req)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("error iterating backchannel authentication requests", zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return requests, nil
+}
+
+// Update updates the status, interval, last poll time and approving sign-in of a request
+func (r *BackchannelAuthRepository) Update(ctx context.Context, req *domain.BackchannelAuthRequest) error {
+	query := `
+		UPDATE backchannel_auth_requests
+		SET status = $1, poll_interval = $2, last_polled_at = $3, amr = $4, auth_time = $5
+		WHERE auth_req_id = $6
+	`
+
+	err := r.db.Exec(ctx, query,
+		req.Status,
+		req.Interval,
+		req.LastPolledAt,
+		req.AMR,
+		req.AuthTime,
+		req.AuthReqID,
+	)
+	if err != nil {
+		r.logger.Error("failed to update backchannel authentication request",
+			zap.String("auth_req_id", req.AuthReqID),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// ConsumeApproved deletes an approved request and returns it. Concurrent redemptions of the same
+// auth_req_id race on the delete, so only one of them gets the request.
+func (r *BackchannelAuthRepository) ConsumeApproved(ctx context.Context, authReqID string) (*domain.BackchannelAuthRequest, error) {
+	query := `
+		DELETE FROM backchannel_auth_requests
+		WHERE auth_req_id = $1 AND status = $2
+		RETURNING auth_req_id, client_id, user_id, scopes, binding_message, client_notification_token,
+			status, poll_interval, last_polled_at, created_at, expires_at, amr, auth_time
+	`
+
+	req, err := scanBackchannelAuthRequest(r.db.QueryRow(ctx, query, authReqID, domain.BackchannelAuthStatusApproved))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrInvalidAuthReqID
+		}
+		r.logger.Error("failed to consume backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return req, nil
+}
+
+// Delete deletes a backchannel authentication request
+func (r *BackchannelAuthRepository) Delete(ctx context.Context, authReqID string) error {
+	query := `
+		DELETE FROM backchannel_auth_requests
+		WHERE auth_req_id = $1
+	`
+
+	err := r.db.Exec(ctx, query, authReqID)
+	if err != nil {
+		r.logger.Error("failed to delete backchannel authentication request",
+			zap.String("auth_req_id", authReqID),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+func scanBackchannelAuthRequest(row pgx.Row) (*domain.BackchannelAuthRequest, error) {
+	var req domain.BackchannelAuthRequest
+	err := row.Scan(
+		&req.AuthReqID,
+		&req.ClientID,
+		&req.UserID,
+		&req.Scopes,
+		&req.BindingMessage,
+		&req.ClientNotificationToken,
+		&req.Status,
+		&req.Interval,
+		&req.LastPolledAt,
+		&req.CreatedAt,
+		&req.ExpiresAt,
+		&req.AMR,
+		&req.AuthTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
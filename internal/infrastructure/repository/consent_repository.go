@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// ConsentRepository implements the consent repository interface
+type ConsentRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewConsentRepository creates a new consent repository
+func NewConsentRepository(db *database.Postgres, logger *zap.Logger) *ConsentRepository {
+	return &ConsentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const consentColumns = `user_id, client_id, scopes, created_at, updated_at`
+
+// Find retrieves the consent of the user for the client
+func (r *ConsentRepository) Find(ctx context.Context, userID ulid.ULID, clientID string) (*domain.Consent, error) {
+	query := `
+		SELECT ` + consentColumns + `
+		FROM oauth2_consents
+		WHERE user_id = $1 AND client_id = $2
+	`
+
+	consent, err := scanConsent(r.db.QueryRow(ctx, query, userID.String(), clientID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrConsentNotFound
+		}
+		r.logger.Error("failed to find consent",
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return consent, nil
+}
+
+// Save creates or replaces the consent of the user for the client
+func (r *ConsentRepository) Save(ctx context.Context, consent *domain.Consent) error {
+	query := `
+		INSERT INTO oauth2_consents (` + consentColumns + `)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = EXCLUDED.scopes, updated_at = EXCLUDED.updated_at
+	`
+
+	err := r.db.Exec(ctx, query,
+		consent.UserID.String(),
+		consent.ClientID,
+		nonNil(consent.Scopes),
+		consent.CreatedAt,
+		consent.UpdatedAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to save consent",
+			zap.String("user_id", consent.UserID.String()),
+			zap.String("client_id", consent.ClientID),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// ListByUser lists the consents of a user, oldest first
+func (r *ConsentRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.Consent, error) {
+	query := `
+		SELECT ` + consentColumns + `
+		FROM oauth2_consents
+		WHERE user_id = $1
+		ORDER BY created_at, client_id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String())
+	if err != nil {
+		r.logger.Error("failed to list consents",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	consents := []*domain.Consent{}
+	for rows.Next() {
+		consent, err := scanConsent(rows)
+		if err != nil {
+			r.logger.Error("failed to scan consent",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		consents = append(consents, consent)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list consents",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return consents, nil
+}
+
+// Delete removes the consent of the user for the client
+func (r *ConsentRepository) Delete(ctx context.Context, userID ulid.ULID, clientID string) error {
+	query := `
+		DELETE FROM oauth2_consents
+		WHERE user_id = $1 AND client_id = $2
+	`
+
+	if err := r.db.Exec(ctx, query, userID.String(), clientID); err != nil {
+		r.logger.Error("failed to delete consent",
+			zap.String("user_id", userID.String()),
+			zap.String("client_id", clientID),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+func scanConsent(row pgx.Row) (*domain.Consent, error) {
+	var consent domain.Consent
+	err := row.Scan(
+		&consent.UserID,
+		&consent.ClientID,
+		&consent.Scopes,
+		&consent.CreatedAt,
+		&consent.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
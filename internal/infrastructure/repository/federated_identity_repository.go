@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// FederatedIdentityRepository implements the federated identity repository interface
+type FederatedIdentityRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewFederatedIdentityRepository creates a new federated identity repository
+func NewFederatedIdentityRepository(db *database.Postgres, logger *zap.Logger) *FederatedIdentityRepository {
+	return &FederatedIdentityRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const federatedIdentityColumns = `id, user_id, provider, subject, email, created_at, last_login_at`
+
+// Create stores a new link
+func (r *FederatedIdentityRepository) Create(ctx context.Context, identity *domain.FederatedIdentity) error {
+	query := `
+		INSERT INTO identities (` + federatedIdentityColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	err := r.db.Exec(ctx, query,
+		identity.ID.String(),
+		identity.UserID.String(),
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+		identity.LastLoginAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to create federated identity",
+			zap.String("user_id", identity.UserID.String()),
+			zap.String("provider", identity.Provider),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// FindByProviderSubject retrieves the link of a provider's user
+func (r *FederatedIdentityRepository) FindByProviderSubject(ctx context.Context, provider, subject string) (*domain.FederatedIdentity, error) {
+	query := `
+		SELECT ` + federatedIdentityColumns + `
+		FROM identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	identity, err := scanFederatedIdentity(r.db.QueryRow(ctx, query, provider, subject))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrFederatedIdentityNotFound
+		}
+		r.logger.Error("failed to find federated identity",
+			zap.String("provider", provider),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return identity, nil
+}
+
+// ListByUser lists the links of a user, oldest first
+func (r *FederatedIdentityRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.FederatedIdentity, error) {
+	query := `
+		SELECT ` + federatedIdentityColumns + `
+		FROM identities
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String())
+	if err != nil {
+		r.logger.Error("failed to list federated identities",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	identities := []*domain.FederatedIdentity{}
+	for rows.Next() {
+		identity, err := scanFederatedIdentity(rows)
+		if err != nil {
+			r.logger.Error("failed to scan federated identity",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list federated identities",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return identities, nil
+}
+
+// Touch records a sign-in through a link, along with the email the provider now reports
+func (r *FederatedIdentityRepository) Touch(ctx context.Context, id ulid.ULID, email string, at time.Time) error {
+	query := `
+		UPDATE identities
+		SET email = $2, last_login_at = $3
+		WHERE id = $1
+	`
+
+	if err := r.db.Exec(ctx, query, id.String(), email, at); err != nil {
+		r.logger.Error("failed to record federated sign-in",
+			zap.String("identity_id", id.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+func scanFederatedIdentity(row pgx.Row) (*domain.FederatedIdentity, error) {
+	var identity domain.FederatedIdentity
+	err := row.Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&identity.LastLoginAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// FederatedLoginStateRepository implements the federated login state repository interface
+type FederatedLoginStateRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewFederatedLoginStateRepository creates a new federated login state repository
+func NewFederatedLoginStateRepository(db *database.Postgres, logger *zap.Logger) *FederatedLoginStateRepository {
+	return &FederatedLoginStateRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new state, clearing out sign-ins that were never finished
+func (r *FederatedLoginStateRepository) Create(ctx context.Context, state *domain.FederatedLoginState) error {
+	if err := r.db.Exec(ctx, `DELETE FROM federated_login_states WHERE expires_at < $1`, time.Now()); err != nil {
+		r.logger.Error("failed to delete expired federated login states", zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	query := `
+		INSERT INTO federated_login_states (id, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	err := r.db.Exec(ctx, query,
+		state.ID.String(),
+		state.Provider,
+		state.Nonce,
+		state.CodeVerifier,
+		state.ExpiresAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to create federated login state",
+			zap.String("provider", state.Provider),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// Consume retrieves and deletes a state, so that a callback can be handled only once
+func (r *FederatedLoginStateRepository) Consume(ctx context.Context, id ulid.ULID) (*domain.FederatedLoginState, error) {
+	query := `
+		DELETE FROM federated_login_states
+		WHERE id = $1
+		RETURNING id, provider, nonce, code_verifier, expires_at
+	`
+
+	var state domain.FederatedLoginState
+	err := r.db.QueryRow(ctx, query, id.String()).Scan(
+		&state.ID,
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ExpiresAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrFederatedLoginFailed
+		}
+		r.logger.Error("failed to consume federated login state",
+			zap.String("state", id.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return &state, nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"go.uber.org/zap"
+)
+
+// LoginAttemptRepository implements the failed login attempt repository interface
+type LoginAttemptRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewLoginAttemptRepository creates a new login attempt repository
+func NewLoginAttemptRepository(db *database.Postgres, logger *zap.Logger) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Get retrieves the attempts for a key, returning an empty record when there are none
+func (r *LoginAttemptRepository) Get(ctx context.Context, key string) (*domain.LoginAttempts, error) {
+	query := `
+		SELECT key, failures, last_failure_at, locked_until
+		FROM login_attempts
+		WHERE key = $1
+	`
+
+	var attempts domain.LoginAttempts
+	err := r.db.QueryRow(ctx, query, key).Scan(
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return &domain.LoginAttempts{Key: key}, nil
+		}
+		r.logger.Error("failed to get login attempts",
+			zap.String("key", key),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return &attempts, nil
+}
+
+// RecordFailure counts a failure, restarting the count when the previous failure is older than resetBefore
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, key string, at, resetBefore time.Time) (*domain.LoginAttempts, error) {
+	query := `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1 ELSE login_attempts.failures + 1 END,
+			locked_until = CASE WHEN login_attempts.last_failure_at < $3 THEN NULL ELSE login_attempts.locked_until END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING key, failures, last_failure_at, locked_until
+	`
+
+	var attempts domain.LoginAttempts
+	err := r.db.QueryRow(ctx, query, key, at, resetBefore).Scan(
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil,
+	)
+	if err != nil {
+		r.logger.Error("failed to record login failure",
+			zap.String("key", key),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return &attempts, nil
+}
+
+// Lock locks the key until the given time
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `
+		UPDATE login_attempts
+		SET locked_until = $2
+		WHERE key = $1
+	`
+
+	if err := r.db.Exec(ctx, query, key, until); err != nil {
+		r.logger.Error("failed to lock login attempts",
+			zap.String("key", key),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// Reset clears the attempts and any lock for a key
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	query := `
+		DELETE FROM login_attempts
+		WHERE key = $1
+	`
+
+	if err := r.db.Exec(ctx, query, key); err != nil {
+		r.logger.Error("failed to reset login attempts",
+			zap.String("key", key),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
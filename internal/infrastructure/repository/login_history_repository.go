@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// LoginHistoryRepository implements the login history repository interface
+type LoginHistoryRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewLoginHistoryRepository creates a new login history repository
+func NewLoginHistoryRepository(db *database.Postgres, logger *zap.Logger) *LoginHistoryRepository {
+	return &LoginHistoryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const loginEventColumns = `id, user_id, COALESCE(client_ip, ''), ip_prefix, COALESCE(user_agent, ''), device, amr,
+	new_device, COALESCE(revoke_token_hash, ''), revoked_at, created_at`
+
+// Create records a login
+func (r *LoginHistoryRepository) Create(ctx context.Context, event *domain.LoginEvent) error {
+	query := `
+		INSERT INTO login_history (id, user_id, client_ip, ip_prefix, user_agent, device, amr, new_device, revoke_token_hash, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''), $10)
+	`
+
+	err := r.db.Exec(ctx, query,
+		event.ID.String(),
+		event.UserID.String(),
+		event.ClientIP,
+		event.IPPrefix,
+		event.UserAgent,
+		event.Device,
+		event.Methods,
+		event.NewDevice,
+		event.RevokeTokenHash,
+		event.CreatedAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to record login",
+			zap.String("user_id", event.UserID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// FindByID retrieves a login
+func (r *LoginHistoryRepository) FindByID(ctx context.Context, id ulid.ULID) (*domain.LoginEvent, error) {
+	query := `
+		SELECT ` + loginEventColumns + `
+		FROM login_history
+		WHERE id = $1
+	`
+
+	event, err := scanLoginEvent(r.db.QueryRow(ctx, query, id.String()))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrInvalidSignInRevocation
+		}
+		r.logger.Error("failed to find login",
+			zap.String("login_id", id.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return event, nil
+}
+
+// ListByUser lists the latest logins of a user, newest first
+func (r *LoginHistoryRepository) ListByUser(ctx context.Context, userID ulid.ULID, limit int) ([]*domain.LoginEvent, error) {
+	query := `
+		SELECT ` + loginEventColumns + `
+		FROM login_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String(), limit)
+	if err != nil {
+		r.logger.Error("failed to list logins",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	events := []*domain.LoginEvent{}
+	for rows.Next() {
+		event, err := scanLoginEvent(rows)
+		if err != nil {
+			r.logger.Error("failed to scan login",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list logins",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return events, nil
+}
+
+// IsNewDevice reports whether a user who signed in before never did from the device
+func (r *LoginHistoryRepository) IsNewDevice(ctx context.Context, userID ulid.ULID, ipPrefix, device string) (bool, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM login_history WHERE user_id = $1)
+			AND NOT EXISTS (SELECT 1 FROM login_history WHERE user_id = $1 AND ip_prefix = $2 AND device = $3)
+	`
+
+	var isNew bool
+	if err := r.db.QueryRow(ctx, query, userID.String(), ipPrefix, device).Scan(&isNew); err != nil {
+		r.logger.Error("failed to look up login device",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return false, domain.ErrDatabaseQuery
+	}
+
+	return isNew, nil
+}
+
+// Revoke marks a login as revoked and forgets the token of its revocation link, so the link
+// works once
+func (r *LoginHistoryRepository) Revoke(ctx context.Context, id ulid.ULID, revokedAt time.Time) error {
+	query := `
+		UPDATE login_history
+		SET revoked_at = $2, revoke_token_hash = NULL
+		WHERE id = $1 AND revoke_token_hash IS NOT NULL
+		RETURNING id
+	`
+
+	var revoked string
+	if err := r.db.QueryRow(ctx, query, id.String(), revokedAt).Scan(&revoked); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrInvalidSignInRevocation
+		}
+		r.logger.Error("failed to revoke login",
+			zap.String("login_id", id.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+func scanLoginEvent(row pgx.Row) (*domain.LoginEvent, error) {
+	var event domain.LoginEvent
+	err := row.Scan(
+		&event.ID,
+		&event.UserID,
+		&event.ClientIP,
+		&event.IPPrefix,
+		&event.UserAgent,
+		&event.Device,
+		&event.Methods,
+		&event.NewDevice,
+		&event.RevokeTokenHash,
+		&event.RevokedAt,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// MFAFactorRepository implements the MFA factor registry interface
+type MFAFactorRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewMFAFactorRepository creates a new MFA factor repository
+func NewMFAFactorRepository(db *database.Postgres, logger *zap.Logger) *MFAFactorRepository {
+	return &MFAFactorRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const mfaFactorColumns = `id, user_id, type, label, created_at, last_used_at`
+
+// Create registers a new factor
+func (r *MFAFactorRepository) Create(ctx context.Context, factor *domain.MFAFactor) error {
+	query := `
+		INSERT INTO mfa_factors (` + mfaFactorColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	err := r.db.Exec(ctx, query,
+		factor.ID.String(),
+		factor.UserID.String(),
+		string(factor.Type),
+		factor.Label,
+		factor.CreatedAt,
+		factor.LastUsedAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to create MFA factor",
+			zap.String("user_id", factor.UserID.String()),
+			zap.String("type", string(factor.Type)),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// ListByUser lists the factors of a user, oldest first
+func (r *MFAFactorRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.MFAFactor, error) {
+	query := `
+		SELECT ` + mfaFactorColumns + `
+		FROM mfa_factors
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String())
+	if err != nil {
+		r.logger.Error("failed to list MFA factors",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	factors := []*domain.MFAFactor{}
+	for rows.Next() {
+		var factor domain.MFAFactor
+		if err := rows.Scan(
+			&factor.ID,
+			&factor.UserID,
+			&factor.Type,
+			&factor.Label,
+			&factor.CreatedAt,
+			&factor.LastUsedAt,
+		); err != nil {
+			r.logger.Error("failed to scan MFA factor",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		factors = append(factors, &factor)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list MFA factors",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return factors, nil
+}
+
+// Touch records a successful use of a factor
+func (r *MFAFactorRepository) Touch(ctx context.Context, id ulid.ULID, usedAt time.Time) error {
+	query := `
+		UPDATE mfa_factors
+		SET last_used_at = $2
+		WHERE id = $1
+	`
+
+	if err := r.db.Exec(ctx, query, id.String(), usedAt); err != nil {
+		r.logger.Error("failed to record MFA factor use",
+			zap.String("factor_id", id.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// Rename changes the label of one of the user's factors
+func (r *MFAFactorRepository) Rename(ctx context.Context, userID, id ulid.ULID, label string) error {
+	query := `
+		UPDATE mfa_factors
+		SET label = $3
+		WHERE id = $1 AND user_id = $2
+		RETURNING id
+	`
+
+	return r.execOwned(ctx, "rename", query, id.String(), userID.String(), label)
+}
+
+// Delete removes one of the user's factors; its TOTP secret or backup codes go with it
+func (r *MFAFactorRepository) Delete(ctx context.Context, userID, id ulid.ULID) error {
+	query := `
+		DELETE FROM mfa_factors
+		WHERE id = $1 AND user_id = $2
+		RETURNING id
+	`
+
+	return r.execOwned(ctx, "delete", query, id.String(), userID.String())
+}
+
+// execOwned runs a statement on a factor of the user, reporting a factor of someone else as not found
+func (r *MFAFactorRepository) execOwned(ctx context.Context, action, query string, args ...interface{}) error {
+	var id string
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&id); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrMFAFactorNotFound
+		}
+		r.logger.Error("failed to "+action+" MFA factor",
+			zap.Any("factor_id", args[0]),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// MFAResetRepository implements the MFA reset interface
+type MFAResetRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewMFAResetRepository creates a new MFA reset repository
+func NewMFAResetRepository(db *database.Postgres, logger *zap.Logger) *MFAResetRepository {
+	return &MFAResetRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const mfaResetColumns = `id, user_id, admin_id, verification_method, reason, removed_factors, client_ip, created_at`
+
+// Reset removes every factor, passkey, pending enrolment and trusted device of the user and
+// records the reset in the same statement, so factors are never removed without a trace. TOTP
+// secrets and backup codes go with their factors.
+func (r *MFAResetRepository) Reset(ctx context.Context, reset *domain.MFAReset) error {
+	query := `
+		WITH factors AS (
+			DELETE FROM mfa_factors WHERE user_id = $2
+		), credentials AS (
+			DELETE FROM webauthn_credentials WHERE user_id = $2
+		), pending AS (
+			DELETE FROM totp_enrollments WHERE user_id = $2
+		), devices AS (
+			DELETE FROM trusted_devices WHERE user_id = $2
+		)
+		INSERT INTO mfa_resets (` + mfaResetColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+	`
+
+	removed := make([]string, len(reset.RemovedFactors))
+	for i, factorType := range reset.RemovedFactors {
+		removed[i] = string(factorType)
+	}
+
+	err := r.db.Exec(ctx, query,
+		reset.ID.String(),
+		reset.UserID.String(),
+		reset.AdminID.String(),
+		string(reset.VerificationMethod),
+		reset.Reason,
+		removed,
+		reset.ClientIP,
+		reset.CreatedAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to reset MFA",
+			zap.String("user_id", reset.UserID.String()),
+			zap.String("admin_id", reset.AdminID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// ListByUser lists the MFA resets of a user, newest first
+func (r *MFAResetRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.MFAReset, error) {
+	query := `
+		SELECT id, user_id, admin_id, verification_method, reason, removed_factors, COALESCE(client_ip, ''), created_at
+		FROM mfa_resets
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String())
+	if err != nil {
+		r.logger.Error("failed to list MFA resets",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	resets := []*domain.MFAReset{}
+	for rows.Next() {
+		var reset domain.MFAReset
+		var removed []string
+		if err := rows.Scan(
+			&reset.ID,
+			&reset.UserID,
+			&reset.AdminID,
+			&reset.VerificationMethod,
+			&reset.Reason,
+			&removed,
+			&reset.ClientIP,
+			&reset.CreatedAt,
+		); err != nil {
+			r.logger.Error("failed to scan MFA reset",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		reset.RemovedFactors = make([]domain.MFAFactorType, len(removed))
+		for i, factorType := range removed {
+			reset.RemovedFactors[i] = domain.MFAFactorType(factorType)
+		}
+		resets = append(resets, &reset)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list MFA resets",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return resets, nil
+}
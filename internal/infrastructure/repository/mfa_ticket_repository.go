@@ -26,8 +26,8 @@ func NewMFATicketRepository(db *database.Postgres, logger *zap.Logger) *MFATicke
 // Create creates a new MFA ticket
 func (r *MFATicketRepository) Create(ctx context.Context, ticket *domain.MFATicket) error {
 	query := `
-		INSERT INTO mfa_tickets (id, user_id, created_at, expires_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO mfa_tickets (id, user_id, created_at, expires_at, enrollment_required, amr, risk_challenge)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
 	err := r.db.Exec(ctx, query,
@@ -35,6 +35,9 @@ func (r *MFATicketRepository) Create(ctx context.Context, ticket *domain.MFATick
 		ticket.User,
 		ticket.CreatedAt,
 		ticket.ExpiresAt,
+		ticket.EnrollmentRequired,
+		ticket.AMR,
+		ticket.RiskChallenge,
 	)
 	if err != nil {
 		r.logger.Error("failed to create MFA ticket",
@@ -49,7 +52,7 @@ func (r *MFATicketRepository) Create(ctx context.Context, ticket *domain.MFATick
 // Get retrieves an MFA ticket by ID
 func (r *MFATicketRepository) Get(ctx context.Context, id string) (*domain.MFATicket, error) {
 	query := `
-		SELECT id, user_id, created_at, expires_at
+		SELECT id, user_id, created_at, expires_at, attempts, enrollment_required, amr, risk_challenge
 		FROM mfa_tickets
 		WHERE id = $1
 	`
@@ -60,6 +63,10 @@ func (r *MFATicketRepository) Get(ctx context.Context, id string) (*domain.MFATi
 		&ticket.User,
 		&ticket.CreatedAt,
 		&ticket.ExpiresAt,
+		&ticket.Attempts,
+		&ticket.EnrollmentRequired,
+		&ticket.AMR,
+		&ticket.RiskChallenge,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -74,6 +81,30 @@ func (r *MFATicketRepository) Get(ctx context.Context, id string) (*domain.MFATi
 	return &ticket, nil
 }
 
+// IncrementAttempts records a failed verification against an MFA ticket and returns the new count
+func (r *MFATicketRepository) IncrementAttempts(ctx context.Context, id string) (int, error) {
+	query := `
+		UPDATE mfa_tickets
+		SET attempts = attempts + 1
+		WHERE id = $1
+		RETURNING attempts
+	`
+
+	var attempts int
+	err := r.db.QueryRow(ctx, query, id).Scan(&attempts)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return 0, domain.ErrInvalidMFATicket
+		}
+		r.logger.Error("failed to increment MFA ticket attempts",
+			zap.String("ticket_id", id),
+			zap.Error(err))
+		return 0, domain.ErrDatabaseQuery
+	}
+
+	return attempts, nil
+}
+
 // Delete deletes an MFA ticket
 func (r *MFATicketRepository) Delete(ctx context.Context, id string) error {
 	query := `
@@ -24,18 +24,26 @@ func NewOAuth2Repository(db *database.Postgres, logger *zap.Logger) domain.OAuth
 
 func (r *PostgresOAuth2Repository) CreateClient(ctx context.Context, client *domain.OAuth2Client) error {
 	return r.db.Exec(ctx, `
-		INSERT INTO oauth2_clients (id, secret, redirect_uris, grant_types, scopes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, client.ID, client.Secret, client.RedirectURIs, client.GrantTypes, client.Scopes, client.CreatedAt, client.UpdatedAt)
+		INSERT INTO oauth2_clients (id, secret, application_type, redirect_uris, redirect_uri_patterns, grant_types, scopes, subject_type, sector_identifier_uri,
+			jwks_uri, userinfo_signed_response_alg, id_token_encrypted_response_alg, id_token_encrypted_response_enc,
+			backchannel_token_delivery_mode, backchannel_client_notification_endpoint, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+	`, client.ID, client.Secret, client.ApplicationType, client.RedirectURIs, redirectURIPatterns(client), client.GrantTypes, client.Scopes, subjectType(client), client.SectorIdentifierURI,
+		client.JWKSURI, client.UserInfoSignedResponseAlg, client.IDTokenEncryptedResponseAlg, client.IDTokenEncryptedResponseEnc,
+		client.BackchannelTokenDeliveryMode, client.BackchannelClientNotificationEndpoint, client.CreatedAt, client.UpdatedAt)
 }
 
 func (r *PostgresOAuth2Repository) FindClientByID(ctx context.Context, id string) (*domain.OAuth2Client, error) {
 	client := &domain.OAuth2Client{}
 
 	err := r.db.QueryRow(ctx, `
-		SELECT id, secret, redirect_uris, grant_types, scopes, created_at, updated_at
+		SELECT id, secret, application_type, redirect_uris, redirect_uri_patterns, grant_types, scopes, subject_type, sector_identifier_uri,
+			jwks_uri, userinfo_signed_response_alg, id_token_encrypted_response_alg, id_token_encrypted_response_enc,
+			backchannel_token_delivery_mode, backchannel_client_notification_endpoint, created_at, updated_at
 		FROM oauth2_clients WHERE id = $1
-	`, id).Scan(&client.ID, &client.Secret, &client.RedirectURIs, &client.GrantTypes, &client.Scopes, &client.CreatedAt, &client.UpdatedAt)
+	`, id).Scan(&client.ID, &client.Secret, &client.ApplicationType, &client.RedirectURIs, &client.RedirectURIPatterns, &client.GrantTypes, &client.Scopes, &client.SubjectType, &client.SectorIdentifierURI,
+		&client.JWKSURI, &client.UserInfoSignedResponseAlg, &client.IDTokenEncryptedResponseAlg, &client.IDTokenEncryptedResponseEnc,
+		&client.BackchannelTokenDeliveryMode, &client.BackchannelClientNotificationEndpoint, &client.CreatedAt, &client.UpdatedAt)
 	if err != nil {
 		r.logger.Error("failed to find client by id", zap.Error(err))
 		return nil, domain.ErrClientNotFound
@@ -48,9 +56,13 @@ func (r *PostgresOAuth2Repository) UpdateClient(ctx context.Context, client *dom
 
 	return r.db.Exec(ctx, `
 		UPDATE oauth2_clients
-		SET secret = $1, redirect_uris = $2, grant_types = $3, scopes = $4, updated_at = $5
-		WHERE id = $6
-	`, client.Secret, client.RedirectURIs, client.GrantTypes, client.Scopes, client.UpdatedAt, client.ID)
+		SET secret = $1, application_type = $2, redirect_uris = $3, redirect_uri_patterns = $4, grant_types = $5, scopes = $6, subject_type = $7, sector_identifier_uri = $8,
+			jwks_uri = $9, userinfo_signed_response_alg = $10, id_token_encrypted_response_alg = $11, id_token_encrypted_response_enc = $12,
+			backchannel_token_delivery_mode = $13, backchannel_client_notification_endpoint = $14, updated_at = $15
+		WHERE id = $16
+	`, client.Secret, client.ApplicationType, client.RedirectURIs, redirectURIPatterns(client), client.GrantTypes, client.Scopes, subjectType(client), client.SectorIdentifierURI,
+		client.JWKSURI, client.UserInfoSignedResponseAlg, client.IDTokenEncryptedResponseAlg, client.IDTokenEncryptedResponseEnc,
+		client.BackchannelTokenDeliveryMode, client.BackchannelClientNotificationEndpoint, client.UpdatedAt, client.ID)
 }
 
 func (r *PostgresOAuth2Repository) DeleteClient(ctx context.Context, id string) error {
@@ -59,7 +71,9 @@ func (r *PostgresOAuth2Repository) DeleteClient(ctx context.Context, id string)
 
 func (r *PostgresOAuth2Repository) ListClients(ctx context.Context) ([]*domain.OAuth2Client, error) {
 	rows, err := r.db.Query(ctx, `
-		SELECT id, secret, redirect_uris, grant_types, scopes, created_at, updated_at
+		SELECT id, secret, application_type, redirect_uris, redirect_uri_patterns, grant_types, scopes, subject_type, sector_identifier_uri,
+			jwks_uri, userinfo_signed_response_alg, id_token_encrypted_response_alg, id_token_encrypted_response_enc,
+			backchannel_token_delivery_mode, backchannel_client_notification_endpoint, created_at, updated_at
 		FROM oauth2_clients
 		ORDER BY created_at DESC
 	`)
@@ -72,7 +86,9 @@ func (r *PostgresOAuth2Repository) ListClients(ctx context.Context) ([]*domain.O
 	for rows.Next() {
 		client := &domain.OAuth2Client{}
 
-		err := rows.Scan(&client.ID, &client.Secret, &client.RedirectURIs, &client.GrantTypes, &client.Scopes, &client.CreatedAt, &client.UpdatedAt)
+		err := rows.Scan(&client.ID, &client.Secret, &client.ApplicationType, &client.RedirectURIs, &client.RedirectURIPatterns, &client.GrantTypes, &client.Scopes, &client.SubjectType, &client.SectorIdentifierURI,
+			&client.JWKSURI, &client.UserInfoSignedResponseAlg, &client.IDTokenEncryptedResponseAlg, &client.IDTokenEncryptedResponseEnc,
+			&client.BackchannelTokenDeliveryMode, &client.BackchannelClientNotificationEndpoint, &client.CreatedAt, &client.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -88,20 +104,36 @@ func (r *PostgresOAuth2Repository) ListClients(ctx context.Context) ([]*domain.O
 	return clients, nil
 }
 
+// redirectURIPatterns avoids writing NULL into the NOT NULL patterns column
+func redirectURIPatterns(client *domain.OAuth2Client) []string {
+	if client.RedirectURIPatterns == nil {
+		return []string{}
+	}
+	return client.RedirectURIPatterns
+}
+
+// subjectType stores clients registered without a subject type as public clients
+func subjectType(client *domain.OAuth2Client) string {
+	if client.SubjectType == "" {
+		return domain.SubjectTypePublic
+	}
+	return client.SubjectType
+}
+
 func (r *PostgresOAuth2Repository) CreateAuthorizationCode(ctx context.Context, code *domain.AuthorizationCode) error {
 	return r.db.Exec(ctx, `
-		INSERT INTO authorization_codes (code, client_id, user_id, scopes, expires_at, created_at, code_verifier, code_challenge, code_challenge_method)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, code.Code, code.ClientID, code.UserID, code.Scopes, code.ExpiresAt, code.CreatedAt, code.CodeVerifier, code.CodeChallenge, code.CodeChallengeMethod)
+		INSERT INTO authorization_codes (code, client_id, user_id, scopes, expires_at, created_at, code_verifier, code_challenge, code_challenge_method, claims_request, amr, auth_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, code.Code, code.ClientID, code.UserID, code.Scopes, code.ExpiresAt, code.CreatedAt, code.CodeVerifier, code.CodeChallenge, code.CodeChallengeMethod, code.ClaimsRequest, code.AMR, code.AuthTime)
 }
 
 func (r *PostgresOAuth2Repository) GetAuthorizationCode(ctx context.Context, code string) (*domain.AuthorizationCode, error) {
 	authCode := &domain.AuthorizationCode{}
 
 	err := r.db.QueryRow(ctx, `
-		SELECT code, client_id, user_id, scopes, expires_at, created_at, code_verifier, code_challenge, code_challenge_method
+		SELECT code, client_id, user_id, scopes, expires_at, created_at, code_verifier, code_challenge, code_challenge_method, claims_request, amr, auth_time
 		FROM authorization_codes WHERE code = $1
-	`, code).Scan(&authCode.Code, &authCode.ClientID, &authCode.UserID, &authCode.Scopes, &authCode.ExpiresAt, &authCode.CreatedAt, &authCode.CodeVerifier, &authCode.CodeChallenge, &authCode.CodeChallengeMethod)
+	`, code).Scan(&authCode.Code, &authCode.ClientID, &authCode.UserID, &authCode.Scopes, &authCode.ExpiresAt, &authCode.CreatedAt, &authCode.CodeVerifier, &authCode.CodeChallenge, &authCode.CodeChallengeMethod, &authCode.ClaimsRequest, &authCode.AMR, &authCode.AuthTime)
 	if err != nil {
 		r.logger.Error("failed to get authorization code", zap.Error(err))
 		return nil, domain.ErrInvalidAuthorizationCode
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// PairwiseSubjectRepository implements the pairwise subject repository interface
+type PairwiseSubjectRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewPairwiseSubjectRepository creates a new pairwise subject repository
+func NewPairwiseSubjectRepository(db *database.Postgres, logger *zap.Logger) *PairwiseSubjectRepository {
+	return &PairwiseSubjectRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save records the subject identifier of the user within the sector
+func (r *PairwiseSubjectRepository) Save(ctx context.Context, sector, subject string, userID ulid.ULID) error {
+	query := `
+		INSERT INTO pairwise_subjects (sector_identifier, subject, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (sector_identifier, subject) DO NOTHING
+	`
+
+	if err := r.db.Exec(ctx, query, sector, subject, userID.String()); err != nil {
+		r.logger.Error("failed to save pairwise subject",
+			zap.String("sector", sector),
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// FindUserID returns the user the subject identifier was issued for within the sector
+func (r *PairwiseSubjectRepository) FindUserID(ctx context.Context, sector, subject string) (ulid.ULID, error) {
+	query := `
+		SELECT user_id
+		FROM pairwise_subjects
+		WHERE sector_identifier = $1 AND subject = $2
+	`
+
+	var userID ulid.ULID
+	if err := r.db.QueryRow(ctx, query, sector, subject).Scan(&userID); err != nil {
+		if err == pgx.ErrNoRows {
+			return ulid.ULID{}, domain.ErrPairwiseSubjectNotFound
+		}
+		r.logger.Error("failed to find pairwise subject",
+			zap.String("sector", sector),
+			zap.Error(err))
+		return ulid.ULID{}, domain.ErrDatabaseQuery
+	}
+
+	return userID, nil
+}
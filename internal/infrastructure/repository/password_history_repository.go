@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// PasswordHistoryRepository implements the password history repository interface
+type PasswordHistoryRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewPasswordHistoryRepository creates a new password history repository
+func NewPasswordHistoryRepository(db *database.Postgres, logger *zap.Logger) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Add stores a password hash for the user, keeping only the newest keep entries
+func (r *PasswordHistoryRepository) Add(ctx context.Context, userID ulid.ULID, hashedPassword string, keep int) error {
+	query := `
+		INSERT INTO password_history (user_id, password_hash, created_at)
+		VALUES ($1, $2, $3)
+	`
+
+	if err := r.db.Exec(ctx, query, userID.String(), hashedPassword, time.Now()); err != nil {
+		r.logger.Error("failed to add password history",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	query = `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		)
+	`
+
+	if err := r.db.Exec(ctx, query, userID.String(), keep); err != nil {
+		r.logger.Error("failed to prune password history",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// ListRecent lists the user's newest password hashes, newest first
+func (r *PasswordHistoryRepository) ListRecent(ctx context.Context, userID ulid.ULID, limit int) ([]string, error) {
+	query := `
+		SELECT password_hash
+		FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String(), limit)
+	if err != nil {
+		r.logger.Error("failed to list password history",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			r.logger.Error("failed to scan password history",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("error iterating password history", zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return hashes, nil
+}
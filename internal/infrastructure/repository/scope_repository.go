@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"go.uber.org/zap"
+)
+
+// ScopeRepository implements the scope repository interface
+type ScopeRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewScopeRepository creates a new scope repository
+func NewScopeRepository(db *database.Postgres, logger *zap.Logger) *ScopeRepository {
+	return &ScopeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create creates a new scope
+func (r *ScopeRepository) Create(ctx context.Context, scope *domain.Scope) error {
+	query := `
+		INSERT INTO scopes (name, description, claims, requires_consent, allowed_clients, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	err := r.db.Exec(ctx, query,
+		scope.Name,
+		scope.Description,
+		nonNil(scope.Claims),
+		scope.RequiresConsent,
+		nonNil(scope.AllowedClients),
+		scope.CreatedAt,
+		scope.UpdatedAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to create scope",
+			zap.String("scope", scope.Name),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// FindByName finds a scope by name
+func (r *ScopeRepository) FindByName(ctx context.Context, name string) (*domain.Scope, error) {
+	query := `
+		SELECT name, description, claims, requires_consent, allowed_clients, created_at, updated_at
+		FROM scopes
+		WHERE name = $1
+	`
+
+	var scope domain.Scope
+	err := r.db.QueryRow(ctx, query, name).Scan(
+		&scope.Name,
+		&scope.Description,
+		&scope.Claims,
+		&scope.RequiresConsent,
+		&scope.AllowedClients,
+		&scope.CreatedAt,
+		&scope.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrScopeNotFound
+		}
+		r.logger.Error("failed to find scope",
+			zap.String("scope", name),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return &scope, nil
+}
+
+// List lists all scopes
+func (r *ScopeRepository) List(ctx context.Context) ([]*domain.Scope, error) {
+	query := `
+		SELECT name, description, claims, requires_consent, allowed_clients, created_at, updated_at
+		FROM scopes
+		ORDER BY name
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		r.logger.Error("failed to list scopes", zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	scopes := make([]*domain.Scope, 0)
+	for rows.Next() {
+		var scope domain.Scope
+		err := rows.Scan(
+			&scope.Name,
+			&scope.Description,
+			&scope.Claims,
+			&scope.RequiresConsent,
+			&scope.AllowedClients,
+			&scope.CreatedAt,
+			&scope.UpdatedAt,
+		)
+		if err != nil {
+			r.logger.Error("failed to scan scope", zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		scopes = append(scopes, &scope)
+	}
+
+	if err := rows.Err(); err != nil {
+		r.logger.Error("error iterating scopes", zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return scopes, nil
+}
+
+// Update updates a scope
+func (r *ScopeRepository) Update(ctx context.Context, scope *domain.Scope) error {
+	query := `
+		UPDATE scopes
+		SET description = $1, claims = $2, requires_consent = $3, allowed_clients = $4, updated_at = $5
+		WHERE name = $6
+	`
+
+	err := r.db.Exec(ctx, query,
+		scope.Description,
+		nonNil(scope.Claims),
+		scope.RequiresConsent,
+		nonNil(scope.AllowedClients),
+		scope.UpdatedAt,
+		scope.Name,
+	)
+	if err != nil {
+		r.logger.Error("failed to update scope",
+			zap.String("scope", scope.Name),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// Delete deletes a scope
+func (r *ScopeRepository) Delete(ctx context.Context, name string) error {
+	query := `
+		DELETE FROM scopes
+		WHERE name = $1
+	`
+
+	err := r.db.Exec(ctx, query, name)
+	if err != nil {
+		r.logger.Error("failed to delete scope",
+			zap.String("scope", name),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// nonNil avoids writing NULL into NOT NULL array columns
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
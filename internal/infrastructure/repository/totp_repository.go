@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/manorfm/authM/internal/domain"
 	"github.com/manorfm/authM/internal/infrastructure/database"
 	"github.com/jackc/pgx/v5"
+	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 )
 
@@ -25,24 +27,34 @@ func NewTOTPRepository(db *database.Postgres, logger *zap.Logger) *TOTPRepositor
 	}
 }
 
-// SaveTOTPSecret saves a TOTP secret for a user
-func (r *TOTPRepository) SaveTOTPSecret(ctx context.Context, userID string, secret string) error {
+// SaveTOTPSecret registers an authenticator app factor together with its secret
+func (r *TOTPRepository) SaveTOTPSecret(ctx context.Context, factor *domain.MFAFactor, secret string) error {
 	if secret == "" {
 		r.logger.Error("invalid secret")
 		return domain.ErrInternal
 	}
 
 	query := `
-		INSERT INTO totp_secrets (user_id, secret)
-		VALUES ($1, $2)
-		ON CONFLICT (user_id) DO UPDATE
-		SET secret = $2
+		WITH factor AS (
+			INSERT INTO mfa_factors (id, user_id, type, label, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, user_id
+		)
+		INSERT INTO totp_secrets (factor_id, user_id, secret)
+		SELECT id, user_id, $6 FROM factor
 	`
 
-	err := r.db.Exec(ctx, query, userID, secret)
+	err := r.db.Exec(ctx, query,
+		factor.ID.String(),
+		factor.UserID.String(),
+		string(domain.MFAFactorTOTP),
+		factor.Label,
+		factor.CreatedAt,
+		secret,
+	)
 	if err != nil {
 		r.logger.Error("failed to save TOTP secret",
-			zap.String("user_id", userID),
+			zap.String("user_id", factor.UserID.String()),
 			zap.String("query", query),
 			zap.String("error_type", fmt.Sprintf("%T", err)),
 			zap.Error(err))
@@ -52,28 +64,99 @@ func (r *TOTPRepository) SaveTOTPSecret(ctx context.Context, userID string, secr
 	return nil
 }
 
-// GetTOTPSecret retrieves a TOTP secret for a user
-func (r *TOTPRepository) GetTOTPSecret(ctx context.Context, userID string) (string, error) {
+// GetTOTPSecret retrieves the secret of one of the user's authenticator apps
+func (r *TOTPRepository) GetTOTPSecret(ctx context.Context, userID, factorID string) (*domain.TOTPSecret, error) {
 	query := `
-		SELECT secret
+		SELECT factor_id, secret, algorithm, digits, period
 		FROM totp_secrets
-		WHERE user_id = $1
+		WHERE factor_id = $1 AND user_id = $2
 	`
 
-	var secret string
-	err := r.db.QueryRow(ctx, query, userID).Scan(&secret)
+	secret, err := scanTOTPSecret(r.db.QueryRow(ctx, query, factorID, userID))
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return "", domain.ErrTOTPNotEnabled
+			return nil, domain.ErrTOTPNotEnabled
 		}
 		r.logger.Error("failed to get TOTP secret", zap.Error(err))
-		return "", domain.ErrDatabaseQuery
+		return nil, domain.ErrDatabaseQuery
 	}
 
 	return secret, nil
 }
 
-// SaveBackupCodes saves backup codes for a user
+// ListTOTPSecrets retrieves the secrets of all the user's authenticator apps
+func (r *TOTPRepository) ListTOTPSecrets(ctx context.Context, userID string) ([]*domain.TOTPSecret, error) {
+	query := `
+		SELECT factor_id, secret, algorithm, digits, period
+		FROM totp_secrets
+		WHERE user_id = $1
+		ORDER BY factor_id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("failed to list TOTP secrets", zap.String("user_id", userID), zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	var secrets []*domain.TOTPSecret
+	for rows.Next() {
+		secret, err := scanTOTPSecret(rows)
+		if err != nil {
+			r.logger.Error("failed to scan TOTP secret", zap.String("user_id", userID), zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		secrets = append(secrets, secret)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list TOTP secrets", zap.String("user_id", userID), zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	if len(secrets) == 0 {
+		return nil, domain.ErrTOTPNotEnabled
+	}
+
+	return secrets, nil
+}
+
+// RecordTOTPStep stores the time-step of the last accepted code of an authenticator app. The
+// update only applies to a later step, so two requests racing with the same code cannot both
+// succeed.
+func (r *TOTPRepository) RecordTOTPStep(ctx context.Context, factorID string, step int64) error {
+	query := `
+		UPDATE totp_secrets
+		SET last_used_step = $2
+		WHERE factor_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)
+		RETURNING factor_id
+	`
+
+	var updated string
+	err := r.db.QueryRow(ctx, query, factorID, step).Scan(&updated)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrTOTPCodeReused
+		}
+		r.logger.Error("failed to record TOTP step", zap.String("factor_id", factorID), zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// scanTOTPSecret reads an authenticator app secret, whose period is stored in seconds
+func scanTOTPSecret(row pgx.Row) (*domain.TOTPSecret, error) {
+	var secret domain.TOTPSecret
+	var period int
+	if err := row.Scan(&secret.FactorID, &secret.Secret, &secret.Algorithm, &secret.Digits, &period); err != nil {
+		return nil, err
+	}
+	secret.Period = time.Duration(period) * time.Second
+	return &secret, nil
+}
+
+// SaveBackupCodes saves backup codes for a user, registering their recovery factor the first time
 func (r *TOTPRepository) SaveBackupCodes(ctx context.Context, userID string, codes []string) error {
 	if len(codes) == 0 {
 		r.logger.Error("invalid backup codes")
@@ -87,14 +170,25 @@ func (r *TOTPRepository) SaveBackupCodes(ctx context.Context, userID string, cod
 		return domain.ErrDatabaseQuery
 	}
 
+	// The new factor is not visible to the SELECT of the same statement, hence the UNION
 	query := `
-		INSERT INTO totp_backup_codes (user_id, codes)
-		VALUES ($1, $2)
+		WITH created AS (
+			INSERT INTO mfa_factors (id, user_id, type, label, created_at)
+			VALUES ($3, $1, $4, 'Backup codes', CURRENT_TIMESTAMP)
+			ON CONFLICT (user_id, type) WHERE type IN ('recovery', 'email_otp') DO NOTHING
+			RETURNING id
+		), factor AS (
+			SELECT id FROM created
+			UNION ALL
+			SELECT id FROM mfa_factors WHERE user_id = $1 AND type = $4
+		)
+		INSERT INTO totp_backup_codes (user_id, factor_id, codes)
+		SELECT $1, id, $2 FROM factor LIMIT 1
 		ON CONFLICT (user_id) DO UPDATE
 		SET codes = $2
 	`
 
-	err = r.db.Exec(ctx, query, userID, data)
+	err = r.db.Exec(ctx, query, userID, data, ulid.Make().String(), string(domain.MFAFactorRecovery))
 	if err != nil {
 		r.logger.Error("failed to save backup codes", zap.String("user_id", userID), zap.Error(err))
 		return domain.ErrDatabaseQuery
@@ -152,27 +246,126 @@ func (r *TOTPRepository) MarkBackupCodeAsUsed(ctx context.Context, userID string
 	return r.SaveBackupCodes(ctx, userID, codes)
 }
 
-// DeleteTOTPConfig deletes all TOTP configuration for a user
+// DeleteTOTPConfig deletes all TOTP configuration for a user: every authenticator app and the
+// backup codes, whose secrets are removed with their factors, and any pending enrolment
 func (r *TOTPRepository) DeleteTOTPConfig(ctx context.Context, userID string) error {
-	// Delete secret
 	query := `
-		DELETE FROM totp_secrets
-		WHERE user_id = $1
+		WITH pending AS (
+			DELETE FROM totp_enrollments WHERE user_id = $1
+		)
+		DELETE FROM mfa_factors
+		WHERE user_id = $1 AND type IN ($2, $3)
 	`
-	err := r.db.Exec(ctx, query, userID)
+	err := r.db.Exec(ctx, query, userID, string(domain.MFAFactorTOTP), string(domain.MFAFactorRecovery))
 	if err != nil {
-		r.logger.Error("failed to delete TOTP secret", zap.Error(err))
+		r.logger.Error("failed to delete TOTP configuration", zap.Error(err))
 		return domain.ErrDatabaseQuery
 	}
 
-	// Delete backup codes
-	query = `
-		DELETE FROM totp_backup_codes
+	return nil
+}
+
+// SavePendingTOTP stores an unconfirmed enrolment, replacing any earlier one of the user
+func (r *TOTPRepository) SavePendingTOTP(ctx context.Context, enrollment *domain.TOTPEnrollment) error {
+	query := `
+		INSERT INTO totp_enrollments (user_id, secret, label, algorithm, digits, period, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, label = EXCLUDED.label,
+			algorithm = EXCLUDED.algorithm, digits = EXCLUDED.digits, period = EXCLUDED.period,
+			created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+	`
+
+	err := r.db.Exec(ctx, query,
+		enrollment.UserID.String(),
+		enrollment.Secret,
+		enrollment.Label,
+		enrollment.Algorithm,
+		enrollment.Digits,
+		int(enrollment.Period/time.Second),
+		enrollment.CreatedAt,
+		enrollment.ExpiresAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to save pending TOTP enrolment",
+			zap.String("user_id", enrollment.UserID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// GetPendingTOTP retrieves the unconfirmed enrolment of a user
+func (r *TOTPRepository) GetPendingTOTP(ctx context.Context, userID string) (*domain.TOTPEnrollment, error) {
+	query := `
+		SELECT user_id, secret, label, algorithm, digits, period, created_at, expires_at
+		FROM totp_enrollments
 		WHERE user_id = $1
 	`
-	err = r.db.Exec(ctx, query, userID)
+
+	var enrollment domain.TOTPEnrollment
+	var period int
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&enrollment.UserID,
+		&enrollment.Secret,
+		&enrollment.Label,
+		&enrollment.Algorithm,
+		&enrollment.Digits,
+		&period,
+		&enrollment.CreatedAt,
+		&enrollment.ExpiresAt,
+	)
 	if err != nil {
-		r.logger.Error("failed to delete backup codes", zap.Error(err))
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrTOTPEnrollmentNotFound
+		}
+		r.logger.Error("failed to get pending TOTP enrolment", zap.String("user_id", userID), zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	enrollment.Period = time.Duration(period) * time.Second
+
+	return &enrollment, nil
+}
+
+// ActivatePendingTOTP turns the user's pending enrolment into an authenticator app factor, keeping
+// its parameters. The enrolment must still hold the given secret, so a confirmation racing a
+// re-enrolment cannot activate a secret its code was not checked against. The confirmation code's
+// step is recorded so it cannot be replayed to sign in.
+func (r *TOTPRepository) ActivatePendingTOTP(ctx context.Context, factor *domain.MFAFactor, secret string, step int64) error {
+	query := `
+		WITH pending AS (
+			DELETE FROM totp_enrollments
+			WHERE user_id = $2 AND secret = $6
+			RETURNING user_id, secret, algorithm, digits, period
+		), factor AS (
+			INSERT INTO mfa_factors (id, user_id, type, label, created_at)
+			SELECT $1, user_id, $3, $4, $5 FROM pending
+			RETURNING id, user_id
+		)
+		INSERT INTO totp_secrets (factor_id, user_id, secret, algorithm, digits, period, last_used_step)
+		SELECT factor.id, factor.user_id, pending.secret, pending.algorithm, pending.digits, pending.period, $7
+		FROM factor JOIN pending ON pending.user_id = factor.user_id
+		RETURNING factor_id
+	`
+
+	var factorID string
+	err := r.db.QueryRow(ctx, query,
+		factor.ID.String(),
+		factor.UserID.String(),
+		string(domain.MFAFactorTOTP),
+		factor.Label,
+		factor.CreatedAt,
+		secret,
+		step,
+	).Scan(&factorID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrTOTPEnrollmentNotFound
+		}
+		r.logger.Error("failed to activate TOTP enrolment",
+			zap.String("user_id", factor.UserID.String()),
+			zap.Error(err))
 		return domain.ErrDatabaseQuery
 	}
 
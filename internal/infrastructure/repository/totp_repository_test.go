@@ -5,8 +5,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/manorfm/authM/internal/domain"
 	"github.com/manorfm/authM/internal/infrastructure/config"
 	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
@@ -69,15 +71,44 @@ func setupTestDB(t *testing.T) (*database.Postgres, func()) {
 
 	// Create tables
 	err = db.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS mfa_factors (
+			id VARCHAR(26) PRIMARY KEY,
+			user_id VARCHAR(26) NOT NULL,
+			type VARCHAR(16) NOT NULL,
+			label VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP WITH TIME ZONE
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_mfa_factors_single_type ON mfa_factors(user_id, type)
+			WHERE type IN ('recovery', 'email_otp');
+
 		CREATE TABLE IF NOT EXISTS totp_secrets (
-			user_id VARCHAR(26) PRIMARY KEY,
-			secret VARCHAR(255) NOT NULL,
+			factor_id VARCHAR(26) PRIMARY KEY REFERENCES mfa_factors(id) ON DELETE CASCADE,
+			user_id VARCHAR(26) NOT NULL,
+			secret TEXT NOT NULL,
+			algorithm VARCHAR(8) NOT NULL DEFAULT 'SHA1',
+			digits SMALLINT NOT NULL DEFAULT 6,
+			period INTEGER NOT NULL DEFAULT 30,
+			last_used_step BIGINT,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		);
 
+		CREATE TABLE IF NOT EXISTS totp_enrollments (
+			user_id VARCHAR(26) PRIMARY KEY,
+			secret TEXT NOT NULL,
+			label VARCHAR(64) NOT NULL,
+			algorithm VARCHAR(8) NOT NULL DEFAULT 'SHA1',
+			digits SMALLINT NOT NULL DEFAULT 6,
+			period INTEGER NOT NULL DEFAULT 30,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+
 		CREATE TABLE IF NOT EXISTS totp_backup_codes (
 			user_id VARCHAR(26) PRIMARY KEY,
+			factor_id VARCHAR(26) NOT NULL REFERENCES mfa_factors(id) ON DELETE CASCADE,
 			codes JSONB NOT NULL,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
@@ -87,7 +118,7 @@ func setupTestDB(t *testing.T) (*database.Postgres, func()) {
 
 	// Clean up tables before each test
 	err = db.Exec(ctx, `
-		TRUNCATE TABLE totp_secrets, totp_backup_codes CASCADE;
+		TRUNCATE TABLE totp_secrets, totp_backup_codes, totp_enrollments, mfa_factors CASCADE;
 	`)
 	require.NoError(t, err)
 
@@ -109,30 +140,33 @@ func TestTOTPRepository_SaveTOTPSecret(t *testing.T) {
 	defer cleanup()
 
 	repo := NewTOTPRepository(db, zap.NewNop())
+	factorRepo := NewMFAFactorRepository(db, zap.NewNop())
 	ctx := context.Background()
 
+	userID := ulid.Make()
+
 	// Test cases
 	tests := []struct {
 		name      string
-		userID    string
+		factor    *domain.MFAFactor
 		secret    string
 		wantError bool
 	}{
 		{
 			name:      "Success",
-			userID:    "user1",
+			factor:    domain.NewMFAFactor(userID, domain.MFAFactorTOTP, "Phone"),
 			secret:    "JBSWY3DPEHPK3PXP",
 			wantError: false,
 		},
 		{
 			name:      "Empty Secret",
-			userID:    "user2",
+			factor:    domain.NewMFAFactor(ulid.Make(), domain.MFAFactorTOTP, "Phone"),
 			secret:    "",
 			wantError: true,
 		},
 		{
-			name:      "Update Existing",
-			userID:    "user1",
+			name:      "Second Authenticator App",
+			factor:    domain.NewMFAFactor(userID, domain.MFAFactorTOTP, "Tablet"),
 			secret:    "NEWSECRET123",
 			wantError: false,
 		},
@@ -141,7 +175,7 @@ func TestTOTPRepository_SaveTOTPSecret(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Execute
-			err := repo.SaveTOTPSecret(ctx, tt.userID, tt.secret)
+			err := repo.SaveTOTPSecret(ctx, tt.factor, tt.secret)
 
 			// Assert
 			if tt.wantError {
@@ -150,12 +184,22 @@ func TestTOTPRepository_SaveTOTPSecret(t *testing.T) {
 				assert.NoError(t, err)
 
 				// Verify the secret was saved
-				savedSecret, err := repo.GetTOTPSecret(ctx, tt.userID)
+				savedSecret, err := repo.GetTOTPSecret(ctx, tt.factor.UserID.String(), tt.factor.ID.String())
 				assert.NoError(t, err)
-				assert.Equal(t, tt.secret, savedSecret)
+				assert.Equal(t, tt.secret, savedSecret.Secret)
 			}
 		})
 	}
+
+	// Both authenticator apps are registered as factors
+	secrets, err := repo.ListTOTPSecrets(ctx, userID.String())
+	require.NoError(t, err)
+	assert.Len(t, secrets, 2)
+
+	factors, err := factorRepo.ListByUser(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, factors, 2)
+	assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorTOTP}, domain.MFAFactorTypes(factors))
 }
 
 func TestTOTPRepository_GetTOTPSecret(t *testing.T) {
@@ -167,27 +211,37 @@ func TestTOTPRepository_GetTOTPSecret(t *testing.T) {
 	ctx := context.Background()
 
 	// Insert test data
-	userID := "user1"
+	factor := domain.NewMFAFactor(ulid.Make(), domain.MFAFactorTOTP, "Phone")
 	secret := "JBSWY3DPEHPK3PXP"
-	err := repo.SaveTOTPSecret(ctx, userID, secret)
+	err := repo.SaveTOTPSecret(ctx, factor, secret)
 	require.NoError(t, err)
 
 	// Test cases
 	tests := []struct {
 		name      string
 		userID    string
+		factorID  string
 		want      string
 		wantError bool
 	}{
 		{
 			name:      "Success",
-			userID:    userID,
+			userID:    factor.UserID.String(),
+			factorID:  factor.ID.String(),
 			want:      secret,
 			wantError: false,
 		},
 		{
-			name:      "User Not Found",
-			userID:    "nonexistent",
+			name:      "Factor Not Found",
+			userID:    factor.UserID.String(),
+			factorID:  ulid.Make().String(),
+			want:      "",
+			wantError: true,
+		},
+		{
+			name:      "Factor Of Another User",
+			userID:    ulid.Make().String(),
+			factorID:  factor.ID.String(),
 			want:      "",
 			wantError: true,
 		},
@@ -196,15 +250,21 @@ func TestTOTPRepository_GetTOTPSecret(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Execute
-			got, err := repo.GetTOTPSecret(ctx, tt.userID)
+			got, err := repo.GetTOTPSecret(ctx, tt.userID, tt.factorID)
 
 			// Assert
 			if tt.wantError {
 				assert.Error(t, err)
-				assert.Empty(t, got)
+				assert.Nil(t, got)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.want, got)
+				assert.Equal(t, &domain.TOTPSecret{
+					FactorID:  tt.factorID,
+					Secret:    tt.want,
+					Algorithm: "SHA1",
+					Digits:    6,
+					Period:    30 * time.Second,
+				}, got)
 			}
 		})
 	}
@@ -216,8 +276,11 @@ func TestTOTPRepository_SaveBackupCodes(t *testing.T) {
 	defer cleanup()
 
 	repo := NewTOTPRepository(db, zap.NewNop())
+	factorRepo := NewMFAFactorRepository(db, zap.NewNop())
 	ctx := context.Background()
 
+	userID := ulid.Make()
+
 	// Test cases
 	tests := []struct {
 		name      string
@@ -227,19 +290,19 @@ func TestTOTPRepository_SaveBackupCodes(t *testing.T) {
 	}{
 		{
 			name:      "Success",
-			userID:    "user1",
+			userID:    userID.String(),
 			codes:     []string{"ABCDEF1234", "GHIJKL5678"},
 			wantError: false,
 		},
 		{
 			name:      "Empty Codes",
-			userID:    "user2",
+			userID:    ulid.Make().String(),
 			codes:     []string{},
 			wantError: true,
 		},
 		{
 			name:      "Update Existing",
-			userID:    "user1",
+			userID:    userID.String(),
 			codes:     []string{"NEWCODE1234", "NEWCODE5678"},
 			wantError: false,
 		},
@@ -263,6 +326,12 @@ func TestTOTPRepository_SaveBackupCodes(t *testing.T) {
 			}
 		})
 	}
+
+	// Saving the codes again keeps the single recovery factor
+	factors, err := factorRepo.ListByUser(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, factors, 1)
+	assert.Equal(t, domain.MFAFactorRecovery, factors[0].Type)
 }
 
 func TestTOTPRepository_GetBackupCodes(t *testing.T) {
@@ -274,7 +343,7 @@ func TestTOTPRepository_GetBackupCodes(t *testing.T) {
 	ctx := context.Background()
 
 	// Insert test data
-	userID := "user1"
+	userID := ulid.Make().String()
 	codes := []string{"ABCDEF1234", "GHIJKL5678"}
 	err := repo.SaveBackupCodes(ctx, userID, codes)
 	require.NoError(t, err)
@@ -294,7 +363,7 @@ func TestTOTPRepository_GetBackupCodes(t *testing.T) {
 		},
 		{
 			name:      "User Not Found",
-			userID:    "nonexistent",
+			userID:    ulid.Make().String(),
 			want:      nil,
 			wantError: true,
 		},
@@ -326,7 +395,7 @@ func TestTOTPRepository_MarkBackupCodeAsUsed(t *testing.T) {
 	ctx := context.Background()
 
 	// Insert test data
-	userID := "user1"
+	userID := ulid.Make().String()
 	codes := []string{"ABCDEF1234", "GHIJKL5678"}
 	err := repo.SaveBackupCodes(ctx, userID, codes)
 	require.NoError(t, err)
@@ -346,7 +415,7 @@ func TestTOTPRepository_MarkBackupCodeAsUsed(t *testing.T) {
 		},
 		{
 			name:      "User Not Found",
-			userID:    "nonexistent",
+			userID:    ulid.Make().String(),
 			codeIndex: 0,
 			wantError: true,
 		},
@@ -384,18 +453,33 @@ func TestTOTPRepository_DeleteTOTPConfig(t *testing.T) {
 	defer cleanup()
 
 	repo := NewTOTPRepository(db, zap.NewNop())
+	factorRepo := NewMFAFactorRepository(db, zap.NewNop())
 	ctx := context.Background()
 
 	// Insert test data
-	userID := "user1"
-	secret := "JBSWY3DPEHPK3PXP"
-	err := repo.SaveTOTPSecret(ctx, userID, secret)
+	factor := domain.NewMFAFactor(ulid.Make(), domain.MFAFactorTOTP, "Phone")
+	userID := factor.UserID.String()
+	err := repo.SaveTOTPSecret(ctx, factor, "JBSWY3DPEHPK3PXP")
 	require.NoError(t, err)
 
 	codes := []string{"ABCDEF1234", "GHIJKL5678"}
 	err = repo.SaveBackupCodes(ctx, userID, codes)
 	require.NoError(t, err)
 
+	err = repo.SavePendingTOTP(ctx, &domain.TOTPEnrollment{
+		UserID:    factor.UserID,
+		Secret:    "NEWSECRET123",
+		Label:     "Tablet",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(10 * time.Minute),
+	})
+	require.NoError(t, err)
+
+	// Other factors are left alone
+	email := domain.NewMFAFactor(factor.UserID, domain.MFAFactorEmailOTP, "test@example.com")
+	err = factorRepo.Create(ctx, email)
+	require.NoError(t, err)
+
 	// Test cases
 	tests := []struct {
 		name      string
@@ -409,7 +493,7 @@ func TestTOTPRepository_DeleteTOTPConfig(t *testing.T) {
 		},
 		{
 			name:      "User Not Found",
-			userID:    "nonexistent",
+			userID:    ulid.Make().String(),
 			wantError: false, // No error when deleting non-existent config
 		},
 	}
@@ -423,13 +507,118 @@ func TestTOTPRepository_DeleteTOTPConfig(t *testing.T) {
 			assert.NoError(t, err)
 
 			// Verify the config was deleted
-			secret, err := repo.GetTOTPSecret(ctx, tt.userID)
+			secrets, err := repo.ListTOTPSecrets(ctx, tt.userID)
 			assert.Error(t, err)
-			assert.Empty(t, secret)
+			assert.Nil(t, secrets)
 
 			codes, err := repo.GetBackupCodes(ctx, tt.userID)
 			assert.Error(t, err)
 			assert.Nil(t, codes)
+
+			_, err = repo.GetPendingTOTP(ctx, tt.userID)
+			assert.ErrorIs(t, err, domain.ErrTOTPEnrollmentNotFound)
 		})
 	}
+
+	factors, err := factorRepo.ListByUser(ctx, factor.UserID)
+	require.NoError(t, err)
+	require.Len(t, factors, 1)
+	assert.Equal(t, email.ID, factors[0].ID)
+}
+
+func TestTOTPRepository_PendingTOTP(t *testing.T) {
+	// Setup
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewTOTPRepository(db, zap.NewNop())
+	factorRepo := NewMFAFactorRepository(db, zap.NewNop())
+	ctx := context.Background()
+
+	userID := ulid.Make()
+	pending := func(secret string) *domain.TOTPEnrollment {
+		now := time.Now().Truncate(time.Microsecond)
+		return &domain.TOTPEnrollment{
+			UserID:    userID,
+			Secret:    secret,
+			Label:     "Phone",
+			Algorithm: "SHA256",
+			Digits:    8,
+			Period:    60 * time.Second,
+			CreatedAt: now,
+			ExpiresAt: now.Add(10 * time.Minute),
+		}
+	}
+
+	// Nothing pending yet
+	_, err := repo.GetPendingTOTP(ctx, userID.String())
+	assert.ErrorIs(t, err, domain.ErrTOTPEnrollmentNotFound)
+
+	// Enrolling again replaces the pending secret
+	require.NoError(t, repo.SavePendingTOTP(ctx, pending("FIRSTSECRET")))
+	require.NoError(t, repo.SavePendingTOTP(ctx, pending("SECONDSECRET")))
+
+	got, err := repo.GetPendingTOTP(ctx, userID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "SECONDSECRET", got.Secret)
+	assert.Equal(t, "Phone", got.Label)
+	assert.Equal(t, "SHA256", got.Algorithm)
+	assert.Equal(t, 8, got.Digits)
+	assert.Equal(t, 60*time.Second, got.Period)
+
+	// The replaced secret cannot be activated
+	factor := domain.NewMFAFactor(userID, domain.MFAFactorTOTP, got.Label)
+	err = repo.ActivatePendingTOTP(ctx, factor, "FIRSTSECRET", 100)
+	assert.ErrorIs(t, err, domain.ErrTOTPEnrollmentNotFound)
+
+	factors, err := factorRepo.ListByUser(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, factors)
+
+	// Activation creates the factor with the enrolment's parameters and consumes the enrolment
+	require.NoError(t, repo.ActivatePendingTOTP(ctx, factor, "SECONDSECRET", 100))
+
+	secret, err := repo.GetTOTPSecret(ctx, userID.String(), factor.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "SECONDSECRET", secret.Secret)
+	assert.Equal(t, "SHA256", secret.Algorithm)
+	assert.Equal(t, 8, secret.Digits)
+	assert.Equal(t, 60*time.Second, secret.Period)
+
+	// The confirmation code cannot be replayed
+	assert.ErrorIs(t, repo.RecordTOTPStep(ctx, factor.ID.String(), 100), domain.ErrTOTPCodeReused)
+
+	_, err = repo.GetPendingTOTP(ctx, userID.String())
+	assert.ErrorIs(t, err, domain.ErrTOTPEnrollmentNotFound)
+
+	err = repo.ActivatePendingTOTP(ctx, domain.NewMFAFactor(userID, domain.MFAFactorTOTP, "Phone"), "SECONDSECRET", 100)
+	assert.ErrorIs(t, err, domain.ErrTOTPEnrollmentNotFound)
+}
+
+func TestTOTPRepository_RecordTOTPStep(t *testing.T) {
+	// Setup
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewTOTPRepository(db, zap.NewNop())
+	ctx := context.Background()
+
+	factor := domain.NewMFAFactor(ulid.Make(), domain.MFAFactorTOTP, "Phone")
+	require.NoError(t, repo.SaveTOTPSecret(ctx, factor, "JBSWY3DPEHPK3PXP"))
+	factorID := factor.ID.String()
+
+	// The first code of an app is accepted
+	require.NoError(t, repo.RecordTOTPStep(ctx, factorID, 100))
+
+	// The same step, or an earlier one within the skew window, is refused
+	assert.ErrorIs(t, repo.RecordTOTPStep(ctx, factorID, 100), domain.ErrTOTPCodeReused)
+	assert.ErrorIs(t, repo.RecordTOTPStep(ctx, factorID, 99), domain.ErrTOTPCodeReused)
+
+	// A later code is accepted
+	assert.NoError(t, repo.RecordTOTPStep(ctx, factorID, 101))
+
+	// Each app keeps its own step
+	other := domain.NewMFAFactor(factor.UserID, domain.MFAFactorTOTP, "Tablet")
+	require.NoError(t, repo.SaveTOTPSecret(ctx, other, "KRSXG5CTMVRXEZLU"))
+	assert.NoError(t, repo.RecordTOTPStep(ctx, other.ID.String(), 101))
 }
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// TrustedDeviceRepository implements the trusted device repository interface
+type TrustedDeviceRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewTrustedDeviceRepository creates a new trusted device repository
+func NewTrustedDeviceRepository(db *database.Postgres, logger *zap.Logger) *TrustedDeviceRepository {
+	return &TrustedDeviceRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const trustedDeviceColumns = `id, user_id, name, token_hash, COALESCE(client_ip, ''), created_at, expires_at, last_used_at`
+
+// Create stores a newly trusted device
+func (r *TrustedDeviceRepository) Create(ctx context.Context, device *domain.TrustedDevice) error {
+	query := `
+		INSERT INTO trusted_devices (id, user_id, name, token_hash, client_ip, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+	`
+
+	err := r.db.Exec(ctx, query,
+		device.ID.String(),
+		device.UserID.String(),
+		device.Name,
+		device.TokenHash,
+		device.ClientIP,
+		device.CreatedAt,
+		device.ExpiresAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to create trusted device",
+			zap.String("user_id", device.UserID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// FindByID retrieves a trusted device
+func (r *TrustedDeviceRepository) FindByID(ctx context.Context, id ulid.ULID) (*domain.TrustedDevice, error) {
+	query := `
+		SELECT ` + trustedDeviceColumns + `
+		FROM trusted_devices
+		WHERE id = $1
+	`
+
+	device, err := scanTrustedDevice(r.db.QueryRow(ctx, query, id.String()))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrTrustedDeviceNotFound
+		}
+		r.logger.Error("failed to find trusted device",
+			zap.String("device_id", id.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return device, nil
+}
+
+// ListByUser lists the trusted devices of a user, newest first
+func (r *TrustedDeviceRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.TrustedDevice, error) {
+	query := `
+		SELECT ` + trustedDeviceColumns + `
+		FROM trusted_devices
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String())
+	if err != nil {
+		r.logger.Error("failed to list trusted devices",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	devices := []*domain.TrustedDevice{}
+	for rows.Next() {
+		device, err := scanTrustedDevice(rows)
+		if err != nil {
+			r.logger.Error("failed to scan trusted device",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list trusted devices",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return devices, nil
+}
+
+// Touch records a login from a trusted device
+func (r *TrustedDeviceRepository) Touch(ctx context.Context, id ulid.ULID, usedAt time.Time) error {
+	query := `
+		UPDATE trusted_devices
+		SET last_used_at = $2
+		WHERE id = $1
+	`
+
+	if err := r.db.Exec(ctx, query, id.String(), usedAt); err != nil {
+		r.logger.Error("failed to record trusted device use",
+			zap.String("device_id", id.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// Delete revokes one of the user's trusted devices, reporting a device of someone else as not found
+func (r *TrustedDeviceRepository) Delete(ctx context.Context, userID, id ulid.ULID) error {
+	query := `
+		DELETE FROM trusted_devices
+		WHERE id = $1 AND user_id = $2
+		RETURNING id
+	`
+
+	var deleted string
+	if err := r.db.QueryRow(ctx, query, id.String(), userID.String()).Scan(&deleted); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrTrustedDeviceNotFound
+		}
+		r.logger.Error("failed to delete trusted device",
+			zap.String("device_id", id.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// DeleteByUser revokes every trusted device of a user
+func (r *TrustedDeviceRepository) DeleteByUser(ctx context.Context, userID ulid.ULID) error {
+	query := `
+		DELETE FROM trusted_devices
+		WHERE user_id = $1
+	`
+
+	if err := r.db.Exec(ctx, query, userID.String()); err != nil {
+		r.logger.Error("failed to delete trusted devices",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+func scanTrustedDevice(row pgx.Row) (*domain.TrustedDevice, error) {
+	var device domain.TrustedDevice
+	err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.Name,
+		&device.TokenHash,
+		&device.ClientIP,
+		&device.CreatedAt,
+		&device.ExpiresAt,
+		&device.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
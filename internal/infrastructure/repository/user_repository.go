@@ -2,15 +2,20 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/manorfm/authM/internal/domain"
 	"github.com/manorfm/authM/internal/infrastructure/database"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 )
 
+// uniqueViolation is the PostgreSQL error code of a unique constraint violation
+const uniqueViolation = "23505"
+
 type UserRepository struct {
 	logger *zap.Logger
 	db     *database.Postgres
@@ -38,9 +43,9 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 func (r *UserRepository) FindByID(ctx context.Context, id ulid.ULID) (*domain.User, error) {
 	user := &domain.User{}
 	err := r.db.QueryRow(ctx, `
-		SELECT id, name, email, password, phone, created_at, updated_at, roles, email_verified
+		SELECT id, name, email, password, phone, phone_verified, created_at, updated_at, roles, email_verified, sessions_revoked_at
 		FROM users WHERE id = $1
-	`, id.String()).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Phone, &user.CreatedAt, &user.UpdatedAt, &user.Roles, &user.EmailVerified)
+	`, id.String()).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Phone, &user.PhoneVerified, &user.CreatedAt, &user.UpdatedAt, &user.Roles, &user.EmailVerified, &user.SessionsRevokedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, domain.ErrUserNotFound
@@ -54,9 +59,9 @@ func (r *UserRepository) FindByID(ctx context.Context, id ulid.ULID) (*domain.Us
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
 	user := &domain.User{}
 	err := r.db.QueryRow(ctx, `
-		SELECT id, name, email, password, phone, created_at, updated_at, roles, email_verified
+		SELECT id, name, email, password, phone, phone_verified, created_at, updated_at, roles, email_verified, sessions_revoked_at
 		FROM users WHERE email = $1
-	`, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Phone, &user.CreatedAt, &user.UpdatedAt, &user.Roles, &user.EmailVerified)
+	`, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Phone, &user.PhoneVerified, &user.CreatedAt, &user.UpdatedAt, &user.Roles, &user.EmailVerified, &user.SessionsRevokedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, domain.ErrUserNotFound
@@ -121,9 +126,9 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]*domain
 func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	return r.db.Exec(ctx, `
 		UPDATE users
-		SET name = $1, phone = $2, updated_at = $3, email_verified = $4, roles = $5
-		WHERE id = $6
-	`, user.Name, user.Phone, user.UpdatedAt, user.EmailVerified, user.Roles, user.ID.String())
+		SET name = $1, phone = $2, phone_verified = $3, updated_at = $4, email_verified = $5, roles = $6
+		WHERE id = $7
+	`, user.Name, user.Phone, user.PhoneVerified, user.UpdatedAt, user.EmailVerified, user.Roles, user.ID.String())
 }
 
 func (r *UserRepository) RemoveRole(ctx context.Context, userID ulid.ULID, role string) error {
@@ -135,9 +140,48 @@ func (r *UserRepository) RemoveRole(ctx context.Context, userID ulid.ULID, role
 }
 
 func (r *UserRepository) UpdatePassword(ctx context.Context, userID ulid.ULID, hashedPassword string) error {
-	return r.db.Exec(ctx, `
+	err := r.db.Exec(ctx, `
 		UPDATE users
 		SET password = $1, updated_at = $2
 		WHERE id = $3
 	`, hashedPassword, time.Now(), userID.String())
+	if err != nil {
+		r.logger.Error("failed to update password", zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdateEmail(ctx context.Context, userID ulid.ULID, email string) error {
+	err := r.db.Exec(ctx, `
+		UPDATE users
+		SET email = $1, email_verified = TRUE, updated_at = $2
+		WHERE id = $3
+	`, email, time.Now(), userID.String())
+	if err != nil {
+		// The address may be taken between the existence check and the update
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return domain.ErrUserAlreadyExists
+		}
+		r.logger.Error("failed to update email", zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdatePhone(ctx context.Context, userID ulid.ULID, phone string) error {
+	return r.db.Exec(ctx, `
+		UPDATE users
+		SET phone = $1, phone_verified = TRUE, updated_at = $2
+		WHERE id = $3
+	`, phone, time.Now(), userID.String())
+}
+
+func (r *UserRepository) RevokeSessions(ctx context.Context, userID ulid.ULID, before time.Time) error {
+	return r.db.Exec(ctx, `
+		UPDATE users
+		SET sessions_revoked_at = $1
+		WHERE id = $2
+	`, before, userID.String())
 }
@@ -24,15 +24,15 @@ func NewVerificationCodeRepository(db *database.Postgres, logger *zap.Logger) *V
 
 func (r *VerificationCodeRepository) Create(ctx context.Context, code *domain.VerificationCode) error {
 	return r.db.Exec(ctx, `
-		INSERT INTO verification_codes (id, user_id, code, type, expires_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, code.ID.String(), code.UserID.String(), code.Code, code.Type, code.ExpiresAt, code.CreatedAt)
+		INSERT INTO verification_codes (id, user_id, code, type, target, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+	`, code.ID.String(), code.UserID.String(), code.Code, code.Type, code.Target, code.ExpiresAt, code.CreatedAt)
 }
 
 func (r *VerificationCodeRepository) FindByCode(ctx context.Context, code string) (*domain.VerificationCode, error) {
 	verificationCode := &domain.VerificationCode{}
 	err := r.db.QueryRow(ctx, `
-		SELECT id, user_id, code, type, expires_at, created_at
+		SELECT id, user_id, code, type, COALESCE(target, ''), expires_at, created_at
 		FROM verification_codes
 		WHERE code = $1
 	`, code).Scan(
@@ -40,6 +40,7 @@ func (r *VerificationCodeRepository) FindByCode(ctx context.Context, code string
 		&verificationCode.UserID,
 		&verificationCode.Code,
 		&verificationCode.Type,
+		&verificationCode.Target,
 		&verificationCode.ExpiresAt,
 		&verificationCode.CreatedAt,
 	)
@@ -53,7 +54,7 @@ func (r *VerificationCodeRepository) FindByCode(ctx context.Context, code string
 func (r *VerificationCodeRepository) FindByUserIDAndType(ctx context.Context, userID ulid.ULID, codeType domain.VerificationCodeType) (*domain.VerificationCode, error) {
 	verificationCode := &domain.VerificationCode{}
 	err := r.db.QueryRow(ctx, `
-		SELECT id, user_id, code, type, expires_at, created_at
+		SELECT id, user_id, code, type, COALESCE(target, ''), expires_at, created_at
 		FROM verification_codes
 		WHERE user_id = $1 AND type = $2
 		ORDER BY created_at DESC
@@ -63,6 +64,7 @@ func (r *VerificationCodeRepository) FindByUserIDAndType(ctx context.Context, us
 		&verificationCode.UserID,
 		&verificationCode.Code,
 		&verificationCode.Type,
+		&verificationCode.Target,
 		&verificationCode.ExpiresAt,
 		&verificationCode.CreatedAt,
 	)
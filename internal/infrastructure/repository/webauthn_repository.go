@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/database"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// WebAuthnCredentialRepository implements the passkey credential repository interface
+type WebAuthnCredentialRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewWebAuthnCredentialRepository creates a new passkey credential repository
+func NewWebAuthnCredentialRepository(db *database.Postgres, logger *zap.Logger) *WebAuthnCredentialRepository {
+	return &WebAuthnCredentialRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const webAuthnCredentialColumns = `id, user_id, credential_id, public_key, sign_count, transports, aaguid, name, created_at, last_used_at`
+
+// Create stores a new credential
+func (r *WebAuthnCredentialRepository) Create(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	query := `
+		INSERT INTO webauthn_credentials (` + webAuthnCredentialColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	transports := credential.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+
+	err := r.db.Exec(ctx, query,
+		credential.ID.String(),
+		credential.UserID.String(),
+		[]byte(credential.CredentialID),
+		credential.PublicKey,
+		int64(credential.SignCount),
+		transports,
+		credential.AAGUID,
+		credential.Name,
+		credential.CreatedAt,
+		credential.LastUsedAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to create WebAuthn credential",
+			zap.String("user_id", credential.UserID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// FindByCredentialID retrieves a credential by the ID the authenticator assigned it
+func (r *WebAuthnCredentialRepository) FindByCredentialID(ctx context.Context, credentialID []byte) (*domain.WebAuthnCredential, error) {
+	query := `
+		SELECT ` + webAuthnCredentialColumns + `
+		FROM webauthn_credentials
+		WHERE credential_id = $1
+	`
+
+	credential, err := scanWebAuthnCredential(r.db.QueryRow(ctx, query, credentialID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrWebAuthnCredentialNotFound
+		}
+		r.logger.Error("failed to find WebAuthn credential", zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return credential, nil
+}
+
+// ListByUser lists the credentials of a user, oldest first
+func (r *WebAuthnCredentialRepository) ListByUser(ctx context.Context, userID ulid.ULID) ([]*domain.WebAuthnCredential, error) {
+	query := `
+		SELECT ` + webAuthnCredentialColumns + `
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query, userID.String())
+	if err != nil {
+		r.logger.Error("failed to list WebAuthn credentials",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+	defer rows.Close()
+
+	credentials := []*domain.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			r.logger.Error("failed to scan WebAuthn credential",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, domain.ErrDatabaseQuery
+		}
+		credentials = append(credentials, credential)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("failed to list WebAuthn credentials",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	return credentials, nil
+}
+
+// UpdateSignCount records a successful use of a credential
+func (r *WebAuthnCredentialRepository) UpdateSignCount(ctx context.Context, id ulid.ULID, signCount uint32, usedAt time.Time) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, last_used_at = $3
+		WHERE id = $1
+	`
+
+	if err := r.db.Exec(ctx, query, id.String(), int64(signCount), usedAt); err != nil {
+		r.logger.Error("failed to update WebAuthn credential sign count",
+			zap.String("credential_id", id.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// Rename changes the friendly name of one of the user's credentials
+func (r *WebAuthnCredentialRepository) Rename(ctx context.Context, userID, id ulid.ULID, name string) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET name = $3
+		WHERE id = $1 AND user_id = $2
+		RETURNING id
+	`
+
+	return r.execOwned(ctx, "rename", query, id.String(), userID.String(), name)
+}
+
+// Delete removes one of the user's credentials
+func (r *WebAuthnCredentialRepository) Delete(ctx context.Context, userID, id ulid.ULID) error {
+	query := `
+		DELETE FROM webauthn_credentials
+		WHERE id = $1 AND user_id = $2
+		RETURNING id
+	`
+
+	return r.execOwned(ctx, "delete", query, id.String(), userID.String())
+}
+
+// execOwned runs a statement on a credential of the user, reporting a credential of someone else as not found
+func (r *WebAuthnCredentialRepository) execOwned(ctx context.Context, action, query string, args ...interface{}) error {
+	var id string
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&id); err != nil {
+		if err == pgx.ErrNoRows {
+			return domain.ErrWebAuthnCredentialNotFound
+		}
+		r.logger.Error("failed to "+action+" WebAuthn credential",
+			zap.Any("credential_id", args[0]),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+	return nil
+}
+
+func scanWebAuthnCredential(row pgx.Row) (*domain.WebAuthnCredential, error) {
+	var (
+		credential   domain.WebAuthnCredential
+		credentialID []byte
+		signCount    int64
+	)
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credentialID,
+		&credential.PublicKey,
+		&signCount,
+		&credential.Transports,
+		&credential.AAGUID,
+		&credential.Name,
+		&credential.CreatedAt,
+		&credential.LastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	credential.CredentialID = credentialID
+	credential.SignCount = uint32(signCount)
+	return &credential, nil
+}
+
+// WebAuthnChallengeRepository implements the WebAuthn challenge repository interface
+type WebAuthnChallengeRepository struct {
+	db     *database.Postgres
+	logger *zap.Logger
+}
+
+// NewWebAuthnChallengeRepository creates a new WebAuthn challenge repository
+func NewWebAuthnChallengeRepository(db *database.Postgres, logger *zap.Logger) *WebAuthnChallengeRepository {
+	return &WebAuthnChallengeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new challenge, clearing out challenges that were never answered
+func (r *WebAuthnChallengeRepository) Create(ctx context.Context, challenge *domain.WebAuthnChallenge) error {
+	if err := r.db.Exec(ctx, `DELETE FROM webauthn_challenges WHERE expires_at < $1`, time.Now()); err != nil {
+		r.logger.Error("failed to delete expired WebAuthn challenges", zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	query := `
+		INSERT INTO webauthn_challenges (id, ceremony, challenge, user_id, mfa_ticket, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+	`
+
+	var userID string
+	if challenge.UserID != (ulid.ULID{}) {
+		userID = challenge.UserID.String()
+	}
+
+	err := r.db.Exec(ctx, query,
+		challenge.ID.String(),
+		string(challenge.Ceremony),
+		challenge.Challenge,
+		userID,
+		challenge.MFATicket,
+		challenge.ExpiresAt,
+	)
+	if err != nil {
+		r.logger.Error("failed to create WebAuthn challenge",
+			zap.String("challenge_id", challenge.ID.String()),
+			zap.Error(err))
+		return domain.ErrDatabaseQuery
+	}
+
+	return nil
+}
+
+// Consume retrieves and deletes a challenge, so that it can be answered only once
+func (r *WebAuthnChallengeRepository) Consume(ctx context.Context, id ulid.ULID) (*domain.WebAuthnChallenge, error) {
+	query := `
+		DELETE FROM webauthn_challenges
+		WHERE id = $1
+		RETURNING id, ceremony, challenge, COALESCE(user_id, ''), COALESCE(mfa_ticket, ''), expires_at
+	`
+
+	var (
+		challenge domain.WebAuthnChallenge
+		ceremony  string
+		userID    string
+	)
+	err := r.db.QueryRow(ctx, query, id.String()).Scan(
+		&challenge.ID,
+		&ceremony,
+		&challenge.Challenge,
+		&userID,
+		&challenge.MFATicket,
+		&challenge.ExpiresAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrInvalidWebAuthnChallenge
+		}
+		r.logger.Error("failed to consume WebAuthn challenge",
+			zap.String("challenge_id", id.String()),
+			zap.Error(err))
+		return nil, domain.ErrDatabaseQuery
+	}
+
+	challenge.Ceremony = domain.WebAuthnCeremony(ceremony)
+	if userID != "" {
+		if challenge.UserID, err = ulid.Parse(userID); err != nil {
+			return nil, domain.ErrInvalidUserID
+		}
+	}
+
+	return &challenge, nil
+}
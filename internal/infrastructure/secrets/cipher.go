@@ -0,0 +1,191 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// envelopeVersion prefixes every sealed secret so the format can evolve
+const envelopeVersion = "v1"
+
+// dataKeyLength is the size of the AES-256 key generated for each secret
+const dataKeyLength = 32
+
+// errMalformedEnvelope is returned when a stored value is not a sealed secret
+var errMalformedEnvelope = errors.New("malformed secret envelope")
+
+// keyWrapper encrypts the data keys of sealed secrets with a key it never hands out
+type keyWrapper interface {
+	// name identifies the wrapper in sealed secrets
+	name() string
+	wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// envelopeCipher implements domain.SecretCipher with envelope encryption: each secret is sealed
+// with its own AES-256-GCM data key, stored next to it wrapped by the configured key provider,
+// as v1.<provider>.<wrapped data key>.<sealed secret>
+type envelopeCipher struct {
+	primary  keyWrapper
+	wrappers map[string]keyWrapper
+	logger   *zap.Logger
+}
+
+// NewCipher creates a secret cipher wrapping data keys with SECRET_KEY_PROVIDER. Secrets sealed
+// with the local key can still be opened after moving to Vault, as long as the key stays set.
+func NewCipher(cfg *config.Config, logger *zap.Logger) domain.SecretCipher {
+	c := &envelopeCipher{
+		wrappers: make(map[string]keyWrapper),
+		logger:   logger,
+	}
+
+	if cfg.SecretEncryptionKey != "" {
+		local, err := newLocalWrapper(cfg.SecretEncryptionKey)
+		if err != nil {
+			logger.Error("Invalid secret encryption key", zap.Error(err))
+		} else {
+			c.wrappers[local.name()] = local
+		}
+	}
+
+	if cfg.SecretKeyProvider == "vault" {
+		vault, err := newVaultWrapper(cfg, logger)
+		if err != nil {
+			logger.Error("Failed to create Vault client for secret encryption", zap.Error(err))
+		} else {
+			c.wrappers[vault.name()] = vault
+		}
+	}
+
+	c.primary = c.wrappers[cfg.SecretKeyProvider]
+	if c.primary == nil {
+		logger.Warn("No key to encrypt secrets at rest, authenticator apps cannot be enrolled",
+			zap.String("provider", cfg.SecretKeyProvider))
+	}
+
+	return c
+}
+
+// IsSealed reports whether a stored value was produced by the secret cipher
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, envelopeVersion+".")
+}
+
+// Encrypt seals a secret under a fresh data key
+func (c *envelopeCipher) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	if c.primary == nil {
+		return "", domain.ErrSecretEncryption
+	}
+
+	dataKey := make([]byte, dataKeyLength)
+	if _, err := rand.Read(dataKey); err != nil {
+		c.logger.Error("Failed to generate data key", zap.Error(err))
+		return "", domain.ErrSecretEncryption
+	}
+
+	header := envelopeVersion + "." + c.primary.name()
+	sealed, err := seal(dataKey, []byte(plaintext), []byte(header))
+	if err != nil {
+		c.logger.Error("Failed to seal secret", zap.Error(err))
+		return "", domain.ErrSecretEncryption
+	}
+
+	wrapped, err := c.primary.wrap(ctx, dataKey)
+	if err != nil {
+		c.logger.Error("Failed to wrap data key", zap.String("provider", c.primary.name()), zap.Error(err))
+		return "", domain.ErrSecretEncryption
+	}
+
+	return strings.Join([]string{
+		header,
+		base64.RawURLEncoding.EncodeToString(wrapped),
+		base64.RawURLEncoding.EncodeToString(sealed),
+	}, "."), nil
+}
+
+// Decrypt opens a secret sealed by Encrypt with whichever provider wrapped its data key
+func (c *envelopeCipher) Decrypt(ctx context.Context, ciphertext string) (string, error) {
+	parts := strings.Split(ciphertext, ".")
+	if len(parts) != 4 || parts[0] != envelopeVersion {
+		c.logger.Error("Failed to open secret", zap.Error(errMalformedEnvelope))
+		return "", domain.ErrSecretEncryption
+	}
+
+	wrapper, ok := c.wrappers[parts[1]]
+	if !ok {
+		c.logger.Error("No key to open secret", zap.String("provider", parts[1]))
+		return "", domain.ErrSecretEncryption
+	}
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		c.logger.Error("Failed to open secret", zap.Error(errMalformedEnvelope))
+		return "", domain.ErrSecretEncryption
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		c.logger.Error("Failed to open secret", zap.Error(errMalformedEnvelope))
+		return "", domain.ErrSecretEncryption
+	}
+
+	dataKey, err := wrapper.unwrap(ctx, wrapped)
+	if err != nil {
+		c.logger.Error("Failed to unwrap data key", zap.String("provider", parts[1]), zap.Error(err))
+		return "", domain.ErrSecretEncryption
+	}
+
+	plaintext, err := open(dataKey, sealed, []byte(parts[0]+"."+parts[1]))
+	if err != nil {
+		c.logger.Error("Failed to open secret", zap.Error(err))
+		return "", domain.ErrSecretEncryption
+	}
+
+	return string(plaintext), nil
+}
+
+// seal encrypts with AES-GCM, prefixing the random nonce to the ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a value produced by seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errMalformedEnvelope
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
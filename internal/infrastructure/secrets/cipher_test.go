@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testKey(b byte) string {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = b
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// newTransitServer fakes the Vault transit engine; its "ciphertext" only tags the plaintext
+func newTransitServer(t *testing.T, requests *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path)
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		data := map[string]string{}
+		switch r.URL.Path {
+		case "/v1/transit/authM/encrypt/totp-secrets":
+			data["ciphertext"] = "vault:v1:" + body["plaintext"]
+		case "/v1/transit/authM/decrypt/totp-secrets":
+			data["plaintext"] = strings.TrimPrefix(body["ciphertext"], "vault:v1:")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCipher_Local(t *testing.T) {
+	ctx := context.Background()
+	cipher := NewCipher(&config.Config{SecretKeyProvider: "local", SecretEncryptionKey: testKey(1)}, zap.NewNop())
+
+	sealed, err := cipher.Encrypt(ctx, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.True(t, strings.HasPrefix(sealed, "v1.local."))
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+
+	again, err := cipher.Encrypt(ctx, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "each secret should get its own data key and nonce")
+
+	plaintext, err := cipher.Decrypt(ctx, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plaintext)
+
+	t.Run("tampered secret", func(t *testing.T) {
+		parts := strings.Split(sealed, ".")
+		body, err := base64.RawURLEncoding.DecodeString(parts[3])
+		require.NoError(t, err)
+		body[len(body)-1] ^= 0xff
+		parts[3] = base64.RawURLEncoding.EncodeToString(body)
+
+		_, err = cipher.Decrypt(ctx, strings.Join(parts, "."))
+		assert.ErrorIs(t, err, domain.ErrSecretEncryption)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		other := NewCipher(&config.Config{SecretKeyProvider: "local", SecretEncryptionKey: testKey(2)}, zap.NewNop())
+		_, err := other.Decrypt(ctx, sealed)
+		assert.ErrorIs(t, err, domain.ErrSecretEncryption)
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		assert.False(t, IsSealed("JBSWY3DPEHPK3PXP"))
+		_, err := cipher.Decrypt(ctx, "JBSWY3DPEHPK3PXP")
+		assert.ErrorIs(t, err, domain.ErrSecretEncryption)
+	})
+}
+
+func TestCipher_MissingKey(t *testing.T) {
+	cipher := NewCipher(&config.Config{SecretKeyProvider: "local"}, zap.NewNop())
+
+	_, err := cipher.Encrypt(context.Background(), "JBSWY3DPEHPK3PXP")
+	assert.ErrorIs(t, err, domain.ErrSecretEncryption)
+}
+
+func TestCipher_Vault(t *testing.T) {
+	ctx := context.Background()
+	var requests []string
+	server := newTransitServer(t, &requests)
+
+	cfg := &config.Config{
+		SecretKeyProvider:   "vault",
+		SecretEncryptionKey: testKey(1),
+		VaultAddress:        server.URL,
+		VaultToken:          "test-token",
+		VaultMountPath:      "transit/authM",
+		VaultSecretKeyName:  "totp-secrets",
+	}
+	cipher := NewCipher(cfg, zap.NewNop())
+
+	sealed, err := cipher.Encrypt(ctx, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "v1.vault."))
+
+	plaintext, err := cipher.Decrypt(ctx, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plaintext)
+	assert.Equal(t, []string{
+		"/v1/transit/authM/encrypt/totp-secrets",
+		"/v1/transit/authM/decrypt/totp-secrets",
+	}, requests)
+
+	// Secrets sealed with the local key before moving to Vault can still be opened
+	local := NewCipher(&config.Config{SecretKeyProvider: "local", SecretEncryptionKey: testKey(1)}, zap.NewNop())
+	legacy, err := local.Encrypt(ctx, "JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	plaintext, err = cipher.Decrypt(ctx, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plaintext)
+}
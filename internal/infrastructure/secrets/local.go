@@ -0,0 +1,38 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+)
+
+// localWrapperName marks data keys wrapped with SECRET_ENCRYPTION_KEY
+const localWrapperName = "local"
+
+// localWrapper wraps data keys with a key-encryption key held in the configuration
+type localWrapper struct {
+	key []byte
+}
+
+func newLocalWrapper(encodedKey string) (*localWrapper, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != dataKeyLength {
+		return nil, errors.New("secret encryption key must be 32 bytes")
+	}
+	return &localWrapper{key: key}, nil
+}
+
+func (w *localWrapper) name() string {
+	return localWrapperName
+}
+
+func (w *localWrapper) wrap(_ context.Context, dataKey []byte) ([]byte, error) {
+	return seal(w.key, dataKey, []byte(localWrapperName))
+}
+
+func (w *localWrapper) unwrap(_ context.Context, wrapped []byte) ([]byte, error) {
+	return open(w.key, wrapped, []byte(localWrapperName))
+}
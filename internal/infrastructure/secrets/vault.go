@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// vaultWrapperName marks data keys wrapped by the Vault transit engine
+const vaultWrapperName = "vault"
+
+// vaultWrapper wraps data keys with a transit key that never leaves Vault
+type vaultWrapper struct {
+	client    *api.Client
+	mountPath string
+	keyName   string
+	logger    *zap.Logger
+}
+
+func newVaultWrapper(cfg *config.Config, logger *zap.Logger) (*vaultWrapper, error) {
+	vaultConfig := api.DefaultConfig()
+	vaultConfig.Address = cfg.VaultAddress
+
+	client, err := api.NewClient(vaultConfig)
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(cfg.VaultToken)
+
+	return &vaultWrapper{
+		client:    client,
+		mountPath: cfg.VaultMountPath,
+		keyName:   cfg.VaultSecretKeyName,
+		logger:    logger,
+	}, nil
+}
+
+func (w *vaultWrapper) name() string {
+	return vaultWrapperName
+}
+
+func (w *vaultWrapper) wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	secret, err := w.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/encrypt/%s", w.mountPath, w.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(dataKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, errors.New("empty response from Vault")
+	}
+
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok {
+		return nil, errors.New("missing ciphertext in Vault response")
+	}
+	return []byte(ciphertext), nil
+}
+
+func (w *vaultWrapper) unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	secret, err := w.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/decrypt/%s", w.mountPath, w.keyName), map[string]interface{}{
+		"ciphertext": string(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, errors.New("empty response from Vault")
+	}
+
+	plaintext, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("missing plaintext in Vault response")
+	}
+	return base64.StdEncoding.DecodeString(plaintext)
+}
@@ -0,0 +1,78 @@
+package sector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"go.uber.org/zap"
+)
+
+// maxDocumentSize bounds the sector identifier document read from a client's server
+const maxDocumentSize = 64 << 10
+
+// verifier implements domain.SectorIdentifierVerifier by fetching the document at the sector_identifier_uri
+type verifier struct {
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewVerifier creates a new sector identifier verifier
+func NewVerifier(logger *zap.Logger) domain.SectorIdentifierVerifier {
+	return &verifier{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+	}
+}
+
+// Verify checks that the JSON array published at sectorIdentifierURI lists every redirect URI, as
+// required by OpenID Connect Dynamic Client Registration section 5
+func (v *verifier) Verify(ctx context.Context, sectorIdentifierURI string, redirectURIs []string) error {
+	listed, err := v.fetch(ctx, sectorIdentifierURI)
+	if err != nil {
+		v.logger.Error("Failed to fetch sector identifier document",
+			zap.String("sector_identifier_uri", sectorIdentifierURI),
+			zap.Error(err))
+		return domain.ErrInvalidSectorIdentifierReason(fmt.Sprintf("%s could not be fetched", sectorIdentifierURI))
+	}
+
+	allowed := make(map[string]bool, len(listed))
+	for _, uri := range listed {
+		allowed[uri] = true
+	}
+	for _, uri := range redirectURIs {
+		if !allowed[uri] {
+			return domain.ErrInvalidSectorIdentifierReason(fmt.Sprintf("%s is not listed at %s", uri, sectorIdentifierURI))
+		}
+	}
+
+	return nil
+}
+
+func (v *verifier) fetch(ctx context.Context, sectorIdentifierURI string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sectorIdentifierURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d fetching %s", resp.StatusCode, sectorIdentifierURI)
+	}
+
+	var uris []string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&uris); err != nil {
+		return nil, err
+	}
+	return uris, nil
+}
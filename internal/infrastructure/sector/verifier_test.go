@@ -0,0 +1,58 @@
+package sector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestVerifier(t *testing.T, status int, body string) (*verifier, string) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return &verifier{httpClient: server.Client(), logger: zap.NewNop()}, server.URL + "/redirect_uris.json"
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	document := `["https://a.example.com/callback", "https://b.example.com/callback"]`
+
+	t.Run("every redirect URI listed", func(t *testing.T) {
+		v, uri := newTestVerifier(t, http.StatusOK, document)
+		err := v.Verify(context.Background(), uri, []string{"https://a.example.com/callback", "https://b.example.com/callback"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("redirect URI missing from the document", func(t *testing.T) {
+		v, uri := newTestVerifier(t, http.StatusOK, document)
+		err := v.Verify(context.Background(), uri, []string{"https://a.example.com/callback", "https://evil.example.com/callback"})
+		assertInvalidSectorIdentifier(t, err)
+	})
+
+	t.Run("document not found", func(t *testing.T) {
+		v, uri := newTestVerifier(t, http.StatusNotFound, `{}`)
+		err := v.Verify(context.Background(), uri, []string{"https://a.example.com/callback"})
+		assertInvalidSectorIdentifier(t, err)
+	})
+
+	t.Run("document is not an array", func(t *testing.T) {
+		v, uri := newTestVerifier(t, http.StatusOK, `{"redirect_uris": []}`)
+		err := v.Verify(context.Background(), uri, []string{"https://a.example.com/callback"})
+		assertInvalidSectorIdentifier(t, err)
+	})
+}
+
+func assertInvalidSectorIdentifier(t *testing.T, err error) {
+	t.Helper()
+	if assert.Error(t, err) {
+		assert.Equal(t, domain.ErrInvalidSectorIdentifierReason("").GetCode(), err.(domain.Error).GetCode())
+	}
+}
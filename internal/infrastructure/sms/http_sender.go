@@ -0,0 +1,77 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// HTTPSender delivers messages through an SMS gateway. Each message is POSTed to the configured
+// URL as JSON with its from, to, channel and message, authenticated with the configured bearer
+// token; any 2xx response means the gateway accepted it.
+type HTTPSender struct {
+	config     *config.SMSConfig
+	httpClient *http.Client
+	logger     *zap.Logger
+}
+
+// NewHTTPSender creates a new SMS gateway sender
+func NewHTTPSender(cfg *config.SMSConfig, logger *zap.Logger) *HTTPSender {
+	return &HTTPSender{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: cfg.HTTPTimeout},
+		logger:     logger,
+	}
+}
+
+type gatewayMessage struct {
+	From    string            `json:"from,omitempty"`
+	To      string            `json:"to"`
+	Channel domain.SMSChannel `json:"channel"`
+	Message string            `json:"message"`
+}
+
+// Send posts the message to the gateway
+func (s *HTTPSender) Send(ctx context.Context, phone string, channel domain.SMSChannel, message string) error {
+	body, err := json.Marshal(&gatewayMessage{
+		From:    s.config.From,
+		To:      phone,
+		Channel: channel,
+		Message: message,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.HTTPURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.HTTPToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.HTTPToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		s.logger.Error("Failed to reach SMS gateway", zap.String("channel", string(channel)), zap.Error(err))
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		s.logger.Error("SMS gateway rejected message",
+			zap.String("channel", string(channel)),
+			zap.Int("status", resp.StatusCode))
+		return fmt.Errorf("unexpected status %d from SMS gateway", resp.StatusCode)
+	}
+
+	s.logger.Debug("SMS delivered to gateway", zap.String("channel", string(channel)))
+	return nil
+}
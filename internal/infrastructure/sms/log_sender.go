@@ -0,0 +1,72 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"go.uber.org/zap"
+)
+
+// LoggedMessage is a message the log sender delivered, one JSON object per line of its file
+type LoggedMessage struct {
+	To      string            `json:"to"`
+	Channel domain.SMSChannel `json:"channel"`
+	Message string            `json:"message"`
+	SentAt  time.Time         `json:"sent_at"`
+}
+
+// LogSender delivers messages to the log instead of phones, for local development and tests.
+// The messages carry codes, so it must not be used in production.
+type LogSender struct {
+	path   string
+	mu     sync.Mutex
+	logger *zap.Logger
+}
+
+// NewLogSender creates a sender logging the messages and, when path is set, appending them to
+// the file
+func NewLogSender(path string, logger *zap.Logger) *LogSender {
+	return &LogSender{
+		path:   path,
+		logger: logger,
+	}
+}
+
+// Send logs the message
+func (s *LogSender) Send(ctx context.Context, phone string, channel domain.SMSChannel, message string) error {
+	s.logger.Info("SMS not sent, logging provider in use",
+		zap.String("to", phone),
+		zap.String("channel", string(channel)),
+		zap.String("message", message))
+
+	if s.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(&LoggedMessage{
+		To:      phone,
+		Channel: channel,
+		Message: message,
+		SentAt:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		s.logger.Error("Failed to open SMS log file", zap.String("path", s.path), zap.Error(err))
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
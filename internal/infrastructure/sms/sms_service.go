@@ -0,0 +1,16 @@
+package sms
+
+import (
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// NewSMSService creates the sender of the configured SMS provider: "http" posts to an SMS
+// gateway, and "log" only logs the messages, and appends them to a file when one is set
+func NewSMSService(cfg *config.SMSConfig, logger *zap.Logger) domain.SMSSender {
+	if cfg.Provider == "http" {
+		return NewHTTPSender(cfg, logger)
+	}
+	return NewLogSender(cfg.LogFile, logger)
+}
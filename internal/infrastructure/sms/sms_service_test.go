@@ -0,0 +1,89 @@
+package sms
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewSMSService(t *testing.T) {
+	assert.IsType(t, &LogSender{}, NewSMSService(&config.SMSConfig{Provider: "log"}, zap.NewNop()))
+	assert.IsType(t, &HTTPSender{}, NewSMSService(&config.SMSConfig{Provider: "http", HTTPURL: "http://localhost", HTTPTimeout: time.Second}, zap.NewNop()))
+}
+
+func TestLogSender_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sms.log")
+	sender := NewLogSender(path, zap.NewNop())
+
+	require.NoError(t, sender.Send(context.Background(), "+14155550123", domain.SMSChannelText, "Your code is 123456."))
+	require.NoError(t, sender.Send(context.Background(), "+14155550123", domain.SMSChannelVoice, "Your code is 1, 2, 3."))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var messages []LoggedMessage
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message LoggedMessage
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+		messages = append(messages, message)
+	}
+	require.Len(t, messages, 2)
+	assert.Equal(t, "+14155550123", messages[0].To)
+	assert.Equal(t, domain.SMSChannelText, messages[0].Channel)
+	assert.Equal(t, "Your code is 123456.", messages[0].Message)
+	assert.Equal(t, domain.SMSChannelVoice, messages[1].Channel)
+}
+
+func TestHTTPSender_Send(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		expectedError bool
+	}{
+		{name: "gateway accepts message", status: http.StatusAccepted},
+		{name: "gateway rejects message", status: http.StatusUnauthorized, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received gatewayMessage
+			var authorization string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				authorization = r.Header.Get("Authorization")
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sender := NewHTTPSender(&config.SMSConfig{
+				HTTPURL:     server.URL,
+				HTTPToken:   "gateway-token",
+				HTTPTimeout: time.Second,
+				From:        "AuthM",
+			}, zap.NewNop())
+
+			err := sender.Send(context.Background(), "+14155550123", domain.SMSChannelVoice, "Your code is 1, 2, 3.")
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, "Bearer gateway-token", authorization)
+			assert.Equal(t, gatewayMessage{From: "AuthM", To: "+14155550123", Channel: domain.SMSChannelVoice, Message: "Your code is 1, 2, 3."}, received)
+		})
+	}
+}
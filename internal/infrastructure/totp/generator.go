@@ -2,18 +2,30 @@ package totp
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base32"
+	"encoding/base64"
 	"fmt"
+	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/boombuler/barcode/qr"
 	"github.com/manorfm/authM/internal/domain"
 	"github.com/pquerna/otp"
-	"github.com/pquerna/otp/totp"
+	"github.com/pquerna/otp/hotp"
 	"go.uber.org/zap"
 )
 
+// validationSkew is the number of time-steps a code may be off by either way
+const validationSkew = 1
+
+// backupCodeSaltLength is the size of the random salt hashed with each backup code
+const backupCodeSaltLength = 16
+
 // Generator implements the domain.TOTPGenerator interface
 type Generator struct {
 	logger *zap.Logger
@@ -37,24 +49,48 @@ func (g *Generator) GenerateSecret() (string, error) {
 	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret), nil
 }
 
-// GenerateQRCode generates a QR code URL for TOTP configuration
-func (g *Generator) GenerateQRCode(config *domain.TOTPConfig) (string, error) {
-	// Format the account name and secret
-	accountName := strings.ReplaceAll(config.AccountName, ":", "%3A")
-	secret := strings.ReplaceAll(config.Secret, "=", "")
-
-	// Generate the TOTP URL
-	url := fmt.Sprintf("otpauth://totp/%s:%s?secret=%s&issuer=%s&algorithm=%s&digits=%d&period=%d",
-		config.Issuer,
-		accountName,
-		secret,
-		config.Issuer,
-		config.Algorithm,
-		config.Digits,
-		int(config.Period.Seconds()),
-	)
-
-	return url, nil
+// GenerateQRCode builds the otpauth URI of an authenticator app secret and renders it as a QR
+// code, both as a PNG data URI and as SVG
+func (g *Generator) GenerateQRCode(config *domain.TOTPConfig) (*domain.TOTPQRCode, error) {
+	uri := keyURI(config)
+
+	code, err := qr.Encode(uri, qr.M, qr.Auto)
+	if err != nil {
+		g.logger.Error("failed to encode QR code", zap.Error(err))
+		return nil, domain.ErrTOTPQRGeneration
+	}
+	modules := qrModules(code)
+
+	pngImage, err := renderQRPNG(modules)
+	if err != nil {
+		g.logger.Error("failed to render QR code", zap.Error(err))
+		return nil, domain.ErrTOTPQRGeneration
+	}
+
+	return &domain.TOTPQRCode{
+		URI: uri,
+		PNG: pngImage,
+		SVG: renderQRSVG(modules),
+	}, nil
+}
+
+// labelEscaper escapes what url.PathEscape keeps but would break the label: the colon separating
+// issuer and account, and '+', which some apps decode as a space
+var labelEscaper = strings.NewReplacer(":", "%3A", "+", "%2B")
+
+// keyURI formats the Key URI understood by authenticator apps. The issuer prefixes the label and is
+// repeated as a parameter; spaces are percent-encoded for the same reason as '+'.
+func keyURI(config *domain.TOTPConfig) string {
+	label := labelEscaper.Replace(url.PathEscape(config.Issuer)) + ":" + labelEscaper.Replace(url.PathEscape(config.AccountName))
+
+	params := url.Values{}
+	params.Set("secret", strings.TrimRight(config.Secret, "="))
+	params.Set("issuer", config.Issuer)
+	params.Set("algorithm", config.Algorithm)
+	params.Set("digits", strconv.Itoa(config.Digits))
+	params.Set("period", strconv.Itoa(int(config.Period.Seconds())))
+
+	return "otpauth://totp/" + label + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
 }
 
 // GenerateBackupCodes generates a specified number of backup codes
@@ -74,29 +110,72 @@ func (g *Generator) GenerateBackupCodes(count int) ([]string, error) {
 	return codes, nil
 }
 
-// ValidateCode validates a TOTP code
-func (g *Generator) ValidateCode(secret, code string) error {
-	// Validate the code
-	valid, err := totp.ValidateCustom(code, secret, time.Now(), totp.ValidateOpts{
-		Period:    30,
-		Skew:      1,
-		Digits:    6,
-		Algorithm: otp.AlgorithmSHA1,
-	})
+// ValidateCode validates a TOTP code with the algorithm, digits and period of the config. The
+// code may belong to the current time-step or to the one before or after it, to allow for clock
+// drift; the latest matching step is returned so callers can refuse to accept it twice.
+func (g *Generator) ValidateCode(config *domain.TOTPConfig, code string) (int64, error) {
+	algorithm, err := otpAlgorithm(config.Algorithm)
 	if err != nil {
-		g.logger.Error("failed to validate TOTP code", zap.Error(err))
-		return domain.ErrInvalidTOTPCode
+		g.logger.Error("unsupported TOTP algorithm", zap.String("algorithm", config.Algorithm))
+		return 0, domain.ErrInvalidTOTPCode
+	}
+	if config.Period < time.Second || len(code) != config.Digits {
+		g.logger.Error("invalid TOTP code")
+		return 0, domain.ErrInvalidTOTPCode
 	}
 
-	if !valid {
+	opts := hotp.ValidateOpts{
+		Digits:    otp.Digits(config.Digits),
+		Algorithm: algorithm,
+	}
+	current := time.Now().Unix() / int64(config.Period/time.Second)
+
+	matched := int64(-1)
+	for step := current - validationSkew; step <= current+validationSkew; step++ {
+		valid, err := hotp.ValidateCustom(code, uint64(step), config.Secret, opts)
+		if err != nil {
+			g.logger.Error("failed to validate TOTP code", zap.Error(err))
+			return 0, domain.ErrInvalidTOTPCode
+		}
+		if valid {
+			matched = step
+		}
+	}
+
+	if matched < 0 {
 		g.logger.Error("invalid TOTP code")
-		return domain.ErrInvalidTOTPCode
+		return 0, domain.ErrInvalidTOTPCode
 	}
 
-	return nil
+	return matched, nil
+}
+
+// otpAlgorithm maps the name of a hash algorithm to its otp constant
+func otpAlgorithm(name string) (otp.Algorithm, error) {
+	switch name {
+	case "SHA1":
+		return otp.AlgorithmSHA1, nil
+	case "SHA256":
+		return otp.AlgorithmSHA256, nil
+	case "SHA512":
+		return otp.AlgorithmSHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm %q", name)
+	}
+}
+
+// HashBackupCode hashes a backup code with a random salt, as base64(salt)$base64(sha256(salt||code))
+func (g *Generator) HashBackupCode(code string) (string, error) {
+	salt := make([]byte, backupCodeSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		g.logger.Error("failed to generate random bytes", zap.Error(err))
+		return "", domain.ErrTOTPBackupCodesGeneration
+	}
+	return encodeBackupCodeHash(salt, code), nil
 }
 
-// ValidateBackupCode validates a backup code
+// ValidateBackupCode finds the hashed backup code matching a code. Every unused entry is
+// compared in constant time so the position of a match does not show in the response time.
 func (g *Generator) ValidateBackupCode(backupCodes []string, code string) (int, error) {
 	// Check if the code matches the pattern
 	pattern := regexp.MustCompile(`^[A-Z0-9]{10}$`)
@@ -105,13 +184,38 @@ func (g *Generator) ValidateBackupCode(backupCodes []string, code string) (int,
 		return -1, domain.ErrInvalidTOTPBackupCode
 	}
 
-	// Find the code in the list
+	index := -1
 	for i, backupCode := range backupCodes {
-		if backupCode == code {
-			return i, nil
+		// Used codes are blanked out
+		if backupCode == "" {
+			continue
+		}
+		salt, err := backupCodeSalt(backupCode)
+		if err != nil {
+			g.logger.Error("malformed backup code hash", zap.Int("index", i))
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(encodeBackupCodeHash(salt, code)), []byte(backupCode)) == 1 && index < 0 {
+			index = i
 		}
 	}
 
-	g.logger.Error("invalid backup code")
-	return -1, domain.ErrInvalidTOTPBackupCode
+	if index < 0 {
+		g.logger.Error("invalid backup code")
+		return -1, domain.ErrInvalidTOTPBackupCode
+	}
+	return index, nil
+}
+
+func encodeBackupCodeHash(salt []byte, code string) string {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), code...))
+	return base64.StdEncoding.EncodeToString(salt) + "$" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func backupCodeSalt(hash string) ([]byte, error) {
+	encodedSalt, _, found := strings.Cut(hash, "$")
+	if !found {
+		return nil, domain.ErrInvalidTOTPBackupCode
+	}
+	return base64.StdEncoding.DecodeString(encodedSalt)
 }
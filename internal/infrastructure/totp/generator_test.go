@@ -1,12 +1,18 @@
 package totp
 
 import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/manorfm/authM/internal/domain"
+	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -27,8 +33,8 @@ func TestTOTPGenerator_GenerateQRCode(t *testing.T) {
 	// Setup
 	generator := NewGenerator(zap.NewNop())
 	config := &domain.TOTPConfig{
-		Issuer:      "test",
-		AccountName: "user@example.com",
+		Issuer:      "Acme R&D",
+		AccountName: "jane+mfa@example.com",
 		Secret:      "JBSWY3DPEHPK3PXP",
 		Period:      30 * time.Second,
 		Digits:      6,
@@ -39,11 +45,28 @@ func TestTOTPGenerator_GenerateQRCode(t *testing.T) {
 	qrCode, err := generator.GenerateQRCode(config)
 
 	// Assert
-	assert.NoError(t, err)
-	assert.NotEmpty(t, qrCode)
-	assert.Contains(t, qrCode, "otpauth://totp/")
-	assert.Contains(t, qrCode, "issuer=test")
-	assert.Contains(t, qrCode, "secret=JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth://totp/Acme%20R&D:jane%2Bmfa@example.com?algorithm=SHA1&digits=6&issuer=Acme%20R%26D&period=30&secret=JBSWY3DPEHPK3PXP", qrCode.URI)
+
+	// The URI parses back to the same key
+	key, err := otp.NewKeyFromURL(qrCode.URI)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme R&D", key.Issuer())
+	assert.Equal(t, "jane+mfa@example.com", key.AccountName())
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", key.Secret())
+
+	// The PNG is a square image with a white quiet zone
+	require.True(t, strings.HasPrefix(qrCode.PNG, "data:image/png;base64,"))
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(qrCode.PNG, "data:image/png;base64,"))
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, img.Bounds().Dx(), img.Bounds().Dy())
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+
+	assert.True(t, strings.HasPrefix(qrCode.SVG, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	assert.Contains(t, qrCode.SVG, `<path fill="#000" d="M`)
 }
 
 func TestTOTPGenerator_GenerateBackupCodes(t *testing.T) {
@@ -67,34 +90,77 @@ func TestTOTPGenerator_ValidateCode(t *testing.T) {
 	// Setup
 	generator := NewGenerator(zap.NewNop())
 	secret := "JBSWY3DPEHPK3PXP"
+	now := time.Now()
+	defaultConfig := &domain.TOTPConfig{Secret: secret, Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}
+	strongConfig := &domain.TOTPConfig{Secret: secret, Algorithm: "SHA512", Digits: 8, Period: 60 * time.Second}
 
-	// Generate a valid TOTP code for the current time
-	validCode, err := totp.GenerateCode(secret, time.Now())
-	if err != nil {
-		t.Fatalf("failed to generate valid TOTP code: %v", err)
+	generate := func(config *domain.TOTPConfig, at time.Time) string {
+		algorithm, err := otpAlgorithm(config.Algorithm)
+		require.NoError(t, err)
+		code, err := totp.GenerateCodeCustom(config.Secret, at, totp.ValidateOpts{
+			Period:    uint(config.Period / time.Second),
+			Digits:    otp.Digits(config.Digits),
+			Algorithm: algorithm,
+		})
+		require.NoError(t, err)
+		return code
+	}
+	step := func(config *domain.TOTPConfig, at time.Time) int64 {
+		return at.Unix() / int64(config.Period/time.Second)
 	}
 
 	tests := []struct {
-		name        string
-		secret      string
-		code        string
-		expectError bool
+		name         string
+		config       *domain.TOTPConfig
+		code         string
+		expectedStep int64
+		expectError  bool
 	}{
 		{
-			name:        "Valid Code",
-			secret:      secret,
-			code:        validCode,
-			expectError: false,
+			name:         "Valid Code",
+			config:       defaultConfig,
+			code:         generate(defaultConfig, now),
+			expectedStep: step(defaultConfig, now),
+		},
+		{
+			name:         "Previous Step Within Skew",
+			config:       defaultConfig,
+			code:         generate(defaultConfig, now.Add(-30*time.Second)),
+			expectedStep: step(defaultConfig, now.Add(-30*time.Second)),
+		},
+		{
+			name:        "Outside Skew",
+			config:      defaultConfig,
+			code:        generate(defaultConfig, now.Add(-2*time.Minute)),
+			expectError: true,
+		},
+		{
+			name:         "SHA512 With Eight Digits",
+			config:       strongConfig,
+			code:         generate(strongConfig, now),
+			expectedStep: step(strongConfig, now),
+		},
+		{
+			name:        "Code Of Other Parameters",
+			config:      defaultConfig,
+			code:        generate(strongConfig, now),
+			expectError: true,
 		},
 		{
 			name:        "Invalid Secret",
-			secret:      "invalid",
-			code:        validCode,
+			config:      &domain.TOTPConfig{Secret: "invalid", Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second},
+			code:        generate(defaultConfig, now),
+			expectError: true,
+		},
+		{
+			name:        "Unsupported Algorithm",
+			config:      &domain.TOTPConfig{Secret: secret, Algorithm: "MD5", Digits: 6, Period: 30 * time.Second},
+			code:        generate(defaultConfig, now),
 			expectError: true,
 		},
 		{
 			name:        "Invalid Code Format",
-			secret:      secret,
+			config:      defaultConfig,
 			code:        "invalid",
 			expectError: true,
 		},
@@ -103,13 +169,14 @@ func TestTOTPGenerator_ValidateCode(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Execute
-			err := generator.ValidateCode(tt.secret, tt.code)
+			step, err := generator.ValidateCode(tt.config, tt.code)
 
 			// Assert
 			if tt.expectError {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, domain.ErrInvalidTOTPCode)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedStep, step)
 			}
 		})
 	}
@@ -118,7 +185,11 @@ func TestTOTPGenerator_ValidateCode(t *testing.T) {
 func TestTOTPGenerator_ValidateBackupCode(t *testing.T) {
 	// Setup
 	generator := NewGenerator(zap.NewNop())
-	codes := []string{"ABCDEF1234", "GHIJKL5678"}
+	first, err := generator.HashBackupCode("ABCDEF1234")
+	require.NoError(t, err)
+	second, err := generator.HashBackupCode("GHIJKL5678")
+	require.NoError(t, err)
+	codes := []string{first, second}
 
 	tests := []struct {
 		name          string
@@ -141,6 +212,27 @@ func TestTOTPGenerator_ValidateBackupCode(t *testing.T) {
 			expectedIndex: -1,
 			expectError:   true,
 		},
+		{
+			name:          "Second Code",
+			codes:         codes,
+			code:          "GHIJKL5678",
+			expectedIndex: 1,
+			expectError:   false,
+		},
+		{
+			name:          "Used Code",
+			codes:         []string{"", second},
+			code:          "ABCDEF1234",
+			expectedIndex: -1,
+			expectError:   true,
+		},
+		{
+			name:          "Plaintext Code Is Not A Hash",
+			codes:         []string{"ABCDEF1234"},
+			code:          "ABCDEF1234",
+			expectedIndex: -1,
+			expectError:   true,
+		},
 		{
 			name:          "Nil Codes",
 			codes:         nil,
@@ -165,3 +257,16 @@ func TestTOTPGenerator_ValidateBackupCode(t *testing.T) {
 		})
 	}
 }
+
+func TestTOTPGenerator_HashBackupCode(t *testing.T) {
+	generator := NewGenerator(zap.NewNop())
+
+	first, err := generator.HashBackupCode("ABCDEF1234")
+	require.NoError(t, err)
+	second, err := generator.HashBackupCode("ABCDEF1234")
+	require.NoError(t, err)
+
+	assert.NotContains(t, first, "ABCDEF1234")
+	assert.NotEqual(t, first, second, "each hash should use its own salt")
+	assert.Regexp(t, `^[A-Za-z0-9+/=]+\$[A-Za-z0-9+/=]+$`, first)
+}
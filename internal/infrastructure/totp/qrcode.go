@@ -0,0 +1,77 @@
+package totp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/boombuler/barcode"
+)
+
+const (
+	// qrQuietZone is the blank border, in modules, that scanners need around a QR code
+	qrQuietZone = 4
+	// qrModuleSize is the width in pixels of one module of the PNG image
+	qrModuleSize = 8
+)
+
+// qrModules lists the dark modules of a QR code, as a matrix indexed by row then column
+func qrModules(code barcode.Barcode) [][]bool {
+	size := code.Bounds().Dx()
+	modules := make([][]bool, size)
+	for y := range modules {
+		modules[y] = make([]bool, size)
+		for x := range modules[y] {
+			r, _, _, _ := code.At(x, y).RGBA()
+			modules[y][x] = r == 0
+		}
+	}
+	return modules
+}
+
+// renderQRPNG draws a QR code with its quiet zone and returns it as a base64 PNG data URI
+func renderQRPNG(modules [][]bool) (string, error) {
+	size := (len(modules) + 2*qrQuietZone) * qrModuleSize
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			left, top := (x+qrQuietZone)*qrModuleSize, (y+qrQuietZone)*qrModuleSize
+			for py := top; py < top+qrModuleSize; py++ {
+				for px := left; px < left+qrModuleSize; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// renderQRSVG draws a QR code with its quiet zone as an SVG document, one square per dark module
+func renderQRSVG(modules [][]bool) string {
+	size := len(modules) + 2*qrQuietZone
+
+	var path strings.Builder
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+qrQuietZone, y+qrQuietZone)
+			}
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		size, size, size*qrModuleSize, size*qrModuleSize, size, size, path.String())
+}
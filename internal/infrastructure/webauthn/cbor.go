@@ -0,0 +1,142 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// maxCBORDepth bounds nesting so that a crafted attestation cannot exhaust the stack
+const maxCBORDepth = 16
+
+var errTruncatedCBOR = errors.New("truncated CBOR data")
+
+// decodeCBOR decodes the first CBOR data item in data and returns it with the remaining bytes.
+// Only the subset used by WebAuthn authenticators is supported: integers, byte and text strings,
+// arrays, maps, booleans and null, all with definite lengths as CTAP2 requires. Integers decode to
+// int64, byte strings to []byte, text strings to string, arrays to []any and maps to map[any]any.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (any, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errors.New("CBOR data nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, nil, errTruncatedCBOR
+	}
+
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		default:
+			return nil, nil, fmt.Errorf("unsupported CBOR simple value %d", info)
+		}
+	}
+
+	arg, data, err := readCBORArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("CBOR integer overflows int64")
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > 1<<63-1 {
+			return nil, nil, errors.New("CBOR integer overflows int64")
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errTruncatedCBOR
+		}
+		value := data[:arg]
+		if major == 3 {
+			return string(value), data[arg:], nil
+		}
+		return append([]byte(nil), value...), data[arg:], nil
+	case 4:
+		// Every item takes at least one byte, which bounds the allocation below
+		if arg > uint64(len(data)) {
+			return nil, nil, errTruncatedCBOR
+		}
+		items := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item any
+			if item, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, nil, errTruncatedCBOR
+		}
+		entries := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value any
+			if key, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.New("unsupported CBOR map key type")
+			}
+			if value, data, err = decodeCBORItem(data, depth+1); err != nil {
+				return nil, nil, err
+			}
+			if _, duplicate := entries[key]; duplicate {
+				return nil, nil, errors.New("duplicate CBOR map key")
+			}
+			entries[key] = value
+		}
+		return entries, data, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported CBOR major type %d", major)
+	}
+}
+
+// readCBORArgument reads the argument that follows an initial byte with the given additional information
+func readCBORArgument(info byte, data []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		if len(data) < 1 {
+			return 0, nil, errTruncatedCBOR
+		}
+		return uint64(data[0]), data[1:], nil
+	case info == 25:
+		if len(data) < 2 {
+			return 0, nil, errTruncatedCBOR
+		}
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26:
+		if len(data) < 4 {
+			return 0, nil, errTruncatedCBOR
+		}
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27:
+		if len(data) < 8 {
+			return 0, nil, errTruncatedCBOR
+		}
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, nil, errors.New("indefinite length CBOR items are not supported")
+	}
+}
@@ -0,0 +1,129 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for passkeys, in order of preference
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms lists the COSE algorithms offered to authenticators during registration
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters, see RFC 9053
+const (
+	coseKeyType      = 1
+	coseKeyAlgorithm = 3
+	coseCurve        = -1
+	coseX            = -2
+	coseY            = -3
+	coseRSAModulus   = -1
+	coseRSAExponent  = -2
+
+	coseKeyTypeOKP = 1
+	coseKeyTypeEC2 = 2
+	coseKeyTypeRSA = 3
+
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// minRSAKeyBits rejects RSA credential keys too short to be trusted
+const minRSAKeyBits = 2048
+
+// publicKey is a credential public key decoded from its COSE form
+type publicKey struct {
+	alg int
+	key crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key, returning the key and the bytes that follow it
+func parsePublicKey(data []byte) (*publicKey, []byte, error) {
+	decoded, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode COSE key: %w", err)
+	}
+	params, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, nil, errors.New("COSE key is not a map")
+	}
+
+	kty, _ := params[int64(coseKeyType)].(int64)
+	alg, _ := params[int64(coseKeyAlgorithm)].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == AlgES256:
+		crv, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, nil, errors.New("invalid ES256 key parameters")
+		}
+		// Building the uncompressed point through ecdh checks that it lies on the curve
+		point := append([]byte{0x04}, append(x, y...)...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, nil, errors.New("ES256 key is not on the P-256 curve")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return &publicKey{alg: AlgES256, key: key}, rest, nil
+
+	case kty == coseKeyTypeOKP && alg == AlgEdDSA:
+		crv, _ := params[int64(coseCurve)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("invalid EdDSA key parameters")
+		}
+		return &publicKey{alg: AlgEdDSA, key: ed25519.PublicKey(x)}, rest, nil
+
+	case kty == coseKeyTypeRSA && alg == AlgRS256:
+		n, _ := params[int64(coseRSAModulus)].([]byte)
+		e, _ := params[int64(coseRSAExponent)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, nil, errors.New("invalid RS256 key parameters")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits || key.E < 3 {
+			return nil, nil, errors.New("RS256 key is too weak")
+		}
+		return &publicKey{alg: AlgRS256, key: key}, rest, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported COSE key type %d with algorithm %d", kty, alg)
+	}
+}
+
+// verify checks a signature over message made with the key
+func (k *publicKey) verify(message, signature []byte) error {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid ES256 signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, message, signature) {
+			return errors.New("invalid EdDSA signature")
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(message)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid RS256 signature")
+		}
+	default:
+		return errors.New("unsupported public key")
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// Authenticator data flags, see https://www.w3.org/TR/webauthn-3/#authenticator-data
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+	flagExtensions   = 0x80
+)
+
+const (
+	clientDataCreate = "webauthn.create"
+	clientDataGet    = "webauthn.get"
+
+	// authDataMinLength is the RP ID hash, the flags and the signature counter
+	authDataMinLength = sha256.Size + 1 + 4
+	aaguidLength      = 16
+)
+
+// Verifier verifies WebAuthn registration and authentication responses for one relying party.
+// Attestation statements are checked for integrity but not chained to a trusted root, as
+// registrations ask for no attestation and passkeys are trusted on first use.
+type Verifier struct {
+	rpIDHash [sha256.Size]byte
+	origins  []string
+	logger   *zap.Logger
+}
+
+// NewVerifier creates a verifier for the configured relying party ID and origins
+func NewVerifier(cfg *config.Config, logger *zap.Logger) *Verifier {
+	return &Verifier{
+		rpIDHash: sha256.Sum256([]byte(cfg.WebAuthnRPID)),
+		origins:  cfg.WebAuthnOrigins,
+		logger:   logger,
+	}
+}
+
+// clientData is the part of CollectedClientData that the relying party checks
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// authenticatorData is the parsed authenticator data of a registration or assertion
+type authenticatorData struct {
+	raw          []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+	key          *publicKey
+}
+
+// VerifyRegistration verifies a registration response against the challenge it answers
+func (v *Verifier) VerifyRegistration(challenge []byte, response *domain.WebAuthnAttestationResponse, requireUserVerification bool) (*domain.WebAuthnAttestation, error) {
+	clientDataJSON := response.Response.ClientDataJSON
+	if err := v.verifyClientData(clientDataJSON, clientDataCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, rest, err := decodeCBOR(response.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("decode attestation object: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after attestation object")
+	}
+	attestation, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, errors.New("attestation object is not a map")
+	}
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[any]any)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	if statement == nil {
+		return nil, errors.New("attestation statement is missing")
+	}
+
+	authData, err := v.parseAuthenticatorData(rawAuthData, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+	if authData.key == nil {
+		return nil, errors.New("registration carries no attested credential")
+	}
+	if !bytes.Equal(authData.credentialID, response.RawID) {
+		return nil, errors.New("credential ID does not match the attested credential")
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := verifyAttestationStatement(format, statement, authData, clientDataHash[:]); err != nil {
+		return nil, err
+	}
+
+	return &domain.WebAuthnAttestation{
+		CredentialID: authData.credentialID,
+		PublicKey:    authData.publicKey,
+		SignCount:    authData.signCount,
+		AAGUID:       formatAAGUID(authData.aaguid),
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion verifies an authentication response against the challenge and the stored COSE public key
+func (v *Verifier) VerifyAssertion(challenge []byte, response *domain.WebAuthnAssertionResponse, storedKey []byte, requireUserVerification bool) (*domain.WebAuthnAssertion, error) {
+	clientDataJSON := response.Response.ClientDataJSON
+	if err := v.verifyClientData(clientDataJSON, clientDataGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := v.parseAuthenticatorData(response.Response.AuthenticatorData, requireUserVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	key, _, err := parsePublicKey(storedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authData.raw...), clientDataHash[:]...)
+	if err := key.verify(signed, response.Response.Signature); err != nil {
+		return nil, err
+	}
+
+	return &domain.WebAuthnAssertion{
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// verifyClientData checks the ceremony type, challenge and origin the client signed over
+func (v *Verifier) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("decode client data: %w", err)
+	}
+	if data.Type != ceremony {
+		return fmt.Errorf("unexpected client data type %q", data.Type)
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return errors.New("client data does not answer the challenge")
+	}
+
+	if !slices.Contains(v.origins, data.Origin) {
+		v.logger.Warn("WebAuthn response from unexpected origin",
+			zap.String("origin", data.Origin))
+		return fmt.Errorf("unexpected origin %q", data.Origin)
+	}
+	if data.CrossOrigin {
+		return errors.New("cross-origin WebAuthn responses are not accepted")
+	}
+	return nil
+}
+
+// parseAuthenticatorData parses authenticator data and checks it is scoped to this relying party
+func (v *Verifier) parseAuthenticatorData(raw []byte, requireUserVerification bool) (*authenticatorData, error) {
+	if len(raw) < authDataMinLength {
+		return nil, errors.New("authenticator data is too short")
+	}
+	if subtle.ConstantTimeCompare(raw[:sha256.Size], v.rpIDHash[:]) != 1 {
+		return nil, errors.New("authenticator data is for another relying party")
+	}
+
+	data := &authenticatorData{
+		raw:       raw,
+		flags:     raw[sha256.Size],
+		signCount: binary.BigEndian.Uint32(raw[sha256.Size+1:]),
+	}
+	if data.flags&flagUserPresent == 0 {
+		return nil, errors.New("user presence was not confirmed")
+	}
+	if requireUserVerification && data.flags&flagUserVerified == 0 {
+		return nil, errors.New("user verification was not performed")
+	}
+
+	rest := raw[authDataMinLength:]
+	if data.flags&flagAttestedData != 0 {
+		if len(rest) < aaguidLength+2 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		data.aaguid = rest[:aaguidLength]
+		idLength := int(binary.BigEndian.Uint16(rest[aaguidLength:]))
+		rest = rest[aaguidLength+2:]
+		if len(rest) < idLength {
+			return nil, errors.New("credential ID is truncated")
+		}
+		data.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		key, afterKey, err := parsePublicKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		data.key = key
+		data.publicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+	if data.flags&flagExtensions != 0 {
+		var err error
+		if _, rest, err = decodeCBOR(rest); err != nil {
+			return nil, fmt.Errorf("decode authenticator extensions: %w", err)
+		}
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after authenticator data")
+	}
+	return data, nil
+}
+
+// verifyAttestationStatement checks the statement of the "none" and "packed" formats
+func verifyAttestationStatement(format string, statement map[any]any, authData *authenticatorData, clientDataHash []byte) error {
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return errors.New("none attestation carries a statement")
+		}
+		return nil
+	case "packed":
+	default:
+		return fmt.Errorf("unsupported attestation format %q", format)
+	}
+
+	alg, _ := statement["alg"].(int64)
+	signature, _ := statement["sig"].([]byte)
+	if len(signature) == 0 {
+		return errors.New("packed attestation has no signature")
+	}
+	signed := append(append([]byte(nil), authData.raw...), clientDataHash...)
+
+	chain, hasChain := statement["x5c"].([]any)
+	if !hasChain {
+		// Self attestation is signed with the credential key itself
+		if int(alg) != authData.key.alg {
+			return errors.New("self attestation algorithm does not match the credential key")
+		}
+		return authData.key.verify(signed, signature)
+	}
+
+	if len(chain) == 0 {
+		return errors.New("packed attestation has an empty certificate chain")
+	}
+	leaf, _ := chain[0].([]byte)
+	certificate, err := x509.ParseCertificate(leaf)
+	if err != nil {
+		return fmt.Errorf("parse attestation certificate: %w", err)
+	}
+	var signatureAlgorithm x509.SignatureAlgorithm
+	switch alg {
+	case AlgES256:
+		signatureAlgorithm = x509.ECDSAWithSHA256
+	case AlgEdDSA:
+		signatureAlgorithm = x509.PureEd25519
+	case AlgRS256:
+		signatureAlgorithm = x509.SHA256WithRSA
+	default:
+		return fmt.Errorf("unsupported attestation algorithm %d", alg)
+	}
+	if err := certificate.CheckSignature(signatureAlgorithm, signed, signature); err != nil {
+		return fmt.Errorf("invalid packed attestation signature: %w", err)
+	}
+	return nil
+}
+
+// formatAAGUID renders an authenticator model identifier in UUID form
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != aaguidLength {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
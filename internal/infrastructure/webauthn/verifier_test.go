@@ -0,0 +1,267 @@
+package webauthn
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/manorfm/authM/internal/infrastructure/webauthn/webauthntest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://example.com"
+)
+
+func newTestVerifier() *Verifier {
+	return NewVerifier(&config.Config{
+		WebAuthnRPID:    testRPID,
+		WebAuthnOrigins: []string{testOrigin},
+	}, zap.NewNop())
+}
+
+func newTestAuthenticator(t *testing.T) *webauthntest.Authenticator {
+	authenticator, err := webauthntest.NewAuthenticator(testRPID, testOrigin)
+	require.NoError(t, err)
+	return authenticator
+}
+
+func randomChallenge(t *testing.T) []byte {
+	challenge := make([]byte, 32)
+	_, err := rand.Read(challenge)
+	require.NoError(t, err)
+	return challenge
+}
+
+func TestVerifier_Registration(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(a *webauthntest.Authenticator)
+		tamper    func(r *domain.WebAuthnAttestationResponse)
+		verifier  func() *Verifier
+		requireUV bool
+		wantErr   bool
+	}{
+		{
+			name: "none attestation",
+		},
+		{
+			name:  "packed self attestation",
+			setup: func(a *webauthntest.Authenticator) { a.SelfAttestation = true },
+		},
+		{
+			name: "origin not allowed",
+			verifier: func() *Verifier {
+				return NewVerifier(&config.Config{WebAuthnRPID: testRPID, WebAuthnOrigins: []string{"https://other.example.com"}}, zap.NewNop())
+			},
+			wantErr: true,
+		},
+		{
+			name:    "credential for another relying party",
+			setup:   func(a *webauthntest.Authenticator) { a.RPID = "evil.example" },
+			wantErr: true,
+		},
+		{
+			name:      "user verification required but not performed",
+			setup:     func(a *webauthntest.Authenticator) { a.UserVerification = false },
+			requireUV: true,
+			wantErr:   true,
+		},
+		{
+			name:    "raw ID does not match the attested credential",
+			tamper:  func(r *domain.WebAuthnAttestationResponse) { r.RawID = []byte("other") },
+			wantErr: true,
+		},
+		{
+			name:  "tampered self attestation signature",
+			setup: func(a *webauthntest.Authenticator) { a.SelfAttestation = true },
+			tamper: func(r *domain.WebAuthnAttestationResponse) {
+				r.Response.ClientDataJSON = append(r.Response.ClientDataJSON[:len(r.Response.ClientDataJSON)-1], []byte(`,"extra":1}`)...)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "attestation object is not CBOR",
+			tamper:  func(r *domain.WebAuthnAttestationResponse) { r.Response.AttestationObject = []byte{0x5f, 0x00} },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t)
+			authenticator.AAGUID = [16]byte{0xad, 0xce, 0x00, 0x02}
+			if tt.setup != nil {
+				tt.setup(authenticator)
+			}
+
+			challenge := randomChallenge(t)
+			response, err := authenticator.Register(challenge, []byte("user-handle"))
+			require.NoError(t, err)
+			if tt.tamper != nil {
+				tt.tamper(response)
+			}
+
+			verifier := newTestVerifier()
+			if tt.verifier != nil {
+				verifier = tt.verifier()
+			}
+
+			attestation, err := verifier.VerifyRegistration(challenge, response, tt.requireUV)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, authenticator.CredentialID, attestation.CredentialID)
+			assert.Equal(t, authenticator.PublicKey(), attestation.PublicKey)
+			assert.Equal(t, "adce0002-0000-0000-0000-000000000000", attestation.AAGUID)
+			assert.True(t, attestation.UserVerified)
+		})
+	}
+}
+
+func TestVerifier_RegistrationRejectsOtherChallenge(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	response, err := authenticator.Register(randomChallenge(t), nil)
+	require.NoError(t, err)
+
+	_, err = newTestVerifier().VerifyRegistration(randomChallenge(t), response, false)
+	assert.Error(t, err)
+}
+
+func TestVerifier_Assertion(t *testing.T) {
+	authenticator := newTestAuthenticator(t)
+	verifier := newTestVerifier()
+
+	challenge := randomChallenge(t)
+	response, err := authenticator.Assert(challenge)
+	require.NoError(t, err)
+
+	assertion, err := verifier.VerifyAssertion(challenge, response, authenticator.PublicKey(), true)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), assertion.SignCount)
+	assert.True(t, assertion.UserVerified)
+
+	t.Run("other challenge", func(t *testing.T) {
+		_, err := verifier.VerifyAssertion(randomChallenge(t), response, authenticator.PublicKey(), false)
+		assert.Error(t, err)
+	})
+
+	t.Run("key of another credential", func(t *testing.T) {
+		other := newTestAuthenticator(t)
+		_, err := verifier.VerifyAssertion(challenge, response, other.PublicKey(), false)
+		assert.Error(t, err)
+	})
+
+	t.Run("registration client data", func(t *testing.T) {
+		registration, err := authenticator.Register(challenge, nil)
+		require.NoError(t, err)
+		replayed := *response
+		replayed.Response.ClientDataJSON = registration.Response.ClientDataJSON
+		_, err = verifier.VerifyAssertion(challenge, &replayed, authenticator.PublicKey(), false)
+		assert.Error(t, err)
+	})
+
+	t.Run("user verification required", func(t *testing.T) {
+		authenticator.UserVerification = false
+		response, err := authenticator.Assert(challenge)
+		require.NoError(t, err)
+
+		_, err = verifier.VerifyAssertion(challenge, response, authenticator.PublicKey(), true)
+		assert.Error(t, err)
+
+		assertion, err := verifier.VerifyAssertion(challenge, response, authenticator.PublicKey(), false)
+		require.NoError(t, err)
+		assert.False(t, assertion.UserVerified)
+	})
+}
+
+func TestVerifier_AssertionEdDSA(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	coseKey := webauthntest.EncodeCBOR(map[any]any{
+		int64(1):  int64(coseKeyTypeOKP),
+		int64(3):  int64(AlgEdDSA),
+		int64(-1): int64(coseCurveEd25519),
+		int64(-2): []byte(public),
+	})
+
+	// Reuse the ES256 authenticator for the client and authenticator data, then sign with Ed25519
+	authenticator := newTestAuthenticator(t)
+	challenge := randomChallenge(t)
+	response, err := authenticator.Assert(challenge)
+	require.NoError(t, err)
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	response.Response.Signature = ed25519.Sign(private, append(append([]byte(nil), response.Response.AuthenticatorData...), clientDataHash[:]...))
+
+	_, err = newTestVerifier().VerifyAssertion(challenge, response, coseKey, true)
+	assert.NoError(t, err)
+}
+
+func TestParseAuthenticatorData(t *testing.T) {
+	verifier := newTestVerifier()
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := binary.BigEndian.AppendUint32(append(rpIDHash[:], flagUserPresent), 7)
+
+	parsed, err := verifier.parseAuthenticatorData(data, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), parsed.signCount)
+
+	_, err = verifier.parseAuthenticatorData(append(data, 0x00), false)
+	assert.Error(t, err, "trailing data is rejected")
+
+	_, err = verifier.parseAuthenticatorData(data[:20], false)
+	assert.Error(t, err, "short data is rejected")
+
+	noPresence := append([]byte(nil), data...)
+	noPresence[sha256.Size] = 0
+	_, err = verifier.parseAuthenticatorData(noPresence, false)
+	assert.Error(t, err, "user presence is required")
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	encoded := webauthntest.EncodeCBOR(map[any]any{
+		"fmt":     "none",
+		int64(-3): []byte{1, 2, 3},
+		"list":    []any{int64(500), true, int64(-70000)},
+	})
+
+	decoded, rest, err := decodeCBOR(append(encoded, 0xff))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff}, rest)
+	assert.Equal(t, map[any]any{
+		"fmt":     "none",
+		int64(-3): []byte{1, 2, 3},
+		"list":    []any{int64(500), true, int64(-70000)},
+	}, decoded)
+
+	invalid := map[string][]byte{
+		"truncated byte string": {0x43, 0x01},
+		"indefinite length":     {0x5f, 0x41, 0x00, 0xff},
+		"huge array":            {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"float":                 {0xf9, 0x3c, 0x00},
+		"duplicate map key":     {0xa2, 0x01, 0x01, 0x01, 0x02},
+		"empty":                 {},
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := decodeCBOR(data)
+			assert.Error(t, err)
+		})
+	}
+
+	deep := make([]byte, 0, maxCBORDepth+2)
+	for i := 0; i <= maxCBORDepth+1; i++ {
+		deep = append(deep, 0x81)
+	}
+	_, _, err = decodeCBOR(append(deep, 0x00))
+	assert.Error(t, err, "deeply nested data is rejected")
+}
@@ -0,0 +1,231 @@
+// Package webauthntest provides a software authenticator that performs WebAuthn ceremonies in tests
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"sort"
+
+	"github.com/manorfm/authM/internal/domain"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// Authenticator is an ES256 platform authenticator holding a single credential.
+// Its fields can be changed between ceremonies to produce responses a relying party must reject.
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// UserVerification sets the UV flag, as if the user entered a PIN or used a biometric
+	UserVerification bool
+	// SignCount is the counter reported by the next ceremony, incremented on every assertion
+	SignCount uint32
+	// SelfAttestation returns a packed self attestation instead of the "none" format
+	SelfAttestation bool
+	// AAGUID identifies the authenticator model
+	AAGUID [16]byte
+
+	CredentialID []byte
+	UserHandle   []byte
+	key          *ecdsa.PrivateKey
+}
+
+// NewAuthenticator creates an authenticator with a fresh credential key
+func NewAuthenticator(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	credentialID := make([]byte, 32)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+	return &Authenticator{
+		RPID:             rpID,
+		Origin:           origin,
+		UserVerification: true,
+		CredentialID:     credentialID,
+		key:              key,
+	}, nil
+}
+
+// Register answers creation options as navigator.credentials.create would
+func (a *Authenticator) Register(challenge []byte, userHandle []byte) (*domain.WebAuthnAttestationResponse, error) {
+	a.UserHandle = userHandle
+	clientDataJSON, err := a.clientData("webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(flagAttestedData)
+	authData = append(authData, a.AAGUID[:]...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, a.PublicKey()...)
+
+	statement := map[any]any{}
+	format := "none"
+	if a.SelfAttestation {
+		signature, err := a.sign(authData, clientDataJSON)
+		if err != nil {
+			return nil, err
+		}
+		format = "packed"
+		statement = map[any]any{"alg": int64(-7), "sig": signature}
+	}
+
+	response := &domain.WebAuthnAttestationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+	}
+	response.Response.ClientDataJSON = clientDataJSON
+	response.Response.AttestationObject = EncodeCBOR(map[any]any{
+		"fmt":      format,
+		"attStmt":  statement,
+		"authData": authData,
+	})
+	response.Response.Transports = []string{"internal"}
+	return response, nil
+}
+
+// Assert answers request options as navigator.credentials.get would
+func (a *Authenticator) Assert(challenge []byte) (*domain.WebAuthnAssertionResponse, error) {
+	a.SignCount++
+	clientDataJSON, err := a.clientData("webauthn.get", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(0)
+	signature, err := a.sign(authData, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &domain.WebAuthnAssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		RawID: a.CredentialID,
+		Type:  "public-key",
+	}
+	response.Response.ClientDataJSON = clientDataJSON
+	response.Response.AuthenticatorData = authData
+	response.Response.Signature = signature
+	response.Response.UserHandle = a.UserHandle
+	return response, nil
+}
+
+// PublicKey returns the COSE encoding of the credential public key
+func (a *Authenticator) PublicKey() []byte {
+	return EncodeCBOR(map[any]any{
+		int64(1):  int64(2),
+		int64(3):  int64(-7),
+		int64(-1): int64(1),
+		int64(-2): pad32(a.key.X),
+		int64(-3): pad32(a.key.Y),
+	})
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	flags |= flagUserPresent
+	if a.UserVerification {
+		flags |= flagUserVerified
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) sign(authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+}
+
+func pad32(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+// EncodeCBOR encodes int64, []byte, string, bool, []any and map[any]any values, with map keys
+// in the canonical order CTAP2 authenticators use
+func EncodeCBOR(value any) []byte {
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case []any:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, EncodeCBOR(item)...)
+		}
+		return out
+	case map[any]any:
+		keys := make([][]byte, 0, len(v))
+		entries := make(map[string][]byte, len(v))
+		for key, item := range v {
+			encodedKey := EncodeCBOR(key)
+			keys = append(keys, encodedKey)
+			entries[string(encodedKey)] = EncodeCBOR(item)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return string(keys[i]) < string(keys[j])
+		})
+		out := cborHead(5, uint64(len(v)))
+		for _, key := range keys {
+			out = append(out, key...)
+			out = append(out, entries[string(key)]...)
+		}
+		return out
+	default:
+		panic("webauthntest: unsupported CBOR value")
+	}
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major | 27}, arg)
+	}
+}
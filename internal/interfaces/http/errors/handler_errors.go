@@ -2,7 +2,10 @@ package errors
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/manorfm/authM/internal/domain"
 )
@@ -17,6 +20,7 @@ type ErrorResponse struct {
 // ErrorDetail represents a validation error detail
 type ErrorDetail struct {
 	Field   string `json:"field"`
+	Rule    string `json:"rule,omitempty"`
 	Message string `json:"message"`
 }
 
@@ -36,8 +40,48 @@ func getStatus(err domain.Error) int {
 		return http.StatusForbidden
 	case domain.ErrTOTPVerificationRequired.GetCode():
 		return http.StatusForbidden
+	case domain.ErrMFAEnrollmentRequired.GetCode():
+		return http.StatusForbidden
+	case domain.ErrInsufficientUserAuthentication.GetCode():
+		return http.StatusUnauthorized
+	case domain.ErrTrustedDeviceNotFound.GetCode():
+		return http.StatusNotFound
+	case domain.ErrDeviceTrustNotAllowed.GetCode():
+		return http.StatusForbidden
 	case domain.ErrDatabaseQuery.GetCode():
 		return http.StatusInternalServerError
+	case domain.ErrTokenEncryption.GetCode():
+		return http.StatusInternalServerError
+	case domain.ErrAccountLocked.GetCode():
+		return http.StatusLocked
+	case domain.ErrTooManyAttempts.GetCode():
+		return http.StatusTooManyRequests
+	case domain.ErrMFAAttemptsExceeded.GetCode():
+		return http.StatusTooManyRequests
+	case domain.ErrPasswordlessDisabled.GetCode():
+		return http.StatusNotFound
+	case domain.ErrWebAuthnVerificationFailed.GetCode():
+		return http.StatusUnauthorized
+	case domain.ErrWebAuthnCredentialNotFound.GetCode():
+		return http.StatusNotFound
+	case domain.ErrWebAuthnCredentialExists.GetCode():
+		return http.StatusConflict
+	case domain.ErrMFAFactorNotFound.GetCode():
+		return http.StatusNotFound
+	case domain.ErrMFAFactorExists.GetCode():
+		return http.StatusConflict
+	case domain.ErrIdentityProviderNotFound.GetCode():
+		return http.StatusNotFound
+	case domain.ErrFederatedLoginFailed.GetCode():
+		return http.StatusUnauthorized
+	case domain.ErrUpstreamEmailNotVerified.GetCode():
+		return http.StatusForbidden
+	case domain.ErrDirectoryUnavailable.GetCode():
+		return http.StatusServiceUnavailable
+	case domain.ErrConsentRequired.GetCode():
+		return http.StatusForbidden
+	case domain.ErrConsentNotFound.GetCode():
+		return http.StatusNotFound
 	}
 
 	return http.StatusBadRequest
@@ -45,6 +89,34 @@ func getStatus(err domain.Error) int {
 
 // RespondWithError sends a standardized error response
 func RespondWithError(w http.ResponseWriter, err domain.Error) {
+	// Password policy failures list every failing rule
+	if policyErr, ok := err.(*domain.PasswordPolicyError); ok {
+		details := make([]ErrorDetail, 0, len(policyErr.Violations))
+		for _, v := range policyErr.Violations {
+			details = append(details, ErrorDetail{
+				Field:   "password",
+				Rule:    v.Rule,
+				Message: v.Message,
+			})
+		}
+		RespondErrorWithDetails(w, err, details)
+		return
+	}
+
+	// Consent failures list the scopes awaiting the user's consent
+	if consentErr, ok := err.(*domain.ConsentRequiredError); ok {
+		details := make([]ErrorDetail, 0, len(consentErr.Scopes))
+		for _, scope := range consentErr.Scopes {
+			details = append(details, ErrorDetail{
+				Field:   "scope",
+				Rule:    "consent",
+				Message: scope,
+			})
+		}
+		RespondErrorWithDetails(w, err, details)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(getStatus(err))
 	json.NewEncoder(w).Encode(ErrorResponse{
@@ -63,3 +135,21 @@ func RespondErrorWithDetails(w http.ResponseWriter, err domain.Error, details []
 		Details: details,
 	})
 }
+
+// RespondWithAuthenticationChallenge sends an RFC 9470 insufficient_user_authentication challenge
+// telling the client the context class, when set, and the maximum age, when set, that the user's
+// sign-in must reach
+func RespondWithAuthenticationChallenge(w http.ResponseWriter, acr string, maxAge time.Duration, description string) {
+	params := []string{
+		`error="insufficient_user_authentication"`,
+		fmt.Sprintf("error_description=%q", description),
+	}
+	if acr != "" {
+		params = append(params, fmt.Sprintf("acr_values=%q", acr))
+	}
+	if maxAge > 0 {
+		params = append(params, fmt.Sprintf("max_age=%d", int(maxAge.Seconds())))
+	}
+	w.Header().Set("WWW-Authenticate", "Bearer "+strings.Join(params, ", "))
+	RespondWithError(w, domain.ErrInsufficientUserAuthentication)
+}
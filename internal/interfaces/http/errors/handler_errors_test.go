@@ -51,6 +51,34 @@ func TestRespondWithError(t *testing.T) {
 			},
 			expectedStatus: http.StatusUnauthorized,
 		},
+		{
+			name: "password policy error",
+			err: domain.NewPasswordPolicyError([]domain.PasswordViolation{
+				{Rule: domain.PasswordRuleMinLength, Message: "Password must be at least 8 characters long"},
+				{Rule: domain.PasswordRuleDigit, Message: "Password must contain a digit"},
+			}),
+			expectedBody: ErrorResponse{
+				Code:    "U0072",
+				Message: "Password does not meet the password policy",
+				Details: []ErrorDetail{
+					{Field: "password", Rule: "min_length", Message: "Password must be at least 8 characters long"},
+					{Field: "password", Rule: "digit", Message: "Password must contain a digit"},
+				},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "consent required error",
+			err:  domain.NewConsentRequiredError([]string{"payments"}),
+			expectedBody: ErrorResponse{
+				Code:    "U0098",
+				Message: "The user has not consented to the requested scopes",
+				Details: []ErrorDetail{
+					{Field: "scope", Rule: "consent", Message: "payments"},
+				},
+			},
+			expectedStatus: http.StatusForbidden,
+		},
 	}
 
 	for _, tt := range tests {
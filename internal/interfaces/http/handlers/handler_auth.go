@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"strings"
 
@@ -35,14 +37,71 @@ type RequestPasswordResetRequest struct {
 type ResetPasswordRequest struct {
 	Email       string `json:"email" validate:"required,email"`
 	Code        string `json:"code" validate:"required"`
-	NewPassword string `json:"new_password" validate:"required,min=8"`
+	NewPassword string `json:"new_password" validate:"required"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type PasswordlessRequest struct {
+	Email  string `json:"email" validate:"required,email"`
+	Method string `json:"method" validate:"omitempty,oneof=code link"`
+}
+
+// PasswordlessLoginRequest redeems a sign-in code, or the token of a magic link
+type PasswordlessLoginRequest struct {
+	Email       string `json:"email" validate:"required,email"`
+	Code        string `json:"code" validate:"required"`
+	DeviceToken string `json:"device_token"`
+}
+
+// MFARequest answers an MFA ticket with a code of the chosen factor; without a factor ID the code
+// is checked against the user's authenticator apps. TrustDevice asks to skip MFA on this device
+// from now on; the device is named after DeviceName, or the user agent without one.
 type MFARequest struct {
+	Ticket      string `json:"ticket" validate:"required"`
+	FactorID    string `json:"factor_id"`
+	Code        string `json:"code" validate:"required"`
+	TrustDevice bool   `json:"trust_device"`
+	DeviceName  string `json:"device_name" validate:"max=64"`
+}
+
+// deviceTokenCookie holds the device token of a trusted device for browsers
+const deviceTokenCookie = "authm_device_token"
+
+// MFAEnrollmentRequest starts enrolling an authenticator app with the ticket of a login the MFA
+// policy requires a factor for
+type MFAEnrollmentRequest struct {
+	Ticket string `json:"ticket" validate:"required"`
+	Label  string `json:"label" validate:"max=64"`
+}
+
+// MFAEnrollmentConfirmRequest confirms the authenticator app with a first code and completes the login
+type MFAEnrollmentConfirmRequest struct {
 	Ticket string `json:"ticket" validate:"required"`
 	Code   string `json:"code" validate:"required"`
 }
 
+// MFAChallengeRequest asks for the code of a factor that delivers one, such as email_otp. Tickets
+// of a login from a new device need no factor ID, their code is sent to the user's email. The
+// channel asks for the code of an sms_otp factor by text message, the default, or voice call.
+type MFAChallengeRequest struct {
+	Ticket   string `json:"ticket" validate:"required"`
+	FactorID string `json:"factor_id"`
+	Channel  string `json:"channel" validate:"omitempty,oneof=sms voice"`
+}
+
 func (h *HandlerAuth) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	var req domain.CreateUserRequest
 
@@ -86,7 +145,17 @@ func (h *HandlerAuth) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.authService.Login(r.Context(), req.Email, req.Password)
+	// The client and scopes a login is for bring their MFA policy into play
+	ctx := withClientIP(r)
+	if req.ClientID != "" {
+		ctx = domain.WithClientID(ctx, req.ClientID)
+	}
+	if scopes := strings.Fields(req.Scope); len(scopes) > 0 {
+		ctx = domain.WithScopes(ctx, scopes)
+	}
+	ctx = withDeviceToken(ctx, r, req.DeviceToken)
+
+	result, err := h.authService.Login(ctx, req.Email, req.Password)
 	if err != nil {
 		h.logger.Debug("failed to login user", zap.Error(err))
 		errors.RespondWithError(w, err.(domain.Error))
@@ -101,6 +170,27 @@ func (h *HandlerAuth) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// withClientIP returns the request context carrying the caller's IP for brute-force tracking, and
+// their user agent for the login history
+func withClientIP(r *http.Request) context.Context {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	return domain.WithUserAgent(domain.WithClientIP(r.Context(), ip), r.UserAgent())
+}
+
+// withDeviceToken adds the token of a trusted device to the context, taken from the request body
+// or else from the cookie set when the device was trusted
+func withDeviceToken(ctx context.Context, r *http.Request, token string) context.Context {
+	if token == "" {
+		if cookie, err := r.Cookie(deviceTokenCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	return domain.WithDeviceToken(ctx, token)
+}
+
 func createErrorMessage(w http.ResponseWriter, err error) {
 	var details []errors.ErrorDetail
 	for _, fe := range err.(validator.ValidationErrors) {
@@ -226,13 +316,158 @@ func (h *HandlerAuth) VerifyMFAHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokenPair, err := h.authService.VerifyMFA(r.Context(), req.Ticket, req.Code)
+	var trust *domain.DeviceTrust
+	if req.TrustDevice {
+		name := req.DeviceName
+		if name == "" {
+			name = r.UserAgent()
+		}
+		trust = &domain.DeviceTrust{Name: name}
+	}
+
+	tokenPair, err := h.authService.VerifyMFA(withClientIP(r), req.Ticket, req.FactorID, req.Code, trust)
 	if err != nil {
 		h.logger.Debug("failed to verify MFA", zap.Error(err))
 		errors.RespondWithError(w, err.(domain.Error))
 		return
 	}
 
+	// Browsers keep the device token in a cookie only the login endpoints see
+	if tokenPair.DeviceToken != "" && tokenPair.DeviceExpiresAt != nil {
+		http.SetCookie(w, &http.Cookie{
+			Name:     deviceTokenCookie,
+			Value:    tokenPair.DeviceToken,
+			Path:     "/api/auth",
+			Expires:  *tokenPair.DeviceExpiresAt,
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokenPair); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
+
+// ChallengeMFAHandler sends the code of the chosen factor of an MFA ticket
+func (h *HandlerAuth) ChallengeMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req MFAChallengeRequest
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	if err := h.authService.ChallengeMFA(r.Context(), req.Ticket, req.FactorID, domain.SMSChannel(req.Channel)); err != nil {
+		h.logger.Debug("failed to send MFA challenge", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// BeginMFAEnrollmentHandler starts enrolling an authenticator app with an enrolment ticket
+func (h *HandlerAuth) BeginMFAEnrollmentHandler(w http.ResponseWriter, r *http.Request) {
+	var req MFAEnrollmentRequest
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	totp, err := h.authService.BeginMFAEnrollment(r.Context(), req.Ticket, req.Label)
+	if err != nil {
+		h.logger.Debug("failed to begin MFA enrolment", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(totp); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
+
+// CompleteMFAEnrollmentHandler confirms the authenticator app of an enrolment ticket and returns
+// the tokens along with the user's backup codes
+func (h *HandlerAuth) CompleteMFAEnrollmentHandler(w http.ResponseWriter, r *http.Request) {
+	var req MFAEnrollmentConfirmRequest
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	enrollment, err := h.authService.CompleteMFAEnrollment(withClientIP(r), req.Ticket, req.Code)
+	if err != nil {
+		h.logger.Debug("failed to complete MFA enrolment", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(enrollment); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
+
+func (h *HandlerAuth) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req ChangePasswordRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	tokenPair, err := h.authService.ChangePassword(withClientIP(r), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		h.logger.Error("failed to change password", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(tokenPair); err != nil {
 		h.logger.Error("failed to encode response", zap.Error(err))
@@ -240,3 +475,121 @@ func (h *HandlerAuth) VerifyMFAHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+func (h *HandlerAuth) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req ChangeEmailRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	if err := h.authService.RequestEmailChange(withClientIP(r), userID, req.NewEmail, req.Password); err != nil {
+		h.logger.Error("failed to request email change", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *HandlerAuth) ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req ConfirmEmailChangeRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	if err := h.authService.ConfirmEmailChange(r.Context(), userID, req.Code); err != nil {
+		h.logger.Error("failed to confirm email change", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *HandlerAuth) RequestPasswordlessLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req PasswordlessRequest
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	method := domain.PasswordlessMethod(req.Method)
+	if method == "" {
+		method = domain.PasswordlessCode
+	}
+
+	if err := h.authService.RequestPasswordlessLogin(r.Context(), req.Email, method); err != nil {
+		h.logger.Error("failed to request passwordless login", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *HandlerAuth) PasswordlessLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req PasswordlessLoginRequest
+
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	ctx := withDeviceToken(withClientIP(r), r, req.DeviceToken)
+	result, err := h.authService.PasswordlessLogin(ctx, req.Email, req.Code)
+	if err != nil {
+		h.logger.Debug("failed to redeem passwordless login", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
@@ -6,13 +6,16 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/manorfm/authM/internal/domain"
 	"github.com/manorfm/authM/internal/interfaces/http/errors"
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -36,14 +39,19 @@ func (m *mockAuthService) Login(ctx context.Context, email, password string) (in
 	return args.Get(0), args.Error(1)
 }
 
-func (m *mockAuthService) VerifyMFA(ctx context.Context, ticketID, code string) (*domain.TokenPair, error) {
-	args := m.Called(ctx, ticketID, code)
+func (m *mockAuthService) VerifyMFA(ctx context.Context, ticketID, factorID, code string, trust *domain.DeviceTrust) (*domain.TokenPair, error) {
+	args := m.Called(ctx, ticketID, factorID, code, trust)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.TokenPair), args.Error(1)
 }
 
+func (m *mockAuthService) ChallengeMFA(ctx context.Context, ticketID, factorID string, channel domain.SMSChannel) error {
+	args := m.Called(ctx, ticketID, factorID, channel)
+	return args.Error(0)
+}
+
 func (m *mockAuthService) VerifyEmail(ctx context.Context, email, code string) error {
 	args := m.Called(ctx, email, code)
 	return args.Error(0)
@@ -59,6 +67,69 @@ func (m *mockAuthService) ResetPassword(ctx context.Context, email, code, newPas
 	return args.Error(0)
 }
 
+func (m *mockAuthService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (*domain.TokenPair, error) {
+	args := m.Called(ctx, userID, currentPassword, newPassword)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func (m *mockAuthService) RequestEmailChange(ctx context.Context, userID, newEmail, password string) error {
+	args := m.Called(ctx, userID, newEmail, password)
+	return args.Error(0)
+}
+
+func (m *mockAuthService) ConfirmEmailChange(ctx context.Context, userID, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+
+func (m *mockAuthService) RequestPasswordlessLogin(ctx context.Context, email string, method domain.PasswordlessMethod) error {
+	args := m.Called(ctx, email, method)
+	return args.Error(0)
+}
+
+func (m *mockAuthService) PasswordlessLogin(ctx context.Context, email, code string) (interface{}, error) {
+	args := m.Called(ctx, email, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0), args.Error(1)
+}
+
+func (m *mockAuthService) CompleteLogin(ctx context.Context, user *domain.User, auth *domain.Authentication) (interface{}, error) {
+	args := m.Called(ctx, user, auth)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0), args.Error(1)
+}
+
+func (m *mockAuthService) CompleteMFA(ctx context.Context, ticketID string, verify func(user *domain.User) (domain.MFAFactorType, error)) (*domain.TokenPair, error) {
+	args := m.Called(ctx, ticketID, verify)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func (m *mockAuthService) BeginMFAEnrollment(ctx context.Context, ticketID, label string) (*domain.TOTP, error) {
+	args := m.Called(ctx, ticketID, label)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTP), args.Error(1)
+}
+
+func (m *mockAuthService) CompleteMFAEnrollment(ctx context.Context, ticketID, code string) (*domain.MFAEnrollment, error) {
+	args := m.Called(ctx, ticketID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAEnrollment), args.Error(1)
+}
+
 func TestAuthHandler_Register(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -244,6 +315,27 @@ func TestAuthHandler_Login(t *testing.T) {
 				Message: "Invalid credentials",
 			},
 		},
+		{
+			name: "account locked",
+			requestBody: map[string]string{
+				"email":    "locked@example.com",
+				"password": "password123",
+			},
+			mockSetup: func() {
+				// The caller's IP is passed on for brute-force tracking
+				withIP := mock.MatchedBy(func(ctx context.Context) bool {
+					ip, _ := domain.GetClientIP(ctx)
+					return ip == "192.0.2.1"
+				})
+				mockService.On("Login", withIP, "locked@example.com", "password123").
+					Return(nil, domain.ErrAccountLocked)
+			},
+			expectedStatus: http.StatusLocked,
+			expectedBody: errors.ErrorResponse{
+				Code:    "U0069",
+				Message: "Account temporarily locked",
+			},
+		},
 		{
 			name: "validation error - missing required fields",
 			requestBody: map[string]string{
@@ -314,3 +406,424 @@ func TestAuthHandler_Login(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthHandler_Login_ForClient(t *testing.T) {
+	mockService := new(mockAuthService)
+	mockService.On("Login", mock.MatchedBy(func(ctx context.Context) bool {
+		clientID, _ := domain.GetClientID(ctx)
+		scopes, _ := domain.GetScopes(ctx)
+		return clientID == "payments-app" && assert.ObjectsAreEqual([]string{"openid", "payments:write"}, scopes)
+	}), "test@example.com", "password123").
+		Return(&domain.MFATicket{Ticket: ulid.Make(), EnrollmentRequired: true}, nil)
+	handler := NewAuthHandler(mockService, zap.NewNop())
+
+	body, _ := json.Marshal(map[string]string{
+		"email":     "test@example.com",
+		"password":  "password123",
+		"client_id": "payments-app",
+		"scope":     "openid payments:write",
+	})
+	req := httptest.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	handler.LoginHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"enrollment_required":true`)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthHandler_ChangePassword(t *testing.T) {
+	userID := ulid.Make().String()
+
+	tests := []struct {
+		name           string
+		subject        string
+		requestBody    map[string]string
+		mockSetup      func(*mockAuthService)
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:        "password changed",
+			subject:     userID,
+			requestBody: map[string]string{"current_password": "Old-Secret-42", "new_password": "New-Secret-42"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("ChangePassword", mock.Anything, userID, "Old-Secret-42", "New-Secret-42").
+					Return(&domain.TokenPair{AccessToken: "access_token", RefreshToken: "refresh_token"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "wrong current password",
+			subject:     userID,
+			requestBody: map[string]string{"current_password": "wrong", "new_password": "New-Secret-42"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("ChangePassword", mock.Anything, userID, "wrong", "New-Secret-42").
+					Return(nil, domain.ErrInvalidCredentials)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "U0001",
+		},
+		{
+			name:           "missing current password",
+			subject:        userID,
+			requestBody:    map[string]string{"new_password": "New-Secret-42"},
+			mockSetup:      func(m *mockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "U0011",
+		},
+		{
+			name:           "not authenticated",
+			requestBody:    map[string]string{"current_password": "Old-Secret-42", "new_password": "New-Secret-42"},
+			mockSetup:      func(m *mockAuthService) {},
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   domain.ErrUnauthorized.GetCode(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockAuthService)
+			tt.mockSetup(mockService)
+			handler := NewAuthHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/users/me/password", bytes.NewBuffer(body))
+			if tt.subject != "" {
+				req = req.WithContext(domain.WithSubject(req.Context(), tt.subject))
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ChangePasswordHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				var response errors.ErrorResponse
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, tt.expectedCode, response.Code)
+			} else {
+				var response domain.TokenPair
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, "access_token", response.AccessToken)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_ChangeEmail(t *testing.T) {
+	userID := ulid.Make().String()
+
+	mockService := new(mockAuthService)
+	mockService.On("RequestEmailChange", mock.Anything, userID, "new@example.com", "Old-Secret-42").Return(nil)
+	mockService.On("ConfirmEmailChange", mock.Anything, userID, "CODE123").Return(nil)
+	handler := NewAuthHandler(mockService, zap.NewNop())
+
+	body, _ := json.Marshal(map[string]string{"new_email": "new@example.com", "password": "Old-Secret-42"})
+	req := httptest.NewRequest("POST", "/users/me/email", bytes.NewBuffer(body))
+	req = req.WithContext(domain.WithSubject(req.Context(), userID))
+	rr := httptest.NewRecorder()
+	handler.ChangeEmailHandler(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+
+	body, _ = json.Marshal(map[string]string{"code": "CODE123"})
+	req = httptest.NewRequest("POST", "/users/me/email/confirm", bytes.NewBuffer(body))
+	req = req.WithContext(domain.WithSubject(req.Context(), userID))
+	rr = httptest.NewRecorder()
+	handler.ConfirmEmailChangeHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthHandler_RequestPasswordlessLogin(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    map[string]string
+		mockSetup      func(*mockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:        "defaults to a code",
+			requestBody: map[string]string{"email": "test@example.com"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("RequestPasswordlessLogin", mock.Anything, "test@example.com", domain.PasswordlessCode).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:        "magic link",
+			requestBody: map[string]string{"email": "test@example.com", "method": "link"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("RequestPasswordlessLogin", mock.Anything, "test@example.com", domain.PasswordlessLink).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "unknown method",
+			requestBody:    map[string]string{"email": "test@example.com", "method": "carrier-pigeon"},
+			mockSetup:      func(m *mockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "disabled",
+			requestBody: map[string]string{"email": "test@example.com"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("RequestPasswordlessLogin", mock.Anything, "test@example.com", domain.PasswordlessCode).Return(domain.ErrPasswordlessDisabled)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:        "rate limited",
+			requestBody: map[string]string{"email": "test@example.com"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("RequestPasswordlessLogin", mock.Anything, "test@example.com", domain.PasswordlessCode).Return(domain.ErrTooManyAttempts)
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockAuthService)
+			tt.mockSetup(mockService)
+			handler := NewAuthHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/passwordless", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+			handler.RequestPasswordlessLoginHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_PasswordlessLogin(t *testing.T) {
+	mockService := new(mockAuthService)
+	mockService.On("PasswordlessLogin", mock.Anything, "test@example.com", "123456").
+		Return(&domain.TokenPair{AccessToken: "access_token", RefreshToken: "refresh_token"}, nil)
+	handler := NewAuthHandler(mockService, zap.NewNop())
+
+	body, _ := json.Marshal(map[string]string{"email": "test@example.com", "code": "123456"})
+	req := httptest.NewRequest("POST", "/auth/passwordless/verify", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	handler.PasswordlessLoginHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var response domain.TokenPair
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	assert.Equal(t, "access_token", response.AccessToken)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthHandler_VerifyMFA(t *testing.T) {
+	mockService := new(mockAuthService)
+	mockService.On("VerifyMFA", mock.Anything, "ticket", "factor", "123456", (*domain.DeviceTrust)(nil)).
+		Return(&domain.TokenPair{AccessToken: "access_token", RefreshToken: "refresh_token"}, nil)
+	handler := NewAuthHandler(mockService, zap.NewNop())
+
+	body, _ := json.Marshal(map[string]string{"ticket": "ticket", "factor_id": "factor", "code": "123456"})
+	req := httptest.NewRequest("POST", "/auth/verify-mfa", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	handler.VerifyMFAHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthHandler_VerifyMFA_TrustDevice(t *testing.T) {
+	expiresAt := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	mockService := new(mockAuthService)
+	mockService.On("VerifyMFA", mock.Anything, "ticket", "", "123456", &domain.DeviceTrust{Name: "Firefox on Linux"}).
+		Return(&domain.TokenPair{AccessToken: "access_token", DeviceToken: "device.secret", DeviceExpiresAt: &expiresAt}, nil)
+	handler := NewAuthHandler(mockService, zap.NewNop())
+
+	body, _ := json.Marshal(map[string]interface{}{"ticket": "ticket", "code": "123456", "trust_device": true})
+	req := httptest.NewRequest("POST", "/auth/verify-mfa", bytes.NewBuffer(body))
+	req.Header.Set("User-Agent", "Firefox on Linux")
+	rr := httptest.NewRecorder()
+	handler.VerifyMFAHandler(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"device_token":"device.secret"`)
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, deviceTokenCookie, cookies[0].Name)
+	assert.Equal(t, "device.secret", cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, expiresAt.Equal(cookies[0].Expires))
+	mockService.AssertExpectations(t)
+}
+
+func TestAuthHandler_Login_DeviceToken(t *testing.T) {
+	withToken := func(token string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool {
+			got, _ := domain.GetDeviceToken(ctx)
+			return got == token
+		})
+	}
+
+	t.Run("from the body", func(t *testing.T) {
+		mockService := new(mockAuthService)
+		mockService.On("Login", withToken("body.token"), "test@example.com", "password123").
+			Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+		handler := NewAuthHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "password123", "device_token": "body.token"})
+		req := httptest.NewRequest("POST", "/auth/login", bytes.NewBuffer(body))
+		req.AddCookie(&http.Cookie{Name: deviceTokenCookie, Value: "cookie.token"})
+		rr := httptest.NewRecorder()
+		handler.LoginHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("from the cookie", func(t *testing.T) {
+		mockService := new(mockAuthService)
+		mockService.On("PasswordlessLogin", withToken("cookie.token"), "test@example.com", "123456").
+			Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+		handler := NewAuthHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"email": "test@example.com", "code": "123456"})
+		req := httptest.NewRequest("POST", "/auth/passwordless/verify", bytes.NewBuffer(body))
+		req.AddCookie(&http.Cookie{Name: deviceTokenCookie, Value: "cookie.token"})
+		rr := httptest.NewRecorder()
+		handler.PasswordlessLoginHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestAuthHandler_ChallengeMFA(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    map[string]string
+		mockSetup      func(*mockAuthService)
+		expectedStatus int
+	}{
+		{
+			name:        "code sent",
+			requestBody: map[string]string{"ticket": "ticket", "factor_id": "factor"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("ChallengeMFA", mock.Anything, "ticket", "factor", domain.SMSChannel("")).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:        "ticket of a login from a new device",
+			requestBody: map[string]string{"ticket": "ticket"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("ChallengeMFA", mock.Anything, "ticket", "", domain.SMSChannel("")).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "missing ticket",
+			requestBody:    map[string]string{"factor_id": "factor"},
+			mockSetup:      func(m *mockAuthService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "unknown factor",
+			requestBody: map[string]string{"ticket": "ticket", "factor_id": "factor"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("ChallengeMFA", mock.Anything, "ticket", "factor", domain.SMSChannel("")).Return(domain.ErrMFAFactorNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:        "rate limited",
+			requestBody: map[string]string{"ticket": "ticket", "factor_id": "factor"},
+			mockSetup: func(m *mockAuthService) {
+				m.On("ChallengeMFA", mock.Anything, "ticket", "factor", domain.SMSChannel("")).Return(domain.ErrTooManyAttempts)
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockAuthService)
+			tt.mockSetup(mockService)
+			handler := NewAuthHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/verify-mfa/challenge", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+			handler.ChallengeMFAHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_MFAEnrollment(t *testing.T) {
+	t.Run("begin returns the QR code", func(t *testing.T) {
+		mockService := new(mockAuthService)
+		mockService.On("BeginMFAEnrollment", mock.Anything, "ticket", "Phone").
+			Return(&domain.TOTP{OTPAuthURI: "otpauth://totp/AuthM:test@example.com"}, nil)
+		handler := NewAuthHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"ticket": "ticket", "label": "Phone"})
+		req := httptest.NewRequest("POST", "/auth/enroll-mfa/totp", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.BeginMFAEnrollmentHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "otpauth://totp/AuthM:test@example.com")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("begin with a long label", func(t *testing.T) {
+		mockService := new(mockAuthService)
+		handler := NewAuthHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"ticket": "ticket", "label": strings.Repeat("a", 65)})
+		req := httptest.NewRequest("POST", "/auth/enroll-mfa/totp", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.BeginMFAEnrollmentHandler(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertNotCalled(t, "BeginMFAEnrollment", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("confirm returns the tokens and backup codes", func(t *testing.T) {
+		mockService := new(mockAuthService)
+		mockService.On("CompleteMFAEnrollment", mock.Anything, "ticket", "123456").
+			Return(&domain.MFAEnrollment{
+				TokenPair:   domain.TokenPair{AccessToken: "access_token", RefreshToken: "refresh_token"},
+				FactorID:    "factor",
+				BackupCodes: []string{"code1"},
+			}, nil)
+		handler := NewAuthHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"ticket": "ticket", "code": "123456"})
+		req := httptest.NewRequest("POST", "/auth/enroll-mfa/totp/confirm", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.CompleteMFAEnrollmentHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"access_token":"access_token","refresh_token":"refresh_token","factor_id":"factor","backup_codes":["code1"]}`, rr.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("confirm with an MFA ticket", func(t *testing.T) {
+		mockService := new(mockAuthService)
+		mockService.On("CompleteMFAEnrollment", mock.Anything, "ticket", "123456").Return(nil, domain.ErrInvalidMFATicket)
+		handler := NewAuthHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"ticket": "ticket", "code": "123456"})
+		req := httptest.NewRequest("POST", "/auth/enroll-mfa/totp/confirm", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		handler.CompleteMFAEnrollmentHandler(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// BackchannelAuthenticationRequest represents a CIBA backchannel authentication request
+type BackchannelAuthenticationRequest struct {
+	ClientID                string `json:"client_id" validate:"required"`
+	ClientSecret            string `json:"client_secret" validate:"required"`
+	LoginHint               string `json:"login_hint" validate:"required"`
+	BindingMessage          string `json:"binding_message" validate:"max=64"`
+	Scope                   string `json:"scope" validate:"required"`
+	ClientNotificationToken string `json:"client_notification_token" validate:"max=1024"`
+}
+
+// CIBAHandler handles Client-Initiated Backchannel Authentication
+type CIBAHandler struct {
+	cibaService domain.CIBAService
+	logger      *zap.Logger
+}
+
+// NewCIBAHandler creates a new CIBAHandler
+func NewCIBAHandler(cibaService domain.CIBAService, logger *zap.Logger) *CIBAHandler {
+	return &CIBAHandler{
+		cibaService: cibaService,
+		logger:      logger,
+	}
+}
+
+// BackchannelAuthenticationHandler starts a backchannel authentication request for the user in login_hint
+func (h *CIBAHandler) BackchannelAuthenticationHandler(w http.ResponseWriter, r *http.Request) {
+	var req BackchannelAuthenticationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	// Validate request
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	response, err := h.cibaService.Authenticate(r.Context(), req.ClientID, req.ClientSecret, req.LoginHint, req.BindingMessage, req.Scope, req.ClientNotificationToken)
+	if err != nil {
+		h.logger.Error("Backchannel authentication failed", zap.String("client_id", req.ClientID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListPendingHandler lists the backchannel authentication requests awaiting the authenticated user's answer
+func (h *CIBAHandler) ListPendingHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	requests, err := h.cibaService.ListPending(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to list backchannel authentication requests", zap.String("user_id", userID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// ApproveHandler approves a backchannel authentication request
+func (h *CIBAHandler) ApproveHandler(w http.ResponseWriter, r *http.Request) {
+	h.answer(w, r, h.cibaService.Approve)
+}
+
+// DenyHandler denies a backchannel authentication request
+func (h *CIBAHandler) DenyHandler(w http.ResponseWriter, r *http.Request) {
+	h.answer(w, r, h.cibaService.Deny)
+}
+
+func (h *CIBAHandler) answer(w http.ResponseWriter, r *http.Request, answer func(ctx context.Context, userID, authReqID string) error) {
+	authReqID := chi.URLParam(r, "auth_req_id")
+	if authReqID == "" {
+		h.logger.Error("Missing auth_req_id in URL")
+		errors.RespondWithError(w, domain.ErrPathNotFound)
+		return
+	}
+
+	userID, ok := h.sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := answer(r.Context(), userID, authReqID); err != nil {
+		h.logger.Error("Failed to answer backchannel authentication request", zap.String("auth_req_id", authReqID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionUser returns the authenticated user, answering the request itself when there is none. Only
+// the user's own sessions see and answer their requests: a token issued to a client could otherwise
+// approve requests of other clients on the user's behalf.
+func (h *CIBAHandler) sessionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		h.logger.Error("User not authenticated")
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return "", false
+	}
+	if clientID, ok := domain.GetClientID(r.Context()); ok && clientID != "" {
+		h.logger.Warn("Client token used to answer backchannel authentication requests", zap.String("client_id", clientID))
+		errors.RespondWithError(w, domain.ErrForbidden)
+		return "", false
+	}
+	return userID, true
+}
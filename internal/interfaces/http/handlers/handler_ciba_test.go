@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// MockCIBAService is a mock implementation of domain.CIBAService
+type MockCIBAService struct {
+	mock.Mock
+}
+
+func (m *MockCIBAService) Authenticate(ctx context.Context, clientID, clientSecret, loginHint, bindingMessage, scope, clientNotificationToken string) (*domain.BackchannelAuthResponse, error) {
+	args := m.Called(ctx, clientID, clientSecret, loginHint, bindingMessage, scope, clientNotificationToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BackchannelAuthResponse), args.Error(1)
+}
+
+func (m *MockCIBAService) ListPending(ctx context.Context, userID string) ([]*domain.BackchannelAuthRequest, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.BackchannelAuthRequest), args.Error(1)
+}
+
+func (m *MockCIBAService) Approve(ctx context.Context, userID, authReqID string) error {
+	args := m.Called(ctx, userID, authReqID)
+	return args.Error(0)
+}
+
+func (m *MockCIBAService) Deny(ctx context.Context, userID, authReqID string) error {
+	args := m.Called(ctx, userID, authReqID)
+	return args.Error(0)
+}
+
+func (m *MockCIBAService) ExchangeAuthReqID(ctx context.Context, clientID, clientSecret, authReqID string) (*domain.TokenPair, error) {
+	args := m.Called(ctx, clientID, clientSecret, authReqID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func withAuthReqID(r *http.Request, authReqID string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("auth_req_id", authReqID)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestBackchannelAuthenticationHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    BackchannelAuthenticationRequest
+		mockSetup      func(*MockCIBAService)
+		expectedStatus int
+	}{
+		{
+			name: "Success",
+			requestBody: BackchannelAuthenticationRequest{
+				ClientID:       "call-centre",
+				ClientSecret:   "secret",
+				LoginHint:      "customer@example.com",
+				BindingMessage: "Call 4821",
+				Scope:          "openid",
+			},
+			mockSetup: func(m *MockCIBAService) {
+				m.On("Authenticate", mock.Anything, "call-centre", "secret", "customer@example.com", "Call 4821", "openid", "").
+					Return(&domain.BackchannelAuthResponse{AuthReqID: "auth-req-1", ExpiresIn: 300, Interval: 5}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Missing Login Hint",
+			requestBody: BackchannelAuthenticationRequest{
+				ClientID:     "call-centre",
+				ClientSecret: "secret",
+				Scope:        "openid",
+			},
+			mockSetup:      func(m *MockCIBAService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Binding Message Too Long",
+			requestBody: BackchannelAuthenticationRequest{
+				ClientID:       "call-centre",
+				ClientSecret:   "secret",
+				LoginHint:      "customer@example.com",
+				BindingMessage: strings.Repeat("x", 65),
+				Scope:          "openid",
+			},
+			mockSetup:      func(m *MockCIBAService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Unknown User",
+			requestBody: BackchannelAuthenticationRequest{
+				ClientID:     "call-centre",
+				ClientSecret: "secret",
+				LoginHint:    "nobody@example.com",
+				Scope:        "openid",
+			},
+			mockSetup: func(m *MockCIBAService) {
+				m.On("Authenticate", mock.Anything, "call-centre", "secret", "nobody@example.com", "", "openid", "").
+					Return(nil, domain.ErrUnknownUserID)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCIBAService)
+			tt.mockSetup(mockService)
+			handler := NewCIBAHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPost, "/api/oauth2/bc-authorize", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.BackchannelAuthenticationHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response domain.BackchannelAuthResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, "auth-req-1", response.AuthReqID)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestListPendingBackchannelHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		clientID       string
+		mockSetup      func(*MockCIBAService)
+		expectedStatus int
+	}{
+		{
+			name: "Success",
+			mockSetup: func(m *MockCIBAService) {
+				m.On("ListPending", mock.Anything, "user123").Return([]*domain.BackchannelAuthRequest{{ClientID: "call-centre"}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Token Issued To A Client",
+			clientID:       "shop",
+			mockSetup:      func(m *MockCIBAService) {},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCIBAService)
+			tt.mockSetup(mockService)
+			handler := NewCIBAHandler(mockService, zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/oauth2/bc-authorize/pending", nil)
+			ctx := domain.WithSubject(req.Context(), "user123")
+			if tt.clientID != "" {
+				ctx = domain.WithClientID(ctx, tt.clientID)
+			}
+			req = req.WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			handler.ListPendingHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestApproveBackchannelHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		userID         string
+		clientID       string
+		mockSetup      func(*MockCIBAService)
+		expectedStatus int
+	}{
+		{
+			name:   "Success",
+			userID: "user123",
+			mockSetup: func(m *MockCIBAService) {
+				m.On("Approve", mock.Anything, "user123", "auth-req-1").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "Not Authenticated",
+			mockSetup:      func(m *MockCIBAService) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "Request Of Another User",
+			userID: "user456",
+			mockSetup: func(m *MockCIBAService) {
+				m.On("Approve", mock.Anything, "user456", "auth-req-1").Return(domain.ErrInvalidAuthReqID)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Token Issued To A Client",
+			userID:         "user123",
+			clientID:       "shop",
+			mockSetup:      func(m *MockCIBAService) {},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCIBAService)
+			tt.mockSetup(mockService)
+			handler := NewCIBAHandler(mockService, zap.NewNop())
+
+			req := withAuthReqID(httptest.NewRequest(http.MethodPost, "/api/oauth2/bc-authorize/auth-req-1/approve", nil), "auth-req-1")
+			if tt.userID != "" {
+				req = req.WithContext(domain.WithSubject(req.Context(), tt.userID))
+			}
+			if tt.clientID != "" {
+				req = req.WithContext(domain.WithClientID(req.Context(), tt.clientID))
+			}
+			w := httptest.NewRecorder()
+
+			handler.ApproveHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTokenHandler_CIBAGrant(t *testing.T) {
+	tests := []struct {
+		name           string
+		authReqID      string
+		mockSetup      func(*MockCIBAService)
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:      "Approved",
+			authReqID: "auth-req-1",
+			mockSetup: func(m *MockCIBAService) {
+				m.On("ExchangeAuthReqID", mock.Anything, "call-centre", "secret", "auth-req-1").
+					Return(&domain.TokenPair{AccessToken: "access_token", RefreshToken: "refresh_token"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:      "Pending",
+			authReqID: "auth-req-1",
+			mockSetup: func(m *MockCIBAService) {
+				m.On("ExchangeAuthReqID", mock.Anything, "call-centre", "secret", "auth-req-1").
+					Return(nil, domain.ErrAuthorizationPending)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   domain.ErrAuthorizationPending.GetCode(),
+		},
+		{
+			name:           "Missing auth_req_id",
+			mockSetup:      func(m *MockCIBAService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   domain.ErrInvalidField.GetCode(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockCIBAService)
+			tt.mockSetup(mockService)
+			handler := NewOIDCHandler(new(mockOIDCService), mockService, nil, zap.NewNop())
+
+			body, _ := json.Marshal(TokenRequest{
+				GrantType:    domain.GrantTypeCIBA,
+				ClientID:     "call-centre",
+				ClientSecret: "secret",
+				AuthReqID:    tt.authReqID,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/oauth2/token", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.TokenHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode != "" {
+				var response errors.ErrorResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedCode, response.Code)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
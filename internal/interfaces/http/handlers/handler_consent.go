@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// GrantConsentRequest represents the scopes a user consents to grant a client
+type GrantConsentRequest struct {
+	ClientID string   `json:"client_id" validate:"required"`
+	Scopes   []string `json:"scopes" validate:"required,min=1"`
+}
+
+// ConsentHandler handles the consents users give clients
+type ConsentHandler struct {
+	consentService domain.ConsentService
+	logger         *zap.Logger
+}
+
+// NewConsentHandler creates a new ConsentHandler
+func NewConsentHandler(consentService domain.ConsentService, logger *zap.Logger) *ConsentHandler {
+	return &ConsentHandler{
+		consentService: consentService,
+		logger:         logger,
+	}
+}
+
+// ListConsentsHandler lists the clients the signed-in user consented to and the scopes of each
+func (h *ConsentHandler) ListConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	consents, err := h.consentService.ListConsents(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to list consents", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(consents); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// GrantConsentHandler records the signed-in user's consent to scopes for a client. Only the user's
+// own sessions may consent: a token issued to a client cannot widen its own grant.
+func (h *ConsentHandler) GrantConsentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+	if clientID, ok := domain.GetClientID(r.Context()); ok && clientID != "" {
+		h.logger.Warn("Client token used to grant consent", zap.String("client_id", clientID))
+		errors.RespondWithError(w, domain.ErrForbidden)
+		return
+	}
+
+	var req GrantConsentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	consent, err := h.consentService.GrantConsent(r.Context(), userID, req.ClientID, req.Scopes)
+	if err != nil {
+		h.logger.Debug("failed to grant consent", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(consent); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// RevokeConsentHandler withdraws the signed-in user's consent for a client
+func (h *ConsentHandler) RevokeConsentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	if err := h.consentService.RevokeConsent(r.Context(), userID, chi.URLParam(r, "client_id")); err != nil {
+		h.logger.Debug("failed to revoke consent", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
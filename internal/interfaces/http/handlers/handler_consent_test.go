@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockConsentService struct {
+	mock.Mock
+}
+
+func (m *mockConsentService) CheckConsent(ctx context.Context, userID, clientID string, scopes []string) error {
+	args := m.Called(ctx, userID, clientID, scopes)
+	return args.Error(0)
+}
+
+func (m *mockConsentService) GrantConsent(ctx context.Context, userID, clientID string, scopes []string) (*domain.Consent, error) {
+	args := m.Called(ctx, userID, clientID, scopes)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Consent), args.Error(1)
+}
+
+func (m *mockConsentService) ListConsents(ctx context.Context, userID string) ([]*domain.Consent, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Consent), args.Error(1)
+}
+
+func (m *mockConsentService) RevokeConsent(ctx context.Context, userID, clientID string) error {
+	args := m.Called(ctx, userID, clientID)
+	return args.Error(0)
+}
+
+func TestConsentHandler_GrantConsentHandler(t *testing.T) {
+	userID := ulid.Make().String()
+	body := `{"client_id":"shop","scopes":["payments"]}`
+
+	t.Run("grants the consent", func(t *testing.T) {
+		service := new(mockConsentService)
+		service.On("GrantConsent", mock.Anything, userID, "shop", []string{"payments"}).
+			Return(&domain.Consent{ClientID: "shop", Scopes: []string{"payments"}}, nil)
+		handler := NewConsentHandler(service, zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodPost, "/users/me/consents", strings.NewReader(body))
+		req = req.WithContext(domain.WithSubject(req.Context(), userID))
+		rr := httptest.NewRecorder()
+		handler.GrantConsentHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var consent map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&consent))
+		assert.Equal(t, "shop", consent["client_id"])
+	})
+
+	t.Run("token issued to a client", func(t *testing.T) {
+		service := new(mockConsentService)
+		handler := NewConsentHandler(service, zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodPost, "/users/me/consents", strings.NewReader(body))
+		ctx := domain.WithSubject(req.Context(), userID)
+		req = req.WithContext(domain.WithClientID(ctx, "shop"))
+		rr := httptest.NewRecorder()
+		handler.GrantConsentHandler(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		service.AssertNotCalled(t, "GrantConsent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing scopes", func(t *testing.T) {
+		handler := NewConsentHandler(new(mockConsentService), zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodPost, "/users/me/consents", strings.NewReader(`{"client_id":"shop"}`))
+		req = req.WithContext(domain.WithSubject(req.Context(), userID))
+		rr := httptest.NewRecorder()
+		handler.GrantConsentHandler(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestConsentHandler_RevokeConsentHandler(t *testing.T) {
+	userID := ulid.Make().String()
+	revoke := func(clientID string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("client_id", clientID)
+		req := httptest.NewRequest(http.MethodDelete, "/users/me/consents/"+clientID, nil)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		return req.WithContext(domain.WithSubject(ctx, userID))
+	}
+
+	t.Run("revokes the consent", func(t *testing.T) {
+		service := new(mockConsentService)
+		service.On("RevokeConsent", mock.Anything, userID, "shop").Return(nil)
+		handler := NewConsentHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.RevokeConsentHandler(rr, revoke("shop"))
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+	})
+
+	t.Run("no consent", func(t *testing.T) {
+		service := new(mockConsentService)
+		service.On("RevokeConsent", mock.Anything, userID, "other").Return(domain.ErrConsentNotFound)
+		handler := NewConsentHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.RevokeConsentHandler(rr, revoke("other"))
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// federatedStateCookie binds a sign-in at an upstream provider to the browser that started it
+const federatedStateCookie = "authm_federated_state"
+
+// FederationHandler handles signing in with upstream identity providers
+type FederationHandler struct {
+	federationService domain.FederationService
+	logger            *zap.Logger
+}
+
+// NewFederationHandler creates a new FederationHandler
+func NewFederationHandler(federationService domain.FederationService, logger *zap.Logger) *FederationHandler {
+	return &FederationHandler{
+		federationService: federationService,
+		logger:            logger,
+	}
+}
+
+// ListProvidersHandler lists the names of the providers users can sign in with
+func (h *FederationHandler) ListProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]string{"providers": h.federationService.Providers()}); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// BeginLoginHandler redirects the user to the provider to sign in
+func (h *FederationHandler) BeginLoginHandler(w http.ResponseWriter, r *http.Request) {
+	state, redirectURL, err := h.federationService.BeginLogin(r.Context(), chi.URLParam(r, "provider"))
+	if err != nil {
+		h.logger.Debug("failed to begin federated login", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	// The provider sends the user back with a top-level navigation, which Lax cookies survive
+	http.SetCookie(w, &http.Cookie{
+		Name:     federatedStateCookie,
+		Value:    state,
+		Path:     "/api/auth/federated",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// CallbackHandler handles the user coming back from the provider and signs them in
+func (h *FederationHandler) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if upstreamErr := query.Get("error"); upstreamErr != "" {
+		h.logger.Debug("identity provider returned an error",
+			zap.String("error", upstreamErr),
+			zap.String("error_description", query.Get("error_description")))
+		errors.RespondWithError(w, domain.ErrFederatedLoginFailed)
+		return
+	}
+
+	// A state from another browser means someone is trying to sign the user in to their account
+	state := query.Get("state")
+	cookie, err := r.Cookie(federatedStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		errors.RespondWithError(w, domain.ErrFederatedLoginFailed)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     federatedStateCookie,
+		Path:     "/api/auth/federated",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	ctx := withDeviceToken(withClientIP(r), r, "")
+	result, err := h.federationService.FinishLogin(ctx, chi.URLParam(r, "provider"), state, query.Get("code"))
+	if err != nil {
+		h.logger.Debug("failed to finish federated login", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
+
+// ListIdentitiesHandler lists the upstream identities linked to the signed-in user
+func (h *FederationHandler) ListIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	identities, err := h.federationService.ListIdentities(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to list federated identities", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(identities); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockFederationService struct {
+	mock.Mock
+}
+
+func (m *mockFederationService) Providers() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *mockFederationService) BeginLogin(ctx context.Context, provider string) (string, string, error) {
+	args := m.Called(ctx, provider)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *mockFederationService) FinishLogin(ctx context.Context, provider, state, code string) (interface{}, error) {
+	args := m.Called(ctx, provider, state, code)
+	return args.Get(0), args.Error(1)
+}
+
+func (m *mockFederationService) ListIdentities(ctx context.Context, userID string) ([]*domain.FederatedIdentity, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.FederatedIdentity), args.Error(1)
+}
+
+func withProvider(r *http.Request, provider string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("provider", provider)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestFederationHandler_ListProvidersHandler(t *testing.T) {
+	service := new(mockFederationService)
+	service.On("Providers").Return([]string{"corp", "social"})
+	handler := NewFederationHandler(service, zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	handler.ListProvidersHandler(rr, httptest.NewRequest(http.MethodGet, "/auth/federated", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"providers":["corp","social"]}`, rr.Body.String())
+}
+
+func TestFederationHandler_BeginLoginHandler(t *testing.T) {
+	t.Run("redirects to the provider", func(t *testing.T) {
+		service := new(mockFederationService)
+		service.On("BeginLogin", mock.Anything, "corp").Return("state-1", "https://idp.example.com/authorize?state=state-1", nil)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.BeginLoginHandler(rr, withProvider(httptest.NewRequest(http.MethodGet, "/auth/federated/corp", nil), "corp"))
+
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "https://idp.example.com/authorize?state=state-1", rr.Header().Get("Location"))
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, federatedStateCookie, cookies[0].Name)
+		assert.Equal(t, "state-1", cookies[0].Value)
+		assert.True(t, cookies[0].HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		service := new(mockFederationService)
+		service.On("BeginLogin", mock.Anything, "social").Return("", "", domain.ErrIdentityProviderNotFound)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.BeginLoginHandler(rr, withProvider(httptest.NewRequest(http.MethodGet, "/auth/federated/social", nil), "social"))
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestFederationHandler_CallbackHandler(t *testing.T) {
+	callback := func(query string, cookie string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/auth/federated/corp/callback?"+query, nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: federatedStateCookie, Value: cookie})
+		}
+		return withProvider(req, "corp")
+	}
+
+	t.Run("signs the user in", func(t *testing.T) {
+		service := new(mockFederationService)
+		service.On("FinishLogin", mock.Anything, "corp", "state-1", "code-1").
+			Return(&domain.TokenPair{AccessToken: "access_token", RefreshToken: "refresh_token"}, nil)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.CallbackHandler(rr, callback("state=state-1&code=code-1", "state-1"))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var tokens domain.TokenPair
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tokens))
+		assert.Equal(t, "access_token", tokens.AccessToken)
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, -1, cookies[0].MaxAge, "the state cookie is cleared")
+	})
+
+	t.Run("state of another browser", func(t *testing.T) {
+		service := new(mockFederationService)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.CallbackHandler(rr, callback("state=state-1&code=code-1", "state-2"))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		service.AssertNotCalled(t, "FinishLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no state cookie", func(t *testing.T) {
+		service := new(mockFederationService)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.CallbackHandler(rr, callback("state=state-1&code=code-1", ""))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		service.AssertNotCalled(t, "FinishLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("error from the provider", func(t *testing.T) {
+		service := new(mockFederationService)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.CallbackHandler(rr, callback("state=state-1&error=access_denied", "state-1"))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		service.AssertNotCalled(t, "FinishLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unverified upstream email", func(t *testing.T) {
+		service := new(mockFederationService)
+		service.On("FinishLogin", mock.Anything, "corp", "state-1", "code-1").Return(nil, domain.ErrUpstreamEmailNotVerified)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.CallbackHandler(rr, callback("state=state-1&code=code-1", "state-1"))
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestFederationHandler_ListIdentitiesHandler(t *testing.T) {
+	userID := ulid.Make().String()
+
+	t.Run("lists the identities", func(t *testing.T) {
+		service := new(mockFederationService)
+		service.On("ListIdentities", mock.Anything, userID).Return([]*domain.FederatedIdentity{
+			domain.NewFederatedIdentity(ulid.MustParse(userID), "corp", "upstream-42", "jane@example.com"),
+		}, nil)
+		handler := NewFederationHandler(service, zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodGet, "/users/me/identities", nil)
+		req = req.WithContext(domain.WithSubject(req.Context(), userID))
+		rr := httptest.NewRecorder()
+		handler.ListIdentitiesHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var identities []map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&identities))
+		require.Len(t, identities, 1)
+		assert.Equal(t, "corp", identities[0]["provider"])
+		assert.NotContains(t, identities[0], "user_id")
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		handler := NewFederationHandler(new(mockFederationService), zap.NewNop())
+
+		rr := httptest.NewRecorder()
+		handler.ListIdentitiesHandler(rr, httptest.NewRequest(http.MethodGet, "/users/me/identities", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"github.com/oklog/ulid/v2"
+	"go.uber.org/zap"
+)
+
+// LockoutHandler handles administration of account lockouts
+type LockoutHandler struct {
+	lockoutService domain.LockoutService
+	logger         *zap.Logger
+}
+
+// NewLockoutHandler creates a new LockoutHandler
+func NewLockoutHandler(lockoutService domain.LockoutService, logger *zap.Logger) *LockoutHandler {
+	return &LockoutHandler{
+		lockoutService: lockoutService,
+		logger:         logger,
+	}
+}
+
+// UnlockUserHandler clears the lockout and failed attempts of a user
+func (h *LockoutHandler) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := ulid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		h.logger.Error("invalid user ID format", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInvalidUserID)
+		return
+	}
+
+	if err := h.lockoutService.Unlock(r.Context(), userID); err != nil {
+		h.logger.Error("failed to unlock user", zap.String("user_id", userID.String()), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
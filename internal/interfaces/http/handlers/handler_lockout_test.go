@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// MockLockoutService is a mock implementation of domain.LockoutService
+type MockLockoutService struct {
+	mock.Mock
+}
+
+func (m *MockLockoutService) Check(ctx context.Context, userID, ip string) error {
+	args := m.Called(ctx, userID, ip)
+	return args.Error(0)
+}
+
+func (m *MockLockoutService) RecordFailure(ctx context.Context, user *domain.User, ip string) error {
+	args := m.Called(ctx, user, ip)
+	return args.Error(0)
+}
+
+func (m *MockLockoutService) CheckUnknown(ctx context.Context, login string) error {
+	args := m.Called(ctx, login)
+	return args.Error(0)
+}
+
+func (m *MockLockoutService) RecordUnknownFailure(ctx context.Context, login, ip string) error {
+	args := m.Called(ctx, login, ip)
+	return args.Error(0)
+}
+
+func (m *MockLockoutService) RecordSuccess(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockLockoutService) Unlock(ctx context.Context, userID ulid.ULID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockLockoutService) Throttle(ctx context.Context, key string, limit int, window time.Duration) error {
+	args := m.Called(ctx, key, limit, window)
+	return args.Error(0)
+}
+
+func TestUnlockUserHandler(t *testing.T) {
+	userID := ulid.Make()
+
+	tests := []struct {
+		name           string
+		userID         string
+		mockSetup      func(*MockLockoutService)
+		expectedStatus int
+	}{
+		{
+			name:   "Success",
+			userID: userID.String(),
+			mockSetup: func(m *MockLockoutService) {
+				m.On("Unlock", mock.Anything, userID).Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:   "User Not Found",
+			userID: userID.String(),
+			mockSetup: func(m *MockLockoutService) {
+				m.On("Unlock", mock.Anything, userID).Return(domain.ErrUserNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Invalid User ID",
+			userID:         "not-a-ulid",
+			mockSetup:      func(m *MockLockoutService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockLockoutService)
+			tt.mockSetup(mockService)
+			handler := NewLockoutHandler(mockService, zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/users/"+tt.userID+"/unlock", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.userID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+
+			handler.UnlockUserHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
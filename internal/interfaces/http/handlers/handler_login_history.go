@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// LoginHistoryHandler handles the login history of users and the revocation of logins from new devices
+type LoginHistoryHandler struct {
+	historyService domain.LoginHistoryService
+	logger         *zap.Logger
+}
+
+// NewLoginHistoryHandler creates a new LoginHistoryHandler
+func NewLoginHistoryHandler(historyService domain.LoginHistoryService, logger *zap.Logger) *LoginHistoryHandler {
+	return &LoginHistoryHandler{
+		historyService: historyService,
+		logger:         logger,
+	}
+}
+
+// RevokeSignInRequest carries the token of the link in a new sign-in email
+type RevokeSignInRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ListHistoryHandler lists the latest logins of the signed-in user, newest first
+func (h *LoginHistoryHandler) ListHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	events, err := h.historyService.List(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to list login history", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// RevokeSignInHandler revokes the sessions and trusted devices of the user a new sign-in email was
+// sent to
+func (h *LoginHistoryHandler) RevokeSignInHandler(w http.ResponseWriter, r *http.Request) {
+	var req RevokeSignInRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.historyService.RevokeSignIn(r.Context(), req.Token); err != nil {
+		h.logger.Debug("failed to revoke sign-in", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
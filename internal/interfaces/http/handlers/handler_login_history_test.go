@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockLoginHistoryService struct {
+	mock.Mock
+}
+
+func (m *mockLoginHistoryService) IsNewDevice(ctx context.Context, user *domain.User) bool {
+	args := m.Called(ctx, user)
+	return args.Bool(0)
+}
+
+func (m *mockLoginHistoryService) Record(ctx context.Context, user *domain.User, auth *domain.Authentication) {
+	m.Called(ctx, user, auth)
+}
+
+func (m *mockLoginHistoryService) List(ctx context.Context, userID string) ([]*domain.LoginEvent, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.LoginEvent), args.Error(1)
+}
+
+func (m *mockLoginHistoryService) RevokeSignIn(ctx context.Context, token string) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func TestLoginHistoryHandler_ListHistoryHandler(t *testing.T) {
+	userID := ulid.Make().String()
+
+	t.Run("lists the logins", func(t *testing.T) {
+		service := new(mockLoginHistoryService)
+		service.On("List", mock.Anything, userID).Return([]*domain.LoginEvent{{
+			ID:              ulid.Make(),
+			Device:          "Firefox on Linux",
+			Methods:         []string{"pwd"},
+			NewDevice:       true,
+			RevokeTokenHash: "hash",
+		}}, nil)
+		handler := NewLoginHistoryHandler(service, zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodGet, "/users/me/login-history", nil)
+		req = req.WithContext(domain.WithSubject(req.Context(), userID))
+		rr := httptest.NewRecorder()
+		handler.ListHistoryHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var events []map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&events))
+		require.Len(t, events, 1)
+		assert.Equal(t, "Firefox on Linux", events[0]["device"])
+		assert.Equal(t, true, events[0]["new_device"])
+		assert.NotContains(t, events[0], "revoke_token_hash")
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		handler := NewLoginHistoryHandler(new(mockLoginHistoryService), zap.NewNop())
+
+		req := httptest.NewRequest(http.MethodGet, "/users/me/login-history", nil)
+		rr := httptest.NewRecorder()
+		handler.ListHistoryHandler(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestLoginHistoryHandler_RevokeSignInHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		serviceErr     error
+		expectedStatus int
+	}{
+		{
+			name:           "revokes the sign-in",
+			body:           `{"token":"login.secret"}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "invalid token",
+			body:           `{"token":"login.secret"}`,
+			serviceErr:     domain.ErrInvalidSignInRevocation,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing token",
+			body:           `{}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(mockLoginHistoryService)
+			service.On("RevokeSignIn", mock.Anything, "login.secret").Return(tt.serviceErr).Maybe()
+			handler := NewLoginHistoryHandler(service, zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/revoke-sign-in", bytes.NewBufferString(tt.body))
+			rr := httptest.NewRecorder()
+			handler.RevokeSignInHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+}
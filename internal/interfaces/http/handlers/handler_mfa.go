@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// MFAHandler handles the management of a user's second factors
+type MFAHandler struct {
+	mfaService domain.MFAService
+	logger     *zap.Logger
+}
+
+// NewMFAHandler creates a new MFAHandler
+func NewMFAHandler(mfaService domain.MFAService, logger *zap.Logger) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+		logger:     logger,
+	}
+}
+
+type RenameMFAFactorRequest struct {
+	Label string `json:"label" validate:"required,max=64"`
+}
+
+type ResetMFARequest struct {
+	VerificationMethod domain.MFAResetMethod `json:"verification_method" validate:"required,oneof=id_document in_person video_call"`
+	Reason             string                `json:"reason" validate:"required,max=500"`
+}
+
+// ListFactorsHandler lists the signed-in user's factors, passkeys included
+func (h *MFAHandler) ListFactorsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	factors, err := h.mfaService.ListFactors(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to list MFA factors", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, factors)
+}
+
+// EnrollEmailOTPHandler registers the signed-in user's verified email as a factor
+func (h *MFAHandler) EnrollEmailOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	factor, err := h.mfaService.EnrollEmailOTP(r.Context(), userID)
+	if err != nil {
+		h.logger.Debug("failed to enrol email factor", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusCreated, factor)
+}
+
+// EnrollSMSOTPHandler registers the signed-in user's verified phone as a factor
+func (h *MFAHandler) EnrollSMSOTPHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	factor, err := h.mfaService.EnrollSMSOTP(r.Context(), userID)
+	if err != nil {
+		h.logger.Debug("failed to enrol SMS factor", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusCreated, factor)
+}
+
+// RenameFactorHandler changes the label of one of the signed-in user's factors
+func (h *MFAHandler) RenameFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req RenameMFAFactorRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.mfaService.RenameFactor(r.Context(), userID, chi.URLParam(r, "id"), req.Label); err != nil {
+		h.logger.Debug("failed to rename MFA factor", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteFactorHandler removes one of the signed-in user's factors
+func (h *MFAHandler) DeleteFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	if err := h.mfaService.DeleteFactor(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		h.logger.Debug("failed to delete MFA factor", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetMFAHandler removes every second factor of a user after an administrator verified their identity
+func (h *MFAHandler) ResetMFAHandler(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := domain.GetSubject(r.Context())
+	if !ok || adminID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req ResetMFARequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	reset, err := h.mfaService.ResetMFA(withClientIP(r), adminID, chi.URLParam(r, "id"), req.VerificationMethod, req.Reason)
+	if err != nil {
+		h.logger.Error("failed to reset MFA", zap.String("user_id", chi.URLParam(r, "id")), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, reset)
+}
+
+// ListResetsHandler lists the MFA resets of a user, newest first
+func (h *MFAHandler) ListResetsHandler(w http.ResponseWriter, r *http.Request) {
+	resets, err := h.mfaService.ListResets(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.logger.Error("failed to list MFA resets", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, resets)
+}
+
+func (h *MFAHandler) respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockMFAService struct {
+	mock.Mock
+}
+
+func (m *mockMFAService) ListFactors(ctx context.Context, userID string) ([]*domain.MFAFactor, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.MFAFactor), args.Error(1)
+}
+
+func (m *mockMFAService) RenameFactor(ctx context.Context, userID, factorID, label string) error {
+	args := m.Called(ctx, userID, factorID, label)
+	return args.Error(0)
+}
+
+func (m *mockMFAService) DeleteFactor(ctx context.Context, userID, factorID string) error {
+	args := m.Called(ctx, userID, factorID)
+	return args.Error(0)
+}
+
+func (m *mockMFAService) EnrollEmailOTP(ctx context.Context, userID string) (*domain.MFAFactor, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAFactor), args.Error(1)
+}
+
+func (m *mockMFAService) EnrollSMSOTP(ctx context.Context, userID string) (*domain.MFAFactor, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAFactor), args.Error(1)
+}
+
+func (m *mockMFAService) SendChallenge(ctx context.Context, user *domain.User, factorID string, channel domain.SMSChannel) error {
+	args := m.Called(ctx, user, factorID, channel)
+	return args.Error(0)
+}
+
+func (m *mockMFAService) SendEmailCode(ctx context.Context, user *domain.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *mockMFAService) VerifyEmailCode(ctx context.Context, user *domain.User, code string) error {
+	args := m.Called(ctx, user, code)
+	return args.Error(0)
+}
+
+func (m *mockMFAService) VerifyFactor(ctx context.Context, user *domain.User, factorID, code string) (domain.MFAFactorType, error) {
+	args := m.Called(ctx, user, factorID, code)
+	factorType, _ := args.Get(0).(domain.MFAFactorType)
+	return factorType, args.Error(1)
+}
+
+func (m *mockMFAService) ResetMFA(ctx context.Context, adminID, userID string, method domain.MFAResetMethod, reason string) (*domain.MFAReset, error) {
+	args := m.Called(ctx, adminID, userID, method, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.MFAReset), args.Error(1)
+}
+
+func (m *mockMFAService) ListResets(ctx context.Context, userID string) ([]*domain.MFAReset, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.MFAReset), args.Error(1)
+}
+
+func TestMFAHandler_ManageFactors(t *testing.T) {
+	userID := ulid.Make().String()
+	factorID := ulid.Make().String()
+
+	withFactorID := func(r *http.Request) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", factorID)
+		ctx := context.WithValue(domain.WithSubject(r.Context(), userID), chi.RouteCtxKey, rctx)
+		return r.WithContext(ctx)
+	}
+
+	t.Run("list", func(t *testing.T) {
+		mockService := new(mockMFAService)
+		mockService.On("ListFactors", mock.Anything, userID).Return([]*domain.MFAFactor{
+			domain.NewMFAFactor(ulid.MustParse(userID), domain.MFAFactorTOTP, "Phone"),
+			domain.NewMFAFactor(ulid.MustParse(userID), domain.MFAFactorRecovery, "Backup codes"),
+		}, nil)
+		handler := NewMFAHandler(mockService, zap.NewNop())
+
+		req := withFactorID(httptest.NewRequest("GET", "/users/me/mfa/factors", nil))
+		rr := httptest.NewRecorder()
+		handler.ListFactorsHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response []map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		require.Len(t, response, 2)
+		assert.Equal(t, "totp", response[0]["type"])
+		assert.Equal(t, "Phone", response[0]["label"])
+		assert.NotContains(t, response[0], "user_id")
+		assert.Equal(t, "recovery", response[1]["type"])
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		mockService := new(mockMFAService)
+		mockService.On("RenameFactor", mock.Anything, userID, factorID, "Work phone").Return(nil)
+		handler := NewMFAHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"label": "Work phone"})
+		req := withFactorID(httptest.NewRequest("PATCH", "/users/me/mfa/factors/"+factorID, bytes.NewBuffer(body)))
+		rr := httptest.NewRecorder()
+		handler.RenameFactorHandler(rr, req)
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("rename without label", func(t *testing.T) {
+		mockService := new(mockMFAService)
+		handler := NewMFAHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"label": ""})
+		req := withFactorID(httptest.NewRequest("PATCH", "/users/me/mfa/factors/"+factorID, bytes.NewBuffer(body)))
+		rr := httptest.NewRecorder()
+		handler.RenameFactorHandler(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertNotCalled(t, "RenameFactor", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("delete factor of another user", func(t *testing.T) {
+		mockService := new(mockMFAService)
+		mockService.On("DeleteFactor", mock.Anything, userID, factorID).Return(domain.ErrMFAFactorNotFound)
+		handler := NewMFAHandler(mockService, zap.NewNop())
+
+		req := withFactorID(httptest.NewRequest("DELETE", "/users/me/mfa/factors/"+factorID, nil))
+		rr := httptest.NewRecorder()
+		handler.DeleteFactorHandler(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestMFAHandler_EnrollEmailOTP(t *testing.T) {
+	userID := ulid.Make().String()
+
+	tests := []struct {
+		name           string
+		mockSetup      func(*mockMFAService)
+		expectedStatus int
+	}{
+		{
+			name: "enrolled",
+			mockSetup: func(m *mockMFAService) {
+				m.On("EnrollEmailOTP", mock.Anything, userID).
+					Return(domain.NewMFAFactor(ulid.MustParse(userID), domain.MFAFactorEmailOTP, "test@example.com"), nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "already enrolled",
+			mockSetup: func(m *mockMFAService) {
+				m.On("EnrollEmailOTP", mock.Anything, userID).Return(nil, domain.ErrMFAFactorExists)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockMFAService)
+			tt.mockSetup(mockService)
+			handler := NewMFAHandler(mockService, zap.NewNop())
+
+			req := httptest.NewRequest("POST", "/users/me/mfa/factors/email", nil)
+			req = req.WithContext(domain.WithSubject(req.Context(), userID))
+			rr := httptest.NewRecorder()
+			handler.EnrollEmailOTPHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMFAHandler_EnrollSMSOTP(t *testing.T) {
+	userID := ulid.Make().String()
+
+	tests := []struct {
+		name           string
+		mockSetup      func(*mockMFAService)
+		expectedStatus int
+	}{
+		{
+			name: "enrolled",
+			mockSetup: func(m *mockMFAService) {
+				m.On("EnrollSMSOTP", mock.Anything, userID).
+					Return(domain.NewMFAFactor(ulid.MustParse(userID), domain.MFAFactorSMSOTP, "+*******0123"), nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "unverified phone",
+			mockSetup: func(m *mockMFAService) {
+				m.On("EnrollSMSOTP", mock.Anything, userID).Return(nil, domain.ErrPhoneNotVerified)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockMFAService)
+			tt.mockSetup(mockService)
+			handler := NewMFAHandler(mockService, zap.NewNop())
+
+			req := httptest.NewRequest("POST", "/users/me/mfa/factors/sms", nil)
+			req = req.WithContext(domain.WithSubject(req.Context(), userID))
+			rr := httptest.NewRecorder()
+			handler.EnrollSMSOTPHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMFAHandler_ResetMFA(t *testing.T) {
+	adminID := ulid.Make().String()
+	userID := ulid.Make().String()
+
+	withUserID := func(r *http.Request) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", userID)
+		ctx := context.WithValue(domain.WithSubject(r.Context(), adminID), chi.RouteCtxKey, rctx)
+		return r.WithContext(ctx)
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		mockService := new(mockMFAService)
+		mockService.On("ResetMFA", mock.Anything, adminID, userID, domain.MFAResetVideoCall, "Lost phone").Return(&domain.MFAReset{
+			ID:                 ulid.Make(),
+			UserID:             ulid.MustParse(userID),
+			AdminID:            ulid.MustParse(adminID),
+			VerificationMethod: domain.MFAResetVideoCall,
+			Reason:             "Lost phone",
+			RemovedFactors:     []domain.MFAFactorType{domain.MFAFactorTOTP},
+		}, nil)
+		handler := NewMFAHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"verification_method": "video_call", "reason": "Lost phone"})
+		req := withUserID(httptest.NewRequest("POST", "/users/"+userID+"/mfa/reset", bytes.NewBuffer(body)))
+		rr := httptest.NewRecorder()
+		handler.ResetMFAHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, adminID, response["admin_id"])
+		assert.Equal(t, []interface{}{"totp"}, response["removed_factors"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("unknown verification method", func(t *testing.T) {
+		mockService := new(mockMFAService)
+		handler := NewMFAHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"verification_method": "email", "reason": "Lost phone"})
+		req := withUserID(httptest.NewRequest("POST", "/users/"+userID+"/mfa/reset", bytes.NewBuffer(body)))
+		rr := httptest.NewRecorder()
+		handler.ResetMFAHandler(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockService.AssertNotCalled(t, "ResetMFA", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("own MFA", func(t *testing.T) {
+		mockService := new(mockMFAService)
+		mockService.On("ResetMFA", mock.Anything, adminID, userID, domain.MFAResetInPerson, "Lost phone").Return(nil, domain.ErrForbidden)
+		handler := NewMFAHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"verification_method": "in_person", "reason": "Lost phone"})
+		req := withUserID(httptest.NewRequest("POST", "/users/"+userID+"/mfa/reset", bytes.NewBuffer(body)))
+		rr := httptest.NewRecorder()
+		handler.ResetMFAHandler(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+}
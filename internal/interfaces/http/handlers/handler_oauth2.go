@@ -14,24 +14,36 @@ import (
 
 // OAuth2ClientRequest represents the request to create/update an OAuth2 client
 type OAuth2ClientRequest struct {
-	ID           string   `json:"id" validate:"required"`
-	Secret       string   `json:"secret" validate:"required"`
-	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1"`
-	GrantTypes   []string `json:"grant_types" validate:"required,min=1"`
-	Scopes       []string `json:"scopes" validate:"required,min=1"`
+	ID                                    string   `json:"id" validate:"required"`
+	Secret                                string   `json:"secret" validate:"required_unless=ApplicationType native"`
+	ApplicationType                       string   `json:"application_type" validate:"omitempty,oneof=web native"`
+	RedirectURIs                          []string `json:"redirect_uris" validate:"required,min=1"`
+	RedirectURIPatterns                   []string `json:"redirect_uri_patterns"`
+	GrantTypes                            []string `json:"grant_types" validate:"required,min=1"`
+	Scopes                                []string `json:"scopes" validate:"required,min=1"`
+	SubjectType                           string   `json:"subject_type" validate:"omitempty,oneof=public pairwise"`
+	SectorIdentifierURI                   string   `json:"sector_identifier_uri" validate:"omitempty,url"`
+	JWKSURI                               string   `json:"jwks_uri" validate:"omitempty,url"`
+	UserInfoSignedResponseAlg             string   `json:"userinfo_signed_response_alg"`
+	IDTokenEncryptedResponseAlg           string   `json:"id_token_encrypted_response_alg"`
+	IDTokenEncryptedResponseEnc           string   `json:"id_token_encrypted_response_enc"`
+	BackchannelTokenDeliveryMode          string   `json:"backchannel_token_delivery_mode" validate:"omitempty,oneof=poll ping"`
+	BackchannelClientNotificationEndpoint string   `json:"backchannel_client_notification_endpoint" validate:"omitempty,url"`
 }
 
 // OAuth2Handler handles OAuth2 client management
 type OAuth2Handler struct {
-	oauthRepo domain.OAuth2Repository
-	logger    *zap.Logger
+	oauthRepo      domain.OAuth2Repository
+	sectorVerifier domain.SectorIdentifierVerifier
+	logger         *zap.Logger
 }
 
 // NewOAuth2Handler creates a new OAuth2Handler
-func NewOAuth2Handler(oauthRepo domain.OAuth2Repository, logger *zap.Logger) *OAuth2Handler {
+func NewOAuth2Handler(oauthRepo domain.OAuth2Repository, sectorVerifier domain.SectorIdentifierVerifier, logger *zap.Logger) *OAuth2Handler {
 	return &OAuth2Handler{
-		oauthRepo: oauthRepo,
-		logger:    logger,
+		oauthRepo:      oauthRepo,
+		sectorVerifier: sectorVerifier,
+		logger:         logger,
 	}
 }
 
@@ -60,13 +72,60 @@ func (h *OAuth2Handler) CreateClientHandler(w http.ResponseWriter, r *http.Reque
 
 	// Create OAuth2 client
 	client := &domain.OAuth2Client{
-		ID:           req.ID,
-		Secret:       req.Secret,
-		RedirectURIs: req.RedirectURIs,
-		GrantTypes:   req.GrantTypes,
-		Scopes:       req.Scopes,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:                                    req.ID,
+		Secret:                                req.Secret,
+		ApplicationType:                       applicationTypeOrDefault(req.ApplicationType),
+		RedirectURIs:                          req.RedirectURIs,
+		RedirectURIPatterns:                   req.RedirectURIPatterns,
+		GrantTypes:                            req.GrantTypes,
+		Scopes:                                req.Scopes,
+		SubjectType:                           subjectTypeOrDefault(req.SubjectType),
+		SectorIdentifierURI:                   req.SectorIdentifierURI,
+		JWKSURI:                               req.JWKSURI,
+		UserInfoSignedResponseAlg:             req.UserInfoSignedResponseAlg,
+		IDTokenEncryptedResponseAlg:           req.IDTokenEncryptedResponseAlg,
+		IDTokenEncryptedResponseEnc:           req.IDTokenEncryptedResponseEnc,
+		BackchannelTokenDeliveryMode:          req.BackchannelTokenDeliveryMode,
+		BackchannelClientNotificationEndpoint: req.BackchannelClientNotificationEndpoint,
+		CreatedAt:                             time.Now(),
+		UpdatedAt:                             time.Now(),
+	}
+
+	// Validate redirect URIs against the client's redirect policy
+	if err := client.ValidateRedirectPolicy(); err != nil {
+		h.logger.Error("Invalid redirect URIs", zap.String("client_id", req.ID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	// Validate that pairwise clients have a sector identifier
+	if err := client.ValidateSubjectType(); err != nil {
+		h.logger.Error("Invalid subject type", zap.String("client_id", req.ID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	// Validate that the sector identifier document lists every redirect URI
+	if client.SectorIdentifierURI != "" {
+		if err := h.sectorVerifier.Verify(r.Context(), client.SectorIdentifierURI, client.RedirectURIs); err != nil {
+			h.logger.Error("Invalid sector identifier", zap.String("client_id", req.ID), zap.Error(err))
+			errors.RespondWithError(w, err.(domain.Error))
+			return
+		}
+	}
+
+	// Validate userinfo signing and ID token encryption metadata
+	if err := client.ValidateResponseFormats(); err != nil {
+		h.logger.Error("Invalid response formats", zap.String("client_id", req.ID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	// Validate the CIBA token delivery mode
+	if err := client.ValidateBackchannel(); err != nil {
+		h.logger.Error("Invalid backchannel metadata", zap.String("client_id", req.ID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
 	}
 
 	// Save client to repository
@@ -117,11 +176,58 @@ func (h *OAuth2Handler) UpdateClientHandler(w http.ResponseWriter, r *http.Reque
 
 	// Update client
 	client.Secret = req.Secret
+	client.ApplicationType = applicationTypeOrDefault(req.ApplicationType)
 	client.RedirectURIs = req.RedirectURIs
+	client.RedirectURIPatterns = req.RedirectURIPatterns
 	client.GrantTypes = req.GrantTypes
 	client.Scopes = req.Scopes
+	client.SubjectType = subjectTypeOrDefault(req.SubjectType)
+	client.SectorIdentifierURI = req.SectorIdentifierURI
+	client.JWKSURI = req.JWKSURI
+	client.UserInfoSignedResponseAlg = req.UserInfoSignedResponseAlg
+	client.IDTokenEncryptedResponseAlg = req.IDTokenEncryptedResponseAlg
+	client.IDTokenEncryptedResponseEnc = req.IDTokenEncryptedResponseEnc
+	client.BackchannelTokenDeliveryMode = req.BackchannelTokenDeliveryMode
+	client.BackchannelClientNotificationEndpoint = req.BackchannelClientNotificationEndpoint
 	client.UpdatedAt = time.Now()
 
+	// Validate redirect URIs against the client's redirect policy
+	if err := client.ValidateRedirectPolicy(); err != nil {
+		h.logger.Error("Invalid redirect URIs", zap.String("client_id", clientID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	// Validate that pairwise clients have a sector identifier
+	if err := client.ValidateSubjectType(); err != nil {
+		h.logger.Error("Invalid subject type", zap.String("client_id", clientID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	// Validate that the sector identifier document lists every redirect URI
+	if client.SectorIdentifierURI != "" {
+		if err := h.sectorVerifier.Verify(r.Context(), client.SectorIdentifierURI, client.RedirectURIs); err != nil {
+			h.logger.Error("Invalid sector identifier", zap.String("client_id", clientID), zap.Error(err))
+			errors.RespondWithError(w, err.(domain.Error))
+			return
+		}
+	}
+
+	// Validate userinfo signing and ID token encryption metadata
+	if err := client.ValidateResponseFormats(); err != nil {
+		h.logger.Error("Invalid response formats", zap.String("client_id", clientID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	// Validate the CIBA token delivery mode
+	if err := client.ValidateBackchannel(); err != nil {
+		h.logger.Error("Invalid backchannel metadata", zap.String("client_id", clientID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
 	if err := h.oauthRepo.UpdateClient(r.Context(), client); err != nil {
 		h.logger.Error("Failed to update OAuth2 client", zap.Error(err))
 		errors.RespondWithError(w, domain.ErrInternal)
@@ -200,3 +306,19 @@ func (h *OAuth2Handler) GetClientHandler(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(client)
 }
+
+// applicationTypeOrDefault treats clients registered without an application type as web clients
+func applicationTypeOrDefault(applicationType string) string {
+	if applicationType == "" {
+		return domain.ApplicationTypeWeb
+	}
+	return applicationType
+}
+
+// subjectTypeOrDefault treats clients registered without a subject type as public clients
+func subjectTypeOrDefault(subjectType string) string {
+	if subjectType == "" {
+		return domain.SubjectTypePublic
+	}
+	return subjectType
+}
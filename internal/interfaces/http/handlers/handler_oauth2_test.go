@@ -68,10 +68,29 @@ func (m *MockOAuth2Repository) DeleteAuthorizationCode(ctx context.Context, code
 	return args.Error(0)
 }
 
+// fakeSectorVerifier checks redirect URIs against fixed sector identifier documents
+type fakeSectorVerifier map[string][]string
+
+func (f fakeSectorVerifier) Verify(ctx context.Context, sectorIdentifierURI string, redirectURIs []string) error {
+	for _, uri := range redirectURIs {
+		listed := false
+		for _, allowed := range f[sectorIdentifierURI] {
+			listed = listed || allowed == uri
+		}
+		if !listed {
+			return domain.ErrInvalidSectorIdentifierReason(uri + " is not listed")
+		}
+	}
+	return nil
+}
+
 func setupTest() (*OAuth2Handler, *MockOAuth2Repository) {
 	logger, _ := zap.NewDevelopment()
 	mockRepo := new(MockOAuth2Repository)
-	handler := NewOAuth2Handler(mockRepo, logger)
+	sectorVerifier := fakeSectorVerifier{
+		"https://example.com/redirect_uris.json": {"https://a.example.com/callback", "https://b.example.com/callback"},
+	}
+	handler := NewOAuth2Handler(mockRepo, sectorVerifier, logger)
 	return handler, mockRepo
 }
 
@@ -88,7 +107,7 @@ func TestCreateClientHandler(t *testing.T) {
 			requestBody: OAuth2ClientRequest{
 				ID:           "test-client",
 				Secret:       "test-secret",
-				RedirectURIs: []string{"http://localhost:8080/callback"},
+				RedirectURIs: []string{"https://app.example.com/callback"},
 				GrantTypes:   []string{"authorization_code"},
 				Scopes:       []string{"openid", "profile"},
 			},
@@ -128,6 +147,245 @@ func TestCreateClientHandler(t *testing.T) {
 			expectedStatus: http.StatusBadRequest,
 			expectedError:  true,
 		},
+		{
+			name: "Confidential Web Client Without HTTPS",
+			requestBody: OAuth2ClientRequest{
+				ID:           "test-client",
+				Secret:       "test-secret",
+				RedirectURIs: []string{"http://app.example.com/callback"},
+				GrantTypes:   []string{"authorization_code"},
+				Scopes:       []string{"openid"},
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "test-client").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "Pairwise Client With Sector Identifier URI",
+			requestBody: OAuth2ClientRequest{
+				ID:                  "pairwise-client",
+				Secret:              "test-secret",
+				RedirectURIs:        []string{"https://a.example.com/callback", "https://b.example.com/callback"},
+				GrantTypes:          []string{"authorization_code"},
+				Scopes:              []string{"openid"},
+				SubjectType:         "pairwise",
+				SectorIdentifierURI: "https://example.com/redirect_uris.json",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "pairwise-client").Return(nil, domain.ErrInvalidClient)
+				m.On("CreateClient", mock.Anything, mock.MatchedBy(func(client *domain.OAuth2Client) bool {
+					return client.IsPairwise() && client.SectorIdentifierURI == "https://example.com/redirect_uris.json"
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedError:  false,
+		},
+		{
+			name: "Redirect URI Missing From Sector Identifier Document",
+			requestBody: OAuth2ClientRequest{
+				ID:                  "pairwise-client",
+				Secret:              "test-secret",
+				RedirectURIs:        []string{"https://a.example.com/callback", "https://c.example.com/callback"},
+				GrantTypes:          []string{"authorization_code"},
+				Scopes:              []string{"openid"},
+				SubjectType:         "pairwise",
+				SectorIdentifierURI: "https://example.com/redirect_uris.json",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "pairwise-client").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "Pairwise Client Without Sector Identifier",
+			requestBody: OAuth2ClientRequest{
+				ID:           "pairwise-client",
+				Secret:       "test-secret",
+				RedirectURIs: []string{"https://a.example.com/callback", "https://b.example.com/callback"},
+				GrantTypes:   []string{"authorization_code"},
+				Scopes:       []string{"openid"},
+				SubjectType:  "pairwise",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "pairwise-client").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "Client With Signed UserInfo And Encrypted ID Tokens",
+			requestBody: OAuth2ClientRequest{
+				ID:                          "secure-client",
+				Secret:                      "test-secret",
+				RedirectURIs:                []string{"https://app.example.com/callback"},
+				GrantTypes:                  []string{"authorization_code"},
+				Scopes:                      []string{"openid"},
+				JWKSURI:                     "https://app.example.com/jwks.json",
+				UserInfoSignedResponseAlg:   "RS256",
+				IDTokenEncryptedResponseAlg: "RSA-OAEP-256",
+				IDTokenEncryptedResponseEnc: "A256GCM",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "secure-client").Return(nil, domain.ErrInvalidClient)
+				m.On("CreateClient", mock.Anything, mock.MatchedBy(func(client *domain.OAuth2Client) bool {
+					return client.WantsSignedUserInfo() && client.WantsEncryptedIDToken() && client.IDTokenEncryptionEnc() == "A256GCM"
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedError:  false,
+		},
+		{
+			name: "Encrypted ID Tokens Without JWKS URI",
+			requestBody: OAuth2ClientRequest{
+				ID:                          "secure-client",
+				Secret:                      "test-secret",
+				RedirectURIs:                []string{"https://app.example.com/callback"},
+				GrantTypes:                  []string{"authorization_code"},
+				Scopes:                      []string{"openid"},
+				IDTokenEncryptedResponseAlg: "RSA-OAEP",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "secure-client").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "Unsupported UserInfo Signing Algorithm",
+			requestBody: OAuth2ClientRequest{
+				ID:                        "secure-client",
+				Secret:                    "test-secret",
+				RedirectURIs:              []string{"https://app.example.com/callback"},
+				GrantTypes:                []string{"authorization_code"},
+				Scopes:                    []string{"openid"},
+				UserInfoSignedResponseAlg: "HS256",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "secure-client").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "CIBA Ping Client",
+			requestBody: OAuth2ClientRequest{
+				ID:                                    "call-centre",
+				Secret:                                "test-secret",
+				RedirectURIs:                          []string{"https://call-centre.example.com/callback"},
+				GrantTypes:                            []string{domain.GrantTypeCIBA},
+				Scopes:                                []string{"openid"},
+				BackchannelTokenDeliveryMode:          "ping",
+				BackchannelClientNotificationEndpoint: "https://call-centre.example.com/ciba",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "call-centre").Return(nil, domain.ErrInvalidClient)
+				m.On("CreateClient", mock.Anything, mock.MatchedBy(func(client *domain.OAuth2Client) bool {
+					return client.UsesBackchannelPing() && client.AllowsGrantType(domain.GrantTypeCIBA)
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedError:  false,
+		},
+		{
+			name: "CIBA Ping Client Without Notification Endpoint",
+			requestBody: OAuth2ClientRequest{
+				ID:                           "call-centre",
+				Secret:                       "test-secret",
+				RedirectURIs:                 []string{"https://call-centre.example.com/callback"},
+				GrantTypes:                   []string{domain.GrantTypeCIBA},
+				Scopes:                       []string{"openid"},
+				BackchannelTokenDeliveryMode: "ping",
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "call-centre").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "Native Client With Loopback And Private-Use Scheme",
+			requestBody: OAuth2ClientRequest{
+				ID:              "desktop-app",
+				Secret:          "test-secret",
+				ApplicationType: "native",
+				RedirectURIs:    []string{"http://127.0.0.1/callback", "com.example.desktop:/oauth2redirect"},
+				GrantTypes:      []string{"authorization_code"},
+				Scopes:          []string{"openid"},
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "desktop-app").Return(nil, domain.ErrInvalidClient)
+				m.On("CreateClient", mock.Anything, mock.MatchedBy(func(client *domain.OAuth2Client) bool {
+					return client.ApplicationType == domain.ApplicationTypeNative
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedError:  false,
+		},
+		{
+			name: "Native Public Client Without Secret",
+			requestBody: OAuth2ClientRequest{
+				ID:              "mobile-app",
+				ApplicationType: "native",
+				RedirectURIs:    []string{"http://localhost/callback"},
+				GrantTypes:      []string{"authorization_code"},
+				Scopes:          []string{"openid"},
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "mobile-app").Return(nil, domain.ErrInvalidClient)
+				m.On("CreateClient", mock.Anything, mock.MatchedBy(func(client *domain.OAuth2Client) bool {
+					return client.Secret == "" && !client.IsConfidential()
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+			expectedError:  false,
+		},
+		{
+			name: "Web Client Without Secret",
+			requestBody: OAuth2ClientRequest{
+				ID:           "web-app",
+				RedirectURIs: []string{"https://app.example.com/callback"},
+				GrantTypes:   []string{"authorization_code"},
+				Scopes:       []string{"openid"},
+			},
+			mockSetup:      func(m *MockOAuth2Repository) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "Native Client With Non Reverse-DNS Scheme",
+			requestBody: OAuth2ClientRequest{
+				ID:              "desktop-app",
+				Secret:          "test-secret",
+				ApplicationType: "native",
+				RedirectURIs:    []string{"myapp:/callback"},
+				GrantTypes:      []string{"authorization_code"},
+				Scopes:          []string{"openid"},
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "desktop-app").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
+		{
+			name: "Wildcard Pattern On Top-Level Domain",
+			requestBody: OAuth2ClientRequest{
+				ID:                  "preview-app",
+				Secret:              "test-secret",
+				RedirectURIs:        []string{"https://app.example.com/callback"},
+				RedirectURIPatterns: []string{"https://*.com/callback"},
+				GrantTypes:          []string{"authorization_code"},
+				Scopes:              []string{"openid"},
+			},
+			mockSetup: func(m *MockOAuth2Repository) {
+				m.On("FindClientByID", mock.Anything, "preview-app").Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  true,
+		},
 		{
 			name: "Invalid Request - Missing Required Fields",
 			requestBody: OAuth2ClientRequest{
@@ -183,7 +441,7 @@ func TestUpdateClientHandler(t *testing.T) {
 			requestBody: OAuth2ClientRequest{
 				ID:           "test-client",
 				Secret:       "new-secret",
-				RedirectURIs: []string{"http://localhost:8080/new-callback"},
+				RedirectURIs: []string{"https://app.example.com/new-callback"},
 				GrantTypes:   []string{"authorization_code"},
 				Scopes:       []string{"openid", "profile", "email"},
 			},
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/manorfm/authM/internal/domain"
@@ -16,21 +19,31 @@ type TokenRequest struct {
 	GrantType    string `json:"grantType" validate:"required"`
 	Code         string `json:"code"`
 	RefreshToken string `json:"refreshToken"`
-	ClientID     string `json:"clientId" validate:"required"`
-	ClientSecret string `json:"clientSecret" validate:"required"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
 	RedirectURI  string `json:"redirectUri"`
 	CodeVerifier string `json:"codeVerifier"`
+	AuthReqID    string `json:"authReqId"`
+}
+
+// IntrospectionRequest represents the token introspection request of RFC 7662
+type IntrospectionRequest struct {
+	Token        string `json:"token" validate:"required"`
+	ClientID     string `json:"client_id" validate:"required"`
+	ClientSecret string `json:"client_secret" validate:"required"`
 }
 
 type OIDCHandler struct {
 	oidcService domain.OIDCService
+	cibaService domain.CIBAService
 	jwtService  domain.JWTService
 	logger      *zap.Logger
 }
 
-func NewOIDCHandler(oidcService domain.OIDCService, jwtService domain.JWTService, logger *zap.Logger) *OIDCHandler {
+func NewOIDCHandler(oidcService domain.OIDCService, cibaService domain.CIBAService, jwtService domain.JWTService, logger *zap.Logger) *OIDCHandler {
 	return &OIDCHandler{
 		oidcService: oidcService,
+		cibaService: cibaService,
 		jwtService:  jwtService,
 		logger:      logger,
 	}
@@ -63,6 +76,20 @@ func (h *OIDCHandler) GetUserInfoHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Clients that registered userinfo_signed_response_alg receive a signed JWT
+	signed, err := h.oidcService.SignUserInfo(r.Context(), userInfo)
+	if err != nil {
+		h.logger.Error("Failed to sign user info", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	if signed != "" {
+		w.Header().Set("Content-Type", "application/jwt")
+		w.Write([]byte(signed))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(userInfo); err != nil {
 		h.logger.Error("Failed to encode user info response", zap.Error(err))
@@ -143,6 +170,12 @@ func (h *OIDCHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch req.GrantType {
 	case "authorization_code":
+		if req.ClientID == "" {
+			h.logger.Error("Missing client ID")
+			errors.RespondWithError(w, domain.ErrInvalidClient)
+			return
+		}
+
 		if req.Code == "" {
 			h.logger.Error("Missing authorization code")
 			errors.RespondWithError(w, domain.ErrInvalidField)
@@ -161,7 +194,7 @@ func (h *OIDCHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		tokenPair, err = h.oidcService.ExchangeCode(r.Context(), req.Code, req.CodeVerifier)
+		tokenPair, err = h.oidcService.ExchangeCode(r.Context(), req.ClientID, req.ClientSecret, req.Code, req.CodeVerifier)
 		if err != nil {
 			h.logger.Error("ExchangeCode failed", zap.Error(err))
 			errors.RespondWithError(w, err.(domain.Error))
@@ -176,18 +209,32 @@ func (h *OIDCHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		tokenPair, err = h.oidcService.RefreshToken(r.Context(), req.RefreshToken)
+		tokenPair, err = h.oidcService.RefreshToken(r.Context(), req.ClientID, req.ClientSecret, req.RefreshToken)
 		if err != nil {
 			h.logger.Error("RefreshToken failed", zap.Error(err))
 			switch err {
-			case domain.ErrInvalidCredentials:
-				errors.RespondWithError(w, domain.ErrInvalidCredentials)
+			case domain.ErrInvalidCredentials, domain.ErrInvalidClient:
+				errors.RespondWithError(w, err.(domain.Error))
 			default:
 				errors.RespondWithError(w, domain.ErrInternal)
 			}
 			return
 		}
 
+	case domain.GrantTypeCIBA:
+		if req.AuthReqID == "" {
+			h.logger.Error("Missing auth_req_id")
+			errors.RespondWithError(w, domain.ErrInvalidField)
+			return
+		}
+
+		tokenPair, err = h.cibaService.ExchangeAuthReqID(r.Context(), req.ClientID, req.ClientSecret, req.AuthReqID)
+		if err != nil {
+			h.logger.Debug("ExchangeAuthReqID failed", zap.Error(err))
+			errors.RespondWithError(w, err.(domain.Error))
+			return
+		}
+
 	default:
 		h.logger.Error("Unsupported grant type",
 			zap.String("grant_type", req.GrantType))
@@ -212,6 +259,39 @@ func (h *OIDCHandler) TokenHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// IntrospectHandler tells an authenticated client whether a token issued to it is active
+func (h *OIDCHandler) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	var req IntrospectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	introspection, err := h.oidcService.IntrospectToken(r.Context(), req.ClientID, req.ClientSecret, req.Token)
+	if err != nil {
+		h.logger.Error("Token introspection failed",
+			zap.String("client_id", req.ClientID),
+			zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(introspection); err != nil {
+		h.logger.Error("Failed to encode introspection response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
+
 func (h *OIDCHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
 	clientID := r.URL.Query().Get("client_id")
@@ -221,6 +301,7 @@ func (h *OIDCHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 	responseType := r.URL.Query().Get("response_type")
 	codeChallenge := r.URL.Query().Get("code_challenge")
 	codeChallengeMethod := r.URL.Query().Get("code_challenge_method")
+	claims := r.URL.Query().Get("claims")
 
 	h.logger.Debug("Received authorization request",
 		zap.String("client_id", clientID),
@@ -229,7 +310,8 @@ func (h *OIDCHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 		zap.String("scope", scope),
 		zap.String("response_type", responseType),
 		zap.String("code_challenge", codeChallenge),
-		zap.String("code_challenge_method", codeChallengeMethod))
+		zap.String("code_challenge_method", codeChallengeMethod),
+		zap.String("claims", claims))
 
 	// Validate required parameters
 	if clientID == "" || redirectURI == "" {
@@ -275,10 +357,48 @@ func (h *OIDCHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A sign-in weaker or older than the client asked for sends the user back to sign in again
+	acr := domain.WeakestACR(strings.Fields(r.URL.Query().Get("acr_values")))
+	maxAge := time.Duration(-1)
+	if value := r.URL.Query().Get("max_age"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			h.logger.Error("Invalid max_age", zap.String("max_age", value))
+			errors.RespondWithError(w, domain.ErrInvalidField)
+			return
+		}
+		maxAge = time.Duration(seconds) * time.Second
+	}
+	if acr != "" || maxAge >= 0 {
+		auth, ok := domain.GetAuthentication(r.Context())
+		switch {
+		case !ok:
+			h.reauthenticate(w, userID, acr, maxAge, "The token does not record how the user signed in")
+			return
+		case acr != "" && !auth.Satisfies(acr):
+			h.reauthenticate(w, userID, acr, maxAge, "A stronger authentication is required")
+			return
+		case maxAge >= 0 && time.Since(auth.Time).Truncate(time.Second) > maxAge:
+			h.reauthenticate(w, userID, acr, maxAge, "A more recent authentication is required")
+			return
+		}
+	}
+
 	// Add PKCE parameters to context
 	ctx := domain.WithCodeChallenge(r.Context(), codeChallenge)
 	ctx = domain.WithCodeChallengeMethod(ctx, codeChallengeMethod)
 
+	// Add the OIDC claims request parameter to context
+	if claims != "" {
+		var claimsRequest domain.ClaimsRequest
+		if err := json.Unmarshal([]byte(claims), &claimsRequest); err != nil {
+			h.logger.Error("Invalid claims request", zap.Error(err))
+			errors.RespondWithError(w, domain.ErrInvalidClaimsRequest)
+			return
+		}
+		ctx = domain.WithClaimsRequest(ctx, &claimsRequest)
+	}
+
 	// Generate authorization code
 	code, err := h.oidcService.Authorize(ctx, clientID, redirectURI, state, scope)
 	if err != nil {
@@ -288,7 +408,14 @@ func (h *OIDCHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 			errors.RespondWithError(w, domain.ErrInvalidClient)
 		case domain.ErrInvalidCredentials:
 			errors.RespondWithError(w, domain.ErrUnauthorized)
+		case domain.ErrInvalidScope:
+			errors.RespondWithError(w, domain.ErrInvalidScope)
 		default:
+			// The user is asked to consent to the listed scopes before retrying
+			if consentErr, ok := err.(*domain.ConsentRequiredError); ok {
+				errors.RespondWithError(w, consentErr)
+				return
+			}
 			errors.RespondWithError(w, domain.ErrInternal)
 		}
 		return
@@ -317,3 +444,14 @@ func (h *OIDCHandler) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
 }
+
+// reauthenticate answers an authorization request whose sign-in does not meet its acr_values or
+// max_age with a challenge telling the client what to ask the user for
+func (h *OIDCHandler) reauthenticate(w http.ResponseWriter, userID, acr string, maxAge time.Duration, description string) {
+	h.logger.Info("Re-authentication required",
+		zap.String("user_id", userID),
+		zap.String("acr", acr),
+		zap.Duration("max_age", maxAge),
+		zap.String("reason", description))
+	errors.RespondWithAuthenticationChallenge(w, acr, maxAge, description)
+}
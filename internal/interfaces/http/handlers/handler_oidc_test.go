@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 	"time"
@@ -139,6 +140,21 @@ func (m *MockUserRepository) UpdatePassword(ctx context.Context, userID ulid.ULI
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) UpdateEmail(ctx context.Context, userID ulid.ULID, email string) error {
+	args := m.Called(ctx, userID, email)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdatePhone(ctx context.Context, userID ulid.ULID, phone string) error {
+	args := m.Called(ctx, userID, phone)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) RevokeSessions(ctx context.Context, userID ulid.ULID, before time.Time) error {
+	args := m.Called(ctx, userID, before)
+	return args.Error(0)
+}
+
 type MockOAuth2Service struct {
 	mock.Mock
 }
@@ -156,24 +172,32 @@ func (m *MockOAuth2Service) GenerateAuthorizationCode(ctx context.Context, clien
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockOAuth2Service) ValidateAuthorizationCode(ctx context.Context, code string) (*domain.OAuth2Client, string, []string, error) {
+func (m *MockOAuth2Service) GetClient(ctx context.Context, clientID string) (*domain.OAuth2Client, error) {
+	args := m.Called(ctx, clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OAuth2Client), args.Error(1)
+}
+
+func (m *MockOAuth2Service) ValidateAuthorizationCode(ctx context.Context, code string) (*domain.OAuth2Client, *domain.AuthorizationCode, error) {
 	args := m.Called(ctx, code)
 	if args.Get(0) == nil {
-		return nil, "", nil, args.Error(3)
+		return nil, nil, args.Error(2)
 	}
-	return args.Get(0).(*domain.OAuth2Client), args.String(1), args.Get(2).([]string), args.Error(3)
+	return args.Get(0).(*domain.OAuth2Client), args.Get(1).(*domain.AuthorizationCode), args.Error(2)
 }
 
 type mockOIDCService struct {
 	mock.Mock
 }
 
-func (m *mockOIDCService) GetUserInfo(ctx context.Context, userID string) (*domain.UserInfo, error) {
+func (m *mockOIDCService) GetUserInfo(ctx context.Context, userID string) (domain.UserInfo, error) {
 	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*domain.UserInfo), args.Error(1)
+	return args.Get(0).(domain.UserInfo), args.Error(1)
 }
 
 func (m *mockOIDCService) GetJWKS(ctx context.Context) (map[string]interface{}, error) {
@@ -192,22 +216,35 @@ func (m *mockOIDCService) GetOpenIDConfiguration(ctx context.Context) (map[strin
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
-func (m *mockOIDCService) ExchangeCode(ctx context.Context, code string, codeVerifier string) (*domain.TokenPair, error) {
-	args := m.Called(ctx, code, codeVerifier)
+func (m *mockOIDCService) ExchangeCode(ctx context.Context, clientID, clientSecret, code, codeVerifier string) (*domain.TokenPair, error) {
+	args := m.Called(ctx, clientID, clientSecret, code, codeVerifier)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.TokenPair), args.Error(1)
 }
 
-func (m *mockOIDCService) RefreshToken(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
-	args := m.Called(ctx, refreshToken)
+func (m *mockOIDCService) RefreshToken(ctx context.Context, clientID, clientSecret, refreshToken string) (*domain.TokenPair, error) {
+	args := m.Called(ctx, clientID, clientSecret, refreshToken)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.TokenPair), args.Error(1)
 }
 
+func (m *mockOIDCService) IntrospectToken(ctx context.Context, clientID, clientSecret, token string) (*domain.TokenIntrospection, error) {
+	args := m.Called(ctx, clientID, clientSecret, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TokenIntrospection), args.Error(1)
+}
+
+func (m *mockOIDCService) SignUserInfo(ctx context.Context, userInfo domain.UserInfo) (string, error) {
+	args := m.Called(ctx, userInfo)
+	return args.String(0), args.Error(1)
+}
+
 func (m *mockOIDCService) Authorize(ctx context.Context, clientID, redirectURI, state, scope string) (string, error) {
 	args := m.Called(ctx, clientID, redirectURI, state, scope)
 	return args.String(0), args.Error(1)
@@ -296,7 +333,7 @@ func TestHandleOpenIDConfiguration(t *testing.T) {
 			jwtService := getJWTService()
 
 			// Create handler with mock service
-			handler := NewOIDCHandler(mockService, jwtService, zap.NewNop())
+			handler := NewOIDCHandler(mockService, nil, jwtService, zap.NewNop())
 
 			// Create test request
 			req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
@@ -366,7 +403,7 @@ func TestHandleJWKS(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler := NewOIDCHandler(nil, tt.jwtService, zap.NewNop())
+			handler := NewOIDCHandler(nil, nil, tt.jwtService, zap.NewNop())
 			rec := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
 
@@ -425,6 +462,14 @@ func (m *mockJWTService) GenerateTokenPair(userID ulid.ULID, roles []string) (*d
 	return nil, nil
 }
 
+func (m *mockJWTService) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func (m *mockJWTService) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "", nil
+}
+
 func (m *mockJWTService) BlacklistToken(tokenID string, expiresAt time.Time) error {
 	return nil
 }
@@ -445,7 +490,7 @@ func TestHandleAuthorize(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockService := new(mockOIDCService)
 	jwtService := getJWTService()
-	handler := NewOIDCHandler(mockService, jwtService, logger)
+	handler := NewOIDCHandler(mockService, nil, jwtService, logger)
 
 	tests := []struct {
 		name             string
@@ -656,7 +701,7 @@ func TestHandleToken(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockService := new(mockOIDCService)
 	jwtService := getJWTService()
-	handler := NewOIDCHandler(mockService, jwtService, logger)
+	handler := NewOIDCHandler(mockService, nil, jwtService, logger)
 
 	tests := []struct {
 		name           string
@@ -666,10 +711,8 @@ func TestHandleToken(t *testing.T) {
 		expectedBody   interface{}
 	}{
 		{
-			name: "Missing required fields",
-			requestBody: TokenRequest{
-				GrantType: "authorization_code",
-			},
+			name:        "Missing required fields",
+			requestBody: TokenRequest{},
 			mockSetup: func() {
 				// No mock setup needed for validation error
 			},
@@ -679,16 +722,51 @@ func TestHandleToken(t *testing.T) {
 				Message: "Invalid field",
 				Details: []errors.ErrorDetail{
 					{
-						Field:   "clientID",
-						Message: "clientID is required",
-					},
-					{
-						Field:   "clientSecret",
-						Message: "clientSecret is required",
+						Field:   "grantType",
+						Message: "grantType is required",
 					},
 				},
 			},
 		},
+		{
+			name: "Missing client ID",
+			requestBody: TokenRequest{
+				GrantType:    "authorization_code",
+				Code:         "valid_code",
+				RedirectURI:  "http://localhost:3000/callback",
+				CodeVerifier: "code_verifier_123",
+			},
+			mockSetup: func() {
+				// No mock setup needed
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: errors.ErrorResponse{
+				Code:    domain.ErrInvalidClient.GetCode(),
+				Message: "Invalid client",
+			},
+		},
+		{
+			name: "Public client exchanges a code without a secret",
+			requestBody: TokenRequest{
+				GrantType:    "authorization_code",
+				Code:         "valid_code",
+				RedirectURI:  "http://127.0.0.1:49152/callback",
+				ClientID:     "desktop_app",
+				CodeVerifier: "valid_verifier",
+			},
+			mockSetup: func() {
+				mockService.On("ExchangeCode", mock.Anything, "desktop_app", "", "valid_code", "valid_verifier").
+					Return(&domain.TokenPair{
+						AccessToken:  "access_token_123",
+						RefreshToken: "refresh_token_123",
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: &domain.TokenPair{
+				AccessToken:  "access_token_123",
+				RefreshToken: "refresh_token_123",
+			},
+		},
 		{
 			name: "Invalid grant type",
 			requestBody: TokenRequest{
@@ -738,7 +816,7 @@ func TestHandleToken(t *testing.T) {
 				CodeVerifier: "code_verifier_123",
 			},
 			mockSetup: func() {
-				mockService.On("ExchangeCode", mock.Anything, "invalid_code", "code_verifier_123").
+				mockService.On("ExchangeCode", mock.Anything, "client_id", "client_secret", "invalid_code", "code_verifier_123").
 					Return(nil, domain.ErrInvalidCredentials)
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -758,7 +836,7 @@ func TestHandleToken(t *testing.T) {
 				CodeVerifier: "code_verifier_123",
 			},
 			mockSetup: func() {
-				mockService.On("ExchangeCode", mock.Anything, "valid_code", "code_verifier_123").
+				mockService.On("ExchangeCode", mock.Anything, "invalid_client", "invalid_secret", "valid_code", "code_verifier_123").
 					Return(nil, domain.ErrInvalidClient)
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -778,7 +856,7 @@ func TestHandleToken(t *testing.T) {
 				CodeVerifier: "invalid_verifier",
 			},
 			mockSetup: func() {
-				mockService.On("ExchangeCode", mock.Anything, "valid_code", "invalid_verifier").
+				mockService.On("ExchangeCode", mock.Anything, "client_id", "client_secret", "valid_code", "invalid_verifier").
 					Return(nil, domain.ErrInvalidPKCE)
 			},
 			expectedStatus: http.StatusBadRequest,
@@ -798,7 +876,7 @@ func TestHandleToken(t *testing.T) {
 				CodeVerifier: "valid_verifier",
 			},
 			mockSetup: func() {
-				mockService.On("ExchangeCode", mock.Anything, "valid_code", "valid_verifier").
+				mockService.On("ExchangeCode", mock.Anything, "client_id", "client_secret", "valid_code", "valid_verifier").
 					Return(&domain.TokenPair{
 						AccessToken:  "access_token_123",
 						RefreshToken: "refresh_token_123",
@@ -854,7 +932,7 @@ func TestHandleUserInfo(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockService := new(mockOIDCService)
 	jwtService := getJWTService()
-	handler := NewOIDCHandler(mockService, jwtService, logger)
+	handler := NewOIDCHandler(mockService, nil, jwtService, logger)
 
 	tests := []struct {
 		name           string
@@ -883,13 +961,13 @@ func TestHandleUserInfo(t *testing.T) {
 			userID:     "user123",
 			mockSetup: func() {
 				mockService.On("GetUserInfo", mock.Anything, "user123").
-					Return(&domain.UserInfo{
-						Sub:           "user123",
-						Name:          "Test User",
-						Email:         "test@example.com",
-						EmailVerified: true,
-						AMR:           []string{"pwd"},
+					Return(domain.UserInfo{
+						"sub":            "user123",
+						"name":           "Test User",
+						"email":          "test@example.com",
+						"email_verified": true,
 					}, nil)
+				mockService.On("SignUserInfo", mock.Anything, mock.Anything).Return("", nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody: map[string]interface{}{
@@ -897,9 +975,21 @@ func TestHandleUserInfo(t *testing.T) {
 				"name":           "Test User",
 				"email":          "test@example.com",
 				"email_verified": true,
-				"amr":            []interface{}{"pwd"},
 			},
 		},
+		{
+			name:       "signed user info",
+			authHeader: "",
+			userID:     "user123",
+			mockSetup: func() {
+				mockService.On("GetUserInfo", mock.Anything, "user123").
+					Return(domain.UserInfo{"sub": "user123"}, nil)
+				mockService.On("SignUserInfo", mock.Anything, domain.UserInfo{"sub": "user123"}).
+					Return("signed.userinfo.jwt", nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "signed.userinfo.jwt",
+		},
 		{
 			name:       "internal server error",
 			authHeader: "",
@@ -933,7 +1023,10 @@ func TestHandleUserInfo(t *testing.T) {
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
 
-			if tt.expectedStatus == http.StatusOK {
+			if signed, ok := tt.expectedBody.(string); ok {
+				assert.Equal(t, "application/jwt", rr.Header().Get("Content-Type"))
+				assert.Equal(t, signed, rr.Body.String())
+			} else if tt.expectedStatus == http.StatusOK {
 				var response map[string]interface{}
 				err := json.NewDecoder(rr.Body).Decode(&response)
 				assert.NoError(t, err)
@@ -954,7 +1047,7 @@ func TestOIDCHandler_TokenHandler(t *testing.T) {
 	logger := zap.NewNop()
 	mockService := new(mockOIDCService)
 	jwtService := getJWTService()
-	handler := NewOIDCHandler(mockService, jwtService, logger)
+	handler := NewOIDCHandler(mockService, nil, jwtService, logger)
 
 	tests := []struct {
 		name           string
@@ -974,7 +1067,7 @@ func TestOIDCHandler_TokenHandler(t *testing.T) {
 				CodeVerifier: "code_verifier_123",
 			},
 			mockSetup: func() {
-				mockService.On("ExchangeCode", mock.Anything, "auth_code_123", "code_verifier_123").
+				mockService.On("ExchangeCode", mock.Anything, "client123", "secret123", "auth_code_123", "code_verifier_123").
 					Return(&domain.TokenPair{
 						AccessToken:  "access_token_123",
 						RefreshToken: "refresh_token_123",
@@ -995,7 +1088,26 @@ func TestOIDCHandler_TokenHandler(t *testing.T) {
 				ClientSecret: "secret123",
 			},
 			mockSetup: func() {
-				mockService.On("RefreshToken", mock.Anything, "refresh_token_123").
+				mockService.On("RefreshToken", mock.Anything, "client123", "secret123", "refresh_token_123").
+					Return(&domain.TokenPair{
+						AccessToken:  "new_access_token_123",
+						RefreshToken: "new_refresh_token_123",
+					}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: &domain.TokenPair{
+				AccessToken:  "new_access_token_123",
+				RefreshToken: "new_refresh_token_123",
+			},
+		},
+		{
+			name: "first-party refresh token exchange",
+			requestBody: TokenRequest{
+				GrantType:    "refresh_token",
+				RefreshToken: "first_party_refresh_token",
+			},
+			mockSetup: func() {
+				mockService.On("RefreshToken", mock.Anything, "", "", "first_party_refresh_token").
 					Return(&domain.TokenPair{
 						AccessToken:  "new_access_token_123",
 						RefreshToken: "new_refresh_token_123",
@@ -1037,11 +1149,84 @@ func TestOIDCHandler_TokenHandler(t *testing.T) {
 	}
 }
 
+func TestOIDCHandler_IntrospectHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    interface{}
+		mockSetup      func(*mockOIDCService)
+		expectedStatus int
+		expectedActive bool
+	}{
+		{
+			name:        "active token",
+			requestBody: IntrospectionRequest{Token: "access_token_123", ClientID: "client123", ClientSecret: "secret123"},
+			mockSetup: func(m *mockOIDCService) {
+				m.On("IntrospectToken", mock.Anything, "client123", "secret123", "access_token_123").
+					Return(&domain.TokenIntrospection{Active: true, Subject: "subject", ClientID: "client123"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedActive: true,
+		},
+		{
+			name:        "inactive token",
+			requestBody: IntrospectionRequest{Token: "expired_token", ClientID: "client123", ClientSecret: "secret123"},
+			mockSetup: func(m *mockOIDCService) {
+				m.On("IntrospectToken", mock.Anything, "client123", "secret123", "expired_token").
+					Return(&domain.TokenIntrospection{Active: false}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedActive: false,
+		},
+		{
+			name:        "invalid client",
+			requestBody: IntrospectionRequest{Token: "access_token_123", ClientID: "client123", ClientSecret: "wrong"},
+			mockSetup: func(m *mockOIDCService) {
+				m.On("IntrospectToken", mock.Anything, "client123", "wrong", "access_token_123").
+					Return(nil, domain.ErrInvalidClient)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing token",
+			requestBody:    IntrospectionRequest{ClientID: "client123", ClientSecret: "secret123"},
+			mockSetup:      func(m *mockOIDCService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockOIDCService)
+			tt.mockSetup(mockService)
+			handler := NewOIDCHandler(mockService, nil, nil, zap.NewNop())
+
+			body, err := json.Marshal(tt.requestBody)
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest("POST", "/oauth2/introspect", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			handler.IntrospectHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response map[string]interface{}
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				assert.Equal(t, tt.expectedActive, response["active"])
+				if !tt.expectedActive {
+					assert.Len(t, response, 1)
+				}
+			}
+
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestOIDCHandler_GetOpenIDConfigurationHandler(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockService := new(mockOIDCService)
 	jwtService := getJWTService()
-	handler := NewOIDCHandler(mockService, jwtService, logger)
+	handler := NewOIDCHandler(mockService, nil, jwtService, logger)
 
 	tests := []struct {
 		name           string
@@ -1108,7 +1293,10 @@ func TestOIDCHandler_GetOpenIDConfigurationHandler(t *testing.T) {
 
 			assert.Equal(t, tt.expectedStatus, rr.Code)
 
-			if tt.expectedStatus == http.StatusOK {
+			if signed, ok := tt.expectedBody.(string); ok {
+				assert.Equal(t, "application/jwt", rr.Header().Get("Content-Type"))
+				assert.Equal(t, signed, rr.Body.String())
+			} else if tt.expectedStatus == http.StatusOK {
 				var response map[string]interface{}
 				err := json.NewDecoder(rr.Body).Decode(&response)
 				assert.NoError(t, err)
@@ -1129,7 +1317,7 @@ func TestOIDCHandler_AuthorizeHandler(t *testing.T) {
 	logger, _ := zap.NewProduction()
 	mockService := new(mockOIDCService)
 	jwtService := getJWTService()
-	handler := NewOIDCHandler(mockService, jwtService, logger)
+	handler := NewOIDCHandler(mockService, nil, jwtService, logger)
 
 	tests := []struct {
 		name             string
@@ -1275,6 +1463,53 @@ func TestOIDCHandler_AuthorizeHandler(t *testing.T) {
 				Message: "Invalid PKCE",
 			},
 		},
+		{
+			name: "successful authorization with claims request",
+			queryParams: map[string]string{
+				"client_id":             "client123",
+				"redirect_uri":          "http://localhost:3000/callback",
+				"response_type":         "code",
+				"state":                 "state123",
+				"scope":                 "openid",
+				"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+				"code_challenge_method": "S256",
+				"claims":                `{"userinfo":{"email":{"essential":true}},"id_token":{"name":null}}`,
+			},
+			mockSetup: func() {
+				mockService.On("Authorize", mock.MatchedBy(func(ctx context.Context) bool {
+					claims, ok := domain.GetClaimsRequest(ctx)
+					if !ok {
+						return false
+					}
+					_, hasName := claims.IDToken["name"]
+					return claims.UserInfo["email"].Essential && hasName
+				}), "client123", "http://localhost:3000/callback", "state123", "openid").
+					Return("auth_code_123", nil)
+			},
+			expectedStatus:   http.StatusFound,
+			expectedRedirect: "http://localhost:3000/callback?code=auth_code_123&state=state123",
+		},
+		{
+			name: "malformed claims request",
+			queryParams: map[string]string{
+				"client_id":             "client123",
+				"redirect_uri":          "http://localhost:3000/callback",
+				"response_type":         "code",
+				"state":                 "state123",
+				"scope":                 "openid",
+				"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+				"code_challenge_method": "S256",
+				"claims":                `{"userinfo":`,
+			},
+			mockSetup: func() {
+				// No mock setup needed
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: errors.ErrorResponse{
+				Code:    domain.ErrInvalidClaimsRequest.GetCode(),
+				Message: "Invalid claims request",
+			},
+		},
 		{
 			name: "unsupported code challenge method",
 			queryParams: map[string]string{
@@ -1335,3 +1570,101 @@ func TestOIDCHandler_AuthorizeHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestOIDCHandler_AuthorizeHandler_Reauthentication(t *testing.T) {
+	singleFactor := domain.NewAuthentication(domain.AMRPassword)
+	multiFactor := domain.NewAuthentication(domain.AMRPassword).WithFactor(domain.MFAFactorTOTP)
+	old := &domain.Authentication{Methods: []string{domain.AMRPassword}, Time: time.Now().Add(-time.Hour)}
+
+	tests := []struct {
+		name           string
+		params         map[string]string
+		auth           *domain.Authentication
+		expectedStatus int
+		expectedHeader string
+	}{
+		{
+			name:           "acr satisfied",
+			params:         map[string]string{"acr_values": domain.ACRMultiFactor},
+			auth:           multiFactor,
+			expectedStatus: http.StatusFound,
+		},
+		{
+			name:           "any listed acr satisfies",
+			params:         map[string]string{"acr_values": domain.ACRMultiFactor + " " + domain.ACRSingleFactor},
+			auth:           singleFactor,
+			expectedStatus: http.StatusFound,
+		},
+		{
+			name:           "unknown acr values are ignored",
+			params:         map[string]string{"acr_values": "urn:example:loa:4"},
+			auth:           singleFactor,
+			expectedStatus: http.StatusFound,
+		},
+		{
+			name:           "acr not reached",
+			params:         map[string]string{"acr_values": domain.ACRMultiFactor},
+			auth:           singleFactor,
+			expectedStatus: http.StatusUnauthorized,
+			expectedHeader: `Bearer error="insufficient_user_authentication", error_description="A stronger authentication is required", acr_values="` + domain.ACRMultiFactor + `"`,
+		},
+		{
+			name:           "recent enough sign-in",
+			params:         map[string]string{"max_age": "300"},
+			auth:           singleFactor,
+			expectedStatus: http.StatusFound,
+		},
+		{
+			name:           "sign-in older than max_age",
+			params:         map[string]string{"max_age": "300"},
+			auth:           old,
+			expectedStatus: http.StatusUnauthorized,
+			expectedHeader: `Bearer error="insufficient_user_authentication", error_description="A more recent authentication is required", max_age=300`,
+		},
+		{
+			name:           "token without authentication",
+			params:         map[string]string{"max_age": "300"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedHeader: `Bearer error="insufficient_user_authentication", error_description="The token does not record how the user signed in", max_age=300`,
+		},
+		{
+			name:           "invalid max_age",
+			params:         map[string]string{"max_age": "-1"},
+			auth:           singleFactor,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockOIDCService)
+			if tt.expectedStatus == http.StatusFound {
+				mockService.On("Authorize", mock.Anything, "client123", "http://localhost:3000/callback", "", "openid").Return("auth_code_123", nil)
+			}
+			handler := NewOIDCHandler(mockService, nil, nil, zap.NewNop())
+
+			q := url.Values{
+				"client_id":      {"client123"},
+				"redirect_uri":   {"http://localhost:3000/callback"},
+				"response_type":  {"code"},
+				"scope":          {"openid"},
+				"code_challenge": {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+			}
+			for key, value := range tt.params {
+				q.Set(key, value)
+			}
+			req := httptest.NewRequest("GET", "/oauth2/authorize?"+q.Encode(), nil)
+			ctx := domain.WithSubject(req.Context(), "user123")
+			if tt.auth != nil {
+				ctx = domain.WithAuthentication(ctx, tt.auth)
+			}
+
+			rr := httptest.NewRecorder()
+			handler.AuthorizeHandler(rr, req.WithContext(ctx))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedHeader, rr.Header().Get("WWW-Authenticate"))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// PhoneHandler handles the verification of the signed-in user's phone number
+type PhoneHandler struct {
+	phoneService domain.PhoneService
+	logger       *zap.Logger
+}
+
+// NewPhoneHandler creates a new PhoneHandler
+func NewPhoneHandler(phoneService domain.PhoneService, logger *zap.Logger) *PhoneHandler {
+	return &PhoneHandler{
+		phoneService: phoneService,
+		logger:       logger,
+	}
+}
+
+// PhoneVerificationRequest asks for a code sent to a phone number in international format, by
+// text message, the default, or voice call
+type PhoneVerificationRequest struct {
+	Phone   string `json:"phone" validate:"required"`
+	Channel string `json:"channel" validate:"omitempty,oneof=sms voice"`
+}
+
+// ConfirmPhoneRequest carries the code sent to the phone number being verified
+type ConfirmPhoneRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// RequestVerificationHandler sends a code to the phone number the signed-in user wants as theirs
+func (h *PhoneHandler) RequestVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req PhoneVerificationRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.phoneService.RequestVerification(r.Context(), userID, req.Phone, domain.SMSChannel(req.Channel)); err != nil {
+		h.logger.Debug("failed to request phone verification", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmVerificationHandler makes the phone number the code was sent to the signed-in user's
+// verified phone
+func (h *PhoneHandler) ConfirmVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req ConfirmPhoneRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.phoneService.ConfirmVerification(r.Context(), userID, req.Code); err != nil {
+		h.logger.Debug("failed to confirm phone verification", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type mockPhoneService struct {
+	mock.Mock
+}
+
+func (m *mockPhoneService) RequestVerification(ctx context.Context, userID, phone string, channel domain.SMSChannel) error {
+	args := m.Called(ctx, userID, phone, channel)
+	return args.Error(0)
+}
+
+func (m *mockPhoneService) ConfirmVerification(ctx context.Context, userID, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+
+func (m *mockPhoneService) SendCode(ctx context.Context, user *domain.User, channel domain.SMSChannel) error {
+	args := m.Called(ctx, user, channel)
+	return args.Error(0)
+}
+
+func (m *mockPhoneService) VerifyCode(ctx context.Context, user *domain.User, code string) error {
+	args := m.Called(ctx, user, code)
+	return args.Error(0)
+}
+
+func TestPhoneHandler_RequestVerification(t *testing.T) {
+	userID := ulid.Make().String()
+
+	tests := []struct {
+		name           string
+		body           map[string]string
+		mockSetup      func(*mockPhoneService)
+		expectedStatus int
+	}{
+		{
+			name: "text message",
+			body: map[string]string{"phone": "+14155550123"},
+			mockSetup: func(m *mockPhoneService) {
+				m.On("RequestVerification", mock.Anything, userID, "+14155550123", domain.SMSChannel("")).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "voice call",
+			body: map[string]string{"phone": "+14155550123", "channel": "voice"},
+			mockSetup: func(m *mockPhoneService) {
+				m.On("RequestVerification", mock.Anything, userID, "+14155550123", domain.SMSChannelVoice).Return(nil)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "unknown channel",
+			body:           map[string]string{"phone": "+14155550123", "channel": "fax"},
+			mockSetup:      func(m *mockPhoneService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "invalid number",
+			body: map[string]string{"phone": "4155550123"},
+			mockSetup: func(m *mockPhoneService) {
+				m.On("RequestVerification", mock.Anything, userID, "4155550123", domain.SMSChannel("")).Return(domain.ErrInvalidPhone)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "too many messages",
+			body: map[string]string{"phone": "+14155550123"},
+			mockSetup: func(m *mockPhoneService) {
+				m.On("RequestVerification", mock.Anything, userID, "+14155550123", domain.SMSChannel("")).Return(domain.ErrTooManyAttempts)
+			},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockPhoneService)
+			tt.mockSetup(mockService)
+			handler := NewPhoneHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest("POST", "/users/me/phone", bytes.NewBuffer(body))
+			req = req.WithContext(domain.WithSubject(req.Context(), userID))
+			rr := httptest.NewRecorder()
+			handler.RequestVerificationHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPhoneHandler_ConfirmVerification(t *testing.T) {
+	userID := ulid.Make().String()
+
+	tests := []struct {
+		name           string
+		code           string
+		mockSetup      func(*mockPhoneService)
+		expectedStatus int
+	}{
+		{
+			name: "sent code",
+			code: "123456",
+			mockSetup: func(m *mockPhoneService) {
+				m.On("ConfirmVerification", mock.Anything, userID, "123456").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name: "wrong code",
+			code: "654321",
+			mockSetup: func(m *mockPhoneService) {
+				m.On("ConfirmVerification", mock.Anything, userID, "654321").Return(domain.ErrInvalidVerificationCode)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing code",
+			mockSetup:      func(m *mockPhoneService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockPhoneService)
+			tt.mockSetup(mockService)
+			handler := NewPhoneHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(map[string]string{"code": tt.code})
+			req := httptest.NewRequest("POST", "/users/me/phone/confirm", bytes.NewBuffer(body))
+			req = req.WithContext(domain.WithSubject(req.Context(), userID))
+			rr := httptest.NewRecorder()
+			handler.ConfirmVerificationHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// ScopeRequest represents the request to create/update a scope
+type ScopeRequest struct {
+	Name            string   `json:"name" validate:"required"`
+	Description     string   `json:"description"`
+	Claims          []string `json:"claims"`
+	RequiresConsent bool     `json:"requires_consent"`
+	AllowedClients  []string `json:"allowed_clients"`
+}
+
+// ScopeHandler handles scope registry management
+type ScopeHandler struct {
+	scopeService domain.ScopeService
+	logger       *zap.Logger
+}
+
+// NewScopeHandler creates a new ScopeHandler
+func NewScopeHandler(scopeService domain.ScopeService, logger *zap.Logger) *ScopeHandler {
+	return &ScopeHandler{
+		scopeService: scopeService,
+		logger:       logger,
+	}
+}
+
+// CreateScopeHandler handles the registration of a new scope
+func (h *ScopeHandler) CreateScopeHandler(w http.ResponseWriter, r *http.Request) {
+	var req ScopeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	// Validate request
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return
+	}
+
+	scope := &domain.Scope{
+		Name:            req.Name,
+		Description:     req.Description,
+		Claims:          req.Claims,
+		RequiresConsent: req.RequiresConsent,
+		AllowedClients:  req.AllowedClients,
+	}
+
+	if err := h.scopeService.CreateScope(r.Context(), scope); err != nil {
+		h.logger.Error("Failed to create scope", zap.String("scope", req.Name), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.logger.Info("Scope created successfully", zap.String("scope", scope.Name))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scope)
+}
+
+// UpdateScopeHandler handles updating a registered scope
+func (h *ScopeHandler) UpdateScopeHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if name == "" {
+		h.logger.Error("Missing scope name in URL")
+		errors.RespondWithError(w, domain.ErrPathNotFound)
+		return
+	}
+
+	var req ScopeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	// The scope name is taken from the URL
+	req.Name = name
+
+	scope := &domain.Scope{
+		Name:            req.Name,
+		Description:     req.Description,
+		Claims:          req.Claims,
+		RequiresConsent: req.RequiresConsent,
+		AllowedClients:  req.AllowedClients,
+	}
+
+	if err := h.scopeService.UpdateScope(r.Context(), scope); err != nil {
+		h.logger.Error("Failed to update scope", zap.String("scope", name), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.logger.Info("Scope updated successfully", zap.String("scope", name))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scope)
+}
+
+// DeleteScopeHandler handles removing a scope from the registry
+func (h *ScopeHandler) DeleteScopeHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if name == "" {
+		h.logger.Error("Missing scope name in URL")
+		errors.RespondWithError(w, domain.ErrPathNotFound)
+		return
+	}
+
+	if err := h.scopeService.DeleteScope(r.Context(), name); err != nil {
+		h.logger.Error("Failed to delete scope", zap.String("scope", name), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.logger.Info("Scope deleted successfully", zap.String("scope", name))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListScopesHandler handles listing all registered scopes
+func (h *ScopeHandler) ListScopesHandler(w http.ResponseWriter, r *http.Request) {
+	scopes, err := h.scopeService.ListScopes(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to list scopes", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scopes)
+}
+
+// GetScopeHandler handles getting a single registered scope
+func (h *ScopeHandler) GetScopeHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if name == "" {
+		h.logger.Error("Missing scope name in URL")
+		errors.RespondWithError(w, domain.ErrPathNotFound)
+		return
+	}
+
+	scope, err := h.scopeService.GetScope(r.Context(), name)
+	if err != nil {
+		h.logger.Error("Failed to find scope", zap.String("scope", name), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scope)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// MockScopeService is a mock implementation of domain.ScopeService
+type MockScopeService struct {
+	mock.Mock
+}
+
+func (m *MockScopeService) CreateScope(ctx context.Context, scope *domain.Scope) error {
+	args := m.Called(ctx, scope)
+	return args.Error(0)
+}
+
+func (m *MockScopeService) GetScope(ctx context.Context, name string) (*domain.Scope, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Scope), args.Error(1)
+}
+
+func (m *MockScopeService) ListScopes(ctx context.Context) ([]*domain.Scope, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Scope), args.Error(1)
+}
+
+func (m *MockScopeService) UpdateScope(ctx context.Context, scope *domain.Scope) error {
+	args := m.Called(ctx, scope)
+	return args.Error(0)
+}
+
+func (m *MockScopeService) DeleteScope(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (m *MockScopeService) ValidateScopes(ctx context.Context, clientID string, scopes []string) error {
+	args := m.Called(ctx, clientID, scopes)
+	return args.Error(0)
+}
+
+func (m *MockScopeService) ResolveClaims(ctx context.Context, scopes []string, requested map[string]*domain.ClaimRequest) ([]string, error) {
+	args := m.Called(ctx, scopes, requested)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func withScopeName(req *http.Request, name string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("name", name)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestCreateScopeHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    ScopeRequest
+		mockSetup      func(*MockScopeService)
+		expectedStatus int
+	}{
+		{
+			name: "Success",
+			requestBody: ScopeRequest{
+				Name:           "phone",
+				Description:    "Access to your phone number",
+				Claims:         []string{"phone_number", "phone_number_verified"},
+				AllowedClients: []string{"mobile-app"},
+			},
+			mockSetup: func(m *MockScopeService) {
+				m.On("CreateScope", mock.Anything, mock.MatchedBy(func(scope *domain.Scope) bool {
+					return scope.Name == "phone" &&
+						len(scope.Claims) == 2 &&
+						scope.AllowsClient("mobile-app") &&
+						!scope.AllowsClient("web-app")
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "Missing Name",
+			requestBody:    ScopeRequest{Claims: []string{"email"}},
+			mockSetup:      func(m *MockScopeService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Unsupported Claim",
+			requestBody: ScopeRequest{Name: "address", Claims: []string{"address"}},
+			mockSetup: func(m *MockScopeService) {
+				m.On("CreateScope", mock.Anything, mock.Anything).Return(domain.ErrUnsupportedClaim)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScopeService)
+			tt.mockSetup(mockService)
+			handler := NewScopeHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPost, "/api/oauth2/scopes", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			handler.CreateScopeHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUpdateScopeHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		scopeName      string
+		requestBody    ScopeRequest
+		mockSetup      func(*MockScopeService)
+		expectedStatus int
+	}{
+		{
+			name:        "Success",
+			scopeName:   "profile",
+			requestBody: ScopeRequest{Claims: []string{"name"}, RequiresConsent: true},
+			mockSetup: func(m *MockScopeService) {
+				m.On("UpdateScope", mock.Anything, mock.MatchedBy(func(scope *domain.Scope) bool {
+					return scope.Name == "profile" && scope.RequiresConsent
+				})).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "Not Found",
+			scopeName:   "unknown",
+			requestBody: ScopeRequest{Claims: []string{"name"}},
+			mockSetup: func(m *MockScopeService) {
+				m.On("UpdateScope", mock.Anything, mock.Anything).Return(domain.ErrScopeNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockScopeService)
+			tt.mockSetup(mockService)
+			handler := NewScopeHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPut, "/api/oauth2/scopes/"+tt.scopeName, bytes.NewBuffer(body))
+			req = withScopeName(req, tt.scopeName)
+			w := httptest.NewRecorder()
+
+			handler.UpdateScopeHandler(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteScopeHandler(t *testing.T) {
+	mockService := new(MockScopeService)
+	mockService.On("DeleteScope", mock.Anything, "roles").Return(nil)
+	handler := NewScopeHandler(mockService, zap.NewNop())
+
+	req := withScopeName(httptest.NewRequest(http.MethodDelete, "/api/oauth2/scopes/roles", nil), "roles")
+	w := httptest.NewRecorder()
+
+	handler.DeleteScopeHandler(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestListScopesHandler(t *testing.T) {
+	mockService := new(MockScopeService)
+	mockService.On("ListScopes", mock.Anything).Return([]*domain.Scope{
+		{Name: "email", Claims: []string{"email", "email_verified"}},
+		{Name: "openid", Claims: []string{"sub"}},
+	}, nil)
+	handler := NewScopeHandler(mockService, zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/oauth2/scopes", nil)
+	w := httptest.NewRecorder()
+
+	handler.ListScopesHandler(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var scopes []*domain.Scope
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&scopes))
+	assert.Len(t, scopes, 2)
+	assert.Equal(t, "email", scopes[0].Name)
+	mockService.AssertExpectations(t)
+}
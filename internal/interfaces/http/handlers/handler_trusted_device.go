@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// TrustedDeviceHandler handles the devices users trusted to skip MFA
+type TrustedDeviceHandler struct {
+	deviceService domain.TrustedDeviceService
+	logger        *zap.Logger
+}
+
+// NewTrustedDeviceHandler creates a new TrustedDeviceHandler
+func NewTrustedDeviceHandler(deviceService domain.TrustedDeviceService, logger *zap.Logger) *TrustedDeviceHandler {
+	return &TrustedDeviceHandler{
+		deviceService: deviceService,
+		logger:        logger,
+	}
+}
+
+// ListDevicesHandler lists the signed-in user's trusted devices, newest first
+func (h *TrustedDeviceHandler) ListDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	devices, err := h.deviceService.List(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to list trusted devices", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(devices); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// RevokeDeviceHandler revokes one of the signed-in user's trusted devices
+func (h *TrustedDeviceHandler) RevokeDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	if err := h.deviceService.Revoke(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		h.logger.Debug("failed to revoke trusted device", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllDevicesHandler revokes every trusted device of the signed-in user
+func (h *TrustedDeviceHandler) RevokeAllDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	h.revokeAll(w, r, userID)
+}
+
+// RevokeUserDevicesHandler revokes every trusted device of a user on behalf of an administrator
+func (h *TrustedDeviceHandler) RevokeUserDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	h.revokeAll(w, r, chi.URLParam(r, "id"))
+}
+
+func (h *TrustedDeviceHandler) revokeAll(w http.ResponseWriter, r *http.Request, userID string) {
+	if err := h.deviceService.RevokeAll(r.Context(), userID); err != nil {
+		h.logger.Error("failed to revoke trusted devices", zap.String("user_id", userID), zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
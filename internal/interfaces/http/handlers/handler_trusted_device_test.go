@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockTrustedDeviceService struct {
+	mock.Mock
+}
+
+func (m *mockTrustedDeviceService) Trust(ctx context.Context, user *domain.User, name string) (*domain.TrustedDevice, string, error) {
+	args := m.Called(ctx, user, name)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*domain.TrustedDevice), args.String(1), args.Error(2)
+}
+
+func (m *mockTrustedDeviceService) Verify(ctx context.Context, user *domain.User, token string) bool {
+	args := m.Called(ctx, user, token)
+	return args.Bool(0)
+}
+
+func (m *mockTrustedDeviceService) List(ctx context.Context, userID string) ([]*domain.TrustedDevice, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.TrustedDevice), args.Error(1)
+}
+
+func (m *mockTrustedDeviceService) Revoke(ctx context.Context, userID, deviceID string) error {
+	args := m.Called(ctx, userID, deviceID)
+	return args.Error(0)
+}
+
+func (m *mockTrustedDeviceService) RevokeAll(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func TestTrustedDeviceHandler(t *testing.T) {
+	userID := ulid.Make().String()
+	deviceID := ulid.Make().String()
+
+	serve := func(service *mockTrustedDeviceService, method, path string, handle func(*TrustedDeviceHandler) http.HandlerFunc, params map[string]string) *httptest.ResponseRecorder {
+		handler := NewTrustedDeviceHandler(service, zap.NewNop())
+		req := httptest.NewRequest(method, path, nil)
+		rctx := chi.NewRouteContext()
+		for key, value := range params {
+			rctx.URLParams.Add(key, value)
+		}
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		req = req.WithContext(domain.WithSubject(ctx, userID))
+		rr := httptest.NewRecorder()
+		handle(handler)(rr, req)
+		return rr
+	}
+
+	t.Run("list", func(t *testing.T) {
+		service := new(mockTrustedDeviceService)
+		service.On("List", mock.Anything, userID).Return([]*domain.TrustedDevice{{ID: ulid.Make(), Name: "Laptop", TokenHash: "hash"}}, nil)
+
+		rr := serve(service, http.MethodGet, "/users/me/trusted-devices", func(h *TrustedDeviceHandler) http.HandlerFunc { return h.ListDevicesHandler }, nil)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var devices []map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&devices))
+		require.Len(t, devices, 1)
+		assert.Equal(t, "Laptop", devices[0]["name"])
+		assert.NotContains(t, devices[0], "token_hash")
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		service := new(mockTrustedDeviceService)
+		service.On("Revoke", mock.Anything, userID, deviceID).Return(nil)
+
+		rr := serve(service, http.MethodDelete, "/users/me/trusted-devices/"+deviceID, func(h *TrustedDeviceHandler) http.HandlerFunc { return h.RevokeDeviceHandler }, map[string]string{"id": deviceID})
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("revoke unknown device", func(t *testing.T) {
+		service := new(mockTrustedDeviceService)
+		service.On("Revoke", mock.Anything, userID, deviceID).Return(domain.ErrTrustedDeviceNotFound)
+
+		rr := serve(service, http.MethodDelete, "/users/me/trusted-devices/"+deviceID, func(h *TrustedDeviceHandler) http.HandlerFunc { return h.RevokeDeviceHandler }, map[string]string{"id": deviceID})
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("revoke all", func(t *testing.T) {
+		service := new(mockTrustedDeviceService)
+		service.On("RevokeAll", mock.Anything, userID).Return(nil)
+
+		rr := serve(service, http.MethodDelete, "/users/me/trusted-devices", func(h *TrustedDeviceHandler) http.HandlerFunc { return h.RevokeAllDevicesHandler }, nil)
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("admin revokes a user's devices", func(t *testing.T) {
+		other := ulid.Make().String()
+		service := new(mockTrustedDeviceService)
+		service.On("RevokeAll", mock.Anything, other).Return(nil)
+
+		rr := serve(service, http.MethodDelete, "/users/"+other+"/trusted-devices", func(h *TrustedDeviceHandler) http.HandlerFunc { return h.RevokeUserDevicesHandler }, map[string]string{"id": other})
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		service.AssertExpectations(t)
+	})
+}
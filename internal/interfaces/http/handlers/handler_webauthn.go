@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator/v10"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"go.uber.org/zap"
+)
+
+// WebAuthnHandler handles passkey registration, sign-in and management
+type WebAuthnHandler struct {
+	webAuthnService domain.WebAuthnService
+	logger          *zap.Logger
+}
+
+// NewWebAuthnHandler creates a new WebAuthnHandler
+func NewWebAuthnHandler(webAuthnService domain.WebAuthnService, logger *zap.Logger) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webAuthnService: webAuthnService,
+		logger:          logger,
+	}
+}
+
+type FinishWebAuthnRegistrationRequest struct {
+	Session    string                              `json:"session" validate:"required"`
+	Name       string                              `json:"name" validate:"max=64"`
+	Credential *domain.WebAuthnAttestationResponse `json:"credential" validate:"required"`
+}
+
+type FinishWebAuthnLoginRequest struct {
+	Session    string                            `json:"session" validate:"required"`
+	Credential *domain.WebAuthnAssertionResponse `json:"credential" validate:"required"`
+}
+
+type BeginWebAuthnMFARequest struct {
+	Ticket string `json:"ticket" validate:"required"`
+}
+
+type FinishWebAuthnMFARequest struct {
+	Ticket     string                            `json:"ticket" validate:"required"`
+	Session    string                            `json:"session" validate:"required"`
+	Credential *domain.WebAuthnAssertionResponse `json:"credential" validate:"required"`
+}
+
+type RenameWebAuthnCredentialRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+// BeginRegistrationHandler returns the options for navigator.credentials.create
+func (h *WebAuthnHandler) BeginRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	start, err := h.webAuthnService.BeginRegistration(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to begin passkey registration", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, start)
+}
+
+// FinishRegistrationHandler stores the passkey created by the authenticator
+func (h *WebAuthnHandler) FinishRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req FinishWebAuthnRegistrationRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	credential, err := h.webAuthnService.FinishRegistration(r.Context(), userID, req.Session, req.Name, req.Credential)
+	if err != nil {
+		h.logger.Debug("failed to finish passkey registration", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusCreated, credential)
+}
+
+// BeginLoginHandler returns the options for a passwordless navigator.credentials.get
+func (h *WebAuthnHandler) BeginLoginHandler(w http.ResponseWriter, r *http.Request) {
+	start, err := h.webAuthnService.BeginLogin(r.Context())
+	if err != nil {
+		h.logger.Error("failed to begin passkey login", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, start)
+}
+
+// FinishLoginHandler signs the user in with a passkey assertion
+func (h *WebAuthnHandler) FinishLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req FinishWebAuthnLoginRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	tokenPair, err := h.webAuthnService.FinishLogin(withClientIP(r), req.Session, req.Credential)
+	if err != nil {
+		h.logger.Debug("failed to log in with passkey", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, tokenPair)
+}
+
+// BeginMFAHandler returns the options for verifying a passkey as the second factor of an MFA ticket
+func (h *WebAuthnHandler) BeginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req BeginWebAuthnMFARequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	start, err := h.webAuthnService.BeginMFA(r.Context(), req.Ticket)
+	if err != nil {
+		h.logger.Debug("failed to begin passkey MFA", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, start)
+}
+
+// FinishMFAHandler redeems an MFA ticket with a passkey assertion
+func (h *WebAuthnHandler) FinishMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var req FinishWebAuthnMFARequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	tokenPair, err := h.webAuthnService.FinishMFA(withClientIP(r), req.Ticket, req.Session, req.Credential)
+	if err != nil {
+		h.logger.Debug("failed to verify passkey MFA", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, tokenPair)
+}
+
+// ListCredentialsHandler lists the passkeys of the signed-in user
+func (h *WebAuthnHandler) ListCredentialsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	credentials, err := h.webAuthnService.ListCredentials(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("failed to list passkeys", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	h.respond(w, http.StatusOK, credentials)
+}
+
+// RenameCredentialHandler changes the friendly name of one of the signed-in user's passkeys
+func (h *WebAuthnHandler) RenameCredentialHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req RenameWebAuthnCredentialRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := h.webAuthnService.RenameCredential(r.Context(), userID, chi.URLParam(r, "id"), req.Name); err != nil {
+		h.logger.Debug("failed to rename passkey", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteCredentialHandler removes one of the signed-in user's passkeys
+func (h *WebAuthnHandler) DeleteCredentialHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	if err := h.webAuthnService.DeleteCredential(r.Context(), userID, chi.URLParam(r, "id")); err != nil {
+		h.logger.Debug("failed to delete passkey", zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebAuthnHandler) respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.Error("failed to encode response", zap.Error(err))
+	}
+}
+
+// decodeRequest decodes and validates a JSON request body, responding with the error when it is not valid
+func decodeRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return false
+	}
+
+	var validate = validator.New()
+	if err := validate.Struct(req); err != nil {
+		createErrorMessage(w, err)
+		return false
+	}
+	return true
+}
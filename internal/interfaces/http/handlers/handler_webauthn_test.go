@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/manorfm/authM/internal/domain"
+	"github.com/manorfm/authM/internal/interfaces/http/errors"
+	"github.com/oklog/ulid/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockWebAuthnService struct {
+	mock.Mock
+}
+
+func (m *mockWebAuthnService) BeginRegistration(ctx context.Context, userID string) (*domain.WebAuthnRegistrationStart, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnRegistrationStart), args.Error(1)
+}
+
+func (m *mockWebAuthnService) FinishRegistration(ctx context.Context, userID, session, name string, response *domain.WebAuthnAttestationResponse) (*domain.WebAuthnCredential, error) {
+	args := m.Called(ctx, userID, session, name, response)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnCredential), args.Error(1)
+}
+
+func (m *mockWebAuthnService) BeginLogin(ctx context.Context) (*domain.WebAuthnLoginStart, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnLoginStart), args.Error(1)
+}
+
+func (m *mockWebAuthnService) FinishLogin(ctx context.Context, session string, response *domain.WebAuthnAssertionResponse) (*domain.TokenPair, error) {
+	args := m.Called(ctx, session, response)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func (m *mockWebAuthnService) BeginMFA(ctx context.Context, ticketID string) (*domain.WebAuthnLoginStart, error) {
+	args := m.Called(ctx, ticketID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WebAuthnLoginStart), args.Error(1)
+}
+
+func (m *mockWebAuthnService) FinishMFA(ctx context.Context, ticketID, session string, response *domain.WebAuthnAssertionResponse) (*domain.TokenPair, error) {
+	args := m.Called(ctx, ticketID, session, response)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func (m *mockWebAuthnService) ListCredentials(ctx context.Context, userID string) ([]*domain.WebAuthnCredential, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.WebAuthnCredential), args.Error(1)
+}
+
+func (m *mockWebAuthnService) RenameCredential(ctx context.Context, userID, credentialID, name string) error {
+	args := m.Called(ctx, userID, credentialID, name)
+	return args.Error(0)
+}
+
+func (m *mockWebAuthnService) DeleteCredential(ctx context.Context, userID, credentialID string) error {
+	args := m.Called(ctx, userID, credentialID)
+	return args.Error(0)
+}
+
+// assertionBody is a passkey assertion as serialized by PublicKeyCredential.toJSON
+var assertionBody = map[string]interface{}{
+	"id":    "Y3JlZGVudGlhbA",
+	"rawId": "Y3JlZGVudGlhbA",
+	"type":  "public-key",
+	"response": map[string]string{
+		"clientDataJSON":    "e30",
+		"authenticatorData": "AAAA",
+		"signature":         "MEUCIQ",
+	},
+}
+
+func TestWebAuthnHandler_FinishLogin(t *testing.T) {
+	session := ulid.Make().String()
+
+	tests := []struct {
+		name           string
+		requestBody    map[string]interface{}
+		mockSetup      func(*mockWebAuthnService)
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:        "valid passkey",
+			requestBody: map[string]interface{}{"session": session, "credential": assertionBody},
+			mockSetup: func(m *mockWebAuthnService) {
+				m.On("FinishLogin", mock.Anything, session, mock.MatchedBy(func(r *domain.WebAuthnAssertionResponse) bool {
+					return string(r.RawID) == "credential" && string(r.Response.ClientDataJSON) == "{}"
+				})).Return(&domain.TokenPair{AccessToken: "access_token"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:        "verification failed",
+			requestBody: map[string]interface{}{"session": session, "credential": assertionBody},
+			mockSetup: func(m *mockWebAuthnService) {
+				m.On("FinishLogin", mock.Anything, session, mock.Anything).Return(nil, domain.ErrWebAuthnVerificationFailed)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   domain.ErrWebAuthnVerificationFailed.GetCode(),
+		},
+		{
+			name:           "missing credential",
+			requestBody:    map[string]interface{}{"session": session},
+			mockSetup:      func(m *mockWebAuthnService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "U0011",
+		},
+		{
+			name:           "credential is not base64url",
+			requestBody:    map[string]interface{}{"session": session, "credential": map[string]interface{}{"rawId": "not base64!", "type": "public-key"}},
+			mockSetup:      func(m *mockWebAuthnService) {},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   domain.ErrInvalidRequestBody.GetCode(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(mockWebAuthnService)
+			tt.mockSetup(mockService)
+			handler := NewWebAuthnHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest("POST", "/auth/webauthn/login", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+			handler.FinishLoginHandler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedCode != "" {
+				var response errors.ErrorResponse
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, tt.expectedCode, response.Code)
+			} else {
+				var response domain.TokenPair
+				assert.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+				assert.Equal(t, "access_token", response.AccessToken)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWebAuthnHandler_BeginRegistration(t *testing.T) {
+	userID := ulid.Make().String()
+
+	t.Run("returns creation options", func(t *testing.T) {
+		mockService := new(mockWebAuthnService)
+		mockService.On("BeginRegistration", mock.Anything, userID).Return(&domain.WebAuthnRegistrationStart{
+			Session:   "session",
+			PublicKey: &domain.WebAuthnCreationOptions{Challenge: []byte{0xfb, 0xff}},
+		}, nil)
+		handler := NewWebAuthnHandler(mockService, zap.NewNop())
+
+		req := httptest.NewRequest("POST", "/users/me/webauthn/register/begin", nil)
+		req = req.WithContext(domain.WithSubject(req.Context(), userID))
+		rr := httptest.NewRecorder()
+		handler.BeginRegistrationHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		assert.Equal(t, "session", response["session"])
+		assert.Equal(t, "-_8", response["publicKey"].(map[string]interface{})["challenge"])
+	})
+
+	t.Run("not authenticated", func(t *testing.T) {
+		handler := NewWebAuthnHandler(new(mockWebAuthnService), zap.NewNop())
+
+		req := httptest.NewRequest("POST", "/users/me/webauthn/register/begin", nil)
+		rr := httptest.NewRecorder()
+		handler.BeginRegistrationHandler(rr, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestWebAuthnHandler_ManageCredentials(t *testing.T) {
+	userID := ulid.Make().String()
+	credentialID := ulid.Make().String()
+
+	withCredentialID := func(r *http.Request) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", credentialID)
+		ctx := context.WithValue(domain.WithSubject(r.Context(), userID), chi.RouteCtxKey, rctx)
+		return r.WithContext(ctx)
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		mockService := new(mockWebAuthnService)
+		mockService.On("RenameCredential", mock.Anything, userID, credentialID, "Work laptop").Return(nil)
+		handler := NewWebAuthnHandler(mockService, zap.NewNop())
+
+		body, _ := json.Marshal(map[string]string{"name": "Work laptop"})
+		req := withCredentialID(httptest.NewRequest("PATCH", "/users/me/webauthn/credentials/"+credentialID, bytes.NewBuffer(body)))
+		rr := httptest.NewRecorder()
+		handler.RenameCredentialHandler(rr, req)
+
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("delete passkey of another user", func(t *testing.T) {
+		mockService := new(mockWebAuthnService)
+		mockService.On("DeleteCredential", mock.Anything, userID, credentialID).Return(domain.ErrWebAuthnCredentialNotFound)
+		handler := NewWebAuthnHandler(mockService, zap.NewNop())
+
+		req := withCredentialID(httptest.NewRequest("DELETE", "/users/me/webauthn/credentials/"+credentialID, nil))
+		rr := httptest.NewRecorder()
+		handler.DeleteCredentialHandler(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		mockService := new(mockWebAuthnService)
+		mockService.On("ListCredentials", mock.Anything, userID).Return([]*domain.WebAuthnCredential{
+			{ID: ulid.Make(), CredentialID: []byte("credential"), PublicKey: []byte("secret-ish"), Name: "Phone"},
+		}, nil)
+		handler := NewWebAuthnHandler(mockService, zap.NewNop())
+
+		req := withCredentialID(httptest.NewRequest("GET", "/users/me/webauthn/credentials", nil))
+		rr := httptest.NewRecorder()
+		handler.ListCredentialsHandler(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var response []map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		require.Len(t, response, 1)
+		assert.Equal(t, "Phone", response[0]["name"])
+		assert.Equal(t, "Y3JlZGVudGlhbA", response[0]["credential_id"])
+		assert.NotContains(t, response[0], "public_key")
+	})
+}
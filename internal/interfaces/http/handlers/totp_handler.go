@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"github.com/manorfm/authM/internal/domain"
@@ -23,7 +24,7 @@ func NewTOTPHandler(service domain.TOTPService, logger *zap.Logger) *TOTPHandler
 	}
 }
 
-// EnableTOTP handles the request to enable TOTP for a user
+// EnableTOTP handles the request to enrol an authenticator app for a user; the body may name it
 func (h *TOTPHandler) EnableTOTP(w http.ResponseWriter, r *http.Request) {
 	userID, ok := domain.GetSubject(r.Context())
 	if !ok || userID == "" {
@@ -32,7 +33,25 @@ func (h *TOTPHandler) EnableTOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	totp, err := h.service.EnableTOTP(userID)
+	var req struct {
+		Label string `json:"label"`
+	}
+
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.logger.Error("Failed to decode request body", zap.Error(err))
+			errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+			return
+		}
+	}
+
+	if len(req.Label) > 64 {
+		h.logger.Error("TOTP label too long")
+		errors.RespondWithError(w, domain.ErrInvalidField)
+		return
+	}
+
+	totp, err := h.service.EnableTOTP(userID, req.Label)
 	if err != nil {
 		h.logger.Error("Failed to enable TOTP",
 			zap.String("user_id", userID),
@@ -49,6 +68,48 @@ func (h *TOTPHandler) EnableTOTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ConfirmTOTP handles the request to activate a pending authenticator app with its first code
+func (h *TOTPHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		h.logger.Error("User not authenticated")
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+		return
+	}
+
+	if req.Code == "" {
+		h.logger.Error("Missing TOTP code")
+		errors.RespondWithError(w, domain.ErrInvalidField)
+		return
+	}
+
+	totp, err := h.service.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		h.logger.Error("Failed to confirm TOTP",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(totp); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
+
 // VerifyTOTP handles the request to verify a TOTP code
 func (h *TOTPHandler) VerifyTOTP(w http.ResponseWriter, r *http.Request) {
 	userID, ok := domain.GetSubject(r.Context())
@@ -74,7 +135,7 @@ func (h *TOTPHandler) VerifyTOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.service.VerifyTOTP(userID, req.Code)
+	err := h.service.VerifyUserCode(r.Context(), userID, req.Code, domain.MFAFactorTOTP)
 	if err != nil {
 		h.logger.Error("Failed to verify TOTP code",
 			zap.String("user_id", userID),
@@ -118,7 +179,7 @@ func (h *TOTPHandler) VerifyBackupCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.service.VerifyBackupCode(userID, req.Code)
+	err := h.service.VerifyUserCode(r.Context(), userID, req.Code, domain.MFAFactorRecovery)
 	if err != nil {
 		h.logger.Error("Failed to verify backup code",
 			zap.String("user_id", userID),
@@ -164,3 +225,70 @@ func (h *TOTPHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// BackupCodesStatus handles the request for how many backup codes the user has left
+func (h *TOTPHandler) BackupCodesStatus(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		h.logger.Error("User not authenticated")
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	status, err := h.service.BackupCodesStatus(r.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to get backup codes status",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
+
+// RegenerateBackupCodes handles the request to replace the user's backup codes; the body may carry
+// a code from one of their authenticator apps, which sessions without a recent second factor need
+func (h *TOTPHandler) RegenerateBackupCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := domain.GetSubject(r.Context())
+	if !ok || userID == "" {
+		h.logger.Error("User not authenticated")
+		errors.RespondWithError(w, domain.ErrUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			h.logger.Error("Failed to decode request body", zap.Error(err))
+			errors.RespondWithError(w, domain.ErrInvalidRequestBody)
+			return
+		}
+	}
+
+	backupCodes, err := h.service.RegenerateBackupCodes(r.Context(), userID, req.Code)
+	if err != nil {
+		h.logger.Error("Failed to regenerate backup codes",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string][]string{
+		"backup_codes": backupCodes,
+	}); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		errors.RespondWithError(w, domain.ErrInternal)
+		return
+	}
+}
@@ -19,8 +19,16 @@ type MockTOTPService struct {
 	mock.Mock
 }
 
-func (m *MockTOTPService) EnableTOTP(userID string) (*domain.TOTP, error) {
-	args := m.Called(userID)
+func (m *MockTOTPService) EnableTOTP(userID, label string) (*domain.TOTP, error) {
+	args := m.Called(userID, label)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.TOTP), args.Error(1)
+}
+
+func (m *MockTOTPService) ConfirmTOTP(ctx context.Context, userID, code string) (*domain.TOTP, error) {
+	args := m.Called(ctx, userID, code)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -37,14 +45,40 @@ func (m *MockTOTPService) VerifyBackupCode(userID, code string) error {
 	return args.Error(0)
 }
 
+func (m *MockTOTPService) VerifyUserCode(ctx context.Context, userID, code string, factorTypes ...domain.MFAFactorType) error {
+	args := m.Called(ctx, userID, code, factorTypes)
+	return args.Error(0)
+}
+
+func (m *MockTOTPService) BackupCodesStatus(ctx context.Context, userID string) (*domain.BackupCodesStatus, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.BackupCodesStatus), args.Error(1)
+}
+
+func (m *MockTOTPService) RegenerateBackupCodes(ctx context.Context, userID, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
 func (m *MockTOTPService) DisableTOTP(userID string) error {
 	args := m.Called(userID)
 	return args.Error(0)
 }
 
-func (m *MockTOTPService) GetTOTPSecret(ctx context.Context, userID string) (string, error) {
+func (m *MockTOTPService) VerifyTOTPFactor(ctx context.Context, userID, factorID, code string) error {
+	args := m.Called(ctx, userID, factorID, code)
+	return args.Error(0)
+}
+
+func (m *MockTOTPService) IsTOTPEnabled(ctx context.Context, userID string) (bool, error) {
 	args := m.Called(ctx, userID)
-	return args.String(0), args.Error(1)
+	return args.Bool(0), args.Error(1)
 }
 
 func TestTOTPHandler_EnableTOTP(t *testing.T) {
@@ -59,22 +93,25 @@ func TestTOTPHandler_EnableTOTP(t *testing.T) {
 			name:   "Success",
 			userID: "test-user",
 			mockSetup: func(m *MockTOTPService) {
-				m.On("EnableTOTP", "test-user").Return(
-					&domain.TOTP{QRCode: "test-secret", BackupCodes: []string{"backup1", "backup2"}},
+				m.On("EnableTOTP", "test-user", "").Return(
+					&domain.TOTP{QRCode: "data:image/png;base64,iVBORw0KGgo=", QRCodeSVG: "<svg/>", OTPAuthURI: "otpauth://totp/AuthM:test@example.com"},
 					nil,
 				)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody: map[string]interface{}{
-				"QRCode":      "test-secret",
-				"BackupCodes": []string{"backup1", "backup2"},
+				"FactorID":    "",
+				"QRCode":      "data:image/png;base64,iVBORw0KGgo=",
+				"QRCodeSVG":   "<svg/>",
+				"OTPAuthURI":  "otpauth://totp/AuthM:test@example.com",
+				"BackupCodes": nil,
 			},
 		},
 		{
 			name:   "Already Enabled",
 			userID: "test-user",
 			mockSetup: func(m *MockTOTPService) {
-				m.On("EnableTOTP", "test-user").Return(
+				m.On("EnableTOTP", "test-user", "").Return(
 					nil,
 					domain.ErrTOTPAlreadyEnabled,
 				)
@@ -128,10 +165,10 @@ func TestTOTPHandler_EnableTOTP(t *testing.T) {
 			err := json.Unmarshal(w.Body.Bytes(), &response)
 			assert.NoError(t, err)
 
-			if codes, ok := response["BackupCodes"]; ok {
+			if codes, ok := response["BackupCodes"].([]interface{}); ok {
 				// Convert []interface{} to []string for comparison
-				actualCodes := make([]string, len(codes.([]interface{})))
-				for i, v := range codes.([]interface{}) {
+				actualCodes := make([]string, len(codes))
+				for i, v := range codes {
 					actualCodes[i] = v.(string)
 				}
 				assert.ElementsMatch(t, tt.expectedBody["BackupCodes"].([]string), actualCodes)
@@ -146,6 +183,79 @@ func TestTOTPHandler_EnableTOTP(t *testing.T) {
 	}
 }
 
+func TestTOTPHandler_EnableTOTP_Label(t *testing.T) {
+	mockService := new(MockTOTPService)
+	mockService.On("EnableTOTP", "test-user", "Work phone").Return(&domain.TOTP{QRCode: "test-secret"}, nil)
+	handler := NewTOTPHandler(mockService, zap.NewNop())
+
+	body, _ := json.Marshal(map[string]string{"label": "Work phone"})
+	req := httptest.NewRequest(http.MethodPost, "/totp/enable", bytes.NewBuffer(body))
+	req = req.WithContext(domain.WithSubject(req.Context(), "test-user"))
+	w := httptest.NewRecorder()
+	handler.EnableTOTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestTOTPHandler_ConfirmTOTP(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    map[string]interface{}
+		mockSetup      func(*MockTOTPService)
+		expectedStatus int
+	}{
+		{
+			name:        "Success",
+			requestBody: map[string]interface{}{"code": "123456"},
+			mockSetup: func(m *MockTOTPService) {
+				m.On("ConfirmTOTP", mock.Anything, "test-user", "123456").Return(
+					&domain.TOTP{FactorID: "factor-id", BackupCodes: []string{"backup1", "backup2"}},
+					nil,
+				)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing Code",
+			requestBody:    map[string]interface{}{},
+			mockSetup:      func(m *MockTOTPService) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Nothing Pending",
+			requestBody: map[string]interface{}{"code": "123456"},
+			mockSetup: func(m *MockTOTPService) {
+				m.On("ConfirmTOTP", mock.Anything, "test-user", "123456").Return(nil, domain.ErrTOTPEnrollmentNotFound)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockTOTPService)
+			tt.mockSetup(mockService)
+			handler := NewTOTPHandler(mockService, zap.NewNop())
+
+			body, _ := json.Marshal(tt.requestBody)
+			req := httptest.NewRequest(http.MethodPost, "/totp/enable/confirm", bytes.NewBuffer(body))
+			req = req.WithContext(domain.WithSubject(req.Context(), "test-user"))
+			w := httptest.NewRecorder()
+			handler.ConfirmTOTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response domain.TOTP
+				assert.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+				assert.Equal(t, "factor-id", response.FactorID)
+				assert.Equal(t, []string{"backup1", "backup2"}, response.BackupCodes)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestTOTPHandler_VerifyTOTP(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -162,7 +272,7 @@ func TestTOTPHandler_VerifyTOTP(t *testing.T) {
 				"code": "123456",
 			},
 			mockSetup: func(m *MockTOTPService) {
-				m.On("VerifyTOTP", "test-user", "123456").Return(nil)
+				m.On("VerifyUserCode", mock.Anything, "test-user", "123456", []domain.MFAFactorType{domain.MFAFactorTOTP}).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody: map[string]interface{}{
@@ -176,7 +286,7 @@ func TestTOTPHandler_VerifyTOTP(t *testing.T) {
 				"code": "123456",
 			},
 			mockSetup: func(m *MockTOTPService) {
-				m.On("VerifyTOTP", "test-user", "123456").Return(domain.ErrInvalidTOTPCode)
+				m.On("VerifyUserCode", mock.Anything, "test-user", "123456", []domain.MFAFactorType{domain.MFAFactorTOTP}).Return(domain.ErrInvalidTOTPCode)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
@@ -191,7 +301,7 @@ func TestTOTPHandler_VerifyTOTP(t *testing.T) {
 				"code": "123456",
 			},
 			mockSetup: func(m *MockTOTPService) {
-				m.On("VerifyTOTP", "test-user", "123456").Return(domain.ErrTOTPNotEnabled)
+				m.On("VerifyUserCode", mock.Anything, "test-user", "123456", []domain.MFAFactorType{domain.MFAFactorTOTP}).Return(domain.ErrTOTPNotEnabled)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
@@ -287,7 +397,7 @@ func TestTOTPHandler_VerifyBackupCode(t *testing.T) {
 				"code": "BACKUP123",
 			},
 			mockSetup: func(m *MockTOTPService) {
-				m.On("VerifyBackupCode", "test-user", "BACKUP123").Return(nil)
+				m.On("VerifyUserCode", mock.Anything, "test-user", "BACKUP123", []domain.MFAFactorType{domain.MFAFactorRecovery}).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody: map[string]interface{}{
@@ -301,7 +411,7 @@ func TestTOTPHandler_VerifyBackupCode(t *testing.T) {
 				"code": "BACKUP123",
 			},
 			mockSetup: func(m *MockTOTPService) {
-				m.On("VerifyBackupCode", "test-user", "BACKUP123").Return(domain.ErrInvalidTOTPBackupCode)
+				m.On("VerifyUserCode", mock.Anything, "test-user", "BACKUP123", []domain.MFAFactorType{domain.MFAFactorRecovery}).Return(domain.ErrInvalidTOTPBackupCode)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
@@ -316,7 +426,7 @@ func TestTOTPHandler_VerifyBackupCode(t *testing.T) {
 				"code": "BACKUP123",
 			},
 			mockSetup: func(m *MockTOTPService) {
-				m.On("VerifyBackupCode", "test-user", "BACKUP123").Return(domain.ErrTOTPNotEnabled)
+				m.On("VerifyUserCode", mock.Anything, "test-user", "BACKUP123", []domain.MFAFactorType{domain.MFAFactorRecovery}).Return(domain.ErrTOTPNotEnabled)
 			},
 			expectedStatus: http.StatusBadRequest,
 			expectedBody: map[string]interface{}{
@@ -396,6 +506,124 @@ func TestTOTPHandler_VerifyBackupCode(t *testing.T) {
 	}
 }
 
+func TestTOTPHandler_BackupCodesStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockSetup      func(*MockTOTPService)
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name: "Success",
+			mockSetup: func(m *MockTOTPService) {
+				m.On("BackupCodesStatus", mock.Anything, "test-user").Return(&domain.BackupCodesStatus{Remaining: 3, Total: 10}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"remaining": float64(3),
+				"total":     float64(10),
+			},
+		},
+		{
+			name: "TOTP Not Enabled",
+			mockSetup: func(m *MockTOTPService) {
+				m.On("BackupCodesStatus", mock.Anything, "test-user").Return(nil, domain.ErrTOTPNotEnabled)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"code":    "U0045",
+				"message": "TOTP is not enabled for this user",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockTOTPService)
+			tt.mockSetup(mockService)
+			handler := NewTOTPHandler(mockService, zap.NewNop())
+
+			req := httptest.NewRequest(http.MethodGet, "/totp/backup-codes", nil)
+			req = req.WithContext(domain.WithSubject(req.Context(), "test-user"))
+			w := httptest.NewRecorder()
+			handler.BackupCodesStatus(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedBody, response)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTOTPHandler_RegenerateBackupCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		requestBody    map[string]interface{}
+		mockSetup      func(*MockTOTPService)
+		expectedStatus int
+		expectedBody   map[string]interface{}
+	}{
+		{
+			name:        "Success",
+			requestBody: map[string]interface{}{"code": "123456"},
+			mockSetup: func(m *MockTOTPService) {
+				m.On("RegenerateBackupCodes", mock.Anything, "test-user", "123456").Return([]string{"code1", "code2"}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: map[string]interface{}{
+				"backup_codes": []interface{}{"code1", "code2"},
+			},
+		},
+		{
+			name:        "Invalid Code",
+			requestBody: map[string]interface{}{"code": "123456"},
+			mockSetup: func(m *MockTOTPService) {
+				m.On("RegenerateBackupCodes", mock.Anything, "test-user", "123456").Return(nil, domain.ErrInvalidTOTPCode)
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: map[string]interface{}{
+				"code":    "U0047",
+				"message": "Invalid TOTP code",
+			},
+		},
+		{
+			name:        "Without a code or a recent second factor",
+			requestBody: map[string]interface{}{},
+			mockSetup: func(m *MockTOTPService) {
+				m.On("RegenerateBackupCodes", mock.Anything, "test-user", "").Return(nil, domain.ErrInsufficientUserAuthentication)
+			},
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody: map[string]interface{}{
+				"code":    "U0086",
+				"message": "A stronger or more recent authentication is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := new(MockTOTPService)
+			tt.mockSetup(mockService)
+			handler := NewTOTPHandler(mockService, zap.NewNop())
+
+			body, err := json.Marshal(tt.requestBody)
+			assert.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/totp/backup-codes/regenerate", bytes.NewBuffer(body))
+			req = req.WithContext(domain.WithSubject(req.Context(), "test-user"))
+			w := httptest.NewRecorder()
+			handler.RegenerateBackupCodes(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedBody, response)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
 func TestTOTPHandler_DisableTOTP(t *testing.T) {
 	tests := []struct {
 		name           string
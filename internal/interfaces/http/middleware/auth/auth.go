@@ -3,6 +3,7 @@ package auth
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/manorfm/authM/internal/domain"
 	httperrors "github.com/manorfm/authM/internal/interfaces/http/errors"
@@ -10,12 +11,14 @@ import (
 )
 
 type AuthMiddleware struct {
-	jwt    domain.JWTService
-	logger *zap.Logger
+	jwt      domain.JWTService
+	subjects domain.SubjectResolver
+	sessions domain.SessionValidator
+	logger   *zap.Logger
 }
 
-func NewAuthMiddleware(jwt domain.JWTService, logger *zap.Logger) *AuthMiddleware {
-	return &AuthMiddleware{jwt: jwt, logger: logger}
+func NewAuthMiddleware(jwt domain.JWTService, subjects domain.SubjectResolver, sessions domain.SessionValidator, logger *zap.Logger) *AuthMiddleware {
+	return &AuthMiddleware{jwt: jwt, subjects: subjects, sessions: sessions, logger: logger}
 }
 
 func (m *AuthMiddleware) Authenticator(next http.Handler) http.Handler {
@@ -33,12 +36,59 @@ func (m *AuthMiddleware) Authenticator(next http.Handler) http.Handler {
 			return
 		}
 
+		// ID and refresh tokens and signed userinfo are signed with the same key but are not bearer credentials
+		if !claims.IsAccessToken() {
+			m.logger.Warn("Token is not an access token",
+				zap.String("subject", claims.Subject),
+				zap.String("typ", claims.Type))
+			httperrors.RespondWithError(w, domain.ErrInvalidToken)
+			return
+		}
+
+		// Tokens issued to pairwise clients carry the client's subject identifier instead of the user ID
+		subject := claims.Subject
+		if claims.ClientID != "" {
+			subject, err = m.subjects.ResolveSubject(r.Context(), claims.ClientID, claims.Subject)
+			if err != nil {
+				m.logger.Error("Failed to resolve token subject",
+					zap.String("client_id", claims.ClientID),
+					zap.Error(err))
+				httperrors.RespondWithError(w, err.(domain.Error))
+				return
+			}
+		}
+
+		// Access tokens of revoked sessions, e.g. after a password change, are refused before they expire
+		var issuedAt *time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = &claims.IssuedAt.Time
+		}
+		if err := m.sessions.ValidateSession(r.Context(), subject, issuedAt); err != nil {
+			m.logger.Warn("Token session is no longer valid",
+				zap.String("subject", subject),
+				zap.Error(err))
+			httperrors.RespondWithError(w, err.(domain.Error))
+			return
+		}
+
 		m.logger.Debug("Token validated successfully",
-			zap.String("subject", claims.Subject),
+			zap.String("subject", subject),
 			zap.Strings("roles", claims.Roles))
 
-		ctx := domain.WithSubject(r.Context(), claims.Subject)
+		ctx := domain.WithSubject(r.Context(), subject)
 		ctx = domain.WithRoles(ctx, claims.Roles)
+		if scopes := claims.Scopes(); len(scopes) > 0 {
+			ctx = domain.WithScopes(ctx, scopes)
+		}
+		if claims.ClientID != "" {
+			ctx = domain.WithClientID(ctx, claims.ClientID)
+		}
+		if len(claims.UserInfoClaims) > 0 {
+			ctx = domain.WithUserInfoClaims(ctx, claims.UserInfoClaims)
+		}
+		if auth := claims.Authentication(); auth != nil {
+			ctx = domain.WithAuthentication(ctx, auth)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -64,6 +114,43 @@ func (m *AuthMiddleware) RequireRole(role string) func(next http.Handler) http.H
 	}
 }
 
+// RequireStepUp guards sensitive routes with the step-up challenge of RFC 9470. The token must
+// record a sign-in reaching the acr context class, when set, and no older than maxAge, when set.
+// Otherwise the caller gets an insufficient_user_authentication challenge telling the client what
+// to ask for when sending the user back to sign in. Without either requirement it lets every
+// request through.
+func (m *AuthMiddleware) RequireStepUp(acr string, maxAge time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if acr == "" && maxAge <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, ok := domain.GetAuthentication(r.Context())
+			switch {
+			case !ok:
+				m.challenge(w, r, acr, maxAge, "The token does not record how the user signed in")
+			case acr != "" && !auth.Satisfies(acr):
+				m.challenge(w, r, acr, maxAge, "A stronger authentication is required")
+			case maxAge > 0 && time.Since(auth.Time) > maxAge:
+				m.challenge(w, r, acr, maxAge, "A more recent authentication is required")
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// challenge responds with an RFC 9470 insufficient_user_authentication challenge
+func (m *AuthMiddleware) challenge(w http.ResponseWriter, r *http.Request, acr string, maxAge time.Duration, description string) {
+	subject, _ := domain.GetSubject(r.Context())
+	m.logger.Info("Step-up authentication required",
+		zap.String("subject", subject),
+		zap.String("path", r.URL.Path),
+		zap.String("reason", description))
+
+	httperrors.RespondWithAuthenticationChallenge(w, acr, maxAge, description)
+}
+
 func (m *AuthMiddleware) extractToken(r *http.Request) string {
 	bearToken := r.Header.Get("Authorization")
 	if len(strings.Split(bearToken, " ")) == 2 {
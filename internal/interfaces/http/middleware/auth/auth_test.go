@@ -29,6 +29,14 @@ func (m *MockJWT) GenerateTokenPair(userID ulid.ULID, roles []string) (*domain.T
 	return args.Get(0).(*domain.TokenPair), args.Error(1)
 }
 
+func (m *MockJWT) GenerateTokenPairWithParams(userID ulid.ULID, roles []string, params *domain.TokenParams) (*domain.TokenPair, error) {
+	return m.GenerateTokenPair(userID, roles)
+}
+
+func (m *MockJWT) SignUserInfo(clientID string, userInfo map[string]interface{}) (string, error) {
+	return "", nil
+}
+
 func (m *MockJWT) ValidateToken(token string) (*domain.Claims, error) {
 	args := m.Called(token)
 	if args.Get(0) == nil {
@@ -76,6 +84,31 @@ func (m *MockJWT) GetAccessDuration() time.Duration {
 	return args.Get(0).(time.Duration)
 }
 
+type MockSubjectResolver struct {
+	mock.Mock
+}
+
+func (m *MockSubjectResolver) ResolveSubject(ctx context.Context, clientID, subject string) (string, error) {
+	args := m.Called(ctx, clientID, subject)
+	return args.String(0), args.Error(1)
+}
+
+type MockSessionValidator struct {
+	mock.Mock
+}
+
+func (m *MockSessionValidator) ValidateSession(ctx context.Context, userID string, issuedAt *time.Time) error {
+	args := m.Called(ctx, userID, issuedAt)
+	return args.Error(0)
+}
+
+// newSessionValidator returns a validator accepting every session
+func newSessionValidator() *MockSessionValidator {
+	sessions := new(MockSessionValidator)
+	sessions.On("ValidateSession", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return sessions
+}
+
 func TestAuthMiddleware_Authenticator(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -111,12 +144,45 @@ func TestAuthMiddleware_Authenticator(t *testing.T) {
 						Subject: "test-user",
 					},
 					Roles: []string{"admin"},
+					Type:  domain.TokenTypeAccess,
 				}
 				m.On("ValidateToken", "valid-token").Return(claims, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"message":"success"}`,
 		},
+		{
+			name:  "ID token",
+			token: "id-token",
+			mockSetup: func(m *MockJWT) {
+				claims := &domain.Claims{
+					RegisteredClaims: &jwt.RegisteredClaims{
+						Subject: "test-user",
+					},
+					Roles: []string{"admin"},
+					Type:  domain.TokenTypeID,
+				}
+				m.On("ValidateToken", "id-token").Return(claims, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"code":"U0019","message":"Invalid token"}`,
+		},
+		{
+			name:  "signed userinfo",
+			token: "userinfo",
+			mockSetup: func(m *MockJWT) {
+				claims := &domain.Claims{
+					RegisteredClaims: &jwt.RegisteredClaims{
+						Subject: "test-user",
+					},
+					Roles: []string{"admin"},
+					Type:  domain.TokenTypeUserInfo,
+				}
+				m.On("ValidateToken", "userinfo").Return(claims, nil)
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"code":"U0019","message":"Invalid token"}`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -124,7 +190,7 @@ func TestAuthMiddleware_Authenticator(t *testing.T) {
 			mockJWT := new(MockJWT)
 			tt.mockSetup(mockJWT)
 
-			middleware := NewAuthMiddleware(mockJWT, zap.NewNop())
+			middleware := NewAuthMiddleware(mockJWT, new(MockSubjectResolver), newSessionValidator(), zap.NewNop())
 
 			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
@@ -145,6 +211,89 @@ func TestAuthMiddleware_Authenticator(t *testing.T) {
 	}
 }
 
+func TestAuthMiddleware_Authenticator_ResolvesClientSubject(t *testing.T) {
+	claims := &domain.Claims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			Subject: "pairwise-subject",
+		},
+		Roles:    []string{"user"},
+		ClientID: "shop",
+		Type:     domain.TokenTypeAccess,
+	}
+
+	t.Run("pairwise subject", func(t *testing.T) {
+		mockJWT := new(MockJWT)
+		mockJWT.On("ValidateToken", "client-token").Return(claims, nil)
+		subjects := new(MockSubjectResolver)
+		subjects.On("ResolveSubject", mock.Anything, "shop", "pairwise-subject").Return("user-id", nil)
+		middleware := NewAuthMiddleware(mockJWT, subjects, newSessionValidator(), zap.NewNop())
+
+		var subject string
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject, _ = domain.GetSubject(r.Context())
+			w.WriteHeader(http.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer client-token")
+		w := httptest.NewRecorder()
+		middleware.Authenticator(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user-id", subject)
+	})
+
+	t.Run("unknown subject", func(t *testing.T) {
+		mockJWT := new(MockJWT)
+		mockJWT.On("ValidateToken", "client-token").Return(claims, nil)
+		subjects := new(MockSubjectResolver)
+		subjects.On("ResolveSubject", mock.Anything, "shop", "pairwise-subject").Return("", domain.ErrInvalidToken)
+		middleware := NewAuthMiddleware(mockJWT, subjects, newSessionValidator(), zap.NewNop())
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("handler must not be called")
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer client-token")
+		w := httptest.NewRecorder()
+		middleware.Authenticator(handler).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestAuthMiddleware_Authenticator_RevokedSession(t *testing.T) {
+	issuedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	claims := &domain.Claims{
+		RegisteredClaims: &jwt.RegisteredClaims{
+			Subject:  "user-id",
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+		Roles: []string{"user"},
+		Type:  domain.TokenTypeAccess,
+	}
+	mockJWT := new(MockJWT)
+	mockJWT.On("ValidateToken", "old-token").Return(claims, nil)
+	sessions := new(MockSessionValidator)
+	sessions.On("ValidateSession", mock.Anything, "user-id", mock.MatchedBy(func(at *time.Time) bool {
+		return at != nil && at.Equal(issuedAt)
+	})).Return(domain.ErrInvalidToken)
+	middleware := NewAuthMiddleware(mockJWT, new(MockSubjectResolver), sessions, zap.NewNop())
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be called")
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer old-token")
+	w := httptest.NewRecorder()
+	middleware.Authenticator(handler).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	sessions.AssertExpectations(t)
+}
+
 func TestAuthMiddleware_RequireRole(t *testing.T) {
 	logger := zap.NewNop()
 	tests := []struct {
@@ -179,7 +328,7 @@ func TestAuthMiddleware_RequireRole(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			middleware := NewAuthMiddleware(nil, logger)
+			middleware := NewAuthMiddleware(nil, nil, nil, logger)
 
 			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusOK)
@@ -198,3 +347,81 @@ func TestAuthMiddleware_RequireRole(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthMiddleware_RequireStepUp(t *testing.T) {
+	password := &domain.Authentication{Methods: []string{domain.AMRPassword}, Time: time.Now().Add(-time.Minute)}
+	mfa := &domain.Authentication{Methods: []string{domain.AMRPassword, domain.AMROTP, domain.AMRMultiFactor}, Time: time.Now().Add(-time.Minute)}
+	staleMFA := &domain.Authentication{Methods: mfa.Methods, Time: time.Now().Add(-time.Hour)}
+
+	tests := []struct {
+		name              string
+		acr               string
+		maxAge            time.Duration
+		auth              *domain.Authentication
+		expectedStatus    int
+		expectedChallenge string
+	}{
+		{
+			name:              "token without authentication",
+			maxAge:            15 * time.Minute,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="insufficient_user_authentication", error_description="The token does not record how the user signed in", max_age=900`,
+		},
+		{
+			name:              "single factor where multi-factor is required",
+			acr:               domain.ACRMultiFactor,
+			auth:              password,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="insufficient_user_authentication", error_description="A stronger authentication is required", acr_values="urn:authm:acr:multi-factor"`,
+		},
+		{
+			name:              "sign-in older than the maximum age",
+			acr:               domain.ACRMultiFactor,
+			maxAge:            15 * time.Minute,
+			auth:              staleMFA,
+			expectedStatus:    http.StatusUnauthorized,
+			expectedChallenge: `Bearer error="insufficient_user_authentication", error_description="A more recent authentication is required", acr_values="urn:authm:acr:multi-factor", max_age=900`,
+		},
+		{
+			name:           "recent multi-factor sign-in",
+			acr:            domain.ACRMultiFactor,
+			maxAge:         15 * time.Minute,
+			auth:           mfa,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "route without requirements",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "recent sign-in without a context class requirement",
+			maxAge:         15 * time.Minute,
+			auth:           password,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := NewAuthMiddleware(nil, nil, nil, zap.NewNop())
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("POST", "/", nil)
+			if tt.auth != nil {
+				req = req.WithContext(domain.WithAuthentication(req.Context(), tt.auth))
+			}
+
+			w := httptest.NewRecorder()
+			middleware.RequireStepUp(tt.acr, tt.maxAge)(handler).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Equal(t, tt.expectedChallenge, w.Header().Get("WWW-Authenticate"))
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.JSONEq(t, `{"code":"U0086","message":"A stronger or more recent authentication is required"}`, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package clientip
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// Middleware resolves the IP address of the client behind the reverse proxies the server trusts
+type Middleware struct {
+	proxies []*net.IPNet
+	logger  *zap.Logger
+}
+
+// NewMiddleware creates a middleware trusting the proxies listed in TRUSTED_PROXIES, as IP addresses
+// or CIDR ranges
+func NewMiddleware(cfg *config.Config, logger *zap.Logger) *Middleware {
+	m := &Middleware{logger: logger}
+	for _, proxy := range cfg.TrustedProxies {
+		network, err := parseProxy(proxy)
+		if err != nil {
+			logger.Error("Ignoring invalid trusted proxy",
+				zap.String("proxy", proxy),
+				zap.Error(err))
+			continue
+		}
+		m.proxies = append(m.proxies, network)
+	}
+	return m
+}
+
+// parseProxy parses a trusted proxy given as an IP address or a CIDR range
+func parseProxy(proxy string) (*net.IPNet, error) {
+	if !strings.Contains(proxy, "/") {
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: proxy}
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(proxy)
+	return network, err
+}
+
+// Handler replaces the remote address of requests relayed by a trusted proxy with the client address
+// recorded in X-Forwarded-For. The header is read from the nearest hop back, skipping the trusted
+// proxies, and is ignored on requests from anyone else, since clients can put any address in it.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := m.clientIP(r); ip != "" {
+			_, port, _ := net.SplitHostPort(r.RemoteAddr)
+			r.RemoteAddr = net.JoinHostPort(ip, port)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the forwarded client address, or an empty string when the request did not come
+// through a trusted proxy
+func (m *Middleware) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !m.isTrusted(net.ParseIP(host)) {
+		return ""
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		return ""
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			m.logger.Warn("Invalid address in X-Forwarded-For",
+				zap.String("remote_addr", r.RemoteAddr))
+			return ""
+		}
+		if !m.isTrusted(ip) {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+func (m *Middleware) isTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range m.proxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
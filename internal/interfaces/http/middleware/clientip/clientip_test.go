@@ -0,0 +1,89 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manorfm/authM/internal/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestMiddleware_Handler(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+		expected       string
+	}{
+		{
+			name:         "no trusted proxies ignores the header",
+			remoteAddr:   "198.51.100.7:4321",
+			forwardedFor: []string{"203.0.113.9"},
+			expected:     "198.51.100.7:4321",
+		},
+		{
+			name:           "untrusted peer cannot spoof its address",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "198.51.100.7:4321",
+			forwardedFor:   []string{"203.0.113.9"},
+			expected:       "198.51.100.7:4321",
+		},
+		{
+			name:           "trusted proxy forwards the client address",
+			trustedProxies: []string{"10.0.0.1"},
+			remoteAddr:     "10.0.0.1:4321",
+			forwardedFor:   []string{"203.0.113.9"},
+			expected:       "203.0.113.9:4321",
+		},
+		{
+			name:           "addresses prepended by the client are skipped",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:4321",
+			forwardedFor:   []string{"192.0.2.1, 203.0.113.9", "10.0.0.2"},
+			expected:       "203.0.113.9:4321",
+		},
+		{
+			name:           "trusted proxy without the header",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:4321",
+			expected:       "10.0.0.1:4321",
+		},
+		{
+			name:           "malformed header",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:4321",
+			forwardedFor:   []string{"not-an-ip"},
+			expected:       "10.0.0.1:4321",
+		},
+		{
+			name:           "IPv6 proxy",
+			trustedProxies: []string{"fd00::/8"},
+			remoteAddr:     "[fd00::1]:4321",
+			forwardedFor:   []string{"2001:db8::5"},
+			expected:       "[2001:db8::5]:4321",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMiddleware(&config.Config{TrustedProxies: tt.trustedProxies}, zap.NewNop())
+
+			var remoteAddr string
+			handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				remoteAddr = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.expected, remoteAddr)
+		})
+	}
+}
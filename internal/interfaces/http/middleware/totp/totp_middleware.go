@@ -9,7 +9,8 @@ import (
 	"go.uber.org/zap"
 )
 
-// Middleware handles TOTP verification after password authentication
+// Middleware handles TOTP verification for signed-in users; second factors at sign-in are
+// enforced by the MFA tickets of the login flow
 type Middleware struct {
 	totpService domain.TOTPService
 	logger      *zap.Logger
@@ -23,58 +24,6 @@ func NewMiddleware(totpService domain.TOTPService, logger *zap.Logger) *Middlewa
 	}
 }
 
-// Verifier is the middleware function that verifies TOTP codes
-func (m *Middleware) Verifier(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get user ID from context (set by auth middleware)
-		userID, ok := domain.GetSubject(r.Context())
-		if !ok || userID == "" {
-			m.logger.Error("User not authenticated")
-			errors.RespondWithError(w, domain.ErrUnauthorized)
-			return
-		}
-
-		// Check if TOTP is enabled for the user
-		secret, err := m.totpService.GetTOTPSecret(r.Context(), userID)
-		if err != nil {
-			if err == domain.ErrTOTPNotEnabled {
-				// TOTP not enabled, proceed to next handler
-				next.ServeHTTP(w, r)
-				return
-			}
-			m.logger.Error("Failed to check TOTP status",
-				zap.String("user_id", userID),
-				zap.Error(err))
-			errors.RespondWithError(w, domain.ErrInternal)
-			return
-		}
-
-		// If we got here and have a secret, TOTP is enabled
-		if secret == "" {
-			// TOTP not enabled, proceed to next handler
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		// TOTP is enabled, check if already verified in this session
-		if verified, ok := domain.GetTOTPVerified(r.Context()); ok && verified {
-			// TOTP already verified in this session, proceed
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		// Check if this is a TOTP verification request
-		if r.URL.Path == "/api/totp/verify" || r.URL.Path == "/api/totp/verify-backup" {
-			// Allow TOTP verification endpoints to proceed
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		// TOTP verification required
-		errors.RespondWithError(w, domain.ErrTOTPVerificationRequired)
-	})
-}
-
 // VerificationHandler handles the TOTP verification process
 func (m *Middleware) VerificationHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := domain.GetSubject(r.Context())
@@ -100,26 +49,13 @@ func (m *Middleware) VerificationHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Try TOTP code first
-	err := m.totpService.VerifyTOTP(userID, req.Code)
-	if err != nil {
-		// If TOTP verification fails, try backup code
-		if err == domain.ErrInvalidTOTPCode {
-			err = m.totpService.VerifyBackupCode(userID, req.Code)
-			if err != nil {
-				m.logger.Error("Failed to verify code",
-					zap.String("user_id", userID),
-					zap.Error(err))
-				errors.RespondWithError(w, err.(domain.Error))
-				return
-			}
-		} else {
-			m.logger.Error("Failed to verify TOTP code",
-				zap.String("user_id", userID),
-				zap.Error(err))
-			errors.RespondWithError(w, err.(domain.Error))
-			return
-		}
+	// Try TOTP code first, then backup code
+	if err := m.totpService.VerifyUserCode(r.Context(), userID, req.Code, domain.MFAFactorTOTP, domain.MFAFactorRecovery); err != nil {
+		m.logger.Error("Failed to verify code",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		errors.RespondWithError(w, err.(domain.Error))
+		return
 	}
 
 	// Create new context with TOTP verification flag
@@ -7,14 +7,24 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/manorfm/authM/internal/application"
+	"github.com/manorfm/authM/internal/infrastructure/ciba"
 	"github.com/manorfm/authM/internal/infrastructure/config"
 	"github.com/manorfm/authM/internal/infrastructure/database"
 	"github.com/manorfm/authM/internal/infrastructure/email"
+	"github.com/manorfm/authM/internal/infrastructure/federation"
+	"github.com/manorfm/authM/internal/infrastructure/jwe"
 	"github.com/manorfm/authM/internal/infrastructure/jwt"
+	"github.com/manorfm/authM/internal/infrastructure/ldap"
+	"github.com/manorfm/authM/internal/infrastructure/password"
 	"github.com/manorfm/authM/internal/infrastructure/repository"
+	"github.com/manorfm/authM/internal/infrastructure/secrets"
+	"github.com/manorfm/authM/internal/infrastructure/sector"
+	"github.com/manorfm/authM/internal/infrastructure/sms"
 	"github.com/manorfm/authM/internal/infrastructure/totp"
+	"github.com/manorfm/authM/internal/infrastructure/webauthn"
 	"github.com/manorfm/authM/internal/interfaces/http/handlers"
 	"github.com/manorfm/authM/internal/interfaces/http/middleware/auth"
+	"github.com/manorfm/authM/internal/interfaces/http/middleware/clientip"
 	"github.com/manorfm/authM/internal/interfaces/http/middleware/ratelimit"
 	httptotp "github.com/manorfm/authM/internal/interfaces/http/middleware/totp"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -33,7 +43,6 @@ func NewRouter(
 ) *Router {
 	strategy := jwt.NewCompositeStrategy(cfg, logger)
 	jwtService := jwt.NewJWTService(strategy, cfg, logger)
-	authMiddleware := auth.NewAuthMiddleware(jwtService, logger)
 	rateLimiter := ratelimit.NewRateLimiter(100, 200, 3*time.Minute)
 
 	userRepo := repository.NewUserRepository(db, logger)
@@ -41,25 +50,73 @@ func NewRouter(
 	verificationRepo := repository.NewVerificationCodeRepository(db, logger)
 	totpRepo := repository.NewTOTPRepository(db, logger)
 	mfaTicketRepo := repository.NewMFATicketRepository(db, logger)
+	mfaFactorRepo := repository.NewMFAFactorRepository(db, logger)
+	mfaResetRepo := repository.NewMFAResetRepository(db, logger)
+	trustedDeviceRepo := repository.NewTrustedDeviceRepository(db, logger)
+	loginHistoryRepo := repository.NewLoginHistoryRepository(db, logger)
+	scopeRepo := repository.NewScopeRepository(db, logger)
+	consentRepo := repository.NewConsentRepository(db, logger)
+	pairwiseSubjectRepo := repository.NewPairwiseSubjectRepository(db, logger)
+	cibaRepo := repository.NewBackchannelAuthRepository(db, logger)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(db, logger)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepository(db, logger)
+	webAuthnCredentialRepo := repository.NewWebAuthnCredentialRepository(db, logger)
+	webAuthnChallengeRepo := repository.NewWebAuthnChallengeRepository(db, logger)
+	identityRepo := repository.NewFederatedIdentityRepository(db, logger)
+	federatedStateRepo := repository.NewFederatedLoginStateRepository(db, logger)
 
 	totpGenerator := totp.NewGenerator(logger)
 	emailTemplate := email.NewEmailTemplate(&cfg.SMTP, logger)
+	smsSender := sms.NewSMSService(&cfg.SMS, logger)
+	tokenEncrypter := jwe.NewEncrypter(cfg, logger)
+	sectorVerifier := sector.NewVerifier(logger)
+	backchannelNotifier := ciba.NewNotifier(logger)
+	passwordHasher := password.NewHasher(cfg)
+	passwordDictionary := password.NewDictionary(cfg, logger)
+	breachCorpus := password.NewBreachCorpus(cfg, logger)
+	webAuthnVerifier := webauthn.NewVerifier(cfg, logger)
+	secretCipher := secrets.NewCipher(cfg, logger)
+	identityProviders := federation.NewIdentityProviders(cfg, logger)
 
-	totpService := application.NewTOTPService(totpRepo, totpGenerator, logger)
+	lockoutService := application.NewLockoutService(loginAttemptRepo, userRepo, emailTemplate, cfg, logger)
+	totpService := application.NewTOTPService(totpRepo, mfaFactorRepo, totpGenerator, secretCipher, userRepo, emailTemplate, lockoutService, cfg, logger)
 	userService := application.NewUserService(userRepo, logger)
 	oauth2Service := application.NewOAuth2Service(oauthRepo, logger)
-	authService := application.NewAuthService(userRepo, verificationRepo, jwtService, emailTemplate, totpService, mfaTicketRepo, logger)
-	oidcService := application.NewOIDCService(oauth2Service, jwtService, userRepo, totpService, cfg, logger)
+	scopeService := application.NewScopeService(scopeRepo, logger)
+	consentService := application.NewConsentService(consentRepo, oauth2Service, scopeService, logger)
+	passwordPolicy := application.NewPasswordPolicyService(passwordHistoryRepo, passwordDictionary, breachCorpus, passwordHasher, cfg, logger)
+	phoneService := application.NewPhoneService(userRepo, verificationRepo, smsSender, lockoutService, cfg, logger)
+	mfaService := application.NewMFAService(mfaFactorRepo, webAuthnCredentialRepo, mfaResetRepo, totpService, userRepo, verificationRepo, emailTemplate, phoneService, cfg, logger)
+	mfaPolicy := application.NewMFAPolicyService(mfaService, userRepo, cfg, logger)
+	trustedDeviceService := application.NewTrustedDeviceService(trustedDeviceRepo, cfg, logger)
+	loginHistoryService := application.NewLoginHistoryService(loginHistoryRepo, userRepo, trustedDeviceRepo, emailTemplate, cfg, logger)
+	credentialVerifier := ldap.NewCredentialVerifier(cfg, logger)
+	authService := application.NewAuthService(userRepo, verificationRepo, jwtService, emailTemplate, mfaService, mfaPolicy, totpService, mfaTicketRepo, trustedDeviceService, loginHistoryService, passwordHasher, passwordPolicy, lockoutService, credentialVerifier, identityRepo, cfg, logger)
+	webAuthnService := application.NewWebAuthnService(webAuthnCredentialRepo, webAuthnChallengeRepo, userRepo, mfaTicketRepo, webAuthnVerifier, authService, jwtService, lockoutService, loginHistoryService, cfg, logger)
+	federationService := application.NewFederationService(identityProviders, identityRepo, federatedStateRepo, userRepo, authService, cfg, logger)
+	oidcService := application.NewOIDCService(oauth2Service, jwtService, userRepo, scopeService, consentService, pairwiseSubjectRepo, mfaPolicy, tokenEncrypter, cfg, logger)
+	authMiddleware := auth.NewAuthMiddleware(jwtService, oidcService, userService, logger)
+	cibaService := application.NewCIBAService(oauth2Service, scopeService, cibaRepo, userRepo, oidcService, backchannelNotifier, cfg, logger)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService, logger)
 	userHandler := handlers.NewUserHandler(userService, logger)
-	oidcHandler := handlers.NewOIDCHandler(oidcService, jwtService, logger)
-	oauth2Handler := handlers.NewOAuth2Handler(oauthRepo, logger)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, cibaService, jwtService, logger)
+	oauth2Handler := handlers.NewOAuth2Handler(oauthRepo, sectorVerifier, logger)
+	scopeHandler := handlers.NewScopeHandler(scopeService, logger)
+	consentHandler := handlers.NewConsentHandler(consentService, logger)
+	cibaHandler := handlers.NewCIBAHandler(cibaService, logger)
 	totpHandler := handlers.NewTOTPHandler(totpService, logger)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutService, logger)
+	webAuthnHandler := handlers.NewWebAuthnHandler(webAuthnService, logger)
+	mfaHandler := handlers.NewMFAHandler(mfaService, logger)
+	trustedDeviceHandler := handlers.NewTrustedDeviceHandler(trustedDeviceService, logger)
+	loginHistoryHandler := handlers.NewLoginHistoryHandler(loginHistoryService, logger)
+	phoneHandler := handlers.NewPhoneHandler(phoneService, logger)
+	federationHandler := handlers.NewFederationHandler(federationService, logger)
 
 	// Create router with middleware
-	router := createRouter()
+	router := createRouter(clientip.NewMiddleware(cfg, logger))
 
 	router.Use(rateLimiter.Middleware)
 
@@ -116,63 +173,129 @@ func NewRouter(
 			r.Post("/register", authHandler.RegisterHandler)
 			r.Post("/auth/login", authHandler.LoginHandler)
 			r.Post("/auth/verify-mfa", authHandler.VerifyMFAHandler)
+			r.Post("/auth/verify-mfa/challenge", authHandler.ChallengeMFAHandler)
+			r.Post("/auth/enroll-mfa/totp", authHandler.BeginMFAEnrollmentHandler)
+			r.Post("/auth/enroll-mfa/totp/confirm", authHandler.CompleteMFAEnrollmentHandler)
+			r.Post("/auth/verify-mfa/webauthn/begin", webAuthnHandler.BeginMFAHandler)
+			r.Post("/auth/verify-mfa/webauthn", webAuthnHandler.FinishMFAHandler)
 			r.Post("/auth/verify-email", authHandler.VerifyEmailHandler)
 			r.Post("/auth/request-password-reset", authHandler.RequestPasswordResetHandler)
 			r.Post("/auth/reset-password", authHandler.ResetPasswordHandler)
+			r.Post("/auth/passwordless", authHandler.RequestPasswordlessLoginHandler)
+			r.Post("/auth/passwordless/verify", authHandler.PasswordlessLoginHandler)
+			r.Post("/auth/revoke-sign-in", loginHistoryHandler.RevokeSignInHandler)
+			r.Post("/auth/webauthn/login/begin", webAuthnHandler.BeginLoginHandler)
+			r.Post("/auth/webauthn/login", webAuthnHandler.FinishLoginHandler)
+			r.Get("/auth/federated", federationHandler.ListProvidersHandler)
+			r.Get("/auth/federated/{provider}", federationHandler.BeginLoginHandler)
+			r.Get("/auth/federated/{provider}/callback", federationHandler.CallbackHandler)
 		})
 
 		// OIDC routes
 		r.Group(func(r chi.Router) {
 			r.Get("/.well-known/openid-configuration", oidcHandler.GetOpenIDConfigurationHandler)
 			r.Get("/.well-known/jwks.json", oidcHandler.GetJWKSHandler)
+
+			// Client-authenticated endpoints; CIBA clients have no user session
+			r.Post("/oauth2/token", oidcHandler.TokenHandler)
+			r.Post("/oauth2/introspect", oidcHandler.IntrospectHandler)
+			r.Post("/oauth2/bc-authorize", cibaHandler.BackchannelAuthenticationHandler)
 		})
 
 		// Admin routes
 		r.Group(func(r chi.Router) {
-			r.Use(authMiddleware.Authenticator, authMiddleware.RequireRole("admin"))
+			r.Use(authMiddleware.Authenticator, authMiddleware.RequireRole("admin"), authMiddleware.RequireStepUp(cfg.AdminRequiredACR, 0))
 			r.Get("/users", userHandler.ListUsersHandler)
+			r.Post("/users/{id}/unlock", lockoutHandler.UnlockUserHandler)
+			r.Post("/users/{id}/mfa/reset", mfaHandler.ResetMFAHandler)
+			r.Get("/users/{id}/mfa/resets", mfaHandler.ListResetsHandler)
+			r.Delete("/users/{id}/trusted-devices", trustedDeviceHandler.RevokeUserDevicesHandler)
+
+			// OAuth2 client management routes; registration fetches the URLs a client supplies, such
+			// as its sector identifier and JWKS, and later calls its notification endpoint
 			r.Get("/oauth2/clients", oauth2Handler.ListClientsHandler)
+			r.Post("/oauth2/clients", oauth2Handler.CreateClientHandler)
+			r.Get("/oauth2/clients/{id}", oauth2Handler.GetClientHandler)
+			r.Put("/oauth2/clients/{id}", oauth2Handler.UpdateClientHandler)
+			r.Delete("/oauth2/clients/{id}", oauth2Handler.DeleteClientHandler)
+
+			// Scope registry routes
+			r.Get("/oauth2/scopes", scopeHandler.ListScopesHandler)
+			r.Post("/oauth2/scopes", scopeHandler.CreateScopeHandler)
+			r.Get("/oauth2/scopes/{name}", scopeHandler.GetScopeHandler)
+			r.Put("/oauth2/scopes/{name}", scopeHandler.UpdateScopeHandler)
+			r.Delete("/oauth2/scopes/{name}", scopeHandler.DeleteScopeHandler)
 		})
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
 			r.Use(authMiddleware.Authenticator)
-			//r.Use(totpMiddleware.Verifier)
 
 			// TOTP verification endpoint
 			r.Post("/totp/verify", totpMiddleware.VerificationHandler)
 
 			r.Get("/users/{id}", userHandler.GetUserHandler)
 			r.Put("/users/{id}", userHandler.UpdateUserHandler)
+
+			// Account changes of the signed-in user
+			r.Post("/users/me/email/confirm", authHandler.ConfirmEmailChangeHandler)
+			r.Post("/users/me/phone/confirm", phoneHandler.ConfirmVerificationHandler)
+			r.Get("/users/me/webauthn/credentials", webAuthnHandler.ListCredentialsHandler)
+			r.Patch("/users/me/webauthn/credentials/{id}", webAuthnHandler.RenameCredentialHandler)
+			r.Get("/users/me/mfa/factors", mfaHandler.ListFactorsHandler)
+			r.Post("/users/me/mfa/factors/email", mfaHandler.EnrollEmailOTPHandler)
+			r.Post("/users/me/mfa/factors/sms", mfaHandler.EnrollSMSOTPHandler)
+			r.Patch("/users/me/mfa/factors/{id}", mfaHandler.RenameFactorHandler)
+			r.Get("/users/me/trusted-devices", trustedDeviceHandler.ListDevicesHandler)
+			r.Delete("/users/me/trusted-devices/{id}", trustedDeviceHandler.RevokeDeviceHandler)
+			r.Delete("/users/me/trusted-devices", trustedDeviceHandler.RevokeAllDevicesHandler)
+			r.Get("/users/me/login-history", loginHistoryHandler.ListHistoryHandler)
+			r.Get("/users/me/identities", federationHandler.ListIdentitiesHandler)
+			r.Get("/users/me/consents", consentHandler.ListConsentsHandler)
+			r.Post("/users/me/consents", consentHandler.GrantConsentHandler)
+			r.Delete("/users/me/consents/{client_id}", consentHandler.RevokeConsentHandler)
+
+			// Changes that could take the account over need a recent sign-in
+			r.Group(func(r chi.Router) {
+				r.Use(authMiddleware.RequireStepUp(cfg.StepUpACR, cfg.StepUpMaxAge))
+				r.Post("/users/me/password", authHandler.ChangePasswordHandler)
+				r.Post("/users/me/email", authHandler.ChangeEmailHandler)
+				r.Post("/users/me/phone", phoneHandler.RequestVerificationHandler)
+				r.Post("/users/me/webauthn/register/begin", webAuthnHandler.BeginRegistrationHandler)
+				r.Post("/users/me/webauthn/register", webAuthnHandler.FinishRegistrationHandler)
+				r.Delete("/users/me/webauthn/credentials/{id}", webAuthnHandler.DeleteCredentialHandler)
+				r.Delete("/users/me/mfa/factors/{id}", mfaHandler.DeleteFactorHandler)
+				r.Post("/totp/backup-codes/regenerate", totpHandler.RegenerateBackupCodes)
+				r.Post("/totp/disable", totpHandler.DisableTOTP)
+			})
 			r.Get("/oauth2/authorize", oidcHandler.AuthorizeHandler)
-			r.Post("/oauth2/token", oidcHandler.TokenHandler)
 			r.Get("/oauth2/userinfo", oidcHandler.GetUserInfoHandler)
 
-			// OAuth2 client management routes
-			r.Post("/oauth2/clients", oauth2Handler.CreateClientHandler)
-			r.Get("/oauth2/clients/{id}", oauth2Handler.GetClientHandler)
-			r.Put("/oauth2/clients/{id}", oauth2Handler.UpdateClientHandler)
-			r.Delete("/oauth2/clients/{id}", oauth2Handler.DeleteClientHandler)
+			// Backchannel authentication requests awaiting the user's answer
+			r.Get("/oauth2/bc-authorize/pending", cibaHandler.ListPendingHandler)
+			r.Post("/oauth2/bc-authorize/{auth_req_id}/approve", cibaHandler.ApproveHandler)
+			r.Post("/oauth2/bc-authorize/{auth_req_id}/deny", cibaHandler.DenyHandler)
 
 			// TOTP routes
 			r.Post("/totp/enable", totpHandler.EnableTOTP)
+			r.Post("/totp/enable/confirm", totpHandler.ConfirmTOTP)
 			r.Post("/totp/verify", totpHandler.VerifyTOTP)
 			r.Post("/totp/verify-backup", totpHandler.VerifyBackupCode)
-			r.Post("/totp/disable", totpHandler.DisableTOTP)
+			r.Get("/totp/backup-codes", totpHandler.BackupCodesStatus)
 		})
 	})
 
 	return &Router{router: router, db: db}
 }
 
-func createRouter() *chi.Mux {
+func createRouter(clientIP *clientip.Middleware) *chi.Mux {
 	router := chi.NewRouter()
 
 	// Add middleware
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
+	router.Use(clientIP.Handler)
 	router.Use(middleware.Timeout(60 * time.Second))
 
 	return router
@@ -0,0 +1,4 @@
+-- Remove per-client redirect URI policy columns
+ALTER TABLE oauth2_clients
+DROP COLUMN redirect_uri_patterns,
+DROP COLUMN application_type;
@@ -0,0 +1,6 @@
+-- Remove the claims request from authorization codes
+ALTER TABLE authorization_codes
+DROP COLUMN claims_request;
+
+-- Drop scopes registry
+DROP TABLE IF EXISTS scopes;
@@ -0,0 +1,4 @@
+-- Remove per-client subject identifier type
+ALTER TABLE oauth2_clients
+DROP COLUMN sector_identifier_uri,
+DROP COLUMN subject_type;
@@ -0,0 +1,6 @@
+-- Remove per-client userinfo signing and ID token encryption metadata
+ALTER TABLE oauth2_clients
+DROP COLUMN id_token_encrypted_response_enc,
+DROP COLUMN id_token_encrypted_response_alg,
+DROP COLUMN userinfo_signed_response_alg,
+DROP COLUMN jwks_uri;
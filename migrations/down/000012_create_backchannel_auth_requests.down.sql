@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS backchannel_auth_requests;
+
+ALTER TABLE oauth2_clients
+DROP COLUMN backchannel_client_notification_endpoint,
+DROP COLUMN backchannel_token_delivery_mode;
@@ -0,0 +1,4 @@
+ALTER TABLE mfa_tickets
+DROP COLUMN IF EXISTS attempts;
+
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,20 @@
+-- Keep the oldest authenticator app of each user
+DELETE FROM totp_secrets t
+USING mfa_factors f
+WHERE f.id = t.factor_id
+  AND EXISTS (
+    SELECT 1 FROM totp_secrets o
+    JOIN mfa_factors of ON of.id = o.factor_id
+    WHERE o.user_id = t.user_id
+      AND (of.created_at, of.id) < (f.created_at, f.id)
+  );
+
+ALTER TABLE totp_backup_codes DROP CONSTRAINT IF EXISTS fk_totp_backup_codes_factor;
+ALTER TABLE totp_backup_codes DROP COLUMN IF EXISTS factor_id;
+
+ALTER TABLE totp_secrets DROP CONSTRAINT IF EXISTS fk_totp_secrets_factor;
+ALTER TABLE totp_secrets DROP CONSTRAINT IF EXISTS totp_secrets_pkey;
+ALTER TABLE totp_secrets DROP COLUMN IF EXISTS factor_id;
+ALTER TABLE totp_secrets ADD PRIMARY KEY (user_id);
+
+DROP TABLE IF EXISTS mfa_factors;
@@ -0,0 +1,4 @@
+-- Backup code hashes cannot be reverted, and sealed secrets have to be opened by the application
+-- before they fit the former column again
+ALTER TABLE totp_enrollments ALTER COLUMN secret TYPE VARCHAR(255);
+ALTER TABLE totp_secrets ALTER COLUMN secret TYPE VARCHAR(255);
@@ -0,0 +1,8 @@
+ALTER TABLE totp_enrollments DROP COLUMN IF EXISTS period;
+ALTER TABLE totp_enrollments DROP COLUMN IF EXISTS digits;
+ALTER TABLE totp_enrollments DROP COLUMN IF EXISTS algorithm;
+
+ALTER TABLE totp_secrets DROP COLUMN IF EXISTS last_used_step;
+ALTER TABLE totp_secrets DROP COLUMN IF EXISTS period;
+ALTER TABLE totp_secrets DROP COLUMN IF EXISTS digits;
+ALTER TABLE totp_secrets DROP COLUMN IF EXISTS algorithm;
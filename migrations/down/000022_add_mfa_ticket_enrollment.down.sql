@@ -0,0 +1 @@
+ALTER TABLE mfa_tickets DROP COLUMN IF EXISTS enrollment_required;
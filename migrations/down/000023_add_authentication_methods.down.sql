@@ -0,0 +1,3 @@
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE authorization_codes DROP COLUMN IF EXISTS amr;
+ALTER TABLE mfa_tickets DROP COLUMN IF EXISTS amr;
@@ -0,0 +1,2 @@
+ALTER TABLE mfa_tickets DROP COLUMN IF EXISTS risk_challenge;
+DROP TABLE IF EXISTS login_history;
@@ -0,0 +1,2 @@
+ALTER TABLE backchannel_auth_requests DROP COLUMN IF EXISTS auth_time;
+ALTER TABLE backchannel_auth_requests DROP COLUMN IF EXISTS amr;
@@ -0,0 +1,4 @@
+-- Add per-client redirect URI policy columns
+ALTER TABLE oauth2_clients
+ADD COLUMN application_type VARCHAR(16) NOT NULL DEFAULT 'web',
+ADD COLUMN redirect_uri_patterns TEXT[] NOT NULL DEFAULT '{}';
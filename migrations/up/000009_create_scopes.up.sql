@@ -0,0 +1,23 @@
+-- Create scopes registry
+CREATE TABLE IF NOT EXISTS scopes (
+    name VARCHAR(255) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    claims TEXT[] NOT NULL DEFAULT '{}',
+    requires_consent BOOLEAN NOT NULL DEFAULT FALSE,
+    allowed_clients TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Seed the standard OpenID Connect scopes
+INSERT INTO scopes (name, description, claims) VALUES
+    ('openid', 'Sign in with OpenID Connect', '{sub}'),
+    ('profile', 'Access to your basic profile', '{name,updated_at}'),
+    ('email', 'Access to your email address', '{email,email_verified}'),
+    ('phone', 'Access to your phone number', '{phone_number,phone_number_verified}'),
+    ('roles', 'Access to your roles', '{roles}')
+ON CONFLICT (name) DO NOTHING;
+
+-- Keep the OpenID Connect claims request with the authorization code
+ALTER TABLE authorization_codes
+ADD COLUMN claims_request TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Add per-client subject identifier type
+ALTER TABLE oauth2_clients
+ADD COLUMN subject_type VARCHAR(16) NOT NULL DEFAULT 'public',
+ADD COLUMN sector_identifier_uri TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Add per-client userinfo signing and ID token encryption metadata
+ALTER TABLE oauth2_clients
+ADD COLUMN jwks_uri TEXT NOT NULL DEFAULT '',
+ADD COLUMN userinfo_signed_response_alg VARCHAR(16) NOT NULL DEFAULT '',
+ADD COLUMN id_token_encrypted_response_alg VARCHAR(32) NOT NULL DEFAULT '',
+ADD COLUMN id_token_encrypted_response_enc VARCHAR(32) NOT NULL DEFAULT '';
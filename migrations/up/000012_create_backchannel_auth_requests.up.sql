@@ -0,0 +1,22 @@
+-- Add CIBA token delivery metadata to clients
+ALTER TABLE oauth2_clients
+ADD COLUMN backchannel_token_delivery_mode VARCHAR(8) NOT NULL DEFAULT '',
+ADD COLUMN backchannel_client_notification_endpoint TEXT NOT NULL DEFAULT '';
+
+-- Create backchannel authentication requests table
+CREATE TABLE IF NOT EXISTS backchannel_auth_requests (
+    auth_req_id VARCHAR(26) PRIMARY KEY,
+    client_id VARCHAR(255) NOT NULL REFERENCES oauth2_clients(id) ON DELETE CASCADE,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    binding_message VARCHAR(64) NOT NULL DEFAULT '',
+    client_notification_token TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    poll_interval INTEGER NOT NULL,
+    last_polled_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_backchannel_auth_requests_user_id ON backchannel_auth_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_backchannel_auth_requests_expires_at ON backchannel_auth_requests(expires_at);
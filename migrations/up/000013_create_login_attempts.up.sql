@@ -0,0 +1,13 @@
+-- Track failed password and MFA attempts per user and per client IP
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(128) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    locked_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
+
+-- Cap verification attempts per MFA ticket
+ALTER TABLE mfa_tickets
+ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
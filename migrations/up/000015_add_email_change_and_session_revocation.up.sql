@@ -0,0 +1,5 @@
+-- The address an email change code was sent to, swapped in once the code is confirmed
+ALTER TABLE verification_codes ADD COLUMN IF NOT EXISTS target VARCHAR(255);
+
+-- Refresh tokens issued before this time are no longer accepted
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_revoked_at TIMESTAMP WITH TIME ZONE;
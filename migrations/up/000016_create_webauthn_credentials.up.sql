@@ -0,0 +1,27 @@
+-- Passkeys registered by users
+CREATE TABLE IF NOT EXISTS webauthn_credentials (
+    id VARCHAR(26) PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL UNIQUE,
+    public_key BYTEA NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    transports TEXT[] NOT NULL DEFAULT '{}',
+    aaguid VARCHAR(36) NOT NULL DEFAULT '',
+    name VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+
+-- Single-use challenges of registration, passkey login and MFA ceremonies in progress
+CREATE TABLE IF NOT EXISTS webauthn_challenges (
+    id VARCHAR(26) PRIMARY KEY,
+    ceremony VARCHAR(16) NOT NULL,
+    challenge BYTEA NOT NULL,
+    user_id VARCHAR(26) REFERENCES users(id) ON DELETE CASCADE,
+    mfa_ticket VARCHAR(32),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_challenges_expires_at ON webauthn_challenges(expires_at);
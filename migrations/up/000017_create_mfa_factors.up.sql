@@ -0,0 +1,46 @@
+-- Registry of the second factors enrolled by users; passkeys stay in webauthn_credentials
+CREATE TABLE IF NOT EXISTS mfa_factors (
+    id VARCHAR(26) PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(16) NOT NULL,
+    label VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_factors_user_id ON mfa_factors(user_id);
+
+-- A user has at most one set of backup codes and one email factor
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mfa_factors_single_type ON mfa_factors(user_id, type)
+    WHERE type IN ('recovery', 'email_otp');
+
+-- Register the existing authenticator apps and backup codes as factors
+ALTER TABLE totp_secrets ADD COLUMN IF NOT EXISTS factor_id VARCHAR(26);
+ALTER TABLE totp_backup_codes ADD COLUMN IF NOT EXISTS factor_id VARCHAR(26);
+
+-- The authenticator app reuses the user ID; backup codes change its last character so the two differ
+UPDATE totp_secrets SET factor_id = user_id WHERE factor_id IS NULL;
+UPDATE totp_backup_codes
+SET factor_id = SUBSTRING(user_id FROM 1 FOR 25) || CASE WHEN RIGHT(user_id, 1) = 'R' THEN 'S' ELSE 'R' END
+WHERE factor_id IS NULL;
+
+INSERT INTO mfa_factors (id, user_id, type, label, created_at)
+SELECT factor_id, user_id, 'totp', 'Authenticator app', COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM totp_secrets
+ON CONFLICT (id) DO NOTHING;
+
+INSERT INTO mfa_factors (id, user_id, type, label, created_at)
+SELECT factor_id, user_id, 'recovery', 'Backup codes', COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM totp_backup_codes
+ON CONFLICT (id) DO NOTHING;
+
+-- Authenticator apps are keyed by factor so a user can enrol several
+ALTER TABLE totp_secrets DROP CONSTRAINT IF EXISTS totp_secrets_pkey;
+ALTER TABLE totp_secrets ALTER COLUMN factor_id SET NOT NULL;
+ALTER TABLE totp_secrets ADD PRIMARY KEY (factor_id);
+ALTER TABLE totp_secrets ADD CONSTRAINT fk_totp_secrets_factor
+    FOREIGN KEY (factor_id) REFERENCES mfa_factors(id) ON DELETE CASCADE;
+
+ALTER TABLE totp_backup_codes ALTER COLUMN factor_id SET NOT NULL;
+ALTER TABLE totp_backup_codes ADD CONSTRAINT fk_totp_backup_codes_factor
+    FOREIGN KEY (factor_id) REFERENCES mfa_factors(id) ON DELETE CASCADE;
@@ -0,0 +1,8 @@
+-- Authenticator apps waiting for their first code; a user has at most one, replaced on re-enrolment
+CREATE TABLE IF NOT EXISTS totp_enrollments (
+    user_id VARCHAR(26) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret VARCHAR(255) NOT NULL,
+    label VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
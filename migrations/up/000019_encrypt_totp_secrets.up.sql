@@ -0,0 +1,18 @@
+-- Authenticator app secrets are sealed by the application, which makes them longer than the
+-- plaintext; existing rows are sealed afterwards with `make migrate-secrets`
+ALTER TABLE totp_secrets ALTER COLUMN secret TYPE TEXT;
+ALTER TABLE totp_enrollments ALTER COLUMN secret TYPE TEXT;
+
+-- Backup codes are kept as base64(salt)$base64(sha256(salt || code)); used codes stay blank
+UPDATE totp_backup_codes
+SET codes = jsonb_set(codes, '{Codes}', COALESCE((
+    SELECT jsonb_agg(
+        CASE
+            WHEN code = '' THEN ''
+            ELSE encode(salt, 'base64') || '$' || encode(sha256(salt || convert_to(code, 'UTF8')), 'base64')
+        END
+        ORDER BY position)
+    FROM jsonb_array_elements_text(codes -> 'Codes') WITH ORDINALITY AS entries(code, position),
+        LATERAL (SELECT decode(md5(random()::text || clock_timestamp()::text || position::text), 'hex') AS salt) AS salts
+), '[]'::jsonb))
+WHERE jsonb_typeof(codes -> 'Codes') = 'array';
@@ -0,0 +1,12 @@
+-- Each authenticator app keeps the parameters it was enrolled with; existing apps use the former
+-- fixed SHA1, 6 digits and 30 seconds
+ALTER TABLE totp_secrets ADD COLUMN IF NOT EXISTS algorithm VARCHAR(8) NOT NULL DEFAULT 'SHA1';
+ALTER TABLE totp_secrets ADD COLUMN IF NOT EXISTS digits SMALLINT NOT NULL DEFAULT 6;
+ALTER TABLE totp_secrets ADD COLUMN IF NOT EXISTS period INTEGER NOT NULL DEFAULT 30;
+
+-- Time-step of the last accepted code, so a code cannot be used twice
+ALTER TABLE totp_secrets ADD COLUMN IF NOT EXISTS last_used_step BIGINT;
+
+ALTER TABLE totp_enrollments ADD COLUMN IF NOT EXISTS algorithm VARCHAR(8) NOT NULL DEFAULT 'SHA1';
+ALTER TABLE totp_enrollments ADD COLUMN IF NOT EXISTS digits SMALLINT NOT NULL DEFAULT 6;
+ALTER TABLE totp_enrollments ADD COLUMN IF NOT EXISTS period INTEGER NOT NULL DEFAULT 30;
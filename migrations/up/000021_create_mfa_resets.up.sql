@@ -0,0 +1,14 @@
+-- Audit trail of administrators resetting the MFA of users who lost their factors. Rows are kept
+-- when either user is deleted, so neither ID references users.
+CREATE TABLE IF NOT EXISTS mfa_resets (
+    id VARCHAR(26) PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL,
+    admin_id VARCHAR(26) NOT NULL,
+    verification_method VARCHAR(32) NOT NULL,
+    reason TEXT NOT NULL,
+    removed_factors TEXT[] NOT NULL,
+    client_ip VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_resets_user_id ON mfa_resets(user_id);
@@ -0,0 +1,2 @@
+-- Tickets issued to users the MFA policy requires a factor of, redeemed by enrolling one
+ALTER TABLE mfa_tickets ADD COLUMN IF NOT EXISTS enrollment_required BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+-- How the user signed in, carried into the tokens of an MFA ticket or an authorization code
+ALTER TABLE mfa_tickets ADD COLUMN IF NOT EXISTS amr TEXT[];
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS amr TEXT[];
+ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,13 @@
+-- Devices a user chose to trust after completing MFA on them; only a hash of the device token is kept
+CREATE TABLE IF NOT EXISTS trusted_devices (
+    id VARCHAR(26) PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    client_ip VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_trusted_devices_user_id ON trusted_devices(user_id);
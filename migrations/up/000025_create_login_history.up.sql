@@ -0,0 +1,20 @@
+-- Successful logins of each user with the device they came from. A login from a device the user
+-- never signed in from keeps the hash of the token of the link revoking it.
+CREATE TABLE IF NOT EXISTS login_history (
+    id VARCHAR(26) PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_ip VARCHAR(45),
+    ip_prefix VARCHAR(64) NOT NULL,
+    user_agent VARCHAR(512),
+    device VARCHAR(64) NOT NULL,
+    amr TEXT[],
+    new_device BOOLEAN NOT NULL DEFAULT FALSE,
+    revoke_token_hash VARCHAR(64),
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_history_user_id ON login_history(user_id, created_at DESC);
+
+-- Tickets asking users without a second factor to confirm a risky login with an emailed code
+ALTER TABLE mfa_tickets ADD COLUMN IF NOT EXISTS risk_challenge BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,2 @@
+-- Phone numbers are verified with a code sent to them before they receive sms_otp codes
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified BOOLEAN NOT NULL DEFAULT FALSE;
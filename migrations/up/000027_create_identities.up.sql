@@ -0,0 +1,24 @@
+-- Accounts of users at upstream identity providers, identified by the provider's subject
+CREATE TABLE IF NOT EXISTS identities (
+    id VARCHAR(26) PRIMARY KEY,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_login_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_identities_user_id ON identities(user_id);
+
+-- Sign-ins in progress at upstream providers, keyed by the state sent along
+CREATE TABLE IF NOT EXISTS federated_login_states (
+    id VARCHAR(26) PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    nonce VARCHAR(64) NOT NULL,
+    code_verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_federated_login_states_expires_at ON federated_login_states(expires_at);
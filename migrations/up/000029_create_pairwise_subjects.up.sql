@@ -0,0 +1,8 @@
+-- Pairwise subject identifiers issued in tokens, mapped back to the user they stand for
+CREATE TABLE IF NOT EXISTS pairwise_subjects (
+    sector_identifier VARCHAR(255) NOT NULL,
+    subject VARCHAR(64) NOT NULL,
+    user_id VARCHAR(26) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (sector_identifier, subject)
+);
@@ -0,0 +1,3 @@
+-- How the user signed in when approving a backchannel authentication request, carried into its tokens
+ALTER TABLE backchannel_auth_requests ADD COLUMN IF NOT EXISTS amr TEXT[];
+ALTER TABLE backchannel_auth_requests ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP WITH TIME ZONE;
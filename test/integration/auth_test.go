@@ -2,6 +2,7 @@ package integration
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,8 +14,13 @@ import (
 	"github.com/manorfm/authM/internal/infrastructure/config"
 	"github.com/manorfm/authM/internal/infrastructure/database"
 	"github.com/manorfm/authM/internal/infrastructure/jwt"
+	"github.com/manorfm/authM/internal/infrastructure/password"
 	"github.com/manorfm/authM/internal/infrastructure/repository"
+	"github.com/manorfm/authM/internal/infrastructure/secrets"
+	"github.com/manorfm/authM/internal/infrastructure/sms"
 	"github.com/manorfm/authM/internal/infrastructure/totp"
+	"github.com/manorfm/authM/internal/infrastructure/webauthn"
+	"github.com/manorfm/authM/internal/infrastructure/webauthn/webauthntest"
 	"github.com/oklog/ulid/v2"
 	extotp "github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/assert"
@@ -44,6 +50,51 @@ func (m *MockEmailService) SendPasswordResetEmail(ctx context.Context, email, co
 	return args.Error(0)
 }
 
+func (m *MockEmailService) SendAccountLockedEmail(ctx context.Context, email string, lockedUntil time.Time) error {
+	args := m.Called(ctx, email, lockedUntil)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendEmailChangeEmail(ctx context.Context, email, code string) error {
+	args := m.Called(ctx, email, code)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendEmailChangeNoticeEmail(ctx context.Context, email, newEmail string) error {
+	args := m.Called(ctx, email, newEmail)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendPasswordlessCodeEmail(ctx context.Context, email, code string) error {
+	args := m.Called(ctx, email, code)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendMagicLinkEmail(ctx context.Context, email, link string) error {
+	args := m.Called(ctx, email, link)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendMFACodeEmail(ctx context.Context, email, code string) error {
+	args := m.Called(ctx, email, code)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendBackupCodesLowEmail(ctx context.Context, email string, remaining int) error {
+	args := m.Called(ctx, email, remaining)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendMFAResetEmail(ctx context.Context, email string, resetAt time.Time) error {
+	args := m.Called(ctx, email, resetAt)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendNewSignInEmail(ctx context.Context, email string, event *domain.LoginEvent, revokeLink string) error {
+	args := m.Called(ctx, email, event, revokeLink)
+	return args.Error(0)
+}
+
 func setupTestContainer(t *testing.T) (testcontainers.Container, *config.Config) {
 	ctx := context.Background()
 
@@ -96,13 +147,15 @@ func setupTestContainer(t *testing.T) (testcontainers.Container, *config.Config)
 			roles TEXT[] NOT NULL,
 			email_verified BOOLEAN NOT NULL DEFAULT FALSE,
 			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
-			updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			sessions_revoked_at TIMESTAMP WITH TIME ZONE
 		)`,
 		`CREATE TABLE IF NOT EXISTS verification_codes (
 			id VARCHAR(255) PRIMARY KEY,
 			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
 			code VARCHAR(255) NOT NULL,
 			type VARCHAR(50) NOT NULL,
+			target VARCHAR(255),
 			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
 			created_at TIMESTAMP WITH TIME ZONE NOT NULL
 		)`,
@@ -131,20 +184,89 @@ func setupTestContainer(t *testing.T) (testcontainers.Container, *config.Config)
 		`CREATE INDEX IF NOT EXISTS idx_authorization_codes_client_id ON authorization_codes(client_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_authorization_codes_user_id ON authorization_codes(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_authorization_codes_expires_at ON authorization_codes(expires_at)`,
+		`CREATE TABLE IF NOT EXISTS mfa_factors (
+			id VARCHAR(26) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			type VARCHAR(16) NOT NULL,
+			label VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			last_used_at TIMESTAMP WITH TIME ZONE
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_mfa_factors_single_type ON mfa_factors(user_id, type)
+			WHERE type IN ('recovery', 'email_otp')`,
 		`CREATE TABLE IF NOT EXISTS totp_secrets (
+			factor_id VARCHAR(26) PRIMARY KEY REFERENCES mfa_factors(id) ON DELETE CASCADE,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			secret TEXT NOT NULL,
+			algorithm VARCHAR(8) NOT NULL DEFAULT 'SHA1',
+			digits SMALLINT NOT NULL DEFAULT 6,
+			period INTEGER NOT NULL DEFAULT 30,
+			last_used_step BIGINT
+		)`,
+		`CREATE TABLE IF NOT EXISTS totp_enrollments (
 			user_id VARCHAR(255) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
-			secret VARCHAR(255) NOT NULL
+			secret TEXT NOT NULL,
+			label VARCHAR(64) NOT NULL,
+			algorithm VARCHAR(8) NOT NULL DEFAULT 'SHA1',
+			digits SMALLINT NOT NULL DEFAULT 6,
+			period INTEGER NOT NULL DEFAULT 30,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
 		)`,
 		`CREATE TABLE IF NOT EXISTS totp_backup_codes (
 			user_id VARCHAR(255) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			factor_id VARCHAR(26) NOT NULL REFERENCES mfa_factors(id) ON DELETE CASCADE,
 			codes JSONB NOT NULL
 		)`,
 		`CREATE TABLE IF NOT EXISTS mfa_tickets (
 			id VARCHAR(255) PRIMARY KEY,
 			user_id VARCHAR(255) NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
 			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
 			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
 		)`,
+		`CREATE TABLE IF NOT EXISTS login_attempts (
+			key VARCHAR(128) PRIMARY KEY,
+			failures INTEGER NOT NULL DEFAULT 0,
+			last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			locked_until TIMESTAMP WITH TIME ZONE
+		)`,
+		`CREATE TABLE IF NOT EXISTS password_history (
+			id BIGSERIAL PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			password_hash VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS webauthn_credentials (
+			id VARCHAR(26) PRIMARY KEY,
+			user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			credential_id BYTEA NOT NULL UNIQUE,
+			public_key BYTEA NOT NULL,
+			sign_count BIGINT NOT NULL DEFAULT 0,
+			transports TEXT[] NOT NULL DEFAULT '{}',
+			aaguid VARCHAR(36) NOT NULL DEFAULT '',
+			name VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			last_used_at TIMESTAMP WITH TIME ZONE
+		)`,
+		`CREATE TABLE IF NOT EXISTS mfa_resets (
+			id VARCHAR(26) PRIMARY KEY,
+			user_id VARCHAR(26) NOT NULL,
+			admin_id VARCHAR(26) NOT NULL,
+			verification_method VARCHAR(32) NOT NULL,
+			reason TEXT NOT NULL,
+			removed_factors TEXT[] NOT NULL,
+			client_ip VARCHAR(45),
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS webauthn_challenges (
+			id VARCHAR(26) PRIMARY KEY,
+			ceremony VARCHAR(16) NOT NULL,
+			challenge BYTEA NOT NULL,
+			user_id VARCHAR(255) REFERENCES users(id) ON DELETE CASCADE,
+			mfa_ticket VARCHAR(255),
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+		)`,
 	}
 
 	for _, migration := range migrations {
@@ -176,6 +298,9 @@ func TestAuthService_Integration(t *testing.T) {
 	verificationRepo := repository.NewVerificationCodeRepository(db, logger)
 	mfaTicketRepo := repository.NewMFATicketRepository(db, logger)
 	totpRepo := repository.NewTOTPRepository(db, logger)
+	mfaFactorRepo := repository.NewMFAFactorRepository(db, logger)
+	credentialRepo := repository.NewWebAuthnCredentialRepository(db, logger)
+	mfaResetRepo := repository.NewMFAResetRepository(db, logger)
 
 	// Setup email service (mock)
 	emailSvc := &MockEmailService{}
@@ -191,6 +316,36 @@ func TestAuthService_Integration(t *testing.T) {
 		JWTRefreshDuration: 24 * time.Hour,
 		JWTKeyPath:         filepath.Join(tempDir, "test-key"),
 		RSAKeySize:         2048,
+		LockoutThreshold:   5,
+		LockoutDuration:    15 * time.Minute,
+		LockoutIPThreshold: 20,
+		MFAMaxAttempts:     5,
+		MFAEmailCodeTTL:    10 * time.Minute,
+		TOTPEnrollmentTTL:  10 * time.Minute,
+
+		PasswordHashMemory:      64 * 1024,
+		PasswordHashIterations:  3,
+		PasswordHashParallelism: 2,
+		PasswordMinLength:       8,
+		PasswordMaxLength:       72,
+		PasswordHistorySize:     5,
+		BreachedPasswordPolicy:  "reject",
+
+		WebAuthnRPID:    "localhost",
+		WebAuthnRPName:  "authM",
+		WebAuthnOrigins: []string{"http://localhost:8080"},
+		WebAuthnTimeout: 5 * time.Minute,
+
+		TOTPIssuer:    "AuthM",
+		TOTPAlgorithm: "SHA1",
+		TOTPDigits:    6,
+		TOTPPeriod:    30 * time.Second,
+
+		TOTPBackupCodesCount:     10,
+		TOTPBackupCodesThreshold: 3,
+
+		SecretKeyProvider:   "local",
+		SecretEncryptionKey: base64.StdEncoding.EncodeToString(make([]byte, 32)),
 	}
 	jwtStrategy, err := jwt.NewLocalStrategy(jwtCfg, logger)
 	require.NoError(t, err)
@@ -198,7 +353,14 @@ func TestAuthService_Integration(t *testing.T) {
 
 	// Setup TOTP service
 	totpGenerator := totp.NewGenerator(logger)
-	totpService := application.NewTOTPService(totpRepo, totpGenerator, logger)
+	secretCipher := secrets.NewCipher(jwtCfg, logger)
+	lockoutService := application.NewLockoutService(repository.NewLoginAttemptRepository(db, logger), userRepo, emailSvc, jwtCfg, logger)
+	totpService := application.NewTOTPService(totpRepo, mfaFactorRepo, totpGenerator, secretCipher, userRepo, emailSvc, lockoutService, jwtCfg, logger)
+	phoneService := application.NewPhoneService(userRepo, verificationRepo, sms.NewLogSender("", logger), lockoutService, jwtCfg, logger)
+	mfaService := application.NewMFAService(mfaFactorRepo, credentialRepo, mfaResetRepo, totpService, userRepo, verificationRepo, emailSvc, phoneService, jwtCfg, logger)
+
+	trustedDeviceRepo := repository.NewTrustedDeviceRepository(db, logger)
+	loginHistory := application.NewLoginHistoryService(repository.NewLoginHistoryRepository(db, logger), userRepo, trustedDeviceRepo, emailSvc, jwtCfg, logger)
 
 	// Setup auth service
 	authService := application.NewAuthService(
@@ -206,8 +368,32 @@ func TestAuthService_Integration(t *testing.T) {
 		verificationRepo,
 		jwtService,
 		emailSvc,
+		mfaService,
+		application.NewMFAPolicyService(mfaService, userRepo, jwtCfg, logger),
 		totpService,
 		mfaTicketRepo,
+		application.NewTrustedDeviceService(trustedDeviceRepo, jwtCfg, logger),
+		loginHistory,
+		password.NewHasher(jwtCfg),
+		application.NewPasswordPolicyService(repository.NewPasswordHistoryRepository(db, logger), password.NewDictionary(jwtCfg, logger), password.NewBreachCorpus(jwtCfg, logger), password.NewHasher(jwtCfg), jwtCfg, logger),
+		lockoutService,
+		nil,
+		nil,
+		jwtCfg,
+		logger,
+	)
+
+	webAuthnService := application.NewWebAuthnService(
+		credentialRepo,
+		repository.NewWebAuthnChallengeRepository(db, logger),
+		userRepo,
+		mfaTicketRepo,
+		webauthn.NewVerifier(jwtCfg, logger),
+		authService,
+		jwtService,
+		lockoutService,
+		loginHistory,
+		jwtCfg,
 		logger,
 	)
 
@@ -225,14 +411,14 @@ func TestAuthService_Integration(t *testing.T) {
 
 	t.Run("Register and Login Flow", func(t *testing.T) {
 		// Register a new user
-		user, err := authService.Register(ctx, "Test User", "test@example.com", "password123", "1234567890")
+		user, err := authService.Register(ctx, "Test User", "test@example.com", "Correct-Horse-7", "1234567890")
 		require.NoError(t, err)
 		assert.NotNil(t, user)
 		assert.Equal(t, "test@example.com", user.Email)
 		assert.False(t, user.EmailVerified)
 
 		// Try to login before email verification
-		_, err = authService.Login(ctx, "test@example.com", "password123")
+		_, err = authService.Login(ctx, "test@example.com", "Correct-Horse-7")
 		assert.ErrorIs(t, err, domain.ErrEmailNotVerified)
 
 		// Verify email using the code from the mock
@@ -240,7 +426,7 @@ func TestAuthService_Integration(t *testing.T) {
 		require.NoError(t, err)
 
 		// Login after email verification
-		result, err := authService.Login(ctx, "test@example.com", "password123")
+		result, err := authService.Login(ctx, "test@example.com", "Correct-Horse-7")
 		require.NoError(t, err)
 		assert.NotNil(t, result)
 
@@ -252,7 +438,7 @@ func TestAuthService_Integration(t *testing.T) {
 
 	t.Run("Password Reset Flow", func(t *testing.T) {
 		// Create a new user for password reset test
-		user, err := authService.Register(ctx, "Reset User", "reset@example.com", "oldpassword", "9876543210")
+		user, err := authService.Register(ctx, "Reset User", "reset@example.com", "Old-Secret-42", "9876543210")
 		require.NoError(t, err)
 		assert.NotNil(t, user)
 
@@ -265,11 +451,11 @@ func TestAuthService_Integration(t *testing.T) {
 		require.NoError(t, err)
 
 		// Reset password using the code from the mock
-		err = authService.ResetPassword(ctx, "reset@example.com", emailSvc.resetCode, "newpassword")
+		err = authService.ResetPassword(ctx, "reset@example.com", emailSvc.resetCode, "New-Secret-42")
 		require.NoError(t, err)
 
 		// Try to login with new password
-		result, err := authService.Login(ctx, "reset@example.com", "newpassword")
+		result, err := authService.Login(ctx, "reset@example.com", "New-Secret-42")
 		require.NoError(t, err)
 		assert.NotNil(t, result)
 
@@ -279,9 +465,31 @@ func TestAuthService_Integration(t *testing.T) {
 		assert.NotEmpty(t, tokens.RefreshToken)
 	})
 
+	t.Run("Change Password Flow", func(t *testing.T) {
+		user, err := authService.Register(ctx, "Change User", "change@example.com", "Old-Secret-42", "5555555555")
+		require.NoError(t, err)
+		err = authService.VerifyEmail(ctx, "change@example.com", emailSvc.verificationCode)
+		require.NoError(t, err)
+
+		// The current password is required
+		_, err = authService.ChangePassword(ctx, user.ID.String(), "Wrong-Secret-42", "New-Secret-42")
+		assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+		tokens, err := authService.ChangePassword(ctx, user.ID.String(), "Old-Secret-42", "New-Secret-42")
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+
+		updated, err := userRepo.FindByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, updated.SessionsRevokedAt)
+
+		_, err = authService.Login(ctx, "change@example.com", "New-Secret-42")
+		require.NoError(t, err)
+	})
+
 	t.Run("Invalid Login Attempts", func(t *testing.T) {
 		// Create a new user for invalid login test
-		user, err := authService.Register(ctx, "Invalid Login User", "invalid@example.com", "password123", "5555555555")
+		user, err := authService.Register(ctx, "Invalid Login User", "invalid@example.com", "Correct-Horse-7", "5555555555")
 		require.NoError(t, err)
 		assert.NotNil(t, user)
 
@@ -296,7 +504,7 @@ func TestAuthService_Integration(t *testing.T) {
 
 	t.Run("Login with TOTP Flow", func(t *testing.T) {
 		// Create a test user
-		user, err := authService.Register(ctx, "TOTP User", "totp@example.com", "password123", "1234567890")
+		user, err := authService.Register(ctx, "TOTP User", "totp@example.com", "Correct-Horse-7", "1234567890")
 		require.NoError(t, err)
 		assert.NotNil(t, user)
 
@@ -304,26 +512,35 @@ func TestAuthService_Integration(t *testing.T) {
 		err = authService.VerifyEmail(ctx, "totp@example.com", emailSvc.verificationCode)
 		require.NoError(t, err)
 
-		// Enable TOTP
-		totp, err := totpService.EnableTOTP(user.ID.String())
+		// Start enrolling TOTP
+		pending, err := totpService.EnableTOTP(user.ID.String(), "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, pending.QRCode)
+		assert.Empty(t, pending.BackupCodes)
+
+		// An unconfirmed enrolment does not lock the user out
+		result, err := authService.Login(ctx, "totp@example.com", "Correct-Horse-7")
+		require.NoError(t, err)
+		assert.IsType(t, &domain.TokenPair{}, result)
+
+		// Confirm the enrolment with a code from the new secret
+		enrollment, err := totpRepo.GetPendingTOTP(ctx, user.ID.String())
+		require.NoError(t, err)
+		pendingSecret, err := secretCipher.Decrypt(ctx, enrollment.Secret)
+		require.NoError(t, err)
+		confirmCode, err := extotp.GenerateCode(pendingSecret, time.Now())
+		require.NoError(t, err)
+		totp, err := totpService.ConfirmTOTP(ctx, user.ID.String(), confirmCode)
 		if err != nil {
 			fmt.Printf("[DEBUG] Erro ao habilitar TOTP: %v\n", err)
 		}
 		require.NoError(t, err)
 		fmt.Printf("[DEBUG] TOTP habilitado: %+v\n", totp)
-		assert.NotNil(t, totp.QRCode)
 		assert.NotEmpty(t, totp.BackupCodes)
 
-		// Verificar se segredo TOTP foi salvo
-		secretCheck, err := totpService.GetTOTPSecret(ctx, user.ID.String())
-		if err != nil {
-			fmt.Printf("[DEBUG] Erro ao checar segredo TOTP após Enable: %v\n", err)
-		}
-		fmt.Printf("[DEBUG] Segredo TOTP após Enable: %s\n", secretCheck)
-
 		// Try to login - should get MFA ticket
 		fmt.Printf("[DEBUG] Chamando Login para gerar ticket MFA...\n")
-		result, err := authService.Login(ctx, "totp@example.com", "password123")
+		result, err = authService.Login(ctx, "totp@example.com", "Correct-Horse-7")
 		if err != nil {
 			fmt.Printf("[DEBUG] Erro no Login: %v\n", err)
 		}
@@ -340,19 +557,22 @@ func TestAuthService_Integration(t *testing.T) {
 		assert.Equal(t, user.ID.String(), ticket.User)
 
 		// Generate a valid TOTP code
-		secret, err := totpService.GetTOTPSecret(ctx, user.ID.String())
+		sealedSecret, err := totpRepo.GetTOTPSecret(ctx, user.ID.String(), totp.FactorID)
 		if err != nil {
 			fmt.Printf("[DEBUG] Erro ao obter segredo TOTP: %v\n", err)
 		}
 		require.NoError(t, err)
-		code, err := extotp.GenerateCode(secret, time.Now())
+		secret, err := secretCipher.Decrypt(ctx, sealedSecret.Secret)
+		require.NoError(t, err)
+		// The confirmation code is spent, so sign in with the next one
+		code, err := extotp.GenerateCode(secret, time.Now().Add(30*time.Second))
 		if err != nil {
 			fmt.Printf("[DEBUG] Erro ao gerar código TOTP: %v\n", err)
 		}
 		require.NoError(t, err)
 
 		// Verify MFA and get tokens
-		result, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), code)
+		result, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), "", code, nil)
 		if err != nil {
 			fmt.Printf("[DEBUG] Erro no VerifyMFA: %v\n", err)
 		}
@@ -366,7 +586,7 @@ func TestAuthService_Integration(t *testing.T) {
 		assert.NotEmpty(t, tokens.RefreshToken)
 
 		// Try to use the same ticket again - should fail
-		_, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), code)
+		_, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), "", code, nil)
 		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
 
 		// Try to use an expired ticket
@@ -379,19 +599,127 @@ func TestAuthService_Integration(t *testing.T) {
 		err = mfaTicketRepo.Create(ctx, expiredTicket)
 		require.NoError(t, err)
 
-		_, err = authService.VerifyMFA(ctx, expiredTicket.Ticket.String(), code)
+		_, err = authService.VerifyMFA(ctx, expiredTicket.Ticket.String(), "", code, nil)
 		assert.ErrorIs(t, err, domain.ErrMFATicketExpired)
 
 		// Try to use an invalid ticket
-		_, err = authService.VerifyMFA(ctx, "invalid", code)
+		_, err = authService.VerifyMFA(ctx, "invalid", "", code, nil)
 		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
 
 		// Try to use an invalid code (ticket já foi deletado, então retorna invalid ticket)
-		_, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), "000000")
+		_, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), "", "000000", nil)
 		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
 
 		// Try to use a backup code (ticket já foi deletado, então retorna invalid ticket)
-		_, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), totp.BackupCodes[0])
+		_, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), "", totp.BackupCodes[0], nil)
 		assert.ErrorIs(t, err, domain.ErrInvalidMFATicket)
+
+		// A backup code completes the sign-in without naming the recovery factor, but only once
+		result, err = authService.Login(ctx, "totp@example.com", "Correct-Horse-7")
+		require.NoError(t, err)
+		ticket, ok = result.(*domain.MFATicket)
+		require.True(t, ok)
+		result, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), "", totp.BackupCodes[0], nil)
+		require.NoError(t, err)
+		assert.IsType(t, &domain.TokenPair{}, result)
+
+		result, err = authService.Login(ctx, "totp@example.com", "Correct-Horse-7")
+		require.NoError(t, err)
+		ticket, ok = result.(*domain.MFATicket)
+		require.True(t, ok)
+		_, err = authService.VerifyMFA(ctx, ticket.Ticket.String(), "", totp.BackupCodes[0], nil)
+		assert.ErrorIs(t, err, domain.ErrInvalidTOTPCode)
+
+		// An administrator resets the MFA of the user, who then signs in with the password alone
+		emailSvc.On("SendMFAResetEmail", mock.Anything, "totp@example.com", mock.Anything).Return(nil).Once()
+		admin := ulid.Make()
+		reset, err := mfaService.ResetMFA(domain.WithClientIP(ctx, "203.0.113.7"), admin.String(), user.ID.String(), domain.MFAResetIDDocument, "Lost phone")
+		require.NoError(t, err)
+		assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorTOTP, domain.MFAFactorRecovery}, reset.RemovedFactors)
+
+		factors, err := mfaService.ListFactors(ctx, user.ID.String())
+		require.NoError(t, err)
+		assert.Empty(t, factors)
+		result, err = authService.Login(ctx, "totp@example.com", "Correct-Horse-7")
+		require.NoError(t, err)
+		assert.IsType(t, &domain.TokenPair{}, result)
+
+		resets, err := mfaService.ListResets(ctx, user.ID.String())
+		require.NoError(t, err)
+		require.Len(t, resets, 1)
+		assert.Equal(t, admin, resets[0].AdminID)
+		assert.Equal(t, domain.MFAResetIDDocument, resets[0].VerificationMethod)
+		assert.Equal(t, "203.0.113.7", resets[0].ClientIP)
+		assert.Equal(t, reset.RemovedFactors, resets[0].RemovedFactors)
+
+		_, err = mfaService.ResetMFA(ctx, admin.String(), user.ID.String(), domain.MFAResetIDDocument, "Lost phone")
+		assert.ErrorIs(t, err, domain.ErrNoMFAFactors)
+	})
+	t.Run("Passkey Flow", func(t *testing.T) {
+		user, err := authService.Register(ctx, "Passkey User", "passkey@example.com", "Correct-Horse-7", "1234567890")
+		require.NoError(t, err)
+		err = authService.VerifyEmail(ctx, "passkey@example.com", emailSvc.verificationCode)
+		require.NoError(t, err)
+
+		authenticator, err := webauthntest.NewAuthenticator("localhost", "http://localhost:8080")
+		require.NoError(t, err)
+
+		// Register a passkey
+		registration, err := webAuthnService.BeginRegistration(ctx, user.ID.String())
+		require.NoError(t, err)
+		attestation, err := authenticator.Register(registration.PublicKey.Challenge, registration.PublicKey.User.ID)
+		require.NoError(t, err)
+		credential, err := webAuthnService.FinishRegistration(ctx, user.ID.String(), registration.Session, "Laptop", attestation)
+		require.NoError(t, err)
+		assert.Equal(t, "Laptop", credential.Name)
+
+		// The registration challenge cannot be answered twice
+		_, err = webAuthnService.FinishRegistration(ctx, user.ID.String(), registration.Session, "Laptop", attestation)
+		assert.ErrorIs(t, err, domain.ErrInvalidWebAuthnChallenge)
+
+		// Sign in with the passkey as the only factor
+		login, err := webAuthnService.BeginLogin(ctx)
+		require.NoError(t, err)
+		assertion, err := authenticator.Assert(login.PublicKey.Challenge)
+		require.NoError(t, err)
+		tokens, err := webAuthnService.FinishLogin(ctx, login.Session, assertion)
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+
+		// A replayed assertion is rejected
+		_, err = webAuthnService.FinishLogin(ctx, login.Session, assertion)
+		assert.ErrorIs(t, err, domain.ErrInvalidWebAuthnChallenge)
+
+		// Use the passkey as the second factor of an MFA ticket
+		ticket := &domain.MFATicket{
+			Ticket:    ulid.Make(),
+			User:      user.ID.String(),
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(5 * time.Minute),
+		}
+		require.NoError(t, mfaTicketRepo.Create(ctx, ticket))
+		mfa, err := webAuthnService.BeginMFA(ctx, ticket.Ticket.String())
+		require.NoError(t, err)
+		require.Len(t, mfa.PublicKey.AllowCredentials, 1)
+		assertion, err = authenticator.Assert(mfa.PublicKey.Challenge)
+		require.NoError(t, err)
+		tokens, err = webAuthnService.FinishMFA(ctx, ticket.Ticket.String(), mfa.Session, assertion)
+		require.NoError(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+
+		// Manage the passkey
+		credentials, err := webAuthnService.ListCredentials(ctx, user.ID.String())
+		require.NoError(t, err)
+		require.Len(t, credentials, 1)
+		assert.Equal(t, uint32(2), credentials[0].SignCount)
+		assert.NotNil(t, credentials[0].LastUsedAt)
+
+		require.NoError(t, webAuthnService.RenameCredential(ctx, user.ID.String(), credential.ID.String(), "Work laptop"))
+		assert.ErrorIs(t, webAuthnService.DeleteCredential(ctx, ulid.Make().String(), credential.ID.String()), domain.ErrWebAuthnCredentialNotFound)
+		require.NoError(t, webAuthnService.DeleteCredential(ctx, user.ID.String(), credential.ID.String()))
+
+		credentials, err = webAuthnService.ListCredentials(ctx, user.ID.String())
+		require.NoError(t, err)
+		assert.Empty(t, credentials)
 	})
 }
@@ -2,6 +2,8 @@ package integration
 
 import (
 	"context"
+	"encoding/base64"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/manorfm/authM/internal/domain"
 	"github.com/manorfm/authM/internal/infrastructure/database"
 	"github.com/manorfm/authM/internal/infrastructure/repository"
+	"github.com/manorfm/authM/internal/infrastructure/secrets"
 	"github.com/manorfm/authM/internal/infrastructure/totp"
 	"github.com/oklog/ulid/v2"
 	extotp "github.com/pquerna/otp/totp"
@@ -33,10 +36,26 @@ func TestTOTPService_Integration(t *testing.T) {
 	// Setup repositories
 	userRepo := repository.NewUserRepository(db, logger)
 	totpRepo := repository.NewTOTPRepository(db, logger)
+	mfaFactorRepo := repository.NewMFAFactorRepository(db, logger)
 
 	// Setup TOTP service with real generator
 	totpGenerator := totp.NewGenerator(logger)
-	totpService := application.NewTOTPService(totpRepo, totpGenerator, logger)
+	cfg.TOTPEnrollmentTTL = 10 * time.Minute
+	cfg.TOTPIssuer = "AuthM"
+	cfg.TOTPAlgorithm = "SHA1"
+	cfg.TOTPDigits = 6
+	cfg.TOTPPeriod = 30 * time.Second
+	cfg.TOTPBackupCodesCount = 10
+	cfg.TOTPBackupCodesThreshold = 3
+	cfg.SecretKeyProvider = "local"
+	cfg.SecretEncryptionKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+	secretCipher := secrets.NewCipher(cfg, logger)
+	emailSvc := &MockEmailService{}
+	cfg.LockoutThreshold = 5
+	cfg.LockoutDuration = 15 * time.Minute
+	cfg.LockoutIPThreshold = 20
+	lockoutService := application.NewLockoutService(repository.NewLoginAttemptRepository(db, logger), userRepo, emailSvc, cfg, logger)
+	totpService := application.NewTOTPService(totpRepo, mfaFactorRepo, totpGenerator, secretCipher, userRepo, emailSvc, lockoutService, cfg, logger)
 
 	t.Run("Enable and Verify TOTP", func(t *testing.T) {
 		// Create a test user
@@ -67,34 +86,101 @@ func TestTOTPService_Integration(t *testing.T) {
 		require.Equal(t, user.ID, fetched.ID)
 
 		t.Logf("Enabling TOTP for user ID: %s", user.ID.String())
-		// Enable TOTP
-		totp, err := totpService.EnableTOTP(user.ID.String())
+		// Start enrolling TOTP
+		pending, err := totpService.EnableTOTP(user.ID.String(), "")
 		if err != nil {
 			t.Logf("EnableTOTP error: %+v", err)
 		}
 		require.NoError(t, err)
-		assert.NotNil(t, totp.QRCode)
-		assert.NotEmpty(t, totp.BackupCodes)
+		assert.True(t, strings.HasPrefix(pending.QRCode, "data:image/png;base64,"))
+		assert.True(t, strings.HasPrefix(pending.QRCodeSVG, "<svg"))
+		assert.True(t, strings.HasPrefix(pending.OTPAuthURI, "otpauth://totp/AuthM:test@example.com?"))
+		assert.Empty(t, pending.FactorID)
+		assert.Empty(t, pending.BackupCodes)
+
+		// Nothing is active until the enrolment is confirmed
+		enabled, err := totpService.IsTOTPEnabled(ctx, user.ID.String())
+		require.NoError(t, err)
+		assert.False(t, enabled)
+
+		_, err = totpService.ConfirmTOTP(ctx, user.ID.String(), "000000")
+		assert.ErrorIs(t, err, domain.ErrInvalidTOTPCode)
+
+		// Confirm with a code from the new secret
+		enrollment, err := totpRepo.GetPendingTOTP(ctx, user.ID.String())
+		require.NoError(t, err)
+		pendingSecret, err := secretCipher.Decrypt(ctx, enrollment.Secret)
+		require.NoError(t, err)
+		confirmCode, err := extotp.GenerateCode(pendingSecret, time.Now())
+		require.NoError(t, err)
+
+		totp, err := totpService.ConfirmTOTP(ctx, user.ID.String(), confirmCode)
+		require.NoError(t, err)
+		assert.NotEmpty(t, totp.FactorID)
 		assert.Len(t, totp.BackupCodes, 10)
 
-		// Retrieve the TOTP secret
-		secret, err := totpService.GetTOTPSecret(ctx, user.ID.String())
+		_, err = totpService.ConfirmTOTP(ctx, user.ID.String(), confirmCode)
+		assert.ErrorIs(t, err, domain.ErrTOTPEnrollmentNotFound)
+
+		// The secret is stored encrypted
+		sealedSecret, err := totpRepo.GetTOTPSecret(ctx, user.ID.String(), totp.FactorID)
+		require.NoError(t, err)
+		assert.Equal(t, enrollment.Secret, sealedSecret.Secret)
+		assert.NotContains(t, sealedSecret.Secret, pendingSecret)
+		secret, err := secretCipher.Decrypt(ctx, sealedSecret.Secret)
+		require.NoError(t, err)
+		assert.Equal(t, pendingSecret, secret)
+
+		// Only hashes of the backup codes are stored
+		storedCodes, err := totpRepo.GetBackupCodes(ctx, user.ID.String())
 		require.NoError(t, err)
-		assert.NotEmpty(t, secret)
+		require.Len(t, storedCodes, 10)
+		assert.NotContains(t, storedCodes, totp.BackupCodes[0])
+
+		// The confirmation code is spent
+		err = totpService.VerifyTOTP(user.ID.String(), confirmCode)
+		assert.ErrorIs(t, err, domain.ErrTOTPCodeReused)
 
-		// Generate a valid TOTP code
-		code, err := extotp.GenerateCode(secret, time.Now())
+		// Generate the next TOTP code, which is within the allowed clock drift
+		code, err := extotp.GenerateCode(secret, time.Now().Add(30*time.Second))
 		require.NoError(t, err)
 		assert.NotEmpty(t, code)
 
-		// Verify TOTP code
+		// Verify TOTP code, only once
 		err = totpService.VerifyTOTP(user.ID.String(), code)
 		require.NoError(t, err)
+		err = totpService.VerifyTOTP(user.ID.String(), code)
+		assert.ErrorIs(t, err, domain.ErrTOTPCodeReused)
+
+		// The authenticator app and the backup codes are registered as factors
+		factors, err := mfaFactorRepo.ListByUser(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []domain.MFAFactorType{domain.MFAFactorTOTP, domain.MFAFactorRecovery}, domain.MFAFactorTypes(factors))
+		assert.NotNil(t, factors[0].LastUsedAt)
 
 		// Verify backup code
 		err = totpService.VerifyBackupCode(user.ID.String(), totp.BackupCodes[0])
 		require.NoError(t, err)
 
+		status, err := totpService.BackupCodesStatus(ctx, user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, &domain.BackupCodesStatus{Remaining: 9, Total: 10}, status)
+
+		// Regenerating the backup codes takes a fresh authenticator code and voids the old set
+		_, err = totpService.RegenerateBackupCodes(ctx, user.ID.String(), code)
+		assert.ErrorIs(t, err, domain.ErrTOTPCodeReused)
+		nextCode, err := extotp.GenerateCode(secret, time.Now().Add(60*time.Second))
+		require.NoError(t, err)
+		backupCodes, err := totpService.RegenerateBackupCodes(ctx, user.ID.String(), nextCode)
+		require.NoError(t, err)
+		assert.Len(t, backupCodes, 10)
+
+		err = totpService.VerifyBackupCode(user.ID.String(), totp.BackupCodes[1])
+		assert.ErrorIs(t, err, domain.ErrInvalidTOTPBackupCode)
+		status, err = totpService.BackupCodesStatus(ctx, user.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, &domain.BackupCodesStatus{Remaining: 10, Total: 10}, status)
+
 		// Disable TOTP
 		err = totpService.DisableTOTP(user.ID.String())
 		require.NoError(t, err)
@@ -106,8 +192,12 @@ func TestTOTPService_Integration(t *testing.T) {
 
 	t.Run("Invalid TOTP Operations", func(t *testing.T) {
 		// Try to enable TOTP for non-existent user
-		_, err := totpService.EnableTOTP("non-existent")
-		assert.ErrorIs(t, err, domain.ErrDatabaseQuery)
+		_, err := totpService.EnableTOTP(ulid.Make().String(), "")
+		assert.ErrorIs(t, err, domain.ErrUserNotFound)
+
+		// Try to enable TOTP with a malformed user ID
+		_, err = totpService.EnableTOTP("non-existent", "")
+		assert.ErrorIs(t, err, domain.ErrInvalidUserID)
 
 		// Try to verify TOTP for non-existent user
 		err = totpService.VerifyTOTP("non-existent", "123456")
@@ -135,8 +225,7 @@ func TestTOTPService_Integration(t *testing.T) {
 		require.NoError(t, err)
 
 		// Enable TOTP
-		totp, err := totpService.EnableTOTP(user.ID.String())
-		require.NoError(t, err)
+		totp, _ := enrollTOTP(t, totpService, totpRepo, secretCipher, user.ID.String(), "")
 
 		// Try invalid TOTP code
 		err = totpService.VerifyTOTP(user.ID.String(), "000000")
@@ -170,30 +259,30 @@ func TestTOTPService_Integration(t *testing.T) {
 		require.NoError(t, err)
 
 		// Enable TOTP first time
-		totp1, err := totpService.EnableTOTP(user.ID.String())
-		require.NoError(t, err)
+		totp1, secret1 := enrollTOTP(t, totpService, totpRepo, secretCipher, user.ID.String(), "")
 
-		// Store the first secret
-		secret1, err := totpService.GetTOTPSecret(ctx, user.ID.String())
+		// Starting an enrolment again replaces the pending secret
+		_, err = totpService.EnableTOTP(user.ID.String(), "Tablet")
 		require.NoError(t, err)
-		assert.NotEmpty(t, secret1)
 
-		// Try to enable TOTP again
-		_, err = totpService.EnableTOTP(user.ID.String())
-		assert.ErrorIs(t, err, domain.ErrTOTPAlreadyEnabled)
+		// A second authenticator app keeps the existing backup codes
+		extra, extraSecret := enrollTOTP(t, totpService, totpRepo, secretCipher, user.ID.String(), "Tablet")
+		assert.NotEqual(t, totp1.FactorID, extra.FactorID)
+		assert.Empty(t, extra.BackupCodes)
+
+		extraCode, err := extotp.GenerateCode(extraSecret, time.Now().Add(30*time.Second))
+		require.NoError(t, err)
+		err = totpService.VerifyTOTPFactor(ctx, user.ID.String(), totp1.FactorID, extraCode)
+		assert.ErrorIs(t, err, domain.ErrInvalidTOTPCode)
+		err = totpService.VerifyTOTPFactor(ctx, user.ID.String(), extra.FactorID, extraCode)
+		require.NoError(t, err)
 
 		// Disable TOTP
 		err = totpService.DisableTOTP(user.ID.String())
 		require.NoError(t, err)
 
 		// Enable TOTP second time
-		totp2, err := totpService.EnableTOTP(user.ID.String())
-		require.NoError(t, err)
-
-		// Retrieve the new secret
-		secret2, err := totpService.GetTOTPSecret(ctx, user.ID.String())
-		require.NoError(t, err)
-		assert.NotEmpty(t, secret2)
+		totp2, secret2 := enrollTOTP(t, totpService, totpRepo, secretCipher, user.ID.String(), "")
 
 		// Verify new configuration is different
 		assert.NotEqual(t, totp1.QRCode, totp2.QRCode)
@@ -209,9 +298,30 @@ func TestTOTPService_Integration(t *testing.T) {
 		assert.ErrorIs(t, err, domain.ErrInvalidTOTPCode)
 
 		// Verify new TOTP code works
-		newCode, err := extotp.GenerateCode(secret2, time.Now())
+		newCode, err := extotp.GenerateCode(secret2, time.Now().Add(30*time.Second))
 		require.NoError(t, err)
 		err = totpService.VerifyTOTP(user.ID.String(), newCode)
 		require.NoError(t, err)
 	})
 }
+
+// enrollTOTP enrols an authenticator app and confirms it, returning the result and its secret
+func enrollTOTP(t *testing.T, service domain.TOTPService, repo domain.TOTPRepository, cipher domain.SecretCipher, userID, label string) (*domain.TOTP, string) {
+	ctx := context.Background()
+
+	pending, err := service.EnableTOTP(userID, label)
+	require.NoError(t, err)
+
+	enrollment, err := repo.GetPendingTOTP(ctx, userID)
+	require.NoError(t, err)
+	secret, err := cipher.Decrypt(ctx, enrollment.Secret)
+	require.NoError(t, err)
+	code, err := extotp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+
+	totp, err := service.ConfirmTOTP(ctx, userID, code)
+	require.NoError(t, err)
+	totp.QRCode = pending.QRCode
+
+	return totp, secret
+}